@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/alerting"
+	"tiger2go/internal/audit"
+	"tiger2go/internal/table"
+)
+
+// runAck acknowledges critical alerts, stopping their escalation, or lists
+// the open ones.
+//
+//	tigerfetch ack
+//	tigerfetch ack --by alice CVE-2026-1234
+func runAck(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ack", flag.ContinueOnError)
+	by := fs.String("by", "", "who acknowledges (default $USER)")
+	format := fs.String("format", "table", "output format of the open alerts: table or json")
+	tf := addTableFlags(fs, ackTable)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch ack [flags] [CVE...]\n\n"+
+			"Without CVEs, lists the critical alerts nobody acknowledged yet.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return ackAlerts(ctx, fs.Args(), *by)
+	}
+
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := ackTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	list, err := alerting.OpenAlerts(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if list == nil {
+			list = []alerting.CriticalAlert{}
+		}
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	case "table":
+		if err := ackTable.Write(&buf, list, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func ackAlerts(ctx context.Context, ids []string, by string) error {
+	if by == "" {
+		if u, err := user.Current(); err == nil {
+			by = u.Username
+		}
+	}
+	for i, id := range ids {
+		ids[i] = strings.ToUpper(id)
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	by = cmp.Or(by, "unknown")
+	acked, err := alerting.Acknowledge(ctx, pool, ids, by)
+	if err != nil {
+		return err
+	}
+	trail := auditLog(cfg, pool)
+	for _, id := range ids {
+		if slices.Contains(acked, id) {
+			fmt.Printf("%s acknowledged\n", id)
+			if err := trail.Record(ctx, audit.AlertAck, id, map[string]any{"by": by}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("%s: no open critical alert\n", id)
+		}
+	}
+	return nil
+}
+
+var ackTable = table.Table[alerting.CriticalAlert]{
+	Columns: []table.Column[alerting.CriticalAlert]{
+		{Name: "cve", Value: func(a alerting.CriticalAlert) string { return a.CVEID }},
+		{Name: "products", Flex: true, Value: func(a alerting.CriticalAlert) string { return strings.Join(a.Products, ", ") }},
+		{Name: "vendor", Value: func(a alerting.CriticalAlert) string { return a.VendorProject }},
+		{Name: "product", Value: func(a alerting.CriticalAlert) string { return a.Product }},
+		{Name: "name", Flex: true, Value: func(a alerting.CriticalAlert) string { return a.VulnerabilityName }},
+		{Name: "ransomware", Value: func(a alerting.CriticalAlert) string { return strconv.FormatBool(a.Ransomware) }},
+		{Name: "due", Value: func(a alerting.CriticalAlert) string { return a.DueDate }},
+		{Name: "detected", Time: func(a alerting.CriticalAlert) time.Time { return a.DetectedAt }, Layout: time.DateTime},
+		{Name: "escalated", Time: func(a alerting.CriticalAlert) time.Time { return timeOf(a.EscalatedAt) }, Layout: time.DateTime},
+	},
+	Defaults: []string{"cve", "products", "name", "due", "detected", "escalated"},
+	Empty:    "No open critical alerts.",
+}
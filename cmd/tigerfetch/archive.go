@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/rawarchive"
+	"tiger2go/internal/table"
+)
+
+// runArchive lists the raw upstream payloads kept by [raw_archive], prints
+// one, or rebuilds the derived tables from them.
+//
+//	tigerfetch archive --source kev
+//	tigerfetch archive show 42 > catalog.json
+//	tigerfetch archive rebuild --since 2026-10-01
+func runArchive(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "show":
+			return runArchiveShow(ctx, args[1:])
+		case "rebuild":
+			return runArchiveRebuild(ctx, args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	source := fs.String("source", "", "only payloads from this source: nvd, kev, epss, feeds or inbound")
+	since := fs.String("since", "", "only payloads first fetched on or after this date (YYYY-MM-DD)")
+	limit := fs.Int("limit", 50, "show at most this many payloads, most recently fetched first (0: all)")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, archiveTable)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch archive [flags]\n       tigerfetch archive show ID\n       tigerfetch archive rebuild [--source S] [--since DATE]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts, err := archiveListOptions(*source, *since)
+	if err != nil {
+		return err
+	}
+
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := archiveTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+	archive, err := rawarchive.Open(pool, cfg.RawArchive)
+	if err != nil {
+		return err
+	}
+
+	opts.Newest, opts.Limit = true, *limit
+	list, err := archive.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if list == nil {
+			list = []rawarchive.Entry{}
+		}
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	case "table":
+		if err := archiveTable.Write(&buf, list, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func archiveListOptions(source, since string) (rawarchive.ListOptions, error) {
+	opts := rawarchive.ListOptions{Source: source}
+	if source != "" && !slices.Contains(quarantineSources, source) {
+		return opts, fmt.Errorf("invalid --source %q", source)
+	}
+	if since != "" {
+		t, err := time.Parse(time.DateOnly, since)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --since %q: want YYYY-MM-DD", since)
+		}
+		opts.Since = t
+	}
+	return opts, nil
+}
+
+// runArchiveRebuild re-processes archived payloads in the order they were
+// first fetched, through the same code as `tigerfetch replay`. Run it
+// against emptied derived tables, or a new database restored from the
+// archive, after a schema or parser change; `tigerfetch reprocess` updates
+// populated tables in place.
+func runArchiveRebuild(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("archive rebuild", flag.ContinueOnError)
+	source := fs.String("source", "", "only payloads from this source: nvd, kev, epss, feeds or inbound")
+	since := fs.String("since", "", "only payloads first fetched on or after this date (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts, err := archiveListOptions(*source, *since)
+	if err != nil {
+		return err
+	}
+	return processArchive(ctx, opts, false)
+}
+
+// runArchiveShow writes an archived payload to stdout as upstream sent it.
+func runArchiveShow(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected: archive show ID")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid payload ID %q", args[0])
+	}
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	archive, err := rawarchive.Open(pool, cfg.RawArchive)
+	if err != nil {
+		return err
+	}
+	e, err := archive.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(e.Payload)
+	return err
+}
+
+var archiveTable = table.Table[rawarchive.Entry]{
+	Columns: []table.Column[rawarchive.Entry]{
+		{Name: "id", Value: func(e rawarchive.Entry) string { return strconv.FormatInt(e.ID, 10) }},
+		{Name: "source", Value: func(e rawarchive.Entry) string { return e.Source }},
+		{Name: "url", Flex: true, Value: func(e rawarchive.Entry) string { return e.URL }},
+		{Name: "size", Value: func(e rawarchive.Entry) string { return strconv.Itoa(e.Size) }},
+		{Name: "object", Flex: true, Value: func(e rawarchive.Entry) string { return e.ObjectURL }},
+		{Name: "first_fetched", Time: func(e rawarchive.Entry) time.Time { return e.FirstFetched }, Layout: time.DateTime},
+		{Name: "last_fetched", Time: func(e rawarchive.Entry) time.Time { return e.LastFetched }, Layout: time.DateTime},
+	},
+	Defaults: []string{"id", "source", "url", "size", "first_fetched", "last_fetched"},
+	Empty:    "No archived payloads.",
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runAudit lists the audit trail recorded in the audit_log table, for
+// review or as signed compliance evidence.
+//
+//	tigerfetch audit --since 720h --action cursor.
+//	tigerfetch audit --since 2026-01-01 --format json --output evidence/audit.json --sign-key key.pem
+func runAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	since := fs.String("since", "168h", "only events at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now")
+	until := fs.String("until", "", "only events before this time, in the same forms as --since")
+	action := fs.String("action", "", `only this action, or those starting with a prefix ending in ".", e.g. "cursor."`)
+	actor := fs.String("actor", "", "only events recorded as this actor")
+	run := fs.String("run", "", "only events of this run ID")
+	limit := fs.Int("limit", 0, "at most this many events, newest first (0 for no limit)")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, auditTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	win, err := window.Parse(*since, *until, "", time.Now())
+	if err != nil {
+		return err
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := auditTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if cfg.Audit.Path != "" {
+		return errors.New("audit.path is set: events are appended to that file, not the audit_log table")
+	}
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	events, err := audit.List(ctx, pool, audit.Filter{
+		Since: win.Since, Until: win.Until, Action: *action, Actor: *actor, Run: *run, Limit: *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		if events == nil {
+			events = []audit.Event{}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(events); err != nil {
+			return err
+		}
+	case "table":
+		if err := auditTable.Write(&buf, events, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var auditTable = table.Table[audit.Event]{
+	Columns: []table.Column[audit.Event]{
+		{Name: "time", Time: func(e audit.Event) time.Time { return e.Time }, Layout: time.DateTime},
+		{Name: "actor", Value: func(e audit.Event) string { return e.Actor }},
+		{Name: "action", Value: func(e audit.Event) string { return e.Action }},
+		{Name: "target", Flex: true, Value: func(e audit.Event) string { return e.Target }},
+		{Name: "detail", Flex: true, Value: func(e audit.Event) string {
+			if e.Detail == nil {
+				return ""
+			}
+			b, _ := json.Marshal(e.Detail)
+			return string(b)
+		}},
+		{Name: "run", Value: func(e audit.Event) string { return e.Run }},
+	},
+	Defaults: []string{"time", "actor", "action", "target", "detail"},
+}
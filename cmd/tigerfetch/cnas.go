@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runCNAs breaks stored NVD CVEs down by the CNA that issued them and counts
+// those NVD has not analysed yet.
+//
+//	tigerfetch cnas --since 720h --products
+func runCNAs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cnas", flag.ContinueOnError)
+	since := fs.String("since", "720h", "only count CVEs first seen at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now")
+	until := fs.String("until", "", "only count CVEs first seen before this time, in the same forms as --since")
+	products := fs.Bool("products", false, "only count CVEs matched to [[nvd.products]]; the window applies to when they matched")
+	product := fs.String("product", "", "only count CVEs matched to this product (implies --products)")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, cnaTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	if *format != "json" && *format != "table" {
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	win, err := window.Parse(*since, *until, "", time.Now())
+	if err != nil {
+		return err
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := cnaTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	report, err := cve.CNABreakdown(ctx, pool, cve.CNAOptions{
+		Since:    win.Since,
+		Until:    win.Until,
+		Products: *products || *product != "",
+		Product:  *product,
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if *format == "json" {
+		if report.CNAs == nil {
+			report.CNAs = []cve.CNAStat{}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := writeCNAs(&buf, report, topts); err != nil {
+		return err
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var cnaTable = table.Table[cve.CNAStat]{
+	Columns: []table.Column[cve.CNAStat]{
+		{Name: "cna", Flex: true, Value: func(s cve.CNAStat) string {
+			if s.SourceIdentifier == "" {
+				return "(unknown)"
+			}
+			return s.SourceIdentifier
+		}},
+		{Name: "cves", Value: func(s cve.CNAStat) string { return strconv.Itoa(s.CVEs) }},
+		{Name: "awaiting", Value: func(s cve.CNAStat) string { return strconv.Itoa(s.AwaitingAnalysis) }},
+		{Name: "analyzed", Value: func(s cve.CNAStat) string { return strconv.Itoa(s.Analyzed) }},
+		{Name: "kev", Value: func(s cve.CNAStat) string { return strconv.Itoa(s.InKEV) }},
+		{Name: "max_cvss", Value: func(s cve.CNAStat) string { return formatFloat(s.MaxCVSS, 1) }},
+	},
+	Defaults: []string{"cna", "cves", "awaiting", "analyzed", "kev", "max_cvss"},
+	Empty:    "No NVD CVEs in window.",
+}
+
+// writeCNAs prints the NVD status totals followed by the per-CNA table.
+func writeCNAs(w io.Writer, r *cve.CNAReport, opts table.Options) error {
+	statuses := make([]string, 0, len(r.ByStatus))
+	for s := range r.ByStatus {
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if r.ByStatus[a] != r.ByStatus[b] {
+			return r.ByStatus[a] > r.ByStatus[b]
+		}
+		return a < b
+	})
+	_, _ = fmt.Fprintf(w, "%d CVEs", r.Total)
+	for i, s := range statuses {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		name := s
+		if name == "" {
+			name = "unknown"
+		}
+		_, _ = fmt.Fprintf(w, "%s%d %s", sep, r.ByStatus[s], name)
+	}
+	_, _ = fmt.Fprint(w, "\n\n")
+	return cnaTable.Write(w, r.CNAs, opts)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// command is a one-shot CLI subcommand. Running tigerfetch without a
+// subcommand starts the long-running daemon instead.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"kev-changes", "List CVEs added to, updated in or removed from CISA KEV", runKevChanges},
+		{"kev-import", "Import archived KEV catalog releases into the change history", runKevImport},
+		{"kev-stats", "KEV listings per month or year and time from publication to listing", runKevStats},
+		{"coverage-gaps", "List CVEs referenced by advisories with no EPSS score or NVD record yet", runCoverageGaps},
+		{"enrich", "Merge NVD, KEV and EPSS data for a list of CVEs", runEnrich},
+		{"consolidate", "Rebuild the consolidated per-CVE records served by GET /cves", runConsolidate},
+		{"summary", "Digest of new KEV entries, EPSS movers and newly critical CVEs", runSummary},
+		{"cnas", "Break NVD CVEs down by issuing CNA and analysis status", runCNAs},
+		{"query", "Select stored advisories with a filter expression", runQuery},
+		{"iocs", "Export indicators of compromise found in advisories", runIOCs},
+		{"jobs", "List ingestion jobs and retry dead ones", runJobs},
+		{"source", "List pipeline sources, switch them off and on at runtime, or run one now", runSource},
+		{"cursor", "Show the ingestion cursors runners resume from, or set or reset one", runCursor},
+		{"audit", "List the audit trail of source switches, cursor moves, acknowledgements and notifications", runAudit},
+		{"archive", "List raw upstream payloads kept by [raw_archive] and rebuild the derived tables from them", runArchive},
+		{"reprocess", "Re-parse archived raw payloads to backfill fields into stored records without upstream traffic", runReprocess},
+		{"replay", "List upstream payloads that failed to parse and re-process them after a parser fix", runReplay},
+		{"ack", "Acknowledge critical alerts so they are not escalated, or list open ones", runAck},
+		{"report", "Write the weekly executive report as a PDF", runWeeklyReport},
+		{"run", "Run the due fetch and enrich steps once, then exit", runRun},
+		{"serverless", "Serve single runs to AWS Lambda, Cloud Functions or Cloud Run", runServerless},
+		{"sync", "Pull another instance's change feed into this database", runSync},
+		{"openapi", "Print the OpenAPI document for the lookup API", runOpenAPI},
+		{"schema", "Print the JSON Schema of an output document or the schema changelog", runSchema},
+		{"keygen", "Create a signing key for --output artifacts", runKeygen},
+	}
+}
+
+// runCommand dispatches a subcommand and returns the process exit code.
+func runCommand(name string, args []string) int {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return 0
+	}
+
+	for _, c := range commands() {
+		if c.name != name {
+			continue
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := c.run(ctx, args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "tigerfetch %s: %v\n", name, err)
+			var exit *exitError
+			if errors.As(err, &exit) {
+				return exit.code
+			}
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "tigerfetch: unknown command %q\n\n", name)
+	printUsage()
+	return 2
+}
+
+// exitError ends a command with an exit status other than 1, for
+// schedulers that branch on it.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: tigerfetch [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nWith no command, runs the ingestion daemon.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.summary)
+	}
+}
+
+// openPool loads configuration and connects to the database for CLI commands.
+// Unlike the daemon it does not run migrations. Read-only pools (reports)
+// connect to database.read_url when set and refuse writes.
+func openPool(ctx context.Context, readOnly bool) (*config.Config, *pgxpool.Pool, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	if cfg.DatabaseURL == "" {
+		return nil, nil, errors.New("DATABASE_URL is required")
+	}
+
+	url, opts := cfg.DatabaseURL, poolOptions(cfg)
+	if readOnly {
+		url, opts.ReadOnly = cfg.ReadDatabaseURL(), true
+	}
+	pool, err := db.NewPoolWithOptions(ctx, url, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, pool, nil
+}
+
+// auditLog returns the [audit] trail of a CLI invocation, recording as the
+// user running it; nil when auditing is off.
+func auditLog(cfg *config.Config, pool *pgxpool.Pool) *audit.Log {
+	return audit.FromConfig(pool, cfg.Audit, audit.CurrentUser())
+}
+
+// poolOptions maps the [database] config section onto db.PoolOptions.
+func poolOptions(cfg *config.Config) db.PoolOptions {
+	slow, err := cfg.Database.GetSlowQueryThreshold()
+	if err != nil {
+		slog.Warn("Invalid database.slow_query_threshold, using default", "value", cfg.Database.SlowQueryThreshold, "error", err)
+		slow = 0
+	}
+	return db.PoolOptions{SlowQueryThreshold: slow, LogStatements: cfg.Database.LogStatements}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"tiger2go/internal/consolidate"
+)
+
+// runConsolidate brings cve_consolidated up to date once, as the daemon
+// does every merge.poll_interval. --rebuild reconsiders every CVE, which
+// applies a changed [merge] cvss to records whose sources did not change.
+//
+//	tigerfetch consolidate --rebuild
+func runConsolidate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("consolidate", flag.ContinueOnError)
+	rebuild := fs.Bool("rebuild", false, "reconsider every CVE, not only those with changed source records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	runner := consolidate.NewRunner(pool, cvssPriority(cfg))
+	if *rebuild {
+		return runner.Rebuild(ctx)
+	}
+	return runner.Run(ctx)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/coverage"
+	"tiger2go/internal/table"
+)
+
+// runCoverageGaps lists CVEs that advisories reference but that have no
+// EPSS score or NVD record yet, as recorded by the coverage runner.
+//
+//	tigerfetch coverage-gaps --missing epss
+//	tigerfetch coverage-gaps --all --format json
+func runCoverageGaps(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("coverage-gaps", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include closed gaps, to see how long CVEs waited for a score")
+	missing := fs.String("missing", "", "only gaps missing this: epss or nvd")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, coverageTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	switch *missing {
+	case "", coverage.MissingEPSS, coverage.MissingNVD:
+	default:
+		return fmt.Errorf("invalid --missing %q", *missing)
+	}
+
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := coverageTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	gaps, err := coverage.List(ctx, pool, coverage.ListOptions{All: *all, Missing: *missing})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if gaps == nil {
+			gaps = []coverage.Gap{}
+		}
+		if err := enc.Encode(gaps); err != nil {
+			return err
+		}
+	case "table":
+		if err := coverageTable.Write(&buf, gaps, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var coverageTable = table.Table[coverage.Gap]{
+	Columns: []table.Column[coverage.Gap]{
+		{Name: "cve", Value: func(g coverage.Gap) string { return g.CveID }},
+		{Name: "missing", Value: func(g coverage.Gap) string { return strings.Join(g.Missing(), ",") }},
+		{Name: "advisories", Value: func(g coverage.Gap) string { return strconv.Itoa(g.Advisories) }},
+		{Name: "referenced", Time: func(g coverage.Gap) time.Time { return g.FirstReferencedAt }, Layout: minuteLayout},
+		{Name: "days", Value: func(g coverage.Gap) string {
+			return strconv.FormatFloat(g.Waited(time.Now()).Hours()/24, 'f', 1, 64)
+		}},
+		{Name: "epss_at", Time: func(g coverage.Gap) time.Time { return timeOf(g.EPSSAt) }, Layout: minuteLayout},
+		{Name: "nvd_at", Time: func(g coverage.Gap) time.Time { return timeOf(g.NVDAt) }, Layout: minuteLayout},
+		{Name: "checked", Time: func(g coverage.Gap) time.Time { return g.CheckedAt }, Layout: minuteLayout},
+	},
+	Defaults: []string{"cve", "missing", "advisories", "referenced", "days"},
+	Empty:    "No coverage gaps.",
+}
+
+// minuteLayout shows coverage timestamps to the minute.
+const minuteLayout = "2006-01-02 15:04"
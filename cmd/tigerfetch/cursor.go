@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/ingeststate"
+	"tiger2go/internal/table"
+)
+
+// runCursor shows the ingest_state cursors runners resume from and moves
+// them, validated and after confirmation, instead of hand-editing the table.
+//
+//	tigerfetch cursor show
+//	tigerfetch cursor show NVD-MODIFIED
+//	tigerfetch cursor set NVD-MODIFIED 2026-03-01
+//	tigerfetch cursor reset --yes ALERTING-KEV
+func runCursor(ctx context.Context, args []string) error {
+	usage := errors.New("expected: cursor show|set|reset")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "show":
+		return runCursorShow(ctx, args[1:])
+	case "set":
+		return runCursorSet(ctx, args[1:])
+	case "reset":
+		return runCursorReset(ctx, args[1:])
+	}
+	return usage
+}
+
+// cursorRow is one cursor in `tigerfetch cursor show`.
+type cursorRow struct {
+	Source string `json:"source"`
+	Cursor string `json:"cursor"`
+	Note   string `json:"note,omitempty"` // age of a timestamp, decoded list position
+}
+
+func runCursorShow(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cursor show", flag.ContinueOnError)
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, cursorTable)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch cursor show [flags] [SOURCE]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("expected at most one source")
+	}
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := cursorTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	var entries []ingeststate.Entry
+	if source := fs.Arg(0); source != "" {
+		cur, ok, err := ingeststate.Get(ctx, pool, source)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no cursor stored for %s", source)
+		}
+		entries = []ingeststate.Entry{{Source: source, Cursor: cur}}
+	} else if entries, err = ingeststate.List(ctx, pool); err != nil {
+		return err
+	}
+	now := time.Now()
+	rows := make([]cursorRow, len(entries))
+	for i, e := range entries {
+		rows[i] = cursorRow{Source: e.Source, Cursor: e.Cursor, Note: ingeststate.Describe(e.Source, e.Cursor, now)}
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rows); err != nil {
+			return err
+		}
+	case "table":
+		if err := cursorTable.Write(&buf, rows, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// runCursorSet moves a cursor. The value is checked against the source's
+// format and normalized, so a date stands for midnight UTC.
+func runCursorSet(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cursor set", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch cursor set [flags] SOURCE VALUE\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("expected a source and a value")
+	}
+	source := fs.Arg(0)
+	value, err := ingeststate.Validate(source, fs.Arg(1), time.Now())
+	if err != nil {
+		return fmt.Errorf("cursor %s: %w", source, err)
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	old, ok, err := ingeststate.Get(ctx, pool, source)
+	if err != nil {
+		return err
+	}
+	detail := map[string]any{"from": old, "to": value}
+	if !ok {
+		old, detail["from"] = "(none)", nil
+	}
+	if old == value {
+		fmt.Printf("Cursor %s is already %s\n", source, value)
+		return nil
+	}
+	if !*yes {
+		if err := confirm(fmt.Sprintf("Move cursor %s from %s to %s?", source, old, value)); err != nil {
+			return err
+		}
+	}
+	if err := ingeststate.Set(ctx, pool, source, value); err != nil {
+		return err
+	}
+	fmt.Printf("Cursor %s set to %s (was %s)\n", source, value, old)
+	return auditLog(cfg, pool).Record(ctx, audit.CursorSet, source, detail)
+}
+
+// runCursorReset deletes a cursor, so its runner starts over as on its
+// first run.
+func runCursorReset(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cursor reset", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch cursor reset [flags] SOURCE\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("expected a source")
+	}
+	source := fs.Arg(0)
+	if !ingeststate.Known(source) {
+		return fmt.Errorf("%w %q", ingeststate.ErrUnknownSource, source)
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	old, ok, err := ingeststate.Get(ctx, pool, source)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Printf("No cursor stored for %s\n", source)
+		return nil
+	}
+	if !*yes {
+		q := fmt.Sprintf("Reset cursor %s (now %s)? Then %s.", source, old, ingeststate.ResetEffect(source))
+		if err := confirm(q); err != nil {
+			return err
+		}
+	}
+	if _, err := ingeststate.Reset(ctx, pool, source); err != nil {
+		return err
+	}
+	fmt.Printf("Cursor %s reset (was %s)\n", source, old)
+	return auditLog(cfg, pool).Record(ctx, audit.CursorReset, source, map[string]any{"was": old})
+}
+
+// confirm asks question on stderr and returns nil only when the answer read
+// from stdin is yes. With no terminal to ask on, it refuses: scripts pass
+// --yes.
+func confirm(question string) error {
+	if ok, _ := table.Terminal(os.Stdin); !ok {
+		return errors.New("stdin is not a terminal; pass --yes to confirm")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New("aborted")
+}
+
+var cursorTable = table.Table[cursorRow]{
+	Columns: []table.Column[cursorRow]{
+		{Name: "source", Value: func(r cursorRow) string { return r.Source }},
+		{Name: "cursor", Value: func(r cursorRow) string { return r.Cursor }},
+		{Name: "note", Value: func(r cursorRow) string { return r.Note }},
+	},
+	Defaults: []string{"source", "cursor", "note"},
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"tiger2go/internal/detections"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/schema"
+	"tiger2go/internal/table"
+)
+
+// runEnrich merges NVD, KEV and EPSS data for a list of CVEs, the same
+// lookup POST /enrich serves.
+//
+//	tigerfetch enrich --cve-file list.txt --format table
+func runEnrich(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	cveFile := fs.String("cve-file", "", "file of CVE IDs, one per line or comma-separated ('-' for stdin)")
+	upstream := fs.Bool("upstream", false, "fetch CVEs missing from the local store from NVD and EPSS")
+	format := fs.String("format", "", "output format: table or json (default table on a terminal, else json)")
+	schemaVersion := addSchemaVersionFlag(fs)
+	tf := addTableFlags(fs, enrichTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	if *format == "" {
+		*format = "json"
+		if stdoutIsTerminal(*output) {
+			*format = "table"
+		}
+	}
+	if *format != "json" && *format != "table" {
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	if err := checkSchemaVersion(*schemaVersion); err != nil {
+		return err
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := enrichTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	raw, err := readCveIDs(*cveFile, fs.Args())
+	if err != nil {
+		return err
+	}
+	ids, invalid := enrich.Normalize(raw)
+	for _, id := range invalid {
+		fmt.Fprintf(os.Stderr, "Skipping invalid CVE ID %q\n", id)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no CVE IDs given (use --cve-file or pass IDs as arguments)")
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	results, err := newEnricher(cfg, pool, nil, *upstream).Enrich(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if *format == "json" {
+		if results == nil {
+			results = []enrich.Result{}
+		}
+		out, err := schema.Encode("enrich", *schemaVersion, results)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	} else if err := enrichTable.Write(&buf, results, topts); err != nil {
+		return err
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+// readCveIDs collects IDs from --cve-file and positional arguments.
+func readCveIDs(path string, args []string) ([]string, error) {
+	ids := append([]string(nil), args...)
+	if path == "" {
+		return ids, nil
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open --cve-file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	fromFile, err := enrich.ReadIDs(r)
+	if err != nil {
+		return nil, fmt.Errorf("read --cve-file: %w", err)
+	}
+	return append(ids, fromFile...), nil
+}
+
+var enrichTable = table.Table[enrich.Result]{
+	Columns: []table.Column[enrich.Result]{
+		{Name: "cve", Value: func(r enrich.Result) string { return r.CveID }},
+		{Name: "cvss", Color: true, Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return formatFloat(r.NVD.CvssBase, 1)
+		}},
+		{Name: "severity", Color: true, Value: enrichSeverity},
+		{Name: "cvss_source", Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return r.NVD.CvssSource
+		}},
+		{Name: "epss", Value: func(r enrich.Result) string {
+			if r.EPSS == nil {
+				return ""
+			}
+			return formatFloat(&r.EPSS.Score, 4)
+		}},
+		{Name: "percentile", Value: func(r enrich.Result) string {
+			if r.EPSS == nil {
+				return ""
+			}
+			return formatFloat(&r.EPSS.Percentile, 3)
+		}},
+		{Name: "kev", Value: func(r enrich.Result) string { return strconv.FormatBool(r.InKEV) }},
+		{Name: "reserved", Value: func(r enrich.Result) string { return strconv.FormatBool(r.Reserved) }},
+		{Name: "due", Value: func(r enrich.Result) string {
+			if r.KEV == nil {
+				return ""
+			}
+			return r.KEV.DueDate
+		}},
+		{Name: "vendor", Flex: true, Value: func(r enrich.Result) string {
+			if r.KEV == nil {
+				return ""
+			}
+			return r.KEV.VendorProject
+		}},
+		{Name: "product", Flex: true, Value: func(r enrich.Result) string {
+			if r.KEV == nil {
+				return ""
+			}
+			return r.KEV.Product
+		}},
+		{Name: "title", Flex: true, Value: func(r enrich.Result) string {
+			if r.KEV == nil {
+				return ""
+			}
+			return r.KEV.VulnerabilityName
+		}},
+		{Name: "cna", Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return r.NVD.SourceIdentifier
+		}},
+		{Name: "status", Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return r.NVD.VulnStatus
+		}},
+		{Name: "exploit", Value: func(r enrich.Result) string {
+			return strconv.FormatBool(r.NVD != nil && r.NVD.ExploitRef)
+		}},
+		{Name: "patch", Flex: true, Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return r.NVD.PatchURL
+		}},
+		{Name: "fixed_in", Flex: true, Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return strings.Join(fixes.Labels(r.NVD.FixedIn), ", ")
+		}},
+		{Name: "advisory", Flex: true, Value: func(r enrich.Result) string {
+			if r.NVD == nil {
+				return ""
+			}
+			return r.NVD.AdvisoryLabel
+		}},
+		{Name: "eol", Flex: true, Value: func(r enrich.Result) string { return strings.Join(eol.Labels(r.EOL), ", ") }},
+		{Name: "detections", Value: func(r enrich.Result) string {
+			return strings.Join(detections.SourcesOf(r.Detections), ",")
+		}},
+		{Name: "source", Value: func(r enrich.Result) string {
+			if !r.Found {
+				return "not found"
+			}
+			return r.Source
+		}},
+	},
+	Defaults: []string{"cve", "cvss", "epss", "kev", "title", "source"},
+	Severity: enrichSeverity,
+}
+
+func enrichSeverity(r enrich.Result) string {
+	if r.NVD == nil {
+		return ""
+	}
+	return r.NVD.Severity
+}
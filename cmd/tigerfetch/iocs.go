@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/filter"
+	"tiger2go/internal/ioc"
+	"tiger2go/internal/query"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runIOCs exports the indicators of compromise found in stored advisories,
+// one row per indicator and advisory, for blocklist pipelines. --filter
+// selects advisories as in `tigerfetch query`.
+//
+//	tigerfetch iocs --since 168h --filter 'source == "CISA"' --format stix --output iocs.json
+func runIOCs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("iocs", flag.ContinueOnError)
+	expr := fs.String("filter", "true", "advisory filter expression (see `tigerfetch query --fields`)")
+	types := fs.String("types", "", "indicator types, comma-separated: "+strings.Join(ioc.Types, ", ")+" (default all)")
+	since := fs.String("since", "720h", "only advisories at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now (0 for no limit)")
+	until := fs.String("until", "", "only advisories before this time, in the same forms as --since")
+	by := fs.String("by", window.Published, "what --since and --until compare against: published or first_seen")
+	format := fs.String("format", "table", "output format: table, json, csv or stix (a STIX 2.1 bundle)")
+	tf := addTableFlags(fs, iocTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	switch *format {
+	case "table", "json", "csv", "stix":
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	want := table.Split(*types)
+	for _, t := range want {
+		if !slices.Contains(ioc.Types, t) {
+			return fmt.Errorf("unknown --types %q (available: %s)", t, strings.Join(ioc.Types, ", "))
+		}
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := iocTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+	win, err := window.Parse(*since, *until, *by, time.Now())
+	if err != nil {
+		return err
+	}
+	prog, err := filter.Compile(*expr, query.Fields)
+	if err != nil {
+		return fmt.Errorf("--filter: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	dict, err := actors.New(cfg.ActorTags)
+	if err != nil {
+		return err
+	}
+	advs, err := query.Run(ctx, pool, newEnricher(cfg, pool, nil, false), cfg.Feeds, prog, query.Options{Window: win, Actors: dict, BatchSize: cfg.AdvisoryBatchSize})
+	if err != nil {
+		return err
+	}
+	mentions := []ioc.Mention{}
+	for _, a := range advs {
+		date := a.FirstSeenAt
+		if a.Published != nil {
+			date = *a.Published
+		}
+		for _, ind := range a.IOCs {
+			if len(want) == 0 || slices.Contains(want, ind.Type) {
+				mentions = append(mentions, ioc.Mention{Indicator: ind, Source: a.Source, Title: a.Title, Link: a.Link, Date: date})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(mentions); err != nil {
+			return err
+		}
+	case "csv":
+		if err := writeIOCCSV(&buf, mentions); err != nil {
+			return err
+		}
+	case "stix":
+		if err := ioc.WriteSTIX(&buf, mentions); err != nil {
+			return err
+		}
+	default:
+		if err := iocTable.Write(&buf, mentions, topts); err != nil {
+			return err
+		}
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var iocCSVHeader = []string{"type", "value", "source", "title", "link", "date"}
+
+func writeIOCCSV(w io.Writer, mentions []ioc.Mention) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(iocCSVHeader); err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		if err := cw.Write([]string{m.Type, m.Value, m.Source, m.Title, m.Link, m.Date.UTC().Format(time.RFC3339)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var iocTable = table.Table[ioc.Mention]{
+	Columns: []table.Column[ioc.Mention]{
+		{Name: "type", Value: func(m ioc.Mention) string { return m.Type }},
+		{Name: "value", Flex: true, Value: func(m ioc.Mention) string { return m.Value }},
+		{Name: "source", Value: func(m ioc.Mention) string { return m.Source }},
+		{Name: "title", Flex: true, Value: func(m ioc.Mention) string { return m.Title }},
+		{Name: "link", Flex: true, Value: func(m ioc.Mention) string { return m.Link }},
+		{Name: "date", Time: func(m ioc.Mention) time.Time { return m.Date }},
+	},
+	Defaults: []string{"type", "value", "source", "title", "date"},
+	Empty:    "No indicators found.",
+}
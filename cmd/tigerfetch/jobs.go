@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/jobs"
+	"tiger2go/internal/table"
+)
+
+// enqueueEvery enqueues a job of kind for each of keys() now and every
+// interval after, until ctx ends. The jobs worker runs them.
+func enqueueEvery(ctx context.Context, queue jobs.Queue, interval time.Duration, kind string, keys func() []string) {
+	ticker := time.NewTimer(0) // fire immediately on first run
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range keys() {
+				if err := queue.Enqueue(ctx, kind, key); err != nil && ctx.Err() == nil {
+					slog.Error("Scheduling job failed", "kind", kind, "key", key, "error", err)
+				}
+			}
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// singleKey is the key of kinds with one job at a time.
+func singleKey() []string { return []string{""} }
+
+// runJobs lists ingestion jobs, or moves dead ones back to the queue.
+//
+//	tigerfetch jobs --state dead
+//	tigerfetch jobs retry 42 57
+func runJobs(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "retry" {
+		return runJobsRetry(ctx, args[1:])
+	}
+
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	state := fs.String("state", "", "only jobs in this state: "+strings.Join(jobs.States(), ", "))
+	kind := fs.String("kind", "", "only jobs of this kind, e.g. feed, nvd or epss")
+	limit := fs.Int("limit", 50, "show at most this many jobs, most recently updated first (0: all)")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, jobsTable)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch jobs [flags]\n       tigerfetch jobs retry ID...\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *state != "" && !slices.Contains(jobs.States(), *state) {
+		return fmt.Errorf("invalid --state %q", *state)
+	}
+
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := jobsTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := checkJobsBackend(cfg); err != nil {
+		return err
+	}
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	list, err := jobs.List(ctx, pool, jobs.ListOptions{State: *state, Kind: *kind, Limit: *limit})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if list == nil {
+			list = []jobs.Job{}
+		}
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	case "table":
+		if err := jobsTable.Write(&buf, list, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// runJobsRetry requeues dead jobs with their attempts reset.
+func runJobsRetry(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected: jobs retry ID...")
+	}
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid job ID %q", arg)
+		}
+		ids[i] = id
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := checkJobsBackend(cfg); err != nil {
+		return err
+	}
+
+	trail := auditLog(cfg, pool)
+	for _, id := range ids {
+		if err := jobs.Retry(ctx, pool, id); err != nil {
+			return err
+		}
+		fmt.Printf("Job %d queued\n", id)
+		if err := trail.Record(ctx, audit.JobRetry, strconv.FormatInt(id, 10), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJobsBackend refuses to inspect the jobs table when an external
+// queue holds the jobs, which has its own tooling.
+func checkJobsBackend(cfg *config.Config) error {
+	switch cfg.Jobs.Backend {
+	case jobs.BackendRiver:
+		return errors.New("jobs.backend is river: inspect and retry jobs with River UI or the river CLI")
+	case jobs.BackendAsynq:
+		return errors.New("jobs.backend is asynq: inspect and retry jobs with asynqmon or the asynq CLI")
+	}
+	return nil
+}
+
+var jobsTable = table.Table[jobs.Job]{
+	Columns: []table.Column[jobs.Job]{
+		{Name: "id", Value: func(j jobs.Job) string { return strconv.FormatInt(j.ID, 10) }},
+		{Name: "kind", Value: func(j jobs.Job) string { return j.Kind }},
+		{Name: "key", Flex: true, Value: func(j jobs.Job) string { return j.Key }},
+		{Name: "state", Value: func(j jobs.Job) string { return j.State }},
+		{Name: "attempts", Value: func(j jobs.Job) string { return fmt.Sprintf("%d/%d", j.Attempts, j.MaxAttempts) }},
+		{Name: "run_at", Time: func(j jobs.Job) time.Time { return j.RunAt }, Layout: time.DateTime},
+		{Name: "updated", Time: func(j jobs.Job) time.Time { return j.UpdatedAt }, Layout: time.DateTime},
+		{Name: "created", Time: func(j jobs.Job) time.Time { return j.CreatedAt }, Layout: time.DateTime},
+		{Name: "finished", Time: func(j jobs.Job) time.Time { return timeOf(j.FinishedAt) }, Layout: time.DateTime},
+		{Name: "error", Flex: true, Value: func(j jobs.Job) string { return j.LastError }},
+	},
+	Defaults: []string{"id", "kind", "key", "state", "attempts", "updated", "error"},
+	Empty:    "No jobs.",
+}
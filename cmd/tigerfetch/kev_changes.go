@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/schema"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runKevChanges prints the KEV catalog diffs recorded by the KEV runner.
+//
+//	tigerfetch kev-changes --since 24h --type added --format table
+func runKevChanges(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("kev-changes", flag.ContinueOnError)
+	since := fs.String("since", "24h", "only show changes detected at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now")
+	until := fs.String("until", "", "only show changes detected before this time, in the same forms as --since")
+	changeType := fs.String("type", "", "filter by change type: added, updated or removed")
+	format := fs.String("format", "table", "output format: table or json")
+	schemaVersion := addSchemaVersionFlag(fs)
+	tf := addTableFlags(fs, kevChangesTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+
+	win, err := window.Parse(*since, *until, "", time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := checkSchemaVersion(*schemaVersion); err != nil {
+		return err
+	}
+
+	switch *changeType {
+	case "", cve.KevChangeAdded, cve.KevChangeUpdated, cve.KevChangeRemoved:
+	default:
+		return fmt.Errorf("invalid --type %q", *changeType)
+	}
+
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := kevChangesTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	changes, err := cve.ListKevChanges(ctx, pool, win.Since, win.Until, *changeType)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		if changes == nil {
+			changes = []cve.KevChange{}
+		}
+		out, err := schema.Encode("kev-changes", *schemaVersion, changes)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	case "table":
+		if err := kevChangesTable.Write(&buf, changes, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var kevChangesTable = table.Table[cve.KevChange]{
+	Columns: []table.Column[cve.KevChange]{
+		{Name: "detected", Time: func(c cve.KevChange) time.Time { return c.DetectedAt }, Layout: "2006-01-02 15:04"},
+		{Name: "change", Value: func(c cve.KevChange) string { return c.ChangeType }},
+		{Name: "cve", Value: func(c cve.KevChange) string { return c.CveID }},
+		{Name: "vendor", Flex: true, Value: func(c cve.KevChange) string { return c.Vuln.VendorProject }},
+		{Name: "product", Flex: true, Value: func(c cve.KevChange) string { return c.Vuln.Product }},
+		{Name: "title", Flex: true, Value: func(c cve.KevChange) string { return c.Vuln.VulnerabilityName }},
+		{Name: "added", Value: func(c cve.KevChange) string { return c.Vuln.DateAdded }},
+		{Name: "due", Value: func(c cve.KevChange) string { return c.Vuln.DueDateRaw }},
+		{Name: "fields", Flex: true, Value: func(c cve.KevChange) string { return strings.Join(c.ChangedFields, ",") }},
+		{Name: "catalog", Value: func(c cve.KevChange) string { return c.CatalogVersion }},
+		{Name: "archived", Value: func(c cve.KevChange) string { return strconv.FormatBool(c.Archived) }},
+	},
+	Defaults: []string{"detected", "change", "cve", "vendor", "product", "due", "fields"},
+	Empty:    "No KEV changes in window.",
+}
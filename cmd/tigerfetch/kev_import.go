@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tiger2go/internal/cve"
+)
+
+// runKevImport backfills kev_changes from archived KEV catalog releases,
+// so trends reach back before tigerfetch started watching the catalog.
+// Each argument is a catalog file, a directory of *.json catalogs or an
+// http(s) URL.
+//
+//	tigerfetch kev-import ./kev-history/
+//	tigerfetch kev-import https://web.archive.org/web/2022id_/https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json
+func runKevImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("kev-import", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: tigerfetch kev-import <file|directory|url>...")
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	fetcher := cve.NewKevRunner(nil, cfg.KEV)
+	var catalogs []*cve.KevCatalog
+	for _, arg := range fs.Args() {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			c, err := fetcher.FetchCatalog(ctx, arg)
+			if err != nil {
+				return fmt.Errorf("fetch %s: %w", arg, err)
+			}
+			catalogs = append(catalogs, c)
+			continue
+		}
+		paths := []string{arg}
+		if info, err := os.Stat(arg); err != nil {
+			return err
+		} else if info.IsDir() {
+			if paths, err = filepath.Glob(filepath.Join(arg, "*.json")); err != nil {
+				return err
+			}
+		}
+		for _, path := range paths {
+			c, err := readKevCatalog(path)
+			if err != nil {
+				return err
+			}
+			catalogs = append(catalogs, c)
+		}
+	}
+
+	stats, err := cve.ImportKevArchive(ctx, pool, catalogs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d catalog releases (%d skipped): %d changes recorded\n", stats.Catalogs, stats.Skipped, stats.Changes)
+	return nil
+}
+
+func readKevCatalog(path string) (*cve.KevCatalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	c, err := cve.ReadKevCatalog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
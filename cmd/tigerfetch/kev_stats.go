@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runKevStats prints KEV listings and removals per month or year, with the
+// time from NVD publication to listing. Import archived releases with
+// kev-import first for history before tigerfetch was deployed.
+//
+//	tigerfetch kev-stats --by year
+//	tigerfetch kev-stats --since 2024-01-01 --format json
+func runKevStats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("kev-stats", flag.ContinueOnError)
+	by := fs.String("by", cve.KevStatsMonth, "period: month or year")
+	since := fs.String("since", "", "only count listings on or after this time: RFC 3339, YYYY-MM-DD or a duration back from now (default all)")
+	until := fs.String("until", "", "only count listings before this time, in the same forms as --since")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, kevStatsTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+
+	win, err := window.Parse(*since, *until, "", time.Now())
+	if err != nil {
+		return err
+	}
+	if *by != cve.KevStatsMonth && *by != cve.KevStatsYear {
+		return fmt.Errorf("invalid --by %q", *by)
+	}
+
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := kevStatsTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	periods, err := cve.KevStats(ctx, pool, *by, win.Since, win.Until)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if periods == nil {
+			periods = []cve.KevPeriod{}
+		}
+		if err := enc.Encode(periods); err != nil {
+			return err
+		}
+	case "table":
+		layout := "2006-01"
+		if *by == cve.KevStatsYear {
+			layout = "2006"
+		}
+		tbl := kevStatsTable
+		tbl.Columns = append([]table.Column[cve.KevPeriod]{
+			{Name: "period", Value: func(p cve.KevPeriod) string { return p.Period.UTC().Format(layout) }},
+		}, tbl.Columns[1:]...)
+		if err := tbl.Write(&buf, periods, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+// kevStatsTable's period column shows the month; runKevStats swaps in the
+// year for --by year.
+var kevStatsTable = table.Table[cve.KevPeriod]{
+	Columns: []table.Column[cve.KevPeriod]{
+		{Name: "period", Value: func(p cve.KevPeriod) string { return p.Period.UTC().Format("2006-01") }},
+		{Name: "added", Value: func(p cve.KevPeriod) string { return strconv.Itoa(p.Added) }},
+		{Name: "removed", Value: func(p cve.KevPeriod) string { return strconv.Itoa(p.Removed) }},
+		{Name: "ransomware", Value: func(p cve.KevPeriod) string { return strconv.Itoa(p.Ransomware) }},
+		{Name: "with_nvd", Value: func(p cve.KevPeriod) string { return strconv.Itoa(p.WithNVD) }},
+		{Name: "median_days", Value: func(p cve.KevPeriod) string { return formatFloat(p.MedianDays, 0) }},
+		{Name: "p90_days", Value: func(p cve.KevPeriod) string { return formatFloat(p.P90Days, 0) }},
+	},
+	Defaults: []string{"period", "added", "removed", "ransomware", "median_days", "p90_days"},
+	Empty:    "No KEV listings in window.",
+}
@@ -5,19 +5,37 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // display.timezone in images without zoneinfo
 
-	"tiger2go/internal/alerting"
+	"tiger2go/internal/api"
+	"tiger2go/internal/audit"
+	"tiger2go/internal/budget"
+	"tiger2go/internal/clickhouse"
 	"tiger2go/internal/config"
 	"tiger2go/internal/cve"
 	"tiger2go/internal/db"
-	"tiger2go/internal/ingestor"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/httpreplay"
+	"tiger2go/internal/inbound"
+	"tiger2go/internal/jobs"
+	"tiger2go/internal/kev"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+	"tiger2go/internal/scheduler"
+	"tiger2go/internal/sourcectl"
+	"tiger2go/internal/upstream"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -34,6 +52,19 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 
+	provenance.SetToolVersion(version, commit)
+
+	// Capture all upstream HTTP interactions to a cassette (for bug reports)
+	if path := os.Getenv("TIGERFETCH_HTTP_RECORD"); path != "" {
+		http.DefaultTransport = httpreplay.NewRecorder(path, http.DefaultTransport)
+		slog.Warn("Recording upstream HTTP interactions", "path", path)
+	}
+
+	// One-shot CLI subcommands (e.g. `tigerfetch kev-changes`)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	slog.Info("Starting TigerFetch...")
 
 	// Record build info and start time
@@ -57,6 +88,40 @@ func main() {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// Optional per-run caps ([limits]): the first one reached ends the run
+	limits, err := budget.FromConfig(cfg.Limits)
+	if err != nil {
+		slog.Error("Invalid limits configuration", "error", err)
+		os.Exit(1)
+	}
+	// Per-host request metrics and rate limit pacing ([http]), under the
+	// budget so it can still tell requests it already counts
+	upstreams := upstream.New()
+	throttle, err := rateLimitThrottle(cfg)
+	if err != nil {
+		slog.Error("Invalid http configuration", "error", err)
+		os.Exit(1)
+	}
+	dns, err := dnsResolver(cfg)
+	if err != nil {
+		slog.Error("Invalid http configuration", "error", err)
+		os.Exit(1)
+	}
+	conns, err := transportOptions(cfg)
+	if err != nil {
+		slog.Error("Invalid http configuration", "error", err)
+		os.Exit(1)
+	}
+	http.DefaultTransport = throttle.Transport(upstreams.Transport(dns.Transport(sharedTransport(http.DefaultTransport, conns))))
+	var runBudget *budget.Budget
+	if limits.Enabled() {
+		ctx, runBudget = budget.New(ctx, limits)
+		defer runBudget.Stop()
+		http.DefaultTransport = runBudget.Transport(http.DefaultTransport)
+		slog.Info("Run limits enabled", "max_requests", limits.MaxRequests,
+			"max_duration", limits.MaxDuration, "max_new_advisories", limits.MaxNewAdvisories)
+	}
+
 	// Run database migrations
 	slog.Info("Running database migrations...")
 	if err := db.Migrate(cfg.DatabaseURL, "migrations"); err != nil {
@@ -65,7 +130,7 @@ func main() {
 	}
 
 	// Create database connection pool
-	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
+	pool, err := db.NewPoolWithOptions(ctx, cfg.DatabaseURL, poolOptions(cfg))
 	if err != nil {
 		slog.Error("Failed to create database pool", "error", err)
 		os.Exit(1)
@@ -75,6 +140,38 @@ func main() {
 	// Register pgxpool metrics collector
 	metrics.RegisterDBCollector(pool)
 
+	// Optional read replica for alert detection queries
+	readPool := pool
+	if cfg.Database.ReadURL != "" {
+		opts := poolOptions(cfg)
+		opts.ReadOnly = true
+		readPool, err = db.NewPoolWithOptions(ctx, cfg.Database.ReadURL, opts)
+		if err != nil {
+			slog.Error("Failed to create read replica pool", "error", err)
+			os.Exit(1)
+		}
+		defer readPool.Close()
+		slog.Info("Read replica connected")
+	}
+
+	// In-memory KEV set, refreshed from the read pool
+	kevCache := kev.NewCache(readPool, kevCacheRefresh(cfg))
+
+	// Optional ClickHouse sink for EPSS and CVSS score history
+	var scoreSink cve.ScoreSink
+	if cfg.ClickHouse.Enabled {
+		ch, err := clickhouse.New(cfg.ClickHouse)
+		if err != nil {
+			slog.Error("Invalid ClickHouse configuration", "error", err)
+			os.Exit(1)
+		}
+		if err := ch.EnsureSchema(ctx); err != nil {
+			slog.Warn("ClickHouse schema setup failed; sink inserts will fail until the tables exist", "error", err)
+		}
+		scoreSink = ch
+		slog.Info("ClickHouse sink enabled", "url", cfg.ClickHouse.URL)
+	}
+
 	slog.Info("Database connected successfully")
 
 	// Start HTTP server for metrics/health
@@ -102,161 +199,162 @@ func main() {
 		}
 	}()
 
-	// WaitGroup to track all worker goroutines for clean shutdown
-	var workers sync.WaitGroup
+	// [audit] trail of notifications and API acknowledgements, nil when off
+	auditTrail := audit.FromConfig(pool, cfg.Audit, "")
 
-	// Run CVE enrichment workers if enabled
-	if cfg.NVD.Enabled {
-		workers.Add(1)
+	// Optional lookup API on its own listener; reads from the read pool,
+	// triage (acknowledgements) goes to the primary
+	var apiServer *http.Server
+	if cfg.API.Enabled {
+		enricher := newEnricher(cfg, readPool, kevCache, cfg.API.UpstreamFallback)
+		apiHandler := api.New(enricher, api.PoolStore{Pool: readPool}, cfg.API)
+		apiHandler.SetTriage(api.PoolTriage{Pool: pool, Audit: auditTrail})
+		apiServer = &http.Server{
+			Addr:         cfg.API.Bind,
+			Handler:      metrics.InstrumentHandler(apiHandler.Handler()),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 2 * time.Minute, // upstream fallback may hit NVD rate limits
+			IdleTimeout:  60 * time.Second,
+		}
 		go func() {
-			defer workers.Done()
-			runner := cve.NewNvdRunner(pool, cfg.NVD)
-			interval, err := cfg.NVD.GetPollDuration()
-			if err != nil || interval <= 0 {
-				slog.Warn("Invalid NVD poll interval, using default 1h", "error", err)
-				interval = 1 * time.Hour
-			}
-			ticker := time.NewTimer(0) // fire immediately on first run
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					if err := runner.Run(ctx); err != nil {
-						slog.Error("NVD runner error", "error", err)
-					}
-					ticker.Reset(interval)
-				}
+			slog.Info("Starting API server", "addr", cfg.API.Bind, "upstream_fallback", cfg.API.UpstreamFallback)
+			if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("API server error", "error", err)
+				os.Exit(1)
 			}
 		}()
 	}
 
-	if cfg.KEV.Enabled {
-		workers.Add(1)
+	// Optional pprof endpoints on their own listener, for diagnosing memory growth
+	var profServer *http.Server
+	if cfg.Profiling.Enabled {
+		profServer = profilingServer(cfg.Profiling.Bind)
 		go func() {
-			defer workers.Done()
-			runner := cve.NewKevRunner(pool, cfg.KEV)
-			interval, err := cfg.KEV.GetPollDuration()
-			if err != nil || interval <= 0 {
-				slog.Warn("Invalid KEV poll interval, using default 1h", "error", err)
-				interval = 1 * time.Hour
-			}
-			ticker := time.NewTimer(0)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					if err := runner.Run(ctx); err != nil {
-						slog.Error("KEV runner error", "error", err)
-					}
-					ticker.Reset(interval)
-				}
+			slog.Info("Starting profiling server", "addr", cfg.Profiling.Bind)
+			if err := profServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Profiling server error", "error", err)
+				os.Exit(1)
 			}
 		}()
 	}
 
-	if cfg.EPSS.Enabled {
-		workers.Add(1)
-		go func() {
-			defer workers.Done()
-			runner := cve.NewEpssRunner(pool, cfg.EPSS)
-			interval, err := cfg.EPSS.GetPollDuration()
-			if err != nil || interval <= 0 {
-				slog.Warn("Invalid EPSS poll interval, using default 24h", "error", err)
-				interval = 24 * time.Hour
-			}
-			ticker := time.NewTimer(0)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					if err := runner.Run(ctx); err != nil {
-						slog.Error("EPSS runner error", "error", err)
-					}
-					ticker.Reset(interval)
-				}
-			}
-		}()
+	// Runners below share the [scheduler] concurrency budget
+	sched, err := scheduler.New(cfg.Scheduler)
+	if err != nil {
+		slog.Error("Invalid scheduler configuration", "error", err)
+		os.Exit(1)
 	}
 
-	// Run RSS/Atom feed ingestor with bounded concurrency
-	if len(cfg.Feeds) > 0 {
-		workers.Add(1)
+	// WaitGroup to track all worker goroutines for clean shutdown
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		kevCache.Run(ctx)
+	}()
+
+	// Ingestion runs as jobs: the loops below enqueue one per poll, the jobs
+	// worker ([jobs] backend) runs them and retries failures with backoff
+	queue, err := jobs.Open(ctx, pool, cfg.Jobs)
+	if err != nil {
+		slog.Error("Invalid jobs configuration", "error", err)
+		os.Exit(1)
+	}
+	handlers := jobs.Handlers{}
+
+	dataQuality, err := quality.FromConfig(pool, cfg.Quality)
+	if err != nil {
+		slog.Error("Invalid quality configuration", "error", err)
+		os.Exit(1)
+	}
+	quarantined, err := quarantine.FromConfig(pool, cfg.Quarantine)
+	if err != nil {
+		slog.Error("Invalid quarantine configuration", "error", err)
+		os.Exit(1)
+	}
+	archive, err := rawarchive.FromConfig(pool, cfg.RawArchive)
+	if err != nil {
+		slog.Error("Invalid raw_archive configuration", "error", err)
+		os.Exit(1)
+	}
+	deps := pipelineDeps{
+		pool:       pool,
+		readPool:   readPool,
+		kevCache:   kevCache,
+		scoreSink:  scoreSink,
+		sched:      sched,
+		budget:     runBudget,
+		upstream:   upstreams,
+		throttle:   throttle,
+		dns:        dns,
+		conns:      conns,
+		quality:    dataQuality,
+		quarantine: quarantined,
+		archive:    archive,
+		audit:      auditTrail,
+	}
+	steps, err := buildPipeline(cfg, deps)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Optional webhook receiver for sources that push advisories, on its
+	// own listener; pushes are stored like feed items
+	var inboundServer *http.Server
+	if cfg.Inbound.Enabled {
+		inboundServer, err = newInboundServer(cfg, deps)
+		if err != nil {
+			slog.Error("Invalid inbound configuration", "error", err)
+			os.Exit(1)
+		}
 		go func() {
-			defer workers.Done()
-			client := ingestor.New(pool)
-			interval, err := cfg.GetIngestDuration()
-			if err != nil || interval <= 0 {
-				slog.Warn("Invalid ingest_interval, using default 1h", "error", err)
-				interval = 1 * time.Hour
-			}
-			const maxConcurrent = 5
-			sem := make(chan struct{}, maxConcurrent)
-			ticker := time.NewTimer(0)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					var wg sync.WaitGroup
-					for _, feedCfg := range cfg.Feeds {
-						wg.Add(1)
-						sem <- struct{}{} // acquire slot
-						go func(fc config.Feed) {
-							defer wg.Done()
-							defer func() { <-sem }() // release slot
-							if err := client.FetchAndSave(ctx, fc); err != nil {
-								slog.Error("Feed ingestion error", "feed", fc.Name, "error", err)
-							}
-						}(feedCfg)
-					}
-					wg.Wait()
-					ticker.Reset(interval)
-				}
+			slog.Info("Starting inbound webhook server", "addr", cfg.Inbound.Bind, "sources", len(cfg.Inbound.Sources))
+			if err := inboundServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Inbound server error", "error", err)
+				os.Exit(1)
 			}
 		}()
 	}
-
-	// Run sleeper CVE alerting if enabled
-	if cfg.Alerting.Enabled {
+	for _, s := range steps {
+		// `tigerfetch source disable` switches a step off between runs
+		s.run = sourcectl.Gate(pool, s.name, s.run)
 		workers.Add(1)
+		if s.kind == "" {
+			// Derived work re-reads the database each run: plain loops
+			go func() {
+				defer workers.Done()
+				runEvery(ctx, s)
+			}()
+			continue
+		}
+		handlers[s.kind] = s.run
 		go func() {
 			defer workers.Done()
-			runner := alerting.NewRunner(pool, cfg.Alerting)
-			interval, err := cfg.Alerting.GetPollDuration()
-			if err != nil || interval <= 0 {
-				slog.Warn("Invalid alerting poll interval, using default 1h", "error", err)
-				interval = 1 * time.Hour
-			}
-			// Delay first run by 30s to let EPSS ingest finish if both start together
-			ticker := time.NewTimer(30 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-ticker.C:
-					if err := runner.Run(ctx); err != nil {
-						slog.Error("Alerting runner error", "error", err)
-					}
-					ticker.Reset(interval)
-				}
-			}
+			enqueueEvery(ctx, queue, s.interval, s.kind, s.stepKeys)
 		}()
 	}
 
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		if err := queue.Work(ctx, handlers); err != nil {
+			slog.Error("Job worker error", "error", err)
+		}
+	}()
+
 	slog.Info("TigerFetch started successfully")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or for the run budget to be spent
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-ctx.Done():
+		slog.Info("Run limit reached", "limit", runBudget.Reason(),
+			"requests", runBudget.Requests(), "new_advisories", runBudget.NewAdvisories())
+	}
 
 	slog.Info("Shutting down...")
 	cancel() // Cancel context to signal goroutines to stop
@@ -271,6 +369,174 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("Server shutdown error", "error", err)
 	}
+	if apiServer != nil {
+		if err := apiServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("API server shutdown error", "error", err)
+		}
+	}
+	if inboundServer != nil {
+		if err := inboundServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Inbound server shutdown error", "error", err)
+		}
+	}
+	if profServer != nil {
+		if err := profServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Profiling server shutdown error", "error", err)
+		}
+	}
 
 	slog.Info("Shutdown complete")
 }
+
+// newInboundServer serves the push URLs of the [inbound] sources on
+// inbound.bind, storing pushes with the feed client.
+func newInboundServer(cfg *config.Config, d pipelineDeps) (*http.Server, error) {
+	client, err := newFeedClient(cfg, d)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := inbound.New(client, cfg.Inbound)
+	if err != nil {
+		return nil, err
+	}
+	recv.SetQuarantine(d.quarantine)
+	recv.SetArchive(d.archive)
+	return &http.Server{
+		Addr:         cfg.Inbound.Bind,
+		Handler:      metrics.InstrumentHandler(recv.Handler()),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: time.Minute, // storing resolves redirector links
+		IdleTimeout:  60 * time.Second,
+	}, nil
+}
+
+// profilingServer serves net/http/pprof on addr. The handlers are mounted
+// on a mux of their own: the package's init also registers them on
+// http.DefaultServeMux, which no listener serves.
+func profilingServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 2 * time.Minute, // CPU profiles and traces run for ?seconds=, 30 by default
+		IdleTimeout:  60 * time.Second,
+	}
+}
+
+// kevCacheRefresh returns kev.cache_refresh, or 0 (the kev default) if unset or invalid.
+func kevCacheRefresh(cfg *config.Config) time.Duration {
+	d, err := cfg.KEV.GetCacheRefresh()
+	if err != nil || d < 0 {
+		slog.Warn("Invalid kev.cache_refresh, using default", "value", cfg.KEV.CacheRefresh, "error", err)
+		return 0
+	}
+	return d
+}
+
+// newEnricher configures an Enricher from the [api] section. The daemon and
+// the enrich command share it so limits behave the same in both.
+func newEnricher(cfg *config.Config, pool *pgxpool.Pool, kevCache *kev.Cache, upstream bool) *enrich.Enricher {
+	e := enrich.New(pool)
+	e.SetLimits(cfg.API.MaxCVEs, cfg.API.MaxUpstream)
+	e.SetCvssPriority(cvssPriority(cfg))
+	if kevCache != nil {
+		e.SetKevCache(kevCache)
+	}
+	if upstream {
+		e.SetUpstream(enrich.NewRunnerUpstream(cfg))
+	}
+	return e
+}
+
+// cvssPriority is the [merge] cvss precedence, shared by ingestion and
+// enrichment so stored and served scores agree.
+func cvssPriority(cfg *config.Config) []string {
+	priority, err := cve.ParseCvssPriority(cfg.Merge.CVSS)
+	if err != nil {
+		slog.Warn("Invalid merge.cvss, using NVD's order", "value", cfg.Merge.CVSS, "error", err)
+		return nil
+	}
+	return priority
+}
+
+// rateLimitThrottle paces upstream requests by their rate limit headers
+// ([http]); nil when rate_limit_headers is off.
+func rateLimitThrottle(cfg *config.Config) (*httpclient.Throttle, error) {
+	if !cfg.HTTP.RateLimitHeaders {
+		return nil, nil
+	}
+	wait, err := cfg.HTTP.GetMaxRateLimitWait()
+	if err != nil || wait <= 0 {
+		return nil, fmt.Errorf("http.max_rate_limit_wait %q must be a positive duration", cfg.HTTP.MaxRateLimitWait)
+	}
+	return httpclient.NewThrottle(wait), nil
+}
+
+// transportOptions sizes the connection pools of the outbound transports
+// ([http]).
+func transportOptions(cfg *config.Config) (httpclient.TransportOptions, error) {
+	idle, err := cfg.HTTP.GetIdleConnTimeout()
+	if err != nil || idle <= 0 {
+		return httpclient.TransportOptions{}, fmt.Errorf("http.idle_conn_timeout %q must be a positive duration", cfg.HTTP.IdleConnTimeout)
+	}
+	return httpclient.TransportOptions{
+		MaxIdleConns:        cfg.HTTP.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTP.MaxIdleConnsPerHost,
+		IdleConnTimeout:     idle,
+	}, nil
+}
+
+// sharedTransport replaces rt, when it is net/http's own transport, with
+// one pooled by opts for every fetcher to share. A recorder
+// (TIGERFETCH_HTTP_RECORD) is kept as it is.
+func sharedTransport(rt http.RoundTripper, opts httpclient.TransportOptions) http.RoundTripper {
+	if _, ok := rt.(*http.Transport); !ok {
+		return rt
+	}
+	return httpclient.NewTransport(opts)
+}
+
+// dnsResolver resolves upstream hosts with a cache, retries and secondary
+// servers ([http]); nil when neither dns_cache_ttl nor dns_servers is set.
+func dnsResolver(cfg *config.Config) (*httpclient.Resolver, error) {
+	h := cfg.HTTP
+	if h.DNSCacheTTL == "" && len(h.DNSServers) == 0 {
+		return nil, nil
+	}
+	ttl, err := h.GetDNSCacheTTL()
+	if err != nil || ttl < 0 {
+		return nil, fmt.Errorf("http.dns_cache_ttl %q must be a duration of 0s or more", h.DNSCacheTTL)
+	}
+	stale, err := h.GetDNSStaleFor()
+	if err != nil || stale < 0 {
+		return nil, fmt.Errorf("http.dns_stale_for %q must be a duration of 0s or more", h.DNSStaleFor)
+	}
+	opts := httpclient.ResolverOptions{CacheTTL: ttl, Retries: h.DNSRetries, Servers: h.DNSServers, StaleFor: stale}
+	if ttl == 0 {
+		opts.CacheTTL = -1
+	}
+	if stale == 0 {
+		opts.StaleFor = -1
+	}
+	return httpclient.NewResolver(opts)
+}
+
+// feedURLPolicy is the [feed_security] guard, applied to every fetch of a
+// URL taken from feeds or NVD references. When it blocks private networks,
+// dns resolves the hosts it dials and pool sizes its connection pool.
+func feedURLPolicy(cfg *config.Config, dns *httpclient.Resolver, pool httpclient.TransportOptions) httpclient.URLPolicy {
+	return httpclient.URLPolicy{
+		Resolver:       dns,
+		Pool:           pool,
+		AllowedSchemes: cfg.FeedSecurity.AllowedSchemes,
+		BlockPrivate:   cfg.FeedSecurity.BlockPrivateNetworks,
+		AllowedHosts:   cfg.FeedSecurity.AllowedHosts,
+		MaxRedirects:   cfg.FeedSecurity.MaxRedirects,
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"tiger2go/internal/api"
+	"tiger2go/internal/integrity"
+	"tiger2go/internal/schema"
+)
+
+// writeOutput writes command output to stdout, or to path as an integrity
+// artifact (manifest entry plus optional signature).
+func writeOutput(path, signKey string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	var signer *integrity.Signer
+	if signKey != "" {
+		s, err := integrity.LoadSigner(signKey)
+		if err != nil {
+			return fmt.Errorf("load signing key: %w", err)
+		}
+		signer = s
+	}
+	if err := integrity.WriteArtifact(path, data, signer); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s (%d bytes)\n", path, len(data))
+	return nil
+}
+
+// runKeygen creates an Ed25519 signing key for export artifacts and prints
+// the minisign-format public key consumers verify with.
+//
+//	tigerfetch keygen --out tigerfetch.key
+func runKeygen(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	out := fs.String("out", "tigerfetch.key", "secret key file to create (never overwritten)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	signer, err := integrity.GenerateKey(*out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Secret key written to %s\n", *out)
+	fmt.Printf("Public key (minisign -P):\n%s\n", signer.PublicKey())
+	return nil
+}
+
+// runOpenAPI prints the OpenAPI document for the lookup API. It needs no
+// configuration or database, so client generators can run it in CI.
+//
+//	tigerfetch openapi > docs/openapi.json
+func runOpenAPI(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	doc, err := api.OpenAPI()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(doc, '\n'))
+	return err
+}
+
+// addSchemaVersionFlag adds --schema-version, which picks the major version
+// of the JSON output shape so parsers can stay on an earlier one.
+func addSchemaVersionFlag(fs *flag.FlagSet) *int {
+	return fs.Int("schema-version", schema.Major, "shape of --format json output: major schema version (see `tigerfetch schema changelog`)")
+}
+
+func checkSchemaVersion(v int) error {
+	if err := schema.CheckVersion(v); err != nil {
+		return fmt.Errorf("--schema-version: %w", err)
+	}
+	return nil
+}
+
+// runSchema prints the JSON Schema of an output document, embedded in the
+// binary so integrators can validate against the exact version they run,
+// or the changelog between schema versions.
+//
+//	tigerfetch schema print advisory > advisory.schema.json
+//	tigerfetch schema print --version 1 query
+//	tigerfetch schema changelog
+//	tigerfetch schema upgrade query old-export.json > export.json
+func runSchema(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	version := fs.Int("version", schema.Major, "major schema version to print, or to upgrade to")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch schema print [--version N] NAME\n       tigerfetch schema changelog\n       tigerfetch schema upgrade [--version N] NAME [FILE]\n\n")
+		fmt.Fprintf(fs.Output(), "Schemas: %s\nVersions: %v\n\n", strings.Join(schema.Names(), ", "), schema.Versions())
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("expected: schema print NAME, schema changelog or schema upgrade NAME [FILE]")
+	}
+	sub := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var doc []byte
+	switch {
+	case sub == "print" && fs.NArg() == 1:
+		b, err := schema.GetVersion(fs.Arg(0), *version)
+		if err != nil {
+			return err
+		}
+		doc = b
+	case sub == "changelog" && fs.NArg() == 0:
+		log, err := schema.Changelog()
+		if err != nil {
+			return err
+		}
+		doc = []byte(log)
+	case sub == "upgrade" && (fs.NArg() == 1 || fs.NArg() == 2):
+		b, err := upgradeDocument(fs.Arg(0), fs.Arg(1), *version)
+		if err != nil {
+			return err
+		}
+		doc = b
+	default:
+		fs.Usage()
+		return errors.New("expected: schema print NAME, schema changelog or schema upgrade NAME [FILE]")
+	}
+	_, err := os.Stdout.Write(doc)
+	return err
+}
+
+// upgradeDocument reads an export written by any version of tigerfetch
+// from path, or stdin when "", and writes it in the shape of major.
+func upgradeDocument(name, path string, major int) ([]byte, error) {
+	if err := schema.CheckVersion(major); err != nil {
+		return nil, fmt.Errorf("--version: %w", err)
+	}
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := schema.New(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Decode(name, data, v); err != nil {
+		return nil, err
+	}
+	out := reflect.ValueOf(v).Elem()
+	if out.Kind() == reflect.Slice && out.IsNil() {
+		out.Set(reflect.MakeSlice(out.Type(), 0, 0))
+	}
+	return schema.Encode(name, major, out.Interface())
+}
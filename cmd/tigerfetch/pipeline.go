@@ -0,0 +1,507 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/alerting"
+	"tiger2go/internal/audit"
+	"tiger2go/internal/budget"
+	"tiger2go/internal/config"
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/coverage"
+	"tiger2go/internal/crawl"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/jobs"
+	"tiger2go/internal/kev"
+	"tiger2go/internal/maillist"
+	"tiger2go/internal/mirror"
+	"tiger2go/internal/owners"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+	"tiger2go/internal/reflabel"
+	"tiger2go/internal/scheduler"
+	"tiger2go/internal/social"
+	"tiger2go/internal/upstream"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// step is one stage of the fetch and enrich pipeline. The daemon runs
+// each on its own schedule; `tigerfetch run` and the serverless handler
+// run every due step once.
+type step struct {
+	name     string // scheduler source, also the run state key
+	kind     string // job kind of ingestion steps; "" for derived steps, run in-process
+	interval time.Duration
+	delay    time.Duration   // derived steps: wait after daemon start, so ingestion goes first
+	keys     func() []string // jobs to enqueue per poll; nil is a single run
+	run      func(ctx context.Context, key string) error
+	errMsg   string // logged when a derived step fails
+}
+
+// stepKeys returns the keys of one poll of s.
+func (s step) stepKeys() []string {
+	if s.keys == nil {
+		return singleKey()
+	}
+	return s.keys()
+}
+
+// pipelineDeps are the shared resources the steps are built on.
+type pipelineDeps struct {
+	pool       *pgxpool.Pool
+	readPool   *pgxpool.Pool
+	kevCache   *kev.Cache
+	scoreSink  cve.ScoreSink
+	sched      *scheduler.Scheduler
+	budget     *budget.Budget       // nil: unlimited
+	upstream   *upstream.Stats      // nil: requests are not measured per host
+	throttle   *httpclient.Throttle // nil: rate limit headers are ignored
+	dns        *httpclient.Resolver // nil: the dialer resolves hosts
+	conns      httpclient.TransportOptions
+	quality    *quality.Recorder   // nil: checks without storing violations
+	quarantine *quarantine.Store   // nil: unparsable payloads are only logged
+	archive    *rawarchive.Archive // nil: payloads are not archived
+	audit      *audit.Log          // nil: notifications are not audited
+}
+
+// newFeedClient returns the client that stores feed items, configured
+// from the feed settings of cfg. Webhook pushes are stored through it too.
+func newFeedClient(cfg *config.Config, d pipelineDeps) (*ingestor.Client, error) {
+	client := ingestor.New(d.pool)
+	client.SetMaxResponseMB(cfg.FeedMaxResponseMB)
+	client.SetMaxItems(cfg.FeedMaxItems)
+	if maxAge, err := cfg.GetFeedMaxItemAge(); err != nil {
+		slog.Warn("Invalid feed_max_item_age, keeping all items", "error", err)
+	} else {
+		client.SetMaxItemAge(maxAge)
+	}
+	ns, err := ingestor.ParseNamespace(cfg.AdvisoryIDNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("advisory_id_namespace: %w", err)
+	}
+	client.SetIDNamespace(ns)
+	client.SetWithdrawAfter(cfg.FeedWithdrawAfter)
+	client.SetLinkRedirectors(cfg.FeedLinkRedirectors)
+	client.SetTrackingParams(cfg.FeedTrackingParams)
+	client.SetURLPolicy(feedURLPolicy(cfg, d.dns, d.conns))
+	client.SetBudget(d.budget)
+	client.SetUpstream(d.upstream)
+	client.SetThrottle(d.throttle)
+	client.SetQuality(d.quality)
+	client.SetQuarantine(d.quarantine)
+	client.SetArchive(d.archive)
+	return client, nil
+}
+
+// buildPipeline returns the enabled steps in the order a single run takes
+// them: ingestion first, then the steps derived from its data. Invalid
+// intervals fall back to their defaults with a warning; invalid sections
+// are errors.
+func buildPipeline(cfg *config.Config, d pipelineDeps) ([]step, error) {
+	var steps []step
+	do := func(source string, fn func(context.Context) error) func(context.Context, string) error {
+		return func(ctx context.Context, _ string) error { return d.sched.Do(ctx, source, fn) }
+	}
+
+	if cfg.NVD.Enabled {
+		runner := cve.NewNvdRunner(d.pool, cfg.NVD)
+		runner.SetCvssPriority(cvssPriority(cfg))
+		runner.SetQuality(d.quality)
+		runner.SetQuarantine(d.quarantine)
+		runner.SetArchive(d.archive)
+		if d.scoreSink != nil {
+			runner.SetSink(d.scoreSink)
+		}
+		interval, err := cfg.NVD.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid NVD poll interval, using default 1h", "error", err)
+			interval = 1 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.NVD, kind: jobs.KindNVD, interval: interval, run: do(scheduler.NVD, runner.Run)})
+	}
+
+	// Product monitor: CVEs matching [[nvd.products]], independent of nvd.enabled
+	if len(cfg.NVD.Products) > 0 {
+		runner := cve.NewNvdRunner(d.pool, cfg.NVD)
+		runner.SetCvssPriority(cvssPriority(cfg))
+		runner.SetQuality(d.quality)
+		runner.SetQuarantine(d.quarantine)
+		runner.SetArchive(d.archive)
+		if d.scoreSink != nil {
+			runner.SetSink(d.scoreSink)
+		}
+		lookback, err := cfg.NVD.GetProductsLookback()
+		if err != nil || lookback <= 0 {
+			slog.Warn("Invalid NVD products lookback, using default 720h", "error", err)
+			lookback = 720 * time.Hour
+		}
+		monitor := cve.NewProductMonitor(d.pool, runner, cfg.NVD.Products, lookback)
+		interval, err := cfg.NVD.GetProductsPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid NVD products poll interval, using default 6h", "error", err)
+			interval = 6 * time.Hour
+		}
+		slog.Info("NVD product monitor enabled", "products", len(cfg.NVD.Products))
+		steps = append(steps, step{name: scheduler.NVDProducts, kind: jobs.KindNVDProducts, interval: interval, run: do(scheduler.NVDProducts, monitor.Run)})
+	}
+
+	if cfg.KEV.Enabled {
+		runner := cve.NewKevRunner(d.pool, cfg.KEV)
+		runner.SetQuality(d.quality)
+		runner.SetQuarantine(d.quarantine)
+		runner.SetArchive(d.archive)
+		interval, err := cfg.KEV.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid KEV poll interval, using default 1h", "error", err)
+			interval = 1 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.KEV, kind: jobs.KindKEV, interval: interval, run: do(scheduler.KEV, runner.Run)})
+	}
+
+	if cfg.EPSS.Enabled {
+		runner := cve.NewEpssRunner(d.pool, cfg.EPSS)
+		runner.SetQuality(d.quality)
+		runner.SetQuarantine(d.quarantine)
+		runner.SetArchive(d.archive)
+		if d.scoreSink != nil {
+			runner.SetSink(d.scoreSink)
+		}
+		interval, err := cfg.EPSS.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid EPSS poll interval, using default 24h", "error", err)
+			interval = 24 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.EPSS, kind: jobs.KindEPSS, interval: interval, run: do(scheduler.EPSS, runner.Run),
+			keys: func() []string { return []string{time.Now().UTC().Format(time.DateOnly)} }})
+	}
+
+	// Pull from another tigerfetch instance's /changes feed (federation)
+	if cfg.Remote.Enabled {
+		hc, err := mirror.HTTPClient(cfg.Remote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote configuration: %w", err)
+		}
+		hc.Transport = d.budget.Transport(d.throttle.Transport(d.upstream.Transport(hc.Transport)))
+		client, err := mirror.NewClient(cfg.Remote.URL, cfg.Remote.APIKey, hc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote configuration: %w", err)
+		}
+		syncer := mirror.NewSyncer(d.pool, client, remotePageSize(cfg.Remote))
+		interval, err := cfg.Remote.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid remote poll interval, using default 15m", "error", err)
+			interval = 15 * time.Minute
+		}
+		slog.Info("Remote sync enabled", "url", client.BaseURL(), "pinned", len(cfg.Remote.PinSHA256) > 0)
+		steps = append(steps, step{name: scheduler.Remote, kind: jobs.KindRemote, interval: interval, run: do(scheduler.Remote, syncer.Poll)})
+	}
+
+	// Reference label fetches and feed backfills share one politeness layer
+	crawlOpts, err := crawl.FromConfig(cfg, reflabel.UserAgent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid crawl configuration: %w", err)
+	}
+	crawler := crawl.New(crawlOpts)
+
+	// RSS/Atom feeds, one job per feed with bounded concurrency
+	if len(cfg.Feeds) > 0 {
+		client, err := newFeedClient(cfg, d)
+		if err != nil {
+			return nil, err
+		}
+		client.SetCrawler(crawler)
+		feeds := make(map[string]config.Feed, len(cfg.Feeds))
+		names := make([]string, 0, len(cfg.Feeds))
+		for _, fc := range cfg.Feeds {
+			feeds[fc.Name] = fc
+			names = append(names, fc.Name)
+		}
+		const maxConcurrent = 5
+		sem := make(chan struct{}, maxConcurrent)
+		interval, err := cfg.GetIngestDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid ingest_interval, using default 1h", "error", err)
+			interval = 1 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.Feeds, kind: jobs.KindFeed, interval: interval,
+			keys: func() []string { return names },
+			run: func(ctx context.Context, name string) error {
+				fc, ok := feeds[name]
+				if !ok {
+					return jobs.Permanent(fmt.Errorf("feed %q is no longer configured", name))
+				}
+				select {
+				case sem <- struct{}{}: // acquire slot
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				defer func() { <-sem }() // release slot
+				return d.sched.Do(ctx, scheduler.Feeds, func(ctx context.Context) error {
+					return client.FetchAndSave(ctx, fc)
+				})
+			}})
+	}
+
+	// Mailing lists read over IMAP, one job per list
+	if cfg.Mail.Enabled && len(cfg.Mail.Lists) > 0 {
+		client, err := newFeedClient(cfg, d)
+		if err != nil {
+			return nil, err
+		}
+		runner, err := maillist.NewRunner(d.pool, client, cfg.Mail)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mail configuration: %w", err)
+		}
+		names := runner.Names()
+		interval, err := cfg.Mail.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid mail poll interval, using default 15m", "error", err)
+			interval = 15 * time.Minute
+		}
+		steps = append(steps, step{name: scheduler.Mail, kind: jobs.KindMail, interval: interval,
+			keys: func() []string { return names },
+			run: func(ctx context.Context, name string) error {
+				if !slices.Contains(names, name) {
+					return jobs.Permanent(fmt.Errorf("mailing list %q is no longer configured", name))
+				}
+				return d.sched.Do(ctx, scheduler.Mail, func(ctx context.Context) error {
+					return runner.Run(ctx, name)
+				})
+			}})
+	}
+
+	// Mastodon, Bluesky, Reddit and Hacker News, one job per source
+	if cfg.Social.Enabled && len(cfg.Social.Sources) > 0 {
+		client, err := newFeedClient(cfg, d)
+		if err != nil {
+			return nil, err
+		}
+		hc := &http.Client{Timeout: time.Minute,
+			Transport: d.budget.Transport(d.throttle.Transport(d.upstream.Transport(nil)))}
+		runner, err := social.NewRunner(client, cfg.Social, hc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid social configuration: %w", err)
+		}
+		names := runner.Names()
+		interval, err := cfg.Social.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid social poll interval, using default 15m", "error", err)
+			interval = 15 * time.Minute
+		}
+		steps = append(steps, step{name: scheduler.Social, kind: jobs.KindSocial, interval: interval,
+			keys: func() []string { return names },
+			run: func(ctx context.Context, name string) error {
+				if !slices.Contains(names, name) {
+					return jobs.Permanent(fmt.Errorf("social source %q is no longer configured", name))
+				}
+				return d.sched.Do(ctx, scheduler.Social, func(ctx context.Context) error {
+					return runner.Run(ctx, name)
+				})
+			}})
+	}
+
+	// Resolve vendor advisory titles for reports
+	if cfg.ReferenceLabels.Enabled {
+		rl := cfg.ReferenceLabels
+		retryAfter, err := rl.GetRetryAfter()
+		if err != nil {
+			slog.Warn("Invalid reference_labels.retry_after, using default 168h", "error", err)
+			retryAfter = 0
+		}
+		resolver := reflabel.NewResolver(d.pool, feedURLPolicy(cfg, d.dns, d.conns), crawler, rl.BatchSize, retryAfter)
+		interval, err := rl.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid reference_labels poll interval, using default 1h", "error", err)
+			interval = time.Hour
+		}
+		// Start after the NVD runner has had a chance to store references
+		steps = append(steps, step{name: scheduler.ReferenceLabels, interval: interval, delay: time.Minute,
+			run: do(scheduler.ReferenceLabels, resolver.Run), errMsg: "Reference label resolver error"})
+	}
+
+	// Cross-reference CVEs with public detection rules
+	if cfg.Detections.Enabled {
+		runner, err := detections.NewRunner(d.pool, cfg.Detections)
+		if err != nil {
+			return nil, fmt.Errorf("invalid detections configuration: %w", err)
+		}
+		interval, err := cfg.Detections.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid detections poll interval, using default 24h", "error", err)
+			interval = 24 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.Detections, interval: interval, delay: time.Minute,
+			run: do(scheduler.Detections, runner.Run), errMsg: "Detection rules update error"})
+	}
+
+	// Flag CVEs in product releases past end of life
+	if cfg.EOL.Enabled {
+		runner := eol.NewRunner(d.pool, cfg.EOL)
+		interval, err := cfg.EOL.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid eol poll interval, using default 24h", "error", err)
+			interval = 24 * time.Hour
+		}
+		steps = append(steps, step{name: scheduler.EOL, interval: interval, delay: time.Minute,
+			run: do(scheduler.EOL, runner.Run), errMsg: "End-of-life update error"})
+	}
+
+	// Track CVEs referenced by advisories before EPSS or NVD cover them
+	if cfg.Coverage.Enabled {
+		runner := coverage.NewRunner(d.pool, cfg.Coverage)
+		interval, err := cfg.Coverage.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid coverage poll interval, using default 1h", "error", err)
+			interval = time.Hour
+		}
+		steps = append(steps, step{name: scheduler.Coverage, interval: interval, delay: time.Minute,
+			run: do(scheduler.Coverage, runner.Run), errMsg: "Coverage gap update error"})
+	}
+
+	// Merge each CVE's source records into cve_consolidated for GET /cves
+	{
+		runner := consolidate.NewRunner(d.pool, cvssPriority(cfg))
+		interval, err := cfg.Merge.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid merge poll interval, using default 5m", "error", err)
+			interval = 5 * time.Minute
+		}
+		steps = append(steps, step{name: scheduler.Merge, interval: interval, delay: time.Minute,
+			run: do(scheduler.Merge, runner.Run), errMsg: "Consolidation error"})
+	}
+
+	// Sleeper CVE alerting
+	if cfg.Alerting.Enabled {
+		dict, err := actors.New(cfg.ActorTags)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor_tags configuration: %w", err)
+		}
+		for i, wh := range cfg.Alerting.Webhooks {
+			lang := cmp.Or(wh.Language, cfg.Display.Language)
+			if _, err := i18n.New(lang); err != nil {
+				return nil, fmt.Errorf("invalid language of webhook %q: %w", wh.Name, err)
+			}
+			if err := alerting.DeliveryPolicy(cfg.Alerting, wh); err != nil {
+				return nil, fmt.Errorf("invalid delivery settings of webhook %q: %w", wh.Name, err)
+			}
+			if err := alerting.CheckTemplate(wh); err != nil {
+				return nil, fmt.Errorf("invalid template of webhook %q: %w", wh.Name, err)
+			}
+			cfg.Alerting.Webhooks[i].Language = lang
+		}
+		if cfg.Alerting.Escalation.Enabled {
+			if err := alerting.EscalationPolicy(cfg.Alerting.Escalation); err != nil {
+				return nil, fmt.Errorf("invalid alerting.escalation configuration: %w", err)
+			}
+			for i, wh := range cfg.Alerting.Escalation.Webhooks {
+				lang := cmp.Or(wh.Language, cfg.Display.Language)
+				if _, err := i18n.New(lang); err != nil {
+					return nil, fmt.Errorf("invalid language of escalation webhook %q: %w", wh.Name, err)
+				}
+				if err := alerting.CheckTemplate(wh); err != nil {
+					return nil, fmt.Errorf("invalid template of escalation webhook %q: %w", wh.Name, err)
+				}
+				cfg.Alerting.Escalation.Webhooks[i].Language = lang
+			}
+		}
+		ownerMap, err := buildOwners(cfg)
+		if err != nil {
+			return nil, err
+		}
+		runner := alerting.NewRunner(d.pool, cfg.Alerting)
+		runner.SetReadPool(d.readPool)
+		runner.SetKevCache(d.kevCache)
+		runner.SetActors(dict)
+		runner.SetOwners(ownerMap)
+		runner.SetAudit(d.audit)
+		interval, err := cfg.Alerting.GetPollDuration()
+		if err != nil || interval <= 0 {
+			slog.Warn("Invalid alerting poll interval, using default 1h", "error", err)
+			interval = 1 * time.Hour
+		}
+		// Delay first run by 30s to let EPSS ingest finish if both start together
+		steps = append(steps, step{name: scheduler.Alerting, interval: interval, delay: 30 * time.Second,
+			run: do(scheduler.Alerting, runner.Run), errMsg: "Alerting runner error"})
+	}
+
+	return steps, nil
+}
+
+// runEvery runs a derived step after its delay and every interval after,
+// until ctx ends.
+func runEvery(ctx context.Context, s step) {
+	ticker := time.NewTimer(s.delay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.run(ctx, ""); err != nil {
+				slog.Error(s.errMsg, "error", err)
+			}
+			ticker.Reset(s.interval)
+		}
+	}
+}
+
+// runKeys runs s once for each of keys, concurrently; the step bounds its
+// own concurrency. It returns the error of each failed key.
+func runKeys(ctx context.Context, s step, keys []string) map[string]error {
+	var (
+		mu   sync.Mutex
+		errs = map[string]error{}
+		wg   sync.WaitGroup
+	)
+	for _, key := range keys {
+		wg.Go(func() {
+			if err := s.run(ctx, key); err != nil {
+				mu.Lock()
+				errs[key] = err
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errs
+}
+
+// buildOwners validates [[owners]]. A webhook an owner routes to must be
+// configured; an unknown monitor is only reported, like an unknown
+// critical source.
+func buildOwners(cfg *config.Config) (*owners.Map, error) {
+	m, err := owners.New(cfg.Owners)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owners configuration: %w", err)
+	}
+	webhooks := slices.Concat(cfg.Alerting.Webhooks, cfg.Alerting.Escalation.Webhooks)
+	for _, o := range cfg.Owners {
+		for _, name := range o.Webhooks {
+			if !slices.ContainsFunc(webhooks, func(wh config.WebhookConfig) bool { return wh.Name == name }) {
+				return nil, fmt.Errorf("owner %q routes to unknown webhook %q", o.Team, name)
+			}
+		}
+		for _, name := range o.Monitors {
+			if !slices.ContainsFunc(cfg.NVD.Products, func(p config.NvdProduct) bool { return strings.EqualFold(p.Name, name) }) {
+				slog.Warn("Owner names no [[nvd.products]] monitor", "team", o.Team, "monitor", name)
+			}
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/filter"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/ioc"
+	"tiger2go/internal/query"
+	"tiger2go/internal/schema"
+	"tiger2go/internal/table"
+	"tiger2go/internal/window"
+)
+
+// runQuery selects stored advisories with a filter expression evaluated
+// over each advisory and the CVEs it mentions.
+//
+//	tigerfetch query --filter 'kev == true && epss > 0.5 && source in ["CISA","MSRC"]' --format table
+func runQuery(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	expr := fs.String("filter", "true", "filter expression (see `tigerfetch query --fields`)")
+	fields := fs.Bool("fields", false, "list the fields a filter can use and exit")
+	since := fs.String("since", "720h", "only advisories at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now (0 for no limit)")
+	until := fs.String("until", "", "only advisories before this time, in the same forms as --since")
+	by := fs.String("by", window.Published, "what --since and --until compare against: published or first_seen")
+	limit := fs.Int("limit", 0, "stop after this many matches (0 for no limit)")
+	upstream := fs.Bool("upstream", false, "fetch CVEs missing from the local store from NVD and EPSS")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	schemaVersion := addSchemaVersionFlag(fs)
+	tf := addTableFlags(fs, queryTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *fields {
+		return writeQueryFields(*output)
+	}
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	switch *format {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	if err := checkSchemaVersion(*schemaVersion); err != nil {
+		return err
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := queryTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+	win, err := window.Parse(*since, *until, *by, time.Now())
+	if err != nil {
+		return err
+	}
+	prog, err := filter.Compile(*expr, query.Fields)
+	if err != nil {
+		return fmt.Errorf("--filter: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	dict, err := actors.New(cfg.ActorTags)
+	if err != nil {
+		return err
+	}
+	opts := query.Options{Window: win, Limit: *limit, Actors: dict, BatchSize: cfg.AdvisoryBatchSize}
+	enricher := newEnricher(cfg, pool, nil, *upstream)
+	advs, err := query.Run(ctx, pool, enricher, cfg.Feeds, prog, opts)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		if advs == nil {
+			advs = []query.Advisory{}
+		}
+		out, err := schema.Encode("query", *schemaVersion, advs)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	case "csv":
+		if err := writeQueryCSV(&buf, advs); err != nil {
+			return err
+		}
+	default:
+		if err := queryTable.Write(&buf, advs, topts); err != nil {
+			return err
+		}
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+func writeQueryFields(output string) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FIELD\tTYPE")
+	for _, name := range slices.Sorted(maps.Keys(query.Fields)) {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", name, query.Fields[name])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return writeOutput(output, "", buf.Bytes())
+}
+
+var queryCSVHeader = []string{"published", "source", "title", "link", "cves", "kev", "epss", "percentile", "cvss", "severity", "exploit_ref", "patches", "actors", "ransomware", "unscored"}
+
+func writeQueryCSV(w io.Writer, advs []query.Advisory) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(queryCSVHeader); err != nil {
+		return err
+	}
+	for _, a := range advs {
+		published := ""
+		if a.Published != nil {
+			published = a.Published.UTC().Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{
+			published, a.Source, a.Title, a.Link, strings.Join(a.CVEs, " "),
+			strconv.FormatBool(a.KEV),
+			strconv.FormatFloat(a.EPSS, 'f', -1, 64),
+			strconv.FormatFloat(a.Percentile, 'f', -1, 64),
+			strconv.FormatFloat(a.CVSS, 'f', -1, 64),
+			a.Severity,
+			strconv.FormatBool(a.ExploitRef),
+			strings.Join(a.Patches, " "),
+			strings.Join(a.Actors, " "),
+			strconv.FormatBool(a.Ransomware),
+			strings.Join(a.Unscored, " "),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var queryTable = table.Table[query.Advisory]{
+	Columns: []table.Column[query.Advisory]{
+		{Name: "published", Time: func(a query.Advisory) time.Time { return timeOf(a.Published) }},
+		{Name: "first_seen", Time: func(a query.Advisory) time.Time { return a.FirstSeenAt }},
+		{Name: "cve", Value: func(a query.Advisory) string {
+			switch len(a.CVEs) {
+			case 0:
+				return ""
+			case 1:
+				return a.CVEs[0]
+			}
+			return fmt.Sprintf("%s +%d", a.CVEs[0], len(a.CVEs)-1)
+		}},
+		{Name: "cves", Flex: true, Value: func(a query.Advisory) string { return strings.Join(a.CVEs, " ") }},
+		{Name: "cvss", Color: true, Value: func(a query.Advisory) string { return queryScore(a, a.CVSS, 1) }},
+		{Name: "severity", Color: true, Value: func(a query.Advisory) string { return a.Severity }},
+		{Name: "epss", Value: func(a query.Advisory) string { return queryScore(a, a.EPSS, 4) }},
+		{Name: "percentile", Value: func(a query.Advisory) string { return queryScore(a, a.Percentile, 3) }},
+		{Name: "kev", Value: func(a query.Advisory) string { return strconv.FormatBool(a.KEV) }},
+		{Name: "exploit", Value: func(a query.Advisory) string { return strconv.FormatBool(a.ExploitRef) }},
+		{Name: "title", Flex: true, Value: func(a query.Advisory) string { return a.Title }},
+		{Name: "source", Value: func(a query.Advisory) string { return a.Source }},
+		{Name: "feed_type", Value: func(a query.Advisory) string { return a.FeedType }},
+		{Name: "author", Flex: true, Value: func(a query.Advisory) string { return a.Author }},
+		{Name: "link", Flex: true, Value: func(a query.Advisory) string { return a.Link }},
+		{Name: "id", Value: func(a query.Advisory) string { return a.ID }},
+		{Name: "patches", Flex: true, Value: func(a query.Advisory) string { return strings.Join(a.Patches, " ") }},
+		{Name: "patch_available", Value: func(a query.Advisory) string { return strconv.FormatBool(a.PatchAvailable) }},
+		{Name: "fixed_in", Flex: true, Value: func(a query.Advisory) string { return strings.Join(fixes.Labels(a.FixedIn), ", ") }},
+		{Name: "eol", Flex: true, Value: func(a query.Advisory) string { return strings.Join(eol.Labels(a.EOL), ", ") }},
+		{Name: "actors", Flex: true, Value: func(a query.Advisory) string { return strings.Join(a.Actors, ", ") }},
+		{Name: "ransomware", Value: func(a query.Advisory) string { return strconv.FormatBool(a.Ransomware) }},
+		{Name: "unscored", Flex: true, Value: func(a query.Advisory) string { return strings.Join(a.Unscored, " ") }},
+		{Name: "detections", Value: func(a query.Advisory) string { return strings.Join(detections.SourcesOf(a.Detections), ",") }},
+		{Name: "iocs", Flex: true, Value: func(a query.Advisory) string { return strings.Join(ioc.Values(a.IOCs), " ") }},
+	},
+	Defaults: []string{"cve", "cvss", "epss", "kev", "title", "source"},
+	Severity: func(a query.Advisory) string { return a.Severity },
+	Empty:    "No matching advisories.",
+}
+
+// queryScore formats a CVE-derived score, blank for advisories that name
+// no CVE and "unscored" when none of its CVEs has one yet.
+func queryScore(a query.Advisory, v float64, prec int) string {
+	if len(a.CVEs) == 0 {
+		return ""
+	}
+	if v == 0 && len(a.Unscored) > 0 {
+		return "unscored"
+	}
+	return formatFloat(&v, prec)
+}
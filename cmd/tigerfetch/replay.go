@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/inbound"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/table"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var quarantineSources = []string{quarantine.NVD, quarantine.KEV, quarantine.EPSS, quarantine.Feeds, quarantine.Inbound}
+
+// runReplay lists quarantined upstream payloads, re-processes them with
+// the current parsers, or prints one as upstream sent it.
+//
+//	tigerfetch replay --source feeds
+//	tigerfetch replay 42 57
+//	tigerfetch replay --pending
+//	tigerfetch replay show 42 > page.json
+func runReplay(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "show" {
+		return runReplayShow(ctx, args[1:])
+	}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	source := fs.String("source", "", "only payloads from this source: nvd, kev, epss, feeds or inbound")
+	pending := fs.Bool("pending", false, "replay every payload not yet replayed successfully")
+	all := fs.Bool("all", false, "list replayed payloads too")
+	limit := fs.Int("limit", 50, "list at most this many payloads, most recently seen first (0: all)")
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, quarantineTable)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch replay [flags] [ID...]\n       tigerfetch replay show ID\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *source != "" && !slices.Contains(quarantineSources, *source) {
+		return fmt.Errorf("invalid --source %q", *source)
+	}
+	ids := make([]int64, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid payload ID %q", arg)
+		}
+		ids[i] = id
+	}
+	if *pending && len(ids) > 0 {
+		return fmt.Errorf("--pending replays every pending payload: drop the IDs")
+	}
+	if *pending || len(ids) > 0 {
+		return replayPayloads(ctx, ids, *source)
+	}
+
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := quarantineTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	list, err := quarantine.List(ctx, pool, quarantine.ListOptions{Source: *source, Pending: !*all, Limit: *limit})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if list == nil {
+			list = []quarantine.Entry{}
+		}
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	case "table":
+		if err := quarantineTable.Write(&buf, list, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// replayPayloads re-processes payloads ids, or every pending one of source
+// when ids is empty, and records each outcome. A payload that still fails
+// keeps its new error and stays pending.
+func replayPayloads(ctx context.Context, ids []int64, source string) error {
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	if len(ids) == 0 {
+		list, err := quarantine.List(ctx, pool, quarantine.ListOptions{Source: source, Pending: true})
+		if err != nil {
+			return err
+		}
+		// Oldest first, so a newer KEV catalog is not refused for an older one
+		for _, e := range slices.Backward(list) {
+			ids = append(ids, e.ID)
+		}
+		if len(ids) == 0 {
+			fmt.Println("No pending payloads.")
+			return nil
+		}
+	}
+
+	rec, err := quality.FromConfig(pool, cfg.Quality)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, id := range ids {
+		e, err := quarantine.Get(ctx, pool, id)
+		if err != nil {
+			return err
+		}
+		n, err := replayPayload(ctx, cfg, pool, rec, upstreamPayload{
+			source: e.Source, name: e.Name, url: e.URL, fetched: e.LastSeen, data: e.Payload,
+		}, false)
+		if recErr := quarantine.Replayed(ctx, pool, id, err); recErr != nil {
+			return recErr
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("Payload %d (%s) still fails: %v\n", id, e.Source, err)
+			continue
+		}
+		fmt.Printf("Payload %d (%s) replayed: %d records\n", id, e.Source, n)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d payloads failed to replay", failed, len(ids))
+	}
+	return nil
+}
+
+// upstreamPayload is a stored upstream response to process again.
+type upstreamPayload struct {
+	source  string // nvd, kev, epss, feeds or inbound
+	name    string // the feed name; "" to find the feed by url
+	url     string
+	fetched time.Time
+	data    []byte
+}
+
+// replayPayload hands p to the component that fetched it, set up as the
+// pipeline sets it up, and returns how many records it stored; rewrite
+// sets the runners' rewrite mode.
+func replayPayload(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, rec *quality.Recorder, p upstreamPayload, rewrite bool) (int, error) {
+	switch p.source {
+	case quarantine.NVD:
+		runner := cve.NewNvdRunner(pool, cfg.NVD)
+		runner.SetCvssPriority(cvssPriority(cfg))
+		runner.SetQuality(rec)
+		runner.SetRewrite(rewrite)
+		return runner.Replay(ctx, p.url, p.data)
+	case quarantine.KEV:
+		runner := cve.NewKevRunner(pool, cfg.KEV)
+		runner.SetQuality(rec)
+		runner.SetRewrite(rewrite)
+		return runner.Replay(ctx, p.url, p.data)
+	case quarantine.EPSS:
+		runner := cve.NewEpssRunner(pool, cfg.EPSS)
+		runner.SetQuality(rec)
+		runner.SetRewrite(rewrite)
+		return runner.Replay(ctx, p.data)
+	case quarantine.Feeds:
+		i := slices.IndexFunc(cfg.Feeds, func(f config.Feed) bool {
+			return f.Name == p.name || p.name == "" && f.URL == p.url
+		})
+		if i < 0 && p.name == "" {
+			return 0, fmt.Errorf("no feed is configured with URL %s", p.url)
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("feed %q is no longer configured", p.name)
+		}
+		client, err := replayFeedClient(cfg, pool, rec)
+		if err != nil {
+			return 0, err
+		}
+		return client.Replay(ctx, cfg.Feeds[i], p.data, p.fetched)
+	case quarantine.Inbound:
+		client, err := replayFeedClient(cfg, pool, rec)
+		if err != nil {
+			return 0, err
+		}
+		recv, err := inbound.New(client, cfg.Inbound)
+		if err != nil {
+			return 0, fmt.Errorf("invalid inbound configuration: %w", err)
+		}
+		return recv.Replay(ctx, cmp.Or(p.name, inbound.SourceName(p.url)), p.data)
+	}
+	return 0, fmt.Errorf("unknown source %q", p.source)
+}
+
+// replayFeedClient returns the client that stores re-processed feed items
+// and pushes.
+func replayFeedClient(cfg *config.Config, pool *pgxpool.Pool, rec *quality.Recorder) (*ingestor.Client, error) {
+	client := ingestor.New(pool)
+	if maxAge, err := cfg.GetFeedMaxItemAge(); err == nil {
+		client.SetMaxItemAge(maxAge)
+	}
+	ns, err := ingestor.ParseNamespace(cfg.AdvisoryIDNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("advisory_id_namespace: %w", err)
+	}
+	client.SetIDNamespace(ns)
+	client.SetMaxItems(cfg.FeedMaxItems)
+	client.SetLinkRedirectors(cfg.FeedLinkRedirectors)
+	client.SetTrackingParams(cfg.FeedTrackingParams)
+	client.SetQuality(rec)
+	return client, nil
+}
+
+// runReplayShow writes a quarantined payload to stdout as upstream sent it.
+func runReplayShow(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected: replay show ID")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid payload ID %q", args[0])
+	}
+	_, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	e, err := quarantine.Get(ctx, pool, id)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(e.Payload)
+	return err
+}
+
+var quarantineTable = table.Table[quarantine.Entry]{
+	Columns: []table.Column[quarantine.Entry]{
+		{Name: "id", Value: func(e quarantine.Entry) string { return strconv.FormatInt(e.ID, 10) }},
+		{Name: "source", Value: func(e quarantine.Entry) string { return e.Source }},
+		{Name: "name", Value: func(e quarantine.Entry) string { return e.Name }},
+		{Name: "url", Flex: true, Value: func(e quarantine.Entry) string { return e.URL }},
+		{Name: "size", Value: func(e quarantine.Entry) string { return strconv.Itoa(e.Size) }},
+		{Name: "attempts", Value: func(e quarantine.Entry) string { return strconv.Itoa(e.Attempts) }},
+		{Name: "first_seen", Time: func(e quarantine.Entry) time.Time { return e.FirstSeen }, Layout: time.DateTime},
+		{Name: "last_seen", Time: func(e quarantine.Entry) time.Time { return e.LastSeen }, Layout: time.DateTime},
+		{Name: "replayed", Time: func(e quarantine.Entry) time.Time { return timeOf(e.ReplayedAt) }, Layout: time.DateTime},
+		{Name: "error", Flex: true, Value: func(e quarantine.Entry) string { return e.Error }},
+	},
+	Defaults: []string{"id", "source", "name", "attempts", "last_seen", "error"},
+	Empty:    "No quarantined payloads.",
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/report"
+)
+
+// runWeeklyReport writes the weekly executive report as a PDF: headline
+// counts, new CVEs by severity, the KEV addition trend, how quickly critical
+// alerts were acknowledged and the week's KEV additions. Scheduled weekly
+// (cron or a Kubernetes CronJob), it is the summary for readers who never
+// run the CLI.
+//
+//	tigerfetch report --output reports/week.pdf --sign-key tigerfetch.key
+func runWeeklyReport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	weeks := fs.Int("weeks", 12, "weeks shown by the trend charts")
+	top := fs.Int("top", 15, "KEV additions listed")
+	until := fs.String("until", "", "end of the report's week, RFC 3339 (default now)")
+	lang := fs.String("lang", "", fmt.Sprintf("language of the report: %s (default display.language, else en)",
+		strings.Join(i18n.Languages(), ", ")))
+	tz := fs.String("tz", "", `time zone of the report's dates, e.g. "Europe/Berlin" (default display.timezone, else UTC)`)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	if stdoutIsTerminal(*output) {
+		return errors.New("the report is a PDF: set --output or redirect stdout")
+	}
+	if *weeks < 1 || *weeks > 52 {
+		return fmt.Errorf("--weeks must be between 1 and 52")
+	}
+	if *top < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+	now := time.Now()
+	end := now
+	if *until != "" {
+		t, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+		end = t
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	loc, err := i18n.New(cmp.Or(*lang, cfg.Display.Language, "en"))
+	if err != nil {
+		return fmt.Errorf("--lang: %w", err)
+	}
+	zone, err := cfg.Display.GetTimezone()
+	if *tz != "" {
+		zone, err = config.LoadTimezone(*tz)
+	}
+	if err != nil {
+		return fmt.Errorf("--tz: %w", err)
+	}
+
+	sla, err := cfg.Alerting.Escalation.GetAfter()
+	if err != nil || sla <= 0 {
+		return fmt.Errorf("invalid alerting.escalation.after %q", cfg.Alerting.Escalation.After)
+	}
+
+	w, err := report.Build(ctx, pool, report.Options{
+		Weeks:     *weeks,
+		SLA:       sla,
+		TopKEV:    *top,
+		Timestamp: end,
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, *signKey, report.PDF(w, loc, zone, now))
+}
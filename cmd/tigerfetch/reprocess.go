@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+)
+
+// runReprocess re-parses archived raw payloads ([raw_archive]) and writes
+// the result over the stored rows, so fields a newer release derives (CWE,
+// CPE, a changed [merge] cvss) are backfilled without upstream traffic.
+// Rows whose JSON is unchanged keep updated_at, so `tigerfetch consolidate
+// --rebuild` afterwards carries the rewritten columns into
+// cve_consolidated.
+//
+//	tigerfetch reprocess --source nvd --since 2024-01-01
+func runReprocess(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	source := fs.String("source", "", "only payloads from this source: nvd, kev, epss, feeds or inbound")
+	since := fs.String("since", "", "only payloads first fetched on or after this date (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	opts, err := archiveListOptions(*source, *since)
+	if err != nil {
+		return err
+	}
+	return processArchive(ctx, opts, true)
+}
+
+// processArchive re-processes the archived payloads matching opts in the
+// order they were first fetched. With rewrite, stored rows are rewritten
+// even when unchanged (but never with an older NVD version), and of the KEV
+// catalogs only the newest is used, since each holds the whole list.
+func processArchive(ctx context.Context, opts rawarchive.ListOptions, rewrite bool) error {
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	archive, err := rawarchive.Open(pool, cfg.RawArchive)
+	if err != nil {
+		return err
+	}
+	rec, err := quality.FromConfig(pool, cfg.Quality)
+	if err != nil {
+		return err
+	}
+
+	list, err := archive.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if rewrite {
+		list = newestKevOnly(list)
+	}
+	if len(list) == 0 {
+		fmt.Println("No archived payloads.")
+		return nil
+	}
+	records, failed := 0, 0
+	for i, entry := range list {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e, err := archive.Get(ctx, entry.ID)
+		if err == nil {
+			var n int
+			n, err = replayPayload(ctx, cfg, pool, rec, upstreamPayload{
+				source: e.Source, url: e.URL, fetched: e.FirstFetched, data: e.Payload,
+			}, rewrite)
+			records += n
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("Payload %d (%s, %s) failed: %v\n", entry.ID, entry.Source, entry.URL, err)
+		}
+		slog.Debug("Processed archived payload", "id", entry.ID, "source", entry.Source, "done", i+1, "of", len(list))
+	}
+	fmt.Printf("Processed %d payloads: %d records written\n", len(list), records)
+	if rewrite && records > 0 && opts.Source != quarantine.Feeds {
+		fmt.Println("Run `tigerfetch consolidate --rebuild` to refresh the consolidated CVE records.")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d payloads failed", failed, len(list))
+	}
+	return nil
+}
+
+// newestKevOnly drops all KEV catalogs but the last of list.
+func newestKevOnly(list []rawarchive.Entry) []rawarchive.Entry {
+	last := -1
+	for i, e := range list {
+		if e.Source == quarantine.KEV {
+			last = i
+		}
+	}
+	out := list[:0:0]
+	for i, e := range list {
+		if e.Source != quarantine.KEV || i == last {
+			out = append(out, e)
+		}
+	}
+	return out
+}
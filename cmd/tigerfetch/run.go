@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/budget"
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+	"tiger2go/internal/healthcheck"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/kev"
+	"tiger2go/internal/objstore"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+	"tiger2go/internal/scheduler"
+	"tiger2go/internal/sourcectl"
+	"tiger2go/internal/upstream"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runRun runs the fetch and enrich pipeline once and exits: every due
+// ingestion step concurrently, then the derived steps in order. It is the
+// entrypoint of scheduled runs without a daemon (a CronJob, Cloud Run Jobs,
+// or `tigerfetch serverless` per invocation). The exit status is 1 when a
+// step failed, one of 3 to 5 when a [run.failure] policy failed the run
+// (see runReport.judge), and 0 when [limits] ended the run early; the
+// next run picks up what is left. --only restricts the run to some steps, so each source
+// can be its own CronJob with its own schedule and resource limits.
+//
+//	RUN_STATE_URL=s3://bucket/tigerfetch/state.json tigerfetch run
+//	tigerfetch run --only nvd
+func runRun(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	force := fs.Bool("force", false, "run every step, due or not")
+	only := fs.String("only", "", "run only these comma-separated steps, e.g. nvd, epss, feeds or alerting")
+	summary := fs.String("summary", "", "also write the run summary here: a path, file://, s3:// or gs:// URL (default [run] summary_path)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts := runOptions{Force: *force}
+	if *only != "" {
+		for name := range strings.SplitSeq(*only, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Only = append(opts.Only, name)
+			}
+		}
+	}
+
+	return runOnce(ctx, opts, *summary)
+}
+
+// runOnce runs the steps opts selects, prints the run report and returns
+// the run's exit error. summary overrides [run] summary_path when set.
+func runOnce(ctx context.Context, opts runOptions, summary string) error {
+	r, err := openRunner(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	if summary != "" {
+		r.cfg.Run.SummaryPath = summary
+	}
+
+	report, err := r.run(ctx, opts)
+	report = r.publish(ctx, report, err)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return report.exitErr()
+}
+
+// runner runs the pipeline once per call of run, sharing the database
+// pools between runs of a serverless instance.
+type runner struct {
+	cfg       *config.Config
+	pool      *pgxpool.Pool
+	readPool  *pgxpool.Pool
+	kevCache  *kev.Cache
+	limits    budget.Limits
+	slo       upstream.SLO
+	throttle  *httpclient.Throttle // across runs: a host's limit outlasts one
+	dns       *httpclient.Resolver // across runs, like its cache
+	conns     httpclient.TransportOptions
+	state     objstore.Object     // nil: no run state, every step is due
+	pinger    *healthcheck.Pinger // nil: no dead man's switch
+	transport http.RoundTripper
+
+	mu sync.Mutex // one run at a time: the budget wraps http.DefaultTransport
+}
+
+// openRunner loads the configuration, migrates the database like the
+// daemon does, and connects.
+func openRunner(ctx context.Context) (*runner, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	if cfg.DatabaseURL == "" {
+		return nil, errors.New("DATABASE_URL is required")
+	}
+	limits, err := budget.FromConfig(cfg.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFailurePolicy(cfg); err != nil {
+		return nil, err
+	}
+	slo, err := upstream.FromConfig(cfg.Run.SLO)
+	if err != nil {
+		return nil, err
+	}
+	throttle, err := rateLimitThrottle(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dns, err := dnsResolver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := transportOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &runner{cfg: cfg, limits: limits, slo: slo, throttle: throttle, dns: dns, conns: conns,
+		transport: dns.Transport(sharedTransport(http.DefaultTransport, conns))}
+	// Outside the run's budget: a run that hit its limit still reports in
+	r.pinger = healthcheck.New(cfg.Run.PingURL, cfg.Run.PingFailURL,
+		&http.Client{Transport: r.transport, Timeout: 10 * time.Second})
+	if cfg.Run.StateURL != "" {
+		r.state, err = objstore.Open(cfg.Run.StateURL, objstore.Options{Endpoint: cfg.Run.StateEndpoint, Region: cfg.Run.StateRegion})
+		if err != nil {
+			return nil, fmt.Errorf("run.state_url: %w", err)
+		}
+	}
+
+	if err := db.Migrate(cfg.DatabaseURL, "migrations"); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	r.pool, err = db.NewPoolWithOptions(ctx, cfg.DatabaseURL, poolOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	r.readPool = r.pool
+	if cfg.Database.ReadURL != "" {
+		opts := poolOptions(cfg)
+		opts.ReadOnly = true
+		r.readPool, err = db.NewPoolWithOptions(ctx, cfg.Database.ReadURL, opts)
+		if err != nil {
+			r.pool.Close()
+			return nil, err
+		}
+	}
+	r.kevCache = kev.NewCache(r.readPool, kevCacheRefresh(cfg))
+	return r, nil
+}
+
+func (r *runner) close() {
+	if r.readPool != r.pool {
+		r.readPool.Close()
+	}
+	r.pool.Close()
+}
+
+// runOptions select the steps of one run.
+type runOptions struct {
+	Force bool     `json:"force"` // run every step, due or not
+	Only  []string `json:"only"`  // step names; empty is every step
+}
+
+// selectSteps keeps the steps named in only. A name that is not a step,
+// or a step not enabled in the configuration, is an error rather than a
+// run that silently does nothing.
+func selectSteps(steps []step, only []string) ([]step, error) {
+	if len(only) == 0 {
+		return steps, nil
+	}
+	for _, name := range only {
+		if !slices.Contains(scheduler.Sources(), name) {
+			return nil, fmt.Errorf("unknown step %q (one of %s)", name, strings.Join(scheduler.Sources(), ", "))
+		}
+		if !slices.ContainsFunc(steps, func(s step) bool { return s.name == name }) {
+			return nil, fmt.Errorf("step %q is not enabled in the configuration", name)
+		}
+	}
+	// In pipeline order: derived steps after the ingestion they read
+	var out []step
+	for _, s := range steps {
+		if slices.Contains(only, s.name) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// run runs the due steps once. Errors of steps are in the report; the
+// error is for runs that could not start.
+func (r *runner) run(ctx context.Context, opts runOptions) (*runReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := newRunReport(opts)
+	ctx, b := budget.New(ctx, runLimits(ctx, r.limits))
+	defer b.Stop()
+	// Requests of this run only, measured under the throttle so its
+	// retries show, and under the budget so it can still tell requests it
+	// already counts
+	stats := upstream.New()
+	http.DefaultTransport = b.Transport(r.throttle.Transport(stats.Transport(r.transport)))
+	defer func() { http.DefaultTransport = r.transport }()
+
+	sched, err := scheduler.New(r.cfg.Scheduler)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduler configuration: %w", err)
+	}
+	// One recorder per run, so the summary counts this run's violations
+	dataQuality, err := quality.FromConfig(r.pool, r.cfg.Quality)
+	if err != nil {
+		return nil, err
+	}
+	quarantined, err := quarantine.FromConfig(r.pool, r.cfg.Quarantine)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := rawarchive.FromConfig(r.pool, r.cfg.RawArchive)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := buildPipeline(r.cfg, pipelineDeps{
+		pool:       r.pool,
+		readPool:   r.readPool,
+		kevCache:   r.kevCache,
+		sched:      sched,
+		budget:     b,
+		upstream:   stats,
+		throttle:   r.throttle,
+		dns:        r.dns,
+		conns:      r.conns,
+		quality:    dataQuality,
+		quarantine: quarantined,
+		archive:    archive,
+		audit:      audit.FromConfig(r.pool, r.cfg.Audit, ""), // a run ID per run
+	})
+	if err != nil {
+		return nil, err
+	}
+	if steps, err = selectSteps(steps, opts.Only); err != nil {
+		return nil, err
+	}
+	force := opts.Force
+
+	state, err := r.loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switches, err := sourcectl.List(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	report.Steps = make([]stepReport, len(steps))
+	off := map[string]bool{}
+	for i, s := range steps {
+		report.Steps[i] = stepReport{Name: s.name, Kind: stepKind(s), Status: stepNotDue}
+		if sw, ok := switches[s.name]; ok && !sw.Enabled {
+			off[s.name] = true
+			report.Steps[i].Status = stepDisabled
+			report.Steps[i].LastSuccess = state.step(s.name).LastSuccess
+			report.Disabled = append(report.Disabled, s.name)
+		}
+	}
+
+	var mu sync.Mutex
+	outcome := func(i int, started time.Time, keys []string, errs map[string]error) {
+		mu.Lock()
+		defer mu.Unlock()
+		s, sr := steps[i], &report.Steps[i]
+		sr.Started, sr.DurationSeconds = started.UTC(), time.Since(started).Seconds()
+		if s.keys != nil {
+			sr.Keys = keys
+		}
+		st := state.step(s.name)
+		switch {
+		case len(errs) == 0:
+			sr.Status = stepSucceeded
+			report.Ran = append(report.Ran, s.name)
+		case ctx.Err() != nil:
+			sr.Status = stepInterrupted
+			report.Interrupted = append(report.Interrupted, s.name)
+		default:
+			sr.Status = stepFailed
+			sr.Error = errors.Join(sortedErrors(errs)...).Error()
+			if s.keys != nil {
+				sr.FailedKeys = map[string]string{}
+				for key, err := range errs {
+					sr.FailedKeys[key] = err.Error()
+				}
+			}
+			report.Ran = append(report.Ran, s.name)
+			report.Failed[s.name] = sr.Error
+		}
+		st.LastError = ""
+		if len(errs) > 0 && ctx.Err() == nil {
+			st.LastError = report.Failed[s.name]
+		}
+		finished := time.Now().UTC()
+		for _, key := range keys {
+			if errs[key] == nil && s.keys != nil {
+				st.Keys[key] = finished
+			}
+		}
+		if len(errs) == 0 {
+			st.LastSuccess = finished
+		}
+		sr.LastSuccess = st.LastSuccess
+	}
+
+	// Ingestion steps concurrently: the scheduler budget orders them
+	var wg sync.WaitGroup
+	for i, s := range steps {
+		if s.kind == "" || off[s.name] {
+			continue
+		}
+		keys := state.dueKeys(s, now, force)
+		if len(keys) == 0 {
+			report.NotDue = append(report.NotDue, s.name)
+			report.Steps[i].LastSuccess = state.step(s.name).LastSuccess
+			continue
+		}
+		wg.Go(func() {
+			started := time.Now()
+			outcome(i, started, keys, runKeys(ctx, s, keys))
+		})
+	}
+	wg.Wait()
+
+	// Derived steps in order, on the ingested data
+	if ctx.Err() == nil && slices.ContainsFunc(steps, func(s step) bool { return s.name == scheduler.Alerting }) {
+		if err := r.kevCache.Refresh(ctx); err != nil {
+			slog.Warn("KEV cache refresh failed", "error", err)
+		}
+	}
+	for i, s := range steps {
+		if s.kind != "" || off[s.name] {
+			continue
+		}
+		report.Steps[i].LastSuccess = state.step(s.name).LastSuccess
+		if ctx.Err() != nil {
+			report.Steps[i].Status = stepInterrupted
+			report.Interrupted = append(report.Interrupted, s.name)
+			continue
+		}
+		if len(state.dueKeys(s, now, force)) == 0 {
+			report.NotDue = append(report.NotDue, s.name)
+			continue
+		}
+		outcome(i, time.Now(), singleKey(), runKeys(ctx, s, singleKey()))
+	}
+
+	report.Limit, report.Requests, report.NewAdvisories = b.Reason(), b.Requests(), b.NewAdvisories()
+	report.DataQuality = dataQuality.Counts()
+	report.Upstreams = stats.Hosts()
+	report.SLOBreaches = r.slo.Check(report.Upstreams)
+	for _, br := range report.SLOBreaches {
+		slog.Warn("Upstream missed its SLO", "host", br.Host, "slo", br.SLO, "value", br.Value, "threshold", br.Threshold)
+	}
+	report.finish(r.cfg.Run.Failure)
+	slog.Info("Run finished", "ran", report.Ran, "failed", len(report.Failed),
+		"interrupted", report.Interrupted, "limit", report.Limit, "duration", report.Duration)
+
+	// The run's context may be spent; the checkpoint still has to land
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := r.saveState(saveCtx, state, steps, len(opts.Only) > 0); err != nil {
+		return report, fmt.Errorf("save run state: %w", err)
+	}
+	return report, nil
+}
+
+// checkpointMargin is the time a run keeps before its invocation deadline
+// to stop its steps and save the run state.
+const checkpointMargin = 30 * time.Second
+
+// runLimits tightens max_duration to end the run before ctx's deadline
+// (a Lambda invocation's), so it checkpoints instead of being killed.
+func runLimits(ctx context.Context, limits budget.Limits) budget.Limits {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return limits
+	}
+	left := max(time.Until(deadline)-checkpointMargin, time.Second)
+	if limits.MaxDuration == 0 || left < limits.MaxDuration {
+		limits.MaxDuration = left
+	}
+	return limits
+}
+
+func sortedErrors(errs map[string]error) []error {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	out := make([]error, len(keys))
+	for i, key := range keys {
+		out[i] = errs[key]
+	}
+	return out
+}
+
+// runState is the checkpoint of scheduled runs, stored at run.state_url.
+type runState struct {
+	Steps map[string]*stepState `json:"steps"`
+}
+
+// stepState is a step's progress across runs.
+type stepState struct {
+	LastSuccess time.Time            `json:"last_success,omitzero"`
+	LastError   string               `json:"last_error,omitempty"`
+	Keys        map[string]time.Time `json:"keys,omitempty"` // keyed steps (feeds, EPSS days): last success per key
+}
+
+func (s *runState) step(name string) *stepState {
+	st := s.Steps[name]
+	if st == nil {
+		st = &stepState{}
+		s.Steps[name] = st
+	}
+	if st.Keys == nil {
+		st.Keys = map[string]time.Time{}
+	}
+	return st
+}
+
+// dueSlack lets a step run a little early, so a schedule that matches its
+// poll interval does not skip every other run on jitter.
+const dueSlack = time.Minute
+
+// dueKeys are the keys of s that are due at now: those that never
+// succeeded or succeeded more than an interval ago.
+func (s *runState) dueKeys(st step, now time.Time, force bool) []string {
+	keys := st.stepKeys()
+	if force {
+		return keys
+	}
+	state := s.step(st.name)
+	due := func(last time.Time) bool { return now.Sub(last) >= st.interval-dueSlack }
+	if st.keys == nil {
+		if due(state.LastSuccess) {
+			return keys
+		}
+		return nil
+	}
+	var out []string
+	for _, key := range keys {
+		if due(state.Keys[key]) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func (r *runner) loadState(ctx context.Context) (*runState, error) {
+	state := &runState{Steps: map[string]*stepState{}}
+	if r.state == nil {
+		return state, nil
+	}
+	data, err := r.state.Get(ctx)
+	if errors.Is(err, objstore.ErrNotExist) {
+		slog.Info("No run state yet, running every step", "state", r.state.String())
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load run state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("load run state %s: %w", r.state, err)
+	}
+	if state.Steps == nil {
+		state.Steps = map[string]*stepState{}
+	}
+	return state, nil
+}
+
+// saveState stores state, dropping steps and keys no longer configured
+// (removed feeds, past EPSS days). A partial run (--only) stores just its
+// steps over the latest state, so CronJobs of different sources sharing
+// one state object keep each other's progress.
+func (r *runner) saveState(ctx context.Context, state *runState, steps []step, partial bool) error {
+	if r.state == nil {
+		return nil
+	}
+	live := map[string]step{}
+	for _, s := range steps {
+		live[s.name] = s
+	}
+	if partial {
+		latest, err := r.loadState(ctx)
+		if err != nil {
+			return err
+		}
+		for name := range live {
+			latest.Steps[name] = state.Steps[name]
+		}
+		state = latest
+	}
+	for name, st := range state.Steps {
+		s, ok := live[name]
+		if !ok {
+			if !partial {
+				delete(state.Steps, name)
+			}
+			continue
+		}
+		keys := s.stepKeys()
+		for key := range st.Keys {
+			if !slices.Contains(keys, key) {
+				delete(st.Keys, key)
+			}
+		}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return r.state.Put(ctx, data)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/objstore"
+	"tiger2go/internal/scheduler"
+	"tiger2go/internal/upstream"
+)
+
+// Run outcomes, for orchestration to branch on.
+const (
+	runSucceeded   = "succeeded"   // every due step succeeded, or nothing was due
+	runPartial     = "partial"     // feeds failed within [run.failure] max_failed_feeds_percent
+	runFailed      = "failed"      // a step failed or a [run.failure] policy was violated
+	runInterrupted = "interrupted" // [limits] or the deadline stopped steps; the next run resumes them
+	runError       = "error"       // the run could not start or could not save its state
+)
+
+// Failure policies of [run.failure] and the exit status of each. Other
+// failures exit 1.
+const (
+	policyFeedFailureRate = "feed_failure_rate" // exit 3
+	policyCriticalSource  = "critical_source"   // exit 4
+	policyNoNewItems      = "no_new_items"      // exit 5
+)
+
+// Step statuses.
+const (
+	stepSucceeded   = "succeeded"
+	stepFailed      = "failed"
+	stepInterrupted = "interrupted"
+	stepNotDue      = "not_due"
+	stepDisabled    = "disabled" // switched off with `tigerfetch source disable`
+)
+
+// runReport is the summary of one run: printed by `tigerfetch run`,
+// returned by the serverless handler, sent with healthcheck pings and
+// written to [run] summary_path.
+type runReport struct {
+	Outcome         string                    `json:"outcome"`
+	Error           string                    `json:"error,omitempty"`  // why the outcome is "error"
+	Policy          string                    `json:"policy,omitempty"` // the [run.failure] policy that failed the run
+	Reason          string                    `json:"reason,omitempty"` // why the outcome is "failed" or "partial"
+	ExitCode        int                       `json:"exit_code"`
+	Only            []string                  `json:"only,omitempty"` // the steps a --only run was limited to
+	Started         time.Time                 `json:"started"`
+	Finished        time.Time                 `json:"finished,omitzero"`
+	Duration        string                    `json:"duration"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Steps           []stepReport              `json:"steps"`
+	Ran             []string                  `json:"ran"`
+	NotDue          []string                  `json:"not_due"`
+	Disabled        []string                  `json:"disabled,omitempty"` // switched off at runtime; not run
+	Failed          map[string]string         `json:"failed,omitempty"`
+	Interrupted     []string                  `json:"interrupted,omitempty"` // stopped by the run limit; resumed next run
+	Limit           string                    `json:"limit,omitempty"`       // the [limits] cap that ended the run
+	Requests        int64                     `json:"requests"`
+	NewAdvisories   int64                     `json:"new_advisories"`
+	DataQuality     map[string]map[string]int `json:"data_quality,omitempty"` // violations by source and rule ([quality])
+	Upstreams       []upstream.Host           `json:"upstreams,omitempty"`    // requests per upstream host, most requested first
+	SLOBreaches     []upstream.Breach         `json:"slo_breaches,omitempty"` // hosts that missed their [run.slo] thresholds; warnings only
+	Cursors         map[string]string         `json:"cursors,omitempty"`      // ingest_state after the run: NVD, KEV, remotes, alerting
+}
+
+// stepReport is one step of a run, in pipeline order.
+type stepReport struct {
+	Name            string            `json:"name"`
+	Kind            string            `json:"kind"`                  // "ingestion" or "derived"
+	Status          string            `json:"status"`                // succeeded, failed, interrupted, not_due or disabled
+	Keys            []string          `json:"keys,omitempty"`        // keyed steps: the feeds or EPSS days run
+	Error           string            `json:"error,omitempty"`       // every failure, joined
+	FailedKeys      map[string]string `json:"failed_keys,omitempty"` // keyed steps: the failure of each key
+	Started         time.Time         `json:"started,omitzero"`
+	DurationSeconds float64           `json:"duration_seconds,omitempty"`
+	LastSuccess     time.Time         `json:"last_success,omitzero"` // across runs, from the run state
+}
+
+func newRunReport(opts runOptions) *runReport {
+	return &runReport{Only: opts.Only, Started: time.Now().UTC(), Steps: []stepReport{},
+		Ran: []string{}, NotDue: []string{}, Failed: map[string]string{}}
+}
+
+func stepKind(s step) string {
+	if s.kind == "" {
+		return "derived"
+	}
+	return "ingestion"
+}
+
+// finish stamps the end of the run and judges its outcome.
+func (rep *runReport) finish(policy config.RunFailureConfig) {
+	rep.Finished = time.Now().UTC()
+	elapsed := rep.Finished.Sub(rep.Started)
+	rep.Duration = elapsed.Round(time.Millisecond).String()
+	rep.DurationSeconds = elapsed.Seconds()
+	rep.judge(policy)
+}
+
+// judge sets the outcome and exit status. In order: an error exits 1, a
+// failed critical source 4, too many failed feeds 3, any other failed
+// step (and failed feeds without max_failed_feeds_percent) 1, and feeds
+// that ran without a new advisory 5. Feed failures within the tolerated
+// share make the run partial, which exits 0 like an interrupted run.
+func (rep *runReport) judge(policy config.RunFailureConfig) {
+	rep.Outcome, rep.Policy, rep.Reason, rep.ExitCode = runSucceeded, "", "", 0
+	fail := func(p, reason string, code int) {
+		rep.Outcome, rep.Policy, rep.Reason, rep.ExitCode = runFailed, p, reason, code
+	}
+	if rep.Error != "" {
+		rep.Outcome, rep.ExitCode = runError, 1
+		return
+	}
+
+	var feeds *stepReport
+	for i := range rep.Steps {
+		if rep.Steps[i].Name == scheduler.Feeds {
+			feeds = &rep.Steps[i]
+		}
+	}
+	for _, name := range policy.CriticalSources {
+		if rep.Failed[name] != "" {
+			fail(policyCriticalSource, "critical source "+name+" failed", 4)
+			return
+		}
+		if feeds != nil && feeds.FailedKeys[name] != "" {
+			fail(policyCriticalSource, "critical feed "+name+" failed", 4)
+			return
+		}
+	}
+
+	feedsFailed := feeds != nil && feeds.Status == stepFailed
+	tolerated := false
+	if feedsFailed && policy.MaxFailedFeedsPercent > 0 {
+		pct := 100 * float64(len(feeds.FailedKeys)) / float64(max(len(feeds.Keys), 1))
+		reason := fmt.Sprintf("%d of %d feeds failed (%.0f%%, max %g%%)",
+			len(feeds.FailedKeys), len(feeds.Keys), pct, policy.MaxFailedFeedsPercent)
+		if pct > policy.MaxFailedFeedsPercent {
+			fail(policyFeedFailureRate, reason, 3)
+			return
+		}
+		tolerated = true
+		rep.Reason = reason
+	}
+	var failed []string
+	for name := range rep.Failed {
+		if !(tolerated && name == scheduler.Feeds) {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		slices.Sort(failed)
+		fail("", strings.Join(failed, ", ")+" failed", 1)
+		return
+	}
+
+	if policy.FailOnNoNewItems && feeds != nil && feeds.Status != stepNotDue && rep.NewAdvisories == 0 {
+		fail(policyNoNewItems, "feeds ran but stored no new advisory", 5)
+		return
+	}
+	switch {
+	case tolerated:
+		rep.Outcome = runPartial
+	case len(rep.Interrupted) > 0:
+		rep.Outcome = runInterrupted
+	}
+}
+
+// exitErr is the error ending `tigerfetch run` or failing an invocation,
+// nil unless the run failed.
+func (rep *runReport) exitErr() error {
+	if rep.ExitCode == 0 {
+		return nil
+	}
+	reason := rep.Reason
+	if rep.Error != "" {
+		reason = rep.Error
+	}
+	if rep.Policy != "" {
+		reason = rep.Policy + ": " + reason
+	}
+	return &exitError{code: rep.ExitCode, err: errors.New("run " + rep.Outcome + ": " + reason)}
+}
+
+// checkFailurePolicy validates [run.failure]. Critical sources that are
+// neither a step nor a configured feed are reported, not rejected: a
+// feed may be removed before the policy is updated.
+func checkFailurePolicy(cfg *config.Config) error {
+	p := cfg.Run.Failure
+	if p.MaxFailedFeedsPercent < 0 || p.MaxFailedFeedsPercent > 100 {
+		return fmt.Errorf("run.failure.max_failed_feeds_percent must be between 0 and 100, got %g", p.MaxFailedFeedsPercent)
+	}
+	for _, name := range p.CriticalSources {
+		known := slices.Contains(scheduler.Sources(), name) ||
+			slices.ContainsFunc(cfg.Feeds, func(f config.Feed) bool { return f.Name == name })
+		if !known {
+			slog.Warn("run.failure.critical_sources names no step or feed", "source", name)
+		}
+	}
+	return nil
+}
+
+// publish completes the report of a run (one that could not start gets
+// an error report), adds the ingestion cursors, writes it to
+// summary_path and pings the healthcheck. Failing to write the summary
+// or to ping is logged and does not change the run's outcome.
+func (r *runner) publish(ctx context.Context, report *runReport, runErr error) *runReport {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if report == nil {
+		report = newRunReport(runOptions{})
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+		report.finish(r.cfg.Run.Failure)
+	}
+	if cursors, err := r.cursors(ctx); err != nil {
+		slog.Warn("Reading ingestion cursors for the run summary failed", "error", err)
+	} else {
+		report.Cursors = cursors
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		slog.Error("Encoding the run summary failed", "error", err)
+		return report
+	}
+	if path := r.cfg.Run.SummaryPath; path != "" {
+		if err := r.writeSummary(ctx, path, report.Started, body); err != nil {
+			slog.Warn("Writing the run summary failed", "path", path, "error", err)
+		}
+	}
+
+	if r.pinger != nil {
+		if report.ExitCode != 0 {
+			err = r.pinger.Fail(ctx, body)
+		} else {
+			err = r.pinger.Success(ctx, body)
+		}
+		if err != nil {
+			slog.Warn("Healthcheck ping failed", "error", err)
+		}
+	}
+	return report
+}
+
+// cursors reads every source's position from ingest_state.
+func (r *runner) cursors(ctx context.Context) (map[string]string, error) {
+	rows, err := r.readPool.Query(ctx, "SELECT source, cursor FROM ingest_state ORDER BY source")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var source, cursor string
+		if err := rows.Scan(&source, &cursor); err != nil {
+			return nil, err
+		}
+		out[source] = cursor
+	}
+	return out, rows.Err()
+}
+
+// writeSummary stores the summary at path, a local path or an object URL.
+// "{started}" in path is replaced by the run's start time, so runs can
+// keep a history ("s3://bucket/runs/{started}.json") instead of replacing
+// the last summary. S3 URLs use the run state's endpoint and region.
+func (r *runner) writeSummary(ctx context.Context, path string, started time.Time, body []byte) error {
+	path = strings.ReplaceAll(path, "{started}", started.UTC().Format("20060102T150405Z"))
+	if !strings.Contains(path, "://") {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = "file://" + abs
+	}
+	obj, err := objstore.Open(path, objstore.Options{Endpoint: r.cfg.Run.StateEndpoint, Region: r.cfg.Run.StateRegion})
+	if err != nil {
+		return err
+	}
+	return obj.Put(ctx, append(body, '\n'))
+}
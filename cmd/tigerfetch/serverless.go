@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"tiger2go/internal/lambda"
+)
+
+// runServerless serves single pipeline runs as a function, so a schedule
+// (EventBridge, Cloud Scheduler) drives tigerfetch with no server of its
+// own. Under AWS Lambda (AWS_LAMBDA_RUNTIME_API is set; the binary is the
+// custom runtime's bootstrap) each invocation is a run that ends before the
+// invocation's deadline. Elsewhere, for Cloud Functions and Cloud Run, it
+// listens on $PORT and each POST / is a run. Either way the reply is the
+// run report, and a run with failed steps is a failed invocation.
+//
+//	tigerfetch serverless
+func runServerless(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serverless", flag.ContinueOnError)
+	addr := fs.String("addr", "", "listen address outside Lambda (default :$PORT, then :8080)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := openRunner(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	if api := os.Getenv("AWS_LAMBDA_RUNTIME_API"); api != "" {
+		slog.Info("Serving Lambda invocations")
+		return lambda.Serve(ctx, api, func(ctx context.Context, event []byte) ([]byte, error) {
+			return invokeRun(ctx, r, event)
+		})
+	}
+
+	if *addr == "" {
+		*addr = ":" + os.Getenv("PORT")
+		if *addr == ":" {
+			*addr = ":8080"
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := readEvent(req)
+		out, err := invokeRun(req.Context(), r, body)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			if out == nil {
+				out, _ = json.Marshal(map[string]string{"error": err.Error()})
+			}
+		}
+		_, _ = w.Write(out)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, "OK")
+	})
+	server := &http.Server{Addr: *addr, Handler: mux, ReadTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	slog.Info("Serving run invocations over HTTP", "addr", *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runEvent is the optional invocation payload, e.g. {"only": ["epss"]}.
+// Schedulers send their own event documents; unknown fields are ignored.
+type runEvent = runOptions
+
+// readEvent reads an HTTP invocation's payload, which is small if any.
+func readEvent(req *http.Request) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(req.Body, 64<<10))
+}
+
+// invokeRun runs the pipeline for one invocation and returns the report.
+// A failed run fails the invocation, with the report still returned.
+func invokeRun(ctx context.Context, r *runner, event []byte) ([]byte, error) {
+	var ev runEvent
+	if len(event) > 0 {
+		if err := json.Unmarshal(event, &ev); err != nil {
+			slog.Warn("Ignoring invocation payload that is not a JSON object", "error", err)
+		}
+	}
+	report, err := r.run(ctx, ev)
+	report = r.publish(ctx, report, err)
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := report.exitErr(); err != nil {
+		return out, fmt.Errorf("%w: %s", err, string(out))
+	}
+	return out, nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/jobs"
+	"tiger2go/internal/scheduler"
+	"tiger2go/internal/sourcectl"
+	"tiger2go/internal/table"
+)
+
+// runSource lists the pipeline's sources, switches them off and on at
+// runtime, or runs one now.
+//
+//	tigerfetch source list
+//	tigerfetch source disable nvd
+//	tigerfetch source run feeds "CISA Advisories"
+//	tigerfetch source run --here merge
+func runSource(ctx context.Context, args []string) error {
+	usage := errors.New("expected: source list|enable|disable|run")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "list":
+		return runSourceList(ctx, args[1:])
+	case "enable":
+		return runSourceSwitch(ctx, args[1:], true)
+	case "disable":
+		return runSourceSwitch(ctx, args[1:], false)
+	case "run":
+		return runSourceRun(ctx, args[1:])
+	}
+	return usage
+}
+
+// sourceRow is one source in `tigerfetch source list`.
+type sourceRow struct {
+	Source     string    `json:"source"`
+	Kind       string    `json:"kind,omitempty"` // "ingestion" or "derived"; empty when not configured
+	Configured bool      `json:"configured"`     // enabled in the configuration
+	Enabled    bool      `json:"enabled"`        // the runtime switch; true unless disabled
+	Interval   string    `json:"interval,omitempty"`
+	Switched   time.Time `json:"switched,omitzero"` // last enable or disable
+}
+
+func runSourceList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("source list", flag.ContinueOnError)
+	format := fs.String("format", "table", "output format: table or json")
+	tf := addTableFlags(fs, sourceTable)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	topts, err := tf.options("")
+	if err != nil {
+		return err
+	}
+	if err := sourceTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+	steps, err := configuredSteps(cfg)
+	if err != nil {
+		return err
+	}
+	switches, err := sourcectl.List(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]sourceRow, 0, len(scheduler.Sources()))
+	for _, name := range scheduler.Sources() {
+		row := sourceRow{Source: name, Enabled: true}
+		if sw, ok := switches[name]; ok {
+			row.Enabled, row.Switched = sw.Enabled, sw.UpdatedAt
+		}
+		if i := slices.IndexFunc(steps, func(s step) bool { return s.name == name }); i >= 0 {
+			row.Configured, row.Kind, row.Interval = true, stepKind(steps[i]), steps[i].interval.String()
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rows); err != nil {
+			return err
+		}
+	case "table":
+		if err := sourceTable.Write(&buf, rows, topts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// runSourceSwitch turns sources on or off. The daemon reads the switch
+// before each run, so no restart is needed.
+func runSourceSwitch(ctx context.Context, names []string, enabled bool) error {
+	if len(names) == 0 {
+		return fmt.Errorf("expected a source name (one of %s)", strings.Join(scheduler.Sources(), ", "))
+	}
+	for _, name := range names {
+		if err := checkSourceName(name); err != nil {
+			return err
+		}
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	steps, err := configuredSteps(cfg)
+	if err != nil {
+		return err
+	}
+
+	state, action := "disabled", audit.SourceDisable
+	if enabled {
+		state, action = "enabled", audit.SourceEnable
+	}
+	trail := auditLog(cfg, pool)
+	for _, name := range names {
+		if err := sourcectl.Set(ctx, pool, name, enabled); err != nil {
+			return err
+		}
+		fmt.Printf("Source %s %s\n", name, state)
+		if err := trail.Record(ctx, action, name, nil); err != nil {
+			return err
+		}
+		if enabled && !slices.ContainsFunc(steps, func(s step) bool { return s.name == name }) {
+			fmt.Fprintf(os.Stderr, "note: %s is not enabled in the configuration and does not run until it is\n", name)
+		}
+	}
+	return nil
+}
+
+// runSourceRun runs a source now, out of schedule. An ingestion source is
+// queued for the daemon's job worker, which picks it up at its next poll
+// and retries it like a scheduled run; a derived source, or any source
+// with --here, runs in this process like `tigerfetch run --only NAME
+// --force`.
+func runSourceRun(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("source run", flag.ContinueOnError)
+	here := fs.Bool("here", false, "run in this process rather than queueing for the daemon")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tigerfetch source run [flags] NAME [KEY...]\n\nKEYs select feeds by name; the default is every key.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("expected a source name (one of %s)", strings.Join(scheduler.Sources(), ", "))
+	}
+	name, keys := fs.Arg(0), fs.Args()[1:]
+	if err := checkSourceName(name); err != nil {
+		return err
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if enabled, err := sourcectl.Enabled(ctx, pool, name); err != nil {
+		return err
+	} else if !enabled {
+		return fmt.Errorf("source %s is disabled; enable it with `tigerfetch source enable %s`", name, name)
+	}
+	steps, err := configuredSteps(cfg)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(steps, func(s step) bool { return s.name == name })
+	if i < 0 {
+		return fmt.Errorf("source %s is not enabled in the configuration", name)
+	}
+	s := steps[i]
+	if len(keys) > 0 {
+		if s.keys == nil {
+			return fmt.Errorf("source %s takes no keys", name)
+		}
+		if *here {
+			return errors.New("--here runs every key; drop it to queue some")
+		}
+		if name == scheduler.Feeds {
+			for _, key := range keys {
+				if !slices.Contains(s.stepKeys(), key) {
+					return fmt.Errorf("no feed named %q in the configuration", key)
+				}
+			}
+		}
+	} else {
+		keys = s.stepKeys()
+	}
+
+	trail := auditLog(cfg, pool)
+	if s.kind == "" || *here {
+		if err := trail.Record(ctx, audit.SourceRun, name, map[string]any{"here": true}); err != nil {
+			return err
+		}
+		pool.Close()
+		return runOnce(ctx, runOptions{Force: true, Only: []string{name}}, "")
+	}
+
+	queue, err := jobs.Open(ctx, pool, cfg.Jobs)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := queue.Enqueue(ctx, s.kind, key); err != nil {
+			return err
+		}
+		if key == "" {
+			fmt.Printf("Queued %s\n", name)
+		} else {
+			fmt.Printf("Queued %s %q\n", name, key)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "The daemon's job worker runs queued jobs at its next poll; a job of the same key already queued or running is kept instead.")
+	return trail.Record(ctx, audit.SourceRun, name, map[string]any{"keys": keys})
+}
+
+func checkSourceName(name string) error {
+	if !slices.Contains(scheduler.Sources(), name) {
+		return fmt.Errorf("unknown source %q (one of %s)", name, strings.Join(scheduler.Sources(), ", "))
+	}
+	return nil
+}
+
+// configuredSteps returns the steps cfg enables, built without the
+// resources they run on; only their names, kinds, intervals and keys are
+// of use.
+func configuredSteps(cfg *config.Config) ([]step, error) {
+	return buildPipeline(cfg, pipelineDeps{})
+}
+
+var sourceTable = table.Table[sourceRow]{
+	Columns: []table.Column[sourceRow]{
+		{Name: "source", Value: func(r sourceRow) string { return r.Source }},
+		{Name: "kind", Value: func(r sourceRow) string { return r.Kind }},
+		{Name: "configured", Value: func(r sourceRow) string { return strconv.FormatBool(r.Configured) }},
+		{Name: "enabled", Value: func(r sourceRow) string { return strconv.FormatBool(r.Enabled) }},
+		{Name: "interval", Value: func(r sourceRow) string { return r.Interval }},
+		{Name: "switched", Time: func(r sourceRow) time.Time { return r.Switched }, Layout: time.DateTime},
+	},
+	Defaults: []string{"source", "kind", "configured", "enabled", "interval", "switched"},
+}
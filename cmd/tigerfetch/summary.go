@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/cluster"
+	"tiger2go/internal/db"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/schema"
+	"tiger2go/internal/summarizer"
+	"tiger2go/internal/summary"
+	"tiger2go/internal/table"
+)
+
+// runSummary prints the digest of new KEV entries, EPSS movers, newly
+// critical CVEs and events reported by several sources. [summary] sets the defaults; flags override them per run.
+//
+//	tigerfetch summary --window 168h --sections kev,critical --sort cvss
+func runSummary(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	window := fs.String("window", "", "look-back period (default summary.window, else 24h)")
+	sections := fs.String("sections", "", "sections to show, comma-separated: kev, epss_movers, critical, events (default summary.sections)")
+	maxItems := fs.Int("max-items", 0, "items per section (default summary.max_items, else 10)")
+	sortBy := fs.String("sort", "", "item order: risk, epss, cvss or date (default summary.sort, else risk)")
+	analysis := fs.Bool("analysis", true, "add LLM analyst notes to events when [summarizer] is enabled")
+	format := fs.String("format", "table", "output format: table or json")
+	schemaVersion := addSchemaVersionFlag(fs)
+	tf := addTableFlags(fs, summaryTable)
+	output := fs.String("output", "", "write to this file and record it in the directory's SHA256SUMS manifest")
+	signKey := fs.String("sign-key", "", "sign --output with this key (see `tigerfetch keygen`)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *signKey != "" && *output == "" {
+		return fmt.Errorf("--sign-key requires --output")
+	}
+	if *format != "json" && *format != "table" {
+		return fmt.Errorf("invalid --format %q", *format)
+	}
+	if err := checkSchemaVersion(*schemaVersion); err != nil {
+		return err
+	}
+	topts, err := tf.options(*output)
+	if err != nil {
+		return err
+	}
+	if err := summaryTable.Check(topts); err != nil {
+		return fmt.Errorf("--columns: %w", err)
+	}
+
+	cfg, pool, err := openPool(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	loc, err := tf.localizer(cfg)
+	if err != nil {
+		return err
+	}
+	if err := tf.localize(&topts, cfg); err != nil {
+		return err
+	}
+
+	sc := cfg.Summary
+	if *window != "" {
+		sc.Window = *window
+	}
+	if *sections != "" {
+		sc.Sections = table.Split(*sections)
+	}
+	if *maxItems != 0 {
+		sc.MaxItems = *maxItems
+	}
+	if *sortBy != "" {
+		sc.Sort = *sortBy
+	}
+	opts, err := summary.NewOptions(sc)
+	if err != nil {
+		return err
+	}
+	opts.Feeds = cfg.Feeds
+	if opts.Actors, err = actors.New(cfg.ActorTags); err != nil {
+		return err
+	}
+	if cfg.Summarizer.Enabled && *analysis && slices.Contains(opts.Sections, summary.SectionEvents) {
+		llm, err := summarizer.New(cfg.Summarizer)
+		if err != nil {
+			return err
+		}
+		// Notes are stored, so they need the primary even when reports read
+		// from a replica.
+		wpool, err := db.NewPoolWithOptions(ctx, cfg.DatabaseURL, poolOptions(cfg))
+		if err != nil {
+			return err
+		}
+		defer wpool.Close()
+		opts.Annotator = summarizer.NewAnnotator(wpool, llm)
+	}
+
+	s, err := summary.Build(ctx, pool, newEnricher(cfg, pool, nil, false), opts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if *format == "json" {
+		s.SchemaVersion = schema.Version
+		out, err := schema.Encode("summary", *schemaVersion, s)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	} else if err := writeSummary(&buf, s, topts, loc); err != nil {
+		return err
+	}
+	return writeOutput(*output, *signKey, buf.Bytes())
+}
+
+var summaryTable = table.Table[summary.Item]{
+	Columns: []table.Column[summary.Item]{
+		{Name: "cve", Value: func(it summary.Item) string { return it.CveID }},
+		{Name: "cvss", Color: true, Value: func(it summary.Item) string { return formatFloat(it.CVSS, 1) }},
+		{Name: "severity", Color: true, Value: func(it summary.Item) string { return it.Severity }},
+		{Name: "epss", Value: func(it summary.Item) string { return formatFloat(it.EPSS, 4) }},
+		{Name: "delta", Value: func(it summary.Item) string {
+			if it.EPSSDelta == nil {
+				return ""
+			}
+			return "+" + strconv.FormatFloat(*it.EPSSDelta, 'f', 4, 64)
+		}},
+		{Name: "kev", Value: func(it summary.Item) string { return strconv.FormatBool(it.InKEV) }},
+		{Name: "ransomware", Value: func(it summary.Item) string { return strconv.FormatBool(it.Ransomware) }},
+		{Name: "exploit", Value: func(it summary.Item) string { return strconv.FormatBool(it.ExploitRef) }},
+		{Name: "eol", Flex: true, Value: func(it summary.Item) string { return strings.Join(eol.Labels(it.EOL), ", ") }},
+		{Name: "title", Flex: true, Value: func(it summary.Item) string { return it.Title }},
+		{Name: "patch", Flex: true, Value: func(it summary.Item) string { return it.PatchURL }},
+		{Name: "advisory", Flex: true, Value: func(it summary.Item) string { return it.AdvisoryLabel }},
+		{Name: "date", Value: func(it summary.Item) string { return it.Date.UTC().Format(time.DateOnly) }},
+	},
+	Defaults: []string{"cve", "cvss", "epss", "delta", "kev", "title"},
+	Severity: func(it summary.Item) string { return it.Severity },
+	Empty:    "  None.",
+}
+
+// eventTable lists the events section, with headlines in the language of
+// loc. --columns applies to the CVE sections only.
+func eventTable(loc *i18n.Localizer) table.Table[cluster.Event] {
+	return table.Table[cluster.Event]{
+		Columns: []table.Column[cluster.Event]{
+			{Name: "event", Flex: true, Value: func(e cluster.Event) string { return headline(loc, e) }},
+			{Name: "cvss", Color: true, Value: func(e cluster.Event) string {
+				if e.CVSS == 0 {
+					return ""
+				}
+				return strconv.FormatFloat(e.CVSS, 'f', 1, 64)
+			}},
+			{Name: "kev", Value: func(e cluster.Event) string { return strconv.FormatBool(e.KEV) }},
+			{Name: "actors", Flex: true, Value: func(e cluster.Event) string { return strings.Join(e.Actors, ", ") }},
+			{Name: "cves", Flex: true, Value: func(e cluster.Event) string { return strings.Join(e.CVEs, " ") }},
+			{Name: "last", Time: func(e cluster.Event) time.Time { return e.Last }},
+		},
+		Defaults: []string{"event", "cvss", "kev", "actors", "cves", "last"},
+		Severity: func(e cluster.Event) string { return e.Severity },
+		Empty:    "  " + loc.T("summary.none", nil),
+	}
+}
+
+// headline is cluster.Event.Headline in the language of loc.
+func headline(loc *i18n.Localizer, e cluster.Event) string {
+	return loc.T("summary.event", map[string]any{"Title": e.Title, "Advisories": len(e.Advisories), "Sources": len(e.Sources)})
+}
+
+// writeSummary renders s as text in the language of loc. Section titles
+// and required actions in the JSON output stay English.
+func writeSummary(w io.Writer, s *summary.Summary, opts table.Options, loc *i18n.Localizer) error {
+	zone := cmp.Or(opts.Location, time.UTC)
+	_, _ = fmt.Fprintf(w, "%s\n", loc.T("summary.since", map[string]string{"Since": s.Since.In(zone).Format("2006-01-02 15:04 MST")}))
+	items, events := summaryTable, eventTable(loc)
+	items.Empty = "  " + loc.T("summary.none", nil)
+	for _, sec := range s.Sections {
+		shown := len(sec.Items)
+		if sec.Name == summary.SectionEvents {
+			shown = len(sec.Events)
+		}
+		count := strconv.Itoa(sec.Total)
+		if shown < sec.Total {
+			count = loc.T("summary.showing", map[string]int{"Total": sec.Total, "Shown": shown})
+		}
+		_, _ = fmt.Fprintf(w, "\n%s (%s)\n", loc.T("summary.section."+sec.Name, nil), count)
+		var err error
+		if sec.Name == summary.SectionEvents {
+			eopts := opts
+			eopts.Columns = nil
+			if err = events.Write(w, sec.Events, eopts); err == nil {
+				writeAnalyses(w, sec.Events, loc)
+			}
+		} else if err = items.Write(w, sec.Items, opts); err == nil {
+			writeRequiredActions(w, sec.Items, loc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRequiredActions prints, below a table, what to do about items in
+// end-of-life releases, which will not be patched.
+func writeRequiredActions(w io.Writer, items []summary.Item, loc *i18n.Localizer) {
+	for _, it := range items {
+		if len(it.EOL) > 0 {
+			_, _ = fmt.Fprintf(w, "\n  %s: %s\n", it.CveID, eol.LocalizedAction(loc, it.EOL))
+		}
+	}
+}
+
+// writeAnalyses prints the analyst notes of events below their table.
+func writeAnalyses(w io.Writer, events []cluster.Event, loc *i18n.Localizer) {
+	for _, e := range events {
+		if e.Analysis == nil {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\n  %s\n    %s\n", headline(loc, e), e.Analysis.Summary)
+		for _, a := range e.Analysis.Actions {
+			_, _ = fmt.Fprintf(w, "    - %s\n", a)
+		}
+		_, _ = fmt.Fprintf(w, "    (%s)\n", e.Analysis.Model)
+	}
+}
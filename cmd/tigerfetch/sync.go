@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/mirror"
+)
+
+// runSync pulls another instance's /changes feed into the local database,
+// resuming from the cursor the last run committed. Schedule it (cron, a
+// systemd timer) to keep a mirror current, or set [remote] to have the
+// daemon do it. The local database must already be migrated, e.g. by
+// starting the daemon once. TLS settings (ca_file, client certificate,
+// pins) come from [remote]; the flags override its url and api_key.
+//
+//	TIGERFETCH_SYNC_API_KEY=... tigerfetch sync --from https://central:9102
+func runSync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	from := fs.String("from", "", "base URL of the source instance's API, e.g. https://central:9102 (default remote.url)")
+	apiKey := fs.String("api-key", "", "API key for the source (default $TIGERFETCH_SYNC_API_KEY, then remote.api_key)")
+	pageSize := fs.Int("page-size", 0, "changes per request, 1-1000 (default remote.page_size, then 1000)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pageSize < 0 || *pageSize > 1000 {
+		return fmt.Errorf("--page-size must be between 1 and 1000")
+	}
+
+	cfg, pool, err := openPool(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	remote := cfg.Remote
+	if *from != "" {
+		remote.URL = *from
+	}
+	if remote.URL == "" {
+		return fmt.Errorf("--from is required when remote.url is not set")
+	}
+	if *apiKey != "" {
+		remote.APIKey = *apiKey
+	} else if key := os.Getenv("TIGERFETCH_SYNC_API_KEY"); key != "" {
+		remote.APIKey = key
+	}
+	if *pageSize > 0 {
+		remote.PageSize = *pageSize
+	}
+	client, err := mirror.NewRemoteClient(remote)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	stats, err := mirror.NewSyncer(pool, client, remotePageSize(remote)).Run(ctx)
+	// Pages applied before a failure stay committed, so report them either way.
+	slog.Info("Sync finished",
+		"from", client.BaseURL(),
+		"advisories", stats[mirror.KindAdvisory],
+		"cves", stats[mirror.KindCVE],
+		"kev_changes", stats[mirror.KindKEV],
+		"epss", stats[mirror.KindEPSS],
+		"duration", time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+// remotePageSize is remote.page_size, defaulting to the API maximum.
+func remotePageSize(cfg config.RemoteConfig) int {
+	if cfg.PageSize <= 0 || cfg.PageSize > 1000 {
+		return 1000
+	}
+	return cfg.PageSize
+}
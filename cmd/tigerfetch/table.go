@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/table"
+)
+
+// tableFlags are the --columns, --width, --color, --lang and --tz flags
+// of commands with table output.
+type tableFlags struct {
+	columns *string
+	width   *int
+	color   *string
+	lang    *string
+	tz      *string
+}
+
+func addTableFlags[T any](fs *flag.FlagSet, t table.Table[T]) *tableFlags {
+	return &tableFlags{
+		columns: fs.String("columns", "", fmt.Sprintf("table columns, comma-separated (default %s; available: %s)",
+			strings.Join(t.Defaults, ","), strings.Join(t.Names(), ","))),
+		width: fs.Int("width", 0, "fit table rows in this many characters (0: terminal width, -1: no limit)"),
+		color: fs.String("color", "auto", "colour table rows by severity: auto, always or never"),
+		lang: fs.String("lang", "", fmt.Sprintf("language of table headers and report text: %s (default display.language, else en)",
+			strings.Join(i18n.Languages(), ", "))),
+		tz: fs.String("tz", "", `time zone of table timestamps, e.g. "Europe/Berlin" or "Local" (default display.timezone, else UTC)`),
+	}
+}
+
+// options resolves the flags for a table written to path ("" for stdout).
+// Terminal width and automatic colour only apply to a terminal stdout.
+func (f *tableFlags) options(path string) (table.Options, error) {
+	terminal, width := false, 0
+	if path == "" {
+		terminal, width = table.Terminal(os.Stdout)
+	}
+	switch {
+	case *f.width > 0:
+		width = *f.width
+	case *f.width < 0:
+		width = 0
+	}
+	color, err := table.UseColor(*f.color, terminal)
+	if err != nil {
+		return table.Options{}, fmt.Errorf("--color: %w", err)
+	}
+	if *f.lang != "" {
+		if _, err := i18n.New(*f.lang); err != nil {
+			return table.Options{}, fmt.Errorf("--lang: %w", err)
+		}
+	}
+	if _, err := config.LoadTimezone(*f.tz); err != nil {
+		return table.Options{}, fmt.Errorf("--tz: %w", err)
+	}
+	return table.Options{Columns: table.Split(*f.columns), Width: width, Color: color}, nil
+}
+
+// localizer is the language of the report: --lang, else display.language.
+func (f *tableFlags) localizer(cfg *config.Config) (*i18n.Localizer, error) {
+	if *f.lang != "" {
+		return i18n.New(*f.lang)
+	}
+	loc, err := i18n.New(cmp.Or(cfg.Display.Language, "en"))
+	if err != nil {
+		return nil, fmt.Errorf("display.language: %w", err)
+	}
+	return loc, nil
+}
+
+// location is the time zone of the report: --tz, else display.timezone.
+func (f *tableFlags) location(cfg *config.Config) (*time.Location, error) {
+	if *f.tz != "" {
+		return config.LoadTimezone(*f.tz)
+	}
+	loc, err := cfg.Display.GetTimezone()
+	if err != nil {
+		return nil, fmt.Errorf("display.timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// localize translates the table headers of opts into the report language
+// and shows timestamps in the report time zone.
+func (f *tableFlags) localize(opts *table.Options, cfg *config.Config) error {
+	loc, err := f.localizer(cfg)
+	if err != nil {
+		return err
+	}
+	if opts.Location, err = f.location(cfg); err != nil {
+		return err
+	}
+	opts.Header = loc.Header
+	return nil
+}
+
+// stdoutIsTerminal reports whether output to path lands on a terminal.
+func stdoutIsTerminal(path string) bool {
+	if path != "" {
+		return false
+	}
+	terminal, _ := table.Terminal(os.Stdout)
+	return terminal
+}
+
+// timeOf is *t, or the zero time (an empty Time cell) when t is nil.
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func formatFloat(v *float64, prec int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', prec, 64)
+}
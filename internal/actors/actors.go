@@ -0,0 +1,167 @@
+// Package actors tags advisories with the threat actors and ransomware
+// families they name. A Dictionary matches names and aliases as whole
+// words, case-insensitively; the built-in list covers prominent groups and
+// [actor_tags] adds or replaces entries. Names that are common words
+// ("Play", "Royal") are only matched with "ransomware" after them.
+package actors
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cveid"
+)
+
+// Kinds of actor.
+const (
+	KindRansomware = "ransomware"
+	KindActor      = "actor"
+)
+
+// Actor is one dictionary entry.
+type Actor struct {
+	Name    string   // canonical name, used as the tag
+	Kind    string   // KindRansomware or KindActor
+	Aliases []string // other names matched in text; Name itself is matched too
+}
+
+// Builtin is the default dictionary.
+var Builtin = []Actor{
+	// Ransomware families
+	{Name: "LockBit", Kind: KindRansomware, Aliases: []string{"LockBit 3.0", "LockBit Black"}},
+	{Name: "ALPHV", Kind: KindRansomware, Aliases: []string{"BlackCat", "Noberus"}},
+	{Name: "Cl0p", Kind: KindRansomware, Aliases: []string{"Clop"}},
+	{Name: "Black Basta", Kind: KindRansomware},
+	{Name: "Akira", Kind: KindRansomware},
+	{Name: "BlackSuit", Kind: KindRansomware, Aliases: []string{"Royal ransomware"}},
+	{Name: "Play", Kind: KindRansomware, Aliases: []string{"Play ransomware", "PlayCrypt"}},
+	{Name: "Medusa", Kind: KindRansomware, Aliases: []string{"Medusa ransomware"}},
+	{Name: "Rhysida", Kind: KindRansomware},
+	{Name: "Hive", Kind: KindRansomware, Aliases: []string{"Hive ransomware"}},
+	{Name: "Conti", Kind: KindRansomware},
+	{Name: "REvil", Kind: KindRansomware, Aliases: []string{"Sodinokibi"}},
+	{Name: "Ryuk", Kind: KindRansomware},
+	{Name: "8Base", Kind: KindRansomware},
+	{Name: "BianLian", Kind: KindRansomware},
+	{Name: "Qilin", Kind: KindRansomware, Aliases: []string{"Agenda ransomware"}},
+	{Name: "RansomHub", Kind: KindRansomware},
+	{Name: "Cuba", Kind: KindRansomware, Aliases: []string{"Cuba ransomware"}},
+
+	// State and criminal groups
+	{Name: "APT28", Kind: KindActor, Aliases: []string{"Fancy Bear", "Forest Blizzard", "Sofacy"}},
+	{Name: "APT29", Kind: KindActor, Aliases: []string{"Cozy Bear", "Midnight Blizzard", "Nobelium"}},
+	{Name: "Sandworm", Kind: KindActor, Aliases: []string{"APT44", "Seashell Blizzard"}},
+	{Name: "Turla", Kind: KindActor, Aliases: []string{"Secret Blizzard"}},
+	{Name: "Lazarus Group", Kind: KindActor, Aliases: []string{"Lazarus", "Hidden Cobra", "Diamond Sleet"}},
+	{Name: "Kimsuky", Kind: KindActor, Aliases: []string{"Emerald Sleet"}},
+	{Name: "APT41", Kind: KindActor, Aliases: []string{"Brass Typhoon", "Double Dragon"}},
+	{Name: "Volt Typhoon", Kind: KindActor, Aliases: []string{"Bronze Silhouette", "Vanguard Panda"}},
+	{Name: "Salt Typhoon", Kind: KindActor, Aliases: []string{"GhostEmperor"}},
+	{Name: "Silk Typhoon", Kind: KindActor, Aliases: []string{"Hafnium"}},
+	{Name: "UNC5221", Kind: KindActor, Aliases: []string{"UTA0178"}},
+	{Name: "MuddyWater", Kind: KindActor, Aliases: []string{"Mango Sandstorm"}},
+	{Name: "APT35", Kind: KindActor, Aliases: []string{"Charming Kitten", "Mint Sandstorm"}},
+	{Name: "Scattered Spider", Kind: KindActor, Aliases: []string{"Octo Tempest", "UNC3944"}},
+	{Name: "FIN7", Kind: KindActor, Aliases: []string{"Carbanak"}},
+	{Name: "TA505", Kind: KindActor},
+}
+
+// ambiguous built-in names are matched only through their aliases.
+var ambiguous = []string{"Play", "Medusa", "Hive", "Cuba"}
+
+// Dictionary matches actor names in text.
+type Dictionary struct {
+	actors   []Actor
+	patterns []*regexp.Regexp
+}
+
+// New builds a dictionary from Builtin, unless cfg.DisableBuiltin, plus the
+// configured actors. A configured actor replaces the built-in one of the
+// same name.
+func New(cfg config.ActorTagsConfig) (*Dictionary, error) {
+	var list []Actor
+	if !cfg.DisableBuiltin {
+		list = slices.Clone(Builtin)
+	}
+	var configured []Actor
+	for _, a := range cfg.Actors {
+		name := strings.TrimSpace(a.Name)
+		if name == "" {
+			return nil, fmt.Errorf("actor_tags.actors: name is required")
+		}
+		kind := strings.ToLower(a.Kind)
+		if kind == "" {
+			kind = KindActor
+		}
+		if kind != KindActor && kind != KindRansomware {
+			return nil, fmt.Errorf("actor_tags.actors %q: unknown kind %q: want %s or %s", name, a.Kind, KindActor, KindRansomware)
+		}
+		list = slices.DeleteFunc(list, func(b Actor) bool { return strings.EqualFold(b.Name, name) })
+		configured = append(configured, Actor{Name: name, Kind: kind, Aliases: a.Aliases})
+	}
+	builtins := len(list)
+	list = append(list, configured...)
+
+	d := &Dictionary{}
+	for i, a := range list {
+		var names []string
+		if i >= builtins || !slices.Contains(ambiguous, a.Name) {
+			names = append(names, regexp.QuoteMeta(a.Name))
+		}
+		for _, alias := range a.Aliases {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				names = append(names, regexp.QuoteMeta(alias))
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		re, err := regexp.Compile(`(?i)\b(?:` + strings.Join(names, "|") + `)\b`)
+		if err != nil {
+			return nil, fmt.Errorf("actor %q: %w", a.Name, err)
+		}
+		d.actors = append(d.actors, a)
+		d.patterns = append(d.patterns, re)
+	}
+	return d, nil
+}
+
+// Match returns the actors named in texts, in dictionary order. Text is
+// normalized like CVE extraction, so HTML entities and Unicode spaces do
+// not hide a name. A nil Dictionary matches nothing.
+func (d *Dictionary) Match(texts ...string) []Actor {
+	if d == nil {
+		return nil
+	}
+	norm := make([]string, len(texts))
+	for i, t := range texts {
+		norm[i] = cveid.Normalize(t)
+	}
+	var out []Actor
+	for i, re := range d.patterns {
+		for _, t := range norm {
+			if re.MatchString(t) {
+				out = append(out, d.actors[i])
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Names returns the names of actors.
+func Names(actors []Actor) []string {
+	out := make([]string, len(actors))
+	for i, a := range actors {
+		out[i] = a.Name
+	}
+	return out
+}
+
+// AnyRansomware reports whether any of actors is a ransomware family.
+func AnyRansomware(actors []Actor) bool {
+	return slices.ContainsFunc(actors, func(a Actor) bool { return a.Kind == KindRansomware })
+}
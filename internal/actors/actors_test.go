@@ -0,0 +1,52 @@
+package actors
+
+import (
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch_Builtin(t *testing.T) {
+	d, err := New(config.ActorTagsConfig{})
+	require.NoError(t, err)
+
+	got := d.Match(
+		"CISA and FBI warn of LockBit&nbsp;3.0 affiliates exploiting Citrix Bleed",
+		"<p>Midnight Blizzard and UTA0178 activity; not the play button or a royal visit.</p>",
+	)
+	assert.Equal(t, []string{"LockBit", "APT29", "UNC5221"}, Names(got))
+	assert.True(t, AnyRansomware(got))
+
+	assert.Equal(t, []string{"Play"}, Names(d.Match("Play ransomware actors")))
+	assert.Empty(t, d.Match("Press play to continue; Hive OS update; clockbits"))
+	assert.False(t, AnyRansomware(d.Match("Volt Typhoon")))
+
+	var none *Dictionary
+	assert.Empty(t, none.Match("LockBit"))
+}
+
+func TestNew_Configured(t *testing.T) {
+	d, err := New(config.ActorTagsConfig{Actors: []config.ActorConfig{
+		{Name: "Hive", Kind: "Ransomware"}, // replaces the built-in entry, bare name included
+		{Name: "UNC4841", Aliases: []string{"Barracuda ESG actor"}},
+	}})
+	require.NoError(t, err)
+	got := d.Match("Hive and UNC4841 again")
+	assert.Equal(t, []Actor{
+		{Name: "Hive", Kind: KindRansomware},
+		{Name: "UNC4841", Kind: KindActor, Aliases: []string{"Barracuda ESG actor"}},
+	}, got)
+
+	d, err = New(config.ActorTagsConfig{DisableBuiltin: true, Actors: []config.ActorConfig{{Name: "Acme Crew"}}})
+	require.NoError(t, err)
+	assert.Empty(t, d.Match("LockBit"))
+	assert.Equal(t, []string{"Acme Crew"}, Names(d.Match("the ACME crew")))
+
+	_, err = New(config.ActorTagsConfig{Actors: []config.ActorConfig{{Name: " "}}})
+	assert.Error(t, err)
+	_, err = New(config.ActorTagsConfig{Actors: []config.ActorConfig{{Name: "X", Kind: "nation"}}})
+	assert.Error(t, err)
+}
@@ -2,13 +2,23 @@ package alerting
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"tiger2go/internal/actors"
+	"tiger2go/internal/audit"
 	"tiger2go/internal/config"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/kev"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/owners"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -26,31 +36,142 @@ type SleeperCVE struct {
 	CvssScore    *float64
 	CvssSeverity string
 	CWE          string
+	InKEV        bool           // set from the in-memory KEV set, when configured
+	Ransomware   bool           // KEV knows of ransomware campaigns using it; needs the KEV set too
+	ExploitRef   bool           // NVD lists a reference tagged Exploit
+	PatchURL     string         // first NVD reference tagged Patch
+	Owners       []owners.Owner // the teams owning the affected products ([[owners]])
+	EPSSChart    string         // PNG of its EPSS trend, with [alerting] chart_url
+}
+
+// KevAddition is a CVE newly added to the CISA KEV catalog (from kev_changes).
+type KevAddition struct {
+	ID                int64    `json:"-"`
+	CVEID             string   `json:"cve_id"`
+	VendorProject     string   `json:"vendor_project"`
+	Product           string   `json:"product"`
+	VulnerabilityName string   `json:"vulnerability_name"`
+	DateAdded         string   `json:"date_added"`
+	DueDate           string   `json:"due_date"`
+	RequiredAction    string   `json:"required_action"`
+	CatalogVersion    string   `json:"catalog_version"`
+	Ransomware        bool     `json:"known_ransomware_use"`
+	Actors            []string `json:"actors"` // threat actors and ransomware families named in the entry
+
+	EOL       []eol.Affected `json:"eol,omitempty"`        // affected product releases past end of life
+	EOLAction string         `json:"eol_action,omitempty"` // what to do instead of patching them
+
+	Owners    []owners.Owner `json:"owners,omitempty"`     // the teams owning the product ([[owners]])
+	EPSSChart string         `json:"epss_chart,omitempty"` // PNG of its EPSS trend, with [alerting] chart_url
 }
 
 // Runner detects sleeper CVEs and sends webhook notifications.
 type Runner struct {
 	db       *pgxpool.Pool
+	read     *pgxpool.Pool // detection queries; db unless SetReadPool is called
+	kev      *kev.Cache
+	actors   *actors.Dictionary
+	owners   *owners.Map
+	audit    *audit.Log // nil: notifications are not audited
 	cfg      config.AlertingConfig
 	webhooks []WebhookSender
+	policies []deliveryPolicy // per webhook, by index
+	store    deliveryStore
+	now      func() time.Time
+
+	escalations   []WebhookSender // [alerting.escalation] webhooks
+	escalateAfter time.Duration
 }
 
-// NewRunner creates a new alerting runner.
+// NewRunner creates a new alerting runner. Invalid delivery settings of a
+// webhook (see DeliveryPolicy) fall back to sending every run.
 func NewRunner(db *pgxpool.Pool, cfg config.AlertingConfig) *Runner {
 	senders := make([]WebhookSender, 0, len(cfg.Webhooks))
+	policies := make([]deliveryPolicy, 0, len(cfg.Webhooks))
 	for _, wh := range cfg.Webhooks {
-		senders = append(senders, NewWebhookSender(wh))
+		s := NewWebhookSender(wh)
+		s.charts = cfg.ChartURL
+		senders = append(senders, s)
+		p, err := resolvePolicy(cfg, wh)
+		if err != nil {
+			slog.Warn("Invalid webhook delivery settings, sending every run", "webhook", wh.Name, "error", err)
+			p = deliveryPolicy{}
+		}
+		policies = append(policies, p)
 	}
-	return &Runner{db: db, cfg: cfg, webhooks: senders}
+	r := &Runner{db: db, read: db, cfg: cfg, webhooks: senders, policies: policies,
+		store: pgStore{db: db}, now: time.Now}
+	if cfg.Escalation.Enabled {
+		for _, wh := range cfg.Escalation.Webhooks {
+			s := NewWebhookSender(wh)
+			s.charts = cfg.ChartURL
+			r.escalations = append(r.escalations, s)
+		}
+		after, err := cfg.Escalation.GetAfter()
+		if err != nil || after <= 0 {
+			slog.Warn("Invalid escalation delay, using default 4h", "after", cfg.Escalation.After, "error", err)
+			after = 4 * time.Hour
+		}
+		r.escalateAfter = after
+	}
+	return r
 }
 
-// Run executes one detection cycle: find sleeper CVEs and notify.
+// SetReadPool routes the detection queries (EPSS history, KEV changes) to a
+// separate pool, typically a read replica. Cursors are still read from and
+// written to the primary, so replica lag can delay an alert but never
+// repeat one.
+func (r *Runner) SetReadPool(pool *pgxpool.Pool) { r.read = pool }
+
+// SetKevCache flags sleepers that are already in CISA KEV.
+func (r *Runner) SetKevCache(c *kev.Cache) { r.kev = c }
+
+// SetActors tags KEV additions with the actors their name, description and
+// notes mention.
+func (r *Runner) SetActors(d *actors.Dictionary) { r.actors = d }
+
+// SetOwners tags notifications with the teams owning the affected
+// products, and sends the webhooks a team routes to only its own.
+func (r *Runner) SetOwners(m *owners.Map) { r.owners = m }
+
+// SetAudit records each notification sent in the audit trail ([audit]).
+func (r *Runner) SetAudit(l *audit.Log) { r.audit = l }
+
+// epssChart is the URL of the API's EPSS trend image of cveID, or "" when
+// no chart_url is configured.
+func epssChart(base, cveID string) string {
+	if base == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/charts/epss/" + url.PathEscape(cveID) + "?format=png&days=30"
+}
+
+// Run executes one detection cycle: find sleeper CVEs (and, if enabled,
+// new KEV additions), notify, send the digests that are due and escalate
+// the critical alerts nobody acknowledged.
 func (r *Runner) Run(ctx context.Context) error {
 	start := time.Now()
 	defer func() {
 		metrics.AlertingRunDuration.Observe(time.Since(start).Seconds())
 	}()
 
+	if r.cfg.KevAdditions {
+		if err := r.runKevAdditions(ctx); err != nil {
+			slog.Error("Alerting: KEV additions notification failed", "error", err)
+		}
+	}
+
+	err := r.runSleepers(ctx)
+	r.flushDigests(ctx)
+	if r.cfg.Escalation.Enabled {
+		if err := r.runEscalations(ctx); err != nil {
+			slog.Error("Alerting: escalation failed", "error", err)
+		}
+	}
+	return err
+}
+
+func (r *Runner) runSleepers(ctx context.Context) error {
 	lookback := r.cfg.LookbackDays
 	if lookback <= 0 {
 		lookback = 7
@@ -68,6 +189,21 @@ func (r *Runner) Run(ctx context.Context) error {
 		return nil
 	}
 
+	if r.kev != nil {
+		cat := r.kev.Catalog()
+		for i := range sleepers {
+			entry, ok := cat.Get(sleepers[i].CVEID)
+			sleepers[i].InKEV, sleepers[i].Ransomware = ok, entry.Ransomware
+		}
+	}
+
+	if err := r.assignSleeperOwners(ctx, sleepers); err != nil {
+		slog.Warn("Alerting: matching sleeper owners failed", "error", err)
+	}
+	for i := range sleepers {
+		sleepers[i].EPSSChart = epssChart(r.cfg.ChartURL, sleepers[i].CVEID)
+	}
+
 	slog.Info("Alerting: sleeper CVEs detected", "count", len(sleepers))
 	metrics.AlertingSleeperCVEs.Add(float64(len(sleepers)))
 
@@ -88,14 +224,11 @@ func (r *Runner) Run(ctx context.Context) error {
 		return nil
 	}
 
-	// Send to all configured webhooks
-	for _, wh := range r.webhooks {
-		if err := wh.Send(ctx, sleepers); err != nil {
+	// Send to all configured webhooks, or queue for their digests
+	for i, wh := range r.webhooks {
+		items := routed(r.owners, wh.Name(), sleepers, func(s SleeperCVE) []owners.Owner { return s.Owners })
+		if err := deliver(ctx, r, i, kindSleeper, items, sleeperKey, wh.Send); err != nil {
 			slog.Error("Alerting: webhook delivery failed", "webhook", wh.Name(), "error", err)
-			metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "error").Inc()
-		} else {
-			slog.Info("Alerting: webhook delivered", "webhook", wh.Name(), "sleepers", len(sleepers))
-			metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "success").Inc()
 		}
 	}
 
@@ -159,7 +292,17 @@ func (r *Runner) detect(ctx context.Context, lookbackDays int) ([]SleeperCVE, er
 				(SELECT json->'weaknesses'->0->'description'->0->>'value'
 				 FROM cve_enriched WHERE cve_id = n.cve_id LIMIT 1),
 				''
-			) AS cwe
+			) AS cwe,
+			COALESCE(
+				(SELECT jsonb_path_exists(json, '$.references[*].tags[*] ? (@ == "Exploit")')
+				 FROM cve_enriched WHERE cve_id = n.cve_id AND source = 'NVD'),
+				false
+			) AS exploit_ref,
+			COALESCE(
+				(SELECT jsonb_path_query_first(json, '$.references[*] ? (@.tags[*] == "Patch").url') #>> '{}'
+				 FROM cve_enriched WHERE cve_id = n.cve_id AND source = 'NVD'),
+				''
+			) AS patch_url
 		FROM now_scores n
 		JOIN before_scores b ON n.cve_id = b.cve_id
 		WHERE b.epss < 0.10
@@ -168,7 +311,7 @@ func (r *Runner) detect(ctx context.Context, lookbackDays int) ([]SleeperCVE, er
 		LIMIT 50
 	`
 
-	rows, err := r.db.Query(ctx, query, lookbackDays)
+	rows, err := r.read.Query(ctx, query, lookbackDays)
 	if err != nil {
 		return nil, fmt.Errorf("sleeper query failed: %w", err)
 	}
@@ -182,6 +325,7 @@ func (r *Runner) detect(ctx context.Context, lookbackDays int) ([]SleeperCVE, er
 			&s.PctChange, &s.Percentile,
 			&s.DateBefore, &s.DateNow, &s.Description,
 			&s.CvssScore, &s.CvssSeverity, &s.CWE,
+			&s.ExploitRef, &s.PatchURL,
 		); err != nil {
 			return nil, fmt.Errorf("scan sleeper row: %w", err)
 		}
@@ -189,3 +333,154 @@ func (r *Runner) detect(ctx context.Context, lookbackDays int) ([]SleeperCVE, er
 	}
 	return sleepers, rows.Err()
 }
+
+// runKevAdditions notifies webhooks of CVEs added to KEV since the last
+// notified kev_changes row. On first run only additions within the lookback
+// window are sent. The cursor stays put while every webhook fails, so the
+// additions are retried on the next run.
+func (r *Runner) runKevAdditions(ctx context.Context) error {
+	var cursor string
+	err := r.db.QueryRow(ctx,
+		"SELECT cursor FROM ingest_state WHERE source = 'ALERTING-KEV'",
+	).Scan(&cursor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		cursor = "0" // first run
+	} else if err != nil {
+		return fmt.Errorf("read KEV alerting cursor: %w", err)
+	}
+	lastID, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		lastID = 0
+	}
+
+	lookback := r.cfg.LookbackDays
+	if lookback <= 0 {
+		lookback = 7
+	}
+
+	additions, err := r.detectKevAdditions(ctx, lastID, lookback)
+	if err != nil {
+		return err
+	}
+	if len(additions) == 0 {
+		return nil
+	}
+
+	slog.Info("Alerting: new KEV additions", "count", len(additions))
+	for i := range additions {
+		additions[i].EPSSChart = epssChart(r.cfg.ChartURL, additions[i].CVEID)
+	}
+	if !r.notifyKevAdditions(ctx, additions) {
+		return fmt.Errorf("KEV additions: all %d webhook deliveries failed", len(r.webhooks))
+	}
+
+	maxID := additions[0].ID
+	for _, a := range additions {
+		if a.ID > maxID {
+			maxID = a.ID
+		}
+	}
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO ingest_state (source, cursor) VALUES ('ALERTING-KEV', $1)
+		ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+	`, strconv.FormatInt(maxID, 10))
+	if err != nil {
+		return fmt.Errorf("update KEV alerting cursor: %w", err)
+	}
+	return nil
+}
+
+// notifyKevAdditions hands additions to every webhook and reports whether
+// at least one took them: sent them, queued them for its digest or had
+// nothing new to send. Without webhooks there is nobody to retry for.
+func (r *Runner) notifyKevAdditions(ctx context.Context, additions []KevAddition) bool {
+	ok := len(r.webhooks) == 0
+	for i, wh := range r.webhooks {
+		items := routed(r.owners, wh.Name(), additions, func(a KevAddition) []owners.Owner { return a.Owners })
+		if err := deliver(ctx, r, i, kindKev, items, kevKey, wh.SendKevAdditions); err != nil {
+			slog.Error("Alerting: webhook delivery failed", "webhook", wh.Name(), "error", err)
+			continue
+		}
+		ok = true
+	}
+	return ok
+}
+
+// detectKevAdditions returns 'added' kev_changes rows newer than lastID.
+func (r *Runner) detectKevAdditions(ctx context.Context, lastID int64, lookbackDays int) ([]KevAddition, error) {
+	rows, err := r.read.Query(ctx, `
+		SELECT id, cve_id,
+		       COALESCE(vendor_project, ''), COALESCE(product, ''), COALESCE(vulnerability_name, ''),
+		       COALESCE(json->>'dateAdded', ''), COALESCE(json->>'dueDate', ''),
+		       COALESCE(json->>'requiredAction', ''), catalog_version,
+		       COALESCE(json->>'knownRansomwareCampaignUse', '') = 'Known',
+		       COALESCE(json->>'shortDescription', ''), COALESCE(json->>'notes', '')
+		FROM kev_changes
+		WHERE change_type = 'added' AND NOT archived
+		  AND id > $1
+		  AND detected_at >= now() - make_interval(days => $2)
+		ORDER BY id
+	`, lastID, lookbackDays)
+	if err != nil {
+		return nil, fmt.Errorf("KEV additions query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var additions []KevAddition
+	for rows.Next() {
+		var a KevAddition
+		var description, notes string
+		if err := rows.Scan(&a.ID, &a.CVEID, &a.VendorProject, &a.Product, &a.VulnerabilityName,
+			&a.DateAdded, &a.DueDate, &a.RequiredAction, &a.CatalogVersion,
+			&a.Ransomware, &description, &notes); err != nil {
+			return nil, fmt.Errorf("scan KEV addition row: %w", err)
+		}
+		a.Actors = actors.Names(r.actors.Match(a.VulnerabilityName, description, notes))
+		additions = append(additions, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(additions))
+	for i, a := range additions {
+		ids[i] = a.CVEID
+	}
+	affected, err := eol.ForCVEs(ctx, r.read, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range additions {
+		additions[i].EOL = affected[additions[i].CVEID]
+		additions[i].EOLAction = eol.RequiredAction(additions[i].EOL)
+	}
+	if r.owners != nil {
+		cpes, err := owners.CPEs(ctx, r.read, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, a := range additions {
+			additions[i].Owners = r.owners.Match(owners.Affected{Vendor: a.VendorProject, Product: a.Product, CPEs: cpes[a.CVEID]})
+		}
+	}
+	return additions, nil
+}
+
+// assignSleeperOwners matches sleepers to owners by their NVD CPEs.
+func (r *Runner) assignSleeperOwners(ctx context.Context, sleepers []SleeperCVE) error {
+	if r.owners == nil {
+		return nil
+	}
+	ids := make([]string, len(sleepers))
+	for i, s := range sleepers {
+		ids[i] = s.CVEID
+	}
+	cpes, err := owners.CPEs(ctx, r.read, ids)
+	if err != nil {
+		return err
+	}
+	for i := range sleepers {
+		sleepers[i].Owners = r.owners.Match(owners.Affected{CPEs: cpes[sleepers[i].CVEID]})
+	}
+	return nil
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 
 	"tiger2go/internal/config"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/i18n"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,10 +34,12 @@ func TestBuildSlackPayload(t *testing.T) {
 			CvssScore:    ptr(9.8),
 			CvssSeverity: "CRITICAL",
 			CWE:          "CWE-94",
+			ExploitRef:   true,
+			PatchURL:     "https://git.spip.net/spip/spip/commit/abc123",
 		},
 	}
 
-	body, err := buildSlackPayload(sleepers)
+	body, err := buildSlackPayload(i18n.English(), sleepers)
 	require.NoError(t, err)
 
 	s := string(body)
@@ -52,6 +57,8 @@ func TestBuildSlackPayload(t *testing.T) {
 	assert.Contains(t, s, "CRITICAL")
 	assert.Contains(t, s, "CWE-94")
 	assert.Contains(t, s, "SPIP plugin RCE")
+	assert.Contains(t, s, "Public exploit")
+	assert.Contains(t, s, "https://git.spip.net/spip/spip/commit/abc123|Patch")
 }
 
 func TestBuildSlackPayload_NoCvss(t *testing.T) {
@@ -65,11 +72,31 @@ func TestBuildSlackPayload_NoCvss(t *testing.T) {
 		},
 	}
 
-	body, err := buildSlackPayload(sleepers)
+	body, err := buildSlackPayload(i18n.English(), sleepers)
 	require.NoError(t, err)
 	assert.Contains(t, string(body), "CVSS: _n/a_")
 }
 
+func TestBuildPayloads_InKEV(t *testing.T) {
+	sleepers := []SleeperCVE{
+		{CVEID: "CVE-2024-21887", EpssBefore: 0.02, EpssNow: 0.97, DateBefore: "2024-01-05", DateNow: "2024-01-12", InKEV: true, Ransomware: true},
+		{CVEID: "CVE-2025-99999", EpssBefore: 0.01, EpssNow: 0.55, DateBefore: "2024-01-05", DateNow: "2024-01-12"},
+	}
+
+	body, err := buildSlackPayload(i18n.English(), sleepers)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(body), "In CISA KEV"))
+	assert.Equal(t, 1, strings.Count(string(body), "Known ransomware use"))
+
+	body, err = buildGenericPayload(sleepers)
+	require.NoError(t, err)
+	var payload genericPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.True(t, payload.Sleepers[0].InKEV)
+	assert.True(t, payload.Sleepers[0].Ransomware)
+	assert.False(t, payload.Sleepers[1].InKEV)
+}
+
 func TestBuildGenericPayload(t *testing.T) {
 	sleepers := []SleeperCVE{
 		{
@@ -191,7 +218,7 @@ func TestBuildSlackPayload_TruncatesLongDescriptions(t *testing.T) {
 		},
 	}
 
-	body, err := buildSlackPayload(sleepers)
+	body, err := buildSlackPayload(i18n.English(), sleepers)
 	require.NoError(t, err)
 	// The full 200-char description should not appear
 	assert.NotContains(t, string(body), longDesc)
@@ -210,7 +237,88 @@ func TestBuildSlackPayload_CapsAt10(t *testing.T) {
 		}
 	}
 
-	body, err := buildSlackPayload(sleepers)
+	body, err := buildSlackPayload(i18n.English(), sleepers)
 	require.NoError(t, err)
 	assert.Contains(t, string(body), "and 5 more")
 }
+
+func TestBuildSlackKevPayload(t *testing.T) {
+	additions := []KevAddition{
+		{
+			CVEID:             "CVE-2025-0282",
+			VendorProject:     "Ivanti",
+			Product:           "Connect Secure",
+			VulnerabilityName: "Stack-Based Buffer Overflow",
+			DueDate:           "2025-01-15",
+			RequiredAction:    "Apply mitigations per vendor instructions.",
+			CatalogVersion:    "2025.01.08",
+			Ransomware:        true,
+			Actors:            []string{"UNC5221"},
+			EOL:               []eol.Affected{{Product: "Ivanti Connect Secure", Release: "9.1", Supported: "22.7"}},
+			EOLAction:         "End of life, no fix will be released: upgrade Ivanti Connect Secure 9.1 to 22.7; isolate or decommission systems that cannot be upgraded.",
+		},
+	}
+
+	body, err := buildSlackKevPayload(i18n.English(), additions)
+	require.NoError(t, err)
+
+	s := string(body)
+	assert.Contains(t, s, "New in CISA KEV")
+	assert.Contains(t, s, "nvd.nist.gov/vuln/detail/CVE-2025-0282")
+	assert.Contains(t, s, "Ivanti Connect Secure")
+	assert.Contains(t, s, "2025-01-15")
+	assert.Contains(t, s, "2025.01.08")
+	assert.Contains(t, s, "Known ransomware use")
+	assert.Contains(t, s, "Actors: *UNC5221*")
+	assert.Contains(t, s, "*End of life:* Ivanti Connect Secure 9.1")
+	assert.Contains(t, s, "upgrade Ivanti Connect Secure 9.1 to 22.7")
+}
+
+func TestBuildSlackKevPayload_Localized(t *testing.T) {
+	additions := []KevAddition{{
+		CVEID:          "CVE-2025-0282",
+		DueDate:        "2025-01-15",
+		CatalogVersion: "2025.01.08",
+		Ransomware:     true,
+		EOL:            []eol.Affected{{Product: "Ivanti Connect Secure", Release: "9.1", Supported: "22.7"}},
+	}}
+	de, err := i18n.New("de")
+	require.NoError(t, err)
+
+	body, err := buildSlackKevPayload(de, additions)
+	require.NoError(t, err)
+
+	s := string(body)
+	assert.Contains(t, s, "Neu im CISA-KEV — 1 CVE hinzugefügt")
+	assert.Contains(t, s, "Katalog *2025.01.08*")
+	assert.Contains(t, s, "Bekannte Ransomware-Nutzung")
+	assert.Contains(t, s, "Fällig: *2025-01-15*")
+	assert.Contains(t, s, "Ivanti Connect Secure 9.1 auf 22.7 aktualisieren")
+}
+
+func TestEPSSChart(t *testing.T) {
+	assert.Empty(t, epssChart("", "CVE-2025-0282"))
+	url := epssChart("https://tiger.example.com/", "CVE-2025-0282")
+	assert.Equal(t, "https://tiger.example.com/charts/epss/CVE-2025-0282?format=png&days=30", url)
+
+	body, err := buildSlackKevPayload(i18n.English(), []KevAddition{{CVEID: "CVE-2025-0282", EPSSChart: url}})
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"accessory":{"alt_text":"EPSS trend","image_url":"https://tiger.example.com/charts/epss/CVE-2025-0282?format=png\u0026days=30","type":"image"}`)
+
+	body, err = buildSlackPayload(i18n.English(), []SleeperCVE{{CVEID: "CVE-2025-0282"}})
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "accessory")
+}
+
+func TestBuildGenericKevPayload(t *testing.T) {
+	additions := []KevAddition{{CVEID: "CVE-2025-0282"}, {CVEID: "CVE-2025-0283"}}
+
+	body, err := buildGenericKevPayload(additions)
+	require.NoError(t, err)
+
+	var payload genericKevPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "kev_added", payload.Event)
+	assert.Equal(t, 2, payload.Count)
+	assert.Equal(t, "CVE-2025-0283", payload.Additions[1].CVEID)
+}
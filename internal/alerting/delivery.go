@@ -0,0 +1,322 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/owners"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Notification kinds, the namespaces of delivery state.
+const (
+	kindSleeper = "sleeper"
+	kindKev     = "kev_added"
+
+	kindEscalation = "escalation" // not delivered through a policy
+)
+
+// defaultDigestMaxWait bounds how long a digest smaller than digest_size
+// waits for more items.
+const defaultDigestMaxWait = 24 * time.Hour
+
+// deliveryPolicy is how notifications reach one webhook.
+type deliveryPolicy struct {
+	cooldown      time.Duration // 0: resend whenever detected
+	digestSize    int           // 0: send every run
+	digestMaxWait time.Duration
+}
+
+func (p deliveryPolicy) digest() bool { return p.digestSize > 0 }
+
+// DeliveryPolicy resolves a webhook's cooldown and digest settings against
+// the [alerting] defaults, failing on invalid ones.
+func DeliveryPolicy(cfg config.AlertingConfig, wh config.WebhookConfig) error {
+	_, err := resolvePolicy(cfg, wh)
+	return err
+}
+
+func resolvePolicy(cfg config.AlertingConfig, wh config.WebhookConfig) (deliveryPolicy, error) {
+	var p deliveryPolicy
+	var err error
+	if s := firstNonEmpty(wh.Cooldown, cfg.Cooldown); s != "" {
+		if p.cooldown, err = time.ParseDuration(s); err != nil || p.cooldown < 0 {
+			return p, fmt.Errorf("invalid cooldown %q", s)
+		}
+	}
+	p.digestSize = cfg.DigestSize
+	if wh.DigestSize != 0 {
+		p.digestSize = wh.DigestSize
+	}
+	if p.digestSize < 0 {
+		return p, fmt.Errorf("invalid digest_size %d", p.digestSize)
+	}
+	p.digestMaxWait = defaultDigestMaxWait
+	if s := firstNonEmpty(wh.DigestMaxWait, cfg.DigestMaxWait); s != "" {
+		if p.digestMaxWait, err = time.ParseDuration(s); err != nil || p.digestMaxWait <= 0 {
+			return p, fmt.Errorf("invalid digest_max_wait %q", s)
+		}
+	}
+	return p, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// queued is a notification waiting in a webhook's digest.
+type queued struct {
+	key      string
+	item     json.RawMessage
+	queuedAt time.Time
+}
+
+// deliveryStore keeps per-webhook notification state across runs.
+type deliveryStore interface {
+	// recent returns the keys sent to webhook since since.
+	recent(ctx context.Context, webhook, kind string, keys []string, since time.Time) (map[string]bool, error)
+	markSent(ctx context.Context, webhook, kind string, keys []string, at time.Time) error
+	// prune forgets deliveries sent before before.
+	prune(ctx context.Context, before time.Time) error
+	// enqueue adds items to webhook's digest; items already waiting stay
+	// as queued.
+	enqueue(ctx context.Context, webhook, kind string, items []queued) error
+	// pending lists webhook's digest, oldest first.
+	pending(ctx context.Context, webhook, kind string) ([]queued, error)
+	ack(ctx context.Context, webhook, kind string, keys []string) error
+}
+
+// pgStore is the deliveryStore in Postgres.
+type pgStore struct{ db *pgxpool.Pool }
+
+func (s pgStore) recent(ctx context.Context, webhook, kind string, keys []string, since time.Time) (map[string]bool, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT item_key FROM alert_deliveries
+		WHERE webhook = $1 AND kind = $2 AND item_key = ANY($3) AND sent_at >= $4
+	`, webhook, kind, keys, since)
+	if err != nil {
+		return nil, fmt.Errorf("read alert deliveries: %w", err)
+	}
+	defer rows.Close()
+	out := map[string]bool{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		out[key] = true
+	}
+	return out, rows.Err()
+}
+
+func (s pgStore) markSent(ctx context.Context, webhook, kind string, keys []string, at time.Time) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO alert_deliveries (webhook, kind, item_key, sent_at)
+		SELECT $1, $2, k, $4 FROM unnest($3::text[]) AS k
+		ON CONFLICT (webhook, kind, item_key) DO UPDATE SET sent_at = EXCLUDED.sent_at
+	`, webhook, kind, keys, at)
+	if err != nil {
+		return fmt.Errorf("record alert deliveries: %w", err)
+	}
+	return nil
+}
+
+func (s pgStore) prune(ctx context.Context, before time.Time) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM alert_deliveries WHERE sent_at < $1", before)
+	return err
+}
+
+func (s pgStore) enqueue(ctx context.Context, webhook, kind string, items []queued) error {
+	keys := make([]string, len(items))
+	docs := make([]string, len(items))
+	for i, it := range items {
+		keys[i], docs[i] = it.key, string(it.item)
+	}
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO alert_digest_queue (webhook, kind, item_key, item)
+		SELECT $1, $2, k, d::jsonb FROM unnest($3::text[], $4::text[]) AS t(k, d)
+		ON CONFLICT (webhook, kind, item_key) DO NOTHING
+	`, webhook, kind, keys, docs)
+	if err != nil {
+		return fmt.Errorf("queue digest items: %w", err)
+	}
+	return nil
+}
+
+func (s pgStore) pending(ctx context.Context, webhook, kind string) ([]queued, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT item_key, item, queued_at FROM alert_digest_queue
+		WHERE webhook = $1 AND kind = $2
+		ORDER BY id
+	`, webhook, kind)
+	if err != nil {
+		return nil, fmt.Errorf("read digest queue: %w", err)
+	}
+	defer rows.Close()
+	var out []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.key, &q.item, &q.queuedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+func (s pgStore) ack(ctx context.Context, webhook, kind string, keys []string) error {
+	_, err := s.db.Exec(ctx,
+		"DELETE FROM alert_digest_queue WHERE webhook = $1 AND kind = $2 AND item_key = ANY($3)",
+		webhook, kind, keys)
+	return err
+}
+
+// routed narrows items to those owned by a team routing to webhook, when
+// one does; other webhooks get every item.
+func routed[T any](m *owners.Map, webhook string, items []T, ownersOf func(T) []owners.Owner) []T {
+	if !m.Routed(webhook) {
+		return items
+	}
+	out := items[:0:0]
+	for _, it := range items {
+		if owners.For(ownersOf(it), webhook) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// deliver notifies webhook i of items: those sent to it within its
+// cooldown are dropped, and with a digest the rest are queued for
+// flushDigest instead of sent. A failure to read the delivery state
+// sends everything: a repeated alert beats a lost one.
+func deliver[T any](ctx context.Context, r *Runner, i int, kind string, items []T,
+	key func(T) string, send func(context.Context, []T) error) error {
+	wh, p := r.webhooks[i], r.policies[i]
+	if p.cooldown > 0 {
+		keys := make([]string, len(items))
+		for j, it := range items {
+			keys[j] = key(it)
+		}
+		recent, err := r.store.recent(ctx, wh.Name(), kind, keys, r.now().Add(-p.cooldown))
+		if err != nil {
+			slog.Warn("Alerting: cooldown state unavailable, not deduplicating", "webhook", wh.Name(), "error", err)
+		}
+		fresh := items[:0:0]
+		for _, it := range items {
+			if !recent[key(it)] {
+				fresh = append(fresh, it)
+			}
+		}
+		if skipped := len(items) - len(fresh); skipped > 0 {
+			slog.Info("Alerting: skipping notifications within cooldown", "webhook", wh.Name(), "kind", kind, "count", skipped)
+		}
+		items = fresh
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	if p.digest() {
+		q := make([]queued, len(items))
+		for j, it := range items {
+			doc, err := json.Marshal(it)
+			if err != nil {
+				return err
+			}
+			q[j] = queued{key: key(it), item: doc}
+		}
+		return r.store.enqueue(ctx, wh.Name(), kind, q)
+	}
+	return sendAndRecord(ctx, r, i, kind, items, key, send)
+}
+
+// flushDigest sends webhook i's queued items as one notification once
+// digest_size of them wait or the oldest waited digest_max_wait.
+func flushDigest[T any](ctx context.Context, r *Runner, i int, kind string,
+	key func(T) string, send func(context.Context, []T) error) error {
+	wh, p := r.webhooks[i], r.policies[i]
+	if !p.digest() {
+		return nil
+	}
+	waiting, err := r.store.pending(ctx, wh.Name(), kind)
+	if err != nil || len(waiting) == 0 {
+		return err
+	}
+	if len(waiting) < p.digestSize && r.now().Sub(waiting[0].queuedAt) < p.digestMaxWait {
+		return nil
+	}
+	items := make([]T, len(waiting))
+	for j, q := range waiting {
+		if err := json.Unmarshal(q.item, &items[j]); err != nil {
+			return fmt.Errorf("decode digest item %s: %w", q.key, err)
+		}
+	}
+	if err := sendAndRecord(ctx, r, i, kind, items, key, send); err != nil {
+		return err // the items stay queued for the next run
+	}
+	keys := make([]string, len(waiting))
+	for j, q := range waiting {
+		keys[j] = q.key
+	}
+	return r.store.ack(ctx, wh.Name(), kind, keys)
+}
+
+func sendAndRecord[T any](ctx context.Context, r *Runner, i int, kind string, items []T,
+	key func(T) string, send func(context.Context, []T) error) error {
+	wh, p := r.webhooks[i], r.policies[i]
+	if err := send(ctx, items); err != nil {
+		metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "error").Inc()
+		return err
+	}
+	metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "success").Inc()
+	slog.Info("Alerting: webhook delivered", "webhook", wh.Name(), "kind", kind, "count", len(items))
+	keys := make([]string, len(items))
+	for j, it := range items {
+		keys[j] = key(it)
+	}
+	if err := r.audit.Record(ctx, audit.Notify, wh.Name(), map[string]any{"kind": kind, "items": keys}); err != nil {
+		slog.Warn("Alerting: auditing notification failed", "webhook", wh.Name(), "error", err)
+	}
+	if p.cooldown <= 0 {
+		return nil
+	}
+	return r.store.markSent(ctx, wh.Name(), kind, keys, r.now())
+}
+
+func sleeperKey(s SleeperCVE) string { return s.CVEID }
+func kevKey(a KevAddition) string    { return a.CVEID }
+
+// flushDigests sends the digests that are due, every run, so a digest
+// goes out on time even when nothing new was detected.
+func (r *Runner) flushDigests(ctx context.Context) {
+	for i, wh := range r.webhooks {
+		if err := flushDigest(ctx, r, i, kindSleeper, sleeperKey, wh.Send); err != nil {
+			slog.Error("Alerting: sleeper digest delivery failed", "webhook", wh.Name(), "error", err)
+		}
+		if err := flushDigest(ctx, r, i, kindKev, kevKey, wh.SendKevAdditions); err != nil {
+			slog.Error("Alerting: KEV digest delivery failed", "webhook", wh.Name(), "error", err)
+		}
+	}
+	var longest time.Duration
+	for _, p := range r.policies {
+		longest = max(longest, p.cooldown)
+	}
+	if longest > 0 {
+		if err := r.store.prune(ctx, r.now().Add(-longest)); err != nil {
+			slog.Warn("Alerting: pruning delivery state failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/owners"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory deliveryStore.
+type memStore struct {
+	sent  map[string]time.Time // webhook/kind/key
+	queue map[string][]queued  // webhook/kind
+	now   func() time.Time
+}
+
+func newMemStore(now func() time.Time) *memStore {
+	return &memStore{sent: map[string]time.Time{}, queue: map[string][]queued{}, now: now}
+}
+
+func (m *memStore) recent(_ context.Context, webhook, kind string, keys []string, since time.Time) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, k := range keys {
+		if at, ok := m.sent[webhook+"/"+kind+"/"+k]; ok && !at.Before(since) {
+			out[k] = true
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) markSent(_ context.Context, webhook, kind string, keys []string, at time.Time) error {
+	for _, k := range keys {
+		m.sent[webhook+"/"+kind+"/"+k] = at
+	}
+	return nil
+}
+
+func (m *memStore) prune(_ context.Context, before time.Time) error {
+	for k, at := range m.sent {
+		if at.Before(before) {
+			delete(m.sent, k)
+		}
+	}
+	return nil
+}
+
+func (m *memStore) enqueue(_ context.Context, webhook, kind string, items []queued) error {
+	q := m.queue[webhook+"/"+kind]
+	for _, it := range items {
+		if !slices.ContainsFunc(q, func(o queued) bool { return o.key == it.key }) {
+			it.queuedAt = m.now()
+			q = append(q, it)
+		}
+	}
+	m.queue[webhook+"/"+kind] = q
+	return nil
+}
+
+func (m *memStore) pending(_ context.Context, webhook, kind string) ([]queued, error) {
+	return m.queue[webhook+"/"+kind], nil
+}
+
+func (m *memStore) ack(_ context.Context, webhook, kind string, keys []string) error {
+	m.queue[webhook+"/"+kind] = slices.DeleteFunc(m.queue[webhook+"/"+kind], func(q queued) bool {
+		return slices.Contains(keys, q.key)
+	})
+	return nil
+}
+
+// recordingHook records the CVE IDs of each generic sleeper payload, by
+// webhook name (the URL path).
+type recordingHook struct {
+	mu       sync.Mutex
+	messages map[string][][]string
+}
+
+func (h *recordingHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var p genericPayload
+	_ = json.NewDecoder(r.Body).Decode(&p)
+	var ids []string
+	for _, s := range p.Sleepers {
+		ids = append(ids, s.CVEID)
+	}
+	h.mu.Lock()
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	h.messages[name] = append(h.messages[name], ids)
+	h.mu.Unlock()
+}
+
+func testRunner(t *testing.T, cfg config.AlertingConfig) (*Runner, *recordingHook, *time.Time) {
+	t.Helper()
+	hook := &recordingHook{messages: map[string][][]string{}}
+	srv := httptest.NewServer(hook)
+	t.Cleanup(srv.Close)
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].URL = srv.URL + "/" + cfg.Webhooks[i].Name
+	}
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	r := NewRunner(nil, cfg)
+	r.now = func() time.Time { return now }
+	r.store = newMemStore(r.now)
+	return r, hook, &now
+}
+
+func sleepersOf(ids ...string) []SleeperCVE {
+	out := make([]SleeperCVE, len(ids))
+	for i, id := range ids {
+		out[i] = SleeperCVE{CVEID: id, EpssNow: 0.6}
+	}
+	return out
+}
+
+func TestDeliver_Cooldown(t *testing.T) {
+	r, hook, now := testRunner(t, config.AlertingConfig{
+		Cooldown: "72h",
+		Webhooks: []config.WebhookConfig{{Name: "soc", Type: "generic"}},
+	})
+	ctx := context.Background()
+	send := r.webhooks[0].Send
+
+	require.NoError(t, deliver(ctx, r, 0, kindSleeper, sleepersOf("CVE-1", "CVE-2"), sleeperKey, send))
+	*now = now.Add(24 * time.Hour)
+	require.NoError(t, deliver(ctx, r, 0, kindSleeper, sleepersOf("CVE-2", "CVE-3"), sleeperKey, send))
+	require.NoError(t, deliver(ctx, r, 0, kindSleeper, sleepersOf("CVE-2"), sleeperKey, send))
+	*now = now.Add(72 * time.Hour)
+	require.NoError(t, deliver(ctx, r, 0, kindSleeper, sleepersOf("CVE-1", "CVE-2"), sleeperKey, send))
+
+	assert.Equal(t, [][]string{{"CVE-1", "CVE-2"}, {"CVE-3"}, {"CVE-1", "CVE-2"}}, hook.messages["soc"])
+}
+
+func TestDeliver_Digest(t *testing.T) {
+	r, hook, now := testRunner(t, config.AlertingConfig{
+		DigestSize: 3,
+		Webhooks: []config.WebhookConfig{
+			{Name: "digest", Type: "generic", DigestMaxWait: "12h"},
+			{Name: "direct", Type: "generic", DigestSize: 1},
+		},
+	})
+	ctx := context.Background()
+
+	run := func(ids ...string) {
+		for i, wh := range r.webhooks {
+			require.NoError(t, deliver(ctx, r, i, kindSleeper, sleepersOf(ids...), sleeperKey, wh.Send))
+		}
+		r.flushDigests(ctx)
+	}
+	run("CVE-1")
+	run("CVE-1", "CVE-2")
+	assert.Empty(t, hook.messages["digest"])
+	assert.Equal(t, [][]string{{"CVE-1"}, {"CVE-1", "CVE-2"}}, hook.messages["direct"], "digest_size 1 sends every run")
+
+	run("CVE-3")
+	assert.Equal(t, [][]string{{"CVE-1", "CVE-2", "CVE-3"}}, hook.messages["digest"], "digest full")
+
+	run("CVE-4")
+	assert.Len(t, hook.messages["digest"], 1)
+	*now = now.Add(13 * time.Hour)
+	r.flushDigests(ctx)
+	assert.Equal(t, [][]string{{"CVE-1", "CVE-2", "CVE-3"}, {"CVE-4"}}, hook.messages["digest"], "digest_max_wait sends a smaller digest")
+}
+
+func TestDeliver_DigestKeptOnFailure(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	r := NewRunner(nil, config.AlertingConfig{DigestSize: 1, Webhooks: []config.WebhookConfig{{Name: "x", URL: srv.URL}}})
+	store := newMemStore(time.Now)
+	r.store = store
+	ctx := context.Background()
+
+	fail.Store(true)
+	require.NoError(t, deliver(ctx, r, 0, kindKev, []KevAddition{{CVEID: "CVE-1"}}, kevKey, r.webhooks[0].SendKevAdditions))
+	assert.Error(t, flushDigest(ctx, r, 0, kindKev, kevKey, r.webhooks[0].SendKevAdditions))
+	assert.Len(t, store.queue["x/"+kindKev], 1)
+
+	fail.Store(false)
+	assert.NoError(t, flushDigest(ctx, r, 0, kindKev, kevKey, r.webhooks[0].SendKevAdditions))
+	assert.Empty(t, store.queue["x/"+kindKev])
+}
+
+func TestNotifyKevAdditions(t *testing.T) {
+	var fail atomic.Bool
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer flaky.Close()
+	r := NewRunner(nil, config.AlertingConfig{Webhooks: []config.WebhookConfig{
+		{Name: "down", URL: down.URL},
+		{Name: "flaky", URL: flaky.URL},
+	}})
+	r.store = newMemStore(time.Now)
+	ctx := context.Background()
+	additions := []KevAddition{{CVEID: "CVE-1"}}
+
+	fail.Store(true)
+	assert.False(t, r.notifyKevAdditions(ctx, additions), "every delivery failed")
+	fail.Store(false)
+	assert.True(t, r.notifyKevAdditions(ctx, additions), "one webhook took them")
+	assert.True(t, NewRunner(nil, config.AlertingConfig{}).notifyKevAdditions(ctx, additions))
+}
+
+func TestDeliveryPolicy(t *testing.T) {
+	cfg := config.AlertingConfig{Cooldown: "24h", DigestSize: 10}
+	p, err := resolvePolicy(cfg, config.WebhookConfig{Cooldown: "0s"})
+	require.NoError(t, err)
+	assert.Equal(t, deliveryPolicy{digestSize: 10, digestMaxWait: defaultDigestMaxWait}, p)
+
+	for _, wh := range []config.WebhookConfig{{Cooldown: "soon"}, {DigestSize: -1}, {DigestMaxWait: "0s"}} {
+		assert.Error(t, DeliveryPolicy(cfg, wh), "%+v", wh)
+	}
+}
+
+func TestRouted(t *testing.T) {
+	m, err := owners.New([]config.OwnerConfig{
+		{Team: "network", Products: []string{"cisco:*"}, Webhooks: []string{"network"}},
+	})
+	require.NoError(t, err)
+	additions := []KevAddition{
+		{CVEID: "CVE-1", Owners: m.Match(owners.Affected{Vendor: "Cisco", Product: "IOS XE"})},
+		{CVEID: "CVE-2", Owners: m.Match(owners.Affected{Vendor: "Microsoft", Product: "Exchange"})},
+	}
+	ownersOf := func(a KevAddition) []owners.Owner { return a.Owners }
+
+	assert.Equal(t, additions[:1], routed(m, "network", additions, ownersOf), "a routed webhook gets its team's items")
+	assert.Equal(t, additions, routed(m, "soc", additions, ownersOf), "other webhooks get everything")
+	assert.Equal(t, additions, routed(nil, "network", additions, ownersOf))
+}
+
+func TestDeliver_Audited(t *testing.T) {
+	r, _, _ := testRunner(t, config.AlertingConfig{
+		Webhooks: []config.WebhookConfig{{Name: "soc", Type: "generic"}},
+	})
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	r.SetAudit(audit.New(nil, path, "tigerfetch@test"))
+	require.NoError(t, deliver(context.Background(), r, 0, kindSleeper, sleepersOf("CVE-1", "CVE-2"), sleeperKey, r.webhooks[0].Send))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var ev audit.Event
+	require.NoError(t, json.Unmarshal(b, &ev))
+	assert.Equal(t, audit.Notify, ev.Action)
+	assert.Equal(t, "soc", ev.Target)
+	assert.Equal(t, "tigerfetch@test", ev.Actor)
+	assert.Equal(t, map[string]any{"kind": kindSleeper, "items": []any{"CVE-1", "CVE-2"}}, ev.Detail)
+}
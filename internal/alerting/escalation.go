@@ -0,0 +1,235 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/owners"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CriticalAlert is a CVE added to CISA KEV that affects a monitored product
+// ([[nvd.products]]): it must be acknowledged (`tigerfetch ack`) within
+// [alerting.escalation] after, or it is escalated.
+type CriticalAlert struct {
+	CVEID             string     `json:"cve_id"`
+	Products          []string   `json:"products"` // the monitored products it affects
+	VendorProject     string     `json:"vendor_project"`
+	Product           string     `json:"product"`
+	VulnerabilityName string     `json:"vulnerability_name"`
+	DueDate           string     `json:"due_date"`
+	Ransomware        bool       `json:"known_ransomware_use"`
+	DetectedAt        time.Time  `json:"detected_at"`
+	EscalatedAt       *time.Time `json:"escalated_at,omitempty"`
+
+	Owners []owners.Owner `json:"owners,omitempty"` // the teams owning the product ([[owners]])
+}
+
+// EscalationPolicy validates [alerting.escalation]: its delay, and the
+// escalation webhooks' delivery settings.
+func EscalationPolicy(cfg config.EscalationConfig) error {
+	if after, err := cfg.GetAfter(); err != nil || after <= 0 {
+		return fmt.Errorf("invalid after %q", cfg.After)
+	}
+	for _, wh := range cfg.Webhooks {
+		pagerDuty := strings.EqualFold(wh.Type, "pagerduty")
+		if pagerDuty && wh.RoutingKey == "" {
+			return fmt.Errorf("pagerduty webhook %q needs a routing_key", wh.Name)
+		}
+		if !pagerDuty && wh.URL == "" {
+			return fmt.Errorf("webhook %q needs a url", wh.Name)
+		}
+	}
+	return nil
+}
+
+// runEscalations records the critical alerts among recent KEV additions,
+// then escalates each one left unacknowledged for escalateAfter, once. An
+// alert counts as escalated when any escalation webhook it was routed to
+// took it; one no webhook took is retried next run.
+func (r *Runner) runEscalations(ctx context.Context) error {
+	lookback := r.cfg.LookbackDays
+	if lookback <= 0 {
+		lookback = 7
+	}
+	if err := r.trackCriticalAlerts(ctx, lookback); err != nil {
+		return err
+	}
+	if len(r.escalations) == 0 {
+		return nil
+	}
+
+	due, err := openAlerts(ctx, r.db, r.now().Add(-r.escalateAfter), false)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	if err := r.assignAlertOwners(ctx, due); err != nil {
+		slog.Warn("Alerting: matching escalation owners failed", "error", err)
+	}
+
+	slog.Info("Alerting: escalating unacknowledged critical alerts", "count", len(due))
+	escalated := map[string]bool{}
+	var errs []error
+	for _, wh := range r.escalations {
+		alerts := routed(r.owners, wh.Name(), due, func(a CriticalAlert) []owners.Owner { return a.Owners })
+		if len(alerts) == 0 {
+			continue
+		}
+		if err := wh.SendEscalations(ctx, alerts, r.now()); err != nil {
+			metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "error").Inc()
+			errs = append(errs, fmt.Errorf("escalation webhook %s: %w", wh.Name(), err))
+			continue
+		}
+		metrics.AlertingWebhooksSent.WithLabelValues(wh.Name(), "success").Inc()
+		ids := make([]string, len(alerts))
+		for j, a := range alerts {
+			escalated[a.CVEID] = true
+			ids[j] = a.CVEID
+		}
+		if err := r.audit.Record(ctx, audit.Escalate, wh.Name(), map[string]any{"items": ids}); err != nil {
+			slog.Warn("Alerting: auditing escalation failed", "webhook", wh.Name(), "error", err)
+		}
+	}
+	if len(escalated) > 0 {
+		ids := slices.Collect(maps.Keys(escalated))
+		if _, err := r.db.Exec(ctx,
+			"UPDATE critical_alerts SET escalated_at = $2 WHERE cve_id = ANY($1)", ids, r.now(),
+		); err != nil {
+			errs = append(errs, fmt.Errorf("record escalations: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// assignAlertOwners matches alerts to owners by KEV product, NVD CPEs and
+// the monitors that matched them.
+func (r *Runner) assignAlertOwners(ctx context.Context, alerts []CriticalAlert) error {
+	if r.owners == nil {
+		return nil
+	}
+	ids := make([]string, len(alerts))
+	for i, a := range alerts {
+		ids[i] = a.CVEID
+	}
+	cpes, err := owners.CPEs(ctx, r.read, ids)
+	if err != nil {
+		return err
+	}
+	for i, a := range alerts {
+		alerts[i].Owners = r.owners.Match(owners.Affected{Vendor: a.VendorProject, Product: a.Product,
+			CPEs: cpes[a.CVEID], Monitors: a.Products})
+	}
+	return nil
+}
+
+// trackCriticalAlerts adds the KEV additions of the last lookbackDays that
+// affect a monitored product to critical_alerts. An alert is detected when
+// both the KEV addition and the product match are known, whichever came
+// later.
+func (r *Runner) trackCriticalAlerts(ctx context.Context, lookbackDays int) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO critical_alerts (cve_id, products, detected_at)
+		SELECT k.cve_id, array_agg(DISTINCT p.product ORDER BY p.product),
+		       min(GREATEST(k.detected_at, p.first_matched_at))
+		FROM kev_changes k
+		JOIN product_cves p ON p.cve_id = k.cve_id
+		WHERE k.change_type = 'added' AND NOT k.archived
+		  AND k.detected_at >= now() - make_interval(days => $1)
+		GROUP BY k.cve_id
+		ON CONFLICT (cve_id) DO UPDATE SET products = EXCLUDED.products
+			WHERE critical_alerts.acked_at IS NULL
+	`, lookbackDays)
+	if err != nil {
+		return fmt.Errorf("track critical alerts: %w", err)
+	}
+	return nil
+}
+
+// OpenAlerts lists the critical alerts nobody acknowledged yet, oldest
+// first, escalated or not.
+func OpenAlerts(ctx context.Context, db *pgxpool.Pool) ([]CriticalAlert, error) {
+	return openAlerts(ctx, db, time.Now(), true)
+}
+
+// openAlerts lists the unacknowledged alerts detected before before,
+// including the escalated ones when escalated is set.
+func openAlerts(ctx context.Context, db *pgxpool.Pool, before time.Time, escalated bool) ([]CriticalAlert, error) {
+	rows, err := db.Query(ctx, `
+		SELECT a.cve_id, a.products, a.detected_at, a.escalated_at,
+		       COALESCE(k.vendor_project, ''), COALESCE(k.product, ''), COALESCE(k.vulnerability_name, ''),
+		       COALESCE(k.json->>'dueDate', ''),
+		       COALESCE(k.json->>'knownRansomwareCampaignUse', '') = 'Known'
+		FROM critical_alerts a
+		LEFT JOIN LATERAL (
+			SELECT vendor_project, product, vulnerability_name, json FROM kev_changes
+			WHERE cve_id = a.cve_id AND change_type = 'added'
+			ORDER BY id DESC LIMIT 1
+		) k ON true
+		WHERE a.acked_at IS NULL AND a.detected_at <= $1
+		  AND ($2 OR a.escalated_at IS NULL)
+		ORDER BY a.detected_at, a.cve_id
+	`, before, escalated)
+	if err != nil {
+		return nil, fmt.Errorf("read critical alerts: %w", err)
+	}
+	defer rows.Close()
+	var out []CriticalAlert
+	for rows.Next() {
+		var a CriticalAlert
+		if err := rows.Scan(&a.CVEID, &a.Products, &a.DetectedAt, &a.EscalatedAt,
+			&a.VendorProject, &a.Product, &a.VulnerabilityName, &a.DueDate, &a.Ransomware); err != nil {
+			return nil, fmt.Errorf("scan critical alert: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Acknowledge marks the critical alerts of ids as handled by by, which
+// stops their escalation, and returns the IDs that were open.
+func Acknowledge(ctx context.Context, db *pgxpool.Pool, ids []string, by string) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		UPDATE critical_alerts SET acked_at = now(), acked_by = $2
+		WHERE cve_id = ANY($1) AND acked_at IS NULL
+		RETURNING cve_id
+	`, ids, by)
+	if err != nil {
+		return nil, fmt.Errorf("acknowledge critical alerts: %w", err)
+	}
+	defer rows.Close()
+	var acked []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		acked = append(acked, id)
+	}
+	return acked, rows.Err()
+}
+
+// unackedFor formats how long an alert has waited, in hours and minutes.
+func unackedFor(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh%dm", h, m)
+}
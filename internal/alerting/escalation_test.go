@@ -0,0 +1,88 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/i18n"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAlert(id string, detected time.Time) CriticalAlert {
+	return CriticalAlert{CVEID: id, Products: []string{"citrix-adc", "netscaler"}, VendorProject: "Citrix",
+		Product: "NetScaler ADC", VulnerabilityName: "Citrix NetScaler Buffer Overflow", DueDate: "2026-03-20",
+		DetectedAt: detected}
+}
+
+func TestBuildSlackEscalationPayload(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	body, err := buildSlackEscalationPayload(i18n.English(), []CriticalAlert{testAlert("CVE-2026-1234", now.Add(-5*time.Hour-30*time.Minute))}, now)
+	require.NoError(t, err)
+	s := string(body)
+	assert.Contains(t, s, "Escalation — 1 critical alert not acknowledged")
+	assert.Contains(t, s, "citrix-adc, netscaler")
+	assert.Contains(t, s, "Not acknowledged for 5h30m")
+	assert.Contains(t, s, "tigerfetch ack CVE-2026-1234")
+}
+
+func TestSendEscalations_PagerDuty(t *testing.T) {
+	var mu sync.Mutex
+	var events []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var ev map[string]any
+		_ = json.Unmarshal(body, &ev)
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	wh := NewWebhookSender(config.WebhookConfig{Name: "oncall", Type: "pagerduty", URL: srv.URL, RoutingKey: "R0UT1NG"})
+	alerts := []CriticalAlert{testAlert("CVE-1", now.Add(-4*time.Hour)), testAlert("CVE-2", now.Add(-6*time.Hour))}
+	require.NoError(t, wh.SendEscalations(context.Background(), alerts, now))
+
+	require.Len(t, events, 2, "one event per alert")
+	assert.Equal(t, "R0UT1NG", events[0]["routing_key"])
+	assert.Equal(t, "trigger", events[0]["event_action"])
+	assert.Equal(t, "tigerfetch-escalation-CVE-1", events[0]["dedup_key"])
+	payload := events[1]["payload"].(map[string]any)
+	assert.Equal(t, "critical", payload["severity"])
+	assert.Equal(t, "CVE-2 in CISA KEV affects citrix-adc, netscaler, unacknowledged for 6h: Citrix NetScaler Buffer Overflow", payload["summary"])
+}
+
+func TestNewWebhookSender_PagerDutyURL(t *testing.T) {
+	wh := NewWebhookSender(config.WebhookConfig{Type: "pagerduty", RoutingKey: "k"})
+	assert.Equal(t, pagerDutyEventsURL, wh.cfg.URL)
+}
+
+func TestEscalationPolicy(t *testing.T) {
+	assert.NoError(t, EscalationPolicy(config.EscalationConfig{Enabled: true,
+		Webhooks: []config.WebhookConfig{{Name: "pd", Type: "pagerduty", RoutingKey: "k"}, {Name: "mgr", Type: "slack", URL: "https://hooks.slack.com/x"}}}))
+
+	for _, cfg := range []config.EscalationConfig{
+		{After: "soon"},
+		{After: "0s"},
+		{Webhooks: []config.WebhookConfig{{Name: "pd", Type: "pagerduty"}}},
+		{Webhooks: []config.WebhookConfig{{Name: "mgr", Type: "slack"}}},
+	} {
+		assert.Error(t, EscalationPolicy(cfg), "%+v", cfg)
+	}
+}
+
+func TestUnackedFor(t *testing.T) {
+	assert.Equal(t, "45m", unackedFor(45*time.Minute))
+	assert.Equal(t, "4h", unackedFor(4*time.Hour+20*time.Second))
+	assert.Equal(t, "26h5m", unackedFor(26*time.Hour+5*time.Minute))
+}
@@ -0,0 +1,104 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/i18n"
+)
+
+// TemplateData is what a webhook's payload template renders: one
+// notification, with every field of its items.
+type TemplateData struct {
+	Event     string          // "sleeper", "kev_added" or "escalation"
+	Webhook   string          // the webhook's name
+	Timestamp time.Time       // UTC
+	Count     int             // the number of items
+	Sleepers  []SleeperCVE    // event "sleeper"
+	Additions []KevAddition   // event "kev_added"
+	Alerts    []CriticalAlert // event "escalation"; one per event for PagerDuty
+	Vars      map[string]string
+	ChartURL  string // [alerting] chart_url, e.g. for {{.ChartURL}}/charts/advisories?format=png
+}
+
+// CheckTemplate parses wh's payload template, if it has one.
+func CheckTemplate(wh config.WebhookConfig) error {
+	_, err := parseTemplate(wh, i18n.English())
+	return err
+}
+
+// parseTemplate returns wh's payload template, nil when it has none. Its
+// "t" function translates into loc.
+func parseTemplate(wh config.WebhookConfig, loc *i18n.Localizer) (*template.Template, error) {
+	text := wh.Template
+	switch {
+	case wh.Template != "" && wh.TemplateFile != "":
+		return nil, errors.New("set template or template_file, not both")
+	case wh.TemplateFile != "":
+		b, err := os.ReadFile(wh.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read template_file: %w", err)
+		}
+		text = string(b)
+	case text == "":
+		return nil, nil
+	}
+	tmpl, err := template.New(wh.Name).Option("missingkey=zero").Funcs(templateFuncs(loc)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func templateFuncs(loc *i18n.Localizer) template.FuncMap {
+	return template.FuncMap{
+		// json encodes a value, e.g. a description as a JSON string
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"join":  func(items []string, sep string) string { return strings.Join(items, sep) },
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"default": func(def, s string) string {
+			if s == "" {
+				return def
+			}
+			return s
+		},
+		"nvd": func(cve string) string { return "https://nvd.nist.gov/vuln/detail/" + cve },
+		// pct formats an EPSS probability or percentile as a percentage
+		"pct": func(p float64) string { return fmt.Sprintf("%.1f%%", p*100) },
+		"t":   func(id string) string { return loc.T(id, nil) },
+	}
+}
+
+// render executes the webhook's template for data. A JSON payload that is
+// not valid JSON is an error, so a template mistake fails the delivery
+// instead of reaching the endpoint.
+func (w WebhookSender) render(data TemplateData) ([]byte, error) {
+	data.Webhook, data.Vars, data.ChartURL = w.cfg.Name, w.cfg.Vars, strings.TrimSuffix(w.charts, "/")
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	body := buf.Bytes()
+	if len(bytes.TrimSpace(body)) > 0 && strings.HasSuffix(w.contentType(), "json") && !json.Valid(body) {
+		return nil, errors.New("template rendered invalid JSON")
+	}
+	return body, nil
+}
+
+func (w WebhookSender) contentType() string {
+	if w.tmpl != nil && w.cfg.ContentType != "" {
+		return w.cfg.ContentType
+	}
+	return "application/json"
+}
@@ -0,0 +1,90 @@
+package alerting
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture records the payloads and content types posted to it.
+type capture struct {
+	bodies []string
+	types  []string
+}
+
+func (c *capture) server(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.bodies = append(c.bodies, string(b))
+		c.types = append(c.types, r.Header.Get("Content-Type"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestTemplate_Sleepers(t *testing.T) {
+	var c capture
+	score := 9.8
+	wh := NewWebhookSender(config.WebhookConfig{
+		Name: "soc", Type: "slack", URL: c.server(t),
+		Template: `{"text": {{json (printf "%d sleeper(s) – runbook %s" .Count .Vars.runbook)}}, "cves": [` +
+			`{{range $i, $s := .Sleepers}}{{if $i}},{{end}}{"id": "{{$s.CVEID}}", "link": "{{nvd $s.CVEID}}", ` +
+			`"epss": "{{pct $s.EpssNow}}", "cvss": {{with $s.CvssScore}}{{.}}{{else}}null{{end}}, "desc": {{json $s.Description}}}{{end}}]}`,
+		Vars: map[string]string{"runbook": "https://wiki.example.com/runbooks/sleeper"},
+	})
+	require.NoError(t, wh.Send(context.Background(), []SleeperCVE{
+		{CVEID: "CVE-2026-1", EpssNow: 0.612, CvssScore: &score, Description: `Overflow in "parse"`},
+		{CVEID: "CVE-2026-2", EpssNow: 0.5},
+	}))
+	require.Len(t, c.bodies, 1)
+	assert.JSONEq(t, `{"text": "2 sleeper(s) – runbook https://wiki.example.com/runbooks/sleeper", "cves": [
+		{"id": "CVE-2026-1", "link": "https://nvd.nist.gov/vuln/detail/CVE-2026-1", "epss": "61.2%", "cvss": 9.8, "desc": "Overflow in \"parse\""},
+		{"id": "CVE-2026-2", "link": "https://nvd.nist.gov/vuln/detail/CVE-2026-2", "epss": "50.0%", "cvss": null, "desc": ""}]}`, c.bodies[0])
+	assert.Equal(t, "application/json", c.types[0])
+}
+
+func TestTemplate_SkipsEmptyAndSetsContentType(t *testing.T) {
+	var c capture
+	wh := NewWebhookSender(config.WebhookConfig{
+		Name: "mail-relay", URL: c.server(t), ContentType: "text/plain",
+		Template: `{{if eq .Event "kev_added"}}{{range .Additions}}{{.CVEID}} {{upper .Product}} due {{default "n/a" .DueDate}}
+{{end}}{{end}}`,
+	})
+	ctx := context.Background()
+	require.NoError(t, wh.Send(ctx, []SleeperCVE{{CVEID: "CVE-1"}}))
+	require.NoError(t, wh.SendKevAdditions(ctx, []KevAddition{{CVEID: "CVE-2", Product: "ios xe"}}))
+	assert.Equal(t, []string{"CVE-2 IOS XE due n/a\n"}, c.bodies, "the sleeper notification renders nothing")
+	assert.Equal(t, []string{"text/plain"}, c.types)
+}
+
+func TestTemplate_InvalidJSON(t *testing.T) {
+	var c capture
+	wh := NewWebhookSender(config.WebhookConfig{Name: "x", URL: c.server(t), Template: `{"text": "{{.Count}}"`})
+	assert.ErrorContains(t, wh.Send(context.Background(), []SleeperCVE{{CVEID: "CVE-1"}}), "invalid JSON")
+	assert.Empty(t, c.bodies)
+}
+
+func TestCheckTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "payload.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte(`{"count": {{.Count}}}`), 0o600))
+
+	assert.NoError(t, CheckTemplate(config.WebhookConfig{}))
+	assert.NoError(t, CheckTemplate(config.WebhookConfig{TemplateFile: file}))
+	for _, wh := range []config.WebhookConfig{
+		{Template: `{{.Count`},
+		{Template: `{{nope .Count}}`},
+		{TemplateFile: filepath.Join(t.TempDir(), "missing.tmpl")},
+		{Template: `{}`, TemplateFile: file},
+	} {
+		assert.Error(t, CheckTemplate(wh), "%+v", wh)
+	}
+}
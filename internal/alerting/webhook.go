@@ -5,23 +5,51 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"text/template"
 	"time"
 
 	"tiger2go/internal/config"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/owners"
 )
 
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint, the default
+// url of a "pagerduty" webhook.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
 // WebhookSender sends alert payloads to configured endpoints.
 type WebhookSender struct {
 	cfg    config.WebhookConfig
+	loc    *i18n.Localizer    // language of Slack messages
+	tmpl   *template.Template // replaces the built-in payloads; nil for none
+	charts string             // [alerting] chart_url, for templates
 	client *http.Client
 }
 
-// NewWebhookSender creates a sender for a webhook config.
+// NewWebhookSender creates a sender for a webhook config. An unknown
+// language falls back to English, an invalid template (see CheckTemplate)
+// to the built-in payloads.
 func NewWebhookSender(cfg config.WebhookConfig) WebhookSender {
+	loc, err := i18n.New(cfg.Language)
+	if err != nil {
+		slog.Warn("Invalid webhook language, using English", "webhook", cfg.Name, "error", err)
+		loc = i18n.English()
+	}
+	tmpl, err := parseTemplate(cfg, loc)
+	if err != nil {
+		slog.Warn("Invalid webhook template, using the built-in payload", "webhook", cfg.Name, "error", err)
+	}
+	if strings.EqualFold(cfg.Type, "pagerduty") && cfg.URL == "" {
+		cfg.URL = pagerDutyEventsURL
+	}
 	return WebhookSender{
-		cfg: cfg,
+		cfg:  cfg,
+		loc:  loc,
+		tmpl: tmpl,
 		client: &http.Client{
 			Timeout: 15 * time.Second,
 		},
@@ -33,24 +61,81 @@ func (w WebhookSender) Name() string { return w.cfg.Name }
 
 // Send dispatches sleeper CVE alerts to the webhook endpoint.
 func (w WebhookSender) Send(ctx context.Context, sleepers []SleeperCVE) error {
-	var body []byte
-	var err error
+	return w.send(ctx, TemplateData{Event: kindSleeper, Count: len(sleepers), Sleepers: sleepers}, func() ([]byte, error) {
+		if strings.EqualFold(w.cfg.Type, "slack") {
+			return buildSlackPayload(w.loc, sleepers)
+		}
+		return buildGenericPayload(sleepers)
+	})
+}
+
+// SendKevAdditions dispatches "newly added to KEV" alerts to the webhook endpoint.
+func (w WebhookSender) SendKevAdditions(ctx context.Context, additions []KevAddition) error {
+	return w.send(ctx, TemplateData{Event: kindKev, Count: len(additions), Additions: additions}, func() ([]byte, error) {
+		if strings.EqualFold(w.cfg.Type, "slack") {
+			return buildSlackKevPayload(w.loc, additions)
+		}
+		return buildGenericKevPayload(additions)
+	})
+}
 
+// SendEscalations dispatches unacknowledged critical alerts, now being
+// when they are escalated. PagerDuty gets one event per alert.
+func (w WebhookSender) SendEscalations(ctx context.Context, alerts []CriticalAlert, now time.Time) error {
 	switch strings.ToLower(w.cfg.Type) {
+	case "pagerduty":
+		for _, a := range alerts {
+			data := TemplateData{Event: kindEscalation, Timestamp: now, Count: 1, Alerts: []CriticalAlert{a}}
+			err := w.send(ctx, data, func() ([]byte, error) {
+				return buildPagerDutyEvent(w.cfg.RoutingKey, a, now)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	case "slack":
-		body, err = buildSlackPayload(sleepers)
+		return w.send(ctx, TemplateData{Event: kindEscalation, Timestamp: now, Count: len(alerts), Alerts: alerts}, func() ([]byte, error) {
+			return buildSlackEscalationPayload(w.loc, alerts, now)
+		})
 	default:
-		body, err = buildGenericPayload(sleepers)
+		return w.send(ctx, TemplateData{Event: kindEscalation, Timestamp: now, Count: len(alerts), Alerts: alerts}, func() ([]byte, error) {
+			return buildGenericEscalationPayload(alerts, now)
+		})
+	}
+}
+
+// send posts the webhook's template rendered for data, or else the
+// built-in payload. A template that renders only whitespace skips the
+// notification.
+func (w WebhookSender) send(ctx context.Context, data TemplateData, builtin func() ([]byte, error)) error {
+	var body []byte
+	var err error
+	if w.tmpl != nil {
+		if data.Timestamp.IsZero() {
+			data.Timestamp = time.Now()
+		}
+		data.Timestamp = data.Timestamp.UTC()
+		body, err = w.render(data)
+	} else {
+		body, err = builtin()
 	}
 	if err != nil {
 		return fmt.Errorf("build payload: %w", err)
 	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		slog.Debug("Alerting: template rendered nothing, not sending", "webhook", w.cfg.Name, "event", data.Event)
+		return nil
+	}
+	return w.post(ctx, body)
+}
 
+func (w WebhookSender) post(ctx context.Context, body []byte) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", w.contentType())
 
 	resp, err := w.client.Do(req)
 	if err != nil {
@@ -87,6 +172,33 @@ func formatCvssBadge(score *float64, severity string) string {
 	return fmt.Sprintf("%s CVSS *%.1f*", emoji, *score)
 }
 
+// formatOwners is the owner line of a Slack item, empty without owners.
+func formatOwners(loc *i18n.Localizer, list []owners.Owner) string {
+	if len(list) == 0 {
+		return ""
+	}
+	teams := make([]string, len(list))
+	for i, o := range list {
+		teams[i] = o.Team
+		if o.JiraProject != "" {
+			teams[i] += " (" + o.JiraProject + ")"
+		}
+	}
+	return fmt.Sprintf("\n%s: *%s*", loc.T("slack.owner", nil), strings.Join(teams, ", "))
+}
+
+// withChart shows the image at chartURL, if any, beside a section's text.
+func withChart(loc *i18n.Localizer, section map[string]interface{}, chartURL string) map[string]interface{} {
+	if chartURL != "" {
+		section["accessory"] = map[string]string{
+			"type":      "image",
+			"image_url": chartURL,
+			"alt_text":  loc.T("slack.epss_chart", nil),
+		}
+	}
+	return section
+}
+
 func formatCWE(cwe string) string {
 	if cwe == "" || cwe == "NVD-CWE-noinfo" || cwe == "NVD-CWE-Other" {
 		return ""
@@ -94,13 +206,13 @@ func formatCWE(cwe string) string {
 	return fmt.Sprintf(" | %s", cwe)
 }
 
-func buildSlackPayload(sleepers []SleeperCVE) ([]byte, error) {
+func buildSlackPayload(loc *i18n.Localizer, sleepers []SleeperCVE) ([]byte, error) {
 	blocks := []map[string]interface{}{
 		{
 			"type": "header",
 			"text": map[string]string{
 				"type": "plain_text",
-				"text": fmt.Sprintf("Sleeper CVE Alert — %d CVEs crossed 50%% EPSS", len(sleepers)),
+				"text": loc.N("slack.sleeper.header", len(sleepers), nil),
 			},
 		},
 		{
@@ -108,11 +220,11 @@ func buildSlackPayload(sleepers []SleeperCVE) ([]byte, error) {
 			"elements": []map[string]string{
 				{
 					"type": "mrkdwn",
-					"text": fmt.Sprintf(
-						"Baseline *%s* vs *%s* (%d-day lookback) | tigerfetch",
-						sleepers[0].DateBefore, sleepers[0].DateNow,
-						daysBetween(sleepers[0].DateBefore, sleepers[0].DateNow),
-					),
+					"text": loc.T("slack.sleeper.baseline", map[string]any{
+						"Before": sleepers[0].DateBefore,
+						"Now":    sleepers[0].DateNow,
+						"Days":   daysBetween(sleepers[0].DateBefore, sleepers[0].DateNow),
+					}),
 				},
 			},
 		},
@@ -133,18 +245,31 @@ func buildSlackPayload(sleepers []SleeperCVE) ([]byte, error) {
 
 		nvdLink := fmt.Sprintf("https://nvd.nist.gov/vuln/detail/%s", s.CVEID)
 
-		// Line 1: CVE ID (linked) + CVSS badge + CWE
+		// Line 1: CVE ID (linked) + CVSS badge + CWE + KEV flag
 		line1 := fmt.Sprintf("*<%s|%s>*  %s%s",
 			nvdLink, s.CVEID,
 			formatCvssBadge(s.CvssScore, s.CvssSeverity),
 			formatCWE(s.CWE),
 		)
+		if s.InKEV {
+			line1 += fmt.Sprintf("  :rotating_light: *%s*", loc.T("slack.in_kev", nil))
+		}
+		if s.Ransomware {
+			line1 += fmt.Sprintf("  :skull: *%s*", loc.T("slack.ransomware", nil))
+		}
+		if s.ExploitRef {
+			line1 += fmt.Sprintf("  :boom: *%s*", loc.T("slack.exploit", nil))
+		}
 
 		// Line 2: EPSS trajectory
 		line2 := fmt.Sprintf(
-			"EPSS: %.2f%% :arrow_right: *%.2f%%*  (+%.0f%%)  |  Percentile: *%.0f*",
-			s.EpssBefore*100, s.EpssNow*100, s.PctChange, s.Percentile*100,
+			"EPSS: %.2f%% :arrow_right: *%.2f%%*  (+%.0f%%)  |  %s: *%.0f*",
+			s.EpssBefore*100, s.EpssNow*100, s.PctChange, loc.T("slack.percentile", nil), s.Percentile*100,
 		)
+		if s.PatchURL != "" {
+			line2 += fmt.Sprintf("  |  <%s|%s>", s.PatchURL, loc.T("slack.patch", nil))
+		}
+		line2 += formatOwners(loc, s.Owners)
 
 		// Line 3: Description
 		line3 := ""
@@ -152,13 +277,13 @@ func buildSlackPayload(sleepers []SleeperCVE) ([]byte, error) {
 			line3 = fmt.Sprintf("\n>%s", desc)
 		}
 
-		blocks = append(blocks, map[string]interface{}{
+		blocks = append(blocks, withChart(loc, map[string]interface{}{
 			"type": "section",
 			"text": map[string]string{
 				"type": "mrkdwn",
 				"text": fmt.Sprintf("%s\n%s%s", line1, line2, line3),
 			},
-		})
+		}, s.EPSSChart))
 
 		blocks = append(blocks, map[string]interface{}{"type": "divider"})
 	}
@@ -169,7 +294,7 @@ func buildSlackPayload(sleepers []SleeperCVE) ([]byte, error) {
 			"elements": []map[string]string{
 				{
 					"type": "mrkdwn",
-					"text": fmt.Sprintf("_...and %d more. Query your TigerFetch database for the full list._", len(sleepers)-10),
+					"text": loc.T("slack.sleeper.more", map[string]int{"Count": len(sleepers) - 10}),
 				},
 			},
 		})
@@ -210,6 +335,13 @@ type genericCVE struct {
 	CvssScore    *float64 `json:"cvss_score"`
 	CvssSeverity string   `json:"cvss_severity"`
 	CWE          string   `json:"cwe"`
+	InKEV        bool     `json:"in_kev"`
+	Ransomware   bool     `json:"known_ransomware_use"`
+	ExploitRef   bool     `json:"exploit_ref"`
+	PatchURL     string   `json:"patch_url,omitempty"`
+
+	Owners    []owners.Owner `json:"owners,omitempty"`
+	EPSSChart string         `json:"epss_chart,omitempty"`
 }
 
 func buildGenericPayload(sleepers []SleeperCVE) ([]byte, error) {
@@ -224,3 +356,178 @@ func buildGenericPayload(sleepers []SleeperCVE) ([]byte, error) {
 	}
 	return json.Marshal(out)
 }
+
+// --- KEV additions payloads ---
+
+func buildSlackKevPayload(loc *i18n.Localizer, additions []KevAddition) ([]byte, error) {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]string{
+				"type": "plain_text",
+				"text": loc.N("slack.kev.header", len(additions), nil),
+			},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]string{
+				{
+					"type": "mrkdwn",
+					"text": loc.T("slack.kev.catalog", map[string]string{"Version": additions[0].CatalogVersion}),
+				},
+			},
+		},
+		{"type": "divider"},
+	}
+
+	limit := len(additions)
+	if limit > 10 {
+		limit = 10
+	}
+
+	for _, a := range additions[:limit] {
+		nvdLink := fmt.Sprintf("https://nvd.nist.gov/vuln/detail/%s", a.CVEID)
+		text := fmt.Sprintf("*<%s|%s>*  %s %s — %s", nvdLink, a.CVEID, a.VendorProject, a.Product, a.VulnerabilityName)
+		if a.Ransomware {
+			text += fmt.Sprintf("  :skull: *%s*", loc.T("slack.ransomware", nil))
+		}
+		if len(a.Actors) > 0 {
+			text += fmt.Sprintf("\n%s: *%s*", loc.T("slack.actors", nil), strings.Join(a.Actors, ", "))
+		}
+		text += formatOwners(loc, a.Owners)
+		if a.DueDate != "" {
+			text += fmt.Sprintf("\n%s: *%s*", loc.T("slack.due", nil), a.DueDate)
+		}
+		if a.RequiredAction != "" {
+			text += fmt.Sprintf("\n>%s", a.RequiredAction)
+		}
+		if len(a.EOL) > 0 {
+			text += fmt.Sprintf("\n:warning: *%s:* %s\n>%s", loc.T("slack.eol", nil), strings.Join(eol.Labels(a.EOL), ", "), eol.LocalizedAction(loc, a.EOL))
+		}
+		blocks = append(blocks, withChart(loc, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		}, a.EPSSChart))
+	}
+
+	if len(additions) > 10 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "context",
+			"elements": []map[string]string{
+				{
+					"type": "mrkdwn",
+					"text": loc.T("slack.kev.more", map[string]int{"Count": len(additions) - 10}),
+				},
+			},
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{"blocks": blocks})
+}
+
+type genericKevPayload struct {
+	Event     string        `json:"event"`
+	Timestamp string        `json:"timestamp"`
+	Count     int           `json:"count"`
+	Additions []KevAddition `json:"additions"`
+}
+
+func buildGenericKevPayload(additions []KevAddition) ([]byte, error) {
+	return json.Marshal(genericKevPayload{
+		Event:     "kev_added",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Count:     len(additions),
+		Additions: additions,
+	})
+}
+
+// --- Escalation payloads ---
+
+func buildSlackEscalationPayload(loc *i18n.Localizer, alerts []CriticalAlert, now time.Time) ([]byte, error) {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]string{
+				"type": "plain_text",
+				"text": loc.N("slack.escalation.header", len(alerts), nil),
+			},
+		},
+		{"type": "divider"},
+	}
+
+	limit := min(len(alerts), 10)
+	for _, a := range alerts[:limit] {
+		nvdLink := fmt.Sprintf("https://nvd.nist.gov/vuln/detail/%s", a.CVEID)
+		text := fmt.Sprintf("*<%s|%s>*  %s %s — %s", nvdLink, a.CVEID, a.VendorProject, a.Product, a.VulnerabilityName)
+		if a.Ransomware {
+			text += fmt.Sprintf("  :skull: *%s*", loc.T("slack.ransomware", nil))
+		}
+		text += fmt.Sprintf("\n%s: *%s*", loc.T("slack.escalation.products", nil), strings.Join(a.Products, ", "))
+		text += formatOwners(loc, a.Owners)
+		if a.DueDate != "" {
+			text += fmt.Sprintf("\n%s: *%s*", loc.T("slack.due", nil), a.DueDate)
+		}
+		text += "\n:rotating_light: " + loc.T("slack.escalation.unacked",
+			map[string]string{"Age": unackedFor(now.Sub(a.DetectedAt)), "CVE": a.CVEID})
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		})
+	}
+
+	if len(alerts) > limit {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "context",
+			"elements": []map[string]string{
+				{
+					"type": "mrkdwn",
+					"text": loc.T("slack.escalation.more", map[string]int{"Count": len(alerts) - limit}),
+				},
+			},
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{"blocks": blocks})
+}
+
+type genericEscalationPayload struct {
+	Event     string          `json:"event"`
+	Timestamp string          `json:"timestamp"`
+	Count     int             `json:"count"`
+	Alerts    []CriticalAlert `json:"alerts"`
+}
+
+func buildGenericEscalationPayload(alerts []CriticalAlert, now time.Time) ([]byte, error) {
+	return json.Marshal(genericEscalationPayload{
+		Event:     "escalation",
+		Timestamp: now.UTC().Format(time.RFC3339),
+		Count:     len(alerts),
+		Alerts:    alerts,
+	})
+}
+
+// buildPagerDutyEvent triggers a PagerDuty incident for a. The dedup key
+// makes a repeated escalation update the incident instead of opening
+// another.
+func buildPagerDutyEvent(routingKey string, a CriticalAlert, now time.Time) ([]byte, error) {
+	summary := fmt.Sprintf("%s in CISA KEV affects %s, unacknowledged for %s",
+		a.CVEID, strings.Join(a.Products, ", "), unackedFor(now.Sub(a.DetectedAt)))
+	if a.VulnerabilityName != "" {
+		summary += ": " + a.VulnerabilityName
+	}
+	return json.Marshal(map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "tigerfetch-escalation-" + a.CVEID,
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         "tigerfetch",
+			"severity":       "critical",
+			"timestamp":      a.DetectedAt.UTC().Format(time.RFC3339),
+			"custom_details": a,
+		},
+		"links": []map[string]string{
+			{"href": "https://nvd.nist.gov/vuln/detail/" + a.CVEID, "text": "NVD"},
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+)
+
+const anonymousClient = "anonymous"
+
+type apiKey struct {
+	name  string
+	key   []byte
+	limit Limit
+	role  Role
+}
+
+// newAPIKey applies the [api] defaults to limits the key leaves at zero.
+// Keys are viewers unless they name a role.
+func newAPIKey(k config.APIKeyConfig, defaults Limit) (apiKey, error) {
+	role, err := ParseRole(k.Role, RoleViewer)
+	if err != nil {
+		return apiKey{}, err
+	}
+	lim := Limit{PerMinute: k.RatePerMinute, Burst: k.Burst, DailyQuota: k.DailyQuota}
+	if lim.PerMinute == 0 {
+		lim.PerMinute = defaults.PerMinute
+	}
+	if lim.Burst == 0 {
+		lim.Burst = defaults.Burst
+	}
+	if lim.DailyQuota == 0 {
+		lim.DailyQuota = defaults.DailyQuota
+	}
+	return apiKey{name: k.Name, key: []byte(k.Key), limit: lim, role: role}, nil
+}
+
+// requestKey returns the key from "Authorization: Bearer" or X-API-Key.
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// lookupKey compares against every configured key in constant time.
+func (s *Server) lookupKey(presented string) (apiKey, bool) {
+	var found apiKey
+	ok := false
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(k.key, []byte(presented)) == 1 {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+// guard authenticates the caller by API key or OIDC token, spends one
+// request from its allowance and sets the RateLimit-* headers (IETF
+// httpapi-ratelimit-headers) on every response. Refused requests get 401
+// or 429 with Retry-After. Without requireKey, callers without valid
+// credentials get the anonymous limits. Anonymous callers are viewers;
+// routes needing more are refused by authorize.
+func (s *Server) guard(next http.Handler, requireKey bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principal{name: anonymousClient, client: anonymousClient, role: RoleViewer}
+		id, lim := "addr:"+remoteHost(r), s.anonLimit
+		if s.authRequired {
+			token, ok := requestKey(r), false
+			if s.oidc != nil && isJWT(token) {
+				user, err := s.oidc.verify(r.Context(), token)
+				switch {
+				case err == nil:
+					p, id, ok = user, "oidc:"+user.name, true
+				case !errors.Is(err, errInvalidToken):
+					slog.Warn("API: verifying OIDC token failed", "error", err)
+					writeError(w, http.StatusServiceUnavailable, "identity provider unavailable")
+					return
+				}
+			} else if k, found := s.lookupKey(token); found {
+				p, id, lim, ok = principal{name: k.name, client: k.name, role: k.role}, "key:"+k.name, k.limit, true
+			}
+			if !ok && requireKey {
+				metrics.APIRejected.WithLabelValues(anonymousClient, "unauthorized").Inc()
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tigerfetch"`)
+				writeError(w, http.StatusUnauthorized, "missing or unknown API key or token")
+				return
+			}
+		}
+		client := p.client
+
+		d := s.limiter.Allow(id, lim)
+		setLimitHeaders(w.Header(), d)
+		if !d.Allowed {
+			metrics.APIRejected.WithLabelValues(client, d.Reason).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+			msg := "rate limit exceeded"
+			if d.Reason == "quota" {
+				msg = "daily quota exceeded"
+			}
+			writeError(w, http.StatusTooManyRequests, msg)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+func setLimitHeaders(h http.Header, d Decision) {
+	if d.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	}
+	if d.QuotaLimit > 0 {
+		h.Set("X-Quota-Limit", strconv.Itoa(d.QuotaLimit))
+		h.Set("X-Quota-Remaining", strconv.Itoa(d.QuotaRemaining))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// remoteHost is the peer address without the port. X-Forwarded-For is not
+// trusted; behind a proxy, configure keys instead.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
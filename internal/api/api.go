@@ -0,0 +1,375 @@
+// Package api is tigerfetch's lookup API. It runs on its own listener
+// (api.bind) so slow upstream lookups never share timeouts with /metrics and
+// /healthz. It only reads from the database, except for acknowledging
+// critical alerts (see Triage).
+//
+// Every request passes through per-client rate limiting and an optional
+// daily quota (see Limiter). When [api] keys or an OIDC issuer are
+// configured, requests must carry a key or token, whose role decides the
+// routes it may use; otherwise clients are told apart by remote address
+// and may only read.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tiger2go/internal/chart"
+	"tiger2go/internal/config"
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/mirror"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxBodyBytes bounds request bodies; 1 MiB holds tens of thousands of IDs.
+const maxBodyBytes = 1 << 20
+
+// Enricher is the part of *enrich.Enricher the handlers use.
+type Enricher interface {
+	Enrich(ctx context.Context, ids []string) ([]enrich.Result, error)
+	MaxIDs() int
+}
+
+// Store serves the cursor-paginated list endpoints.
+type Store interface {
+	ListConsolidated(ctx context.Context, page cursor.Page) ([]consolidate.Record, error)
+	ListCVEs(ctx context.Context, page cursor.Page, source string) ([]cve.EnrichedRecord, error)
+	ListAdvisories(ctx context.Context, page cursor.Page, feedURL string, w window.Window) ([]ingestor.Advisory, error)
+	ListChanges(ctx context.Context, page cursor.Page) ([]mirror.Change, error)
+	EPSSTrend(ctx context.Context, cveID string, days int) ([]chart.Point, error)
+	AdvisoryVolume(ctx context.Context, weeks int, until time.Time) ([]chart.Point, error)
+}
+
+// PoolStore is the Store backed by the database (typically the read pool).
+type PoolStore struct {
+	Pool *pgxpool.Pool
+}
+
+func (p PoolStore) ListConsolidated(ctx context.Context, page cursor.Page) ([]consolidate.Record, error) {
+	return consolidate.List(ctx, p.Pool, page)
+}
+
+func (p PoolStore) ListCVEs(ctx context.Context, page cursor.Page, source string) ([]cve.EnrichedRecord, error) {
+	return cve.ListEnriched(ctx, p.Pool, page, source)
+}
+
+func (p PoolStore) ListAdvisories(ctx context.Context, page cursor.Page, feedURL string, w window.Window) ([]ingestor.Advisory, error) {
+	return ingestor.ListAdvisories(ctx, p.Pool, page, feedURL, w)
+}
+
+func (p PoolStore) ListChanges(ctx context.Context, page cursor.Page) ([]mirror.Change, error) {
+	return mirror.ListChanges(ctx, p.Pool, page)
+}
+
+func (p PoolStore) EPSSTrend(ctx context.Context, cveID string, days int) ([]chart.Point, error) {
+	return chart.EPSSTrend(ctx, p.Pool, cveID, days)
+}
+
+func (p PoolStore) AdvisoryVolume(ctx context.Context, weeks int, until time.Time) ([]chart.Point, error) {
+	return chart.AdvisoryVolume(ctx, p.Pool, weeks, until)
+}
+
+// Server holds the API handlers and their dependencies.
+type Server struct {
+	enricher  Enricher
+	store     Store
+	settle    time.Duration
+	triage    Triage // nil: the triage routes answer 501
+	keys      []apiKey
+	oidc      *oidcVerifier
+	anonLimit Limit
+	limiter   *Limiter
+
+	authRequired bool // keys or an OIDC issuer are configured
+
+	publicCharts bool // chart routes need no key
+}
+
+// New creates a Server with the access rules from cfg.
+func New(enricher Enricher, store Store, cfg config.APIConfig) *Server {
+	defaults := Limit{PerMinute: cfg.RatePerMinute, Burst: cfg.Burst, DailyQuota: cfg.DailyQuota}
+	settle, err := cfg.GetListSettle()
+	switch {
+	case err != nil:
+		slog.Warn("Invalid api.list_settle, using default", "value", cfg.ListSettle, "error", err)
+		settle = DefaultListSettle
+	case settle == 0:
+		settle = DefaultListSettle
+	case settle < 0:
+		settle = 0
+	}
+	s := &Server{enricher: enricher, store: store, settle: settle, anonLimit: defaults, limiter: NewLimiter(),
+		publicCharts: cfg.PublicCharts}
+	for _, k := range cfg.Keys {
+		if k.Key == "" {
+			slog.Warn("Ignoring API key with empty key", "name", k.Name)
+			continue
+		}
+		key, err := newAPIKey(k, defaults)
+		if err != nil {
+			slog.Warn("Ignoring API key with invalid role", "name", k.Name, "error", err)
+			s.authRequired = true
+			continue
+		}
+		s.keys = append(s.keys, key)
+	}
+	if s.oidc, err = newOIDCVerifier(cfg.OIDC); err != nil {
+		// Still require credentials: an invalid section must not open the API
+		slog.Error("Invalid api.oidc, refusing OIDC tokens", "error", err)
+	}
+	s.authRequired = s.authRequired || len(s.keys) > 0 || cfg.OIDC.Issuer != ""
+	return s
+}
+
+// SetTriage serves the critical alert and audit routes from t.
+func (s *Server) SetTriage(t Triage) { s.triage = t }
+
+// operation is one API route. The same table registers the handlers and
+// generates the OpenAPI document, so the two cannot drift apart.
+type operation struct {
+	method   string
+	path     string
+	id       string
+	summary  string
+	request  any      // zero value of the JSON request body, nil for none
+	response any      // zero value of the 200 response body
+	media    []string // media types of a non-JSON 200 response
+	params   []param
+	errors   []int // documented error statuses besides 401/429
+	public   bool  // served without a key when [api] public_charts is set
+	role     Role  // least role allowed; zero means viewer
+	handler  http.HandlerFunc
+}
+
+// param is a documented query parameter.
+type param struct {
+	name string
+	desc string
+	typ  string // "string" or "integer"
+}
+
+var pageParams = []param{
+	{"cursor", "Opaque cursor from a previous page's next_cursor; omit to start from the beginning", "string"},
+	{"limit", "Page size, 1-1000 (default 100)", "integer"},
+}
+
+func (s *Server) operations() []operation {
+	return []operation{
+		{
+			method:   http.MethodPost,
+			path:     "/enrich",
+			id:       "enrich",
+			summary:  "Merged NVD, KEV and EPSS data for a list of CVE IDs",
+			request:  enrichRequest{},
+			response: enrichResponse{},
+			errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusInternalServerError},
+			handler:  s.handleEnrich,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/cves",
+			id:       "listCves",
+			summary:  "One consolidated record per CVE, merged from every source, in change order, paginated by cursor",
+			response: consolidatedList{},
+			params:   pageParams,
+			errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+			handler:  s.handleListConsolidated,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/cves/records",
+			id:       "listCveRecords",
+			summary:  "Stored NVD and KEV records as received, in change order, paginated by cursor",
+			response: cveList{},
+			params:   append([]param{{"source", "Only this source, e.g. NVD or CISA-KEV", "string"}}, pageParams...),
+			errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+			handler:  s.handleListCVEs,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/advisories",
+			id:       "listAdvisories",
+			summary:  "Current feed advisories in change order, paginated by cursor",
+			response: advisoryList{},
+			params: append([]param{
+				{"feed_url", "Only advisories from this feed", "string"},
+				{"since", "Only advisories at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now such as 72h", "string"},
+				{"until", "Only advisories before this time, in the same forms as since", "string"},
+				{"time_field", "What since and until compare against: published (default; undated items use first_seen) or first_seen", "string"},
+			}, pageParams...),
+			errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+			handler: s.handleListAdvisories,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/changes",
+			id:       "listChanges",
+			summary:  "Every advisory, CVE, KEV and EPSS change since a cursor, for mirrors",
+			response: mirror.ChangePage{},
+			params: []param{
+				{"since", "Opaque cursor from a previous page's next_cursor; omit to start from the beginning", "string"},
+				pageParams[1],
+			},
+			errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+			handler: s.handleChanges,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/charts/epss/{cve}",
+			id:      "epssChart",
+			summary: "Daily EPSS score of a CVE as a line chart, for notifications and digests",
+			media:   []string{chartMedia["svg"], chartMedia["png"]},
+			params: append([]param{
+				{"days", "Days up to the latest score, 2-365 (default 30)", "integer"},
+			}, chartParams...),
+			errors:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+			public:  true,
+			handler: s.handleEPSSChart,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/charts/advisories",
+			id:      "advisoryVolumeChart",
+			summary: "Feed advisories first seen per week as a bar chart, for notifications and digests",
+			media:   []string{chartMedia["svg"], chartMedia["png"]},
+			params: append([]param{
+				{"weeks", "Weeks up to now, 1-104 (default 12)", "integer"},
+			}, chartParams...),
+			errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+			public:  true,
+			handler: s.handleVolumeChart,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/alerts",
+			id:       "listOpenAlerts",
+			summary:  "Critical alerts on monitored products nobody acknowledged yet",
+			response: alertList{},
+			errors:   []int{http.StatusInternalServerError, http.StatusNotImplemented},
+			handler:  s.handleOpenAlerts,
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/alerts/ack",
+			id:       "ackAlerts",
+			summary:  "Acknowledge critical alerts as the caller, stopping their escalation",
+			request:  ackRequest{},
+			response: ackResponse{},
+			errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented},
+			role:     RoleAnalyst,
+			handler:  s.handleAck,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/audit",
+			id:       "listAuditEvents",
+			summary:  "Audit trail of source switches, cursor moves, acknowledgements and notifications, newest first",
+			response: auditList{},
+			params: []param{
+				{"since", "Only events at or after this time: RFC 3339, YYYY-MM-DD or a duration back from now such as 72h", "string"},
+				{"until", "Only events before this time, in the same forms as since", "string"},
+				{"action", `Only this action, or those starting with a prefix ending in ".", e.g. "cursor."`, "string"},
+				{"actor", "Only events recorded as this actor", "string"},
+				{"limit", "At most this many events, 1-1000 (default 100)", "integer"},
+			},
+			errors:  []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented},
+			role:    RoleAdmin,
+			handler: s.handleAudit,
+		},
+	}
+}
+
+// Handler returns the API routes behind authentication and rate limiting.
+// GET /openapi.json is served without either, and with [api] public_charts
+// the chart routes without a key: chat clients fetch image URLs
+// themselves.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	api := http.NewServeMux()
+	for _, op := range s.operations() {
+		handler := authorize(max(op.role, RoleViewer), op.handler)
+		if op.public && s.publicCharts {
+			mux.Handle(op.method+" "+op.path, s.guard(handler, false))
+			continue
+		}
+		api.HandleFunc(op.method+" "+op.path, handler)
+	}
+
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	mux.Handle("/", s.guard(api, true))
+	return mux
+}
+
+type enrichRequest struct {
+	CveIDs []string `json:"cve_ids"`
+}
+
+type enrichResponse struct {
+	Results []enrich.Result `json:"results"`
+	Invalid []string        `json:"invalid,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// handleEnrich returns one merged record per requested CVE, in request order
+// with duplicates removed. Malformed IDs are listed under "invalid".
+func (s *Server) handleEnrich(w http.ResponseWriter, r *http.Request) {
+	var req enrichRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	ids, invalid := enrich.Normalize(req.CveIDs)
+	if len(ids) == 0 && len(invalid) == 0 {
+		writeError(w, http.StatusBadRequest, "cve_ids is required")
+		return
+	}
+	if len(ids) > s.enricher.MaxIDs() {
+		writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("too many CVE IDs: %d requested, limit %d", len(ids), s.enricher.MaxIDs()))
+		return
+	}
+
+	results, err := s.enricher.Enrich(r.Context(), ids)
+	if err != nil {
+		if errors.Is(err, enrich.ErrTooManyIDs) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		slog.Error("Enrich request failed", "count", len(ids), "error", err)
+		writeError(w, http.StatusInternalServerError, "enrichment failed")
+		return
+	}
+	if results == nil {
+		results = []enrich.Result{}
+	}
+	writeJSON(w, http.StatusOK, enrichResponse{Results: results, Invalid: invalid})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("Failed to write API response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
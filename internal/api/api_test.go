@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/enrich"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubEnricher struct {
+	max int
+	got []string
+	err error
+}
+
+func (s *stubEnricher) MaxIDs() int { return s.max }
+
+func (s *stubEnricher) Enrich(_ context.Context, ids []string) ([]enrich.Result, error) {
+	s.got = ids
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make([]enrich.Result, len(ids))
+	for i, id := range ids {
+		out[i] = enrich.Result{CveID: id, Found: id == "CVE-2024-21887", InKEV: id == "CVE-2024-21887"}
+	}
+	return out, nil
+}
+
+func post(t *testing.T, h http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/enrich", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestEnrich_OK(t *testing.T) {
+	stub := &stubEnricher{max: 10}
+	rr := post(t, New(stub, nil, config.APIConfig{}).Handler(), `{"cve_ids":["cve-2024-21887","CVE-2024-21887","not-a-cve","CVE-2023-46805"]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"CVE-2024-21887", "CVE-2023-46805"}, stub.got, "normalized and de-duplicated")
+
+	var resp enrichResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.True(t, resp.Results[0].InKEV)
+	assert.False(t, resp.Results[1].Found)
+	assert.Equal(t, []string{"not-a-cve"}, resp.Invalid)
+}
+
+func TestEnrich_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"bad json", `{"cve_ids":`, nil, http.StatusBadRequest},
+		{"unknown field", `{"ids":["CVE-2024-0001"]}`, nil, http.StatusBadRequest},
+		{"empty", `{"cve_ids":[]}`, nil, http.StatusBadRequest},
+		{"over limit", `{"cve_ids":["CVE-2024-0001","CVE-2024-0002","CVE-2024-0003"]}`, nil, http.StatusRequestEntityTooLarge},
+		{"store error", `{"cve_ids":["CVE-2024-0001"]}`, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := post(t, New(&stubEnricher{max: 2, err: tt.err}, nil, config.APIConfig{}).Handler(), tt.body)
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"error"`)
+			assert.NotContains(t, rr.Body.String(), "connection refused", "internal errors are not leaked")
+		})
+	}
+}
+
+func TestEnrich_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/enrich", nil)
+	rr := httptest.NewRecorder()
+	New(&stubEnricher{max: 1}, nil, config.APIConfig{}).Handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
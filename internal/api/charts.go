@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/chart"
+	"tiger2go/internal/enrich"
+)
+
+// Chart defaults and limits.
+const (
+	defaultChartWidth  = 480
+	defaultChartHeight = 200
+	defaultEPSSDays    = 30
+	maxEPSSDays        = 365
+	defaultVolumeWeeks = 12
+	maxVolumeWeeks     = 104
+)
+
+// chartMedia are the media types of the chart endpoints, by format.
+var chartMedia = map[string]string{"svg": "image/svg+xml", "png": "image/png"}
+
+var chartParams = []param{
+	{"format", "svg (default, with title and labels) or png (no text, for Slack image blocks)", "string"},
+	{"width", "Width in pixels (default 480)", "integer"},
+	{"height", "Height in pixels (default 200)", "integer"},
+}
+
+func (s *Server) handleEPSSChart(w http.ResponseWriter, r *http.Request) {
+	ids, _ := enrich.Normalize([]string{r.PathValue("cve")})
+	if len(ids) != 1 {
+		writeError(w, http.StatusBadRequest, "invalid CVE ID")
+		return
+	}
+	days, ok := intParam(w, r, "days", defaultEPSSDays, 2, maxEPSSDays)
+	if !ok {
+		return
+	}
+	points, err := s.store.EPSSTrend(r.Context(), ids[0], days)
+	if err != nil {
+		slog.Error("EPSS chart failed", "cve", ids[0], "error", err)
+		writeError(w, http.StatusInternalServerError, "chart failed")
+		return
+	}
+	if len(points) == 0 {
+		writeError(w, http.StatusNotFound, "no EPSS scores for "+ids[0])
+		return
+	}
+	writeChart(w, r, chart.Chart{
+		Title:  "EPSS " + ids[0],
+		Kind:   chart.Line,
+		Points: points,
+		Max:    1,
+		Format: func(v float64) string { return strconv.FormatFloat(math.Round(v*1000)/10, 'f', -1, 64) + "%" },
+	})
+}
+
+func (s *Server) handleVolumeChart(w http.ResponseWriter, r *http.Request) {
+	weeks, ok := intParam(w, r, "weeks", defaultVolumeWeeks, 1, maxVolumeWeeks)
+	if !ok {
+		return
+	}
+	points, err := s.store.AdvisoryVolume(r.Context(), weeks, time.Now())
+	if err != nil {
+		slog.Error("Advisory volume chart failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "chart failed")
+		return
+	}
+	writeChart(w, r, chart.Chart{Title: "Advisories per week", Kind: chart.Bar, Points: points})
+}
+
+// writeChart renders c in the requested format and size.
+func writeChart(w http.ResponseWriter, r *http.Request, c chart.Chart) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "svg"
+	}
+	media, ok := chartMedia[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid format %q: use svg or png", format))
+		return
+	}
+	width, ok := intParam(w, r, "width", defaultChartWidth, chart.MinSize, chart.MaxSize)
+	if !ok {
+		return
+	}
+	height, ok := intParam(w, r, "height", defaultChartHeight, chart.MinSize, chart.MaxSize)
+	if !ok {
+		return
+	}
+
+	body := chart.SVG(c, width, height)
+	if format == "png" {
+		var err error
+		if body, err = chart.PNG(c, width, height); err != nil {
+			slog.Error("Chart encoding failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "chart failed")
+			return
+		}
+	}
+	w.Header().Set("Content-Type", media)
+	// Chat clients fetch and cache image URLs themselves; a short max-age
+	// keeps repeated unfurls off the database.
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	_, _ = w.Write(body)
+}
+
+// intParam reads query parameter name, def when absent; it answers 400 and
+// returns false when the value is not an integer in [lo, hi].
+func intParam(w http.ResponseWriter, r *http.Request, name string, def, lo, hi int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || n > hi {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be between %d and %d", name, lo, hi))
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiger2go/internal/chart"
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEPSSChart(t *testing.T) {
+	store := &stubStore{epss: []chart.Point{{Label: "10-10", Value: 0.02}, {Label: "10-11", Value: 0.31}, {Label: "10-12", Value: 0.57}}}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	rr := get(t, h, "/charts/epss/cve-2026-1234")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "CVE-2026-1234", store.lastQuery)
+	assert.Contains(t, rr.Body.String(), "EPSS CVE-2026-1234</text>")
+	assert.Contains(t, rr.Body.String(), "<title>10-12: 57%</title>")
+
+	rr = get(t, h, "/charts/epss/CVE-2026-1234?format=png&width=120&height=60&days=2")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	img, err := png.Decode(bytes.NewReader(rr.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 120, img.Bounds().Dx())
+	assert.Equal(t, 60, img.Bounds().Dy())
+
+	for target, status := range map[string]int{
+		"/charts/epss/not-a-cve":                  http.StatusBadRequest,
+		"/charts/epss/CVE-2026-1234?format=gif":   http.StatusBadRequest,
+		"/charts/epss/CVE-2026-1234?width=5000":   http.StatusBadRequest,
+		"/charts/epss/CVE-2026-1234?days=0":       http.StatusBadRequest,
+		"/charts/advisories?weeks=x":              http.StatusBadRequest,
+		"/charts/advisories?weeks=200&format=png": http.StatusBadRequest,
+	} {
+		assert.Equal(t, status, get(t, h, target).Code, target)
+	}
+
+	store.epss = nil
+	assert.Equal(t, http.StatusNotFound, get(t, h, "/charts/epss/CVE-2026-1234").Code)
+}
+
+func TestVolumeChart(t *testing.T) {
+	store := &stubStore{volume: []chart.Point{{Label: "09-28", Value: 120}, {Label: "10-05", Value: 95}}}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	rr := get(t, h, "/charts/advisories?weeks=2")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 2, store.weeks)
+	assert.Contains(t, rr.Body.String(), "<title>09-28: 120</title>")
+}
+
+func TestCharts_PublicCharts(t *testing.T) {
+	store := &stubStore{volume: []chart.Point{{Label: "10-05", Value: 1}}}
+	keys := []config.APIKeyConfig{{Name: "a", Key: "k"}}
+
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{Keys: keys}).Handler()
+	assert.Equal(t, http.StatusUnauthorized, get(t, h, "/charts/advisories").Code, "charts need a key by default")
+	req := httptest.NewRequest(http.MethodGet, "/charts/advisories", nil)
+	req.Header.Set("X-API-Key", "k")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	h = New(&stubEnricher{max: 1}, store, config.APIConfig{Keys: keys, PublicCharts: true, Burst: 1}).Handler()
+	rr = get(t, h, "/charts/advisories")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"), "anonymous callers get the anonymous limits")
+	assert.Equal(t, http.StatusTooManyRequests, get(t, h, "/charts/advisories").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(t, h, "/cves").Code, "other routes still need a key")
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/mirror"
+	"tiger2go/internal/window"
+)
+
+// Page sizes for the list endpoints.
+const (
+	DefaultPageSize   = 100
+	MaxPageSize       = 1000
+	DefaultListSettle = time.Minute
+)
+
+// Lists are ordered by the server-assigned updated_at, not by upstream
+// timestamps, and paged with opaque cursors rather than offsets: an offset
+// shifts whenever rows are inserted ahead of it, a cursor does not. A client
+// mirroring the data keeps the last next_cursor and polls with it; rows
+// that change later come back with a newer updated_at.
+
+type consolidatedList struct {
+	Items      []consolidate.Record `json:"items"`
+	NextCursor string               `json:"next_cursor"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+type cveList struct {
+	Items      []cve.EnrichedRecord `json:"items"`
+	NextCursor string               `json:"next_cursor"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+type advisoryList struct {
+	Items      []ingestor.Advisory `json:"items"`
+	NextCursor string              `json:"next_cursor"`
+	HasMore    bool                `json:"has_more"`
+}
+
+func (s *Server) handleListConsolidated(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.parsePage(w, r, "cursor", consolidate.CursorKeys)
+	if !ok {
+		return
+	}
+	items, err := s.store.ListConsolidated(r.Context(), page)
+	if err != nil {
+		slog.Error("List consolidated CVEs failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	resp := consolidatedList{Items: []consolidate.Record{}, NextCursor: page.After.String()}
+	if len(items) > page.Limit-1 {
+		items, resp.HasMore = items[:page.Limit-1], true
+	}
+	if len(items) > 0 {
+		resp.Items, resp.NextCursor = items, items[len(items)-1].Cursor().String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleListCVEs(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.parsePage(w, r, "cursor", cve.EnrichedCursorKeys)
+	if !ok {
+		return
+	}
+	items, err := s.store.ListCVEs(r.Context(), page, r.URL.Query().Get("source"))
+	if err != nil {
+		slog.Error("List CVEs failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	resp := cveList{Items: []cve.EnrichedRecord{}, NextCursor: page.After.String()}
+	if len(items) > page.Limit-1 {
+		items, resp.HasMore = items[:page.Limit-1], true
+	}
+	if len(items) > 0 {
+		resp.Items, resp.NextCursor = items, items[len(items)-1].Cursor().String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleListAdvisories(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.parsePage(w, r, "cursor", ingestor.AdvisoryCursorKeys)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	win, err := window.Parse(q.Get("since"), q.Get("until"), q.Get("time_field"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	items, err := s.store.ListAdvisories(r.Context(), page, q.Get("feed_url"), win)
+	if err != nil {
+		slog.Error("List advisories failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	resp := advisoryList{Items: []ingestor.Advisory{}, NextCursor: page.After.String()}
+	if len(items) > page.Limit-1 {
+		items, resp.HasMore = items[:page.Limit-1], true
+	}
+	if len(items) > 0 {
+		resp.Items, resp.NextCursor = items, items[len(items)-1].Cursor().String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleChanges serves the delta sync feed: every advisory, CVE, KEV and
+// EPSS change since a cursor. See package mirror.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.parsePage(w, r, "since", mirror.CursorKeys)
+	if !ok {
+		return
+	}
+	changes, err := s.store.ListChanges(r.Context(), page)
+	if err != nil {
+		slog.Error("List changes failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	resp := mirror.ChangePage{Changes: []mirror.Change{}, NextCursor: page.After.String()}
+	if len(changes) > page.Limit-1 {
+		changes, resp.HasMore = changes[:page.Limit-1], true
+	}
+	if len(changes) > 0 {
+		resp.Changes, resp.NextCursor = changes, changes[len(changes)-1].Cursor().String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parsePage reads the cursor query parameter (named cursorParam) and limit.
+// The returned Limit is one more than the page size so the handler can tell
+// whether another page follows.
+func (s *Server) parsePage(w http.ResponseWriter, r *http.Request, cursorParam string, keyLen int) (cursor.Page, bool) {
+	q := r.URL.Query()
+	limit := DefaultPageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPageSize {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(MaxPageSize))
+			return cursor.Page{}, false
+		}
+		limit = n
+	}
+	after, err := cursor.Parse(q.Get(cursorParam), keyLen)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return cursor.Page{}, false
+	}
+	return cursor.Page{After: after, Limit: limit + 1, Settle: s.settle}, true
+}
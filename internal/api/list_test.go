@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiger2go/internal/chart"
+	"tiger2go/internal/config"
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/mirror"
+	"tiger2go/internal/window"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStore serves records already sorted by cursor position.
+type stubStore struct {
+	records   []consolidate.Record
+	cves      []cve.EnrichedRecord
+	changes   []mirror.Change
+	pages     []cursor.Page
+	lastQuery string
+	window    window.Window
+	epss      []chart.Point
+	volume    []chart.Point
+	weeks     int
+}
+
+func (s *stubStore) ListConsolidated(_ context.Context, page cursor.Page) ([]consolidate.Record, error) {
+	s.pages = append(s.pages, page)
+	var out []consolidate.Record
+	for _, r := range s.records {
+		if after(r.Cursor(), page.After) && len(out) < page.Limit {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubStore) ListCVEs(_ context.Context, page cursor.Page, source string) ([]cve.EnrichedRecord, error) {
+	s.pages = append(s.pages, page)
+	s.lastQuery = source
+	var out []cve.EnrichedRecord
+	for _, r := range s.cves {
+		if after(r.Cursor(), page.After) && len(out) < page.Limit {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubStore) ListAdvisories(_ context.Context, _ cursor.Page, feedURL string, w window.Window) ([]ingestor.Advisory, error) {
+	s.lastQuery, s.window = feedURL, w
+	return nil, nil
+}
+
+func (s *stubStore) EPSSTrend(_ context.Context, cveID string, days int) ([]chart.Point, error) {
+	s.lastQuery = cveID
+	if len(s.epss) > days {
+		return s.epss[len(s.epss)-days:], nil
+	}
+	return s.epss, nil
+}
+
+func (s *stubStore) AdvisoryVolume(_ context.Context, weeks int, _ time.Time) ([]chart.Point, error) {
+	s.weeks = weeks
+	return s.volume, nil
+}
+
+func (s *stubStore) ListChanges(_ context.Context, page cursor.Page) ([]mirror.Change, error) {
+	s.pages = append(s.pages, page)
+	var out []mirror.Change
+	for _, c := range s.changes {
+		if c.ChangedAt.After(page.After.UpdatedAt) && len(out) < page.Limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func after(a, b cursor.Cursor) bool {
+	if !a.UpdatedAt.Equal(b.UpdatedAt) {
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}
+	return a.KeyPart(0)+"\x00"+a.KeyPart(1) > b.KeyPart(0)+"\x00"+b.KeyPart(1)
+}
+
+func get(t *testing.T, h http.Handler, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+	return rr
+}
+
+func TestListConsolidated_PagesWithCursor(t *testing.T) {
+	ts := time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)
+	store := &stubStore{records: []consolidate.Record{
+		{CveID: "CVE-2024-0001", Sources: []string{"CISA-KEV", "NVD"}, InKEV: true, UpdatedAt: ts},
+		{CveID: "CVE-2024-0002", Sources: []string{"NVD"}, UpdatedAt: ts.Add(time.Second)},
+	}}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	rr := get(t, h, "/cves?limit=1")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var page consolidatedList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.True(t, page.HasMore)
+	assert.True(t, page.Items[0].InKEV)
+	assert.Equal(t, []string{"CISA-KEV", "NVD"}, page.Items[0].Sources)
+
+	rr = get(t, h, "/cves?limit=1&cursor="+page.NextCursor)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, "CVE-2024-0002", page.Items[0].CveID)
+
+	recordCursor := cve.EnrichedRecord{CveID: "CVE-2024-0001", Source: "NVD", UpdatedAt: ts}.Cursor().String()
+	assert.Equal(t, http.StatusBadRequest, get(t, h, "/cves?cursor="+recordCursor).Code, "raw record cursors do not page /cves")
+}
+
+func TestListCVEs_PagesWithCursor(t *testing.T) {
+	ts := time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)
+	store := &stubStore{cves: []cve.EnrichedRecord{
+		{CveID: "CVE-2024-0001", Source: "NVD", UpdatedAt: ts},
+		{CveID: "CVE-2024-0002", Source: "NVD", UpdatedAt: ts},
+		{CveID: "CVE-2024-0003", Source: "NVD", UpdatedAt: ts.Add(time.Second)},
+	}}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	var seen []string
+	next := ""
+	for range 5 {
+		rr := get(t, h, "/cves/records?limit=2&source=NVD&cursor="+next)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var page cveList
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		for _, r := range page.Items {
+			seen = append(seen, r.CveID)
+		}
+		next = page.NextCursor
+		if !page.HasMore {
+			break
+		}
+	}
+	assert.Equal(t, []string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"}, seen)
+	assert.Equal(t, "NVD", store.lastQuery)
+	assert.Equal(t, 3, store.pages[0].Limit, "one extra row to detect has_more")
+	assert.Equal(t, DefaultListSettle, store.pages[0].Settle)
+
+	// A record arriving after the sync caught up shows up on the next poll.
+	store.cves = append(store.cves, cve.EnrichedRecord{CveID: "CVE-2023-9999", Source: "NVD", UpdatedAt: ts.Add(time.Minute)})
+	rr := get(t, h, "/cves/records?cursor="+next)
+	var page cveList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "CVE-2023-9999", page.Items[0].CveID)
+
+	// An empty page hands the same cursor back.
+	rr = get(t, h, "/cves/records?cursor="+page.NextCursor)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Empty(t, page.Items)
+	assert.NotNil(t, page.Items)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestList_BadParams(t *testing.T) {
+	h := New(&stubEnricher{max: 1}, &stubStore{}, config.APIConfig{RatePerMinute: -1}).Handler()
+	advCursor := cursor.Cursor{UpdatedAt: time.Now(), Key: []string{"00000000-0000-0000-0000-000000000001"}}.String()
+
+	for _, url := range []string{
+		"/cves?limit=0",
+		"/cves?limit=1001",
+		"/cves?limit=ten",
+		"/cves?cursor=garbage",
+		"/cves/records?limit=0",
+		"/cves/records?cursor=" + advCursor, // cursor from another list
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(t, h, url).Code, url)
+	}
+	assert.Equal(t, http.StatusOK, get(t, h, "/advisories?cursor="+advCursor).Code)
+}
+
+func TestNew_ListSettle(t *testing.T) {
+	assert.Equal(t, DefaultListSettle, New(nil, nil, config.APIConfig{}).settle)
+	assert.Equal(t, 5*time.Second, New(nil, nil, config.APIConfig{ListSettle: "5s"}).settle)
+	assert.Zero(t, New(nil, nil, config.APIConfig{ListSettle: "-1s"}).settle)
+}
+
+func TestChanges_PagesWithSince(t *testing.T) {
+	ts := time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC)
+	store := &stubStore{changes: []mirror.Change{
+		{Kind: mirror.KindCVE, ChangedAt: ts, CVE: &cve.EnrichedRecord{CveID: "CVE-2024-0001", Source: "NVD"}},
+		{Kind: mirror.KindEPSS, ChangedAt: ts.Add(time.Second), EPSS: &cve.EpssRow{CVE: "CVE-2024-0001", EPSS: "0.5", Date: "2024-01-22"}},
+		{Kind: mirror.KindAdvisory, ChangedAt: ts.Add(2 * time.Second), Advisory: &ingestor.Advisory{GUID: "a-1"}},
+	}}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	rr := get(t, h, "/changes?limit=2")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var page mirror.ChangePage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Changes, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "0.5", page.Changes[1].EPSS.EPSS)
+	assert.NotContains(t, rr.Body.String(), `"advisory":`, "only the payload for the kind is set")
+
+	rr = get(t, h, "/changes?limit=2&since="+page.NextCursor)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Changes, 1)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, "a-1", page.Changes[0].Advisory.GUID)
+
+	cveCursor := cve.EnrichedRecord{CveID: "CVE-2024-0001", Source: "NVD", UpdatedAt: ts}.Cursor().String()
+	assert.Equal(t, http.StatusBadRequest, get(t, h, "/changes?since="+cveCursor).Code, "list cursors are not feed cursors")
+}
+
+func TestListAdvisories_Window(t *testing.T) {
+	store := &stubStore{}
+	h := New(&stubEnricher{max: 1}, store, config.APIConfig{RatePerMinute: -1}).Handler()
+
+	rr := get(t, h, "/advisories?feed_url=https://example.com/feed&since=2024-01-01&until=2024-02-01T00:00:00Z&time_field=first_seen")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "https://example.com/feed", store.lastQuery)
+	assert.Equal(t, window.Window{
+		Field: window.FirstSeen,
+		Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, store.window)
+
+	require.Equal(t, http.StatusOK, get(t, h, "/advisories").Code)
+	assert.Equal(t, window.Window{Field: window.Published}, store.window)
+
+	for _, url := range []string{
+		"/advisories?since=yesterday",
+		"/advisories?time_field=updated",
+		"/advisories?since=2024-02-01&until=2024-01-01",
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(t, h, url).Code, url)
+	}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // the RS256, PS256 and ES256 digests
+	_ "crypto/sha512" // the 384 and 512 digests
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+)
+
+// jwksRefetch spaces key set fetches on a token signed with an unknown
+// key, so garbage tokens cannot make the API hammer the identity provider.
+const jwksRefetch = time.Minute
+
+// errInvalidToken is returned for every token that does not verify; the
+// reason is kept from the caller.
+var errInvalidToken = errors.New("invalid token")
+
+// oidcVerifier checks bearer JWTs from one OpenID Connect issuer against
+// its published keys and maps their groups to a role.
+type oidcVerifier struct {
+	issuer      string
+	audience    string
+	groupsClaim string
+	userClaim   string
+	defaultRole Role
+	groups      map[string]Role
+	client      *http.Client
+	now         func() time.Time
+
+	mu      sync.Mutex
+	jwksURL string
+	keys    map[string]crypto.PublicKey // by kid
+	fetched time.Time
+}
+
+// newOIDCVerifier returns the verifier of cfg, nil when no issuer is set.
+func newOIDCVerifier(cfg config.APIOIDCConfig) (*oidcVerifier, error) {
+	if cfg.Issuer == "" {
+		return nil, nil
+	}
+	if cfg.Audience == "" {
+		return nil, errors.New("api.oidc.audience is required with an issuer")
+	}
+	def, err := ParseRole(cfg.DefaultRole, RoleNone)
+	if err != nil {
+		return nil, fmt.Errorf("api.oidc.default_role: %w", err)
+	}
+	v := &oidcVerifier{
+		issuer:      strings.TrimSuffix(cfg.Issuer, "/"),
+		audience:    cfg.Audience,
+		groupsClaim: cfg.GroupsClaim,
+		userClaim:   cfg.UserClaim,
+		defaultRole: def,
+		groups:      map[string]Role{},
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+	if v.groupsClaim == "" {
+		v.groupsClaim = "groups"
+	}
+	if v.userClaim == "" {
+		v.userClaim = "email"
+	}
+	for role, groups := range map[Role][]string{RoleViewer: cfg.Viewers, RoleAnalyst: cfg.Analysts, RoleAdmin: cfg.Admins} {
+		for _, g := range groups {
+			v.groups[g] = max(v.groups[g], role)
+		}
+	}
+	return v, nil
+}
+
+// isJWT tells a JWT from an API key: three base64url segments.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verify checks token's signature, issuer, audience and lifetime and
+// returns the caller it names with the role of its groups.
+func (v *oidcVerifier) verify(ctx context.Context, token string) (principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return principal{}, errInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return principal{}, errInvalidToken
+	}
+	hash, ok := jwsHashes[header.Alg]
+	if !ok {
+		return principal{}, errInvalidToken // "none" and HMAC included
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return principal{}, errInvalidToken
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return principal{}, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key, header.Alg, hash, h.Sum(nil), sig) {
+		return principal{}, errInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return principal{}, errInvalidToken
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != v.issuer {
+		return principal{}, errInvalidToken
+	}
+	if !slices.Contains(stringsClaim(claims["aud"]), v.audience) {
+		return principal{}, errInvalidToken
+	}
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || !now.Before(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return principal{}, errInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(time.Minute).Before(time.Unix(int64(nbf), 0)) {
+		return principal{}, errInvalidToken
+	}
+
+	role := v.defaultRole
+	for _, g := range stringsClaim(claims[v.groupsClaim]) {
+		role = max(role, v.groups[g])
+	}
+	name, _ := claims[v.userClaim].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+	return principal{name: name, client: "oidc", role: role}, nil
+}
+
+// key returns the issuer's key kid, fetching the key set on first use and
+// again when kid is unknown, at most every jwksRefetch.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if v.now().Sub(v.fetched) < jwksRefetch {
+		return nil, errInvalidToken
+	}
+	v.fetched = v.now()
+	if err := v.fetchKeys(ctx); err != nil {
+		return nil, fmt.Errorf("oidc keys of %s: %w", v.issuer, err)
+	}
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, errInvalidToken
+}
+
+// fetchKeys reads the key set named by the issuer's discovery document.
+// Keys of types and curves it cannot use are skipped.
+func (v *oidcVerifier) fetchKeys(ctx context.Context) error {
+	if v.jwksURL == "" {
+		var disc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &disc); err != nil {
+			return err
+		}
+		if strings.TrimSuffix(disc.Issuer, "/") != v.issuer || disc.JWKSURI == "" {
+			return fmt.Errorf("discovery document names issuer %q and jwks_uri %q", disc.Issuer, disc.JWKSURI)
+		}
+		v.jwksURL = disc.JWKSURI
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURL, &set); err != nil {
+		return err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384()}[k.Crv]
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if curve == nil || err1 != nil || err2 != nil {
+				continue
+			}
+			size := (curve.Params().BitSize + 7) / 8
+			point := append([]byte{4}, append(leftPad(x, size), leftPad(y, size)...)...)
+			pub, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+			if err != nil {
+				continue
+			}
+			keys[k.Kid] = pub
+		}
+	}
+	v.keys = keys
+	return nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwsHashes are the signature algorithms accepted (RFC 7518).
+var jwsHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384,
+}
+
+func verifySignature(key crypto.PublicKey, alg string, hash crypto.Hash, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, sig, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(seg string, out any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// stringsClaim reads a claim that is a string or an array of strings.
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func leftPad(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b
+	}
+	return append(make([]byte, n-len(b)), b...)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIssuer is an OpenID provider publishing one RSA and one P-256 key.
+type testIssuer struct {
+	srv     *httptest.Server
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	fetches atomic.Int64 // key set fetches
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{}
+	var err error
+	iss.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	iss.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": iss.srv.URL, "jwks_uri": iss.srv.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		ecPoint, err := iss.ecKey.PublicKey.Bytes()
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(iss.rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(iss.rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		}})
+	})
+	iss.srv = httptest.NewServer(mux)
+	t.Cleanup(iss.srv.Close)
+	return iss
+}
+
+// token signs claims with alg: RS256 with the RSA key, ES256 with the EC
+// key; kid defaults to the key's.
+func (iss *testIssuer) token(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	if kid == "" {
+		kid = map[string]string{"RS256": "rsa", "ES256": "ec"}[alg]
+	}
+	seg := func(v any) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := seg(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + seg(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (iss *testIssuer) claims(extra map[string]any) map[string]any {
+	c := map[string]any{
+		"iss":   iss.srv.URL,
+		"aud":   []string{"tigerfetch", "other"},
+		"sub":   "u-123",
+		"email": "alice@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func (iss *testIssuer) config() config.APIOIDCConfig {
+	return config.APIOIDCConfig{
+		Issuer:   iss.srv.URL + "/",
+		Audience: "tigerfetch",
+		Viewers:  []string{"engineering"},
+		Analysts: []string{"secops"},
+		Admins:   []string{"secops-leads"},
+	}
+}
+
+func TestOIDCVerifier_Roles(t *testing.T) {
+	iss := newTestIssuer(t)
+	v, err := newOIDCVerifier(iss.config())
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		groups any
+		want   Role
+	}{
+		{[]string{"engineering"}, RoleViewer},
+		{[]string{"engineering", "secops"}, RoleAnalyst},
+		{"secops-leads", RoleAdmin},
+		{[]string{"marketing"}, RoleNone},
+		{nil, RoleNone},
+	} {
+		p, err := v.verify(t.Context(), iss.token(t, "RS256", "", iss.claims(map[string]any{"groups": tc.groups})))
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, p.role, "%v", tc.groups)
+		assert.Equal(t, "alice@example.com", p.name)
+		assert.Equal(t, "oidc", p.client)
+	}
+
+	p, err := v.verify(t.Context(), iss.token(t, "ES256", "", iss.claims(map[string]any{"email": nil, "groups": []string{"secops"}})))
+	require.NoError(t, err)
+	assert.Equal(t, RoleAnalyst, p.role)
+	assert.Equal(t, "u-123", p.name, "sub without the user claim")
+	assert.EqualValues(t, 1, iss.fetches.Load(), "keys are cached")
+
+	cfg := iss.config()
+	cfg.DefaultRole, cfg.GroupsClaim = "viewer", "roles"
+	v, err = newOIDCVerifier(cfg)
+	require.NoError(t, err)
+	p, err = v.verify(t.Context(), iss.token(t, "RS256", "", iss.claims(map[string]any{"roles": []string{"secops"}, "groups": []string{"secops-leads"}})))
+	require.NoError(t, err)
+	assert.Equal(t, RoleAnalyst, p.role, "groups_claim names the claim read")
+	p, err = v.verify(t.Context(), iss.token(t, "RS256", "", iss.claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, RoleViewer, p.role, "default_role")
+}
+
+func TestOIDCVerifier_Rejects(t *testing.T) {
+	iss := newTestIssuer(t)
+	v, err := newOIDCVerifier(iss.config())
+	require.NoError(t, err)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	good := iss.token(t, "RS256", "", iss.claims(nil))
+	parts := strings.Split(good, ".")
+	for name, token := range map[string]string{
+		"wrong issuer":    iss.token(t, "RS256", "", iss.claims(map[string]any{"iss": "https://evil.example"})),
+		"wrong audience":  iss.token(t, "RS256", "", iss.claims(map[string]any{"aud": "other"})),
+		"expired":         iss.token(t, "RS256", "", iss.claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})),
+		"no expiry":       iss.token(t, "RS256", "", iss.claims(map[string]any{"exp": nil})),
+		"not yet valid":   iss.token(t, "RS256", "", iss.claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"key of other":    iss.token(t, "RS256", "ec", iss.claims(nil)),
+		"alg none":        strings.Replace(good, parts[0], base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`)), 1),
+		"tampered claims": parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"x"}`)) + "." + parts[2],
+		"not a JWT":       "s3cret",
+	} {
+		_, err := v.verify(t.Context(), token)
+		assert.ErrorIs(t, err, errInvalidToken, name)
+	}
+
+	fetches := iss.fetches.Load()
+	_, err = v.verify(t.Context(), iss.token(t, "RS256", "rotated", iss.claims(nil)))
+	assert.ErrorIs(t, err, errInvalidToken)
+	assert.Equal(t, fetches, iss.fetches.Load(), "unknown kids refetch at most every minute")
+	now = now.Add(jwksRefetch)
+	_, err = v.verify(t.Context(), iss.token(t, "RS256", "rotated", iss.claims(nil)))
+	assert.ErrorIs(t, err, errInvalidToken)
+	assert.Equal(t, fetches+1, iss.fetches.Load())
+}
+
+func TestNewOIDCVerifier_Config(t *testing.T) {
+	v, err := newOIDCVerifier(config.APIOIDCConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = newOIDCVerifier(config.APIOIDCConfig{Issuer: "https://login.example.com"})
+	assert.Error(t, err, "audience required")
+	_, err = newOIDCVerifier(config.APIOIDCConfig{Issuer: "https://login.example.com", Audience: "a", DefaultRole: "root"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Version is the API contract version reported in the OpenAPI document.
+// Bump the minor version for additions and the major for breaking changes.
+const Version = "2.2.0"
+
+// OpenAPI returns the OpenAPI 3.0 document for the API, indented and with
+// stable key order so the checked-in copy diffs cleanly.
+func OpenAPI() ([]byte, error) {
+	return json.MarshalIndent((&Server{}).openAPIDoc(), "", "  ")
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPIDoc())
+}
+
+func (s *Server) openAPIDoc() map[string]any {
+	g := &schemaGen{schemas: map[string]any{}}
+	errRef := g.schema(reflect.TypeFor[errorResponse]())
+
+	paths := map[string]any{}
+	for _, op := range s.operations() {
+		success := map[string]any{"description": "OK"}
+		if len(op.media) > 0 {
+			content := map[string]any{}
+			for _, m := range op.media {
+				content[m] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+			}
+			success["content"] = content
+		} else {
+			success = jsonResponse("OK", g.schema(reflect.TypeOf(op.response)))
+		}
+		responses := map[string]any{
+			"200": success,
+			"401": jsonResponse("Missing or unknown API key or token (only when keys or an OIDC issuer are configured)", errRef),
+			"429": map[string]any{
+				"description": "Rate limit or daily quota exceeded",
+				"headers": map[string]any{
+					"Retry-After": header("Seconds until a retry can succeed"),
+				},
+				"content": jsonContent(errRef),
+			},
+		}
+		for _, status := range op.errors {
+			responses[strconv.Itoa(status)] = jsonResponse(http.StatusText(status), errRef)
+		}
+		role := max(op.role, RoleViewer)
+		responses["403"] = jsonResponse("The caller's role is below "+role.String(), errRef)
+
+		o := map[string]any{
+			"operationId":     op.id,
+			"summary":         op.summary,
+			"description":     "Requires the " + role.String() + " role or higher.",
+			"responses":       responses,
+			"x-required-role": role.String(),
+		}
+		if len(op.params) > 0 || strings.Contains(op.path, "{") {
+			var params []any
+			for _, seg := range strings.Split(op.path, "/") {
+				if name, ok := strings.CutPrefix(seg, "{"); ok {
+					params = append(params, map[string]any{
+						"name":     strings.TrimSuffix(name, "}"),
+						"in":       "path",
+						"required": true,
+						"schema":   map[string]any{"type": "string"},
+					})
+				}
+			}
+			for _, p := range op.params {
+				params = append(params, map[string]any{
+					"name":        p.name,
+					"in":          "query",
+					"description": p.desc,
+					"schema":      map[string]any{"type": p.typ},
+				})
+			}
+			o["parameters"] = params
+		}
+		if op.request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(g.schema(reflect.TypeOf(op.request))),
+			}
+		}
+
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "tigerfetch API",
+			"version":     Version,
+			"description": "Lookup API served on api.bind. Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		// Keys are optional server-side, so anonymous access is listed too.
+		"security": []any{
+			map[string]any{"bearer": []string{}},
+			map[string]any{"apiKey": []string{}},
+			map[string]any{},
+		},
+	}
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func jsonResponse(desc string, schema any) map[string]any {
+	return map[string]any{"description": desc, "content": jsonContent(schema)}
+}
+
+func header(desc string) map[string]any {
+	return map[string]any{"description": desc, "schema": map[string]any{"type": "integer"}}
+}
+
+// schemaGen derives JSON schemas from Go types using their json tags.
+// Named structs become components referenced by $ref.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]any{"description": "Record as stored, source-specific JSON"}
+	case t.Kind() == reflect.Pointer:
+		s := g.schema(t.Elem())
+		if _, isRef := s["$ref"]; !isRef {
+			s["nullable"] = true
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, done := g.schemas[name]; !done {
+			g.schemas[name] = nil // reserve, for recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for f := range t.Fields() {
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// schemaName is the package-qualified type name, e.g. enrich.Result becomes
+// "EnrichResult" and kev.Entry "KevEntry". Types in this package drop the
+// prefix: enrichRequest becomes "EnrichRequest".
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "api" {
+		return upperFirst(t.Name())
+	}
+	return upperFirst(pkg) + upperFirst(t.Name())
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPI_CheckedInSpecIsCurrent fails when the route table or response
+// types change without `make openapi`.
+func TestOpenAPI_CheckedInSpecIsCurrent(t *testing.T) {
+	want, err := OpenAPI()
+	require.NoError(t, err)
+	got, err := os.ReadFile("../../docs/openapi.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want)+"\n", string(got), "docs/openapi.json is stale; run `make openapi`")
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string                     `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                   `json:"required"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	raw, err := OpenAPI()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &doc))
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	op := doc.Paths["/enrich"]["post"]
+	assert.Equal(t, "enrich", op.OperationID)
+	for _, status := range []string{"200", "400", "401", "413", "429", "500"} {
+		assert.Contains(t, op.Responses, status)
+	}
+
+	result := doc.Components.Schemas["EnrichResult"]
+	assert.ElementsMatch(t, []string{"cve_id", "found", "in_kev"}, result.Required, "omitempty fields are optional")
+	assert.JSONEq(t, `{"$ref":"#/components/schemas/KevEntry"}`, string(result.Properties["kev"]))
+	assert.JSONEq(t, `{"type":"number","nullable":true}`, string(doc.Components.Schemas["EnrichNVD"].Properties["cvss_base"]))
+}
+
+func TestOpenAPI_ServedWithoutKey(t *testing.T) {
+	h := New(&stubEnricher{max: 1}, nil, config.APIConfig{Keys: []config.APIKeyConfig{{Name: "a", Key: "k"}}}).Handler()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"openapi":"3.0.3"`)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults applied when [api] leaves a limit at zero.
+const (
+	DefaultRatePerMinute = 60
+	idleClientTTL        = time.Hour
+)
+
+// Limit is the allowance for one client: a token bucket refilled at
+// PerMinute tokens a minute holding at most Burst, plus a request quota per
+// UTC day. PerMinute < 0 disables rate limiting; DailyQuota <= 0 means no
+// quota.
+type Limit struct {
+	PerMinute  int
+	Burst      int
+	DailyQuota int
+}
+
+// bucket returns the refill rate and capacity with defaults applied.
+func (l Limit) bucket() (perMinute, burst int) {
+	perMinute = l.PerMinute
+	if perMinute == 0 {
+		perMinute = DefaultRatePerMinute
+	}
+	burst = l.Burst
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return perMinute, burst
+}
+
+// Decision is the outcome of one Allow call, with the values for the
+// RateLimit-* response headers.
+type Decision struct {
+	Allowed    bool
+	Reason     string // "rate" or "quota" when not allowed
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // when not allowed
+
+	QuotaLimit     int // 0 when there is no quota
+	QuotaRemaining int
+}
+
+type clientState struct {
+	tokens   float64
+	last     time.Time
+	day      string
+	used     int
+	lastSeen time.Time
+}
+
+// Limiter tracks buckets and quotas per client ID in memory. Counts reset
+// when the process restarts, and each replica counts separately.
+type Limiter struct {
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewLimiter creates an empty Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{clients: make(map[string]*clientState), now: time.Now}
+}
+
+// Allow spends one request for id under lim.
+func (l *Limiter) Allow(id string, lim Limit) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	perMinute, burst := lim.bucket()
+
+	st := l.clients[id]
+	if st == nil {
+		st = &clientState{tokens: float64(burst), last: now}
+		l.clients[id] = st
+	}
+	st.lastSeen = now
+
+	d := Decision{Allowed: true}
+
+	// Daily quota, reset at UTC midnight.
+	if lim.DailyQuota > 0 {
+		today := now.UTC().Format(time.DateOnly)
+		if st.day != today {
+			st.day, st.used = today, 0
+		}
+		d.QuotaLimit = lim.DailyQuota
+		if st.used >= lim.DailyQuota {
+			d.Allowed, d.Reason = false, "quota"
+			d.RetryAfter = untilUTCMidnight(now)
+		}
+	}
+
+	// Token bucket.
+	if lim.PerMinute >= 0 {
+		rate := float64(perMinute) / 60 // tokens per second
+		st.tokens = math.Min(float64(burst), st.tokens+now.Sub(st.last).Seconds()*rate)
+		st.last = now
+
+		d.Limit = burst
+		if d.Allowed && st.tokens < 1 {
+			d.Allowed, d.Reason = false, "rate"
+			d.RetryAfter = time.Duration((1 - st.tokens) / rate * float64(time.Second))
+		}
+		if d.Allowed {
+			st.tokens--
+		}
+		d.Remaining = int(st.tokens)
+		d.Reset = time.Duration((float64(burst) - st.tokens) / rate * float64(time.Second))
+	}
+
+	if d.Allowed && lim.DailyQuota > 0 {
+		st.used++
+	}
+	if lim.DailyQuota > 0 {
+		d.QuotaRemaining = max(lim.DailyQuota-st.used, 0)
+	}
+	return d
+}
+
+// sweep drops clients idle for longer than idleClientTTL so per-address
+// entries for anonymous callers do not accumulate. Clients holding today's
+// quota count are kept, so going quiet does not reset a quota.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleClientTTL/4 {
+		return
+	}
+	l.lastSweep = now
+	today := now.UTC().Format(time.DateOnly)
+	for id, st := range l.clients {
+		if now.Sub(st.lastSeen) > idleClientTTL && st.day != today {
+			delete(l.clients, id)
+		}
+	}
+}
+
+func untilUTCMidnight(now time.Time) time.Duration {
+	u := now.UTC()
+	midnight := time.Date(u.Year(), u.Month(), u.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(u)
+}
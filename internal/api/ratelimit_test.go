@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(start time.Time) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: start}
+	l := NewLimiter()
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	l, clock := newTestLimiter(time.Date(2024, 1, 12, 10, 0, 0, 0, time.UTC))
+	lim := Limit{PerMinute: 60, Burst: 2}
+
+	d := l.Allow("a", lim)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Limit)
+	assert.Equal(t, 1, d.Remaining)
+	assert.True(t, l.Allow("a", lim).Allowed)
+
+	d = l.Allow("a", lim)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "rate", d.Reason)
+	assert.Equal(t, time.Second, d.RetryAfter, "one token per second at 60/min")
+
+	assert.True(t, l.Allow("b", lim).Allowed, "clients have separate buckets")
+
+	clock.advance(time.Second)
+	assert.True(t, l.Allow("a", lim).Allowed)
+}
+
+func TestLimiter_DailyQuota(t *testing.T) {
+	l, clock := newTestLimiter(time.Date(2024, 1, 12, 23, 0, 0, 0, time.UTC))
+	lim := Limit{PerMinute: -1, DailyQuota: 2}
+
+	d := l.Allow("a", lim)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 1, d.QuotaRemaining)
+	assert.Zero(t, d.Limit, "no bucket when rate limiting is disabled")
+	assert.True(t, l.Allow("a", lim).Allowed)
+
+	d = l.Allow("a", lim)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "quota", d.Reason)
+	assert.Equal(t, time.Hour, d.RetryAfter, "until UTC midnight")
+
+	clock.advance(2 * time.Hour)
+	assert.True(t, l.Allow("a", lim).Allowed, "quota resets at UTC midnight")
+}
+
+func TestLimiter_RejectedRequestsDoNotSpendQuota(t *testing.T) {
+	l, clock := newTestLimiter(time.Date(2024, 1, 12, 10, 0, 0, 0, time.UTC))
+	lim := Limit{PerMinute: 60, Burst: 1, DailyQuota: 5}
+
+	assert.True(t, l.Allow("a", lim).Allowed)
+	assert.False(t, l.Allow("a", lim).Allowed)
+	clock.advance(time.Second)
+	d := l.Allow("a", lim)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 3, d.QuotaRemaining)
+}
+
+func TestLimiter_SweepKeepsTodaysQuota(t *testing.T) {
+	l, clock := newTestLimiter(time.Date(2024, 1, 12, 1, 0, 0, 0, time.UTC))
+	l.Allow("anon", Limit{})
+	l.Allow("quota", Limit{DailyQuota: 10})
+
+	clock.advance(2 * time.Hour)
+	l.Allow("other", Limit{})
+	assert.NotContains(t, l.clients, "anon")
+	assert.Contains(t, l.clients, "quota")
+}
+
+func TestGuard_Keys(t *testing.T) {
+	srv := New(&stubEnricher{max: 10}, nil, config.APIConfig{
+		RatePerMinute: 60,
+		Keys: []config.APIKeyConfig{
+			{Name: "scanner", Key: "s3cret", Burst: 1},
+			{Name: "blank", Key: ""},
+		},
+	})
+	h := srv.Handler()
+	send := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/enrich", strings.NewReader(`{"cve_ids":["CVE-2024-0001"]}`))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "empty keys in config never match")
+	assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, send("X-API-Key", "wrong").Code)
+
+	rr = send("Authorization", "Bearer s3cret")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+
+	rr = send("X-API-Key", "s3cret")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "both headers identify the same key")
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "rate limit exceeded")
+}
+
+func TestGuard_AnonymousPerAddress(t *testing.T) {
+	h := New(&stubEnricher{max: 10}, nil, config.APIConfig{Burst: 1, DailyQuota: 100}).Handler()
+	send := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/enrich", strings.NewReader(`{"cve_ids":["CVE-2024-0001"]}`))
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("192.0.2.1:5000")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "100", rr.Header().Get("X-Quota-Limit"))
+	assert.Equal(t, "99", rr.Header().Get("X-Quota-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, send("192.0.2.1:5001").Code, "port is ignored")
+	assert.Equal(t, http.StatusOK, send("192.0.2.2:5000").Code)
+}
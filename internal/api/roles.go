@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"tiger2go/internal/metrics"
+)
+
+// Role is what a caller may do, each including the ones before it:
+// viewers read, analysts also triage alerts, admins also read the audit
+// trail.
+type Role int
+
+const (
+	RoleNone Role = iota // refused everything but the public routes
+	RoleViewer
+	RoleAnalyst
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleAnalyst:
+		return "analyst"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// ParseRole parses a configured role; empty means def.
+func ParseRole(s string, def Role) (Role, error) {
+	switch s {
+	case "":
+		return def, nil
+	case "viewer":
+		return RoleViewer, nil
+	case "analyst":
+		return RoleAnalyst, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q (viewer, analyst or admin)", s)
+}
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	name   string // key name, OIDC user, or "anonymous"
+	client string // metrics label: key name, "oidc" or "anonymous"
+	role   Role
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) principal {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p
+}
+
+// authorize refuses callers below role with 403. It runs after guard,
+// which sets the principal.
+func authorize(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := principalFrom(r.Context())
+		if p.role < role {
+			metrics.APIRejected.WithLabelValues(p.client, "forbidden").Inc()
+			writeError(w, http.StatusForbidden, "requires the "+role.String()+" role")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/alerting"
+	"tiger2go/internal/audit"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Triage serves the critical alert and audit routes. Acknowledging
+// writes, so it is backed by the primary pool, not the Store's replica.
+type Triage interface {
+	OpenAlerts(ctx context.Context) ([]alerting.CriticalAlert, error)
+	Acknowledge(ctx context.Context, ids []string, by string) ([]string, error)
+	AuditEvents(ctx context.Context, f audit.Filter) ([]audit.Event, error)
+}
+
+// PoolTriage is the Triage backed by the database, recording
+// acknowledgements in Audit as the API caller.
+type PoolTriage struct {
+	Pool  *pgxpool.Pool
+	Audit *audit.Log // nil: acknowledgements are not audited
+}
+
+func (p PoolTriage) OpenAlerts(ctx context.Context) ([]alerting.CriticalAlert, error) {
+	return alerting.OpenAlerts(ctx, p.Pool)
+}
+
+func (p PoolTriage) Acknowledge(ctx context.Context, ids []string, by string) ([]string, error) {
+	acked, err := alerting.Acknowledge(ctx, p.Pool, ids, by)
+	if err != nil {
+		return nil, err
+	}
+	trail := p.Audit.As(by)
+	for _, id := range acked {
+		if err := trail.Record(ctx, audit.AlertAck, id, map[string]any{"via": "api"}); err != nil {
+			return acked, err
+		}
+	}
+	return acked, nil
+}
+
+func (p PoolTriage) AuditEvents(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	return audit.List(ctx, p.Pool, f)
+}
+
+// maxAuditEvents caps one GET /audit response.
+const maxAuditEvents = 1000
+
+type alertList struct {
+	Alerts []alerting.CriticalAlert `json:"alerts"`
+}
+
+type ackRequest struct {
+	CveIDs []string `json:"cve_ids"`
+}
+
+type ackResponse struct {
+	Acknowledged []string `json:"acknowledged"`
+	NotOpen      []string `json:"not_open,omitempty"` // no open critical alert for these
+	Invalid      []string `json:"invalid,omitempty"`
+}
+
+type auditList struct {
+	Events []audit.Event `json:"events"`
+}
+
+var errNoTriage = errors.New("triage is not available on this instance")
+
+func (s *Server) handleOpenAlerts(w http.ResponseWriter, r *http.Request) {
+	if s.triage == nil {
+		writeError(w, http.StatusNotImplemented, errNoTriage.Error())
+		return
+	}
+	list, err := s.triage.OpenAlerts(r.Context())
+	if err != nil {
+		slog.Error("List open alerts failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	if list == nil {
+		list = []alerting.CriticalAlert{}
+	}
+	writeJSON(w, http.StatusOK, alertList{Alerts: list})
+}
+
+// handleAck acknowledges critical alerts as the caller, stopping their
+// escalation like `tigerfetch ack`.
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if s.triage == nil {
+		writeError(w, http.StatusNotImplemented, errNoTriage.Error())
+		return
+	}
+	var req ackRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	ids, invalid := enrich.Normalize(req.CveIDs)
+	if len(ids) == 0 {
+		writeError(w, http.StatusBadRequest, "cve_ids must hold at least one valid CVE ID")
+		return
+	}
+	resp := ackResponse{Acknowledged: []string{}, Invalid: invalid}
+
+	p := principalFrom(r.Context())
+	acked, err := s.triage.Acknowledge(r.Context(), ids, p.name)
+	if err != nil {
+		slog.Error("Acknowledge alerts failed", "by", p.name, "error", err)
+		writeError(w, http.StatusInternalServerError, "acknowledge failed")
+		return
+	}
+	for _, id := range ids {
+		if slices.Contains(acked, id) {
+			resp.Acknowledged = append(resp.Acknowledged, id)
+		} else {
+			resp.NotOpen = append(resp.NotOpen, id)
+		}
+	}
+	slog.Info("Critical alerts acknowledged over the API", "by", p.name, "count", len(acked))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if s.triage == nil {
+		writeError(w, http.StatusNotImplemented, errNoTriage.Error())
+		return
+	}
+	q := r.URL.Query()
+	win, err := window.Parse(q.Get("since"), q.Get("until"), "", time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxAuditEvents {
+			writeError(w, http.StatusBadRequest, "limit must be 1-"+strconv.Itoa(maxAuditEvents))
+			return
+		}
+	}
+	events, err := s.triage.AuditEvents(r.Context(), audit.Filter{
+		Since:  win.Since,
+		Until:  win.Until,
+		Action: strings.TrimSpace(q.Get("action")),
+		Actor:  q.Get("actor"),
+		Limit:  limit,
+	})
+	if err != nil {
+		slog.Error("List audit events failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "list failed")
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeJSON(w, http.StatusOK, auditList{Events: events})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/alerting"
+	"tiger2go/internal/audit"
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTriage struct {
+	open   []string
+	ackBy  string
+	filter audit.Filter
+}
+
+func (s *stubTriage) OpenAlerts(context.Context) ([]alerting.CriticalAlert, error) {
+	out := make([]alerting.CriticalAlert, len(s.open))
+	for i, id := range s.open {
+		out[i] = alerting.CriticalAlert{CVEID: id}
+	}
+	return out, nil
+}
+
+func (s *stubTriage) Acknowledge(_ context.Context, ids []string, by string) ([]string, error) {
+	s.ackBy = by
+	var acked []string
+	for _, id := range ids {
+		if i := slices.Index(s.open, id); i >= 0 {
+			s.open = slices.Delete(s.open, i, i+1)
+			acked = append(acked, id)
+		}
+	}
+	return acked, nil
+}
+
+func (s *stubTriage) AuditEvents(_ context.Context, f audit.Filter) ([]audit.Event, error) {
+	s.filter = f
+	return []audit.Event{{Actor: "alice", Action: audit.CursorReset, Target: "NVD"}}, nil
+}
+
+func roleServer(t *testing.T, cfg config.APIConfig) (http.Handler, *stubTriage) {
+	t.Helper()
+	triage := &stubTriage{open: []string{"CVE-2026-0001", "CVE-2026-0002"}}
+	srv := New(&stubEnricher{max: 10}, nil, cfg)
+	srv.SetTriage(triage)
+	return srv.Handler(), triage
+}
+
+func call(h http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRoles_Keys(t *testing.T) {
+	h, triage := roleServer(t, config.APIConfig{Keys: []config.APIKeyConfig{
+		{Name: "dashboard", Key: "view"},
+		{Name: "soc", Key: "triage", Role: "analyst"},
+		{Name: "auditor", Key: "all", Role: "admin"},
+		{Name: "typo", Key: "typo", Role: "superuser"},
+	}})
+	ack := `{"cve_ids":["cve-2026-0001","CVE-2026-0009","nope"]}`
+
+	assert.Equal(t, http.StatusOK, call(h, http.MethodGet, "/alerts", "view", "").Code)
+	rr := call(h, http.MethodPost, "/alerts/ack", "view", ack)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "requires the analyst role")
+	assert.Equal(t, http.StatusUnauthorized, call(h, http.MethodGet, "/alerts", "typo", "").Code, "a key with an invalid role is ignored")
+
+	rr = call(h, http.MethodPost, "/alerts/ack", "triage", ack)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp ackResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, ackResponse{Acknowledged: []string{"CVE-2026-0001"}, NotOpen: []string{"CVE-2026-0009"}, Invalid: []string{"nope"}}, resp)
+	assert.Equal(t, "soc", triage.ackBy, "acknowledged as the caller")
+	assert.Equal(t, []string{"CVE-2026-0002"}, triage.open)
+
+	assert.Equal(t, http.StatusForbidden, call(h, http.MethodGet, "/audit", "triage", "").Code)
+	rr = call(h, http.MethodGet, "/audit?since=24h&action=cursor.&limit=5", "all", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"action":"cursor.reset"`)
+	assert.Equal(t, "cursor.", triage.filter.Action)
+	assert.Equal(t, 5, triage.filter.Limit)
+	assert.False(t, triage.filter.Since.IsZero())
+	assert.Equal(t, http.StatusBadRequest, call(h, http.MethodGet, "/audit?limit=5000", "all", "").Code)
+}
+
+func TestRoles_AnonymousReadsOnly(t *testing.T) {
+	h, _ := roleServer(t, config.APIConfig{})
+	assert.Equal(t, http.StatusOK, call(h, http.MethodGet, "/alerts", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, call(h, http.MethodPost, "/alerts/ack", "", `{"cve_ids":["CVE-2026-0001"]}`).Code)
+	assert.Equal(t, http.StatusForbidden, call(h, http.MethodGet, "/audit", "", "").Code)
+}
+
+func TestRoles_OIDC(t *testing.T) {
+	iss := newTestIssuer(t)
+	h, triage := roleServer(t, config.APIConfig{
+		OIDC: iss.config(),
+		Keys: []config.APIKeyConfig{{Name: "dashboard", Key: "view"}},
+	})
+	analyst := iss.token(t, "RS256", "", iss.claims(map[string]any{"groups": []string{"secops"}}))
+	outsider := iss.token(t, "ES256", "", iss.claims(map[string]any{"groups": []string{"marketing"}}))
+
+	assert.Equal(t, http.StatusOK, call(h, http.MethodPost, "/alerts/ack", analyst, `{"cve_ids":["CVE-2026-0002"]}`).Code)
+	assert.Equal(t, "alice@example.com", triage.ackBy)
+	assert.Equal(t, http.StatusForbidden, call(h, http.MethodGet, "/cves", outsider, "").Code, "no mapped group, no access")
+	assert.Equal(t, http.StatusUnauthorized, call(h, http.MethodGet, "/alerts", analyst+"x", "").Code)
+	assert.Equal(t, http.StatusOK, call(h, http.MethodGet, "/alerts", "view", "").Code, "keys still work")
+}
+
+func TestTriage_NotConfigured(t *testing.T) {
+	h := New(&stubEnricher{max: 10}, nil, config.APIConfig{}).Handler()
+	assert.Equal(t, http.StatusNotImplemented, call(h, http.MethodGet, "/alerts", "", "").Code)
+}
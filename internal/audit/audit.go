@@ -0,0 +1,210 @@
+// Package audit keeps an append-only trail of significant actions — source
+// switches and cursor moves from the CLI, acknowledgements, webhook
+// notifications — with who did what and when, as compliance evidence.
+// Events go to the audit_log table, whose trigger rejects changes, or are
+// appended to a JSON Lines file. Each process records under a run ID of its
+// own, so the events of one invocation can be told apart.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Actions recorded.
+const (
+	SourceEnable  = "source.enable"
+	SourceDisable = "source.disable"
+	SourceRun     = "source.run"
+	CursorSet     = "cursor.set"
+	CursorReset   = "cursor.reset"
+	JobRetry      = "job.retry"
+	AlertAck      = "alert.ack"
+	Notify        = "notification.send"     // target: webhook name
+	Escalate      = "notification.escalate" // target: escalation webhook name
+)
+
+// Event is one recorded action.
+type Event struct {
+	Time   time.Time      `json:"time"`
+	Run    string         `json:"run"`
+	Actor  string         `json:"actor"`
+	Action string         `json:"action"`
+	Target string         `json:"target"`
+	Detail map[string]any `json:"detail,omitempty"`
+}
+
+// Log records events. A nil *Log records nothing, so components can take
+// one unconditionally.
+type Log struct {
+	db    *pgxpool.Pool
+	path  string
+	run   string
+	actor string
+	now   func() time.Time
+
+	mu *sync.Mutex // file appends; shared by the copies As makes
+}
+
+// New records events in db, or appends them to the file at path when path
+// is set, as actor.
+func New(db *pgxpool.Pool, path, actor string) *Log {
+	return &Log{db: db, path: path, run: newRun(), actor: actor, now: time.Now, mu: &sync.Mutex{}}
+}
+
+// FromConfig returns the Log of the [audit] settings, nil when disabled.
+// actor is who the process acts for: the CLI passes CurrentUser, the
+// daemon "" to record as audit.actor, or tigerfetch@<hostname> without one.
+func FromConfig(db *pgxpool.Pool, cfg config.AuditConfig, actor string) *Log {
+	if !cfg.Enabled {
+		return nil
+	}
+	if actor == "" {
+		actor = cfg.Actor
+	}
+	if actor == "" {
+		host, _ := os.Hostname()
+		actor = "tigerfetch@" + host
+	}
+	return New(db, cfg.Path, actor)
+}
+
+// CurrentUser is the actor of a CLI invocation: user@host of the process.
+func CurrentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
+
+// As returns a copy of l recording as actor, under the same run.
+func (l *Log) As(actor string) *Log {
+	if l == nil || actor == "" {
+		return l
+	}
+	c := *l
+	c.actor = actor
+	return &c
+}
+
+// Run is the run ID l records under.
+func (l *Log) Run() string {
+	if l == nil {
+		return ""
+	}
+	return l.run
+}
+
+// Record appends one event. detail holds what the action changed, e.g. a
+// cursor's old and new value; nil for none.
+func (l *Log) Record(ctx context.Context, action, target string, detail map[string]any) error {
+	if l == nil {
+		return nil
+	}
+	ev := Event{Time: l.now().UTC(), Run: l.run, Actor: l.actor, Action: action, Target: target, Detail: detail}
+	if l.path != "" {
+		return l.append(ev)
+	}
+	_, err := l.db.Exec(ctx, `
+		INSERT INTO audit_log (at, run, actor, action, target, detail)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, ev.Time, ev.Run, ev.Actor, ev.Action, ev.Target, ev.Detail)
+	if err != nil {
+		return fmt.Errorf("audit %s %s: %w", action, target, err)
+	}
+	return nil
+}
+
+// append writes ev as one line. O_APPEND keeps lines whole between
+// processes sharing the file; the mutex between goroutines of this one.
+func (l *Log) append(ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit %s %s: %w", ev.Action, ev.Target, err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("audit %s %s: %w", ev.Action, ev.Target, err)
+	}
+	return f.Close()
+}
+
+// Filter selects events for List.
+type Filter struct {
+	Since  time.Time // zero: from the start
+	Until  time.Time // zero: to now
+	Action string    // exact, or a prefix ending in "." such as "cursor."
+	Actor  string
+	Run    string
+	Limit  int // newest first; zero: no limit
+}
+
+// List returns the events of the audit_log table matching f, newest first.
+func List(ctx context.Context, db *pgxpool.Pool, f Filter) ([]Event, error) {
+	where, args := []string{"TRUE"}, []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "at >= "+arg(f.Since))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "at < "+arg(f.Until))
+	}
+	if strings.HasSuffix(f.Action, ".") {
+		where = append(where, "starts_with(action, "+arg(f.Action)+")")
+	} else if f.Action != "" {
+		where = append(where, "action = "+arg(f.Action))
+	}
+	if f.Actor != "" {
+		where = append(where, "actor = "+arg(f.Actor))
+	}
+	if f.Run != "" {
+		where = append(where, "run = "+arg(f.Run))
+	}
+	q := "SELECT at, run, actor, action, target, detail FROM audit_log WHERE " +
+		strings.Join(where, " AND ") + " ORDER BY at DESC, id DESC"
+	if f.Limit > 0 {
+		q += " LIMIT " + arg(f.Limit)
+	}
+	rows, err := db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+	list, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Event])
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+	return list, nil
+}
+
+func newRun() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var out []Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev))
+		out = append(out, ev)
+	}
+	require.NoError(t, sc.Err())
+	return out
+}
+
+func TestLog_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := New(nil, path, "alice@host")
+	ctx := context.Background()
+
+	require.NoError(t, l.Record(ctx, CursorSet, "NVD", map[string]any{"from": "a", "to": "b"}))
+	require.NoError(t, l.As("bob").Record(ctx, AlertAck, "CVE-2026-0001", nil))
+
+	events := readEvents(t, path)
+	require.Len(t, events, 2)
+	assert.Equal(t, "alice@host", events[0].Actor)
+	assert.Equal(t, CursorSet, events[0].Action)
+	assert.Equal(t, map[string]any{"from": "a", "to": "b"}, events[0].Detail)
+	assert.Equal(t, "bob", events[1].Actor)
+	assert.Nil(t, events[1].Detail)
+	assert.Equal(t, l.Run(), events[0].Run)
+	assert.Equal(t, l.Run(), events[1].Run, "As keeps the run")
+	assert.NotEqual(t, l.Run(), New(nil, path, "alice@host").Run())
+}
+
+func TestLog_FileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := New(nil, path, "tigerfetch@host")
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() { assert.NoError(t, l.Record(context.Background(), Notify, "soc", nil)) })
+	}
+	wg.Wait()
+	assert.Len(t, readEvents(t, path), 20, "one whole line per event")
+}
+
+func TestLog_Nil(t *testing.T) {
+	var l *Log
+	assert.NoError(t, l.Record(context.Background(), CursorReset, "NVD", nil))
+	assert.Nil(t, l.As("bob"))
+	assert.Empty(t, l.Run())
+	assert.Nil(t, FromConfig(nil, config.AuditConfig{}, "alice"))
+}
+
+func TestFromConfig_Actor(t *testing.T) {
+	assert.Equal(t, "alice", FromConfig(nil, config.AuditConfig{Enabled: true, Actor: "daemon"}, "alice").actor)
+	assert.Equal(t, "daemon", FromConfig(nil, config.AuditConfig{Enabled: true, Actor: "daemon"}, "").actor)
+	assert.Contains(t, FromConfig(nil, config.AuditConfig{Enabled: true}, "").actor, "tigerfetch@")
+}
+
+// TestLog_DB requires a running DB.
+func TestLog_DB(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	l := New(pool, "", "alice@host")
+	start := time.Now().Add(-time.Second)
+	require.NoError(t, l.Record(ctx, CursorReset, "NVD", map[string]any{"was": "2026-03-01T00:00:00Z"}))
+	require.NoError(t, l.Record(ctx, SourceDisable, "nvd", nil))
+
+	events, err := List(ctx, pool, Filter{Run: l.Run()})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, SourceDisable, events[0].Action, "newest first")
+	assert.Equal(t, map[string]any{"was": "2026-03-01T00:00:00Z"}, events[1].Detail)
+
+	events, err = List(ctx, pool, Filter{Run: l.Run(), Action: "cursor.", Since: start})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "NVD", events[0].Target)
+
+	_, err = pool.Exec(ctx, "UPDATE audit_log SET actor = 'mallory' WHERE run = $1", l.Run())
+	assert.ErrorContains(t, err, "append-only")
+	_, err = pool.Exec(ctx, "DELETE FROM audit_log WHERE run = $1", l.Run())
+	assert.ErrorContains(t, err, "append-only")
+}
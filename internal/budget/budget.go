@@ -0,0 +1,169 @@
+// Package budget caps one run of the daemon: upstream requests, wall time
+// and new advisories. The first cap reached cancels the run's context with
+// ErrExhausted, so running work stops the way it does on SIGTERM — jobs go
+// back to the queue, cursors keep their last completed window — and the
+// daemon exits cleanly for the next run to resume.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tiger2go/internal/config"
+)
+
+// ErrExhausted is the cause of a run context cancelled by its budget, and
+// the error of requests refused after it.
+var ErrExhausted = errors.New("run budget exhausted")
+
+// Limits are the caps of one run; zero disables a cap.
+type Limits struct {
+	MaxRequests      int64
+	MaxDuration      time.Duration
+	MaxNewAdvisories int64
+}
+
+// FromConfig reads the [limits] section.
+func FromConfig(cfg config.LimitsConfig) (Limits, error) {
+	if cfg.MaxRequests < 0 || cfg.MaxNewAdvisories < 0 {
+		return Limits{}, errors.New("limits.max_requests and limits.max_new_advisories must not be negative")
+	}
+	d, err := cfg.GetMaxDuration()
+	if err != nil || d < 0 {
+		return Limits{}, fmt.Errorf("limits.max_duration %q must be a positive duration", cfg.MaxDuration)
+	}
+	return Limits{MaxRequests: cfg.MaxRequests, MaxDuration: d, MaxNewAdvisories: cfg.MaxNewAdvisories}, nil
+}
+
+// Enabled reports whether any cap is set.
+func (l Limits) Enabled() bool {
+	return l.MaxRequests > 0 || l.MaxDuration > 0 || l.MaxNewAdvisories > 0
+}
+
+// Budget counts a run's spending against its Limits. A nil *Budget is
+// unlimited, so components can take one unconditionally.
+type Budget struct {
+	limits     Limits
+	cancel     context.CancelCauseFunc
+	requests   atomic.Int64
+	advisories atomic.Int64
+
+	mu     sync.Mutex
+	reason string // the cap that ended the run
+	timer  *time.Timer
+}
+
+// New returns a context that is cancelled with ErrExhausted when the first
+// of limits is reached, or when parent ends.
+func New(parent context.Context, limits Limits) (context.Context, *Budget) {
+	ctx, cancel := context.WithCancelCause(parent)
+	b := &Budget{limits: limits, cancel: cancel}
+	if limits.MaxDuration > 0 {
+		b.timer = time.AfterFunc(limits.MaxDuration, func() { b.exhaust("max_duration") })
+	}
+	return ctx, b
+}
+
+// Stop releases the wall-time timer.
+func (b *Budget) Stop() {
+	if b != nil && b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// Reason is the cap that ended the run, or "" while the budget lasts.
+func (b *Budget) Reason() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reason
+}
+
+// Requests is the number of upstream requests made so far.
+func (b *Budget) Requests() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.requests.Load()
+}
+
+// NewAdvisories is the number of new advisories stored so far.
+func (b *Budget) NewAdvisories() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.advisories.Load()
+}
+
+// AddNewAdvisory counts one advisory stored for the first time. The one
+// that reaches max_new_advisories is kept; the run ends after it.
+func (b *Budget) AddNewAdvisory() {
+	if b == nil {
+		return
+	}
+	if n := b.advisories.Add(1); b.limits.MaxNewAdvisories > 0 && n >= b.limits.MaxNewAdvisories {
+		b.exhaust("max_new_advisories")
+	}
+}
+
+// exhaust cancels the run. It runs before a refused request returns, so a
+// job that fails on ErrExhausted already sees its context cancelled and is
+// requeued rather than counted as a failed attempt.
+func (b *Budget) exhaust(reason string) {
+	b.mu.Lock()
+	first := b.reason == ""
+	if first {
+		b.reason = reason
+	}
+	b.mu.Unlock()
+	if first {
+		slog.Warn("Run budget exhausted, stopping", "limit", reason,
+			"requests", b.requests.Load(), "new_advisories", b.advisories.Load())
+		b.cancel(fmt.Errorf("%w: %s", ErrExhausted, reason))
+	}
+}
+
+// Transport counts requests through next (http.DefaultTransport when nil)
+// against max_requests; requests after the cap fail with ErrExhausted.
+// Wrapping a transport of the same budget again returns it unchanged, so a
+// client on a wrapped http.DefaultTransport is not counted twice. A nil
+// budget returns next as is.
+func (b *Budget) Transport(next http.RoundTripper) http.RoundTripper {
+	if b == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if t, ok := next.(*transport); ok && t.budget == b {
+		return t
+	}
+	return &transport{budget: b, next: next}
+}
+
+type transport struct {
+	budget *Budget
+	next   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.budget
+	n := b.requests.Add(1)
+	if max := b.limits.MaxRequests; max > 0 && n > max {
+		b.requests.Add(-1) // not made
+		b.exhaust("max_requests")
+		return nil, ErrExhausted
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Unwrap returns the wrapped transport.
+func (t *transport) Unwrap() http.RoundTripper { return t.next }
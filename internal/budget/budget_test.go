@@ -0,0 +1,90 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromConfig(t *testing.T) {
+	l, err := FromConfig(config.LimitsConfig{})
+	require.NoError(t, err)
+	assert.False(t, l.Enabled())
+
+	l, err = FromConfig(config.LimitsConfig{MaxDuration: "14m"})
+	require.NoError(t, err)
+	assert.Equal(t, Limits{MaxDuration: 14 * time.Minute}, l)
+	assert.True(t, l.Enabled())
+
+	for _, cfg := range []config.LimitsConfig{
+		{MaxRequests: -1},
+		{MaxNewAdvisories: -1},
+		{MaxDuration: "soon"},
+		{MaxDuration: "-1m"},
+	} {
+		_, err := FromConfig(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestMaxRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ctx, b := New(context.Background(), Limits{MaxRequests: 2})
+	defer b.Stop()
+	rt := b.Transport(nil)
+	assert.Same(t, rt, b.Transport(rt), "wrapping twice counts once")
+	client := &http.Client{Transport: rt}
+
+	for range 2 {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.NoError(t, ctx.Err())
+
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, ErrExhausted)
+	assert.ErrorIs(t, context.Cause(ctx), ErrExhausted)
+	assert.Equal(t, "max_requests", b.Reason())
+	assert.EqualValues(t, 2, b.Requests())
+}
+
+func TestMaxNewAdvisories(t *testing.T) {
+	ctx, b := New(context.Background(), Limits{MaxNewAdvisories: 3})
+	b.AddNewAdvisory()
+	b.AddNewAdvisory()
+	assert.NoError(t, ctx.Err())
+	b.AddNewAdvisory()
+	assert.Error(t, ctx.Err(), "the run ends with the last advisory it may store")
+	assert.Equal(t, "max_new_advisories", b.Reason())
+}
+
+func TestMaxDuration(t *testing.T) {
+	ctx, b := New(context.Background(), Limits{MaxDuration: 10 * time.Millisecond, MaxRequests: 1})
+	defer b.Stop()
+	<-ctx.Done()
+	assert.Equal(t, "max_duration", b.Reason())
+
+	_, err := (&http.Client{Transport: b.Transport(nil)}).Get("http://127.0.0.1:1")
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrExhausted), "the first request is within max_requests")
+	assert.Equal(t, "max_duration", b.Reason(), "the first cap reached is kept")
+}
+
+func TestNilBudget(t *testing.T) {
+	var b *Budget
+	b.AddNewAdvisory()
+	b.Stop()
+	assert.Empty(t, b.Reason())
+	assert.Same(t, http.DefaultTransport, b.Transport(http.DefaultTransport))
+}
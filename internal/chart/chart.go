@@ -0,0 +1,256 @@
+// Package chart draws the small charts notifications link to: the EPSS
+// trend of a CVE and the weekly volume of advisories. Charts render as SVG,
+// with a title and axis labels, or as PNG for clients that do not display
+// SVG, such as Slack image blocks. The PNG has no text (the standard library
+// has no font rasterizer); the title goes in the message around it.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kind is the chart type.
+type Kind int
+
+const (
+	Line Kind = iota // a trend over evenly spaced points, e.g. days
+	Bar              // one bar per point, e.g. weeks
+)
+
+// Point is one value of a chart.
+type Point struct {
+	Label string  `json:"label"` // x-axis label, e.g. "10-12"
+	Value float64 `json:"value"`
+}
+
+// Chart is a chart to render.
+type Chart struct {
+	Title  string
+	Kind   Kind
+	Points []Point
+	Max    float64              // top of the scale; 0 uses the largest value
+	Format func(float64) string // value labels of the SVG; nil prints the number
+}
+
+// Size limits of a rendered chart, in pixels.
+const (
+	MinSize = 32
+	MaxSize = 2000
+)
+
+var (
+	accent = color.RGBA{22, 60, 110, 255}
+	area   = color.RGBA{206, 218, 234, 255}
+	rule   = color.RGBA{200, 205, 212, 255}
+	muted  = color.RGBA{110, 110, 110, 255}
+)
+
+// frame is the plot area inside the image.
+type frame struct {
+	left, top, right, bottom float64
+	max                      float64
+}
+
+func (c Chart) frame(w, h int, text bool) frame {
+	f := frame{left: 4, top: 4, right: float64(w) - 4, bottom: float64(h) - 4, max: c.Max}
+	if text {
+		f.left, f.right, f.bottom = 44, float64(w)-10, float64(h)-20
+		if c.Title != "" {
+			f.top = 30
+		} else {
+			f.top = 10
+		}
+	}
+	if f.max <= 0 {
+		for _, p := range c.Points {
+			f.max = math.Max(f.max, p.Value)
+		}
+	}
+	if f.max <= 0 {
+		f.max = 1
+	}
+	return f
+}
+
+// y is the vertical position of value v.
+func (f frame) y(v float64) float64 {
+	v = math.Max(0, math.Min(v, f.max))
+	return f.bottom - (f.bottom-f.top)*v/f.max
+}
+
+// x is the horizontal centre of point i of n.
+func (f frame) x(kind Kind, i, n int) float64 {
+	width := f.right - f.left
+	if kind == Bar {
+		return f.left + width*(float64(i)+0.5)/float64(n)
+	}
+	if n == 1 {
+		return f.left + width/2
+	}
+	return f.left + width*float64(i)/float64(n-1)
+}
+
+// barWidth is the width of each of n bars.
+func (f frame) barWidth(n int) float64 {
+	return (f.right - f.left) / float64(n) * 0.7
+}
+
+func (c Chart) format(v float64) string {
+	if c.Format != nil {
+		return c.Format(v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// SVG renders c at w by h pixels.
+func SVG(c Chart, w, h int) []byte {
+	f := c.frame(w, h, true)
+	n := len(c.Points)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="Helvetica, Arial, sans-serif" font-size="11">`+"\n", w, h, w, h)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", w, h)
+	if c.Title != "" {
+		fmt.Fprintf(&b, `<text x="%s" y="18" font-size="13" font-weight="bold" fill="%s">%s</text>`+"\n",
+			num(f.left), hex(accent), html.EscapeString(c.Title))
+	}
+	// Scale: the baseline and the top of the scale, labelled on the left.
+	for _, v := range []float64{0, f.max} {
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s"/>`+"\n",
+			num(f.left), num(f.y(v)), num(f.right), num(f.y(v)), hex(rule))
+		fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="end" fill="%s">%s</text>`+"\n",
+			num(f.left-6), num(f.y(v)+4), hex(muted), html.EscapeString(c.format(v)))
+	}
+
+	switch {
+	case n == 0:
+	case c.Kind == Bar:
+		bw := f.barWidth(n)
+		for i, p := range c.Points {
+			top := f.y(p.Value)
+			fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"><title>%s: %s</title></rect>`+"\n",
+				num(f.x(Bar, i, n)-bw/2), num(top), num(bw), num(f.bottom-top), hex(accent),
+				html.EscapeString(p.Label), html.EscapeString(c.format(p.Value)))
+		}
+	default:
+		pts := make([]string, n)
+		for i, p := range c.Points {
+			pts[i] = num(f.x(Line, i, n)) + "," + num(f.y(p.Value))
+		}
+		fmt.Fprintf(&b, `<polygon points="%s,%s %s %s,%s" fill="%s"/>`+"\n",
+			num(f.x(Line, 0, n)), num(f.bottom), strings.Join(pts, " "), num(f.x(Line, n-1, n)), num(f.bottom), hex(area))
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2" stroke-linejoin="round"/>`+"\n",
+			strings.Join(pts, " "), hex(accent))
+		last := c.Points[n-1]
+		fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="3" fill="%s"><title>%s: %s</title></circle>`+"\n",
+			num(f.x(Line, n-1, n)), num(f.y(last.Value)), hex(accent),
+			html.EscapeString(last.Label), html.EscapeString(c.format(last.Value)))
+	}
+
+	// x-axis labels, thinned out to about one per 48 pixels.
+	step := max(1, int(math.Ceil(float64(n)*48/(f.right-f.left))))
+	for i := 0; i < n; i += step {
+		if c.Kind == Line && i+step >= n && i != n-1 {
+			i = n - 1 // a trend always shows its last date
+		}
+		fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			num(f.x(c.Kind, i, n)), num(f.bottom+14), hex(muted), html.EscapeString(c.Points[i].Label))
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// PNG renders c at w by h pixels, without text.
+func PNG(c Chart, w, h int) ([]byte, error) {
+	f := c.frame(w, h, false)
+	n := len(c.Points)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	fill(img, f.left, f.bottom, f.right, f.bottom+1, rule)
+
+	switch {
+	case n == 0:
+	case c.Kind == Bar:
+		bw := f.barWidth(n)
+		for i, p := range c.Points {
+			x := f.x(Bar, i, n)
+			fill(img, x-bw/2, f.y(p.Value), x+bw/2, f.bottom, accent)
+		}
+	default:
+		// Shade under the line column by column, then draw the line.
+		for px := int(f.left); px <= int(f.right); px++ {
+			if y, ok := lineAt(c, f, float64(px)); ok {
+				fill(img, float64(px), y, float64(px+1), f.bottom, area)
+			}
+		}
+		for i := 1; i < n; i++ {
+			stroke(img, f.x(Line, i-1, n), f.y(c.Points[i-1].Value), f.x(Line, i, n), f.y(c.Points[i].Value), 1.2, accent)
+		}
+		x, y := f.x(Line, n-1, n), f.y(c.Points[n-1].Value)
+		stroke(img, x, y, x, y, 2.5, accent)
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// lineAt interpolates the line of c at horizontal position x.
+func lineAt(c Chart, f frame, x float64) (float64, bool) {
+	n := len(c.Points)
+	if n == 1 {
+		return f.y(c.Points[0].Value), true
+	}
+	for i := 1; i < n; i++ {
+		x0, x1 := f.x(Line, i-1, n), f.x(Line, i, n)
+		if x >= x0 && x <= x1 {
+			t := (x - x0) / (x1 - x0)
+			return f.y(c.Points[i-1].Value)*(1-t) + f.y(c.Points[i].Value)*t, true
+		}
+	}
+	return 0, false
+}
+
+// fill paints the rectangle from x0, y0 to x1, y1.
+func fill(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	r := image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)))
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// stroke draws a line of round dots of radius r, which is good enough at
+// chart sizes and needs no anti-aliasing.
+func stroke(img *image.RGBA, x0, y0, x1, y1, r float64, c color.RGBA) {
+	steps := int(math.Hypot(x1-x0, y1-y0)*2) + 1
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(steps)
+		cx, cy := x0+(x1-x0)*t, y0+(y1-y0)*t
+		for py := int(cy - r); py <= int(cy+r); py++ {
+			for px := int(cx - r); px <= int(cx+r); px++ {
+				if math.Hypot(float64(px)+0.5-cx, float64(py)+0.5-cy) <= r+0.5 {
+					if image.Pt(px, py).In(img.Bounds()) {
+						img.SetRGBA(px, py, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
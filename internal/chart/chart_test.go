@@ -0,0 +1,76 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSVG_Bar(t *testing.T) {
+	c := Chart{Title: "Advisories <per> week", Kind: Bar, Points: []Point{{"09-28", 50}, {"10-05", 100}}}
+	out := SVG(c, 400, 200)
+	require.NoError(t, xml.Unmarshal(out, new(any)), "well-formed XML")
+
+	s := string(out)
+	assert.Contains(t, s, `width="400" height="200"`)
+	assert.Contains(t, s, ">Advisories &lt;per&gt; week</text>")
+	assert.Equal(t, 2, strings.Count(s, `fill="#163c6e"><title>`))
+	assert.Contains(t, s, "<title>10-05: 100</title>")
+	// The tallest bar reaches the top of the scale, the other half way.
+	assert.Contains(t, s, `y="30" width=`)
+	assert.Contains(t, s, `y="105" width=`)
+}
+
+func TestSVG_LineThinsLabels(t *testing.T) {
+	var points []Point
+	for d := range 30 {
+		points = append(points, Point{Label: time.Date(2026, 9, 13+d, 0, 0, 0, 0, time.UTC).Format("01-02"), Value: float64(d) / 100})
+	}
+	s := string(SVG(Chart{Kind: Line, Points: points, Max: 1}, 480, 200))
+
+	assert.Contains(t, s, "<polyline ")
+	assert.Contains(t, s, ">09-13</text>")
+	assert.Contains(t, s, ">10-12</text>", "the last date is always labelled")
+	assert.Less(t, strings.Count(s, `text-anchor="middle"`), 15)
+}
+
+func TestSVG_Empty(t *testing.T) {
+	out := SVG(Chart{Title: "Nothing"}, 200, 100)
+	require.NoError(t, xml.Unmarshal(out, new(any)))
+	assert.NotContains(t, string(out), "<polyline")
+}
+
+func TestPNG(t *testing.T) {
+	out, err := PNG(Chart{Kind: Bar, Points: []Point{{"a", 1}, {"b", 0}}}, 100, 50)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, 100, img.Bounds().Dx())
+
+	rgba := func(x, y int) color.RGBA {
+		r, g, b, a := img.At(x, y).RGBA()
+		return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+	}
+	assert.Equal(t, accent, rgba(27, 25), "inside the first bar")
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, rgba(73, 25), "no second bar")
+
+	out, err = PNG(Chart{Kind: Line, Points: []Point{{"a", 0.2}, {"b", 0.8}}, Max: 1}, 100, 50)
+	require.NoError(t, err)
+	img, err = png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, area, rgba(50, 44), "shaded under the line")
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, rgba(10, 5), "white above it")
+}
+
+func TestWeekly(t *testing.T) {
+	points := weekly(time.Date(2026, 10, 12, 6, 0, 0, 0, time.UTC), 3)
+	assert.Equal(t, []Point{{"09-21", 0}, {"09-28", 0}, {"10-05", 0}}, points)
+	assert.Len(t, weekly(time.Now(), 0), 1)
+}
@@ -0,0 +1,71 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const week = 7 * 24 * time.Hour
+
+// EPSSTrend is the daily EPSS score of cveID over the days up to its latest
+// score, oldest first. Days without a score are left out.
+func EPSSTrend(ctx context.Context, db *pgxpool.Pool, cveID string, days int) ([]Point, error) {
+	rows, err := db.Query(ctx, `
+		SELECT to_char(as_of, 'MM-DD'), epss::float8
+		FROM epss_daily
+		WHERE cve_id = $1
+		  AND as_of > (SELECT max(as_of) FROM epss_daily WHERE cve_id = $1) - $2::int
+		ORDER BY as_of
+	`, cveID, days)
+	if err != nil {
+		return nil, fmt.Errorf("read EPSS trend: %w", err)
+	}
+	defer rows.Close()
+	var out []Point
+	for rows.Next() {
+		var p Point
+		if err := rows.Scan(&p.Label, &p.Value); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// AdvisoryVolume counts the feed advisories first stored in each of the
+// weeks up to until, oldest first, labelled with the week's start date.
+func AdvisoryVolume(ctx context.Context, db *pgxpool.Pool, weeks int, until time.Time) ([]Point, error) {
+	out := weekly(until, weeks)
+	rows, err := db.Query(ctx, `
+		SELECT floor(extract(epoch FROM $1::timestamptz - first_seen_at) / 604800)::int, count(*)
+		FROM current
+		WHERE first_seen_at > $1 - make_interval(weeks => $2) AND first_seen_at <= $1
+		GROUP BY 1
+	`, until, weeks)
+	if err != nil {
+		return nil, fmt.Errorf("read advisory volume: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ago, n int
+		if err := rows.Scan(&ago, &n); err != nil {
+			return nil, err
+		}
+		if ago >= 0 && ago < len(out) {
+			out[len(out)-1-ago].Value = float64(n)
+		}
+	}
+	return out, rows.Err()
+}
+
+// weekly returns empty points for the weeks up to until, oldest first.
+func weekly(until time.Time, weeks int) []Point {
+	out := make([]Point, max(weeks, 1))
+	for i := range out {
+		out[i].Label = until.Add(-time.Duration(len(out)-i) * week).Format("01-02")
+	}
+	return out
+}
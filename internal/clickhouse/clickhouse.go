@@ -0,0 +1,200 @@
+// Package clickhouse is an insert-only analytics sink for high-volume score
+// history. EPSS publishes a score for every CVE every day (~100M rows a
+// year), which Postgres handles poorly for analytical scans; ClickHouse keeps
+// the same history columnar and compressed.
+//
+// It talks to ClickHouse's HTTP interface with JSONEachRow bodies, so no
+// driver dependency is needed. Postgres remains the source of truth.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/metrics"
+)
+
+const defaultDatabase = "tigerfetch"
+
+// Schema is the DDL applied by EnsureSchema. ReplacingMergeTree collapses
+// rows re-sent for the same key (a re-run day or a re-fetched NVD page)
+// during background merges; query with FINAL for exact counts.
+var Schema = []string{
+	`CREATE TABLE IF NOT EXISTS epss_daily (
+		as_of       Date,
+		cve_id      LowCardinality(String),
+		epss        Float64,
+		percentile  Float64,
+		inserted_at DateTime('UTC') DEFAULT now()
+	) ENGINE = ReplacingMergeTree(inserted_at)
+	PARTITION BY toYYYYMM(as_of)
+	ORDER BY (cve_id, as_of)`,
+	`CREATE TABLE IF NOT EXISTS cve_scores (
+		cve_id      String,
+		source      LowCardinality(String),
+		cvss_base   Nullable(Float64),
+		modified    DateTime('UTC'),
+		inserted_at DateTime('UTC') DEFAULT now()
+	) ENGINE = ReplacingMergeTree(inserted_at)
+	PARTITION BY toYear(modified)
+	ORDER BY (cve_id, source, modified)`,
+}
+
+// Client writes rows to ClickHouse. It implements cve.ScoreSink.
+type Client struct {
+	baseURL  string
+	database string
+	username string
+	password string
+	http     *http.Client
+}
+
+var _ cve.ScoreSink = (*Client)(nil)
+
+// New creates a client from configuration.
+func New(cfg config.ClickHouseConfig) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("clickhouse.url is required")
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return nil, fmt.Errorf("invalid clickhouse.url: %w", err)
+	}
+	timeout, err := cfg.GetTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid clickhouse.timeout: %w", err)
+	}
+	database := cfg.Database
+	if database == "" {
+		database = defaultDatabase
+	}
+	return &Client{
+		baseURL:  strings.TrimRight(cfg.URL, "/"),
+		database: database,
+		username: cfg.Username,
+		password: cfg.Password,
+		http:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name identifies the sink in logs and metrics.
+func (c *Client) Name() string { return "clickhouse" }
+
+// EnsureSchema creates the database and tables if they do not exist.
+func (c *Client) EnsureSchema(ctx context.Context) error {
+	if err := c.exec(ctx, "", "CREATE DATABASE IF NOT EXISTS "+c.database, nil); err != nil {
+		return err
+	}
+	for _, ddl := range Schema {
+		if err := c.exec(ctx, c.database, ddl, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type epssRow struct {
+	AsOf       string  `json:"as_of"`
+	CveID      string  `json:"cve_id"`
+	EPSS       float64 `json:"epss"`
+	Percentile float64 `json:"percentile"`
+}
+
+// InsertEpss appends one page of EPSS scores. Rows whose scores do not parse
+// as numbers are dropped, matching what Postgres would reject.
+func (c *Client) InsertEpss(ctx context.Context, asOf time.Time, rows []cve.EpssRow) error {
+	out := make([]any, 0, len(rows))
+	for _, r := range rows {
+		epss, err1 := strconv.ParseFloat(r.EPSS, 64)
+		pct, err2 := strconv.ParseFloat(r.Percentile, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		out = append(out, epssRow{AsOf: asOf.Format("2006-01-02"), CveID: r.CVE, EPSS: epss, Percentile: pct})
+	}
+	return c.insert(ctx, "epss_daily", out)
+}
+
+type scoreRow struct {
+	CveID    string   `json:"cve_id"`
+	Source   string   `json:"source"`
+	CvssBase *float64 `json:"cvss_base"`
+	Modified string   `json:"modified"`
+}
+
+// InsertCvssScores appends CVSS base scores keyed by lastModified.
+func (c *Client) InsertCvssScores(ctx context.Context, scores []cve.CvssScore) error {
+	out := make([]any, len(scores))
+	for i, s := range scores {
+		out[i] = scoreRow{
+			CveID:    s.CveID,
+			Source:   s.Source,
+			CvssBase: s.CvssBase,
+			Modified: s.Modified.UTC().Format(time.DateTime),
+		}
+	}
+	return c.insert(ctx, "cve_scores", out)
+}
+
+func (c *Client) insert(ctx context.Context, table string, rows []any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if err := c.exec(ctx, c.database, "INSERT INTO "+table+" FORMAT JSONEachRow", &body); err != nil {
+		return err
+	}
+	metrics.SinkRows.WithLabelValues(c.Name(), table).Add(float64(len(rows)))
+	return nil
+}
+
+// exec runs one statement. With a body, the statement goes in the query
+// string and the body carries the data, as ClickHouse's HTTP interface expects.
+func (c *Client) exec(ctx context.Context, database, query string, body io.Reader) error {
+	params := url.Values{}
+	if database != "" {
+		params.Set("database", database)
+	}
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/?"+params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.Header.Set("X-ClickHouse-User", c.username)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("clickhouse request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
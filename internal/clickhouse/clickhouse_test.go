@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captured struct {
+	query    string
+	database string
+	user     string
+	body     string
+}
+
+func newServer(t *testing.T, status int) (*httptest.Server, *[]captured) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []captured
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, captured{
+			query:    r.URL.Query().Get("query"),
+			database: r.URL.Query().Get("database"),
+			user:     r.Header.Get("X-ClickHouse-User"),
+			body:     string(body),
+		})
+		mu.Unlock()
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			_, _ = w.Write([]byte("Code: 60. DB::Exception: Table tigerfetch.epss_daily does not exist\n"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestInsertEpss(t *testing.T) {
+	srv, reqs := newServer(t, http.StatusOK)
+	c, err := New(config.ClickHouseConfig{URL: srv.URL + "/", Username: "writer", Password: "pw"})
+	require.NoError(t, err)
+
+	asOf := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	err = c.InsertEpss(context.Background(), asOf, []cve.EpssRow{
+		{CVE: "CVE-2023-46805", EPSS: "0.97", Percentile: "0.999"},
+		{CVE: "CVE-BAD", EPSS: "n/a", Percentile: "0.1"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *reqs, 1)
+	got := (*reqs)[0]
+	assert.Equal(t, "INSERT INTO epss_daily FORMAT JSONEachRow", got.query)
+	assert.Equal(t, "tigerfetch", got.database)
+	assert.Equal(t, "writer", got.user)
+	assert.Equal(t, `{"as_of":"2024-01-12","cve_id":"CVE-2023-46805","epss":0.97,"percentile":0.999}`+"\n", got.body)
+}
+
+func TestInsertCvssScores(t *testing.T) {
+	srv, reqs := newServer(t, http.StatusOK)
+	c, err := New(config.ClickHouseConfig{URL: srv.URL, Database: "intel"})
+	require.NoError(t, err)
+
+	score := 9.1
+	err = c.InsertCvssScores(context.Background(), []cve.CvssScore{
+		{CveID: "CVE-2024-21887", Source: "NVD", CvssBase: &score, Modified: time.Date(2024, 1, 22, 17, 15, 10, 0, time.UTC)},
+		{CveID: "CVE-2024-0001", Source: "NVD", Modified: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *reqs, 1)
+	assert.Equal(t, "intel", (*reqs)[0].database)
+	assert.Empty(t, (*reqs)[0].user)
+	lines := strings.Split(strings.TrimSpace((*reqs)[0].body), "\n")
+	assert.Equal(t, []string{
+		`{"cve_id":"CVE-2024-21887","source":"NVD","cvss_base":9.1,"modified":"2024-01-22 17:15:10"}`,
+		`{"cve_id":"CVE-2024-0001","source":"NVD","cvss_base":null,"modified":"2024-01-02 00:00:00"}`,
+	}, lines)
+}
+
+func TestInsert_ErrorIncludesServerMessage(t *testing.T) {
+	srv, _ := newServer(t, http.StatusNotFound)
+	c, err := New(config.ClickHouseConfig{URL: srv.URL})
+	require.NoError(t, err)
+
+	err = c.InsertEpss(context.Background(), time.Now(), []cve.EpssRow{{CVE: "CVE-1", EPSS: "0.1", Percentile: "0.2"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestEnsureSchema(t *testing.T) {
+	srv, reqs := newServer(t, http.StatusOK)
+	c, err := New(config.ClickHouseConfig{URL: srv.URL})
+	require.NoError(t, err)
+
+	require.NoError(t, c.EnsureSchema(context.Background()))
+	require.Len(t, *reqs, 1+len(Schema))
+	assert.Equal(t, "CREATE DATABASE IF NOT EXISTS tigerfetch", (*reqs)[0].body)
+	assert.Empty(t, (*reqs)[0].database)
+	assert.Contains(t, (*reqs)[1].body, "CREATE TABLE IF NOT EXISTS epss_daily")
+	assert.Equal(t, "tigerfetch", (*reqs)[1].database)
+}
+
+func TestNew_RequiresURL(t *testing.T) {
+	_, err := New(config.ClickHouseConfig{})
+	assert.Error(t, err)
+}
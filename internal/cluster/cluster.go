@@ -0,0 +1,237 @@
+// Package cluster correlates advisories from different sources into events.
+// Advisories that mention the same CVE, or CVEs of the same KEV product,
+// within a time window of each other belong to one event, so a digest can
+// say "Ivanti Connect Secure exploitation – 7 advisories from 5 sources"
+// instead of listing seven rows.
+package cluster
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/query"
+)
+
+// Defaults for Options.
+const (
+	DefaultWindow     = 72 * time.Hour
+	DefaultMinSources = 2
+)
+
+// Options tune the correlation.
+type Options struct {
+	Window     time.Duration // advisories further apart than this are not linked directly
+	MinSources int           // events need advisories from at least this many sources
+}
+
+// Event is a group of related advisories.
+type Event struct {
+	Title      string     `json:"title"`      // e.g. "Ivanti Connect Secure exploitation"
+	CVEs       []string   `json:"cves"`       // every CVE its advisories mention, sorted
+	Products   []string   `json:"products"`   // KEV products of those CVEs, most mentioned first
+	Sources    []string   `json:"sources"`    // sorted
+	First      time.Time  `json:"first"`      // earliest advisory date
+	Last       time.Time  `json:"last"`       // latest advisory date
+	Actors     []string   `json:"actors"`     // threat actors and ransomware families named, sorted
+	KEV        bool       `json:"kev"`        // any CVE is in KEV
+	Ransomware bool       `json:"ransomware"` // any advisory is tagged ransomware
+	EPSS       float64    `json:"epss"`       // highest EPSS score
+	CVSS       float64    `json:"cvss"`       // highest CVSS base score
+	Severity   string     `json:"severity"`   // NVD severity of the highest-scoring CVE
+	Advisories []Advisory `json:"advisories"`
+	Analysis   *Analysis  `json:"analysis,omitempty"` // LLM-written note, when [summarizer] is enabled
+}
+
+// Analysis is an analyst-style note on an event.
+type Analysis struct {
+	Summary string   `json:"summary"` // about three sentences
+	Actions []string `json:"actions"` // suggested next steps
+	Model   string   `json:"model"`   // provider and model that wrote it
+}
+
+// Advisory is one member of an event, newest first.
+type Advisory struct {
+	Source string    `json:"source"`
+	Title  string    `json:"title"`
+	Link   string    `json:"link"`
+	Date   time.Time `json:"date"`
+}
+
+// Headline is the one-line description of e used in digests.
+func (e Event) Headline() string {
+	return fmt.Sprintf("%s – %d advisories from %d sources", e.Title, len(e.Advisories), len(e.Sources))
+}
+
+// Key identifies e by its member advisories: the same advisories give the
+// same key, and a new member changes it.
+func (e Event) Key() string {
+	ids := make([]string, len(e.Advisories))
+	for i, a := range e.Advisories {
+		ids[i] = cmp.Or(a.Link, a.Source+"\x00"+a.Title)
+	}
+	slices.Sort(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Group clusters advisories into events. Two advisories are linked when
+// they share a CVE or a product and their dates (published, else first
+// seen) are at most opts.Window apart; links are transitive. Only groups of
+// two or more advisories from at least opts.MinSources sources are
+// returned, most sources first.
+func Group(advs []query.Advisory, opts Options) []Event {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.MinSources <= 0 {
+		opts.MinSources = DefaultMinSources
+	}
+
+	parent := make([]int, len(advs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	byKey := map[string][]int{}
+	for i, a := range advs {
+		for _, k := range keys(a) {
+			byKey[k] = append(byKey[k], i)
+		}
+	}
+	for _, members := range byKey {
+		slices.SortFunc(members, func(i, j int) int { return date(advs[i]).Compare(date(advs[j])) })
+		for n := 1; n < len(members); n++ {
+			prev, cur := members[n-1], members[n]
+			if date(advs[cur]).Sub(date(advs[prev])) <= opts.Window {
+				parent[find(cur)] = find(prev)
+			}
+		}
+	}
+
+	// An advisory repeated by an aggregator has the same canonical link:
+	// it is one member, from the feed that listed it first
+	groups := map[int][]int{}
+	links := map[string]bool{}
+	for i, a := range advs {
+		if a.Link != "" {
+			if links[a.Link] {
+				continue
+			}
+			links[a.Link] = true
+		}
+		r := find(i)
+		groups[r] = append(groups[r], i)
+	}
+
+	var events []Event
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		e := build(advs, members)
+		if len(e.Sources) >= opts.MinSources {
+			events = append(events, e)
+		}
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Sources), len(a.Sources)),
+			cmp.Compare(len(b.Advisories), len(a.Advisories)),
+			b.Last.Compare(a.Last),
+			strings.Compare(a.Title, b.Title),
+		)
+	})
+	return events
+}
+
+// keys are the values an advisory can be linked on.
+func keys(a query.Advisory) []string {
+	out := make([]string, 0, len(a.CVEs)+len(a.Products))
+	for _, id := range a.CVEs {
+		out = append(out, "cve:"+id)
+	}
+	for _, p := range a.Products {
+		out = append(out, "product:"+strings.ToLower(p))
+	}
+	return out
+}
+
+func date(a query.Advisory) time.Time {
+	if a.Published != nil {
+		return *a.Published
+	}
+	return a.FirstSeenAt
+}
+
+func build(advs []query.Advisory, members []int) Event {
+	var e Event
+	cves, sources, named := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	productCount := map[string]int{}
+	for _, i := range members {
+		a := advs[i]
+		d := date(a)
+		if e.First.IsZero() || d.Before(e.First) {
+			e.First = d
+		}
+		if d.After(e.Last) {
+			e.Last = d
+		}
+		e.Advisories = append(e.Advisories, Advisory{Source: a.Source, Title: a.Title, Link: a.Link, Date: d})
+		sources[a.Source] = true
+		for _, id := range a.CVEs {
+			cves[id] = true
+		}
+		for _, p := range a.Products {
+			productCount[p]++
+		}
+		for _, n := range a.Actors {
+			named[n] = true
+		}
+		e.KEV = e.KEV || a.KEV
+		e.Ransomware = e.Ransomware || a.Ransomware
+		e.EPSS = max(e.EPSS, a.EPSS)
+		if a.CVSS > e.CVSS || (a.CVSS == e.CVSS && e.Severity == "") {
+			e.CVSS, e.Severity = a.CVSS, a.Severity
+		}
+	}
+	slices.SortFunc(e.Advisories, func(a, b Advisory) int {
+		return cmp.Or(b.Date.Compare(a.Date), strings.Compare(a.Source, b.Source))
+	})
+	e.CVEs = slices.Sorted(maps.Keys(cves))
+	e.Sources = slices.Sorted(maps.Keys(sources))
+	e.Actors = slices.Sorted(maps.Keys(named))
+	e.Products = slices.Collect(maps.Keys(productCount))
+	slices.SortFunc(e.Products, func(a, b string) int {
+		return cmp.Or(cmp.Compare(productCount[b], productCount[a]), strings.Compare(a, b))
+	})
+	e.Title = title(e)
+	return e
+}
+
+// title names an event after its main product, else its CVEs.
+func title(e Event) string {
+	switch {
+	case len(e.Products) > 0 && e.KEV:
+		return e.Products[0] + " exploitation"
+	case len(e.Products) > 0:
+		return e.Products[0] + " vulnerabilities"
+	case len(e.CVEs) == 1:
+		return e.CVEs[0]
+	case len(e.CVEs) > 1:
+		return fmt.Sprintf("%s and %d more CVEs", e.CVEs[0], len(e.CVEs)-1)
+	}
+	return e.Advisories[0].Title
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"tiger2go/internal/query"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+	ivanti := []string{"Ivanti Connect Secure"}
+	advs := []query.Advisory{
+		{Source: "CISA", Title: "CISA adds Ivanti flaws to KEV", Published: day(10), CVEs: []string{"CVE-2023-46805", "CVE-2024-21887"}, Products: ivanti, KEV: true, CVSS: 9.1, Severity: "CRITICAL"},
+		{Source: "Volexity", Title: "Active exploitation of Ivanti Connect Secure", Published: day(10), CVEs: []string{"CVE-2024-21887"}, Products: ivanti, KEV: true, Actors: []string{"UNC5221"}},
+		{Source: "NCSC", Title: "Ivanti mitigation guidance", Published: day(12), Products: ivanti, Actors: []string{"Akira", "UNC5221"}, Ransomware: true},
+		{Source: "CISA", Title: "Ivanti emergency directive", Published: day(13), CVEs: []string{"CVE-2023-46805"}, Products: ivanti, KEV: true, EPSS: 0.97},
+		// Same CVE, but a month later: not linked to the burst above.
+		{Source: "Vendor", Title: "Ivanti follow-up", Published: day(31), CVEs: []string{"CVE-2024-21887"}},
+		// Two sources on an unrelated CVE.
+		{Source: "MSRC", Title: "CVE-2024-21412 advisory", Published: day(13), CVEs: []string{"CVE-2024-21412"}},
+		{Source: "ZDI", Title: "Internet Shortcut bypass", Published: day(14), CVEs: []string{"CVE-2024-21412"}},
+		// One source only.
+		{Source: "MSRC", Title: "Patch Tuesday", Published: day(9), CVEs: []string{"CVE-2024-0001"}},
+		{Source: "MSRC", Title: "Patch Tuesday revision", Published: day(10), CVEs: []string{"CVE-2024-0001"}},
+	}
+
+	events := Group(advs, Options{})
+	require.Len(t, events, 2)
+
+	e := events[0]
+	assert.Equal(t, "Ivanti Connect Secure exploitation", e.Title)
+	assert.Equal(t, "Ivanti Connect Secure exploitation – 4 advisories from 3 sources", e.Headline())
+	assert.Equal(t, []string{"CISA", "NCSC", "Volexity"}, e.Sources)
+	assert.Equal(t, []string{"CVE-2023-46805", "CVE-2024-21887"}, e.CVEs)
+	assert.Equal(t, []string{"Akira", "UNC5221"}, e.Actors)
+	assert.True(t, e.KEV)
+	assert.True(t, e.Ransomware)
+	assert.Equal(t, 0.97, e.EPSS)
+	assert.Equal(t, 9.1, e.CVSS)
+	assert.Equal(t, "CRITICAL", e.Severity)
+	assert.Equal(t, *day(10), e.First)
+	assert.Equal(t, *day(13), e.Last)
+	assert.Equal(t, "Ivanti emergency directive", e.Advisories[0].Title, "newest first")
+
+	assert.Equal(t, "CVE-2024-21412", events[1].Title)
+	assert.Len(t, events[1].Advisories, 2)
+
+	key := events[0].Key()
+	assert.Len(t, key, 32)
+	reversed := slices.Clone(advs)
+	slices.Reverse(reversed)
+	assert.Equal(t, key, Group(reversed, Options{})[0].Key(), "independent of input order")
+	assert.NotEqual(t, key, events[1].Key())
+
+	// A single source is enough when asked for.
+	events = Group(advs, Options{MinSources: 1})
+	assert.Len(t, events, 3)
+
+	// A longer window pulls the follow-up into the Ivanti event.
+	events = Group(advs, Options{Window: 30 * 24 * time.Hour})
+	assert.Len(t, events[0].Advisories, 5)
+}
+
+func TestGroup_UndatedUsesFirstSeen(t *testing.T) {
+	seen := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	events := Group([]query.Advisory{
+		{Source: "A", FirstSeenAt: seen, CVEs: []string{"CVE-2024-1"}},
+		{Source: "B", FirstSeenAt: seen.Add(time.Hour), CVEs: []string{"CVE-2024-1", "CVE-2024-2"}},
+	}, Options{})
+	require.Len(t, events, 1)
+	assert.Equal(t, "CVE-2024-1 and 1 more CVEs", events[0].Title)
+	assert.Equal(t, seen, events[0].First)
+}
+
+func TestGroup_SameLinkOnce(t *testing.T) {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	cves := []string{"CVE-2024-21412"}
+	advs := []query.Advisory{
+		{Source: "MSRC", Title: "CVE-2024-21412 advisory", Link: "https://msrc.microsoft.com/update-guide/vulnerability/CVE-2024-21412", Published: &day, CVEs: cves},
+		{Source: "Aggregator", Title: "CVE-2024-21412 advisory", Link: "https://msrc.microsoft.com/update-guide/vulnerability/CVE-2024-21412", Published: &day, CVEs: cves},
+	}
+	assert.Empty(t, Group(advs, Options{MinSources: 1}), "one advisory is no event")
+
+	advs = append(advs, query.Advisory{Source: "ZDI", Title: "Internet Shortcut bypass", Link: "https://zerodayinitiative.com/advisories/ZDI-24-001/", Published: &day, CVEs: cves})
+	events := Group(advs, Options{})
+	require.Len(t, events, 1)
+	assert.Len(t, events[0].Advisories, 2)
+	assert.Equal(t, []string{"MSRC", "ZDI"}, events[0].Sources)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,44 +12,128 @@ import (
 
 // Config holds the global application configuration.
 type Config struct {
-	DatabaseURL    string `mapstructure:"database_url"`
-	IngestInterval string `mapstructure:"ingest_interval"`
-	ServerBind     string `mapstructure:"server_bind"`
-	Feeds          []Feed `mapstructure:"feeds"`
+	DatabaseURL         string   `mapstructure:"database_url"`
+	IngestInterval      string   `mapstructure:"ingest_interval"`
+	ServerBind          string   `mapstructure:"server_bind"`
+	FeedMaxResponseMB   int      `mapstructure:"feed_max_response_mb"`  // 0 = default (16 MB)
+	FeedMaxItems        int      `mapstructure:"feed_max_items"`        // newest items stored per fetch; 0 = default (1000), negative no limit
+	FeedMaxItemAge      string   `mapstructure:"feed_max_item_age"`     // skip items dated longer ago than this; empty keeps all
+	AdvisoryIDNamespace string   `mapstructure:"advisory_id_namespace"` // UUID advisory IDs are derived in; empty is the built-in one
+	FeedWithdrawAfter   int      `mapstructure:"feed_withdraw_after"`   // fetches an item must be missing from its feed to be marked withdrawn; 0 never
+	FeedLinkRedirectors []string `mapstructure:"feed_link_redirectors"` // hosts whose item links are resolved, besides the built-in shorteners
+	FeedTrackingParams  []string `mapstructure:"feed_tracking_params"`  // query parameters removed from item links, besides utm_* and click IDs
+	Feeds               []Feed   `mapstructure:"feeds"`
+	AdvisoryBatchSize   int      `mapstructure:"advisory_batch_size"` // advisories loaded, enriched and matched at a time by query and iocs; 0 = default (500)
+
+	Database     DatabaseConfig     `mapstructure:"database"`
+	FeedSecurity FeedSecurityConfig `mapstructure:"feed_security"`
 
 	NVD      NvdConfig      `mapstructure:"nvd"`
 	EPSS     EpssConfig     `mapstructure:"epss"`
 	KEV      KevConfig      `mapstructure:"kev"`
 	Alerting AlertingConfig `mapstructure:"alerting"`
+
+	ClickHouse ClickHouseConfig `mapstructure:"clickhouse"`
+	API        APIConfig        `mapstructure:"api"`
+	Remote     RemoteConfig     `mapstructure:"remote"`
+	Inbound    InboundConfig    `mapstructure:"inbound"`
+	Mail       MailConfig       `mapstructure:"mail"`
+	Social     SocialConfig     `mapstructure:"social"`
+	Watchlist  WatchlistConfig  `mapstructure:"watchlist"`
+	Summary    SummaryConfig    `mapstructure:"summary"`
+	Display    DisplayConfig    `mapstructure:"display"`
+
+	ReferenceLabels ReferenceLabelsConfig `mapstructure:"reference_labels"`
+	Crawl           CrawlConfig           `mapstructure:"crawl"`
+	HTTP            HTTPConfig            `mapstructure:"http"`
+	Summarizer      SummarizerConfig      `mapstructure:"summarizer"`
+	ActorTags       ActorTagsConfig       `mapstructure:"actor_tags"`
+	Owners          []OwnerConfig         `mapstructure:"owners"`
+	Detections      DetectionsConfig      `mapstructure:"detections"`
+	EOL             EOLConfig             `mapstructure:"eol"`
+	Coverage        CoverageConfig        `mapstructure:"coverage"`
+	Merge           MergeConfig           `mapstructure:"merge"`
+	Scheduler       SchedulerConfig       `mapstructure:"scheduler"`
+	Jobs            JobsConfig            `mapstructure:"jobs"`
+	Limits          LimitsConfig          `mapstructure:"limits"`
+	Quality         QualityConfig         `mapstructure:"quality"`
+	Quarantine      QuarantineConfig      `mapstructure:"quarantine"`
+	RawArchive      RawArchiveConfig      `mapstructure:"raw_archive"`
+	Audit           AuditConfig           `mapstructure:"audit"`
+	Run             RunConfig             `mapstructure:"run"`
+	Profiling       ProfilingConfig       `mapstructure:"profiling"`
 }
 
 // Feed represents a single RSS/Atom source configuration.
 type Feed struct {
-	Name     string   `mapstructure:"name"`
-	URL      string   `mapstructure:"url"`
-	FeedType string   `mapstructure:"feed_type"`
-	Tags     []string `mapstructure:"tags"`
+	Name          string   `mapstructure:"name"`
+	URL           string   `mapstructure:"url"`
+	FeedType      string   `mapstructure:"feed_type"`
+	Tags          []string `mapstructure:"tags"`
+	MaxItemAge    string   `mapstructure:"max_item_age"`   // overrides feed_max_item_age; "-1s" keeps all
+	Backfill      bool     `mapstructure:"backfill"`       // ignore the item age cutoff and item limit, to load a feed's archive once
+	MaxItems      int      `mapstructure:"max_items"`      // overrides feed_max_items; negative no limit
+	WithdrawAfter int      `mapstructure:"withdraw_after"` // overrides feed_withdraw_after; negative never marks items withdrawn
+}
+
+// DatabaseConfig tunes query tracing and read routing. The primary
+// connection string stays in the top-level database_url.
+type DatabaseConfig struct {
+	ReadURL            string `mapstructure:"read_url"`             // optional read replica for reports and alert queries
+	SlowQueryThreshold string `mapstructure:"slow_query_threshold"` // default "1s"; "-1s" disables slow-query logs
+	LogStatements      bool   `mapstructure:"log_statements"`       // log every statement at DEBUG (needs LOG_LEVEL=debug)
+}
+
+// FeedSecurityConfig restricts which feed URLs may be fetched (SSRF guard).
+// The defaults allow http/https to any host, for trusted environments.
+type FeedSecurityConfig struct {
+	AllowedSchemes       []string `mapstructure:"allowed_schemes"`        // default ["http", "https"]
+	BlockPrivateNetworks bool     `mapstructure:"block_private_networks"` // refuse loopback/private/link-local targets
+	AllowedHosts         []string `mapstructure:"allowed_hosts"`          // ".example.com" matches subdomains
+	MaxRedirects         int      `mapstructure:"max_redirects"`          // 0 = 10, negative disables redirects
 }
 
 type NvdConfig struct {
-	Enabled      bool   `mapstructure:"enabled"`
-	PollInterval string `mapstructure:"poll_interval"`
-	PageSize     int    `mapstructure:"page_size"`
-	ApiKey       string `mapstructure:"api_key"`
-	URL          string `mapstructure:"url"`
+	Enabled       bool   `mapstructure:"enabled"`
+	PollInterval  string `mapstructure:"poll_interval"`
+	PageSize      int    `mapstructure:"page_size"`
+	ApiKey        string `mapstructure:"api_key"`
+	URL           string `mapstructure:"url"`
+	MaxResponseMB int    `mapstructure:"max_response_mb"` // 0 = default (64 MB)
+	CursorOverlap string `mapstructure:"cursor_overlap"`  // previous window re-fetched each run, default "15m"
+
+	// Product monitor: pull CVEs matching these products whether or not a
+	// feed mentioned them. Runs when products are set, even with enabled off.
+	Products             []NvdProduct `mapstructure:"products"`
+	ProductsPollInterval string       `mapstructure:"products_poll_interval"` // default "6h"
+	ProductsLookback     string       `mapstructure:"products_lookback"`      // first run's look-back, default "720h"
+}
+
+// NvdProduct is one monitored product. Set at least one of CPE, CPEMatch
+// and Keyword; together they narrow the match.
+type NvdProduct struct {
+	Name       string `mapstructure:"name"`
+	CPE        string `mapstructure:"cpe"`         // full CPE 2.3 name (NVD cpeName)
+	CPEMatch   string `mapstructure:"cpe_match"`   // CPE prefix such as "cpe:2.3:a:ivanti:connect_secure" (virtualMatchString)
+	Keyword    string `mapstructure:"keyword"`     // words in the description (keywordSearch)
+	ExactMatch bool   `mapstructure:"exact_match"` // treat keyword as a phrase
 }
 
 type EpssConfig struct {
-	Enabled      bool   `mapstructure:"enabled"`
-	PollInterval string `mapstructure:"poll_interval"`
-	URL          string `mapstructure:"url"`
-	PageSize     int    `mapstructure:"page_size"`
+	Enabled       bool   `mapstructure:"enabled"`
+	PollInterval  string `mapstructure:"poll_interval"`
+	URL           string `mapstructure:"url"`
+	PageSize      int    `mapstructure:"page_size"`
+	Concurrency   int    `mapstructure:"concurrency"`     // pages fetched at once, 0 = default (4)
+	MaxResponseMB int    `mapstructure:"max_response_mb"` // 0 = default (32 MB)
 }
 
 type KevConfig struct {
-	Enabled      bool   `mapstructure:"enabled"`
-	PollInterval string `mapstructure:"poll_interval"`
-	URL          string `mapstructure:"url"`
+	Enabled       bool   `mapstructure:"enabled"`
+	PollInterval  string `mapstructure:"poll_interval"`
+	URL           string `mapstructure:"url"`
+	MaxResponseMB int    `mapstructure:"max_response_mb"` // 0 = default (32 MB)
+	CacheRefresh  string `mapstructure:"cache_refresh"`   // in-memory KEV set reload interval, default "10m"
 }
 
 type AlertingConfig struct {
@@ -55,12 +141,565 @@ type AlertingConfig struct {
 	PollInterval string          `mapstructure:"poll_interval"`
 	Webhooks     []WebhookConfig `mapstructure:"webhooks"`
 	LookbackDays int             `mapstructure:"lookback_days"`
+	KevAdditions bool            `mapstructure:"kev_additions"` // notify when CVEs are newly added to KEV
+
+	// Delivery defaults of every webhook; see WebhookConfig.
+	Cooldown      string `mapstructure:"cooldown"`        // a CVE notified to a webhook is not resent within this, e.g. "72h"; empty resends
+	DigestSize    int    `mapstructure:"digest_size"`     // queue notifications and send them together once this many wait; 0 sends every run
+	DigestMaxWait string `mapstructure:"digest_max_wait"` // send a smaller digest once its oldest item waited this long, default "24h"
+
+	Escalation EscalationConfig `mapstructure:"escalation"`
+
+	// ChartURL is the externally reachable base URL of the API (e.g.
+	// "https://tigerfetch.example.com"); when set, notifications embed its
+	// /charts images.
+	ChartURL string `mapstructure:"chart_url"`
+}
+
+// EscalationConfig re-notifies critical alerts nobody acknowledged: KEV
+// additions affecting a monitored product ([[nvd.products]]) that are not
+// acknowledged with `tigerfetch ack` within After go to Webhooks, a
+// secondary channel such as a manager's Slack channel or PagerDuty.
+type EscalationConfig struct {
+	Enabled  bool            `mapstructure:"enabled"`
+	After    string          `mapstructure:"after"` // default "4h"
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// GetAfter parses After; empty means 4h.
+func (c *EscalationConfig) GetAfter() (time.Duration, error) {
+	if c.After == "" {
+		return 4 * time.Hour, nil
+	}
+	return time.ParseDuration(c.After)
+}
+
+// ClickHouseConfig enables the insert-only analytics sink for EPSS history
+// and CVSS score history, written over ClickHouse's HTTP interface.
+type ClickHouseConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	URL      string `mapstructure:"url"`      // e.g. "http://clickhouse:8123"
+	Database string `mapstructure:"database"` // default "tigerfetch"
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Timeout  string `mapstructure:"timeout"` // default "30s"
+}
+
+// APIConfig enables the lookup API (POST /enrich) on its own listener.
+type APIConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Bind             string `mapstructure:"bind"`              // default "0.0.0.0:9102"
+	MaxCVEs          int    `mapstructure:"max_cves"`          // IDs per request, 0 = default (500)
+	UpstreamFallback bool   `mapstructure:"upstream_fallback"` // fetch CVEs missing locally from NVD/EPSS
+	MaxUpstream      int    `mapstructure:"max_upstream"`      // upstream lookups per request, 0 = default (20)
+
+	// Per-client limits. With keys configured every request needs a key and
+	// is limited per key; without, clients are limited per remote address.
+	RatePerMinute int            `mapstructure:"rate_per_minute"` // 0 = default (60), negative disables
+	Burst         int            `mapstructure:"burst"`           // 0 = rate_per_minute
+	DailyQuota    int            `mapstructure:"daily_quota"`     // requests per UTC day, 0 = unlimited
+	Keys          []APIKeyConfig `mapstructure:"keys"`
+	PublicCharts  bool           `mapstructure:"public_charts"` // serve /charts/* without a key, for chat clients fetching images
+
+	ListSettle string `mapstructure:"list_settle"` // hold back rows changed this recently from list pages, default "1m"; "-1s" disables
+
+	OIDC APIOIDCConfig `mapstructure:"oidc"`
+}
+
+// APIKeyConfig is one API client. Zero limits inherit the [api] values.
+type APIKeyConfig struct {
+	Name          string `mapstructure:"name"` // used in logs and metrics, never the key
+	Key           string `mapstructure:"key"`
+	RatePerMinute int    `mapstructure:"rate_per_minute"`
+	Burst         int    `mapstructure:"burst"`
+	DailyQuota    int    `mapstructure:"daily_quota"`
+	Role          string `mapstructure:"role"` // "viewer" (default), "analyst" or "admin"
+}
+
+// APIOIDCConfig accepts OpenID Connect ID or access tokens as bearer
+// credentials besides the [[api.keys]], mapping the groups they carry to
+// roles. A token's role is the highest any of its groups maps to.
+type APIOIDCConfig struct {
+	Issuer      string   `mapstructure:"issuer"`       // e.g. "https://login.example.com/realms/sec"; empty disables
+	Audience    string   `mapstructure:"audience"`     // the aud tokens must carry, usually the client ID
+	GroupsClaim string   `mapstructure:"groups_claim"` // default "groups"
+	UserClaim   string   `mapstructure:"user_claim"`   // names the caller in logs and the audit trail, default "email", then sub
+	DefaultRole string   `mapstructure:"default_role"` // role of a token in no mapped group; empty refuses it
+	Viewers     []string `mapstructure:"viewers"`      // groups granted the viewer role
+	Analysts    []string `mapstructure:"analysts"`     // groups granted the analyst role
+	Admins      []string `mapstructure:"admins"`       // groups granted the admin role
+}
+
+// RemoteConfig makes another tigerfetch instance a source: its /changes
+// feed is pulled into this database, so edge or air-gapped installs never
+// reach the public upstreams. The TLS settings secure the federation link.
+type RemoteConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	URL          string `mapstructure:"url"`           // central API base, e.g. "https://central:9102"
+	APIKey       string `mapstructure:"api_key"`       // one of the central instance's [[api.keys]]
+	PollInterval string `mapstructure:"poll_interval"` // default "15m"
+	PageSize     int    `mapstructure:"page_size"`     // changes per request, 0 = 1000
+	Timeout      string `mapstructure:"timeout"`       // per request, default "1m"
+
+	CAFile     string   `mapstructure:"ca_file"`     // PEM roots trusted instead of the system pool
+	CertFile   string   `mapstructure:"cert_file"`   // client certificate for mutual TLS
+	KeyFile    string   `mapstructure:"key_file"`    // its private key
+	ServerName string   `mapstructure:"server_name"` // name to verify when it differs from the URL host
+	PinSHA256  []string `mapstructure:"pin_sha256"`  // base64 SHA-256 of a SubjectPublicKeyInfo in the server's chain
+}
+
+// DisplayConfig localizes human-readable output: summary and report
+// tables and Slack digests. JSON and CSV output are not affected, and
+// timestamps are always stored in UTC.
+type DisplayConfig struct {
+	Language string `mapstructure:"language"` // "en" (default), "de" or "fr"; --lang overrides it per run
+	Timezone string `mapstructure:"timezone"` // IANA name such as "Europe/Berlin", or "Local"; default "UTC"; --tz overrides it per run
+}
+
+// GetTimezone loads Timezone; empty means UTC.
+func (d DisplayConfig) GetTimezone() (*time.Location, error) {
+	return LoadTimezone(d.Timezone)
+}
+
+// LoadTimezone loads an IANA time zone name, "Local" for the server's
+// zone; empty means UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// SummaryConfig shapes `tigerfetch summary`: which sections it shows, what
+// counts as critical and how items are ranked. Zero values use the defaults.
+type SummaryConfig struct {
+	Window       string   `mapstructure:"window"`         // look-back period, default "24h"
+	Sections     []string `mapstructure:"sections"`       // "kev", "epss_movers", "critical", "events"; default all, in that order
+	MaxItems     int      `mapstructure:"max_items"`      // per section, default 10
+	Sort         string   `mapstructure:"sort"`           // "risk" (default), "epss", "cvss" or "date"
+	CriticalCVSS float64  `mapstructure:"critical_cvss"`  // CVSS base score that makes a CVE critical, default 9.0
+	CriticalEPSS float64  `mapstructure:"critical_epss"`  // EPSS score that makes a CVE critical, default 0.5
+	MinEPSSDelta float64  `mapstructure:"min_epss_delta"` // smallest EPSS rise listed as a mover, default 0.1
+
+	EventWindow     string `mapstructure:"event_window"`      // max gap between linked advisories of an event, default "72h"
+	EventMinSources int    `mapstructure:"event_min_sources"` // sources an event needs, default 2
+}
+
+// ReferenceLabelsConfig enables resolving the page titles of NVD references
+// tagged "Vendor Advisory". Requests go out one at a time under the
+// [feed_security] URL policy.
+type ReferenceLabelsConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	PollInterval string `mapstructure:"poll_interval"` // default "1h"
+	BatchSize    int    `mapstructure:"batch_size"`    // URLs per run, 0 = 200
+	HostDelay    string `mapstructure:"host_delay"`    // crawl.host_delay when that is unset, from before [crawl]
+	RetryAfter   string `mapstructure:"retry_after"`   // retry failed URLs after this long, default "168h"
+}
+
+// CrawlConfig makes fetches of pages that are not feeds polite: reference
+// labels and feed backfills (see internal/crawl).
+type CrawlConfig struct {
+	Robots    bool     `mapstructure:"robots"`     // honour robots.txt, default true
+	HostDelay string   `mapstructure:"host_delay"` // pause between requests to one host, default "5s"
+	RobotsTTL string   `mapstructure:"robots_ttl"` // how long a robots.txt is cached, default "24h"
+	OwnHosts  []string `mapstructure:"own_hosts"`  // hosts exempt from all of it; ".example.com" matches subdomains
+}
+
+// HTTPConfig tunes the outbound HTTP layer shared by every upstream
+// fetcher.
+type HTTPConfig struct {
+	RateLimitHeaders bool   `mapstructure:"rate_limit_headers"`  // pace requests by Retry-After and X-RateLimit-* headers, default true
+	MaxRateLimitWait string `mapstructure:"max_rate_limit_wait"` // longest a request waits for a host's limit, default "1m"; beyond it the request fails
+
+	// Connection pool shared by the upstream fetchers
+	MaxIdleConns        int    `mapstructure:"max_idle_conns"`          // idle connections kept across hosts, default 100
+	MaxIdleConnsPerHost int    `mapstructure:"max_idle_conns_per_host"` // idle connections kept per host, default 16
+	IdleConnTimeout     string `mapstructure:"idle_conn_timeout"`       // how long an idle connection is kept, default "90s"
+
+	// Hosts are resolved by tigerfetch itself when either of these is set
+	DNSCacheTTL string   `mapstructure:"dns_cache_ttl"` // how long an answer is reused, e.g. "5m"; "0s" for none
+	DNSServers  []string `mapstructure:"dns_servers"`   // asked in order when the system resolver fails, e.g. ["1.1.1.1", "8.8.8.8:53"]
+	DNSRetries  int      `mapstructure:"dns_retries"`   // further attempts after a temporary failure, default 2; negative for none
+	DNSStaleFor string   `mapstructure:"dns_stale_for"` // how long past its TTL an answer stands in when lookups fail, default "1h"; "0s" never
+}
+
+// DetectionsConfig enables cross-referencing CVEs with public detection
+// rules: Sigma rules, Nuclei templates and Emerging Threats Open signatures.
+type DetectionsConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	PollInterval  string   `mapstructure:"poll_interval"`   // default "24h"
+	Sources       []string `mapstructure:"sources"`         // default all: "sigma", "nuclei", "etopen"
+	SigmaURL      string   `mapstructure:"sigma_url"`       // zip of the Sigma repository
+	NucleiURL     string   `mapstructure:"nuclei_url"`      // nuclei-templates cves.json
+	ETOpenURL     string   `mapstructure:"etopen_url"`      // ET Open Suricata rules file
+	MaxResponseMB int      `mapstructure:"max_response_mb"` // 0 = default (256 MB)
+}
+
+// EOLConfig enables flagging CVEs in product releases past end of life,
+// from the endoflife.date dataset.
+type EOLConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	PollInterval  string `mapstructure:"poll_interval"`   // default "24h"
+	URL           string `mapstructure:"url"`             // default the endoflife.date API's full product list
+	MaxResponseMB int    `mapstructure:"max_response_mb"` // 0 = default (64 MB)
+}
+
+// CoverageConfig enables tracking CVEs that advisories reference before
+// EPSS scores them or NVD publishes them.
+type CoverageConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	PollInterval string `mapstructure:"poll_interval"` // default "1h"
+	Lookback     string `mapstructure:"lookback"`      // advisories first seen this long ago or later, default "720h"
+}
+
+// MergeConfig decides which source wins when several describe the same
+// field of a CVE, and how often the consolidated records are rebuilt.
+type MergeConfig struct {
+	CVSS         []string `mapstructure:"cvss"`          // CVSS score sources by precedence: "nvd", "cna", "adp", "other"; default NVD's order
+	PollInterval string   `mapstructure:"poll_interval"` // consolidation of changed CVEs; default 5m
+}
+
+// SchedulerConfig shares a concurrency budget among the daemon's runners
+// (NVD, KEV, EPSS, feeds, ...), so a long NVD backfill cannot hold every
+// slot while KEV waits. Zero max_concurrent leaves the runners unlimited.
+type SchedulerConfig struct {
+	MaxConcurrent int                        `mapstructure:"max_concurrent"` // runs at once across all sources; 0 = unlimited
+	Reserved      int                        `mapstructure:"reserved"`       // of those, slots only critical sources may take; default 1 when max_concurrent > 1
+	Critical      []string                   `mapstructure:"critical"`       // sources that may use reserved slots; default ["kev", "alerting"]
+	Sources       map[string]SchedulerSource `mapstructure:"sources"`        // per-source overrides, keyed by source name
+}
+
+// SchedulerSource tunes one source. Zero values keep the built-in defaults.
+type SchedulerSource struct {
+	Priority      int     `mapstructure:"priority"`       // waiting runs of higher priority start first
+	Weight        float64 `mapstructure:"weight"`         // share among sources of equal priority, default 1
+	MaxConcurrent int     `mapstructure:"max_concurrent"` // runs of this source at once; 0 = up to the budget
+}
+
+// JobsConfig tunes the ingestion job queue. NVD, KEV, EPSS, remote and
+// feed runs are persisted as jobs and retried with exponential backoff;
+// a job that fails max_attempts times is dead until retried by hand.
+// Backend "river" or "asynq" hands the jobs to an existing River
+// (Postgres) or Asynq (Redis) deployment instead of the jobs table.
+type JobsConfig struct {
+	Backend      string `mapstructure:"backend"`       // "postgres" (default, the jobs table), "river" or "asynq"
+	Queue        string `mapstructure:"queue"`         // River or Asynq queue name, default "tigerfetch"
+	RedisURL     string `mapstructure:"redis_url"`     // Asynq's Redis, e.g. "redis://:pw@redis:6379/0"; JOBS_REDIS_URL
+	MaxAttempts  int    `mapstructure:"max_attempts"`  // runs before a job is dead, default 5
+	Backoff      string `mapstructure:"backoff"`       // wait before the first retry, doubling after each; default "1m"
+	MaxBackoff   string `mapstructure:"max_backoff"`   // longest wait between retries, default "1h"
+	PollInterval string `mapstructure:"poll_interval"` // how often the worker looks for due jobs, default "5s"
+	Concurrency  int    `mapstructure:"concurrency"`   // jobs run at once, default 8
+	Retention    string `mapstructure:"retention"`     // succeeded jobs are deleted after this, default "168h"
+}
+
+// LimitsConfig caps one run of the daemon. When a cap is reached, running
+// ingestion jobs go back to the queue, cursors stay at the last completed
+// window, and the daemon exits cleanly, so a metered or scheduled run ends
+// inside its budget and the next run resumes. Zero values disable a cap.
+type LimitsConfig struct {
+	MaxRequests      int64  `mapstructure:"max_requests"`       // upstream HTTP requests
+	MaxDuration      string `mapstructure:"max_duration"`       // wall time, e.g. "14m"
+	MaxNewAdvisories int64  `mapstructure:"max_new_advisories"` // feed items stored for the first time
+}
+
+// QualityConfig tunes the data quality checks on ingest (see
+// internal/quality). The checks themselves always run.
+type QualityConfig struct {
+	FutureTolerance string `mapstructure:"future_tolerance"` // how far in the future a date may lie, default "48h"
+	Retention       string `mapstructure:"retention"`        // stored violations are deleted after this, default "720h"
+}
+
+// QuarantineConfig keeps upstream payloads that failed to parse for
+// `tigerfetch replay` (see internal/quarantine).
+type QuarantineConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`   // default true
+	Retention string `mapstructure:"retention"` // payloads not seen again for this long are deleted, default "720h"
+}
+
+// RawArchiveConfig keeps a compressed copy of every upstream payload, so
+// the derived tables can be rebuilt without refetching (see
+// internal/rawarchive). Off by default.
+type RawArchiveConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	URL       string `mapstructure:"url"`       // "s3://bucket/prefix", "gs://bucket/prefix" or "file:///dir"; empty stores payloads in the database
+	Endpoint  string `mapstructure:"endpoint"`  // S3-compatible endpoint, e.g. MinIO
+	Region    string `mapstructure:"region"`    // S3 region, default $AWS_REGION
+	Retention string `mapstructure:"retention"` // payloads first fetched longer ago are deleted; empty keeps them
+}
+
+// AuditConfig keeps an append-only trail of significant actions: runtime
+// switches and cursor moves from the CLI, acknowledgements and webhook
+// notifications, with who did what and when (see internal/audit). Off by
+// default.
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`  // JSON Lines file to append to; empty stores events in the audit_log table
+	Actor   string `mapstructure:"actor"` // who the daemon records as, default "tigerfetch@<hostname>"
+}
+
+// InboundConfig accepts advisories pushed by webhook (see internal/inbound)
+// on a listener of its own, for sources that push rather than publish a
+// feed. Pushed advisories are stored like feed items.
+type InboundConfig struct {
+	Enabled   bool                  `mapstructure:"enabled"`
+	Bind      string                `mapstructure:"bind"`        // default "0.0.0.0:9103"
+	MaxBodyMB int                   `mapstructure:"max_body_mb"` // request body limit, 0 = default (1)
+	Sources   []InboundSourceConfig `mapstructure:"sources"`
+}
+
+// InboundSourceConfig is one pushing source, served at POST /inbound/{name}.
+type InboundSourceConfig struct {
+	Name   string `mapstructure:"name"`
+	Secret string `mapstructure:"secret"` // HMAC-SHA256 key of the X-Hub-Signature-256 header; required
+	Format string `mapstructure:"format"` // "tigerfetch" (default) or "github" (security_advisory events)
+	Title  string `mapstructure:"title"`  // stored as the feed title, default the name
+}
+
+// MailConfig polls IMAP mailboxes subscribed to advisory mailing lists
+// (see internal/maillist). Each list's posts are stored as the items of a
+// feed named after it.
+type MailConfig struct {
+	Enabled      bool             `mapstructure:"enabled"`
+	PollInterval string           `mapstructure:"poll_interval"` // default "15m"
+	Lookback     string           `mapstructure:"lookback"`      // posts a new or reset list starts with, default "168h"
+	MaxMessages  int              `mapstructure:"max_messages"`  // per list and run, oldest first, 0 = default (200)
+	Lists        []MailListConfig `mapstructure:"lists"`
+}
+
+// MailListConfig is one mailing list and the mailbox it arrives in.
+type MailListConfig struct {
+	Name        string `mapstructure:"name"`         // feed name of its posts, e.g. "oss-security"
+	Server      string `mapstructure:"server"`       // IMAP host[:port], implicit TLS; default port 993
+	Username    string `mapstructure:"username"`     // IMAP login
+	Password    string `mapstructure:"password"`     // or password_env
+	PasswordEnv string `mapstructure:"password_env"` // environment variable holding the password instead
+	Mailbox     string `mapstructure:"mailbox"`      // default "INBOX"
+	ListID      string `mapstructure:"list_id"`      // only posts whose List-Id contains this, for a shared mailbox
+	SkipReplies bool   `mapstructure:"skip_replies"` // leave out replies ("Re:", In-Reply-To)
+	Link        string `mapstructure:"link"`         // post link with {message_id}, e.g. "https://lore.kernel.org/all/{message_id}/"
+}
+
+// SocialConfig follows security accounts and hashtags on Mastodon and
+// Bluesky, subreddits and Hacker News (see internal/social). Posts naming
+// a CVE ID or a keyword are stored as the items of a feed named after the
+// source.
+type SocialConfig struct {
+	Enabled       bool                 `mapstructure:"enabled"`
+	PollInterval  string               `mapstructure:"poll_interval"`   // default "15m"
+	MaxResponseMB int                  `mapstructure:"max_response_mb"` // per API response, 0 = default (4)
+	Sources       []SocialSourceConfig `mapstructure:"sources"`
+}
+
+// SocialSourceConfig is one account, hashtag, subreddit or Hacker News
+// list.
+type SocialSourceConfig struct {
+	Name      string   `mapstructure:"name"`      // feed name of its posts
+	Network   string   `mapstructure:"network"`   // "mastodon", "bluesky", "reddit" or "hackernews"
+	Instance  string   `mapstructure:"instance"`  // API base URL, default the network's public API
+	Account   string   `mapstructure:"account"`   // "user@host" on Mastodon, a handle or DID on Bluesky
+	Hashtag   string   `mapstructure:"hashtag"`   // instead of account, without "#"
+	Subreddit string   `mapstructure:"subreddit"` // Reddit, without "r/", e.g. "netsec"
+	List      string   `mapstructure:"list"`      // Hacker News story list: "new" (default), "top" or "best"
+	Keywords  []string `mapstructure:"keywords"`  // also keep posts naming one of these (case-insensitive), e.g. "zero-day"
+	Token     string   `mapstructure:"token"`     // bearer token, for instances and searches that need a login
+	Limit     int      `mapstructure:"limit"`     // newest posts read per poll, 0 = default (40)
+}
+
+// WatchlistConfig turns keywords, such as the organisation's products,
+// into news search feeds: every keyword is searched with every term on
+// every engine, and each search becomes a feed of its own, added to
+// [[feeds]] when the configuration is loaded.
+type WatchlistConfig struct {
+	Keywords      []string `mapstructure:"keywords"`       // e.g. "Acme VPN"; quoted in the query when they contain a space
+	SearchTerms   []string `mapstructure:"search_terms"`   // added to each keyword, default ["vulnerability"]
+	SearchEngines []string `mapstructure:"search_engines"` // "google_news", "bing_news" (default both) or an RSS URL template with {query}
+	Tags          []string `mapstructure:"tags"`           // tags of the search feeds, default ["watchlist"]
+}
+
+// searchEngines are the built-in news search RSS URL templates.
+var searchEngines = map[string]string{
+	"google_news": "https://news.google.com/rss/search?q={query}&hl=en-US&gl=US&ceid=US:en",
+	"bing_news":   "https://www.bing.com/news/search?q={query}&format=rss",
+}
+
+// searchEngineNames label the built-in engines in feed names.
+var searchEngineNames = map[string]string{"google_news": "Google News", "bing_news": "Bing News"}
+
+// SearchFeeds returns one feed per keyword, search term and engine, named
+// "Watchlist: <query> (<engine>)". Search results come and go, so the
+// feeds never mark their items withdrawn.
+func (c *WatchlistConfig) SearchFeeds() ([]Feed, error) {
+	terms := c.SearchTerms
+	if len(terms) == 0 {
+		terms = []string{"vulnerability"}
+	}
+	engines := c.SearchEngines
+	if len(engines) == 0 {
+		engines = []string{"google_news", "bing_news"}
+	}
+	tags := c.Tags
+	if len(tags) == 0 {
+		tags = []string{"watchlist"}
+	}
+	var feeds []Feed
+	for _, engine := range engines {
+		tmpl, label := searchEngines[engine], searchEngineNames[engine]
+		if tmpl == "" {
+			u, err := url.Parse(strings.ReplaceAll(engine, "{query}", "q"))
+			if err != nil || !strings.Contains(engine, "{query}") || u.Host == "" {
+				return nil, fmt.Errorf("search engine %q: want google_news, bing_news or a URL with {query}", engine)
+			}
+			tmpl, label = engine, u.Host
+		}
+		for _, keyword := range c.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword == "" {
+				continue
+			}
+			if strings.ContainsAny(keyword, " \t") {
+				keyword = `"` + keyword + `"`
+			}
+			for _, term := range terms {
+				query := strings.TrimSpace(keyword + " " + term)
+				feeds = append(feeds, Feed{
+					Name:          fmt.Sprintf("Watchlist: %s (%s)", query, label),
+					URL:           strings.ReplaceAll(tmpl, "{query}", url.QueryEscape(query)),
+					FeedType:      "search",
+					Tags:          slices.Clone(tags),
+					WithdrawAfter: -1,
+				})
+			}
+		}
+	}
+	return feeds, nil
+}
+
+// ProfilingConfig serves the net/http/pprof endpoints in daemon mode, for
+// diagnosing memory growth and leaks. Profiles expose internals, so they
+// have a listener of their own, on localhost by default.
+type ProfilingConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Bind    string `mapstructure:"bind"` // default "127.0.0.1:6060"
+}
+
+// RunConfig configures single runs of the pipeline: `tigerfetch run` and
+// the serverless handler. Without a daemon to remember when each step last
+// ran, the run state (every step's last success) lives in an object, so a
+// schedule can invoke runs more often than the slowest source polls and a
+// run cut short by [limits] is resumed by the next. A run can also ping a
+// dead man's switch, which alerts when a run fails or is missed.
+type RunConfig struct {
+	StateURL      string `mapstructure:"state_url"`      // "s3://bucket/key", "gs://bucket/key" or "file:///path"; empty runs every step each time
+	StateEndpoint string `mapstructure:"state_endpoint"` // S3-compatible endpoint, e.g. MinIO or "https://storage.googleapis.com" with HMAC keys
+	StateRegion   string `mapstructure:"state_region"`   // S3 region, default $AWS_REGION
+	PingURL       string `mapstructure:"ping_url"`       // pinged after a successful run, e.g. "https://hc-ping.com/<uuid>"
+	PingFailURL   string `mapstructure:"ping_fail_url"`  // pinged after a failed run, default ping_url + "/fail"
+	SummaryPath   string `mapstructure:"summary_path"`   // run summary JSON: a path or object URL; "{started}" is replaced by the start time
+
+	Failure RunFailureConfig `mapstructure:"failure"`
+	SLO     RunSLOConfig     `mapstructure:"slo"`
+}
+
+// RunFailureConfig decides when a single run fails despite feeds being
+// fetched independently, each policy with its own exit status. Without it
+// any failed step fails the run with status 1.
+type RunFailureConfig struct {
+	MaxFailedFeedsPercent float64  `mapstructure:"max_failed_feeds_percent"` // tolerate feed failures up to this share; over it exit 3. 0 tolerates none
+	CriticalSources       []string `mapstructure:"critical_sources"`         // steps ("nvd", "kev") or feed names whose failure exits 4
+	FailOnNoNewItems      bool     `mapstructure:"fail_on_no_new_items"`     // exit 5 when feeds ran and stored no new advisory
+}
+
+// RunSLOConfig sets the error rate and latency each upstream host should
+// stay within during a run. A host that misses them is a warning in the
+// run summary, not a failure. Zero thresholds are not checked.
+type RunSLOConfig struct {
+	MaxErrorPercent float64         `mapstructure:"max_error_percent"` // requests without a response, 429 or 5xx
+	MaxP95Latency   string          `mapstructure:"max_p95_latency"`   // 95th percentile time to response headers, e.g. "5s"
+	MinRequests     int             `mapstructure:"min_requests"`      // hosts with fewer requests in a run are not judged, default 10
+	Hosts           []HostSLOConfig `mapstructure:"hosts"`
+}
+
+// HostSLOConfig overrides the thresholds of one host ([[run.slo.hosts]]);
+// unset ones are the defaults, negative ones are not checked.
+type HostSLOConfig struct {
+	Host            string  `mapstructure:"host"` // ".example.com" matches subdomains
+	MaxErrorPercent float64 `mapstructure:"max_error_percent"`
+	MaxP95Latency   string  `mapstructure:"max_p95_latency"`
+}
+
+// SummarizerConfig enables LLM-written analyst notes for the events of
+// `tigerfetch summary`. Event titles, CVEs and advisory titles are sent to
+// the configured endpoint.
+type SummarizerConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Provider string `mapstructure:"provider"` // "openai" (any OpenAI-compatible API) or "ollama"
+	URL      string `mapstructure:"url"`      // default "https://api.openai.com/v1" or "http://localhost:11434"
+	Model    string `mapstructure:"model"`    // required, e.g. "gpt-4o-mini" or "llama3.1"
+	APIKey   string `mapstructure:"api_key"`  // bearer token; SUMMARIZER_API_KEY
+	Timeout  string `mapstructure:"timeout"`  // per request, default "60s"
+}
+
+// ActorTagsConfig extends the threat-actor and ransomware dictionary that
+// tags advisories by the groups they name.
+type ActorTagsConfig struct {
+	DisableBuiltin bool          `mapstructure:"disable_builtin"` // use only Actors
+	Actors         []ActorConfig `mapstructure:"actors"`
+}
+
+// ActorConfig is one dictionary entry; it replaces a built-in entry of the
+// same name.
+type ActorConfig struct {
+	Name    string   `mapstructure:"name"`
+	Kind    string   `mapstructure:"kind"` // "actor" (default) or "ransomware"
+	Aliases []string `mapstructure:"aliases"`
+}
+
+// OwnerConfig assigns the advisories of some products to the team that
+// owns them ([[owners]]). Patterns are case-insensitive globs; an advisory
+// matching any of them belongs to the team.
+type OwnerConfig struct {
+	Team     string   `mapstructure:"team"`
+	Products []string `mapstructure:"products"` // "vendor:product", from KEV names or NVD CPEs, e.g. "cisco:ios*"
+	CPEs     []string `mapstructure:"cpes"`     // NVD CPE match criteria, e.g. "cpe:2.3:a:gitlab:*"
+	Monitors []string `mapstructure:"monitors"` // names of [[nvd.products]] entries
+
+	// Where the team's advisories go: the [[alerting.webhooks]] and
+	// [[alerting.escalation.webhooks]] of these names, which then only
+	// receive owned advisories, and the Jira project passed along in
+	// payloads for tracker webhooks.
+	Webhooks    []string `mapstructure:"webhooks"`
+	JiraProject string   `mapstructure:"jira_project"`
 }
 
 type WebhookConfig struct {
 	Name string `mapstructure:"name"`
 	URL  string `mapstructure:"url"`
-	Type string `mapstructure:"type"` // "slack" or "generic"
+	Type string `mapstructure:"type"` // "slack" or "generic"; escalation webhooks also "pagerduty"
+
+	// PagerDuty Events API v2 integration key of a "pagerduty" webhook,
+	// whose url defaults to https://events.pagerduty.com/v2/enqueue.
+	RoutingKey string `mapstructure:"routing_key"`
+
+	Language string `mapstructure:"language"` // Slack message language, default display.language
+
+	// Delivery policy, defaulting to [alerting]'s: "0s" turns the cooldown
+	// off for this webhook and digest_size 1 sends every run.
+	Cooldown      string `mapstructure:"cooldown"`
+	DigestSize    int    `mapstructure:"digest_size"`
+	DigestMaxWait string `mapstructure:"digest_max_wait"`
+
+	// Template replaces the built-in payload with a Go text/template,
+	// inline or read from TemplateFile, rendered per notification with
+	// its items and Vars (keys are lower-cased). A template that renders
+	// only whitespace sends nothing.
+	Template     string            `mapstructure:"template"`
+	TemplateFile string            `mapstructure:"template_file"`
+	ContentType  string            `mapstructure:"content_type"` // of a templated payload, default "application/json"
+	Vars         map[string]string `mapstructure:"vars"`         // e.g. runbook and asset owner links
 }
 
 // Load reads configuration from config files and environment variables.
@@ -70,6 +709,31 @@ func Load() (*Config, error) {
 	// Default values
 	v.SetDefault("server_bind", "0.0.0.0:9101")
 	v.SetDefault("ingest_interval", "1h")
+	v.SetDefault("feed_withdraw_after", 3)
+	v.SetDefault("api.bind", "0.0.0.0:9102")
+	v.SetDefault("profiling.bind", "127.0.0.1:6060")
+	v.SetDefault("inbound.bind", "0.0.0.0:9103")
+	v.SetDefault("database.read_url", "")  // so DATABASE_READ_URL is picked up from the environment
+	v.SetDefault("remote.api_key", "")     // REMOTE_API_KEY, keeping the federation key out of config files
+	v.SetDefault("summarizer.api_key", "") // SUMMARIZER_API_KEY
+	v.SetDefault("display.language", "")   // DISPLAY_LANGUAGE
+	v.SetDefault("display.timezone", "")   // DISPLAY_TIMEZONE
+	v.SetDefault("jobs.redis_url", "")     // JOBS_REDIS_URL, keeping Redis credentials out of config files
+	v.SetDefault("limits.max_requests", 0) // LIMITS_MAX_REQUESTS and friends, set per invocation by the scheduler
+	v.SetDefault("limits.max_duration", "")
+	v.SetDefault("limits.max_new_advisories", 0)
+	v.SetDefault("quarantine.enabled", true)
+	v.SetDefault("crawl.robots", true)
+	v.SetDefault("http.rate_limit_headers", true)
+	v.SetDefault("raw_archive.url", "") // RAW_ARCHIVE_URL
+	v.SetDefault("audit.path", "")      // AUDIT_PATH
+	v.SetDefault("run.state_url", "")   // RUN_STATE_URL, set per function or CronJob
+	v.SetDefault("run.ping_url", "")    // RUN_PING_URL, one check per CronJob
+	v.SetDefault("run.ping_fail_url", "")
+	v.SetDefault("run.summary_path", "") // RUN_SUMMARY_PATH
+	v.SetDefault("run.failure.max_failed_feeds_percent", 0)
+	v.SetDefault("run.failure.critical_sources", []string{})
+	v.SetDefault("run.failure.fail_on_no_new_items", false)
 
 	// Config file setup
 	v.SetConfigName("Config") // name of config file (without extension)
@@ -93,19 +757,140 @@ func Load() (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	search, err := cfg.Watchlist.SearchFeeds()
+	if err != nil {
+		return nil, fmt.Errorf("watchlist: %w", err)
+	}
+	for _, f := range search {
+		// a search already configured by hand keeps its settings
+		if !slices.ContainsFunc(cfg.Feeds, func(g Feed) bool { return g.URL == f.URL }) {
+			cfg.Feeds = append(cfg.Feeds, f)
+		}
+	}
+	if err := checkFeedNames(cfg.Feeds); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
 
+// checkFeedNames rejects feeds without a name or sharing one: feeds are
+// scheduled, tracked and reported by name, so all but one of them would
+// never be fetched.
+func checkFeedNames(feeds []Feed) error {
+	seen := make(map[string]bool, len(feeds))
+	for _, f := range feeds {
+		if strings.TrimSpace(f.Name) == "" {
+			return fmt.Errorf("feed %s has no name", f.URL)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("feed name %q is used twice", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
+
 // GetIngestDuration parses the IngestInterval string into a time.Duration.
 func (c *Config) GetIngestDuration() (time.Duration, error) {
 	return time.ParseDuration(c.IngestInterval)
 }
 
+// GetFeedMaxItemAge parses FeedMaxItemAge; empty means 0 (no cutoff).
+func (c *Config) GetFeedMaxItemAge() (time.Duration, error) {
+	if c.FeedMaxItemAge == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.FeedMaxItemAge)
+}
+
+// GetMaxItemAge returns the item age cutoff for f: its own max_item_age,
+// else def (from feed_max_item_age). Zero means no cutoff, as do negative
+// values and Backfill.
+func (f Feed) GetMaxItemAge(def time.Duration) (time.Duration, error) {
+	if f.Backfill {
+		return 0, nil
+	}
+	d := def
+	if f.MaxItemAge != "" {
+		var err error
+		if d, err = time.ParseDuration(f.MaxItemAge); err != nil {
+			return 0, err
+		}
+	}
+	return max(d, 0), nil
+}
+
+// GetMaxItems returns how many of f's items a fetch stores at most: its
+// own max_items, else def. Zero means no limit, as do negative values and
+// Backfill.
+func (f Feed) GetMaxItems(def int) int {
+	if f.Backfill {
+		return 0
+	}
+	n := def
+	if f.MaxItems != 0 {
+		n = f.MaxItems
+	}
+	return max(n, 0)
+}
+
+// GetWithdrawAfter returns how many fetches in a row an item must be
+// missing from f before it is marked withdrawn: its own withdraw_after,
+// else def (from feed_withdraw_after). Zero means never.
+func (f Feed) GetWithdrawAfter(def int) int {
+	n := def
+	if f.WithdrawAfter != 0 {
+		n = f.WithdrawAfter
+	}
+	return max(n, 0)
+}
+
+// ReadDatabaseURL returns the DSN for read-only work: database.read_url when
+// set, otherwise the primary database_url.
+func (c *Config) ReadDatabaseURL() string {
+	if c.Database.ReadURL != "" {
+		return c.Database.ReadURL
+	}
+	return c.DatabaseURL
+}
+
+// GetSlowQueryThreshold parses SlowQueryThreshold; empty means 0 (the db default).
+func (c *DatabaseConfig) GetSlowQueryThreshold() (time.Duration, error) {
+	if c.SlowQueryThreshold == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.SlowQueryThreshold)
+}
+
 func (c *NvdConfig) GetPollDuration() (time.Duration, error) {
 	return time.ParseDuration(c.PollInterval)
 }
 
+// GetCursorOverlap parses CursorOverlap; empty means 15m, "0s" none.
+func (c *NvdConfig) GetCursorOverlap() (time.Duration, error) {
+	if c.CursorOverlap == "" {
+		return 15 * time.Minute, nil
+	}
+	return time.ParseDuration(c.CursorOverlap)
+}
+
+// GetProductsPollDuration parses ProductsPollInterval; empty means 6h.
+func (c *NvdConfig) GetProductsPollDuration() (time.Duration, error) {
+	if c.ProductsPollInterval == "" {
+		return 6 * time.Hour, nil
+	}
+	return time.ParseDuration(c.ProductsPollInterval)
+}
+
+// GetProductsLookback parses ProductsLookback; empty means 720h (30 days).
+func (c *NvdConfig) GetProductsLookback() (time.Duration, error) {
+	if c.ProductsLookback == "" {
+		return 720 * time.Hour, nil
+	}
+	return time.ParseDuration(c.ProductsLookback)
+}
+
 func (c *EpssConfig) GetPollDuration() (time.Duration, error) {
 	return time.ParseDuration(c.PollInterval)
 }
@@ -114,6 +899,266 @@ func (c *KevConfig) GetPollDuration() (time.Duration, error) {
 	return time.ParseDuration(c.PollInterval)
 }
 
+// GetTimeout parses Timeout; empty means 30s.
+func (c *ClickHouseConfig) GetTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 30 * time.Second, nil
+	}
+	return time.ParseDuration(c.Timeout)
+}
+
+// GetCacheRefresh parses CacheRefresh; empty means 0 (the kev package default).
+func (c *KevConfig) GetCacheRefresh() (time.Duration, error) {
+	if c.CacheRefresh == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.CacheRefresh)
+}
+
+// GetListSettle parses ListSettle; empty means 0 (the api package default).
+func (c *APIConfig) GetListSettle() (time.Duration, error) {
+	if c.ListSettle == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.ListSettle)
+}
+
+func (c *RemoteConfig) GetPollDuration() (time.Duration, error) {
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetTimeout parses Timeout; empty means 1m.
+func (c *RemoteConfig) GetTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(c.Timeout)
+}
+
+// GetPollDuration parses PollInterval; empty means 15m.
+func (c *MailConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 15 * time.Minute, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetLookback parses Lookback; empty means 168h (7 days).
+func (c *MailConfig) GetLookback() (time.Duration, error) {
+	if c.Lookback == "" {
+		return 168 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Lookback)
+}
+
+// GetPollDuration parses PollInterval; empty means 15m.
+func (c *SocialConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 15 * time.Minute, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetWindow parses Window; empty means 24h.
+func (c *SummaryConfig) GetWindow() (time.Duration, error) {
+	if c.Window == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Window)
+}
+
+// GetEventWindow parses EventWindow; empty means 72h.
+func (c *SummaryConfig) GetEventWindow() (time.Duration, error) {
+	if c.EventWindow == "" {
+		return 72 * time.Hour, nil
+	}
+	return time.ParseDuration(c.EventWindow)
+}
+
+// GetTimeout parses Timeout; empty means 60s.
+func (c *SummarizerConfig) GetTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 60 * time.Second, nil
+	}
+	return time.ParseDuration(c.Timeout)
+}
+
 func (c *AlertingConfig) GetPollDuration() (time.Duration, error) {
 	return time.ParseDuration(c.PollInterval)
 }
+
+// GetPollDuration parses PollInterval; empty means 24h.
+func (c *DetectionsConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetPollDuration parses PollInterval; empty means 24h.
+func (c *EOLConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetPollDuration parses PollInterval; empty means 1h.
+func (c *CoverageConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetLookback parses Lookback; empty means 720h (30 days).
+func (c *CoverageConfig) GetLookback() (time.Duration, error) {
+	if c.Lookback == "" {
+		return 720 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Lookback)
+}
+
+// GetPollDuration parses PollInterval; empty means 5m.
+func (c *MergeConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 5 * time.Minute, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetPollDuration parses PollInterval; empty means 1h.
+func (c *ReferenceLabelsConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetBackoff parses Backoff; empty means 1m.
+func (c *JobsConfig) GetBackoff() (time.Duration, error) {
+	if c.Backoff == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(c.Backoff)
+}
+
+// GetMaxBackoff parses MaxBackoff; empty means 1h.
+func (c *JobsConfig) GetMaxBackoff() (time.Duration, error) {
+	if c.MaxBackoff == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(c.MaxBackoff)
+}
+
+// GetPollDuration parses PollInterval; empty means 5s.
+func (c *JobsConfig) GetPollDuration() (time.Duration, error) {
+	if c.PollInterval == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(c.PollInterval)
+}
+
+// GetRetention parses Retention; empty means 168h (a week).
+func (c *JobsConfig) GetRetention() (time.Duration, error) {
+	if c.Retention == "" {
+		return 168 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Retention)
+}
+
+// GetMaxDuration parses MaxDuration; empty means no limit (0).
+func (c *LimitsConfig) GetMaxDuration() (time.Duration, error) {
+	if c.MaxDuration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.MaxDuration)
+}
+
+// GetFutureTolerance parses FutureTolerance; empty means 48h.
+func (c *QualityConfig) GetFutureTolerance() (time.Duration, error) {
+	if c.FutureTolerance == "" {
+		return 48 * time.Hour, nil
+	}
+	return time.ParseDuration(c.FutureTolerance)
+}
+
+// GetRetention parses Retention; empty means 720h (30 days).
+func (c *QualityConfig) GetRetention() (time.Duration, error) {
+	if c.Retention == "" {
+		return 720 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Retention)
+}
+
+// GetRetention parses Retention; empty means 720h (30 days).
+func (c *QuarantineConfig) GetRetention() (time.Duration, error) {
+	if c.Retention == "" {
+		return 720 * time.Hour, nil
+	}
+	return time.ParseDuration(c.Retention)
+}
+
+// GetRetention parses Retention; empty means 0, keeping every payload.
+func (c *RawArchiveConfig) GetRetention() (time.Duration, error) {
+	if c.Retention == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.Retention)
+}
+
+// GetHostDelay parses HostDelay; empty means 5s.
+func (c *ReferenceLabelsConfig) GetHostDelay() (time.Duration, error) {
+	if c.HostDelay == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(c.HostDelay)
+}
+
+// GetRobotsTTL parses RobotsTTL; empty means 24h.
+func (c *CrawlConfig) GetRobotsTTL() (time.Duration, error) {
+	if c.RobotsTTL == "" {
+		return 24 * time.Hour, nil
+	}
+	return time.ParseDuration(c.RobotsTTL)
+}
+
+// GetMaxRateLimitWait parses MaxRateLimitWait; empty means 1m.
+func (c *HTTPConfig) GetMaxRateLimitWait() (time.Duration, error) {
+	if c.MaxRateLimitWait == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(c.MaxRateLimitWait)
+}
+
+// GetIdleConnTimeout parses IdleConnTimeout; empty means 90s.
+func (c *HTTPConfig) GetIdleConnTimeout() (time.Duration, error) {
+	if c.IdleConnTimeout == "" {
+		return 90 * time.Second, nil
+	}
+	return time.ParseDuration(c.IdleConnTimeout)
+}
+
+// GetDNSCacheTTL parses DNSCacheTTL; empty means 5m.
+func (c *HTTPConfig) GetDNSCacheTTL() (time.Duration, error) {
+	if c.DNSCacheTTL == "" {
+		return 5 * time.Minute, nil
+	}
+	return time.ParseDuration(c.DNSCacheTTL)
+}
+
+// GetDNSStaleFor parses DNSStaleFor; empty means 1h.
+func (c *HTTPConfig) GetDNSStaleFor() (time.Duration, error) {
+	if c.DNSStaleFor == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(c.DNSStaleFor)
+}
+
+// GetRetryAfter parses RetryAfter; empty means 168h (a week).
+func (c *ReferenceLabelsConfig) GetRetryAfter() (time.Duration, error) {
+	if c.RetryAfter == "" {
+		return 168 * time.Hour, nil
+	}
+	return time.ParseDuration(c.RetryAfter)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"testing"
 	"time"
 
@@ -34,6 +35,22 @@ func TestGetIngestDuration_Negative(t *testing.T) {
 	assert.True(t, d < 0, "negative durations parse successfully but callers must validate")
 }
 
+func TestNvdGetCursorOverlap(t *testing.T) {
+	cfg := &NvdConfig{}
+	d, err := cfg.GetCursorOverlap()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, d)
+
+	cfg.CursorOverlap = "0s"
+	d, err = cfg.GetCursorOverlap()
+	require.NoError(t, err)
+	assert.Zero(t, d, "no overlap")
+
+	cfg.CursorOverlap = "soon"
+	_, err = cfg.GetCursorOverlap()
+	assert.Error(t, err)
+}
+
 func TestNvdGetPollDuration(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -80,4 +97,214 @@ func TestLoad_Defaults(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "0.0.0.0:9101", cfg.ServerBind)
 	assert.Equal(t, "1h", cfg.IngestInterval)
+	assert.Equal(t, 3, cfg.FeedWithdrawAfter)
+	assert.True(t, cfg.Crawl.Robots)
+	assert.Equal(t, "0.0.0.0:9102", cfg.API.Bind)
+	assert.False(t, cfg.API.Enabled)
+	assert.False(t, cfg.Profiling.Enabled)
+	assert.Equal(t, "127.0.0.1:6060", cfg.Profiling.Bind, "profiles stay on localhost")
+}
+
+func TestLoad_FeedNames(t *testing.T) {
+	for want, feeds := range map[string]string{
+		"has no name":   "[[feeds]]\nurl = \"https://a.example/rss\"\n",
+		"is used twice": "[[feeds]]\nname = \"A\"\nurl = \"https://a.example/rss\"\n[[feeds]]\nname = \"A\"\nurl = \"https://b.example/rss\"\n",
+	} {
+		t.Run(want, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			require.NoError(t, os.WriteFile("Config.toml", []byte(feeds), 0o600))
+			_, err := Load()
+			assert.ErrorContains(t, err, want)
+		})
+	}
+}
+
+func TestReadDatabaseURL(t *testing.T) {
+	cfg := &Config{DatabaseURL: "postgres://primary/db"}
+	assert.Equal(t, "postgres://primary/db", cfg.ReadDatabaseURL())
+
+	cfg.Database.ReadURL = "postgres://replica/db"
+	assert.Equal(t, "postgres://replica/db", cfg.ReadDatabaseURL())
+}
+
+func TestLoad_DatabaseReadURLFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_READ_URL", "postgres://replica/db")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://replica/db", cfg.Database.ReadURL)
+}
+
+func TestDisplayTimezone(t *testing.T) {
+	t.Setenv("DISPLAY_TIMEZONE", "Europe/Berlin")
+	cfg, err := Load()
+	require.NoError(t, err)
+	loc, err := cfg.Display.GetTimezone()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+
+	loc, err = DisplayConfig{}.GetTimezone()
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc, "empty is UTC")
+
+	_, err = DisplayConfig{Timezone: "Mars/Olympus_Mons"}.GetTimezone()
+	assert.ErrorContains(t, err, `time zone "Mars/Olympus_Mons"`)
+}
+
+func TestRemoteConfig(t *testing.T) {
+	t.Setenv("REMOTE_API_KEY", "s3cret")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Remote.APIKey)
+
+	d, err := cfg.Remote.GetTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d, "empty timeout defaults to 1m")
+
+	cfg.Remote.PollInterval = "15m"
+	d, err = cfg.Remote.GetPollDuration()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, d)
+}
+
+func TestFeedMaxItemAge(t *testing.T) {
+	cfg := &Config{}
+	def, err := cfg.GetFeedMaxItemAge()
+	require.NoError(t, err)
+	assert.Zero(t, def, "no cutoff by default")
+
+	cfg.FeedMaxItemAge = "2160h"
+	def, err = cfg.GetFeedMaxItemAge()
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, def)
+
+	for _, tt := range []struct {
+		feed Feed
+		want time.Duration
+	}{
+		{Feed{}, def},
+		{Feed{MaxItemAge: "168h"}, 7 * 24 * time.Hour},
+		{Feed{MaxItemAge: "-1s"}, 0},
+		{Feed{MaxItemAge: "168h", Backfill: true}, 0},
+	} {
+		got, err := tt.feed.GetMaxItemAge(def)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%+v", tt.feed)
+	}
+
+	_, err = Feed{MaxItemAge: "old"}.GetMaxItemAge(def)
+	assert.Error(t, err)
+}
+
+func TestFeedMaxItems(t *testing.T) {
+	assert.Equal(t, 1000, Feed{}.GetMaxItems(1000))
+	assert.Equal(t, 50, Feed{MaxItems: 50}.GetMaxItems(1000))
+	assert.Zero(t, Feed{MaxItems: -1}.GetMaxItems(1000))
+	assert.Zero(t, Feed{MaxItems: 50, Backfill: true}.GetMaxItems(1000))
+}
+
+func TestFeedWithdrawAfter(t *testing.T) {
+	assert.Equal(t, 3, Feed{}.GetWithdrawAfter(3))
+	assert.Equal(t, 5, Feed{WithdrawAfter: 5}.GetWithdrawAfter(3))
+	assert.Zero(t, Feed{WithdrawAfter: -1}.GetWithdrawAfter(3))
+	assert.Zero(t, Feed{}.GetWithdrawAfter(0))
+}
+
+func TestReferenceLabelsConfig(t *testing.T) {
+	var c ReferenceLabelsConfig
+	d, err := c.GetPollDuration()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+	d, err = c.GetHostDelay()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, d)
+	d, err = c.GetRetryAfter()
+	require.NoError(t, err)
+	assert.Equal(t, 168*time.Hour, d)
+
+	c.HostDelay = "soon"
+	_, err = c.GetHostDelay()
+	assert.Error(t, err)
+}
+
+func TestCrawlConfig(t *testing.T) {
+	var c CrawlConfig
+	d, err := c.GetRobotsTTL()
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, d)
+
+	c.RobotsTTL = "1h"
+	d, err = c.GetRobotsTTL()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+}
+
+func TestHTTPConfig(t *testing.T) {
+	var c HTTPConfig
+	d, err := c.GetMaxRateLimitWait()
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+	d, err = c.GetIdleConnTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+	d, err = c.GetDNSCacheTTL()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, d)
+	d, err = c.GetDNSStaleFor()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.HTTP.RateLimitHeaders, "on by default")
+}
+
+func TestSummaryAndSummarizerConfig(t *testing.T) {
+	var s SummaryConfig
+	d, err := s.GetEventWindow()
+	require.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, d)
+
+	var c SummarizerConfig
+	d, err = c.GetTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Second, d)
+
+	t.Setenv("SUMMARIZER_API_KEY", "sk-env")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-env", cfg.Summarizer.APIKey)
+}
+
+func TestWatchlistSearchFeeds(t *testing.T) {
+	cfg := &WatchlistConfig{Keywords: []string{"Acme VPN", " ", "WidgetOS"}, SearchEngines: []string{"google_news"}}
+	feeds, err := cfg.SearchFeeds()
+	require.NoError(t, err)
+	require.Len(t, feeds, 2)
+	assert.Equal(t, Feed{
+		Name:          `Watchlist: "Acme VPN" vulnerability (Google News)`,
+		URL:           "https://news.google.com/rss/search?q=%22Acme+VPN%22+vulnerability&hl=en-US&gl=US&ceid=US:en",
+		FeedType:      "search",
+		Tags:          []string{"watchlist"},
+		WithdrawAfter: -1,
+	}, feeds[0])
+	assert.Equal(t, "Watchlist: WidgetOS vulnerability (Google News)", feeds[1].Name)
+
+	cfg = &WatchlistConfig{Keywords: []string{"WidgetOS"}, SearchTerms: []string{"CVE", "exploit"},
+		SearchEngines: []string{"bing_news", "https://search.example/rss?q={query}"}, Tags: []string{"product"}}
+	feeds, err = cfg.SearchFeeds()
+	require.NoError(t, err)
+	require.Len(t, feeds, 4)
+	assert.Equal(t, "https://www.bing.com/news/search?q=WidgetOS+CVE&format=rss", feeds[0].URL)
+	assert.Equal(t, "Watchlist: WidgetOS exploit (search.example)", feeds[3].Name)
+	assert.Equal(t, "https://search.example/rss?q=WidgetOS+exploit", feeds[3].URL)
+	assert.Equal(t, []string{"product"}, feeds[3].Tags)
+
+	feeds, err = (&WatchlistConfig{}).SearchFeeds()
+	require.NoError(t, err)
+	assert.Empty(t, feeds)
+
+	for _, engine := range []string{"duckduckgo", "https://search.example/rss", "{query}"} {
+		_, err := (&WatchlistConfig{Keywords: []string{"x"}, SearchEngines: []string{engine}}).SearchFeeds()
+		assert.Error(t, err, engine)
+	}
 }
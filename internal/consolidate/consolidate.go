@@ -0,0 +1,385 @@
+// Package consolidate builds the golden record: one normalized row per CVE
+// in cve_consolidated, merged from every cve_enriched source. NVD supplies
+// the description, dates, CWEs and CVSS score (chosen under [merge] cvss),
+// CISA-KEV the exploitation fields; other sources are listed in Sources.
+// The Runner follows cve_enriched.updated_at, and the KEV removals in
+// kev_changes whose rows are deleted, so only CVEs with a changed source
+// record are rebuilt, and it is the only writer of the table.
+package consolidate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Record is the consolidated view of one CVE.
+type Record struct {
+	CveID       string     `json:"cve_id"`
+	Sources     []string   `json:"sources"` // cve_enriched sources merged, e.g. NVD, CISA-KEV
+	Description string     `json:"description,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	Modified    time.Time  `json:"modified"`              // latest upstream modification of any source
+	VulnStatus  string     `json:"vuln_status,omitempty"` // NVD analysis status
+	CNA         string     `json:"cna,omitempty"`         // issuing CNA
+
+	CvssBase     *float64 `json:"cvss_base"`
+	CvssSeverity string   `json:"cvss_severity,omitempty"`
+	CvssVersion  string   `json:"cvss_version,omitempty"`
+	CvssSource   string   `json:"cvss_source,omitempty"` // nvd, cna, adp or other; empty for a stored score without v3 metrics
+	CWEs         []string `json:"cwes,omitempty"`
+
+	InKEV         bool       `json:"in_kev"`
+	KEVVendor     string     `json:"kev_vendor,omitempty"`
+	KEVProduct    string     `json:"kev_product,omitempty"`
+	KEVName       string     `json:"kev_name,omitempty"`
+	KEVDateAdded  *time.Time `json:"kev_date_added,omitempty"`
+	KEVDueDate    *time.Time `json:"kev_due_date,omitempty"`
+	KEVRansomware bool       `json:"kev_ransomware"`
+
+	FirstSeenAt time.Time `json:"first_seen_at"` // when tigerfetch first stored any source
+	UpdatedAt   time.Time `json:"updated_at"`    // when the consolidated record last changed
+}
+
+// Cursor returns the position just after r.
+func (r Record) Cursor() cursor.Cursor {
+	return cursor.Cursor{UpdatedAt: r.UpdatedAt, Key: []string{r.CveID}}
+}
+
+// CursorKeys is the number of key parts in a cve_consolidated cursor.
+const CursorKeys = 1
+
+// nvdPublishedLayout is NVD's timestamp format, UTC without a zone.
+const nvdPublishedLayout = "2006-01-02T15:04:05"
+
+// nvdFields are the parts of a stored NVD record the golden record uses.
+type nvdFields struct {
+	Published    string `json:"published"`
+	Descriptions []struct {
+		Lang  string `json:"lang"`
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Weaknesses []struct {
+		Description []struct {
+			Value string `json:"value"`
+		} `json:"description"`
+	} `json:"weaknesses"`
+	Metrics json.RawMessage `json:"metrics"`
+}
+
+// Build merges the cve_enriched rows of one CVE. priority is the [merge]
+// cvss precedence; nil takes NVD's order (see cve.SelectCvss).
+func Build(rows []cve.EnrichedRecord, priority []string) Record {
+	var rec Record
+	for i, r := range rows {
+		rec.CveID = r.CveID
+		if !slices.Contains(rec.Sources, r.Source) {
+			rec.Sources = append(rec.Sources, r.Source)
+		}
+		if r.Modified.After(rec.Modified) {
+			rec.Modified = r.Modified
+		}
+		if i == 0 || r.FirstSeenAt.Before(rec.FirstSeenAt) {
+			rec.FirstSeenAt = r.FirstSeenAt
+		}
+		switch r.Source {
+		case "NVD":
+			rec.setNVD(r, priority)
+		case "CISA-KEV":
+			rec.setKEV(r)
+		}
+	}
+	slices.Sort(rec.Sources)
+	return rec
+}
+
+func (rec *Record) setNVD(r cve.EnrichedRecord, priority []string) {
+	rec.CNA, rec.VulnStatus = r.SourceIdentifier, r.VulnStatus
+	rec.CvssBase = r.CvssBase
+
+	var n nvdFields
+	if err := json.Unmarshal(r.Data, &n); err != nil {
+		slog.Warn("Skipping unreadable NVD record", "cve_id", r.CveID, "error", err)
+		return
+	}
+	if t, err := time.Parse(nvdPublishedLayout, n.Published); err == nil {
+		rec.Published = &t
+	}
+	for _, d := range n.Descriptions {
+		if d.Lang == "en" {
+			rec.Description = strings.TrimSpace(d.Value)
+			break
+		}
+	}
+	for _, w := range n.Weaknesses {
+		for _, d := range w.Description {
+			if strings.HasPrefix(d.Value, "CWE-") && !slices.Contains(rec.CWEs, d.Value) {
+				rec.CWEs = append(rec.CWEs, d.Value)
+			}
+		}
+	}
+	slices.Sort(rec.CWEs)
+	if c := cve.SelectCvss(n.Metrics, r.SourceIdentifier, priority); c != nil {
+		rec.CvssBase, rec.CvssSeverity, rec.CvssVersion, rec.CvssSource = &c.Score, c.Severity, c.Version, c.Source
+	}
+}
+
+func (rec *Record) setKEV(r cve.EnrichedRecord) {
+	var k cve.KevVuln
+	if err := json.Unmarshal(r.Data, &k); err != nil {
+		slog.Warn("Skipping unreadable KEV record", "cve_id", r.CveID, "error", err)
+		return
+	}
+	rec.InKEV = true
+	rec.KEVVendor, rec.KEVProduct, rec.KEVName = k.VendorProject, k.Product, k.VulnerabilityName
+	rec.KEVDateAdded, _ = k.ParsedDateAdded()
+	rec.KEVDueDate = k.DueDate
+	rec.KEVRansomware = k.KnownRansomwareCampaignUse == "Known"
+}
+
+// stateKey is the ingest_state source holding the Runner's position in
+// cve_enriched.
+const stateKey = "CONSOLIDATE"
+
+// Runner keeps cve_consolidated in step with cve_enriched.
+type Runner struct {
+	db        *pgxpool.Pool
+	priority  []string
+	settle    time.Duration // rows this recent wait for the next run, as in cursor.Page
+	batchSize int
+}
+
+// NewRunner creates a Runner that scores CVSS under priority (see
+// cve.ParseCvssPriority).
+func NewRunner(db *pgxpool.Pool, priority []string) *Runner {
+	return &Runner{db: db, priority: priority, settle: time.Minute, batchSize: 1000}
+}
+
+// Run rebuilds the records of CVEs whose cve_enriched rows changed since
+// the last run. The first run consolidates every CVE.
+func (r *Runner) Run(ctx context.Context) error {
+	var raw string
+	err := r.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", stateKey).Scan(&raw)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("read consolidation cursor: %w", err)
+	}
+	after, err := cursor.Parse(raw, cve.EnrichedCursorKeys)
+	if err != nil {
+		slog.Warn("Invalid consolidation cursor, rebuilding", "error", err)
+		after = cursor.Cursor{}
+	}
+
+	var written int64
+	for {
+		ids, next, n, err := r.changed(ctx, after)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		w, err := r.consolidate(ctx, ids)
+		if err != nil {
+			return err
+		}
+		written += w
+		metrics.ConsolidatedRecords.Add(float64(w))
+		after = next
+		if _, err := r.db.Exec(ctx, `
+			INSERT INTO ingest_state (source, cursor) VALUES ($1, $2)
+			ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+		`, stateKey, after.String()); err != nil {
+			return fmt.Errorf("update consolidation cursor: %w", err)
+		}
+		if n < r.batchSize {
+			break
+		}
+	}
+	slog.Info("CVE records consolidated", "written", written)
+	return nil
+}
+
+// Rebuild forgets the Runner's position and consolidates every CVE again,
+// e.g. after [merge] cvss changed. Unchanged records keep their updated_at.
+func (r *Runner) Rebuild(ctx context.Context) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM ingest_state WHERE source = $1", stateKey); err != nil {
+		return fmt.Errorf("reset consolidation cursor: %w", err)
+	}
+	return r.Run(ctx)
+}
+
+// changed returns the distinct CVEs of the next batch of changed
+// cve_enriched rows after pos, the position after the batch and its size.
+// A CVE dropped from KEV has no row left to change; its removal counts as
+// a change of its CISA-KEV row.
+func (r *Runner) changed(ctx context.Context, pos cursor.Cursor) ([]string, cursor.Cursor, int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT cve_id, source, updated_at FROM (
+			SELECT cve_id, source, updated_at FROM cve_enriched
+			UNION ALL
+			SELECT cve_id, 'CISA-KEV', detected_at FROM kev_changes
+			WHERE change_type = 'removed' AND NOT archived
+		) changed
+		WHERE (updated_at, cve_id, source) > ($1, $2, $3)
+		  AND updated_at < clock_timestamp() - $4::interval
+		ORDER BY updated_at, cve_id, source
+		LIMIT $5
+	`, pos.UpdatedAt, pos.KeyPart(0), pos.KeyPart(1), r.settle, r.batchSize)
+	if err != nil {
+		return nil, pos, 0, fmt.Errorf("query changed CVE records: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	n := 0
+	for rows.Next() {
+		var id, source string
+		var at time.Time
+		if err := rows.Scan(&id, &source, &at); err != nil {
+			return nil, pos, 0, fmt.Errorf("scan changed CVE record: %w", err)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+		pos = cursor.Cursor{UpdatedAt: at, Key: []string{id, source}}
+		n++
+	}
+	return ids, pos, n, rows.Err()
+}
+
+// consolidate rebuilds the records of ids and returns how many changed.
+// A CVE without any source record left loses its record.
+func (r *Runner) consolidate(ctx context.Context, ids []string) (int64, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT cve_id, source, cvss_base::float8, modified, first_seen_at, json,
+		       COALESCE(source_identifier, ''), COALESCE(vuln_status, '')
+		FROM cve_enriched
+		WHERE cve_id = ANY($1)
+		ORDER BY cve_id, source
+	`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("query CVE records: %w", err)
+	}
+	byID := map[string][]cve.EnrichedRecord{}
+	for rows.Next() {
+		var e cve.EnrichedRecord
+		if err := rows.Scan(&e.CveID, &e.Source, &e.CvssBase, &e.Modified, &e.FirstSeenAt, &e.Data,
+			&e.SourceIdentifier, &e.VulnStatus); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan CVE record: %w", err)
+		}
+		byID[e.CveID] = append(byID[e.CveID], e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("query CVE records: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	for _, id := range ids {
+		if len(byID[id]) == 0 {
+			batch.Queue("DELETE FROM cve_consolidated WHERE cve_id = $1", id)
+			continue
+		}
+		rec := Build(byID[id], r.priority)
+		if rec.CWEs == nil {
+			rec.CWEs = []string{}
+		}
+		batch.Queue(`
+			INSERT INTO cve_consolidated (
+				cve_id, sources, description, published, modified, vuln_status, cna,
+				cvss_base, cvss_severity, cvss_version, cvss_source, cwes,
+				in_kev, kev_vendor, kev_product, kev_name, kev_date_added, kev_due_date, kev_ransomware,
+				first_seen_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			ON CONFLICT (cve_id) DO UPDATE SET
+				sources = EXCLUDED.sources, description = EXCLUDED.description,
+				published = EXCLUDED.published, modified = EXCLUDED.modified,
+				vuln_status = EXCLUDED.vuln_status, cna = EXCLUDED.cna,
+				cvss_base = EXCLUDED.cvss_base, cvss_severity = EXCLUDED.cvss_severity,
+				cvss_version = EXCLUDED.cvss_version, cvss_source = EXCLUDED.cvss_source,
+				cwes = EXCLUDED.cwes, in_kev = EXCLUDED.in_kev,
+				kev_vendor = EXCLUDED.kev_vendor, kev_product = EXCLUDED.kev_product,
+				kev_name = EXCLUDED.kev_name, kev_date_added = EXCLUDED.kev_date_added,
+				kev_due_date = EXCLUDED.kev_due_date, kev_ransomware = EXCLUDED.kev_ransomware,
+				first_seen_at = EXCLUDED.first_seen_at,
+				updated_at = clock_timestamp()
+			WHERE (cve_consolidated.sources, cve_consolidated.description, cve_consolidated.published,
+			       cve_consolidated.modified, cve_consolidated.vuln_status, cve_consolidated.cna,
+			       cve_consolidated.cvss_base, cve_consolidated.cvss_severity, cve_consolidated.cvss_version,
+			       cve_consolidated.cvss_source, cve_consolidated.cwes, cve_consolidated.in_kev,
+			       cve_consolidated.kev_vendor, cve_consolidated.kev_product, cve_consolidated.kev_name,
+			       cve_consolidated.kev_date_added, cve_consolidated.kev_due_date, cve_consolidated.kev_ransomware,
+			       cve_consolidated.first_seen_at)
+			  IS DISTINCT FROM
+			      (EXCLUDED.sources, EXCLUDED.description, EXCLUDED.published,
+			       EXCLUDED.modified, EXCLUDED.vuln_status, EXCLUDED.cna,
+			       EXCLUDED.cvss_base, EXCLUDED.cvss_severity, EXCLUDED.cvss_version,
+			       EXCLUDED.cvss_source, EXCLUDED.cwes, EXCLUDED.in_kev,
+			       EXCLUDED.kev_vendor, EXCLUDED.kev_product, EXCLUDED.kev_name,
+			       EXCLUDED.kev_date_added, EXCLUDED.kev_due_date, EXCLUDED.kev_ransomware,
+			       EXCLUDED.first_seen_at)
+		`, rec.CveID, rec.Sources, rec.Description, rec.Published, rec.Modified, rec.VulnStatus, rec.CNA,
+			rec.CvssBase, rec.CvssSeverity, rec.CvssVersion, rec.CvssSource, rec.CWEs,
+			rec.InKEV, rec.KEVVendor, rec.KEVProduct, rec.KEVName, rec.KEVDateAdded, rec.KEVDueDate, rec.KEVRansomware,
+			rec.FirstSeenAt)
+	}
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+	var written int64
+	for range batch.Len() {
+		tag, err := br.Exec()
+		if err != nil {
+			return written, fmt.Errorf("write consolidated records: %w", err)
+		}
+		written += tag.RowsAffected()
+	}
+	return written, nil
+}
+
+// List returns one page of consolidated records in (updated_at, cve_id)
+// order.
+func List(ctx context.Context, pool *pgxpool.Pool, page cursor.Page) ([]Record, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT cve_id, sources, description, published, modified, vuln_status, cna,
+		       cvss_base::float8, cvss_severity, cvss_version, cvss_source, cwes,
+		       in_kev, kev_vendor, kev_product, kev_name, kev_date_added, kev_due_date, kev_ransomware,
+		       first_seen_at, updated_at
+		FROM cve_consolidated
+		WHERE (updated_at, cve_id) > ($1, $2)
+		  AND updated_at < clock_timestamp() - $3::interval
+		ORDER BY updated_at, cve_id
+		LIMIT $4
+	`, page.After.UpdatedAt, page.After.KeyPart(0), page.Settle, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list cve_consolidated: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.CveID, &r.Sources, &r.Description, &r.Published, &r.Modified, &r.VulnStatus, &r.CNA,
+			&r.CvssBase, &r.CvssSeverity, &r.CvssVersion, &r.CvssSource, &r.CWEs,
+			&r.InKEV, &r.KEVVendor, &r.KEVProduct, &r.KEVName, &r.KEVDateAdded, &r.KEVDueDate, &r.KEVRansomware,
+			&r.FirstSeenAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan cve_consolidated: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,157 @@
+package consolidate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nvdJSON = `{
+	"id": "CVE-2024-3400",
+	"published": "2024-04-12T08:15:06.230",
+	"descriptions": [
+		{"lang": "es", "value": "Una vulnerabilidad ..."},
+		{"lang": "en", "value": " A command injection vulnerability in GlobalProtect. "}
+	],
+	"weaknesses": [
+		{"source": "nvd@nist.gov", "description": [{"lang": "en", "value": "CWE-77"}]},
+		{"source": "psirt@paloaltonetworks.com", "description": [{"lang": "en", "value": "CWE-20"}, {"lang": "en", "value": "CWE-77"}]},
+		{"source": "nvd@nist.gov", "description": [{"lang": "en", "value": "NVD-CWE-noinfo"}]}
+	],
+	"metrics": {"cvssMetricV31": [
+		{"source": "psirt@paloaltonetworks.com", "type": "Secondary", "cvssData": {"baseScore": 10.0, "baseSeverity": "CRITICAL"}},
+		{"source": "nvd@nist.gov", "type": "Primary", "cvssData": {"baseScore": 9.8, "baseSeverity": "CRITICAL"}}
+	]}
+}`
+
+const kevJSON = `{
+	"cveID": "CVE-2024-3400",
+	"vendorProject": "Palo Alto Networks",
+	"product": "PAN-OS",
+	"vulnerabilityName": "Palo Alto Networks PAN-OS Command Injection Vulnerability",
+	"dateAdded": "2024-04-12",
+	"dueDate": "2024-04-19",
+	"knownRansomwareCampaignUse": "Unknown"
+}`
+
+func TestBuild(t *testing.T) {
+	t0 := time.Date(2024, 4, 12, 9, 0, 0, 0, time.UTC)
+	score := 9.8
+	rows := []cve.EnrichedRecord{
+		{CveID: "CVE-2024-3400", Source: "NVD", CvssBase: &score, Modified: t0.Add(48 * time.Hour), FirstSeenAt: t0.Add(time.Hour),
+			Data: json.RawMessage(nvdJSON), SourceIdentifier: "psirt@paloaltonetworks.com", VulnStatus: cve.VulnStatusAnalyzed},
+		{CveID: "CVE-2024-3400", Source: "CISA-KEV", Modified: t0, FirstSeenAt: t0, Data: json.RawMessage(kevJSON)},
+	}
+
+	rec := Build(rows, nil)
+	assert.Equal(t, "CVE-2024-3400", rec.CveID)
+	assert.Equal(t, []string{"CISA-KEV", "NVD"}, rec.Sources)
+	assert.Equal(t, "A command injection vulnerability in GlobalProtect.", rec.Description)
+	require.NotNil(t, rec.Published)
+	assert.Equal(t, time.Date(2024, 4, 12, 8, 15, 6, 230_000_000, time.UTC), *rec.Published)
+	assert.Equal(t, t0.Add(48*time.Hour), rec.Modified, "latest modification of any source")
+	assert.Equal(t, t0, rec.FirstSeenAt, "earliest first sighting of any source")
+	assert.Equal(t, "psirt@paloaltonetworks.com", rec.CNA)
+	assert.Equal(t, cve.VulnStatusAnalyzed, rec.VulnStatus)
+	assert.Equal(t, []string{"CWE-20", "CWE-77"}, rec.CWEs)
+
+	require.NotNil(t, rec.CvssBase)
+	assert.InDelta(t, 10.0, *rec.CvssBase, 0.001, "NVD's order: the first v3.1 score")
+	assert.Equal(t, cve.CvssSourceCNA, rec.CvssSource)
+	assert.Equal(t, "3.1", rec.CvssVersion)
+
+	assert.True(t, rec.InKEV)
+	assert.Equal(t, "PAN-OS", rec.KEVProduct)
+	require.NotNil(t, rec.KEVDueDate)
+	assert.Equal(t, time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC), *rec.KEVDueDate)
+	assert.False(t, rec.KEVRansomware)
+
+	rec = Build(rows, []string{cve.CvssSourceNVD, cve.CvssSourceCNA})
+	assert.InDelta(t, 9.8, *rec.CvssBase, 0.001, "[merge] cvss decides the score")
+	assert.Equal(t, cve.CvssSourceNVD, rec.CvssSource)
+
+	rec = Build(rows[1:], nil)
+	assert.Equal(t, []string{"CISA-KEV"}, rec.Sources)
+	assert.Nil(t, rec.CvssBase)
+	assert.Empty(t, rec.Description)
+}
+
+func TestRun(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	id := "CVE-1999-990101"
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = $1", id)
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_consolidated WHERE cve_id = $1", id)
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id = $1", id)
+	}
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified, source_identifier) VALUES
+		  ($1, 'NVD', $2, 9.8, now(), 'psirt@paloaltonetworks.com'),
+		  ($1, 'CISA-KEV', $3, NULL, now(), NULL)`, id, nvdJSON, kevJSON)
+	require.NoError(t, err)
+
+	r := NewRunner(pool, nil)
+	r.settle = 0
+	require.NoError(t, r.Run(ctx))
+
+	find := func() *Record {
+		list, err := List(ctx, pool, cursor.Page{Limit: 1_000_000})
+		require.NoError(t, err)
+		for i := range list {
+			if list[i].CveID == id {
+				return &list[i]
+			}
+		}
+		return nil
+	}
+	rec := find()
+	require.NotNil(t, rec)
+	assert.True(t, rec.InKEV)
+	assert.Equal(t, []string{"CISA-KEV", "NVD"}, rec.Sources)
+	assert.InDelta(t, 9.8, *rec.CvssBase, 0.001)
+	updated := rec.UpdatedAt
+
+	// Nothing changed: the record keeps its updated_at.
+	require.NoError(t, r.Rebuild(ctx))
+	assert.Equal(t, updated, find().UpdatedAt)
+
+	// A changed source record is picked up on the next run.
+	_, err = pool.Exec(ctx, `UPDATE cve_enriched SET json = jsonb_set(json, '{knownRansomwareCampaignUse}', '"Known"') WHERE cve_id = $1 AND source = 'CISA-KEV'`, id)
+	require.NoError(t, err)
+	require.NoError(t, r.Run(ctx))
+	rec = find()
+	assert.True(t, rec.KEVRansomware)
+	assert.True(t, rec.UpdatedAt.After(updated))
+
+	// A CVE dropped from KEV loses its KEV row; the recorded removal
+	// rebuilds it.
+	_, err = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = $1 AND source = 'CISA-KEV'", id)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO kev_changes (cve_id, change_type, catalog_version, json) VALUES ($1, 'removed', '1999.01.02', $2)`, id, kevJSON)
+	require.NoError(t, err)
+	require.NoError(t, r.Run(ctx))
+	rec = find()
+	assert.False(t, rec.InKEV)
+	assert.Equal(t, []string{"NVD"}, rec.Sources)
+}
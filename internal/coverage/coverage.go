@@ -0,0 +1,241 @@
+// Package coverage tracks CVEs that advisories reference before EPSS has
+// scored them or NVD has published them, which is common in the first days
+// after disclosure. Such CVEs sort to the bottom of score-sorted views; the
+// Runner records them in coverage_gaps, with when each score or record
+// arrived, so analysts can list what to judge manually and how long the
+// gaps last.
+package coverage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/query"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// What a gap is missing.
+const (
+	MissingEPSS = "epss"
+	MissingNVD  = "nvd"
+)
+
+// Gap is a CVE that had no EPSS score or no NVD record when first seen
+// referenced by an advisory.
+type Gap struct {
+	CveID             string     `json:"cve_id"`
+	FirstReferencedAt time.Time  `json:"first_referenced_at"` // first seen time of the earliest advisory naming it
+	Advisories        int        `json:"advisories"`          // advisories naming it, at the last check in the lookback
+	DetectedAt        time.Time  `json:"detected_at"`
+	CheckedAt         time.Time  `json:"checked_at"`
+	EPSSAt            *time.Time `json:"epss_at"` // when an EPSS score was first seen; nil while missing
+	NVDAt             *time.Time `json:"nvd_at"`  // when the NVD record was first seen; nil while missing
+}
+
+// Missing lists what the CVE still lacks, MissingEPSS and MissingNVD.
+func (g Gap) Missing() []string {
+	var out []string
+	if g.EPSSAt == nil {
+		out = append(out, MissingEPSS)
+	}
+	if g.NVDAt == nil {
+		out = append(out, MissingNVD)
+	}
+	return out
+}
+
+// Open reports whether the CVE still lacks anything.
+func (g Gap) Open() bool { return g.EPSSAt == nil || g.NVDAt == nil }
+
+// Waited is how long the CVE went without full coverage after it was first
+// referenced: until now for an open gap.
+func (g Gap) Waited(now time.Time) time.Duration {
+	end := now
+	if !g.Open() {
+		end = *g.EPSSAt
+		if g.NVDAt.After(end) {
+			end = *g.NVDAt
+		}
+	}
+	return max(end.Sub(g.FirstReferencedAt), 0)
+}
+
+// reference is a CVE as the advisories in the lookback name it.
+type reference struct {
+	first      time.Time
+	advisories int
+}
+
+// addReferences adds the CVEs advs name to out.
+func addReferences(out map[string]reference, advs []query.Advisory) {
+	for _, a := range advs {
+		for _, id := range a.CVEs {
+			ref, ok := out[id]
+			if !ok || a.FirstSeenAt.Before(ref.first) {
+				ref.first = a.FirstSeenAt
+			}
+			ref.advisories++
+			out[id] = ref
+		}
+	}
+}
+
+// Runner records coverage gaps of the CVEs named by recent advisories.
+type Runner struct {
+	db       *pgxpool.Pool
+	lookback time.Duration
+}
+
+// NewRunner creates a Runner for cfg.
+func NewRunner(db *pgxpool.Pool, cfg config.CoverageConfig) *Runner {
+	lookback, err := cfg.GetLookback()
+	if err != nil || lookback <= 0 {
+		slog.Warn("Invalid coverage lookback, using default 720h", "value", cfg.Lookback, "error", err)
+		lookback = 720 * time.Hour
+	}
+	return &Runner{db: db, lookback: lookback}
+}
+
+// Run checks the CVEs named by advisories first seen in the lookback, and
+// the open gaps, for an EPSS score and an NVD record. New gaps are
+// recorded; open ones are closed as their score and record arrive.
+func (r *Runner) Run(ctx context.Context) error {
+	// Only the CVE IDs are kept, not the advisories naming them
+	refs := map[string]reference{}
+	err := query.Each(ctx, r.db, nil, nil, nil, window.Window{Field: window.FirstSeen, Since: time.Now().Add(-r.lookback)}, 0,
+		func(advs []query.Advisory) error {
+			addReferences(refs, advs)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(refs))
+	for id := range refs {
+		ids = append(ids, id)
+	}
+	open, err := openIDs(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	for _, id := range open {
+		if _, ok := refs[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	batch := &pgx.Batch{}
+	rows, err := r.db.Query(ctx, `
+		SELECT id,
+		       EXISTS (SELECT 1 FROM epss_daily WHERE cve_id = id AND as_of >= CURRENT_DATE - 31),
+		       EXISTS (SELECT 1 FROM cve_enriched WHERE cve_id = id AND source = 'NVD')
+		FROM unnest($1::text[]) AS id
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("query CVE coverage: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var epss, nvd bool
+		if err := rows.Scan(&id, &epss, &nvd); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan CVE coverage: %w", err)
+		}
+		if ref, ok := refs[id]; ok && (!epss || !nvd) {
+			batch.Queue(`
+				INSERT INTO coverage_gaps (cve_id, first_referenced_at, advisories, epss_at, nvd_at)
+				VALUES ($1, $2, $3, CASE WHEN $4 THEN now() END, CASE WHEN $5 THEN now() END)
+				ON CONFLICT (cve_id) DO UPDATE SET
+					first_referenced_at = LEAST(coverage_gaps.first_referenced_at, EXCLUDED.first_referenced_at),
+					advisories = EXCLUDED.advisories,
+					checked_at = now(),
+					epss_at = COALESCE(coverage_gaps.epss_at, EXCLUDED.epss_at),
+					nvd_at = COALESCE(coverage_gaps.nvd_at, EXCLUDED.nvd_at)
+			`, id, ref.first, ref.advisories, epss, nvd)
+			continue
+		}
+		batch.Queue(`
+			UPDATE coverage_gaps SET
+				checked_at = now(),
+				epss_at = COALESCE(epss_at, CASE WHEN $2 THEN now() END),
+				nvd_at = COALESCE(nvd_at, CASE WHEN $3 THEN now() END)
+			WHERE cve_id = $1 AND (epss_at IS NULL OR nvd_at IS NULL)
+		`, id, epss, nvd)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query CVE coverage: %w", err)
+	}
+	if batch.Len() > 0 {
+		if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("record coverage gaps: %w", err)
+		}
+	}
+
+	var noEPSS, noNVD int
+	if err := r.db.QueryRow(ctx, `
+		SELECT count(*) FILTER (WHERE epss_at IS NULL), count(*) FILTER (WHERE nvd_at IS NULL)
+		FROM coverage_gaps
+	`).Scan(&noEPSS, &noNVD); err != nil {
+		return fmt.Errorf("count coverage gaps: %w", err)
+	}
+	metrics.CoverageGaps.WithLabelValues(MissingEPSS).Set(float64(noEPSS))
+	metrics.CoverageGaps.WithLabelValues(MissingNVD).Set(float64(noNVD))
+	slog.Info("Coverage gaps updated", "referenced", len(refs), "missing_epss", noEPSS, "missing_nvd", noNVD)
+	return nil
+}
+
+func openIDs(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+	rows, err := db.Query(ctx, `SELECT cve_id FROM coverage_gaps WHERE epss_at IS NULL OR nvd_at IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("query open coverage gaps: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan open coverage gaps: %w", err)
+	}
+	return ids, nil
+}
+
+// ListOptions selects gaps for List.
+type ListOptions struct {
+	All     bool   // include closed gaps
+	Missing string // only gaps missing this, MissingEPSS or MissingNVD; empty for either
+}
+
+// List returns the recorded gaps, most recently referenced first.
+func List(ctx context.Context, db *pgxpool.Pool, opts ListOptions) ([]Gap, error) {
+	switch opts.Missing {
+	case "", MissingEPSS, MissingNVD:
+	default:
+		return nil, fmt.Errorf("invalid missing %q: want %s or %s", opts.Missing, MissingEPSS, MissingNVD)
+	}
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, first_referenced_at, advisories, detected_at, checked_at, epss_at, nvd_at
+		FROM coverage_gaps
+		WHERE ($1 OR epss_at IS NULL OR nvd_at IS NULL)
+		  AND ($2 = '' OR ($2 = 'epss' AND epss_at IS NULL) OR ($2 = 'nvd' AND nvd_at IS NULL))
+		ORDER BY first_referenced_at DESC, cve_id
+	`, opts.All, opts.Missing)
+	if err != nil {
+		return nil, fmt.Errorf("query coverage gaps: %w", err)
+	}
+	defer rows.Close()
+	var out []Gap
+	for rows.Next() {
+		var g Gap
+		if err := rows.Scan(&g.CveID, &g.FirstReferencedAt, &g.Advisories, &g.DetectedAt, &g.CheckedAt, &g.EPSSAt, &g.NVDAt); err != nil {
+			return nil, fmt.Errorf("scan coverage gap: %w", err)
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,128 @@
+package coverage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+	"tiger2go/internal/query"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddReferences(t *testing.T) {
+	t0 := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	got := map[string]reference{}
+	addReferences(got, []query.Advisory{
+		{FirstSeenAt: t0.Add(time.Hour), CVEs: []string{"CVE-2024-0001", "CVE-2024-0002"}},
+		{FirstSeenAt: t0},
+	})
+	addReferences(got, []query.Advisory{{FirstSeenAt: t0, CVEs: []string{"CVE-2024-0001"}}})
+	assert.Equal(t, map[string]reference{
+		"CVE-2024-0001": {first: t0, advisories: 2},
+		"CVE-2024-0002": {first: t0.Add(time.Hour), advisories: 1},
+	}, got)
+}
+
+func TestGap(t *testing.T) {
+	t0 := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	epss, nvd := t0.Add(24*time.Hour), t0.Add(72*time.Hour)
+
+	g := Gap{FirstReferencedAt: t0}
+	assert.True(t, g.Open())
+	assert.Equal(t, []string{MissingEPSS, MissingNVD}, g.Missing())
+	assert.Equal(t, 96*time.Hour, g.Waited(t0.Add(96*time.Hour)))
+
+	g.EPSSAt = &epss
+	assert.Equal(t, []string{MissingNVD}, g.Missing())
+
+	g.NVDAt = &nvd
+	assert.False(t, g.Open())
+	assert.Empty(t, g.Missing())
+	assert.Equal(t, 72*time.Hour, g.Waited(t0.Add(96*time.Hour)), "a closed gap lasts until the later arrival")
+}
+
+func TestRun(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ids := []string{"CVE-1999-990001", "CVE-1999-990002", "CVE-1999-990003"}
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM current WHERE feed_url = 'https://coverage.test/feed'")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = ANY($1)", ids)
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE cve_id = ANY($1)", ids)
+		_, _ = pool.Exec(ctx, "DELETE FROM coverage_gaps WHERE cve_id = ANY($1)", ids)
+	}
+	cleanup()
+	defer cleanup()
+
+	// 990001 is fully covered, 990002 has NVD only, 990003 nothing yet.
+	_, err = pool.Exec(ctx, `
+		INSERT INTO current (guid, title, link, summary, feed_url) VALUES
+		  ('coverage-1', 'CVE-1999-990001 and CVE-1999-990002', 'https://coverage.test/1', '', 'https://coverage.test/feed'),
+		  ('coverage-2', 'CVE-1999-990002, CVE-1999-990003', 'https://coverage.test/2', '', 'https://coverage.test/feed')`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified) VALUES
+		  ('CVE-1999-990001', 'NVD', '{}', 7.5, now()),
+		  ('CVE-1999-990002', 'NVD', '{}', 9.8, now())`)
+	require.NoError(t, err)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	require.NoError(t, cve.EnsureEpssPartition(ctx, pool, today))
+	_, err = pool.Exec(ctx, `INSERT INTO epss_daily (as_of, cve_id, epss, percentile) VALUES ($1, 'CVE-1999-990001', 0.1, 0.9)`, today)
+	require.NoError(t, err)
+
+	r := NewRunner(pool, config.CoverageConfig{})
+	require.NoError(t, r.Run(ctx))
+
+	gaps := map[string]Gap{}
+	list, err := List(ctx, pool, ListOptions{})
+	require.NoError(t, err)
+	for _, g := range list {
+		gaps[g.CveID] = g
+	}
+	assert.NotContains(t, gaps, "CVE-1999-990001")
+	require.Contains(t, gaps, "CVE-1999-990002")
+	assert.Equal(t, []string{MissingEPSS}, gaps["CVE-1999-990002"].Missing())
+	assert.Equal(t, 2, gaps["CVE-1999-990002"].Advisories)
+	require.Contains(t, gaps, "CVE-1999-990003")
+	assert.Equal(t, []string{MissingEPSS, MissingNVD}, gaps["CVE-1999-990003"].Missing())
+
+	// Once the advisories are gone, the open gap is still rechecked and closes.
+	_, err = pool.Exec(ctx, "DELETE FROM current WHERE feed_url = 'https://coverage.test/feed'")
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO epss_daily (as_of, cve_id, epss, percentile) VALUES ($1, 'CVE-1999-990002', 0.2, 0.95)`, today)
+	require.NoError(t, err)
+	require.NoError(t, r.Run(ctx))
+
+	list, err = List(ctx, pool, ListOptions{Missing: MissingEPSS})
+	require.NoError(t, err)
+	for _, g := range list {
+		assert.NotEqual(t, "CVE-1999-990002", g.CveID)
+	}
+	list, err = List(ctx, pool, ListOptions{All: true})
+	require.NoError(t, err)
+	var closed *Gap
+	for i := range list {
+		if list[i].CveID == "CVE-1999-990002" {
+			closed = &list[i]
+		}
+	}
+	require.NotNil(t, closed, "closed gaps are kept for --all")
+	assert.False(t, closed.Open())
+
+	_, err = List(ctx, pool, ListOptions{Missing: "cvss"})
+	assert.Error(t, err)
+}
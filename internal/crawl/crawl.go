@@ -0,0 +1,327 @@
+// Package crawl makes fetches of pages we were not given as feeds polite:
+// the deep fetches of vendor advisory pages and the backfill of feed
+// archives. A Crawler honours robots.txt, sends one request at a time to a
+// host and pauses between them. Hosts we own skip all of it.
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+)
+
+// ErrDisallowed is returned for requests robots.txt forbids.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Defaults for Options.
+const (
+	DefaultDelay     = 5 * time.Second
+	DefaultRobotsTTL = 24 * time.Hour
+	DefaultTimeout   = 30 * time.Second
+)
+
+const (
+	maxRobotsBytes     = 512 << 10 // RFC 9309 asks crawlers to read at least 500 KiB
+	maxRobotsRedirects = 5
+	maxCrawlDelay      = time.Minute // a larger Crawl-delay would stall a run
+	unreachableTTL     = time.Hour   // a host whose robots.txt failed is asked again after this
+)
+
+// Options configure a Crawler.
+type Options struct {
+	// UserAgent is sent with robots.txt requests; its product token
+	// ("tigerfetch" in "tigerfetch/1.0") selects the robots.txt group.
+	UserAgent string
+	// Delay is the pause between requests to one host, lengthened to a
+	// host's Crawl-delay up to a minute. Zero means DefaultDelay;
+	// negative means none.
+	Delay time.Duration
+	// Robots honours robots.txt.
+	Robots bool
+	// RobotsTTL is how long a host's robots.txt is cached. Zero means
+	// DefaultRobotsTTL.
+	RobotsTTL time.Duration
+	// Timeout bounds a request from when it is sent until its body is
+	// closed; waiting for its host does not count, so use it instead of
+	// http.Client.Timeout. Zero means DefaultTimeout; negative means none.
+	Timeout time.Duration
+	// OwnHosts are exempt from robots.txt, the per-host limit and the
+	// delay. An entry starting with "." matches any subdomain.
+	OwnHosts []string
+}
+
+// FromConfig reads the [crawl] section. reference_labels.host_delay, from
+// before the section existed, still sets the delay when crawl.host_delay
+// does not.
+func FromConfig(cfg *config.Config, userAgent string) (Options, error) {
+	c := cfg.Crawl
+	opts := Options{UserAgent: userAgent, Robots: c.Robots, OwnHosts: c.OwnHosts}
+	delay, raw := c.HostDelay, "crawl.host_delay"
+	if delay == "" && cfg.ReferenceLabels.HostDelay != "" {
+		delay, raw = cfg.ReferenceLabels.HostDelay, "reference_labels.host_delay"
+	}
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return opts, fmt.Errorf("%s %q: %w", raw, delay, err)
+		}
+		opts.Delay = d
+		if d == 0 {
+			opts.Delay = -1
+		}
+	}
+	ttl, err := c.GetRobotsTTL()
+	if err != nil || ttl < 0 {
+		return opts, fmt.Errorf("crawl.robots_ttl %q must be a positive duration", c.RobotsTTL)
+	}
+	opts.RobotsTTL = ttl
+	return opts, nil
+}
+
+// Crawler keeps the per-host state shared by every transport it wraps. A
+// nil *Crawler is not polite at all, so components can take one
+// unconditionally.
+type Crawler struct {
+	opts  Options
+	agent string // product token
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+type host struct {
+	slot      chan struct{} // held for a request until its body is closed
+	next      time.Time     // earliest next request; guarded by slot
+	robots    *robots       // guarded by slot
+	robotsExp time.Time
+}
+
+// New returns a Crawler with opts.
+func New(opts Options) *Crawler {
+	if opts.Delay == 0 {
+		opts.Delay = DefaultDelay
+	}
+	opts.Delay = max(opts.Delay, 0)
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.RobotsTTL <= 0 {
+		opts.RobotsTTL = DefaultRobotsTTL
+	}
+	agent, _, _ := strings.Cut(opts.UserAgent, "/")
+	return &Crawler{opts: opts, agent: strings.TrimSpace(agent), hosts: map[string]*host{}}
+}
+
+// Own reports whether host is one of Options.OwnHosts.
+func (c *Crawler) Own(host string) bool {
+	host = strings.ToLower(host)
+	for _, o := range c.opts.OwnHosts {
+		o = strings.ToLower(strings.TrimSpace(o))
+		if host == o || strings.HasPrefix(o, ".") && (strings.HasSuffix(host, o) || host == o[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport makes requests through next (http.DefaultTransport when nil)
+// politely. A request waits for its host's previous one to be read and
+// for the delay after it, then fails with ErrDisallowed if robots.txt
+// forbids it. Redirects pass through the transport again, so each hop is
+// checked. A nil Crawler returns next as is.
+func (c *Crawler) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{crawler: c, next: next}
+}
+
+type transport struct {
+	crawler *Crawler
+	next    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.crawler
+	name := strings.ToLower(req.URL.Hostname())
+	if c.Own(name) {
+		return t.next.RoundTrip(req)
+	}
+	ctx := req.Context()
+	h := c.host(name)
+	select {
+	case h.slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() {
+		h.next = time.Now().Add(c.delay(h))
+		<-h.slot
+	}
+
+	if c.opts.Robots {
+		if err := c.loadRobots(ctx, t.next, req.URL, h); err != nil {
+			<-h.slot
+			return nil, err
+		}
+		if !h.robots.allowed(req.URL.RequestURI()) {
+			<-h.slot
+			metrics.CrawlDisallowed.Inc()
+			return nil, fmt.Errorf("%w: %s", ErrDisallowed, req.URL.Redacted())
+		}
+	}
+	if err := sleepUntil(ctx, h.next); err != nil {
+		<-h.slot
+		return nil, err
+	}
+	cancel := context.CancelFunc(func() {})
+	if c.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		req = req.WithContext(ctx)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	resp.Body = &body{ReadCloser: resp.Body, release: func() { cancel(); release() }}
+	return resp, nil
+}
+
+// Unwrap returns the wrapped transport.
+func (t *transport) Unwrap() http.RoundTripper { return t.next }
+
+// body releases its host when closed.
+type body struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (c *Crawler) host(name string) *host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.hosts[name]
+	if !ok {
+		h = &host{slot: make(chan struct{}, 1)}
+		c.hosts[name] = h
+	}
+	return h
+}
+
+// delay is the pause after a request to h.
+func (c *Crawler) delay(h *host) time.Duration {
+	d := c.opts.Delay
+	if h.robots != nil {
+		d = max(d, min(h.robots.crawlDelay, maxCrawlDelay))
+	}
+	return d
+}
+
+// loadRobots fetches the robots.txt of u's host into h unless cached. It
+// is called holding h's slot; its request counts towards the delay. A
+// missing robots.txt allows everything, an unreachable one (5xx, 429 or a
+// network error) nothing, per RFC 9309.
+func (c *Crawler) loadRobots(ctx context.Context, next http.RoundTripper, u *url.URL, h *host) error {
+	if h.robots != nil && time.Now().Before(h.robotsExp) {
+		return nil
+	}
+	if err := sleepUntil(ctx, h.next); err != nil {
+		return err
+	}
+	rctx := ctx
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+	r, status, err := c.fetchRobots(rctx, next, &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"})
+	h.next = time.Now().Add(c.opts.Delay)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	ttl := c.opts.RobotsTTL
+	switch {
+	case err != nil || status == http.StatusTooManyRequests || status >= 500:
+		slog.Info("robots.txt unreachable, not crawling host", "host", u.Hostname(), "status", status, "error", err)
+		metrics.CrawlRobots.WithLabelValues("unreachable").Inc()
+		r, ttl = disallowAll, min(ttl, unreachableTTL)
+	case status >= 400:
+		metrics.CrawlRobots.WithLabelValues("missing").Inc()
+		r = allowAll
+	default:
+		metrics.CrawlRobots.WithLabelValues("ok").Inc()
+	}
+	h.robots, h.robotsExp = r, time.Now().Add(ttl)
+	return nil
+}
+
+// fetchRobots GETs robots.txt at u, following up to five redirects, and
+// parses it on a 2xx answer.
+func (c *Crawler) fetchRobots(ctx context.Context, next http.RoundTripper, u *url.URL) (*robots, int, error) {
+	for range maxRobotsRedirects + 1 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if c.opts.UserAgent != "" {
+			req.Header.Set("User-Agent", c.opts.UserAgent)
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+		_ = resp.Body.Close()
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			loc, lerr := resp.Location()
+			if lerr != nil {
+				return nil, resp.StatusCode, lerr
+			}
+			u = loc
+			continue
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			return nil, resp.StatusCode, nil
+		case err != nil:
+			return nil, resp.StatusCode, err
+		}
+		return parseRobots(data, c.agent), resp.StatusCode, nil
+	}
+	return nil, 0, fmt.Errorf("more than %d redirects", maxRobotsRedirects)
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
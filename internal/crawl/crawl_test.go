@@ -0,0 +1,187 @@
+package crawl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, error) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestTransport_Robots(t *testing.T) {
+	var robotsFetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			assert.Equal(t, "tigerfetch/1.0 (+test)", r.UserAgent())
+			_, _ = io.WriteString(w, "User-agent: tigerfetch\nDisallow: /private\n")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := New(Options{UserAgent: "tigerfetch/1.0 (+test)", Robots: true, Delay: -1})
+	client := &http.Client{Transport: c.Transport(nil)}
+
+	status, err := get(t, client, srv.URL+"/public")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	_, err = get(t, client, srv.URL+"/private/page")
+	assert.ErrorIs(t, err, ErrDisallowed)
+	assert.EqualValues(t, 1, robotsFetches.Load(), "robots.txt is cached")
+
+	c.opts.Robots = false
+	_, err = get(t, client, srv.URL+"/private/page")
+	assert.NoError(t, err)
+}
+
+func TestTransport_RobotsStatus(t *testing.T) {
+	for status, allowed := range map[int]bool{
+		http.StatusNotFound:            true,
+		http.StatusForbidden:           true,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(status)
+			}
+		}))
+		client := &http.Client{Transport: New(Options{Robots: true, Delay: -1}).Transport(nil)}
+		_, err := get(t, client, srv.URL+"/page")
+		if allowed {
+			assert.NoError(t, err, "robots.txt status %d", status)
+		} else {
+			assert.ErrorIs(t, err, ErrDisallowed, "robots.txt status %d", status)
+		}
+		srv.Close()
+	}
+}
+
+func TestTransport_DelayAndOneRequestPerHost(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		times = append(times, time.Now())
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	const delay = 50 * time.Millisecond
+	client := &http.Client{Transport: New(Options{Delay: delay}).Transport(nil)}
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			_, err := get(t, client, srv.URL)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxInFlight)
+	require.Len(t, times, 3)
+	for i := 1; i < len(times); i++ {
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), delay)
+	}
+}
+
+func TestTransport_OwnHosts(t *testing.T) {
+	var robotsFetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			_, _ = io.WriteString(w, "User-agent: *\nDisallow: /\n")
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Options{Robots: true, Delay: time.Hour, OwnHosts: []string{"127.0.0.1"}})
+	client := &http.Client{Transport: c.Transport(nil)}
+	for range 2 {
+		_, err := get(t, client, srv.URL+"/page")
+		require.NoError(t, err, "no robots.txt and no pause for our own hosts")
+	}
+	assert.Zero(t, robotsFetches.Load())
+}
+
+func TestTransport_WaitHonoursContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: New(Options{Delay: time.Hour}).Transport(nil)}
+	_, err := get(t, client, srv.URL)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOwn(t *testing.T) {
+	c := New(Options{OwnHosts: []string{"tigerblue.app", ".example.com"}})
+	assert.True(t, c.Own("tigerblue.app"))
+	assert.True(t, c.Own("TigerBlue.app"))
+	assert.False(t, c.Own("api.tigerblue.app"))
+	assert.True(t, c.Own("example.com"))
+	assert.True(t, c.Own("www.example.com"))
+	assert.False(t, c.Own("notexample.com"))
+}
+
+func TestNilCrawler(t *testing.T) {
+	var c *Crawler
+	assert.Equal(t, http.DefaultTransport, c.Transport(http.DefaultTransport))
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := &config.Config{Crawl: config.CrawlConfig{Robots: true, OwnHosts: []string{"tigerblue.app"}}}
+	opts, err := FromConfig(cfg, "tigerfetch/1.0")
+	require.NoError(t, err)
+	assert.Equal(t, Options{UserAgent: "tigerfetch/1.0", Robots: true, RobotsTTL: 24 * time.Hour, OwnHosts: []string{"tigerblue.app"}}, opts)
+
+	cfg.ReferenceLabels.HostDelay = "10s"
+	opts, err = FromConfig(cfg, "")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, opts.Delay, "reference_labels.host_delay is the fallback")
+
+	cfg.Crawl.HostDelay = "0s"
+	opts, err = FromConfig(cfg, "")
+	require.NoError(t, err)
+	assert.Negative(t, opts.Delay, "zero disables the pause")
+
+	for _, c := range []config.CrawlConfig{{HostDelay: "soon"}, {RobotsTTL: "-1h"}} {
+		_, err := FromConfig(&config.Config{Crawl: c}, "")
+		assert.Error(t, err, "%+v", c)
+	}
+}
@@ -0,0 +1,131 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots holds the rules of one robots.txt (RFC 9309) that apply to one
+// user agent.
+type robots struct {
+	rules      []rule
+	crawlDelay time.Duration // the non-standard Crawl-delay; 0 when absent
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+var (
+	allowAll    = &robots{}
+	disallowAll = &robots{rules: []rule{{pattern: "/"}}}
+)
+
+// parseRobots returns the rules of data for the product token agent: the
+// groups naming it, or else the "*" groups. Unknown lines are ignored.
+func parseRobots(data []byte, agent string) *robots {
+	agent = strings.ToLower(agent)
+	type group struct {
+		agents     []string
+		rules      []rule
+		crawlDelay time.Duration
+	}
+	var groups []*group
+	var g *group
+	inAgents := false // the previous line was a User-agent line
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 4096), maxRobotsBytes)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				g = &group{}
+				groups = append(groups, g)
+			}
+			g.agents = append(g.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			if g != nil && value != "" {
+				g.rules = append(g.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if s, err := strconv.ParseFloat(value, 64); g != nil && err == nil && s > 0 {
+				g.crawlDelay = time.Duration(s * float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+
+	r := &robots{}
+	for _, want := range []string{agent, "*"} {
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if token, _, _ := strings.Cut(a, "/"); token == want {
+					r.rules = append(r.rules, g.rules...)
+					r.crawlDelay = max(r.crawlDelay, g.crawlDelay)
+					break
+				}
+			}
+		}
+		if len(r.rules) > 0 || r.crawlDelay > 0 {
+			break
+		}
+	}
+	return r
+}
+
+// allowed reports whether path, with its query, may be fetched: the
+// longest matching rule decides, and Allow wins a tie.
+func (r *robots) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	best, allow := -1, true
+	for _, ru := range r.rules {
+		if !match(ru.pattern, path) {
+			continue
+		}
+		if n := len(ru.pattern); n > best || n == best && ru.allow {
+			best, allow = n, ru.allow
+		}
+	}
+	return allow
+}
+
+// match reports whether pattern matches path from its start, "*" standing
+// for any characters and a trailing "$" for the end of the path.
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, p)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(p):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const robotsTxt = `# example
+User-agent: *
+Disallow: /private/
+Crawl-delay: 2
+
+User-agent: Googlebot
+User-agent: TigerFetch/1.0
+Disallow: /search
+Allow: /search/about
+Disallow: /*.pdf$
+Disallow: /tmp/*/cache
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParseRobots_AgentGroup(t *testing.T) {
+	r := parseRobots([]byte(robotsTxt), "tigerfetch")
+	assert.Equal(t, 500*time.Millisecond, r.crawlDelay)
+	for path, want := range map[string]bool{
+		"/":                      true,
+		"/private/x":             true, // only the "*" group says so
+		"/search?q=cve":          false,
+		"/search/about":          true, // longer Allow wins
+		"/docs/advisory.pdf":     false,
+		"/docs/advisory.pdf?v=2": true, // "$" anchors the end
+		"/tmp/a/b/cache/x":       false,
+		"/robots.txt":            true,
+	} {
+		assert.Equal(t, want, r.allowed(path), path)
+	}
+}
+
+func TestParseRobots_Wildcard(t *testing.T) {
+	r := parseRobots([]byte(robotsTxt), "otherbot")
+	assert.Equal(t, 2*time.Second, r.crawlDelay)
+	assert.False(t, r.allowed("/private/x"))
+	assert.True(t, r.allowed("/search"))
+
+	r = parseRobots([]byte("User-agent: somebot\nDisallow: /\n"), "tigerfetch")
+	assert.True(t, r.allowed("/anything"), "no group applies")
+
+	r = parseRobots([]byte("User-agent: *\nDisallow:\n"), "tigerfetch")
+	assert.True(t, r.allowed("/anything"), "empty Disallow allows all")
+}
+
+func TestParseRobots_TieGoesToAllow(t *testing.T) {
+	r := parseRobots([]byte("User-agent: *\nDisallow: /page\nAllow: /page\n"), "tigerfetch")
+	assert.True(t, r.allowed("/page"))
+}
+
+func TestMatch(t *testing.T) {
+	assert.True(t, match("/", "/a"))
+	assert.True(t, match("/a*", "/a"))
+	assert.True(t, match("/*/b", "/a/x/b/c"))
+	assert.True(t, match("/a$", "/a"))
+	assert.False(t, match("/a$", "/ab"))
+	assert.False(t, match("/b", "/a/b"))
+	assert.True(t, match("*.php$", "/x/index.php"))
+	assert.False(t, match("*.php$", "/x/index.php5"))
+}
@@ -0,0 +1,37 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := Cursor{UpdatedAt: time.Date(2024, 1, 22, 17, 15, 10, 123456000, time.UTC), Key: []string{"CVE-2024-21887", "NVD"}}
+	s := c.String()
+	assert.NotContains(t, s, "CVE", "cursors are opaque")
+
+	got, err := Parse(s, 2)
+	require.NoError(t, err)
+	assert.True(t, c.UpdatedAt.Equal(got.UpdatedAt), "microseconds survive")
+	assert.Equal(t, c.Key, got.Key)
+	assert.Equal(t, "NVD", got.KeyPart(1))
+}
+
+func TestZero(t *testing.T) {
+	assert.Empty(t, Cursor{}.String())
+	c, err := Parse("", 2)
+	require.NoError(t, err)
+	assert.True(t, c.IsZero())
+	assert.Empty(t, c.KeyPart(0))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	good := Cursor{UpdatedAt: time.Now(), Key: []string{"a"}}.String()
+	for _, s := range []string{"!!!", "e30", good} {
+		_, err := Parse(s, 2)
+		assert.ErrorIs(t, err, ErrInvalid, s)
+	}
+}
@@ -0,0 +1,116 @@
+package cve
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CNAOptions selects the NVD CVEs a CNA breakdown covers: those first seen
+// in [Since, Until), optionally only those matched to monitored products.
+type CNAOptions struct {
+	Since    time.Time // zero for no lower bound
+	Until    time.Time // zero for no upper bound
+	Products bool      // only CVEs in product_cves; the window applies to first_matched_at
+	Product  string    // with Products, only this product
+}
+
+// CNAStat is one issuing CNA's share of the selected CVEs.
+type CNAStat struct {
+	SourceIdentifier string   `json:"source_identifier"` // empty for records stored without one
+	CVEs             int      `json:"cves"`
+	AwaitingAnalysis int      `json:"awaiting_analysis"` // Received, Awaiting or Undergoing Analysis
+	Analyzed         int      `json:"analyzed"`          // Analyzed or Modified
+	InKEV            int      `json:"in_kev"`
+	MaxCVSS          *float64 `json:"max_cvss"`
+}
+
+// CNAReport breaks the selected CVEs down by issuing CNA and NVD status.
+type CNAReport struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"` // vulnStatus -> CVEs; "" for unknown
+	CNAs     []CNAStat      `json:"cnas"`      // most CVEs first
+}
+
+// pendingStatuses are the vulnStatus values of CVEs NVD has not analysed
+// yet: no CVSS score or CPE configuration from NVD itself.
+var pendingStatuses = []string{VulnStatusReceived, VulnStatusAwaitingAnalysis, VulnStatusUndergoingAnalysis}
+
+// cnaScope selects the CVEs for opts; $1-$4 are cnaArgs.
+const cnaScope = `
+	FROM cve_enriched e
+	LEFT JOIN product_cves p ON $3 AND p.cve_id = e.cve_id AND ($4 = '' OR p.product = $4)
+	WHERE e.source = 'NVD'
+	  AND (NOT $3 OR p.cve_id IS NOT NULL)
+	  AND ($1::timestamptz IS NULL OR CASE WHEN $3 THEN p.first_matched_at ELSE e.first_seen_at END >= $1)
+	  AND ($2::timestamptz IS NULL OR CASE WHEN $3 THEN p.first_matched_at ELSE e.first_seen_at END < $2)`
+
+func cnaArgs(opts CNAOptions) []any {
+	var since, until *time.Time
+	if !opts.Since.IsZero() {
+		since = &opts.Since
+	}
+	if !opts.Until.IsZero() {
+		until = &opts.Until
+	}
+	return []any{since, until, opts.Products, opts.Product}
+}
+
+// CNABreakdown reports which CNAs issued the selected NVD CVEs and how many
+// are still awaiting NVD analysis. A CVE matched to several products counts
+// once.
+func CNABreakdown(ctx context.Context, db *pgxpool.Pool, opts CNAOptions) (*CNAReport, error) {
+	args := cnaArgs(opts)
+	report := &CNAReport{ByStatus: map[string]int{}}
+
+	rows, err := db.Query(ctx, `
+		SELECT COALESCE(source_identifier, ''), count(*),
+		       count(*) FILTER (WHERE vuln_status = ANY($5)),
+		       count(*) FILTER (WHERE vuln_status IN ('Analyzed', 'Modified')),
+		       count(*) FILTER (WHERE in_kev),
+		       max(cvss_base)::float8
+		FROM (
+			SELECT DISTINCT e.cve_id, e.source_identifier, e.vuln_status, e.cvss_base,
+			       EXISTS (SELECT 1 FROM cve_enriched k WHERE k.cve_id = e.cve_id AND k.source = 'CISA-KEV') AS in_kev
+			`+cnaScope+`
+		) s
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+	`, append(args, pendingStatuses)...)
+	if err != nil {
+		return nil, fmt.Errorf("query CNA breakdown: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s CNAStat
+		if err := rows.Scan(&s.SourceIdentifier, &s.CVEs, &s.AwaitingAnalysis, &s.Analyzed, &s.InKEV, &s.MaxCVSS); err != nil {
+			return nil, fmt.Errorf("scan CNA breakdown: %w", err)
+		}
+		report.CNAs = append(report.CNAs, s)
+		report.Total += s.CVEs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT COALESCE(vuln_status, ''), count(*)
+		FROM (SELECT DISTINCT e.cve_id, e.vuln_status `+cnaScope+`) s
+		GROUP BY 1
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query NVD status counts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scan NVD status counts: %w", err)
+		}
+		report.ByStatus[status] = n
+	}
+	return report, rows.Err()
+}
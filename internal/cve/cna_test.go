@@ -0,0 +1,77 @@
+package cve
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCNABreakdown_Integration(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	const product = "test-cna-breakdown"
+	ids := []string{"CVE-TEST-CNA-1", "CVE-TEST-CNA-2", "CVE-TEST-CNA-3"}
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM product_cves WHERE product = $1", product)
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = ANY($1)", ids)
+	}
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, first_seen_at) VALUES
+		  ('CVE-TEST-CNA-1', 'NVD', '{"sourceIdentifier":"cna@test.example","vulnStatus":"Awaiting Analysis"}', NULL, now()),
+		  ('CVE-TEST-CNA-2', 'NVD', '{"sourceIdentifier":"cna@test.example","vulnStatus":"Analyzed"}', 9.8, now()),
+		  ('CVE-TEST-CNA-2', 'CISA-KEV', '{}', NULL, now()),
+		  ('CVE-TEST-CNA-3', 'NVD', '{"sourceIdentifier":"other@test.example","vulnStatus":"Received"}', 5.0, now())`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO product_cves (product, cve_id) VALUES ($1, 'CVE-TEST-CNA-2'), ($1, 'CVE-TEST-CNA-3')`, product)
+	require.NoError(t, err)
+
+	find := func(r *CNAReport, cna string) *CNAStat {
+		for i := range r.CNAs {
+			if r.CNAs[i].SourceIdentifier == cna {
+				return &r.CNAs[i]
+			}
+		}
+		return nil
+	}
+
+	r, err := CNABreakdown(ctx, pool, CNAOptions{Since: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	s := find(r, "cna@test.example")
+	require.NotNil(t, s)
+	assert.Equal(t, 2, s.CVEs)
+	assert.Equal(t, 1, s.AwaitingAnalysis)
+	assert.Equal(t, 1, s.Analyzed)
+	assert.Equal(t, 1, s.InKEV)
+	require.NotNil(t, s.MaxCVSS)
+	assert.Equal(t, 9.8, *s.MaxCVSS)
+	assert.GreaterOrEqual(t, r.ByStatus[VulnStatusReceived], 1)
+
+	r, err = CNABreakdown(ctx, pool, CNAOptions{Products: true, Product: product})
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.Total)
+	assert.Equal(t, map[string]int{VulnStatusAnalyzed: 1, VulnStatusReceived: 1}, r.ByStatus)
+	s = find(r, "cna@test.example")
+	require.NotNil(t, s)
+	assert.Equal(t, 1, s.CVEs, "only the product's CVE counts")
+
+	r, err = CNABreakdown(ctx, pool, CNAOptions{Until: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, find(r, "cna@test.example"), "nothing first seen before the window")
+}
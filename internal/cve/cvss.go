@@ -0,0 +1,118 @@
+package cve
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Sources of the CVSS scores in an NVD record, for the [merge] cvss
+// precedence.
+const (
+	CvssSourceNVD   = "nvd"   // NVD's own assessment
+	CvssSourceCNA   = "cna"   // the CNA that published the CVE
+	CvssSourceADP   = "adp"   // other secondary scorers, e.g. CISA's ADP enrichment
+	CvssSourceOther = "other" // not attributable: another primary scorer, or any secondary one when the CNA is unknown
+)
+
+// cvssSources are the sources [merge] cvss may list.
+var cvssSources = []string{CvssSourceNVD, CvssSourceCNA, CvssSourceADP, CvssSourceOther}
+
+// nvdSourceIdentifier is the metric source of NVD's own scores.
+const nvdSourceIdentifier = "nvd@nist.gov"
+
+// ParseCvssPriority checks a configured precedence; empty returns nil,
+// NVD's own order (see SelectCvss).
+func ParseCvssPriority(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	for i, n := range names {
+		if !slices.Contains(cvssSources, n) {
+			return nil, fmt.Errorf("unknown CVSS source %q: want %s, %s, %s or %s",
+				n, CvssSourceNVD, CvssSourceCNA, CvssSourceADP, CvssSourceOther)
+		}
+		if slices.Contains(names[:i], n) {
+			return nil, fmt.Errorf("CVSS source %q listed twice", n)
+		}
+	}
+	return names, nil
+}
+
+// Cvss is the CVSS v3 score chosen for a CVE and where it came from.
+type Cvss struct {
+	Score    float64
+	Severity string
+	Version  string // "3.1" or "3.0"
+	Source   string // CvssSourceNVD, CvssSourceCNA, CvssSourceADP or CvssSourceOther
+}
+
+// SetCvssPriority sets which source's score is stored as cvss_base; see
+// SelectCvss.
+func (r *NvdRunner) SetCvssPriority(priority []string) { r.cvssPriority = priority }
+
+// SelectCvss picks the base score of the first source in priority that
+// scored the CVE, from an NVD metrics object; within a source CVSS v3.1
+// wins over v3.0. cna is the record's sourceIdentifier, which tells the
+// CNA's score from other secondary ones. Sources missing from priority are
+// never used. A nil priority takes the scores in NVD's order instead: the
+// first v3.1 score, else the first v3.0 one, whoever scored it. It returns
+// nil when no listed source has a score.
+func SelectCvss(metricsRaw json.RawMessage, cna string, priority []string) *Cvss {
+	if len(metricsRaw) == 0 {
+		return nil
+	}
+	type cvssMetric struct {
+		Source   string `json:"source"`
+		Type     string `json:"type"`
+		CvssData struct {
+			BaseScore    float64 `json:"baseScore"`
+			BaseSeverity string  `json:"baseSeverity"`
+		} `json:"cvssData"`
+	}
+	var m struct {
+		V31 []cvssMetric `json:"cvssMetricV31"`
+		V30 []cvssMetric `json:"cvssMetricV30"`
+	}
+	if err := json.Unmarshal(metricsRaw, &m); err != nil {
+		return nil
+	}
+
+	sourceOf := func(c cvssMetric) string {
+		switch {
+		case c.Source == nvdSourceIdentifier:
+			return CvssSourceNVD
+		case cna == "":
+			// Without the CNA's identifier its score can't be told from
+			// other secondary ones.
+		case c.Source == cna:
+			return CvssSourceCNA
+		case c.Type == "Secondary":
+			return CvssSourceADP
+		}
+		return CvssSourceOther
+	}
+	versions := []struct {
+		version string
+		list    []cvssMetric
+	}{{"3.1", m.V31}, {"3.0", m.V30}}
+	if priority == nil {
+		for _, v := range versions {
+			if len(v.list) > 0 {
+				c := v.list[0]
+				return &Cvss{Score: c.CvssData.BaseScore, Severity: c.CvssData.BaseSeverity, Version: v.version, Source: sourceOf(c)}
+			}
+		}
+		return nil
+	}
+	for _, want := range priority {
+		for _, v := range versions {
+			for _, c := range v.list {
+				if sourceOf(c) == want {
+					return &Cvss{Score: c.CvssData.BaseScore, Severity: c.CvssData.BaseSeverity, Version: v.version, Source: want}
+				}
+			}
+		}
+	}
+	return nil
+}
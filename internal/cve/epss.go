@@ -3,18 +3,33 @@ package cve
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DefaultEpssConcurrency is the number of EPSS pages fetched at once when
+// epss.concurrency is not set.
+const DefaultEpssConcurrency = 4
+
+// epssRecoverDays is how far back before the latest day incomplete days
+// are re-ingested.
+const epssRecoverDays = 30
+
 type EpssRow struct {
 	CVE        string `json:"cve"`
 	EPSS       string `json:"epss"`
@@ -23,18 +38,25 @@ type EpssRow struct {
 }
 
 type EpssResponse struct {
-	Status string    `json:"status"`
-	Total  int       `json:"total"`
-	Offset int       `json:"offset"`
-	Limit  int       `json:"limit"`
-	Data   []EpssRow `json:"data"`
+	Status  string    `json:"status"`
+	Version string    `json:"version"`
+	Total   int       `json:"total"`
+	Offset  int       `json:"offset"`
+	Limit   int       `json:"limit"`
+	Data    []EpssRow `json:"data"`
 }
 
 // EpssRunner handles EPSS data ingestion.
 type EpssRunner struct {
-	db     *pgxpool.Pool
-	cfg    config.EpssConfig
-	client *http.Client
+	db         *pgxpool.Pool
+	cfg        config.EpssConfig
+	client     *http.Client
+	fetcher    EPSSFetcher // nil means fetch over HTTP with client
+	sink       ScoreSink   // optional analytics copy
+	quality    *quality.Recorder
+	quarantine *quarantine.Store
+	archive    *rawarchive.Archive
+	rewrite    bool // reprocess: replace stored scores instead of keeping them
 }
 
 // NewEpssRunner creates a new instance of EpssRunner.
@@ -48,7 +70,8 @@ func NewEpssRunner(db *pgxpool.Pool, cfg config.EpssConfig) *EpssRunner {
 	}
 }
 
-// Run starts the EPSS ingestion process.
+// Run starts the EPSS ingestion process: the latest day, then any of the
+// days before it that a failed run left incomplete.
 func (r *EpssRunner) Run(ctx context.Context) (retErr error) {
 	if !r.cfg.Enabled {
 		slog.Info("EPSS ingestion disabled")
@@ -66,14 +89,8 @@ func (r *EpssRunner) Run(ctx context.Context) (retErr error) {
 	slog.Info("Starting EPSS ingestion")
 
 	// 1. Fetch first page to get total and date
-	pageSize := r.cfg.PageSize
-	if pageSize <= 0 {
-		pageSize = 5000
-	}
-
-	url := fmt.Sprintf("%s?limit=%d&offset=0", r.cfg.URL, pageSize)
-
-	resp, e := r.fetch(url)
+	url := r.pageURL("", 0)
+	resp, e := r.fetch(ctx, url)
 	if e != nil {
 		return fmt.Errorf("failed to fetch EPSS: %w", e)
 	}
@@ -93,69 +110,255 @@ func (r *EpssRunner) Run(ctx context.Context) (retErr error) {
 	metrics.EpssCursorLag.Set(time.Since(date).Seconds())
 
 	// 2. Check if we already have this date
-	// Note: Schema uses 'as_of' column, not 'date'
-	var exists bool
-	err = r.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM epss_daily WHERE as_of = $1 LIMIT 1)", date).Scan(&exists)
+	complete, err := r.dayComplete(ctx, date)
+	if err != nil {
+		return err
+	}
+	if !complete {
+		if err := r.ingestDay(ctx, date, "", resp); err != nil {
+			return err
+		}
+	}
+
+	// 3. Days before it a failed run left partial
+	recovered, err := r.recoverDays(ctx, date)
 	if err != nil {
-		return fmt.Errorf("failed to check existing EPSS date: %w", err)
+		return err
 	}
 
-	if exists {
+	if complete && recovered == 0 {
 		slog.Info("EPSS data for date already exists, skipping", "date", dateStr)
 		metrics.EpssRuns.WithLabelValues("skipped").Inc()
 		return nil
 	}
+	metrics.EpssRuns.WithLabelValues("success").Inc()
+	return nil
+}
 
-	// 3. Ensure partition exists
-	if err := r.ensurePartition(ctx, date); err != nil {
-		return err
+// pageURL is the URL of the page at offset, of day ("2006-01-02") or, when
+// empty, of the latest day.
+func (r *EpssRunner) pageURL(day string, offset int) string {
+	pageSize := r.cfg.PageSize
+	if pageSize <= 0 {
+		pageSize = 5000
 	}
+	u := fmt.Sprintf("%s?limit=%d&offset=%d", r.cfg.URL, pageSize, offset)
+	if day != "" {
+		u += "&date=" + day
+	}
+	return u
+}
 
-	// 4. Ingest Loop
-	total := resp.Total
-	offset := 0
-
-	// Process first page
-	if err := r.bulkInsert(ctx, resp.Data, date); err != nil {
-		return err
+// dayComplete reports whether date's scores are stored in full. A day is
+// incomplete while its provenance has no completed_at: the run storing it
+// failed or was cut short. Days stored with no provenance row predate it
+// and count as complete.
+func (r *EpssRunner) dayComplete(ctx context.Context, date time.Time) (bool, error) {
+	var exists bool
+	var completed *bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM epss_daily WHERE as_of = $1),
+		       (SELECT completed_at IS NOT NULL FROM epss_provenance WHERE as_of = $1)
+	`, date).Scan(&exists, &completed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check existing EPSS date: %w", err)
 	}
-	offset += len(resp.Data)
-	metrics.EpssRecordsProcessed.Add(float64(len(resp.Data)))
-	metrics.EpssPagesFetched.Inc()
-	slog.Info("Ingested EPSS batch", "offset", offset, "total", total)
+	return exists && (completed == nil || *completed), nil
+}
 
-	for offset < total {
-		url := fmt.Sprintf("%s?limit=%d&offset=%d", r.cfg.URL, pageSize, offset)
+// recoverDays re-ingests the incomplete days in the epssRecoverDays before
+// latest, oldest first, and returns how many it completed. A day FIRST no
+// longer serves is left as it is; other failures are returned once every
+// day was tried.
+func (r *EpssRunner) recoverDays(ctx context.Context, latest time.Time) (int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT as_of FROM epss_provenance
+		WHERE completed_at IS NULL AND as_of < $1 AND as_of >= $2
+		ORDER BY as_of
+	`, latest, latest.AddDate(0, 0, -epssRecoverDays))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list incomplete EPSS days: %w", err)
+	}
+	days, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		return 0, fmt.Errorf("failed to list incomplete EPSS days: %w", err)
+	}
 
-		pData, err := r.fetch(url)
+	var errs []error
+	recovered := 0
+	for _, date := range days {
+		day := date.Format(time.DateOnly)
+		resp, err := r.fetch(ctx, r.pageURL(day, 0))
 		if err != nil {
-			return fmt.Errorf("failed to fetch EPSS page at offset %d: %w", offset, err)
+			errs = append(errs, fmt.Errorf("failed to fetch EPSS for %s: %w", day, err))
+			continue
 		}
-
-		if len(pData.Data) == 0 {
-			break
+		if len(resp.Data) == 0 || resp.Data[0].Date != day {
+			slog.Warn("EPSS no longer serves an incomplete day, leaving it", "date", day)
+			continue
 		}
+		if err := r.ingestDay(ctx, date, day, resp); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, errors.Join(errs...)
+}
+
+// ingestDay stores the scores of date, whose first page first was fetched
+// for day ("" for the latest), replacing rows an earlier incomplete run left. The day is
+// marked complete only once as many rows as first announced are stored.
+func (r *EpssRunner) ingestDay(ctx context.Context, date time.Time, day string, first *EpssResponse) error {
+	dateStr := date.Format(time.DateOnly)
+	chk := r.quality.Checker("EPSS")
+	chk.Date("", "date", date)
+	chk.Flush(ctx)
+
+	if err := r.ensurePartition(ctx, date); err != nil {
+		return err
+	}
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM epss_daily WHERE as_of = $1", date)
+	if err != nil {
+		return fmt.Errorf("failed to clear incomplete EPSS day %s: %w", dateStr, err)
+	}
+	if tag.RowsAffected() > 0 {
+		slog.Warn("Re-ingesting incomplete EPSS day", "date", dateStr, "partial_rows", tag.RowsAffected())
+	}
 
-		if err := r.bulkInsert(ctx, pData.Data, date); err != nil {
+	prov := provenance.New(r.pageURL(day, 0), fetchedStatus(r.fetcher != nil), first.Version)
+	total := first.Total
+	if err := r.recordProvenance(ctx, date, prov, total); err != nil {
+		return err
+	}
+
+	rows := 0
+	pages := 0
+	store := func(offset int, page *EpssResponse) error {
+		if len(page.Data) == 0 {
+			return nil
+		}
+		if err := r.bulkInsert(ctx, page.Data, date); err != nil {
 			return fmt.Errorf("failed to bulk insert EPSS at offset %d: %w", offset, err)
 		}
-
-		offset += len(pData.Data)
-		metrics.EpssRecordsProcessed.Add(float64(len(pData.Data)))
+		rows += len(page.Data)
+		pages++
+		metrics.EpssRecordsProcessed.Add(float64(len(page.Data)))
 		metrics.EpssPagesFetched.Inc()
-		slog.Info("Ingested EPSS batch", "offset", offset, "total", total)
+		slog.Info("Ingested EPSS batch", "date", dateStr, "offset", offset, "rows", rows, "total", total)
+		return nil
+	}
+	if err := store(0, first); err != nil {
+		return err
+	}
 
-		time.Sleep(100 * time.Millisecond) // Rate limit
+	// The rest of the pages, by offset: the API may return fewer rows per
+	// page than asked for, so the first page's size is the stride. They ask
+	// for the first page's date, so a latest-day run can't straddle a new
+	// day being published.
+	var offsets []int
+	for offset := len(first.Data); offset < total; offset += len(first.Data) {
+		offsets = append(offsets, offset)
+	}
+	fetch := func(ctx context.Context, offset int) (*EpssResponse, error) {
+		page, err := r.fetch(ctx, r.pageURL(dateStr, offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch EPSS page at offset %d: %w", offset, err)
+		}
+		return page, nil
+	}
+	if err := fetchPages(ctx, offsets, r.concurrency(), fetch, store); err != nil {
+		return err
 	}
 
+	if rows < total {
+		// completed_at stays NULL, so the next run ingests the day again
+		return fmt.Errorf("EPSS day %s incomplete: %d of %d rows returned", dateStr, rows, total)
+	}
+	if err := r.completeProvenance(ctx, date, pages, rows); err != nil {
+		return err
+	}
 	slog.Info("EPSS ingestion complete", "date", dateStr, "total", total)
-	metrics.EpssRuns.WithLabelValues("success").Inc()
 	return nil
 }
 
-func (r *EpssRunner) fetch(url string) (*EpssResponse, error) {
+func (r *EpssRunner) concurrency() int {
+	if r.cfg.Concurrency <= 0 {
+		return DefaultEpssConcurrency
+	}
+	return r.cfg.Concurrency
+}
+
+type epssPage struct {
+	offset int
+	page   *EpssResponse
+}
+
+// fetchPages fetches the pages at offsets with up to workers requests in
+// flight and hands each to store as it arrives, in no particular order.
+// store runs on the calling goroutine, one page at a time, so inserts
+// overlap the fetches of the pages after them rather than each other.
+// The first error stops the fetches and is returned.
+func fetchPages(ctx context.Context, offsets []int, workers int, fetch func(context.Context, int) (*EpssResponse, error), store func(int, *EpssResponse) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	todo := make(chan int)
+	go func() {
+		defer close(todo)
+		for _, offset := range offsets {
+			select {
+			case todo <- offset:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	done := make(chan epssPage, workers)
+	var wg sync.WaitGroup
+	for range min(workers, len(offsets)) {
+		wg.Go(func() {
+			for offset := range todo {
+				page, err := fetch(ctx, offset)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				done <- epssPage{offset, page}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for p := range done {
+		if ctx.Err() != nil {
+			continue // drain, so the fetchers can exit
+		}
+		if err := store(p.offset, p.page); err != nil {
+			cancel(err)
+		}
+	}
+	return context.Cause(ctx)
+}
+
+// fetch retrieves one EPSS page via the configured fetcher, or over HTTP.
+func (r *EpssRunner) fetch(ctx context.Context, url string) (*EpssResponse, error) {
+	if r.fetcher != nil {
+		return r.fetcher.FetchPage(ctx, url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	httpStart := time.Now()
-	resp, err := r.client.Get(url)
+	resp, err := r.client.Do(req)
 	metrics.UpstreamRequestDuration.WithLabelValues("epss").Observe(time.Since(httpStart).Seconds())
 	if err != nil {
 		return nil, err
@@ -163,17 +366,114 @@ func (r *EpssRunner) fetch(url string) (*EpssResponse, error) {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %d", resp.StatusCode)
+		return nil, newStatusError("epss", resp)
+	}
+
+	body, err := httpclient.ReadBody(resp, r.maxResponseBytes())
+	if err != nil {
+		return nil, err
+	}
+	r.archive.Put(ctx, rawarchive.EPSS, url, body)
+	page, err := parseEpssPage(body)
+	if err != nil {
+		r.quarantine.Put(ctx, quarantine.EPSS, "", url, body, err)
+		return nil, err
 	}
+	return page, nil
+}
 
+func parseEpssPage(body []byte) (*EpssResponse, error) {
 	var page EpssResponse
-	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+	if err := json.Unmarshal(body, &page); err != nil {
 		return nil, err
 	}
 	return &page, nil
 }
 
+// Replay stores the scores of a quarantined page and returns how many were
+// new. Unlike a run it leaves scores already stored for the day alone, so
+// a page replayed after the rest of the day was ingested fills its gap; in
+// rewrite mode it replaces them and returns how many it wrote.
+func (r *EpssRunner) Replay(ctx context.Context, payload []byte) (int, error) {
+	page, err := parseEpssPage(payload)
+	if err != nil {
+		return 0, err
+	}
+	if len(page.Data) == 0 {
+		return 0, nil
+	}
+	date, err := time.Parse("2006-01-02", page.Data[0].Date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse EPSS date %s: %w", page.Data[0].Date, err)
+	}
+	chk := r.quality.Checker("EPSS")
+	rows := checkEpssRows(page.Data, chk)
+	chk.Flush(ctx)
+	if err := r.ensurePartition(ctx, date); err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		batch.Queue(`
+			INSERT INTO epss_daily (cve_id, epss, percentile, as_of) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (as_of, cve_id) DO UPDATE SET epss = EXCLUDED.epss, percentile = EXCLUDED.percentile
+			WHERE $5
+		`, row.CVE, row.EPSS, row.Percentile, date, r.rewrite)
+	}
+	br := r.db.SendBatch(ctx, batch)
+	defer func() { _ = br.Close() }()
+	inserted := 0
+	for range rows {
+		tag, err := br.Exec()
+		if err != nil {
+			return inserted, fmt.Errorf("replay EPSS rows: %w", err)
+		}
+		inserted += int(tag.RowsAffected())
+	}
+	return inserted, nil
+}
+
+// recordProvenance stores where the day's scores came from and how many
+// rows the day has. One row covers every epss_daily row for the date.
+func (r *EpssRunner) recordProvenance(ctx context.Context, date time.Time, prov provenance.Record, expected int) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO epss_provenance (as_of, fetch_url, fetched_at, http_status, upstream_version, tool_version, expected_rows)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (as_of) DO UPDATE SET
+			fetch_url = EXCLUDED.fetch_url,
+			fetched_at = EXCLUDED.fetched_at,
+			http_status = EXCLUDED.http_status,
+			upstream_version = EXCLUDED.upstream_version,
+			tool_version = EXCLUDED.tool_version,
+			expected_rows = EXCLUDED.expected_rows,
+			pages = 0,
+			row_count = 0,
+			completed_at = NULL
+	`, date, prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, expected)
+	if err != nil {
+		return fmt.Errorf("failed to record EPSS provenance: %w", err)
+	}
+	return nil
+}
+
+func (r *EpssRunner) completeProvenance(ctx context.Context, date time.Time, pages, rows int) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE epss_provenance SET pages = $2, row_count = $3, completed_at = now()
+		WHERE as_of = $1
+	`, date, pages, rows)
+	if err != nil {
+		return fmt.Errorf("failed to complete EPSS provenance: %w", err)
+	}
+	return nil
+}
+
 func (r *EpssRunner) ensurePartition(ctx context.Context, date time.Time) error {
+	return EnsureEpssPartition(ctx, r.db, date)
+}
+
+// EnsureEpssPartition creates the monthly epss_daily partition holding date.
+func EnsureEpssPartition(ctx context.Context, db *pgxpool.Pool, date time.Time) error {
 	// Partition by month
 	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
 	nextMonth := startOfMonth.AddDate(0, 1, 0)
@@ -186,7 +486,7 @@ func (r *EpssRunner) ensurePartition(ctx context.Context, date time.Time) error
 		FOR VALUES FROM ('%s') TO ('%s')
 	`, partitionName, startOfMonth.Format("2006-01-02"), nextMonth.Format("2006-01-02"))
 
-	_, err := r.db.Exec(ctx, query)
+	_, err := db.Exec(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to create partition %s: %w", partitionName, err)
 	}
@@ -194,6 +494,10 @@ func (r *EpssRunner) ensurePartition(ctx context.Context, date time.Time) error
 }
 
 func (r *EpssRunner) bulkInsert(ctx context.Context, rows []EpssRow, date time.Time) error {
+	chk := r.quality.Checker("EPSS")
+	rows = checkEpssRows(rows, chk)
+	chk.Flush(ctx)
+
 	// 1. Insert into epss_daily (History)
 	inputRows := make([][]interface{}, len(rows))
 	for i, row := range rows {
@@ -202,15 +506,15 @@ func (r *EpssRunner) bulkInsert(ctx context.Context, rows []EpssRow, date time.T
 			row.EPSS,       // pgx will handle string -> numeric conversion if format is valid
 			row.Percentile, // pgx will handle string -> numeric conversion if format is valid
 			date,
-			time.Now(), // inserted_at
 		}
 	}
 
-	// Schema columns: as_of, cve_id, epss, percentile, raw (skipped), inserted_at
+	// Schema columns: as_of, cve_id, epss, percentile, raw (skipped), inserted_at.
+	// inserted_at is left to the database default: it orders the /changes feed.
 	copyCount, err := r.db.CopyFrom(
 		ctx,
 		pgx.Identifier{"epss_daily"},
-		[]string{"cve_id", "epss", "percentile", "as_of", "inserted_at"},
+		[]string{"cve_id", "epss", "percentile", "as_of"},
 		pgx.CopyFromRows(inputRows),
 	)
 	if err != nil {
@@ -218,5 +522,10 @@ func (r *EpssRunner) bulkInsert(ctx context.Context, rows []EpssRow, date time.T
 	}
 	_ = copyCount
 
+	if r.sink != nil {
+		if err := r.sink.InsertEpss(ctx, date, rows); err != nil {
+			sinkFailed(r.sink, "epss_daily", err)
+		}
+	}
 	return nil
 }
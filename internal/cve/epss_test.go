@@ -2,10 +2,14 @@ package cve
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"tiger2go/internal/config"
 	"tiger2go/internal/db"
@@ -50,7 +54,11 @@ func TestEpssRunner_Integration(t *testing.T) {
 				]
 			}`))
 		} else {
-			// Second page (finish)
+			// Second page (finish), of the day the first page gave
+			if r.URL.Query().Get("date") != "2100-01-01" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte(`{
 				"status": "OK",
@@ -86,3 +94,123 @@ func TestEpssRunner_Integration(t *testing.T) {
 	// Cleanup
 	_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE as_of = '2100-01-01'")
 }
+
+// TestEpssRunner_RecoversPartialDay requires a running DB. A day a failed
+// run left half stored is ingested again, the latest and an earlier one.
+func TestEpssRunner_RecoversPartialDay(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE as_of IN ('2100-02-01', '2100-02-02')")
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_provenance WHERE as_of IN ('2100-02-01', '2100-02-02')")
+	}
+	cleanup()
+	defer cleanup()
+
+	// Both days were cut short after their first row
+	runner := NewEpssRunner(pool, config.EpssConfig{Enabled: true, PageSize: 1})
+	for _, day := range []string{"2100-02-01", "2100-02-02"} {
+		date, _ := time.Parse(time.DateOnly, day)
+		require.NoError(t, runner.ensurePartition(ctx, date))
+		_, err = pool.Exec(ctx, "INSERT INTO epss_daily (cve_id, epss, percentile, as_of) VALUES ('CVE-TEST-0001', 0.5, 0.5, $1)", date)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `
+			INSERT INTO epss_provenance (as_of, fetch_url, fetched_at, tool_version, expected_rows)
+			VALUES ($1, 'http://epss.test', now(), 'test', 2)
+		`, date)
+		require.NoError(t, err)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day := r.URL.Query().Get("date")
+		if day == "" {
+			day = "2100-02-02"
+		}
+		cve := "CVE-TEST-0001"
+		if r.URL.Query().Get("offset") != "0" {
+			cve = "CVE-TEST-0002"
+		}
+		_, _ = w.Write([]byte(`{"status": "OK", "total": 2, "data": [
+			{"cve": "` + cve + `", "epss": "0.9", "percentile": "0.9", "date": "` + day + `"}
+		]}`))
+	}))
+	defer mockServer.Close()
+
+	runner.cfg.URL = mockServer.URL
+	require.NoError(t, runner.Run(ctx))
+
+	for _, day := range []string{"2100-02-01", "2100-02-02"} {
+		date, _ := time.Parse(time.DateOnly, day)
+		var count, expected int
+		var completed bool
+		err = pool.QueryRow(ctx, `
+			SELECT (SELECT count(*) FROM epss_daily WHERE as_of = $1), expected_rows, completed_at IS NOT NULL
+			FROM epss_provenance WHERE as_of = $1
+		`, date).Scan(&count, &expected, &completed)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count, day)
+		assert.Equal(t, 2, expected, day)
+		assert.True(t, completed, day)
+	}
+}
+
+func TestFetchPages(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak int
+	fetch := func(ctx context.Context, offset int) (*EpssResponse, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		return &EpssResponse{Offset: offset, Data: []EpssRow{{CVE: "CVE-2024-0001"}}}, nil
+	}
+	stored := map[int]bool{}
+	err := fetchPages(context.Background(), []int{10, 20, 30, 40, 50, 60, 70, 80}, 3, fetch, func(offset int, page *EpssResponse) error {
+		assert.Equal(t, offset, page.Offset)
+		stored[offset] = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, stored, 8)
+	assert.Equal(t, 3, peak, "pages are fetched concurrently, up to the worker count")
+}
+
+func TestFetchPages_StopsOnError(t *testing.T) {
+	errPage := errors.New("page 30 failed")
+	var fetched atomic.Int32
+	fetch := func(ctx context.Context, offset int) (*EpssResponse, error) {
+		fetched.Add(1)
+		if offset == 30 {
+			return nil, errPage
+		}
+		return &EpssResponse{}, nil
+	}
+	offsets := make([]int, 1000)
+	for i := range offsets {
+		offsets[i] = (i + 1) * 10
+	}
+	err := fetchPages(context.Background(), offsets, 2, fetch, func(int, *EpssResponse) error { return nil })
+	assert.ErrorIs(t, err, errPage)
+	assert.Less(t, fetched.Load(), int32(1000), "no more pages are fetched after a failure")
+
+	errStore := errors.New("copy failed")
+	err = fetchPages(context.Background(), offsets[:5], 2, func(context.Context, int) (*EpssResponse, error) {
+		return &EpssResponse{}, nil
+	}, func(int, *EpssResponse) error { return errStore })
+	assert.ErrorIs(t, err, errStore)
+}
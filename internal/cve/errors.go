@@ -0,0 +1,60 @@
+package cve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tiger2go/internal/httpclient"
+)
+
+// Sentinel errors for upstream (NVD, KEV, EPSS) HTTP failures.
+// Match them with errors.Is; use errors.As with *StatusError for details
+// such as the status code or Retry-After delay.
+var (
+	ErrNotFound     = errors.New("upstream resource not found")
+	ErrUnauthorized = errors.New("upstream rejected credentials")
+
+	// ErrRateLimited is also returned when the throttle refuses to wait
+	// for a host's rate limit.
+	ErrRateLimited = httpclient.ErrRateLimited
+
+	// ErrResponseTooLarge is returned when a response body exceeds its size limit.
+	ErrResponseTooLarge = httpclient.ErrResponseTooLarge
+)
+
+// StatusError is returned when an upstream responds with an unexpected HTTP status.
+type StatusError struct {
+	Source     string        // "nvd", "kev" or "epss"
+	StatusCode int           // HTTP status code
+	URL        string        // request URL
+	RetryAfter time.Duration // parsed Retry-After header, zero if absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status code: %d", e.Source, e.StatusCode)
+}
+
+// Is maps status codes onto the package sentinel errors.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// newStatusError builds a StatusError from a non-OK response.
+func newStatusError(source string, resp *http.Response) *StatusError {
+	e := &StatusError{Source: source, StatusCode: resp.StatusCode}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = resp.Request.URL.String()
+	}
+	e.RetryAfter = httpclient.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return e
+}
@@ -0,0 +1,89 @@
+package cve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusError_Is(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusServiceUnavailable, ErrRateLimited},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &StatusError{Source: "nvd", StatusCode: tt.code})
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	err := &StatusError{Source: "kev", StatusCode: http.StatusInternalServerError}
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrRateLimited))
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestKevFetchCatalog_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	runner := &KevRunner{client: &http.Client{Timeout: 5 * time.Second}}
+	_, err := runner.fetchCatalog(context.Background(), ts.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var se *StatusError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, "kev", se.Source)
+	assert.Equal(t, ts.URL, se.URL)
+}
+
+func TestEpssFetch_RateLimitedWithRetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	runner := &EpssRunner{client: &http.Client{Timeout: 5 * time.Second}}
+	_, err := runner.fetch(context.Background(), ts.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	var se *StatusError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, 2*time.Minute, se.RetryAfter)
+}
+
+func TestFetchWithRetry_UnauthorizedIsTyped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	runner := &NvdRunner{
+		cfg:    config.NvdConfig{ApiKey: "bad-key"},
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+
+	_, err := runner.fetchWithRetry(context.Background(), ts.URL)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
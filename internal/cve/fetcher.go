@@ -0,0 +1,45 @@
+package cve
+
+import (
+	"context"
+	"net/http"
+)
+
+// The fetcher interfaces separate "get data from upstream" from "store it".
+// Each runner fetches over HTTP by default; SetFetcher substitutes another
+// implementation (an in-memory fake, a mirror, a recorded fixture) so the
+// pipeline can run without live upstreams. See internal/fakes.
+
+// NVDFetcher retrieves one page of NVD CVE API 2.0 results. The URL carries
+// the window and pagination query parameters built by NvdRunner.
+type NVDFetcher interface {
+	FetchPage(ctx context.Context, url string) (*NvdResponse, error)
+}
+
+// KEVFetcher retrieves the CISA KEV catalog.
+type KEVFetcher interface {
+	FetchCatalog(ctx context.Context, url string) (*KevCatalog, error)
+}
+
+// EPSSFetcher retrieves one page of FIRST.org EPSS API results.
+type EPSSFetcher interface {
+	FetchPage(ctx context.Context, url string) (*EpssResponse, error)
+}
+
+// SetFetcher replaces the HTTP client used to fetch NVD pages.
+func (r *NvdRunner) SetFetcher(f NVDFetcher) { r.fetcher = f }
+
+// SetFetcher replaces the HTTP client used to fetch the KEV catalog.
+func (r *KevRunner) SetFetcher(f KEVFetcher) { r.fetcher = f }
+
+// SetFetcher replaces the HTTP client used to fetch EPSS pages.
+func (r *EpssRunner) SetFetcher(f EPSSFetcher) { r.fetcher = f }
+
+// fetchedStatus is the HTTP status recorded in provenance for a successful
+// fetch: 200 over HTTP (other statuses are errors), unknown for a custom fetcher.
+func fetchedStatus(customFetcher bool) int {
+	if customFetcher {
+		return 0
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,92 @@
+package cve
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Fuzz targets for upstream response handling. Run one with e.g.
+//
+//	go test ./internal/cve -run '^$' -fuzz FuzzDecodeKevCatalog -fuzztime 30s
+//
+// Without -fuzz they run the seed corpus as ordinary tests.
+
+func FuzzParseKevDate(f *testing.F) {
+	for _, s := range []string{"", "2024-03-07", "2024-03-07T00:00:00Z", "3/7/2024", "March 7, 2024", "TBD", "0000-00-00", "9999-12-31T23:59:59+14:00"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := parseKevDate(s)
+		if err != nil && got != nil {
+			t.Fatalf("parseKevDate(%q) returned both a time and an error", s)
+		}
+		if got != nil && got.Location() != time.UTC {
+			t.Fatalf("parseKevDate(%q) returned non-UTC time %v", s, got)
+		}
+		if got != nil && !got.Equal(got.Truncate(24*time.Hour)) {
+			t.Fatalf("parseKevDate(%q) returned a time of day %v", s, got)
+		}
+	})
+}
+
+func FuzzSelectCvss(f *testing.F) {
+	f.Add([]byte(`{"cvssMetricV31":[{"cvssData":{"baseScore":9.8}}]}`))
+	f.Add([]byte(`{"cvssMetricV30":[{"cvssData":{"baseScore":5}}]}`))
+	f.Add([]byte(`{"cvssMetricV31":[]}`))
+	f.Add([]byte(`{"cvssMetricV31":[{"cvssData":{"baseScore":"high"}}]}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[[[[[[[[[[[[[[[[`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = SelectCvss(json.RawMessage(data), "", nil)
+	})
+}
+
+func FuzzDecodeNvdResponse(f *testing.F) {
+	f.Add([]byte(`{"totalResults":1,"vulnerabilities":[{"cve":{"id":"CVE-2024-0001","lastModified":"2024-01-01T00:00:00.000","metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":7.5}}]}}}]}`))
+	f.Add([]byte(`{"vulnerabilities":[{"cve":{"id":"","metrics":null}}]}`))
+	f.Add([]byte(`{"vulnerabilities":[{}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var resp NvdResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return
+		}
+		for _, item := range resp.Vulnerabilities {
+			_ = SelectCvss(item.Cve.Metrics, item.Cve.SourceIdentifier, nil)
+			if _, err := json.Marshal(item); err != nil {
+				t.Fatalf("decoded NVD item does not re-marshal: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzDecodeKevCatalog(f *testing.F) {
+	f.Add([]byte(`{"catalogVersion":"2024.01.01","dateReleased":"2024-01-01T00:00:00.000Z","count":1,"vulnerabilities":[{"cveID":"CVE-2024-0001","dateAdded":"2024-01-01","dueDate":"2024-01-22"}]}`))
+	f.Add([]byte(`{"vulnerabilities":[{"cveID":"CVE-2024-0001"},{"cveID":"CVE-2024-0001","dueDate":"soon"}]}`))
+	f.Add([]byte(`{"dateReleased":"not a date","vulnerabilities":null}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var catalog KevCatalog
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return
+		}
+		_, _ = parseKevTime(catalog.DateReleased)
+		for _, v := range catalog.Vulnerabilities {
+			_ = validateKevDates(v)
+		}
+		existing := make(map[string]KevVuln)
+		if len(catalog.Vulnerabilities) > 0 {
+			existing[catalog.Vulnerabilities[0].CveID] = KevVuln{}
+		}
+		_ = diffKev(existing, catalog.Vulnerabilities)
+	})
+}
+
+func FuzzDecodeEpssResponse(f *testing.F) {
+	f.Add([]byte(`{"status":"OK","total":1,"offset":0,"limit":100,"data":[{"cve":"CVE-2024-0001","epss":"0.00043","percentile":"0.0512","date":"2024-01-01"}]}`))
+	f.Add([]byte(`{"status":"error","data":null}`))
+	f.Add([]byte(`{"total":-1,"limit":0,"data":[{"epss":1e400}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var page EpssResponse
+		_ = json.Unmarshal(data, &page)
+	})
+}
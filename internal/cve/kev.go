@@ -9,7 +9,12 @@ import (
 	"time"
 
 	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -30,16 +35,28 @@ type KevVuln struct {
 	DateAdded         string `json:"dateAdded"`
 	ShortDescription  string `json:"shortDescription"`
 	RequiredAction    string `json:"requiredAction"`
-	DueDate           string `json:"dueDate"`
-	Notes             string `json:"notes"`
+	// DueDateRaw is the dueDate as published; DueDate is that date parsed,
+	// set when the entry is decoded. A nil DueDate means no due date when
+	// DueDateRaw is empty, and an unparsable one otherwise.
+	DueDateRaw string     `json:"dueDate"`
+	DueDate    *time.Time `json:"-"`
+	Notes      string     `json:"notes"`
+	// KnownRansomwareCampaignUse is "Known" when CISA knows of ransomware
+	// campaigns exploiting the CVE, else "Unknown".
+	KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
 	// We capture the raw JSON for storage by re-marshaling the struct or using a map wrapper.
 	// Since the fields are flat, re-marshaling is easy.
 }
 
 type KevRunner struct {
-	db     *pgxpool.Pool
-	cfg    config.KevConfig
-	client *http.Client
+	db         *pgxpool.Pool
+	cfg        config.KevConfig
+	client     *http.Client
+	fetcher    KEVFetcher // nil means fetch over HTTP with client
+	quality    *quality.Recorder
+	quarantine *quarantine.Store
+	archive    *rawarchive.Archive
+	rewrite    bool // reprocess: rewrite unchanged entries, keeping their modified date
 }
 
 func NewKevRunner(db *pgxpool.Pool, cfg config.KevConfig) *KevRunner {
@@ -73,12 +90,54 @@ func (r *KevRunner) Run(ctx context.Context) (retErr error) {
 
 	// 1. Fetch Catalog
 	slog.Info("Fetching KEV catalog", "url", url)
-	catalog, err := r.fetchCatalog(ctx, url)
+	catalog, err := r.FetchCatalog(ctx, url)
 	if err != nil {
 		return fmt.Errorf("failed to fetch KEV catalog: %w", err)
 	}
+	return r.process(ctx, catalog, provenance.New(url, fetchedStatus(r.fetcher != nil), catalog.CatalogVersion))
+}
 
-	// 2. Check Cursor
+// Replay processes a quarantined catalog like a fetched one. A catalog
+// older than the stored one is refused: diffing against newer entries
+// would report bogus changes; kev-import handles archived catalogs. In
+// rewrite mode the stored catalog, or an older one, is written over the
+// stored entries without a diff or moving the cursor.
+func (r *KevRunner) Replay(ctx context.Context, url string, payload []byte) (int, error) {
+	catalog, err := parseKevCatalog(payload)
+	if err != nil {
+		return 0, err
+	}
+	existingCursor, err := r.getCursor(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get existing cursor: %w", err)
+	}
+	stored, errStored := time.Parse(time.RFC3339, existingCursor)
+	replayed, errReplayed := time.Parse(time.RFC3339, kevCursor(catalog))
+	older := errStored == nil && errReplayed == nil && replayed.Before(stored)
+	if r.rewrite && (older || kevCursor(catalog) == existingCursor) {
+		chk := r.quality.Checker("CISA-KEV")
+		catalog.Vulnerabilities = checkKevVulns(catalog, chk)
+		chk.Flush(ctx)
+		stats, err := r.upsertVulns(ctx, catalog, nil, provenance.New(url, 0, catalog.CatalogVersion))
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert KEV vulns: %w", err)
+		}
+		stats.record("CISA-KEV")
+		return stats.Written, nil
+	}
+	if older {
+		return 0, fmt.Errorf("KEV catalog of %s is older than the stored one of %s; import it with kev-import",
+			replayed.Format(time.DateOnly), stored.Format(time.DateOnly))
+	}
+	if err := r.process(ctx, catalog, provenance.New(url, 0, catalog.CatalogVersion)); err != nil {
+		return 0, err
+	}
+	return len(catalog.Vulnerabilities), nil
+}
+
+// kevCursor is the ingest_state cursor of catalog: its release date,
+// normalized, or its version without one.
+func kevCursor(catalog *KevCatalog) string {
 	cursor := catalog.DateReleased // Prefer DateReleased as cursor
 	if cursor == "" {
 		cursor = catalog.CatalogVersion // Fallback
@@ -88,6 +147,14 @@ func (r *KevRunner) Run(ctx context.Context) (retErr error) {
 	if t, err := time.Parse(time.RFC3339, cursor); err == nil {
 		cursor = t.Format(time.RFC3339)
 	}
+	return cursor
+}
+
+// process stores catalog unless it is the one already stored: it records
+// the entries' changes, upserts them and advances the cursor.
+func (r *KevRunner) process(ctx context.Context, catalog *KevCatalog, prov provenance.Record) error {
+	// 2. Check Cursor
+	cursor := kevCursor(catalog)
 
 	// Record cursor lag
 	if t, err := time.Parse(time.RFC3339, cursor); err == nil {
@@ -106,23 +173,58 @@ func (r *KevRunner) Run(ctx context.Context) (retErr error) {
 	}
 
 	slog.Info("New KEV catalog found", "version", catalog.CatalogVersion, "date", catalog.DateReleased, "count", len(catalog.Vulnerabilities))
+	chk := r.quality.Checker("CISA-KEV")
+	catalog.Vulnerabilities = checkKevVulns(catalog, chk)
+	chk.Flush(ctx)
+
+	// 3. Diff against the stored catalog
+	existing, err := r.loadExistingKev(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load stored KEV entries: %w", err)
+	}
+
+	var changes []KevChange
+	if len(existing) == 0 {
+		// First load: every entry would be "added", which is noise rather than news.
+		slog.Info("No stored KEV entries, recording catalog as baseline without diff")
+	} else {
+		changes = diffKev(existing, catalog.Vulnerabilities)
+	}
 
-	// 3. Upsert Vulnerabilities
-	if err := r.upsertVulns(ctx, catalog.Vulnerabilities, catalog.DateReleased); err != nil {
+	// 4. Upsert Vulnerabilities (and record changes in the same batch)
+	stats, err := r.upsertVulns(ctx, catalog, changes, prov)
+	if err != nil {
 		return fmt.Errorf("failed to upsert KEV vulns: %w", err)
 	}
+	stats.record("CISA-KEV")
 
-	// 4. Update Cursor
+	for _, c := range changes {
+		metrics.KevChanges.WithLabelValues(c.ChangeType).Inc()
+		if c.ChangeType == KevChangeAdded {
+			slog.Info("CVE added to KEV", "cve_id", c.CveID, "vendor", c.Vuln.VendorProject, "product", c.Vuln.Product)
+		}
+	}
+
+	// 5. Update Cursor
 	if err := r.setCursor(ctx, cursor); err != nil {
 		return fmt.Errorf("failed to update cursor: %w", err)
 	}
 
 	metrics.KevFetches.WithLabelValues("success").Inc()
 	metrics.KevVulnsProcessed.Add(float64(len(catalog.Vulnerabilities)))
-	slog.Info("KEV ingestion complete")
+	slog.Info("KEV ingestion complete", "changes", len(changes), "rows", stats)
 	return nil
 }
 
+// FetchCatalog retrieves the catalog at url via the configured fetcher, or
+// over HTTP. kev-import uses it for archived copies.
+func (r *KevRunner) FetchCatalog(ctx context.Context, url string) (*KevCatalog, error) {
+	if r.fetcher != nil {
+		return r.fetcher.FetchCatalog(ctx, url)
+	}
+	return r.fetchCatalog(ctx, url)
+}
+
 func (r *KevRunner) fetchCatalog(ctx context.Context, url string) (*KevCatalog, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -139,27 +241,56 @@ func (r *KevRunner) fetchCatalog(ctx context.Context, url string) (*KevCatalog,
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+		return nil, newStatusError("kev", resp)
 	}
 
+	body, err := httpclient.ReadBody(resp, r.maxResponseBytes())
+	if err != nil {
+		return nil, err
+	}
+	r.archive.Put(ctx, rawarchive.KEV, url, body)
+	catalog, err := parseKevCatalog(body)
+	if err != nil {
+		r.quarantine.Put(ctx, quarantine.KEV, "", url, body, err)
+		return nil, err
+	}
+	return catalog, nil
+}
+
+func parseKevCatalog(body []byte) (*KevCatalog, error) {
 	var catalog KevCatalog
-	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+	if err := json.Unmarshal(body, &catalog); err != nil {
 		return nil, err
 	}
 	return &catalog, nil
 }
 
-func (r *KevRunner) upsertVulns(ctx context.Context, vulns []KevVuln, dateReleased string) error {
+// upsertVulns writes the catalog entries and any changes in one batch. An
+// entry whose JSON is unchanged is skipped, so its modified date stays at
+// the release that last changed it rather than following every release.
+// Entries removed from the catalog are deleted in the same batch, so the
+// CVE stops counting as in KEV and later releases do not report it removed
+// again.
+func (r *KevRunner) upsertVulns(ctx context.Context, catalog *KevCatalog, changes []KevChange, prov provenance.Record) (upsertStats, error) {
 	// Parse catalog date for 'modified' timestamp
-	modified, err := time.Parse(time.RFC3339, dateReleased)
+	released, err := parseKevTime(catalog.DateReleased)
 	if err != nil {
-		modified = time.Now()
+		slog.Warn("Unparsable KEV dateReleased, using current time", "value", catalog.DateReleased, "error", err)
+		metrics.KevDateParseFailures.WithLabelValues("dateReleased").Inc()
+	}
+	modified := time.Now()
+	if released != nil {
+		modified = *released
 	}
 
 	batch := &pgx.Batch{}
-	queued := 0
 
-	for _, v := range vulns {
+	for _, v := range catalog.Vulnerabilities {
+		for _, w := range validateKevDates(v) {
+			slog.Warn("Unparsable KEV date", "cve_id", w.CveID, "field", w.Field, "value", w.Value, "error", w.Err)
+			metrics.KevDateParseFailures.WithLabelValues(w.Field).Inc()
+		}
+
 		jsonBytes, err := json.Marshal(v)
 		if err != nil {
 			slog.Error("Failed to marshal KEV vuln", "cve_id", v.CveID, "error", err)
@@ -167,27 +298,55 @@ func (r *KevRunner) upsertVulns(ctx context.Context, vulns []KevVuln, dateReleas
 		}
 
 		batch.Queue(`
-			INSERT INTO cve_enriched (cve_id, source, json, modified)
-			VALUES ($1, 'CISA-KEV', $2, $3)
+			INSERT INTO cve_enriched (
+				cve_id, source, json, modified, content_hash,
+				fetch_url, fetched_at, http_status, upstream_version, tool_version
+			)
+			VALUES ($1, 'CISA-KEV', $2, $3, $4, $5, $6, $7, $8, $9)
 			ON CONFLICT (cve_id, source)
 			DO UPDATE SET
 				json = EXCLUDED.json,
-				modified = EXCLUDED.modified
-		`, v.CveID, jsonBytes, modified)
-		queued++
+				modified = CASE WHEN cve_enriched.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+					THEN EXCLUDED.modified ELSE cve_enriched.modified END,
+				content_hash = EXCLUDED.content_hash,
+				fetch_url = EXCLUDED.fetch_url,
+				fetched_at = EXCLUDED.fetched_at,
+				http_status = EXCLUDED.http_status,
+				upstream_version = EXCLUDED.upstream_version,
+				tool_version = EXCLUDED.tool_version
+			WHERE $10 OR cve_enriched.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+		`, v.CveID, jsonBytes, modified, ContentHash(jsonBytes),
+			prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, r.rewrite)
+	}
+	upserts := batch.Len()
+
+	QueueKevChanges(batch, changes, catalog.CatalogVersion, released)
+	for _, c := range changes {
+		if c.ChangeType == KevChangeRemoved {
+			batch.Queue("DELETE FROM cve_enriched WHERE cve_id = $1 AND source = 'CISA-KEV'", c.CveID)
+		}
 	}
 
 	br := r.db.SendBatch(ctx, batch)
 	defer func() { _ = br.Close() }()
 
-	for i := 0; i < queued; i++ {
-		_, err := br.Exec()
+	var stats upsertStats
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := br.Exec()
 		if err != nil {
-			return fmt.Errorf("batch execution failed at index %d: %w", i, err)
+			return stats, fmt.Errorf("batch execution failed at index %d: %w", i, err)
+		}
+		if i >= upserts {
+			continue
+		}
+		if tag.RowsAffected() == 0 {
+			stats.Skipped++
+		} else {
+			stats.Written++
 		}
 	}
 
-	return nil
+	return stats, nil
 }
 
 func (r *KevRunner) getCursor(ctx context.Context) (string, error) {
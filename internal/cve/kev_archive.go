@@ -0,0 +1,147 @@
+package cve
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReadKevCatalog decodes one KEV catalog release, such as a copy from the
+// cisagov/kev-data repository history or a web archive snapshot.
+func ReadKevCatalog(r io.Reader) (*KevCatalog, error) {
+	var catalog KevCatalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("decode KEV catalog: %w", err)
+	}
+	if len(catalog.Vulnerabilities) == 0 {
+		return nil, fmt.Errorf("KEV catalog %q has no vulnerabilities", catalog.CatalogVersion)
+	}
+	return &catalog, nil
+}
+
+// KevImportStats counts what ImportKevArchive did.
+type KevImportStats struct {
+	Catalogs int `json:"catalogs"` // releases diffed
+	Skipped  int `json:"skipped"`  // repeated versions and releases without a usable dateReleased
+	Changes  int `json:"changes"`  // kev_changes rows recorded; rows already present are not counted
+}
+
+// ImportKevArchive records the differences between consecutive archived
+// catalog releases in kev_changes, as archived rows dated at each release.
+// The oldest release is diffed against the archived history before it, so
+// an import that starts the history records every entry as added.
+//
+// Catalogs may be in any order. Import a gap-free history oldest first:
+// releases older than ones already imported are diffed against the
+// releases before them only. Importing a release again records nothing
+// new. cve_enriched, the current catalog, is not touched.
+func ImportKevArchive(ctx context.Context, db *pgxpool.Pool, catalogs []*KevCatalog) (KevImportStats, error) {
+	type release struct {
+		catalog  *KevCatalog
+		released time.Time
+	}
+	var stats KevImportStats
+	var releases []release
+	seen := map[string]bool{}
+	for _, c := range catalogs {
+		released, err := parseKevTime(c.DateReleased)
+		if err != nil || released == nil || seen[c.CatalogVersion] {
+			slog.Warn("Skipping archived KEV catalog", "version", c.CatalogVersion, "date_released", c.DateReleased, "error", err)
+			stats.Skipped++
+			continue
+		}
+		seen[c.CatalogVersion] = true
+		releases = append(releases, release{c, *released})
+	}
+	slices.SortFunc(releases, func(a, b release) int {
+		return cmp.Or(a.released.Compare(b.released), cmp.Compare(a.catalog.CatalogVersion, b.catalog.CatalogVersion))
+	})
+	if len(releases) == 0 {
+		return stats, nil
+	}
+
+	state, err := archivedKevState(ctx, db, releases[0].released)
+	if err != nil {
+		return stats, err
+	}
+	for _, rel := range releases {
+		changes := diffKev(state, rel.catalog.Vulnerabilities)
+		for i := range changes {
+			changes[i].Archived = true
+		}
+		n, err := recordKevChanges(ctx, db, changes, rel.catalog.CatalogVersion, rel.released)
+		if err != nil {
+			return stats, fmt.Errorf("catalog %s: %w", rel.catalog.CatalogVersion, err)
+		}
+		stats.Catalogs++
+		stats.Changes += n
+
+		state = make(map[string]KevVuln, len(rel.catalog.Vulnerabilities))
+		for _, v := range rel.catalog.Vulnerabilities {
+			state[v.CveID] = v
+		}
+	}
+	return stats, nil
+}
+
+// archivedKevState rebuilds the catalog as of just before the given release
+// date from the archived changes: each CVE's latest change, unless it was a
+// removal.
+func archivedKevState(ctx context.Context, db *pgxpool.Pool, before time.Time) (map[string]KevVuln, error) {
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, change_type, json FROM (
+			SELECT DISTINCT ON (cve_id) cve_id, change_type, json
+			FROM kev_changes
+			WHERE archived AND date_released < $1
+			ORDER BY cve_id, date_released DESC, id DESC
+		) latest
+		WHERE change_type <> 'removed'
+	`, before)
+	if err != nil {
+		return nil, fmt.Errorf("query archived KEV changes: %w", err)
+	}
+	defer rows.Close()
+	state := map[string]KevVuln{}
+	for rows.Next() {
+		var id, changeType string
+		var raw []byte
+		if err := rows.Scan(&id, &changeType, &raw); err != nil {
+			return nil, fmt.Errorf("scan archived KEV change: %w", err)
+		}
+		var v KevVuln
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("decode archived KEV change %s: %w", id, err)
+		}
+		state[id] = v
+	}
+	return state, rows.Err()
+}
+
+// recordKevChanges writes one release's changes and returns how many rows
+// were new.
+func recordKevChanges(ctx context.Context, db *pgxpool.Pool, changes []KevChange, version string, released time.Time) (int, error) {
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	batch := &pgx.Batch{}
+	QueueKevChanges(batch, changes, version, &released)
+	br := db.SendBatch(ctx, batch)
+	defer func() { _ = br.Close() }()
+	n := 0
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := br.Exec()
+		if err != nil {
+			return n, fmt.Errorf("record KEV change: %w", err)
+		}
+		n += int(tag.RowsAffected())
+	}
+	return n, nil
+}
@@ -0,0 +1,185 @@
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KEV change types recorded in kev_changes.
+const (
+	KevChangeAdded   = "added"
+	KevChangeUpdated = "updated"
+	KevChangeRemoved = "removed"
+)
+
+// KevChange is a single per-CVE difference between two KEV catalog releases.
+type KevChange struct {
+	ID             int64      `json:"id,omitempty"`
+	CveID          string     `json:"cve_id"`
+	ChangeType     string     `json:"change_type"`
+	CatalogVersion string     `json:"catalog_version"`
+	DateReleased   *time.Time `json:"date_released,omitempty"`
+	ChangedFields  []string   `json:"changed_fields,omitempty"`
+	DetectedAt     time.Time  `json:"detected_at"`
+	Archived       bool       `json:"archived"` // from kev-import: DetectedAt is the release date
+	Vuln           KevVuln    `json:"vuln"`
+}
+
+// diffKev compares the previously stored KEV entries against a new catalog.
+// Entries present only in the catalog are 'added', entries whose fields
+// differ are 'updated' and entries missing from the catalog are 'removed'.
+// The result is sorted by change type then CVE ID for stable output.
+func diffKev(existing map[string]KevVuln, vulns []KevVuln) []KevChange {
+	var changes []KevChange
+	seen := make(map[string]bool, len(vulns))
+
+	for _, v := range vulns {
+		seen[v.CveID] = true
+		old, ok := existing[v.CveID]
+		if !ok {
+			changes = append(changes, KevChange{CveID: v.CveID, ChangeType: KevChangeAdded, Vuln: v})
+			continue
+		}
+		if fields := kevChangedFields(old, v); len(fields) > 0 {
+			changes = append(changes, KevChange{CveID: v.CveID, ChangeType: KevChangeUpdated, ChangedFields: fields, Vuln: v})
+		}
+	}
+
+	for id, old := range existing {
+		if !seen[id] {
+			changes = append(changes, KevChange{CveID: id, ChangeType: KevChangeRemoved, Vuln: old})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].ChangeType != changes[j].ChangeType {
+			return changes[i].ChangeType < changes[j].ChangeType
+		}
+		return changes[i].CveID < changes[j].CveID
+	})
+	return changes
+}
+
+// kevChangedFields returns the JSON names of the fields that differ between two entries.
+func kevChangedFields(a, b KevVuln) []string {
+	var fields []string
+	check := func(name, x, y string) {
+		if x != y {
+			fields = append(fields, name)
+		}
+	}
+	check("vendorProject", a.VendorProject, b.VendorProject)
+	check("product", a.Product, b.Product)
+	check("vulnerabilityName", a.VulnerabilityName, b.VulnerabilityName)
+	check("dateAdded", a.DateAdded, b.DateAdded)
+	check("shortDescription", a.ShortDescription, b.ShortDescription)
+	check("requiredAction", a.RequiredAction, b.RequiredAction)
+	check("dueDate", a.DueDateRaw, b.DueDateRaw)
+	check("notes", a.Notes, b.Notes)
+	// Entries stored before the field was kept have no value to compare.
+	if a.KnownRansomwareCampaignUse != "" {
+		check("knownRansomwareCampaignUse", a.KnownRansomwareCampaignUse, b.KnownRansomwareCampaignUse)
+	}
+	return fields
+}
+
+// loadExistingKev returns the KEV entries currently stored in cve_enriched.
+func (r *KevRunner) loadExistingKev(ctx context.Context) (map[string]KevVuln, error) {
+	rows, err := r.db.Query(ctx, "SELECT cve_id, json FROM cve_enriched WHERE source = 'CISA-KEV'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]KevVuln)
+	for rows.Next() {
+		var id string
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		var v KevVuln
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("decode stored KEV entry %s: %w", id, err)
+		}
+		existing[id] = v
+	}
+	return existing, rows.Err()
+}
+
+// QueueKevChanges adds kev_changes inserts to the batch that upserts the catalog,
+// so the diff and the new catalog state are written together. Mirrors use it
+// to replay changes received from another instance.
+// Archived changes are dated at the catalog's release instead of now.
+func QueueKevChanges(batch *pgx.Batch, changes []KevChange, catalogVersion string, dateReleased *time.Time) {
+	for _, c := range changes {
+		jsonBytes, err := json.Marshal(c.Vuln)
+		if err != nil {
+			continue
+		}
+		// Unparsable dates are stored as NULL; upsertVulns already warned about them.
+		dateAdded, _ := c.Vuln.ParsedDateAdded()
+		fields := c.ChangedFields
+		if fields == nil {
+			fields = []string{}
+		}
+		var detectedAt *time.Time
+		if c.Archived {
+			detectedAt = dateReleased
+		}
+		batch.Queue(`
+			INSERT INTO kev_changes (
+				cve_id, change_type, catalog_version, date_released,
+				vendor_project, product, vulnerability_name, date_added,
+				due_date, changed_fields, json, archived, detected_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE($13, now()))
+			ON CONFLICT (cve_id, change_type, catalog_version) DO NOTHING
+		`, c.CveID, c.ChangeType, catalogVersion, dateReleased,
+			c.Vuln.VendorProject, c.Vuln.Product, c.Vuln.VulnerabilityName, dateAdded,
+			c.Vuln.DueDate, fields, jsonBytes, c.Archived, detectedAt)
+	}
+}
+
+// ListKevChanges returns KEV changes detected at or after since and before
+// until, newest first. A zero until is open. An empty changeType returns all
+// change types.
+func ListKevChanges(ctx context.Context, db *pgxpool.Pool, since, until time.Time, changeType string) ([]KevChange, error) {
+	var before *time.Time
+	if !until.IsZero() {
+		before = &until
+	}
+	rows, err := db.Query(ctx, `
+		SELECT id, cve_id, change_type, catalog_version, date_released,
+		       changed_fields, detected_at, archived, json
+		FROM kev_changes
+		WHERE detected_at >= $1
+		  AND ($2::timestamptz IS NULL OR detected_at < $2)
+		  AND ($3 = '' OR change_type = $3)
+		ORDER BY detected_at DESC, change_type, cve_id
+	`, since, before, changeType)
+	if err != nil {
+		return nil, fmt.Errorf("query kev_changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []KevChange
+	for rows.Next() {
+		var c KevChange
+		var raw []byte
+		if err := rows.Scan(&c.ID, &c.CveID, &c.ChangeType, &c.CatalogVersion, &c.DateReleased,
+			&c.ChangedFields, &c.DetectedAt, &c.Archived, &raw); err != nil {
+			return nil, fmt.Errorf("scan kev_changes row: %w", err)
+		}
+		if err := json.Unmarshal(raw, &c.Vuln); err != nil {
+			return nil, fmt.Errorf("decode kev_changes json for %s: %w", c.CveID, err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,107 @@
+package cve
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// kevDateLayouts are the date formats seen in the KEV catalog and its mirrors.
+// CISA publishes plain dates, but archived copies and re-exports have used
+// timestamps and US-style dates.
+var kevDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z07:00",
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02",
+	"01/02/2006",
+	"1/2/2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+// parseKevDate parses a KEV calendar date, such as dateAdded or dueDate, in
+// any of the known layouts. A timestamp keeps the date as written in its own
+// offset: "2026-10-10T23:00:00-05:00" is due on October 10, not 11.
+// It returns (nil, nil) for an empty value so callers can tell
+// "no date" apart from "date that failed to parse".
+func parseKevDate(s string) (*time.Time, error) {
+	t, err := parseKevLayouts(s)
+	if t == nil {
+		return nil, err
+	}
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return &d, nil
+}
+
+// parseKevTime parses a KEV timestamp, such as the catalog's dateReleased,
+// in any of the known layouts, keeping its instant. Like parseKevDate it
+// returns (nil, nil) for an empty value.
+func parseKevTime(s string) (*time.Time, error) {
+	t, err := parseKevLayouts(s)
+	if t == nil {
+		return nil, err
+	}
+	u := t.UTC()
+	return &u, nil
+}
+
+func parseKevLayouts(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range kevDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecognised KEV date %q", s)
+}
+
+// ParsedDateAdded returns the date the CVE was added to KEV, or nil if unset.
+func (v KevVuln) ParsedDateAdded() (*time.Time, error) {
+	return parseKevDate(v.DateAdded)
+}
+
+// ParsedDueDate returns the remediation due date, or nil if unset, with
+// the parse error DueDate leaves out.
+func (v KevVuln) ParsedDueDate() (*time.Time, error) {
+	return parseKevDate(v.DueDateRaw)
+}
+
+// UnmarshalJSON decodes an entry and sets DueDate from its dueDate.
+func (v *KevVuln) UnmarshalJSON(b []byte) error {
+	type plain KevVuln
+	if err := json.Unmarshal(b, (*plain)(v)); err != nil {
+		return err
+	}
+	v.DueDate, _ = parseKevDate(v.DueDateRaw)
+	return nil
+}
+
+// KevDateWarning describes a KEV date field that was present but unparsable.
+type KevDateWarning struct {
+	CveID string
+	Field string
+	Value string
+	Err   error
+}
+
+// validateKevDates returns a warning for each non-empty date field that
+// could not be parsed. Missing dates are not warnings.
+func validateKevDates(v KevVuln) []KevDateWarning {
+	var warnings []KevDateWarning
+	if _, err := v.ParsedDateAdded(); err != nil {
+		warnings = append(warnings, KevDateWarning{CveID: v.CveID, Field: "dateAdded", Value: v.DateAdded, Err: err})
+	}
+	if _, err := v.ParsedDueDate(); err != nil {
+		warnings = append(warnings, KevDateWarning{CveID: v.CveID, Field: "dueDate", Value: v.DueDateRaw, Err: err})
+	}
+	return warnings
+}
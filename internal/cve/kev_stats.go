@@ -0,0 +1,105 @@
+package cve
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KEV statistics periods.
+const (
+	KevStatsMonth = "month"
+	KevStatsYear  = "year"
+)
+
+// KevPeriod is one month or year of KEV listings. A CVE is listed on its
+// earliest known dateAdded, from the current catalog or any recorded
+// change, archived history included.
+type KevPeriod struct {
+	Period     time.Time `json:"period"`      // first day of the month or year, UTC
+	Added      int       `json:"added"`       // CVEs first listed in the period
+	Removed    int       `json:"removed"`     // CVEs removed by a release in the period
+	Ransomware int       `json:"ransomware"`  // added CVEs with known ransomware use
+	WithNVD    int       `json:"with_nvd"`    // added CVEs with an NVD publication date
+	MedianDays *float64  `json:"median_days"` // median days from NVD publication to listing
+	P90Days    *float64  `json:"p90_days"`    // 90th percentile of the same
+}
+
+// KevStats counts KEV listings and removals per period in [since, until),
+// with the time from NVD publication to listing. by is KevStatsMonth or
+// KevStatsYear; zero bounds are open.
+func KevStats(ctx context.Context, db *pgxpool.Pool, by string, since, until time.Time) ([]KevPeriod, error) {
+	if by != KevStatsMonth && by != KevStatsYear {
+		return nil, fmt.Errorf("invalid period %q: want %s or %s", by, KevStatsMonth, KevStatsYear)
+	}
+	var after, before *time.Time
+	if !since.IsZero() {
+		after = &since
+	}
+	if !until.IsZero() {
+		before = &until
+	}
+
+	rows, err := db.Query(ctx, `
+		WITH listed AS (
+			SELECT cve_id, min(date_added) AS date_added FROM (
+				SELECT cve_id, date_added FROM kev_changes
+				WHERE change_type = 'added' AND date_added IS NOT NULL
+				UNION ALL
+				SELECT cve_id, (json->>'dateAdded')::date FROM cve_enriched
+				WHERE source = 'CISA-KEV' AND json->>'dateAdded' ~ '^\d{4}-\d{2}-\d{2}$'
+			) d
+			GROUP BY cve_id
+		), listings AS (
+			SELECT l.cve_id, l.date_added,
+			       l.date_added - left(n.json->>'published', 10)::date AS days,
+			       COALESCE(k.json->>'knownRansomwareCampaignUse', '') = 'Known' AS ransomware
+			FROM listed l
+			LEFT JOIN cve_enriched n ON n.cve_id = l.cve_id AND n.source = 'NVD'
+			     AND n.json->>'published' ~ '^\d{4}-\d{2}-\d{2}'
+			LEFT JOIN cve_enriched k ON k.cve_id = l.cve_id AND k.source = 'CISA-KEV'
+			WHERE ($2::timestamptz IS NULL OR l.date_added >= $2)
+			  AND ($3::timestamptz IS NULL OR l.date_added < $3)
+		), removals AS (
+			SELECT date_released::date AS day, count(DISTINCT cve_id) AS removed
+			FROM kev_changes
+			WHERE change_type = 'removed' AND date_released IS NOT NULL
+			  AND ($2::timestamptz IS NULL OR date_released >= $2)
+			  AND ($3::timestamptz IS NULL OR date_released < $3)
+			GROUP BY 1
+		), added AS (
+			SELECT date_trunc($1, date_added)::date AS period, count(*) AS added,
+			       count(*) FILTER (WHERE ransomware) AS ransomware,
+			       count(days) AS with_nvd,
+			       percentile_cont(0.5) WITHIN GROUP (ORDER BY days) AS median_days,
+			       percentile_cont(0.9) WITHIN GROUP (ORDER BY days) AS p90_days
+			FROM listings
+			GROUP BY 1
+		), removed AS (
+			SELECT date_trunc($1, day)::date AS period, sum(removed)::int AS removed
+			FROM removals
+			GROUP BY 1
+		)
+		SELECT COALESCE(a.period, r.period), COALESCE(a.added, 0), COALESCE(r.removed, 0),
+		       COALESCE(a.ransomware, 0), COALESCE(a.with_nvd, 0), a.median_days, a.p90_days
+		FROM added a
+		FULL JOIN removed r ON r.period = a.period
+		ORDER BY 1
+	`, by, after, before)
+	if err != nil {
+		return nil, fmt.Errorf("query KEV statistics: %w", err)
+	}
+	defer rows.Close()
+
+	var out []KevPeriod
+	for rows.Next() {
+		var p KevPeriod
+		if err := rows.Scan(&p.Period, &p.Added, &p.Removed, &p.Ransomware, &p.WithNVD, &p.MedianDays, &p.P90Days); err != nil {
+			return nil, fmt.Errorf("scan KEV statistics: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -2,13 +2,18 @@ package cve
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"tiger2go/internal/config"
 	"tiger2go/internal/db"
+	"tiger2go/internal/provenance"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -74,6 +79,19 @@ func TestKevRunner_Integration(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 1, count)
 
+	// Provenance is stored with the record
+	var fetchURL, upstreamVersion, toolVersion string
+	var httpStatus int
+	err = pool.QueryRow(ctx, `
+		SELECT fetch_url, http_status, upstream_version, tool_version
+		FROM cve_enriched WHERE cve_id = 'CVE-TEST-KEV-001' AND source = 'CISA-KEV'
+	`).Scan(&fetchURL, &httpStatus, &upstreamVersion, &toolVersion)
+	require.NoError(t, err)
+	assert.Equal(t, mockServer.URL, fetchURL)
+	assert.Equal(t, http.StatusOK, httpStatus)
+	assert.Equal(t, "2099.01.01", upstreamVersion)
+	assert.NotEmpty(t, toolVersion)
+
 	// 5. Verify State
 	var cursor string
 	err = pool.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = 'CISA-KEV'").Scan(&cursor)
@@ -85,3 +103,209 @@ func TestKevRunner_Integration(t *testing.T) {
 	// Clean up
 	_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = 'CVE-TEST-KEV-001'")
 }
+
+func TestKevRunner_RecordsChanges(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	err := db.Migrate(databaseURL, "../../migrations")
+	require.NoError(t, err, "failed to run migrations")
+
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	catalog := `{
+		"catalogVersion": "2099.01.01",
+		"dateReleased": "2099-01-01T00:00:00Z",
+		"vulnerabilities": [
+			{"cveID": "CVE-TEST-KEVDIFF-001", "product": "Gateway", "dateAdded": "2099-01-01", "dueDate": "2099-01-22"}
+		]
+	}`
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(catalog))
+	}))
+	defer mockServer.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = 'CISA-KEV'")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE source = 'CISA-KEV'")
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id LIKE 'CVE-TEST-KEVDIFF-%'")
+	}
+	cleanup()
+	defer cleanup()
+
+	runner := NewKevRunner(pool, config.KevConfig{Enabled: true, URL: mockServer.URL})
+
+	// Baseline load records no changes
+	require.NoError(t, runner.Run(ctx))
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM kev_changes WHERE cve_id LIKE 'CVE-TEST-KEVDIFF-%'").Scan(&count))
+	assert.Equal(t, 0, count)
+
+	// Next release adds one CVE and extends a due date
+	catalog = `{
+		"catalogVersion": "2099.01.02",
+		"dateReleased": "2099-01-02T00:00:00Z",
+		"vulnerabilities": [
+			{"cveID": "CVE-TEST-KEVDIFF-001", "product": "Gateway", "dateAdded": "2099-01-01", "dueDate": "2099-02-01"},
+			{"cveID": "CVE-TEST-KEVDIFF-002", "product": "VPN", "dateAdded": "2099-01-02", "dueDate": "2099-01-23"}
+		]
+	}`
+	require.NoError(t, runner.Run(ctx))
+
+	changes, err := ListKevChanges(ctx, pool, time.Now().Add(-time.Hour), time.Time{}, "")
+	require.NoError(t, err)
+
+	got := map[string]string{}
+	for _, c := range changes {
+		if c.CatalogVersion == "2099.01.02" {
+			got[c.CveID] = c.ChangeType
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"CVE-TEST-KEVDIFF-001": KevChangeUpdated,
+		"CVE-TEST-KEVDIFF-002": KevChangeAdded,
+	}, got)
+
+	// A later release with the same entries rewrites nothing
+	var same KevCatalog
+	require.NoError(t, json.Unmarshal([]byte(catalog), &same))
+	same.CatalogVersion, same.DateReleased = "2099.01.03", "2099-01-03T00:00:00Z"
+	stats, err := runner.upsertVulns(ctx, &same, nil, provenance.New(mockServer.URL, http.StatusOK, same.CatalogVersion))
+	require.NoError(t, err)
+	assert.Equal(t, upsertStats{Skipped: 2}, stats)
+}
+
+func TestKevRunner_RemovesDroppedEntries(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+
+	err := db.Migrate(databaseURL, "../../migrations")
+	require.NoError(t, err, "failed to run migrations")
+
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	release := func(version string, ids ...string) string {
+		var vulns []string
+		for _, id := range ids {
+			vulns = append(vulns, fmt.Sprintf(`{"cveID": %q, "product": "Gateway", "dateAdded": "2099-01-01", "dueDate": "2099-01-22"}`, id))
+		}
+		return fmt.Sprintf(`{"catalogVersion": %q, "dateReleased": "%sT00:00:00Z", "vulnerabilities": [%s]}`,
+			version, strings.ReplaceAll(version, ".", "-"), strings.Join(vulns, ","))
+	}
+	catalog := release("2099.01.01", "CVE-TEST-KEVDROP-001", "CVE-TEST-KEVDROP-002")
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(catalog))
+	}))
+	defer mockServer.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = 'CISA-KEV'")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE source = 'CISA-KEV'")
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id LIKE 'CVE-TEST-KEVDROP-%'")
+	}
+	cleanup()
+	defer cleanup()
+
+	runner := NewKevRunner(pool, config.KevConfig{Enabled: true, URL: mockServer.URL})
+	require.NoError(t, runner.Run(ctx))
+
+	// The second release drops one entry, the third still lacks it.
+	catalog = release("2099.01.02", "CVE-TEST-KEVDROP-001")
+	require.NoError(t, runner.Run(ctx))
+	catalog = release("2099.01.03", "CVE-TEST-KEVDROP-001")
+	require.NoError(t, runner.Run(ctx))
+
+	var removals []string
+	rows, err := pool.Query(ctx, "SELECT catalog_version FROM kev_changes WHERE cve_id = 'CVE-TEST-KEVDROP-002' AND change_type = 'removed'")
+	require.NoError(t, err)
+	for rows.Next() {
+		var version string
+		require.NoError(t, rows.Scan(&version))
+		removals = append(removals, version)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"2099.01.02"}, removals, "recorded once")
+
+	existing, err := runner.loadExistingKev(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, existing, "CVE-TEST-KEVDROP-001")
+	assert.NotContains(t, existing, "CVE-TEST-KEVDROP-002")
+}
+
+func TestImportKevArchive(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id LIKE 'CVE-TEST-KEVARC-%'")
+	}
+	cleanup()
+	defer cleanup()
+
+	read := func(s string) *KevCatalog {
+		c, err := ReadKevCatalog(strings.NewReader(s))
+		require.NoError(t, err)
+		return c
+	}
+	first := read(`{"catalogVersion": "1901.11.03", "dateReleased": "1901-11-03T00:00:00Z", "vulnerabilities": [
+		{"cveID": "CVE-TEST-KEVARC-001", "dateAdded": "1901-11-03", "dueDate": "1901-11-17"},
+		{"cveID": "CVE-TEST-KEVARC-002", "dateAdded": "1901-11-03", "dueDate": "1901-11-17"}]}`)
+	second := read(`{"catalogVersion": "1901.11.10", "dateReleased": "1901-11-10T00:00:00Z", "vulnerabilities": [
+		{"cveID": "CVE-TEST-KEVARC-001", "dateAdded": "1901-11-03", "dueDate": "1901-12-01"},
+		{"cveID": "CVE-TEST-KEVARC-003", "dateAdded": "1901-11-10", "dueDate": "1901-11-24"}]}`)
+
+	stats, err := ImportKevArchive(ctx, pool, []*KevCatalog{second, first, second})
+	require.NoError(t, err)
+	assert.Equal(t, KevImportStats{Catalogs: 2, Skipped: 1, Changes: 5}, stats, "2 added, then 1 added, 1 updated, 1 removed")
+
+	changes, err := ListKevChanges(ctx, pool, time.Date(1901, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(1901, 12, 1, 0, 0, 0, 0, time.UTC), "")
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, c := range changes {
+		assert.True(t, c.Archived)
+		assert.Equal(t, *c.DateReleased, c.DetectedAt, "archived changes are dated at their release")
+		if c.CatalogVersion == "1901.11.10" {
+			got[c.CveID] = c.ChangeType
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"CVE-TEST-KEVARC-001": KevChangeUpdated,
+		"CVE-TEST-KEVARC-002": KevChangeRemoved,
+		"CVE-TEST-KEVARC-003": KevChangeAdded,
+	}, got)
+
+	// A later import continues from the archived state
+	third := read(`{"catalogVersion": "1901.11.17", "dateReleased": "1901-11-17T00:00:00Z", "vulnerabilities": [
+		{"cveID": "CVE-TEST-KEVARC-001", "dateAdded": "1901-11-03", "dueDate": "1901-12-01"},
+		{"cveID": "CVE-TEST-KEVARC-003", "dateAdded": "1901-11-10", "dueDate": "1901-11-24"}]}`)
+	stats, err = ImportKevArchive(ctx, pool, []*KevCatalog{third})
+	require.NoError(t, err)
+	assert.Equal(t, KevImportStats{Catalogs: 1}, stats)
+
+	periods, err := KevStats(ctx, pool, KevStatsMonth, time.Date(1901, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(1902, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, periods, 1)
+	assert.Equal(t, 3, periods[0].Added)
+	assert.Equal(t, 1, periods[0].Removed)
+	assert.Equal(t, time.Date(1901, 11, 1, 0, 0, 0, 0, time.UTC), periods[0].Period.UTC())
+}
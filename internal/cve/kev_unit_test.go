@@ -0,0 +1,182 @@
+package cve
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ---------------------------------------------------------------------------
+// diffKev
+// ---------------------------------------------------------------------------
+
+func TestDiffKev_AddedUpdatedRemoved(t *testing.T) {
+	existing := map[string]KevVuln{
+		"CVE-2024-0001": {CveID: "CVE-2024-0001", Product: "Gateway", DueDateRaw: "2024-02-01"},
+		"CVE-2024-0002": {CveID: "CVE-2024-0002", Product: "Router"},
+		"CVE-2024-0003": {CveID: "CVE-2024-0003", Product: "Legacy"},
+	}
+	catalog := []KevVuln{
+		{CveID: "CVE-2024-0001", Product: "Gateway", DueDateRaw: "2024-03-01", Notes: "extended"},
+		{CveID: "CVE-2024-0002", Product: "Router"},
+		{CveID: "CVE-2024-0004", Product: "VPN"},
+	}
+
+	changes := diffKev(existing, catalog)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, KevChangeAdded, changes[0].ChangeType)
+	assert.Equal(t, "CVE-2024-0004", changes[0].CveID)
+	assert.Equal(t, "VPN", changes[0].Vuln.Product)
+
+	assert.Equal(t, KevChangeRemoved, changes[1].ChangeType)
+	assert.Equal(t, "CVE-2024-0003", changes[1].CveID)
+
+	assert.Equal(t, KevChangeUpdated, changes[2].ChangeType)
+	assert.Equal(t, "CVE-2024-0001", changes[2].CveID)
+	assert.Equal(t, []string{"dueDate", "notes"}, changes[2].ChangedFields)
+}
+
+func TestDiffKev_Unchanged(t *testing.T) {
+	v := KevVuln{CveID: "CVE-2024-0001", VendorProject: "Acme", Product: "Gateway"}
+	changes := diffKev(map[string]KevVuln{v.CveID: v}, []KevVuln{v})
+	assert.Empty(t, changes)
+}
+
+func TestDiffKev_RansomwareUse(t *testing.T) {
+	old := KevVuln{CveID: "CVE-2024-0001", KnownRansomwareCampaignUse: "Unknown"}
+	v := old
+	v.KnownRansomwareCampaignUse = "Known"
+	changes := diffKev(map[string]KevVuln{v.CveID: old}, []KevVuln{v})
+	require.Len(t, changes, 1)
+	assert.Equal(t, []string{"knownRansomwareCampaignUse"}, changes[0].ChangedFields)
+
+	stored := KevVuln{CveID: "CVE-2024-0001"} // saved before the field was kept
+	assert.Empty(t, diffKev(map[string]KevVuln{v.CveID: stored}, []KevVuln{v}))
+}
+
+func TestDiffKev_EmptyExisting(t *testing.T) {
+	changes := diffKev(map[string]KevVuln{}, []KevVuln{{CveID: "CVE-2024-0002"}, {CveID: "CVE-2024-0001"}})
+	require.Len(t, changes, 2)
+	assert.Equal(t, "CVE-2024-0001", changes[0].CveID, "output is sorted by CVE ID within a change type")
+}
+
+// ---------------------------------------------------------------------------
+// parseKevDate
+// ---------------------------------------------------------------------------
+
+func TestParseKevDate_Layouts(t *testing.T) {
+	want := time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)
+	for _, in := range []string{
+		"2024-03-07",
+		" 2024-03-07 ",
+		"2024-03-07T00:00:00Z",
+		"2024-03-07T00:00:00.000Z",
+		"2024-03-07T00:00:00",
+		"2024/03/07",
+		"03/07/2024",
+		"3/7/2024",
+		"March 7, 2024",
+		"Mar 7, 2024",
+		"7 March 2024",
+	} {
+		t.Run(in, func(t *testing.T) {
+			got, err := parseKevDate(in)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.True(t, want.Equal(*got), "got %s", got)
+		})
+	}
+}
+
+func TestParseKevDate_EmptyIsNilWithoutError(t *testing.T) {
+	got, err := parseKevDate("")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = parseKevDate("   ")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestParseKevDate_Invalid(t *testing.T) {
+	got, err := parseKevDate("next tuesday")
+	assert.Error(t, err)
+	assert.Nil(t, got)
+}
+
+func TestParseKevDate_KeepsWrittenDate(t *testing.T) {
+	want := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	for _, in := range []string{
+		"2026-10-10T23:00:00-05:00",
+		"2026-10-10T00:30:00+09:00",
+		"2026-10-10T12:00:00.000Z",
+	} {
+		got, err := parseKevDate(in)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.True(t, want.Equal(*got), "%s: got %s", in, got)
+	}
+}
+
+func TestParseKevTime_KeepsInstant(t *testing.T) {
+	got, err := parseKevTime("2026-10-10T23:00:00-05:00")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, time.Date(2026, 10, 11, 4, 0, 0, 0, time.UTC), *got)
+
+	got, err = parseKevTime("")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestValidateKevDates(t *testing.T) {
+	warnings := validateKevDates(KevVuln{CveID: "CVE-2024-0001", DateAdded: "2024-01-01", DueDateRaw: "TBD"})
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "dueDate", warnings[0].Field)
+	assert.Equal(t, "TBD", warnings[0].Value)
+
+	assert.Empty(t, validateKevDates(KevVuln{CveID: "CVE-2024-0002", DateAdded: "2024-01-01"}),
+		"a missing due date is not a warning")
+}
+
+func TestKevVuln_DueDate(t *testing.T) {
+	var v KevVuln
+	require.NoError(t, json.Unmarshal([]byte(`{"cveID":"CVE-2024-0001","dueDate":"2026-10-10T23:00:00-05:00"}`), &v))
+	require.NotNil(t, v.DueDate)
+	assert.Equal(t, time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC), *v.DueDate)
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"dueDate":"2026-10-10T23:00:00-05:00"`, "stored as published")
+
+	v = KevVuln{}
+	require.NoError(t, json.Unmarshal([]byte(`{"cveID":"CVE-2024-0001","dueDate":"TBD"}`), &v))
+	assert.Nil(t, v.DueDate)
+	assert.Equal(t, "TBD", v.DueDateRaw, "failed to parse")
+
+	v = KevVuln{}
+	require.NoError(t, json.Unmarshal([]byte(`{"cveID":"CVE-2024-0001"}`), &v))
+	assert.Nil(t, v.DueDate)
+	assert.Empty(t, v.DueDateRaw, "no due date")
+}
+
+// ---------------------------------------------------------------------------
+// ReadKevCatalog
+// ---------------------------------------------------------------------------
+
+func TestReadKevCatalog(t *testing.T) {
+	c, err := ReadKevCatalog(strings.NewReader(`{"catalogVersion": "2021.11.03", "dateReleased": "2021-11-03T15:59:13.2007Z",
+		"count": 1, "vulnerabilities": [{"cveID": "CVE-2021-27104", "vendorProject": "Accellion", "dateAdded": "2021-11-03"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2021.11.03", c.CatalogVersion)
+	assert.Equal(t, "CVE-2021-27104", c.Vulnerabilities[0].CveID)
+
+	_, err = ReadKevCatalog(strings.NewReader(`{"catalogVersion": "x", "vulnerabilities": []}`))
+	assert.Error(t, err, "an empty catalog is not a release")
+	_, err = ReadKevCatalog(strings.NewReader(`<html>`))
+	assert.Error(t, err)
+}
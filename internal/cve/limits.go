@@ -0,0 +1,26 @@
+package cve
+
+import "tiger2go/internal/httpclient"
+
+// Default upper bounds on upstream response bodies, overridable with each
+// source's max_response_mb setting. They are generous multiples of real sizes
+// (a 2,000-CVE NVD page is ~10 MB, the KEV catalog ~1.5 MB, a 10,000-row EPSS
+// page ~1.5 MB) and only exist to stop a broken or hostile upstream from
+// exhausting memory.
+const (
+	maxNvdResponseBytes  = 64 << 20
+	maxKevResponseBytes  = 32 << 20
+	maxEpssResponseBytes = 32 << 20
+)
+
+func (r *NvdRunner) maxResponseBytes() int64 {
+	return httpclient.LimitFromMB(r.cfg.MaxResponseMB, maxNvdResponseBytes)
+}
+
+func (r *KevRunner) maxResponseBytes() int64 {
+	return httpclient.LimitFromMB(r.cfg.MaxResponseMB, maxKevResponseBytes)
+}
+
+func (r *EpssRunner) maxResponseBytes() int64 {
+	return httpclient.LimitFromMB(r.cfg.MaxResponseMB, maxEpssResponseBytes)
+}
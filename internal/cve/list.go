@@ -0,0 +1,67 @@
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/cursor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EnrichedRecord is one cve_enriched row as served by the list API.
+type EnrichedRecord struct {
+	CveID       string          `json:"cve_id"`
+	Source      string          `json:"source"`
+	CvssBase    *float64        `json:"cvss_base"`
+	Modified    time.Time       `json:"modified"`      // upstream lastModified
+	FirstSeenAt time.Time       `json:"first_seen_at"` // when tigerfetch first stored the record
+	UpdatedAt   time.Time       `json:"updated_at"`    // when tigerfetch last stored a change
+	Data        json.RawMessage `json:"data"`
+
+	// From the NVD record; empty for KEV rows. Derived from Data, so
+	// mirrors do not write them.
+	SourceIdentifier string `json:"source_identifier,omitempty"` // issuing CNA
+	VulnStatus       string `json:"vuln_status,omitempty"`       // NVD analysis status
+}
+
+// Cursor returns the position just after r.
+func (r EnrichedRecord) Cursor() cursor.Cursor {
+	return cursor.Cursor{UpdatedAt: r.UpdatedAt, Key: []string{r.CveID, r.Source}}
+}
+
+// EnrichedCursorKeys is the number of key parts in a cve_enriched cursor.
+const EnrichedCursorKeys = 2
+
+// ListEnriched returns one page of cve_enriched rows in
+// (updated_at, cve_id, source) order. source optionally filters, e.g. "NVD"
+// or "CISA-KEV".
+func ListEnriched(ctx context.Context, pool *pgxpool.Pool, page cursor.Page, source string) ([]EnrichedRecord, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT cve_id, source, cvss_base::float8, modified, first_seen_at, updated_at, json,
+		       COALESCE(source_identifier, ''), COALESCE(vuln_status, '')
+		FROM cve_enriched
+		WHERE (updated_at, cve_id, source) > ($1, $2, $3)
+		  AND updated_at < clock_timestamp() - $4::interval
+		  AND ($5 = '' OR source = $5)
+		ORDER BY updated_at, cve_id, source
+		LIMIT $6
+	`, page.After.UpdatedAt, page.After.KeyPart(0), page.After.KeyPart(1), page.Settle, source, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list cve_enriched: %w", err)
+	}
+	defer rows.Close()
+
+	var out []EnrichedRecord
+	for rows.Next() {
+		var r EnrichedRecord
+		if err := rows.Scan(&r.CveID, &r.Source, &r.CvssBase, &r.Modified, &r.FirstSeenAt, &r.UpdatedAt, &r.Data,
+			&r.SourceIdentifier, &r.VulnStatus); err != nil {
+			return nil, fmt.Errorf("scan cve_enriched: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
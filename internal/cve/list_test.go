@@ -0,0 +1,64 @@
+package cve
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListEnriched_CursorAndUpdatedAt(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	const source = "TEST-LIST"
+	cleanup := func() { _, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE source = $1", source) }
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, modified) VALUES
+			('CVE-2024-0001', $1, '{"v":1}', '2024-01-01'),
+			('CVE-2024-0002', $1, '{"v":1}', '2020-01-01')
+	`, source)
+	require.NoError(t, err)
+
+	list := func(after cursor.Cursor, settle time.Duration) []EnrichedRecord {
+		t.Helper()
+		rows, err := ListEnriched(ctx, pool, cursor.Page{After: after, Limit: 10, Settle: settle}, source)
+		require.NoError(t, err)
+		return rows
+	}
+
+	rows := list(cursor.Cursor{}, 0)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "CVE-2024-0001", rows[0].CveID, "ordered by change time, not upstream modified")
+	assert.Empty(t, list(cursor.Cursor{}, time.Hour), "recent changes are held back by the settle window")
+
+	// A provenance-only update does not move the row.
+	_, err = pool.Exec(ctx, "UPDATE cve_enriched SET fetched_at = now() WHERE cve_id = 'CVE-2024-0001' AND source = $1", source)
+	require.NoError(t, err)
+	end := rows[1].Cursor()
+	assert.Empty(t, list(end, 0))
+
+	// A content change does, even with an older upstream timestamp.
+	_, err = pool.Exec(ctx, `UPDATE cve_enriched SET json = '{"v":2}', modified = '2019-01-01' WHERE cve_id = 'CVE-2024-0001' AND source = $1`, source)
+	require.NoError(t, err)
+	rows = list(end, 0)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "CVE-2024-0001", rows[0].CveID)
+	assert.JSONEq(t, `{"v":2}`, string(rows[0].Data))
+}
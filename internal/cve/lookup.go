@@ -0,0 +1,67 @@
+package cve
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Single-CVE lookups for callers outside the scheduled runs (the enrich API).
+// They go through the same fetchers, retries and size limits as the runners
+// but store nothing.
+
+const defaultNvdURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+// epssLookupChunk bounds the number of CVEs in one EPSS ?cve= query.
+const epssLookupChunk = 100
+
+// Lookup fetches a single CVE from NVD. It returns (nil, nil) when NVD has
+// no record of it.
+func (r *NvdRunner) Lookup(ctx context.Context, cveID string) (*NvdCveItem, error) {
+	baseURL := r.cfg.URL
+	if baseURL == "" {
+		baseURL = defaultNvdURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NVD URL %q: %w", baseURL, err)
+	}
+	q := u.Query()
+	q.Set("cveId", cveID)
+	u.RawQuery = q.Encode()
+
+	resp, err := r.fetchPage(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Vulnerabilities {
+		if strings.EqualFold(resp.Vulnerabilities[i].Cve.ID, cveID) {
+			return &resp.Vulnerabilities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Lookup fetches the latest EPSS scores for the given CVEs. CVEs unknown to
+// EPSS are simply absent from the result.
+func (r *EpssRunner) Lookup(ctx context.Context, cveIDs []string) ([]EpssRow, error) {
+	var out []EpssRow
+	for start := 0; start < len(cveIDs); start += epssLookupChunk {
+		end := min(start+epssLookupChunk, len(cveIDs))
+		u, err := url.Parse(r.cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPSS URL %q: %w", r.cfg.URL, err)
+		}
+		q := u.Query()
+		q.Set("cve", strings.Join(cveIDs[start:end], ","))
+		u.RawQuery = q.Encode()
+
+		page, err := r.fetch(ctx, u.String())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page.Data...)
+	}
+	return out, nil
+}
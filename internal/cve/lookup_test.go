@@ -0,0 +1,71 @@
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubNvd struct {
+	urls []string
+	resp *NvdResponse
+}
+
+func (s *stubNvd) FetchPage(_ context.Context, url string) (*NvdResponse, error) {
+	s.urls = append(s.urls, url)
+	return s.resp, nil
+}
+
+type stubEpss struct{ urls []string }
+
+func (s *stubEpss) FetchPage(_ context.Context, url string) (*EpssResponse, error) {
+	s.urls = append(s.urls, url)
+	return &EpssResponse{Data: []EpssRow{{CVE: "CVE-2024-21887", EPSS: "0.97"}}}, nil
+}
+
+func TestNvdLookup(t *testing.T) {
+	var resp NvdResponse
+	require.NoError(t, json.Unmarshal([]byte(`{"vulnerabilities":[{"cve":{"id":"CVE-2024-21887","lastModified":"2024-01-22T17:15:10Z"}}]}`), &resp))
+	stub := &stubNvd{resp: &resp}
+	r := NewNvdRunner(nil, config.NvdConfig{URL: "https://nvd.example/cves"})
+	r.SetFetcher(stub)
+
+	item, err := r.Lookup(context.Background(), "CVE-2024-21887")
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "CVE-2024-21887", item.Cve.ID)
+	assert.Equal(t, []string{"https://nvd.example/cves?cveId=CVE-2024-21887"}, stub.urls)
+
+	item, err = r.Lookup(context.Background(), "CVE-1999-0001")
+	require.NoError(t, err)
+	assert.Nil(t, item, "a response without the requested CVE means not found")
+}
+
+func TestEpssLookup_Chunks(t *testing.T) {
+	stub := &stubEpss{}
+	r := NewEpssRunner(nil, config.EpssConfig{URL: "https://epss.example/data/v1/epss"})
+	r.SetFetcher(stub)
+
+	ids := make([]string, epssLookupChunk+1)
+	for i := range ids {
+		ids[i] = "CVE-2024-0001"
+	}
+	rows, err := r.Lookup(context.Background(), ids)
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+	require.Len(t, stub.urls, 2)
+	assert.True(t, strings.HasPrefix(stub.urls[0], "https://epss.example/data/v1/epss?cve=CVE-2024-0001%2C"))
+}
+
+func TestSelectCvss_Severity(t *testing.T) {
+	c := SelectCvss(json.RawMessage(`{"cvssMetricV30":[{"cvssData":{"baseScore":7.5,"baseSeverity":"HIGH"}}],"cvssMetricV31":[{"cvssData":{"baseScore":9.1,"baseSeverity":"CRITICAL"}}]}`), "", nil)
+	require.NotNil(t, c)
+	assert.Equal(t, 9.1, c.Score)
+	assert.Equal(t, "CRITICAL", c.Severity)
+}
@@ -4,15 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -29,37 +36,142 @@ type NvdResponse struct {
 }
 
 type NvdCveItem struct {
-	Cve struct {
-		ID           string          `json:"id"`
-		LastModified string          `json:"lastModified"`
-		Metrics      json.RawMessage `json:"metrics"`
-		// We capture the whole raw CVE object for storage,
-		// but unmarshal specific fields for indexing.
-	} `json:"cve"`
+	Cve NvdCve `json:"cve"`
 }
 
-// Helper to extract the full raw JSON of the item since we can't easily Unmarshal into itself
-// In a real optimized scenario we might use a custom unmarshaler or map[string]interface{}.
-// For simplicity, we will just marshal the struct back to JSON or keep it as byte slice if we can.
-// Actually, since we want to store the "cve" part of the item, we can just use the Cve field above
-// and when inserting, marshal it again.
+// NvdCve is the "cve" object of an NVD API 2.0 result. The fields used for
+// indexing are decoded; the whole object is kept as received and stored
+// verbatim in cve_enriched.json.
+type NvdCve struct {
+	ID               string          `json:"id"`
+	SourceIdentifier string          `json:"sourceIdentifier,omitempty"` // CNA that issued the CVE, e.g. "psirt@fortinet.com"
+	Published        string          `json:"published,omitempty"`
+	LastModified     string          `json:"lastModified"`
+	VulnStatus       string          `json:"vulnStatus,omitempty"` // NVD analysis status, e.g. "Awaiting Analysis", "Analyzed"
+	Metrics          json.RawMessage `json:"metrics"`
+	References       []NvdReference  `json:"references,omitempty"`
+	Configurations   json.RawMessage `json:"configurations,omitempty"` // CPE applicability, with fixed versions
+
+	raw json.RawMessage // the object as received; nil for items built in code
+}
+
+// Vulnerability statuses NVD reports in vulnStatus.
+const (
+	VulnStatusReceived           = "Received"
+	VulnStatusAwaitingAnalysis   = "Awaiting Analysis"
+	VulnStatusUndergoingAnalysis = "Undergoing Analysis"
+	VulnStatusAnalyzed           = "Analyzed"
+	VulnStatusModified           = "Modified"
+	VulnStatusDeferred           = "Deferred"
+	VulnStatusRejected           = "Rejected"
+)
+
+// NvdReference is one of a CVE's reference links. NVD analysts label them
+// with tags such as "Exploit", "Patch" and "Vendor Advisory".
+type NvdReference struct {
+	URL    string   `json:"url"`
+	Source string   `json:"source,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// Reference tags used in scoring and reports.
+const (
+	RefTagExploit        = "Exploit"
+	RefTagPatch          = "Patch"
+	RefTagVendorAdvisory = "Vendor Advisory"
+)
+
+// HasTag reports whether NVD tagged the reference with tag.
+func (r NvdReference) HasTag(tag string) bool { return slices.Contains(r.Tags, tag) }
+
+// TaggedURL returns the URL of the first reference tagged tag, or "".
+func TaggedURL(refs []NvdReference, tag string) string {
+	for _, r := range refs {
+		if r.HasTag(tag) {
+			return r.URL
+		}
+	}
+	return ""
+}
+
+// nvdCveFields avoids recursing into NvdCve's own (un)marshalers.
+type nvdCveFields NvdCve
+
+func (c *NvdCve) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*nvdCveFields)(c)); err != nil {
+		return err
+	}
+	c.raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// MarshalJSON returns the object as received, so descriptions, weaknesses,
+// configurations and references survive storage; items built in code
+// marshal their decoded fields.
+func (c NvdCve) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	return json.Marshal(nvdCveFields(c))
+}
 
 type NvdRunner struct {
-	db     *pgxpool.Pool
-	cfg    config.NvdConfig
-	client *http.Client
+	db         *pgxpool.Pool
+	cfg        config.NvdConfig
+	client     *http.Client
+	fetcher    NVDFetcher // nil means fetch over HTTP with client
+	sink       ScoreSink  // optional analytics copy
+	quality    *quality.Recorder
+	quarantine *quarantine.Store
+	archive    *rawarchive.Archive
+	rewrite    bool // reprocess: rewrite unchanged rows, never with an older version
+
+	cvssPriority []string // [merge] cvss; nil takes NVD's order
+
+	// pageDelay separates page requests. NVD allows 5 requests per 30s
+	// rolling window without an API key (~6s apart) and 50 with one (~0.6s).
+	pageDelay time.Duration
+
+	// overlap is how much of the previous window each run fetches again.
+	overlap time.Duration
 }
 
 func NewNvdRunner(db *pgxpool.Pool, cfg config.NvdConfig) *NvdRunner {
+	delay := 6 * time.Second
+	if cfg.ApiKey != "" {
+		delay = 600 * time.Millisecond
+	}
+	overlap, err := cfg.GetCursorOverlap()
+	if err != nil || overlap < 0 {
+		slog.Warn("Invalid NVD cursor overlap, using default 15m", "overlap", cfg.CursorOverlap, "error", err)
+		overlap = 15 * time.Minute
+	}
 	return &NvdRunner{
 		db:  db,
 		cfg: cfg,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		pageDelay: delay,
+		overlap:   overlap,
 	}
 }
 
+// ingest_state sources of the NVD runner's cursors.
+const (
+	nvdPublishedKey = "NVD"          // publication backfill
+	nvdModifiedKey  = "NVD-MODIFIED" // modified sync
+)
+
+// nvdEpoch is where the publication backfill starts.
+var nvdEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Run brings cve_enriched up to date with NVD in two passes over 120-day
+// windows, each with its own cursor in ingest_state. The publication
+// backfill ('NVD') fetches by pubStartDate every CVE published before the
+// modified sync began, once. The modified sync ('NVD-MODIFIED') then
+// fetches by lastModStartDate, which covers new CVEs as well as changes to
+// old ones, such as a CVE rescored years after publication.
 func (r *NvdRunner) Run(ctx context.Context) error {
 	if !r.cfg.Enabled {
 		slog.Info("NVD ingestion disabled")
@@ -71,130 +183,251 @@ func (r *NvdRunner) Run(ctx context.Context) error {
 		metrics.NvdRunDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	// 1. Get Cursor
-	cursor, err := r.getCursor(ctx)
+	now := time.Now().UTC()
+
+	// 1. Get Cursors
+	cursor, pubSet, err := r.getCursor(ctx, nvdPublishedKey)
 	if err != nil {
 		return fmt.Errorf("failed to get NVD cursor: %w", err)
 	}
+	pubDt, err := time.Parse(time.RFC3339, cursor)
+	if !pubSet || err != nil {
+		if pubSet {
+			slog.Warn("Invalid NVD cursor, resetting to 2000-01-01", "cursor", cursor, "error", err)
+		}
+		pubDt, pubSet = nvdEpoch, false
+	}
 
-	startDt, err := time.Parse(time.RFC3339, cursor)
+	cursor, modSet, err := r.getCursor(ctx, nvdModifiedKey)
 	if err != nil {
-		slog.Warn("Invalid NVD cursor, resetting to 2000-01-01", "cursor", cursor, "error", err)
-		startDt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		return fmt.Errorf("failed to get NVD cursor: %w", err)
+	}
+	modDt, err := time.Parse(time.RFC3339, cursor)
+	if !modSet || err != nil {
+		if modSet {
+			slog.Warn("Invalid NVD modified cursor, restarting it", "cursor", cursor, "error", err)
+		}
+		// Changes are tracked from the end of an earlier publication-only
+		// sync, else from now: the backfill fetches every CVE as it is now.
+		modDt = now
+		if pubSet {
+			modDt = pubDt
+		}
+		if err := r.setCursor(ctx, modDt.Format(time.RFC3339), nvdModifiedKey); err != nil {
+			return fmt.Errorf("failed to update cursor: %w", err)
+		}
 	}
-
-	now := time.Now().UTC()
 
 	// Record cursor lag
-	metrics.NvdCursorLag.Set(now.Sub(startDt).Seconds())
+	metrics.NvdCursorLag.Set(now.Sub(pubDt).Seconds())
+
+	// 2. Publication backfill, up to where the modified sync starts
+	var total upsertStats
+	for pubDt.Before(modDt) {
+		endDt := pubDt.Add(nvdMaxRange)
+		if endDt.After(modDt) {
+			endDt = modDt
+		}
+		slog.Info("Processing NVD backfill window", "start", pubDt, "end", endDt)
 
-	// NVD Max window is 120 days
-	maxWindow := 120 * 24 * time.Hour
+		stats, err := r.processWindow(ctx, "pub", pubDt, endDt)
+		total.add(stats)
+		if err != nil {
+			return err
+		}
+		if err := r.setCursor(ctx, endDt.Format(time.RFC3339), nvdPublishedKey); err != nil {
+			return fmt.Errorf("failed to update cursor: %w", err)
+		}
+		pubDt = endDt
+		metrics.NvdCursorLag.Set(now.Sub(pubDt).Seconds())
+	}
 
+	// 3. Modified sync, from a little before the cursor
+	startDt := r.windowStart(modDt)
 	for startDt.Before(now) {
-		endDt := startDt.Add(maxWindow)
+		endDt := startDt.Add(nvdMaxRange)
 		if endDt.After(now) {
 			endDt = now
 		}
-
 		slog.Info("Processing NVD window", "start", startDt, "end", endDt)
 
-		if err := r.processWindow(ctx, startDt, endDt); err != nil {
+		stats, err := r.processWindow(ctx, "lastMod", startDt, endDt)
+		total.add(stats)
+		if err != nil {
 			return err
 		}
 
-		// Update cursor
-		if err := r.setCursor(ctx, endDt.Format(time.RFC3339)); err != nil {
+		// A CVE published in the window was last modified in it or later,
+		// so the backfill cursor moves along: every CVE published before
+		// endDt is stored.
+		if err := r.setCursor(ctx, endDt.Format(time.RFC3339), nvdModifiedKey, nvdPublishedKey); err != nil {
 			return fmt.Errorf("failed to update cursor: %w", err)
 		}
-
 		startDt = endDt
 
 		// Update cursor lag as we catch up
 		metrics.NvdCursorLag.Set(now.Sub(startDt).Seconds())
 	}
 
-	slog.Info("NVD ingestion complete")
+	slog.Info("NVD ingestion complete", "rows", total)
 	return nil
 }
 
-func (r *NvdRunner) processWindow(ctx context.Context, start, end time.Time) error {
-	startIndex := 0
+// windowStart is where the modified sync after cursor starts: overlap
+// before it. NVD stamps a record with the time it was changed, which can
+// be before the change is visible in the API, so one changed at the end of
+// the previous window may have been missed. Fetching that stretch again
+// costs a page or two; records already stored are skipped by the upsert
+// as unchanged.
+func (r *NvdRunner) windowStart(cursor time.Time) time.Time {
+	return cursor.Add(-r.overlap)
+}
+
+// processWindow stores the CVEs whose date by ("pub" for published,
+// "lastMod" for last modified) is in [start, end].
+func (r *NvdRunner) processWindow(ctx context.Context, by string, start, end time.Time) (upsertStats, error) {
+	// NVD expects ISO8601/RFC3339.
+	params := url.Values{
+		by + "StartDate": {start.Format(time.RFC3339)},
+		by + "EndDate":   {end.Format(time.RFC3339)},
+	}
+
+	var total upsertStats
+	err := r.pages(ctx, params, func(resp *NvdResponse, pageURL string) error {
+		prov := provenance.New(pageURL, fetchedStatus(r.fetcher != nil), resp.Version)
+		stats, err := r.saveBatch(ctx, resp.Vulnerabilities, prov)
+		if err != nil {
+			return fmt.Errorf("failed to save batch: %w", err)
+		}
+		stats.record("NVD")
+		total.add(stats)
+
+		metrics.NvdBatchSize.Observe(float64(len(resp.Vulnerabilities)))
+		metrics.NvdCvesProcessed.Add(float64(len(resp.Vulnerabilities)))
+
+		slog.Info("Processed NVD batch", "start_index", resp.StartIndex, "count", len(resp.Vulnerabilities), "total_in_window", resp.TotalResults, "rows", stats)
+		return nil
+	})
+	return total, err
+}
+
+// pages fetches every page of an NVD query, calling fn with each non-empty
+// one, and waits pageDelay between requests.
+func (r *NvdRunner) pages(ctx context.Context, params url.Values, fn func(resp *NvdResponse, pageURL string) error) error {
 	pageSize := r.cfg.PageSize
 	if pageSize <= 0 {
 		pageSize = 2000
 	}
+	baseURL := r.cfg.URL
+	if baseURL == "" {
+		baseURL = defaultNvdURL
+	}
 
-	// Format times for API
-	// NVD expects ISO8601/RFC3339.
-	startStr := start.Format(time.RFC3339)
-	endStr := end.Format(time.RFC3339)
-
+	startIndex := 0
 	for {
-		// Construct URL
-		baseURL := r.cfg.URL
-		if baseURL == "" {
-			baseURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
-		}
 		u, err := url.Parse(baseURL)
 		if err != nil {
 			return fmt.Errorf("invalid NVD URL %q: %w", baseURL, err)
 		}
 		q := u.Query()
-		q.Set("pubStartDate", startStr)
-		q.Set("pubEndDate", endStr)
+		for k, v := range params {
+			q[k] = v
+		}
 		q.Set("resultsPerPage", strconv.Itoa(pageSize))
 		q.Set("startIndex", strconv.Itoa(startIndex))
-		u.RawQuery = q.Encode()
+		u.RawQuery = encodeNvdQuery(q)
 
-		// Fetch
-		respData, err := r.fetchWithRetry(ctx, u.String())
+		resp, err := r.fetchPage(ctx, u.String())
 		if err != nil {
 			return fmt.Errorf("failed to fetch NVD page: %w", err)
 		}
+		if len(resp.Vulnerabilities) == 0 {
+			return nil
+		}
+		if err := fn(resp, u.String()); err != nil {
+			return err
+		}
 
-		// Parse
-		var resp NvdResponse
-		if err := json.Unmarshal(respData, &resp); err != nil {
-			return fmt.Errorf("failed to parse NVD response: %w", err)
+		startIndex += len(resp.Vulnerabilities)
+		if startIndex >= resp.TotalResults {
+			return nil
 		}
 
-		if len(resp.Vulnerabilities) == 0 {
-			break
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pageDelay):
 		}
+	}
+}
 
-		// Save Batch
-		if err := r.saveBatch(ctx, resp.Vulnerabilities); err != nil {
-			return fmt.Errorf("failed to save batch: %w", err)
+// encodeNvdQuery encodes q like url.Values.Encode, except that empty
+// values are written as bare flags ("keywordExactMatch"), the form NVD
+// documents for its valueless parameters.
+func encodeNvdQuery(q url.Values) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(q)) {
+		for _, v := range q[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			if v != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(v))
+			}
 		}
+	}
+	return b.String()
+}
 
-		metrics.NvdBatchSize.Observe(float64(len(resp.Vulnerabilities)))
-		metrics.NvdCvesProcessed.Add(float64(len(resp.Vulnerabilities)))
+// fetchPage fetches and decodes one NVD page, via the configured fetcher if set.
+func (r *NvdRunner) fetchPage(ctx context.Context, urlStr string) (*NvdResponse, error) {
+	if r.fetcher != nil {
+		return r.fetcher.FetchPage(ctx, urlStr)
+	}
 
-		// Log progress
-		slog.Info("Processed NVD batch", "start_index", startIndex, "count", len(resp.Vulnerabilities), "total_in_window", resp.TotalResults)
+	respData, err := r.fetchWithRetry(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
 
-		startIndex += len(resp.Vulnerabilities)
-		if startIndex >= resp.TotalResults {
-			break
-		}
+	r.archive.Put(ctx, rawarchive.NVD, urlStr, respData)
+	resp, err := parseNvdPage(respData)
+	if err != nil {
+		r.quarantine.Put(ctx, quarantine.NVD, "", urlStr, respData, err)
+		return nil, err
+	}
+	return resp, nil
+}
 
-		// Rate limit
-		// NVD recommends sleeping. With API key, limits are higher (50 req/30s rolling window -> ~0.6s)
-		// Without API key, limits are stricter (5 req/30s -> ~6s)
-		delay := 6 * time.Second
-		if r.cfg.ApiKey != "" {
-			delay = 600 * time.Millisecond
-		}
-		time.Sleep(delay)
+func parseNvdPage(body []byte) (*NvdResponse, error) {
+	var resp NvdResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse NVD response: %w", err)
 	}
+	return &resp, nil
+}
 
-	return nil
+// Replay stores the CVEs of a quarantined page like those of a fetched
+// one, and returns how many it wrote or found unchanged.
+func (r *NvdRunner) Replay(ctx context.Context, pageURL string, payload []byte) (int, error) {
+	resp, err := parseNvdPage(payload)
+	if err != nil {
+		return 0, err
+	}
+	stats, err := r.saveBatch(ctx, resp.Vulnerabilities, provenance.New(pageURL, 0, resp.Version))
+	if err != nil {
+		return 0, err
+	}
+	stats.record("NVD")
+	return stats.Written + stats.Skipped, nil
 }
 
 func (r *NvdRunner) fetchWithRetry(ctx context.Context, urlStr string) ([]byte, error) {
 	backoff := 6 * time.Second
 	const maxRetries = 10
+	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
@@ -214,6 +447,7 @@ func (r *NvdRunner) fetchWithRetry(ctx context.Context, urlStr string) ([]byte,
 			metrics.UpstreamRequestDuration.WithLabelValues("nvd").Observe(time.Since(httpStart).Seconds())
 			metrics.NvdFetches.WithLabelValues("error").Inc()
 			slog.Warn("NVD fetch failed, retrying", "url", urlStr, "error", err, "attempt", attempt+1)
+			lastErr = err
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -224,7 +458,7 @@ func (r *NvdRunner) fetchWithRetry(ctx context.Context, urlStr string) ([]byte,
 		metrics.UpstreamRequestDuration.WithLabelValues("nvd").Observe(time.Since(httpStart).Seconds())
 
 		if resp.StatusCode == http.StatusOK {
-			body, readErr := io.ReadAll(resp.Body)
+			body, readErr := httpclient.ReadBody(resp, r.maxResponseBytes())
 			_ = resp.Body.Close()
 			if readErr != nil {
 				return nil, readErr
@@ -233,9 +467,11 @@ func (r *NvdRunner) fetchWithRetry(ctx context.Context, urlStr string) ([]byte,
 			return body, nil
 		}
 		_ = resp.Body.Close()
+		statusErr := newStatusError("nvd", resp)
 
 		// Check for 429 or 503
 		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			lastErr = statusErr
 			metrics.NvdRateLimits.Inc()
 			slog.Warn("NVD rate limited or unavailable", "status", resp.StatusCode, "attempt", attempt+1)
 			select {
@@ -251,110 +487,239 @@ func (r *NvdRunner) fetchWithRetry(ctx context.Context, urlStr string) ([]byte,
 		}
 
 		metrics.NvdApiErrors.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, statusErr
 	}
 
-	return nil, fmt.Errorf("NVD fetch failed after %d retries: %s", maxRetries, urlStr)
+	return nil, fmt.Errorf("NVD fetch failed after %d retries: %s: %w", maxRetries, urlStr, lastErr)
 }
 
-func (r *NvdRunner) saveBatch(ctx context.Context, items []NvdCveItem) error {
-	batch := &pgx.Batch{}
-	queued := 0
+// nvdCopyThreshold is the page size at which saveBatch switches from
+// row-by-row upserts to COPY into a staging table plus a single merge.
+// Backfill pages (2,000 CVEs) take the COPY path; incremental pages are
+// usually small enough that the batch path is just as fast.
+const nvdCopyThreshold = 500
+
+// nvdRow is one CVE prepared for storage.
+type nvdRow struct {
+	id       string
+	json     []byte
+	cvssBase *float64
+	modified time.Time
+	hash     []byte
+}
 
+// prepareNvdRows marshals items and picks each one's stored CVSS score by
+// priority (see SelectCvss).
+// A CVE without an ID is skipped and a score outside 0–10 is stored as
+// NULL; chk records both, and dates in the future.
+func prepareNvdRows(items []NvdCveItem, priority []string, chk *quality.Checker) []nvdRow {
+	rows := make([]nvdRow, 0, len(items))
 	for _, item := range items {
-		// Convert the cve struct back to JSON for storage
+		id := item.Cve.ID
+		if !chk.Required(id, "id", id) {
+			continue
+		}
+		chk.Date(id, "published", nvdTime(item.Cve.Published))
+		chk.Date(id, "lastModified", nvdTime(item.Cve.LastModified))
+
+		// Store the cve object as NVD sent it
 		cveJSON, err := json.Marshal(item.Cve)
 		if err != nil {
 			slog.Error("Failed to marshal CVE item", "id", item.Cve.ID, "error", err)
 			continue
 		}
 
-		// Parse modified time
-		modified, err := time.Parse(time.RFC3339, item.Cve.LastModified)
-		if err != nil {
+		// Unknown without a parsable lastModified, which the check above
+		// reports; the row is stamped now rather than at the zero time.
+		modified := nvdTime(item.Cve.LastModified)
+		if modified.IsZero() {
 			modified = time.Now()
 		}
 
-		// Extract CVSS Base Score (V3.1 preferred)
-		cvssBase := extractCvssScore(item.Cve.Metrics)
-		if cvssBase == nil {
+		var cvssBase *float64
+		if c := SelectCvss(item.Cve.Metrics, item.Cve.SourceIdentifier, priority); c == nil {
 			metrics.NvdCvesWithoutCvss.Inc()
+		} else if chk.Range(id, "baseScore", c.Score, 0, 10) {
+			cvssBase = &c.Score
 		}
 
+		rows = append(rows, nvdRow{id: item.Cve.ID, json: cveJSON, cvssBase: cvssBase, modified: modified, hash: ContentHash(cveJSON)})
+	}
+	return rows
+}
+
+// nvdTime parses an NVD timestamp, UTC without a zone ("2024-01-22T10:15:00.000")
+// or RFC 3339; it returns the zero time for anything else.
+func nvdTime(s string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (r *NvdRunner) saveBatch(ctx context.Context, items []NvdCveItem, prov provenance.Record) (upsertStats, error) {
+	chk := r.quality.Checker("NVD")
+	rows := prepareNvdRows(items, r.cvssPriority, chk)
+	chk.Flush(ctx)
+	save := r.saveRowsBatch
+	if len(rows) >= nvdCopyThreshold {
+		save = r.saveRowsCopy
+	}
+	stats, err := save(ctx, rows, prov)
+	if err != nil {
+		return stats, err
+	}
+
+	if r.sink != nil {
+		scores := make([]CvssScore, len(rows))
+		for i, row := range rows {
+			scores[i] = CvssScore{CveID: row.id, Source: "NVD", CvssBase: row.cvssBase, Modified: row.modified}
+		}
+		if err := r.sink.InsertCvssScores(ctx, scores); err != nil {
+			sinkFailed(r.sink, "cve_scores", err)
+		}
+	}
+	return stats, nil
+}
+
+// saveRowsBatch upserts rows one statement each in a single pgx.Batch.
+func (r *NvdRunner) saveRowsBatch(ctx context.Context, rows []nvdRow, prov provenance.Record) (upsertStats, error) {
+	batch := &pgx.Batch{}
+	for _, row := range rows {
 		batch.Queue(`
-			INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified)
-			VALUES ($1, 'NVD', $2, $3, $4)
+			INSERT INTO cve_enriched (
+				cve_id, source, json, cvss_base, modified, content_hash,
+				fetch_url, fetched_at, http_status, upstream_version, tool_version
+			)
+			VALUES ($1, 'NVD', $2, $3, $4, $5, $6, $7, $8, $9, $10)
 			ON CONFLICT (cve_id, source)
 			DO UPDATE SET
 				json = EXCLUDED.json,
 				cvss_base = EXCLUDED.cvss_base,
-				modified = EXCLUDED.modified
-		`, item.Cve.ID, cveJSON, cvssBase, modified)
-		queued++
+				modified = EXCLUDED.modified,
+				content_hash = EXCLUDED.content_hash,
+				fetch_url = EXCLUDED.fetch_url,
+				fetched_at = EXCLUDED.fetched_at,
+				http_status = EXCLUDED.http_status,
+				upstream_version = EXCLUDED.upstream_version,
+				tool_version = EXCLUDED.tool_version
+			WHERE CASE WHEN $11 THEN cve_enriched.modified <= EXCLUDED.modified
+				ELSE cve_enriched.content_hash IS DISTINCT FROM EXCLUDED.content_hash END
+		`, row.id, row.json, row.cvssBase, row.modified, row.hash,
+			prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, r.rewrite)
 	}
 
 	br := r.db.SendBatch(ctx, batch)
 	defer func() { _ = br.Close() }()
 
-	for i := 0; i < queued; i++ {
-		_, err := br.Exec()
+	var stats upsertStats
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := br.Exec()
 		if err != nil {
-			return fmt.Errorf("batch execution failed at index %d: %w", i, err)
+			return stats, fmt.Errorf("batch execution failed at index %d: %w", i, err)
+		}
+		if tag.RowsAffected() == 0 {
+			stats.Skipped++
+		} else {
+			stats.Written++
 		}
 	}
 
-	return nil
+	return stats, nil
 }
 
-// extractCvssScore tries to extract CVSS V3.1 or V3.0 base score
-func extractCvssScore(metricsRaw json.RawMessage) *float64 {
-	if len(metricsRaw) == 0 {
-		return nil
+// saveRowsCopy streams rows into a transaction-scoped staging table with
+// COPY, then merges them into cve_enriched with one INSERT ... SELECT.
+// DISTINCT ON keeps the newest version if a page repeats a CVE, which
+// ON CONFLICT DO UPDATE would otherwise reject.
+func (r *NvdRunner) saveRowsCopy(ctx context.Context, rows []nvdRow, prov provenance.Record) (upsertStats, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return upsertStats{}, err
 	}
-
-	// Simple structure for parsing just what we need
-	type CvssData struct {
-		BaseScore float64 `json:"baseScore"`
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE nvd_stage (
+			cve_id    TEXT,
+			json      JSONB,
+			cvss_base NUMERIC,
+			modified  TIMESTAMPTZ,
+			hash      BYTEA
+		) ON COMMIT DROP
+	`); err != nil {
+		return upsertStats{}, fmt.Errorf("create NVD staging table: %w", err)
 	}
-	type CvssMetric struct {
-		CvssData CvssData `json:"cvssData"`
+
+	input := make([][]interface{}, len(rows))
+	ids := make(map[string]struct{}, len(rows))
+	for i, row := range rows {
+		input[i] = []interface{}{row.id, row.json, row.cvssBase, row.modified, row.hash}
+		ids[row.id] = struct{}{}
 	}
-	type Metrics struct {
-		CvssMetricV31 []CvssMetric `json:"cvssMetricV31"`
-		CvssMetricV30 []CvssMetric `json:"cvssMetricV30"`
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"nvd_stage"},
+		[]string{"cve_id", "json", "cvss_base", "modified", "hash"},
+		pgx.CopyFromRows(input),
+	); err != nil {
+		return upsertStats{}, fmt.Errorf("copy to NVD staging table failed: %w", err)
 	}
 
-	var m Metrics
-	if err := json.Unmarshal(metricsRaw, &m); err != nil {
-		return nil
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO cve_enriched (
+			cve_id, source, json, cvss_base, modified, content_hash,
+			fetch_url, fetched_at, http_status, upstream_version, tool_version
+		)
+		SELECT DISTINCT ON (cve_id)
+			cve_id, 'NVD', json, cvss_base, modified, hash,
+			$1::text, $2::timestamptz, $3::smallint, $4::text, $5::text
+		FROM nvd_stage
+		ORDER BY cve_id, modified DESC
+		ON CONFLICT (cve_id, source)
+		DO UPDATE SET
+			json = EXCLUDED.json,
+			cvss_base = EXCLUDED.cvss_base,
+			modified = EXCLUDED.modified,
+			content_hash = EXCLUDED.content_hash,
+			fetch_url = EXCLUDED.fetch_url,
+			fetched_at = EXCLUDED.fetched_at,
+			http_status = EXCLUDED.http_status,
+			upstream_version = EXCLUDED.upstream_version,
+			tool_version = EXCLUDED.tool_version
+		WHERE CASE WHEN $6::boolean THEN cve_enriched.modified <= EXCLUDED.modified
+			ELSE cve_enriched.content_hash IS DISTINCT FROM EXCLUDED.content_hash END
+	`, prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, r.rewrite)
+	if err != nil {
+		return upsertStats{}, fmt.Errorf("merge NVD staging table failed: %w", err)
 	}
 
-	if len(m.CvssMetricV31) > 0 {
-		return &m.CvssMetricV31[0].CvssData.BaseScore
+	if err := tx.Commit(ctx); err != nil {
+		return upsertStats{}, err
 	}
-	if len(m.CvssMetricV30) > 0 {
-		return &m.CvssMetricV30[0].CvssData.BaseScore
-	}
-	return nil
+	written := int(tag.RowsAffected())
+	return upsertStats{Written: written, Skipped: len(ids) - written}, nil
 }
 
-func (r *NvdRunner) getCursor(ctx context.Context) (string, error) {
-	var cursor string
-	err := r.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = 'NVD'").Scan(&cursor)
+// getCursor reads the cursor stored under source; ok is false when there
+// is none yet.
+func (r *NvdRunner) getCursor(ctx context.Context, source string) (cursor string, ok bool, err error) {
+	err = r.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", source).Scan(&cursor)
 	if err == pgx.ErrNoRows {
-		// Default start date: 2000-01-01
-		return "2000-01-01T00:00:00Z", nil
+		return "", false, nil
 	}
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	return cursor, nil
+	return cursor, true, nil
 }
 
-func (r *NvdRunner) setCursor(ctx context.Context, cursor string) error {
+// setCursor stores cursor under each of sources, together.
+func (r *NvdRunner) setCursor(ctx context.Context, cursor string, sources ...string) error {
 	_, err := r.db.Exec(ctx, `
-		INSERT INTO ingest_state (source, cursor) VALUES ('NVD', $1)
+		INSERT INTO ingest_state (source, cursor) SELECT unnest($2::text[]), $1
 		ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
-	`, cursor)
+	`, cursor, sources)
 	return err
 }
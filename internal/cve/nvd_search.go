@@ -0,0 +1,78 @@
+package cve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// nvdMaxRange is the longest date range NVD accepts in one query.
+const nvdMaxRange = 120 * 24 * time.Hour
+
+// NvdSearch selects NVD CVEs by product or keyword rather than by date
+// alone. Set at least one of CPEName, VirtualMatch and Keyword; NVD ANDs
+// them together. A modified window limits the results to CVEs changed in
+// it, which catches CVEs whose product configuration NVD added after
+// publication.
+type NvdSearch struct {
+	CPEName      string    // full CPE 2.3 name (cpeName)
+	VirtualMatch string    // CPE prefix; omitted trailing components match anything (virtualMatchString)
+	Keyword      string    // words in the description (keywordSearch)
+	ExactMatch   bool      // match Keyword as a phrase (keywordExactMatch)
+	ModifiedFrom time.Time // lastModStartDate; zero with ModifiedTo for no window
+	ModifiedTo   time.Time // lastModEndDate, at most 120 days after ModifiedFrom
+}
+
+// params validates s and returns its NVD query parameters.
+func (s NvdSearch) params() (url.Values, error) {
+	q := url.Values{}
+	if s.CPEName != "" {
+		if !strings.HasPrefix(s.CPEName, "cpe:2.3:") {
+			return nil, fmt.Errorf("invalid CPE name %q: want cpe:2.3:...", s.CPEName)
+		}
+		q.Set("cpeName", s.CPEName)
+	}
+	if s.VirtualMatch != "" {
+		if !strings.HasPrefix(s.VirtualMatch, "cpe:2.3:") {
+			return nil, fmt.Errorf("invalid CPE match string %q: want cpe:2.3:...", s.VirtualMatch)
+		}
+		q.Set("virtualMatchString", s.VirtualMatch)
+	}
+	if s.Keyword != "" {
+		q.Set("keywordSearch", s.Keyword)
+		if s.ExactMatch {
+			q.Set("keywordExactMatch", "") // a bare flag, see encodeNvdQuery
+		}
+	}
+	if len(q) == 0 {
+		return nil, errors.New("NVD search needs a CPE name, CPE match string or keyword")
+	}
+
+	switch {
+	case s.ModifiedFrom.IsZero() && s.ModifiedTo.IsZero():
+	case s.ModifiedFrom.IsZero() || s.ModifiedTo.IsZero():
+		return nil, errors.New("NVD search window needs both ends")
+	case !s.ModifiedFrom.Before(s.ModifiedTo):
+		return nil, errors.New("NVD search window is empty")
+	case s.ModifiedTo.Sub(s.ModifiedFrom) > nvdMaxRange:
+		return nil, fmt.Errorf("NVD search window longer than %s", nvdMaxRange)
+	default:
+		q.Set("lastModStartDate", s.ModifiedFrom.UTC().Format(time.RFC3339))
+		q.Set("lastModEndDate", s.ModifiedTo.UTC().Format(time.RFC3339))
+	}
+	return q, nil
+}
+
+// Search runs s against the NVD CVE API, calling fn with each page of
+// results. It pages and rate-limits like the scheduled run and stores
+// nothing itself.
+func (r *NvdRunner) Search(ctx context.Context, s NvdSearch, fn func(resp *NvdResponse, pageURL string) error) error {
+	params, err := s.params()
+	if err != nil {
+		return err
+	}
+	return r.pages(ctx, params, fn)
+}
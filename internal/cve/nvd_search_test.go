@@ -0,0 +1,96 @@
+package cve
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedNVD serves total CVEs in pages of size, recording each URL.
+type pagedNVD struct {
+	total, size int
+	urls        []string
+}
+
+func (f *pagedNVD) FetchPage(_ context.Context, rawURL string) (*NvdResponse, error) {
+	f.urls = append(f.urls, rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	start, _ := strconv.Atoi(u.Query().Get("startIndex"))
+	resp := &NvdResponse{StartIndex: start, TotalResults: f.total}
+	for i := start; i < min(start+f.size, f.total); i++ {
+		var item NvdCveItem
+		item.Cve.ID = "CVE-2024-" + strconv.Itoa(1000+i)
+		resp.Vulnerabilities = append(resp.Vulnerabilities, item)
+	}
+	return resp, nil
+}
+
+func TestNvdSearch_Params(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, err := NvdSearch{
+		VirtualMatch: "cpe:2.3:a:ivanti:connect_secure",
+		Keyword:      "Connect Secure",
+		ExactMatch:   true,
+		ModifiedFrom: from,
+		ModifiedTo:   from.Add(30 * 24 * time.Hour),
+	}.params()
+	require.NoError(t, err)
+	assert.Equal(t, "keywordExactMatch&keywordSearch=Connect+Secure&lastModEndDate=2024-01-31T00%3A00%3A00Z"+
+		"&lastModStartDate=2024-01-01T00%3A00%3A00Z&virtualMatchString=cpe%3A2.3%3Aa%3Aivanti%3Aconnect_secure", encodeNvdQuery(q))
+
+	for name, s := range map[string]NvdSearch{
+		"no criteria":   {ModifiedFrom: from, ModifiedTo: from.Add(time.Hour)},
+		"bad cpe":       {CPEName: "ivanti connect secure"},
+		"bad match":     {VirtualMatch: "cpe:/a:ivanti"},
+		"half window":   {Keyword: "ivanti", ModifiedFrom: from},
+		"empty window":  {Keyword: "ivanti", ModifiedFrom: from, ModifiedTo: from},
+		"window > 120d": {Keyword: "ivanti", ModifiedFrom: from, ModifiedTo: from.Add(121 * 24 * time.Hour)},
+	} {
+		_, err := s.params()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestNvdSearch_Pages(t *testing.T) {
+	fake := &pagedNVD{total: 5, size: 2}
+	r := NewNvdRunner(nil, config.NvdConfig{URL: "https://nvd.example/cves", PageSize: 2})
+	r.SetFetcher(fake)
+	r.pageDelay = 0
+
+	var ids []string
+	err := r.Search(context.Background(), NvdSearch{CPEName: "cpe:2.3:a:ivanti:connect_secure:22.7:r2.5:*:*:*:*:*:*"},
+		func(resp *NvdResponse, pageURL string) error {
+			for _, item := range resp.Vulnerabilities {
+				ids = append(ids, item.Cve.ID)
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Len(t, ids, 5)
+	require.Len(t, fake.urls, 3)
+	assert.Contains(t, fake.urls[0], "cpeName=cpe%3A2.3%3Aa%3Aivanti")
+	assert.Contains(t, fake.urls[2], "startIndex=4")
+
+	assert.Error(t, r.Search(context.Background(), NvdSearch{}, nil), "criteria are validated before fetching")
+	assert.Len(t, fake.urls, 3)
+}
+
+func TestProductSearch(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := productSearch(config.NvdProduct{Name: "ICS", CPEMatch: "cpe:2.3:a:ivanti:connect_secure", Keyword: "gateway"}, from, from.Add(time.Hour))
+	assert.Equal(t, "cpe:2.3:a:ivanti:connect_secure", s.VirtualMatch)
+	assert.Equal(t, "gateway", s.Keyword)
+	assert.Equal(t, from, s.ModifiedFrom)
+	assert.Equal(t, "NVD-PRODUCT:ICS", productStateKey("ICS"))
+}
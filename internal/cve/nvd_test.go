@@ -2,15 +2,21 @@ package cve
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"tiger2go/internal/config"
 	"tiger2go/internal/db"
+	"tiger2go/internal/provenance"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -61,7 +67,7 @@ func TestNvdRunner_Integration(t *testing.T) {
 	// So Start=Now-60d, End=Now.
 	start := time.Now().Add(-60 * time.Hour * 24).Format(time.RFC3339)
 
-	_, err = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = 'NVD'")
+	_, err = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source IN ('NVD', 'NVD-MODIFIED')")
 	require.NoError(t, err)
 	_, err = pool.Exec(ctx, "INSERT INTO ingest_state (source, cursor) VALUES ('NVD', $1)", start)
 	require.NoError(t, err)
@@ -87,3 +93,260 @@ func TestNvdRunner_Integration(t *testing.T) {
 	// Clean up
 	_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = 'CVE-TEST-NVD-001'")
 }
+
+// TestNvdRunner_BackfillThenModified requires a running DB. A first run
+// backfills by publication date up to its start, then syncs by
+// modification date; later runs only sync.
+func TestNvdRunner_BackfillThenModified(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var mu sync.Mutex
+	var queries []url.Values
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"resultsPerPage": 0, "startIndex": 0, "totalResults": 0, "vulnerabilities": []}`))
+	}))
+	defer mockServer.Close()
+
+	_, err = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source IN ('NVD', 'NVD-MODIFIED')")
+	require.NoError(t, err)
+	defer func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source IN ('NVD', 'NVD-MODIFIED')")
+	}()
+
+	runner := NewNvdRunner(pool, config.NvdConfig{Enabled: true, URL: mockServer.URL})
+	runner.pageDelay = 0
+	require.NoError(t, runner.Run(ctx))
+
+	require.NotEmpty(t, queries)
+	assert.Equal(t, "2000-01-01T00:00:00Z", queries[0].Get("pubStartDate"))
+	var pub, mod int
+	for _, q := range queries {
+		if q.Has("pubStartDate") {
+			assert.Zero(t, mod, "the backfill comes first")
+			pub++
+		}
+		if q.Has("lastModStartDate") {
+			mod++
+		}
+	}
+	assert.Greater(t, pub, 70, "120-day windows since 2000")
+	assert.Equal(t, 1, mod, "the modified sync covers the minutes the backfill took")
+
+	cursor := func(source string) string {
+		var c string
+		require.NoError(t, pool.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", source).Scan(&c))
+		return c
+	}
+	assert.Equal(t, cursor("NVD-MODIFIED"), cursor("NVD"), "the backfill cursor follows the modified sync")
+
+	queries = nil
+	require.NoError(t, runner.Run(ctx))
+	require.Len(t, queries, 1)
+	assert.True(t, queries[0].Has("lastModStartDate"), "no second backfill")
+}
+
+func TestNvdRunner_WindowStart(t *testing.T) {
+	cursor := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := NewNvdRunner(nil, config.NvdConfig{})
+	assert.Equal(t, cursor.Add(-15*time.Minute), r.windowStart(cursor), "the default overlap")
+
+	r = NewNvdRunner(nil, config.NvdConfig{CursorOverlap: "1h"})
+	assert.Equal(t, cursor.Add(-time.Hour), r.windowStart(cursor))
+
+	r = NewNvdRunner(nil, config.NvdConfig{CursorOverlap: "0s"})
+	assert.Equal(t, cursor, r.windowStart(cursor))
+}
+
+// benchNvdItems builds n synthetic NVD items with realistic metrics payloads.
+func benchNvdItems(n int) []NvdCveItem {
+	items := make([]NvdCveItem, n)
+	for i := range items {
+		items[i].Cve.ID = fmt.Sprintf("CVE-BENCH-%06d", i)
+		items[i].Cve.LastModified = "2024-01-12T17:15:10.000"
+		items[i].Cve.Metrics = json.RawMessage(`{"cvssMetricV31":[{"source":"nvd@nist.gov","type":"Primary","cvssData":{"version":"3.1","vectorString":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H","baseScore":9.8,"baseSeverity":"CRITICAL"}}]}`)
+	}
+	return items
+}
+
+func nvdBenchPool(tb testing.TB) *pgxpool.Pool {
+	tb.Helper()
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		tb.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	require.NoError(tb, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(context.Background(), databaseURL)
+	require.NoError(tb, err)
+	clearBenchRows(tb, pool)
+	tb.Cleanup(func() {
+		clearBenchRows(tb, pool)
+		pool.Close()
+	})
+	return pool
+}
+
+// clearBenchRows removes benchmark rows so every iteration measures inserts
+// rather than skipped unchanged rows.
+func clearBenchRows(tb testing.TB, pool *pgxpool.Pool) {
+	tb.Helper()
+	if _, err := pool.Exec(context.Background(), "DELETE FROM cve_enriched WHERE cve_id LIKE 'CVE-BENCH-%'"); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestNvdSaveRowsCopy_MatchesBatch(t *testing.T) {
+	pool := nvdBenchPool(t)
+	ctx := context.Background()
+	runner := &NvdRunner{db: pool}
+	prov := provenance.New("https://nvd.example/page", http.StatusOK, "2.0")
+
+	rows := prepareNvdRows(benchNvdItems(3), nil, runner.quality.Checker("NVD"))
+	// A repeated CVE in one page must not break the merge; the newest wins.
+	dup := rows[0]
+	dup.modified = dup.modified.Add(time.Hour)
+	rows = append(rows, dup)
+
+	stats, err := runner.saveRowsCopy(ctx, rows, prov)
+	require.NoError(t, err)
+	assert.Equal(t, upsertStats{Written: 3}, stats)
+
+	stats, err = runner.saveRowsCopy(ctx, rows, prov)
+	require.NoError(t, err, "re-running the merge is idempotent")
+	assert.Equal(t, upsertStats{Skipped: 3}, stats, "unchanged rows are not rewritten")
+
+	var count int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM cve_enriched WHERE cve_id LIKE 'CVE-BENCH-%' AND source = 'NVD'").Scan(&count))
+	assert.Equal(t, 3, count)
+
+	var modified time.Time
+	var status int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT modified, http_status FROM cve_enriched WHERE cve_id = $1 AND source = 'NVD'", dup.id).Scan(&modified, &status))
+	assert.True(t, modified.Equal(dup.modified))
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestNvdSaveRowsBatch_SkipsUnchanged(t *testing.T) {
+	pool := nvdBenchPool(t)
+	ctx := context.Background()
+	runner := &NvdRunner{db: pool}
+	prov := provenance.New("https://nvd.example/page", http.StatusOK, "2.0")
+
+	items := benchNvdItems(3)
+	stats, err := runner.saveRowsBatch(ctx, prepareNvdRows(items, nil, runner.quality.Checker("NVD")), prov)
+	require.NoError(t, err)
+	assert.Equal(t, upsertStats{Written: 3}, stats)
+
+	items[1].Cve.LastModified = "2024-02-01T00:00:00.000"
+	stats, err = runner.saveRowsBatch(ctx, prepareNvdRows(items, nil, runner.quality.Checker("NVD")), prov)
+	require.NoError(t, err)
+	assert.Equal(t, upsertStats{Written: 1, Skipped: 2}, stats)
+}
+
+func TestNvdSave_Rewrite(t *testing.T) {
+	pool := nvdBenchPool(t)
+	ctx := context.Background()
+	runner := &NvdRunner{db: pool}
+	prov := provenance.New("https://nvd.example/page", http.StatusOK, "2.0")
+
+	items := benchNvdItems(2)
+	items[1].Cve.LastModified = "2024-02-01T00:00:00.000"
+	rows := prepareNvdRows(items, nil, runner.quality.Checker("NVD"))
+	_, err := runner.saveRowsBatch(ctx, rows, prov)
+	require.NoError(t, err)
+
+	// A reprocess rewrites unchanged rows, but not with an older version
+	runner.SetRewrite(true)
+	items[1].Cve.LastModified = "2024-01-12T17:15:10.000"
+	rows = prepareNvdRows(items, nil, runner.quality.Checker("NVD"))
+	for _, save := range []func(context.Context, []nvdRow, provenance.Record) (upsertStats, error){runner.saveRowsBatch, runner.saveRowsCopy} {
+		stats, err := save(ctx, rows, prov)
+		require.NoError(t, err)
+		assert.Equal(t, upsertStats{Written: 1, Skipped: 1}, stats)
+	}
+}
+
+// BenchmarkNvdSave compares the two write paths on a backfill-sized page:
+//
+//	DATABASE_URL=... go test ./internal/cve -run '^$' -bench NvdSave -benchtime 5x
+func BenchmarkNvdSave(b *testing.B) {
+	pool := nvdBenchPool(b)
+	ctx := context.Background()
+	runner := &NvdRunner{db: pool}
+	prov := provenance.New("https://nvd.example/page", http.StatusOK, "2.0")
+	rows := prepareNvdRows(benchNvdItems(2000), nil, runner.quality.Checker("NVD"))
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			clearBenchRows(b, pool)
+			b.StartTimer()
+			if _, err := runner.saveRowsBatch(ctx, rows, prov); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(len(rows)*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+	b.Run("copy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			clearBenchRows(b, pool)
+			b.StartTimer()
+			if _, err := runner.saveRowsCopy(ctx, rows, prov); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(len(rows)*b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+}
+
+// BenchmarkNvdDecode measures decoding a full NVD page (2,000 CVEs, the
+// API's page size) and preparing its rows, the CPU side of each page of a
+// backfill:
+//
+//	go test ./internal/cve -run '^$' -bench NvdDecode
+func BenchmarkNvdDecode(b *testing.B) {
+	items := benchNvdItems(2000)
+	for i := range items {
+		items[i].Cve.Published = "2024-01-10T08:15:42.000"
+		items[i].Cve.VulnStatus = VulnStatusAnalyzed
+		items[i].Cve.References = []NvdReference{
+			{URL: "https://vendor.example/advisory/" + items[i].Cve.ID, Tags: []string{RefTagVendorAdvisory, RefTagPatch}},
+			{URL: "https://github.com/example/poc", Tags: []string{RefTagExploit}},
+		}
+		items[i].Cve.Configurations = json.RawMessage(`[{"nodes":[{"operator":"OR","cpeMatch":[{"vulnerable":true,"criteria":"cpe:2.3:a:example:product:*:*:*:*:*:*:*:*","versionEndExcluding":"2.4.1"}]}]}]`)
+	}
+	body, err := json.Marshal(NvdResponse{ResultsPerPage: len(items), TotalResults: len(items), Vulnerabilities: items})
+	require.NoError(b, err)
+
+	b.Run("decode", func(b *testing.B) {
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			if _, err := parseNvdPage(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decode_and_prepare", func(b *testing.B) {
+		b.SetBytes(int64(len(body)))
+		var runner NvdRunner
+		for b.Loop() {
+			resp, err := parseNvdPage(body)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = prepareNvdRows(resp.Vulnerabilities, nil, runner.quality.Checker("NVD"))
+		}
+	})
+}
@@ -16,54 +16,103 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// extractCvssScore
+// SelectCvss
 // ---------------------------------------------------------------------------
 
-func TestExtractCvssScore_V31(t *testing.T) {
+func TestSelectCvss_V31(t *testing.T) {
 	raw := json.RawMessage(`{
 		"cvssMetricV31": [{"cvssData": {"baseScore": 9.8}}]
 	}`)
-	score := extractCvssScore(raw)
-	require.NotNil(t, score)
-	assert.Equal(t, 9.8, *score)
+	c := SelectCvss(raw, "", nil)
+	require.NotNil(t, c)
+	assert.Equal(t, 9.8, c.Score)
 }
 
-func TestExtractCvssScore_V30Fallback(t *testing.T) {
+func TestSelectCvss_V30Fallback(t *testing.T) {
 	raw := json.RawMessage(`{
 		"cvssMetricV30": [{"cvssData": {"baseScore": 7.5}}]
 	}`)
-	score := extractCvssScore(raw)
-	require.NotNil(t, score)
-	assert.Equal(t, 7.5, *score)
+	c := SelectCvss(raw, "", nil)
+	require.NotNil(t, c)
+	assert.Equal(t, 7.5, c.Score)
+	assert.Equal(t, "3.0", c.Version)
 }
 
-func TestExtractCvssScore_V31PreferredOverV30(t *testing.T) {
+func TestSelectCvss_V31PreferredOverV30(t *testing.T) {
 	raw := json.RawMessage(`{
 		"cvssMetricV31": [{"cvssData": {"baseScore": 9.0}}],
 		"cvssMetricV30": [{"cvssData": {"baseScore": 7.0}}]
 	}`)
-	score := extractCvssScore(raw)
-	require.NotNil(t, score)
-	assert.Equal(t, 9.0, *score)
+	c := SelectCvss(raw, "", nil)
+	require.NotNil(t, c)
+	assert.Equal(t, 9.0, c.Score)
 }
 
-func TestExtractCvssScore_Empty(t *testing.T) {
-	assert.Nil(t, extractCvssScore(nil))
-	assert.Nil(t, extractCvssScore(json.RawMessage("")))
-	assert.Nil(t, extractCvssScore(json.RawMessage("{}")))
+func TestSelectCvss_Empty(t *testing.T) {
+	assert.Nil(t, SelectCvss(nil, "", nil))
+	assert.Nil(t, SelectCvss(json.RawMessage(""), "", nil))
+	assert.Nil(t, SelectCvss(json.RawMessage("{}"), "", nil))
 }
 
-func TestExtractCvssScore_InvalidJSON(t *testing.T) {
+func TestSelectCvss_InvalidJSON(t *testing.T) {
 	raw := json.RawMessage(`not json`)
-	assert.Nil(t, extractCvssScore(raw))
+	assert.Nil(t, SelectCvss(raw, "", nil))
 }
 
-func TestExtractCvssScore_EmptyArrays(t *testing.T) {
+func TestSelectCvss_EmptyArrays(t *testing.T) {
 	raw := json.RawMessage(`{
 		"cvssMetricV31": [],
 		"cvssMetricV30": []
 	}`)
-	assert.Nil(t, extractCvssScore(raw))
+	assert.Nil(t, SelectCvss(raw, "", nil))
+}
+
+func TestSelectCvss_Priority(t *testing.T) {
+	// The CNA scored 9.8, CISA's ADP 8.1 and NVD 7.5 (as v3.0 only).
+	raw := json.RawMessage(`{
+		"cvssMetricV31": [
+			{"source": "psirt@vendor.example", "type": "Secondary", "cvssData": {"baseScore": 9.8, "baseSeverity": "CRITICAL"}},
+			{"source": "134c704f-9b21-4f2e-91b3-4a467353bcc0", "type": "Secondary", "cvssData": {"baseScore": 8.1, "baseSeverity": "HIGH"}}
+		],
+		"cvssMetricV30": [
+			{"source": "nvd@nist.gov", "type": "Primary", "cvssData": {"baseScore": 7.5, "baseSeverity": "HIGH"}}
+		]
+	}`)
+	const cna = "psirt@vendor.example"
+	tests := []struct {
+		priority []string
+		want     *Cvss
+	}{
+		{nil, &Cvss{Score: 9.8, Severity: "CRITICAL", Version: "3.1", Source: CvssSourceCNA}},
+		{[]string{CvssSourceNVD, CvssSourceCNA}, &Cvss{Score: 7.5, Severity: "HIGH", Version: "3.0", Source: CvssSourceNVD}},
+		{[]string{CvssSourceCNA, CvssSourceNVD}, &Cvss{Score: 9.8, Severity: "CRITICAL", Version: "3.1", Source: CvssSourceCNA}},
+		{[]string{CvssSourceADP}, &Cvss{Score: 8.1, Severity: "HIGH", Version: "3.1", Source: CvssSourceADP}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, SelectCvss(raw, cna, tt.priority), "%v", tt.priority)
+	}
+	assert.Nil(t, SelectCvss(raw, "", []string{CvssSourceCNA, CvssSourceADP}),
+		"without the record's sourceIdentifier no secondary score is the CNA's or an ADP's")
+	assert.Equal(t, CvssSourceOther, SelectCvss(raw, "", []string{CvssSourceOther}).Source)
+	assert.Equal(t, CvssSourceOther, SelectCvss(raw, "", nil).Source, "NVD's order still labels the winner")
+	assert.Equal(t, CvssSourceOther,
+		SelectCvss(json.RawMessage(`{"cvssMetricV31":[{"source":"scorer@other.example","type":"Primary","cvssData":{"baseScore":5}}]}`), cna, nil).Source,
+		"another primary scorer is not NVD")
+	assert.Nil(t, SelectCvss(json.RawMessage(`{"cvssMetricV31":[{"source":"nvd@nist.gov","type":"Primary","cvssData":{"baseScore":5}}]}`), cna, []string{CvssSourceCNA}),
+		"unlisted sources are never used")
+}
+
+func TestParseCvssPriority(t *testing.T) {
+	got, err := ParseCvssPriority(nil)
+	require.NoError(t, err)
+	assert.Nil(t, got, "NVD's order")
+	got, err = ParseCvssPriority([]string{"cna", "nvd", "other"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cna", "nvd", "other"}, got)
+	_, err = ParseCvssPriority([]string{"osv"})
+	assert.Error(t, err)
+	_, err = ParseCvssPriority([]string{"nvd", "nvd"})
+	assert.Error(t, err)
 }
 
 // ---------------------------------------------------------------------------
@@ -166,3 +215,29 @@ func TestFetchWithRetry_UnexpectedStatusCode(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unexpected status code: 403")
 }
+
+// ---------------------------------------------------------------------------
+// NvdCve decoding
+// ---------------------------------------------------------------------------
+
+func TestNvdCve_KeepsFullRecordAndReferenceTags(t *testing.T) {
+	in := `{"id":"CVE-2024-3400","sourceIdentifier":"psirt@paloaltonetworks.com","vulnStatus":"Analyzed",` +
+		`"lastModified":"2024-04-19T14:15:10.000","descriptions":[{"lang":"en","value":"A command injection"}],` +
+		`"metrics":{},"references":[` +
+		`{"url":"https://security.paloaltonetworks.com/CVE-2024-3400","source":"psirt@paloaltonetworks.com","tags":["Vendor Advisory","Patch"]},` +
+		`{"url":"https://github.com/example/poc","source":"nvd@nist.gov","tags":["Exploit"]}]}`
+
+	var c NvdCve
+	require.NoError(t, json.Unmarshal([]byte(in), &c))
+	assert.Equal(t, "psirt@paloaltonetworks.com", c.SourceIdentifier)
+	assert.Equal(t, VulnStatusAnalyzed, c.VulnStatus)
+	require.Len(t, c.References, 2)
+	assert.True(t, c.References[0].HasTag(RefTagPatch))
+	assert.False(t, c.References[0].HasTag(RefTagExploit))
+	assert.Equal(t, "https://github.com/example/poc", TaggedURL(c.References, RefTagExploit))
+	assert.Empty(t, TaggedURL(c.References, "Mitigation"))
+
+	out, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.JSONEq(t, in, string(out), "stored verbatim, descriptions included")
+}
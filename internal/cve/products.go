@@ -0,0 +1,137 @@
+package cve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProductMonitor pulls every NVD CVE matching the configured products
+// ([[nvd.products]]), whether or not a feed mentioned it. Each run asks NVD
+// for CVEs modified since the product's cursor, stores them in cve_enriched
+// like the NVD runner and records the match in product_cves.
+type ProductMonitor struct {
+	db       *pgxpool.Pool
+	nvd      *NvdRunner
+	products []config.NvdProduct
+	lookback time.Duration // first run's window
+}
+
+// NewProductMonitor returns a monitor that fetches and stores through nvd.
+func NewProductMonitor(db *pgxpool.Pool, nvd *NvdRunner, products []config.NvdProduct, lookback time.Duration) *ProductMonitor {
+	return &ProductMonitor{db: db, nvd: nvd, products: products, lookback: lookback}
+}
+
+// productSearch converts p into an NVD search over [from, to).
+func productSearch(p config.NvdProduct, from, to time.Time) NvdSearch {
+	return NvdSearch{
+		CPEName:      p.CPE,
+		VirtualMatch: p.CPEMatch,
+		Keyword:      p.Keyword,
+		ExactMatch:   p.ExactMatch,
+		ModifiedFrom: from,
+		ModifiedTo:   to,
+	}
+}
+
+// productStateKey is the ingest_state source holding a product's cursor.
+func productStateKey(name string) string { return "NVD-PRODUCT:" + name }
+
+// Run polls every product. A failing product is logged and does not stop
+// the others; the errors are returned together.
+func (m *ProductMonitor) Run(ctx context.Context) error {
+	var errs []error
+	for _, p := range m.products {
+		if err := m.poll(ctx, p); err != nil {
+			metrics.ProductMonitorRuns.WithLabelValues(p.Name, "error").Inc()
+			slog.Error("Product monitor failed", "product", p.Name, "error", err)
+			errs = append(errs, fmt.Errorf("product %q: %w", p.Name, err))
+			continue
+		}
+		metrics.ProductMonitorRuns.WithLabelValues(p.Name, "success").Inc()
+	}
+	return errors.Join(errs...)
+}
+
+func (m *ProductMonitor) poll(ctx context.Context, p config.NvdProduct) error {
+	if p.Name == "" {
+		return errors.New("product needs a name")
+	}
+	now := time.Now().UTC()
+	from := now.Add(-m.lookback)
+	var cursor string
+	err := m.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", productStateKey(p.Name)).Scan(&cursor)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("read cursor: %w", err)
+	default:
+		if from, err = time.Parse(time.RFC3339, cursor); err != nil {
+			return fmt.Errorf("invalid cursor %q: %w", cursor, err)
+		}
+	}
+
+	matched := 0
+	for from.Before(now) {
+		to := from.Add(nvdMaxRange)
+		if to.After(now) {
+			to = now
+		}
+		err := m.nvd.Search(ctx, productSearch(p, from, to), func(resp *NvdResponse, pageURL string) error {
+			prov := provenance.New(pageURL, fetchedStatus(m.nvd.fetcher != nil), resp.Version)
+			stats, err := m.nvd.saveBatch(ctx, resp.Vulnerabilities, prov)
+			if err != nil {
+				return fmt.Errorf("save batch: %w", err)
+			}
+			stats.record("NVD")
+			n, err := m.recordMatches(ctx, p.Name, resp.Vulnerabilities)
+			if err != nil {
+				return err
+			}
+			matched += n
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := m.db.Exec(ctx, `
+			INSERT INTO ingest_state (source, cursor) VALUES ($1, $2)
+			ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+		`, productStateKey(p.Name), to.Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("update cursor: %w", err)
+		}
+		from = to
+	}
+
+	slog.Info("Product monitor complete", "product", p.Name, "new_matches", matched)
+	return nil
+}
+
+// recordMatches adds the CVEs in items to product_cves and returns how many
+// were new for the product.
+func (m *ProductMonitor) recordMatches(ctx context.Context, product string, items []NvdCveItem) (int, error) {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Cve.ID)
+	}
+	tag, err := m.db.Exec(ctx, `
+		INSERT INTO product_cves (product, cve_id)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (product, cve_id) DO NOTHING
+	`, product, ids)
+	if err != nil {
+		return 0, fmt.Errorf("record product matches: %w", err)
+	}
+	n := int(tag.RowsAffected())
+	metrics.ProductMonitorMatches.WithLabelValues(product).Add(float64(n))
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package cve
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductMonitor_Integration(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	const product = "test-product-monitor"
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM product_cves WHERE product = $1", product)
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = $1", productStateKey(product))
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id LIKE 'CVE-2024-100_'")
+	}
+	cleanup()
+	defer cleanup()
+
+	fake := &pagedNVD{total: 3, size: 2}
+	runner := NewNvdRunner(pool, config.NvdConfig{PageSize: 2})
+	runner.SetFetcher(fake)
+	runner.pageDelay = 0
+	monitor := NewProductMonitor(pool, runner, []config.NvdProduct{
+		{Name: product, CPEMatch: "cpe:2.3:a:example:widget"},
+	}, 200*24*time.Hour)
+
+	require.NoError(t, monitor.Run(ctx))
+	var matches int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM product_cves WHERE product = $1", product).Scan(&matches))
+	assert.Equal(t, 3, matches)
+	assert.Len(t, fake.urls, 4, "200 days is two NVD windows of two pages each")
+	assert.Contains(t, fake.urls[0], "virtualMatchString=")
+
+	var cursor string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", productStateKey(product)).Scan(&cursor))
+	last, err := time.Parse(time.RFC3339, cursor)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), last, time.Minute)
+
+	require.NoError(t, monitor.Run(ctx), "second run resumes from the cursor")
+	require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM product_cves WHERE product = $1", product).Scan(&matches))
+	assert.Equal(t, 3, matches, "matches are recorded once")
+}
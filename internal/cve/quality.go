@@ -0,0 +1,51 @@
+package cve
+
+import "tiger2go/internal/quality"
+
+// SetQuality records the data quality violations of each NVD page in q.
+func (r *NvdRunner) SetQuality(q *quality.Recorder) { r.quality = q }
+
+// SetQuality records the data quality violations of each EPSS page in q.
+func (r *EpssRunner) SetQuality(q *quality.Recorder) { r.quality = q }
+
+// SetQuality records the data quality violations of each KEV catalog in q.
+func (r *KevRunner) SetQuality(q *quality.Recorder) { r.quality = q }
+
+// checkEpssRows returns the rows with a CVE ID and with a score and
+// percentile within 0–1. The others are left out: a malformed number would
+// fail the whole COPY, and an out-of-range one would skew every ranking.
+func checkEpssRows(rows []EpssRow, chk *quality.Checker) []EpssRow {
+	out := make([]EpssRow, 0, len(rows))
+	for _, row := range rows {
+		if chk.Required(row.CVE, "cve", row.CVE) &&
+			chk.Number(row.CVE, "epss", row.EPSS, 0, 1) &&
+			chk.Number(row.CVE, "percentile", row.Percentile, 0, 1) {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// checkKevVulns returns the catalog entries with a CVE ID; chk also
+// records entries missing their vendor, product or dateAdded, and dates
+// in the future.
+func checkKevVulns(catalog *KevCatalog, chk *quality.Checker) []KevVuln {
+	if t, err := parseKevTime(catalog.DateReleased); err == nil && t != nil {
+		chk.Date("", "dateReleased", *t)
+	}
+	out := make([]KevVuln, 0, len(catalog.Vulnerabilities))
+	for _, v := range catalog.Vulnerabilities {
+		if !chk.Required(v.CveID, "cveID", v.CveID) {
+			continue
+		}
+		chk.Required(v.CveID, "vendorProject", v.VendorProject)
+		chk.Required(v.CveID, "product", v.Product)
+		if chk.Required(v.CveID, "dateAdded", v.DateAdded) {
+			if t, err := v.ParsedDateAdded(); err == nil && t != nil {
+				chk.Date(v.CveID, "dateAdded", *t)
+			}
+		}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package cve
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"tiger2go/internal/quality"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(vs []quality.Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.Record+" "+v.Field+" "+v.Rule)
+	}
+	return out
+}
+
+func TestPrepareNvdRows_Quality(t *testing.T) {
+	future := time.Now().Add(30 * 24 * time.Hour).UTC().Format("2006-01-02T15:04:05.000")
+	items := []NvdCveItem{
+		{Cve: NvdCve{ID: "CVE-2026-0001", Published: "2026-01-02T10:00:00.000", LastModified: "2026-01-03T10:00:00.000",
+			Metrics: json.RawMessage(`{"cvssMetricV31": [{"cvssData": {"baseScore": 9.8}}]}`)}},
+		{Cve: NvdCve{ID: "CVE-2026-0002", Published: future, LastModified: future,
+			Metrics: json.RawMessage(`{"cvssMetricV31": [{"cvssData": {"baseScore": 98}}]}`)}},
+		{Cve: NvdCve{Published: "2026-01-02T10:00:00.000"}},
+	}
+	chk := (*quality.Recorder)(nil).Checker("NVD")
+
+	rows := prepareNvdRows(items, nil, chk)
+	require.Len(t, rows, 2, "a CVE without an ID is skipped")
+	require.NotNil(t, rows[0].cvssBase)
+	assert.Equal(t, 9.8, *rows[0].cvssBase)
+	assert.Nil(t, rows[1].cvssBase, "an out-of-range score is stored as NULL")
+	assert.Equal(t, time.Date(2026, 1, 3, 10, 0, 0, 0, time.UTC), rows[0].modified, "lastModified as NVD sends it, without a zone")
+	assert.Equal(t, []string{
+		"CVE-2026-0002 published future_date",
+		"CVE-2026-0002 lastModified future_date",
+		"CVE-2026-0002 baseScore range",
+		" id required",
+	}, rules(chk.Violations()))
+}
+
+func TestCheckEpssRows(t *testing.T) {
+	chk := (*quality.Recorder)(nil).Checker("EPSS")
+	rows := checkEpssRows([]EpssRow{
+		{CVE: "CVE-2026-0001", EPSS: "0.97", Percentile: "0.999"},
+		{CVE: "CVE-2026-0002", EPSS: "1.7", Percentile: "0.5"},
+		{CVE: "CVE-2026-0003", EPSS: "0.1", Percentile: "n/a"},
+		{EPSS: "0.1", Percentile: "0.5"},
+	}, chk)
+
+	require.Len(t, rows, 1)
+	assert.Equal(t, "CVE-2026-0001", rows[0].CVE)
+	assert.Equal(t, []string{
+		"CVE-2026-0002 epss range",
+		"CVE-2026-0003 percentile number",
+		" cve required",
+	}, rules(chk.Violations()))
+}
+
+func TestCheckKevVulns(t *testing.T) {
+	chk := (*quality.Recorder)(nil).Checker("CISA-KEV")
+	future := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
+	catalog := &KevCatalog{DateReleased: "2026-01-02T15:00:00.000Z", Vulnerabilities: []KevVuln{
+		{CveID: "CVE-2026-0001", VendorProject: "Ivanti", Product: "Connect Secure", DateAdded: "2026-01-02"},
+		{CveID: "CVE-2026-0002", VendorProject: "Ivanti", DateAdded: future},
+		{VendorProject: "Ivanti", Product: "Connect Secure", DateAdded: "2026-01-02"},
+	}}
+
+	vulns := checkKevVulns(catalog, chk)
+	require.Len(t, vulns, 2, "entries without a CVE ID are skipped, others kept")
+	assert.Equal(t, []string{
+		"CVE-2026-0002 product required",
+		"CVE-2026-0002 dateAdded future_date",
+		" cveID required",
+	}, rules(chk.Violations()))
+}
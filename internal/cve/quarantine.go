@@ -0,0 +1,12 @@
+package cve
+
+import "tiger2go/internal/quarantine"
+
+// SetQuarantine keeps NVD pages that fail to parse in q.
+func (r *NvdRunner) SetQuarantine(q *quarantine.Store) { r.quarantine = q }
+
+// SetQuarantine keeps EPSS pages that fail to parse in q.
+func (r *EpssRunner) SetQuarantine(q *quarantine.Store) { r.quarantine = q }
+
+// SetQuarantine keeps KEV catalogs that fail to parse in q.
+func (r *KevRunner) SetQuarantine(q *quarantine.Store) { r.quarantine = q }
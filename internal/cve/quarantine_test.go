@@ -0,0 +1,44 @@
+package cve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetch_UnparsablePayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"vulnerabilities": [`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	// Without a quarantine Store the parse error is returned as before
+	_, err := (&KevRunner{client: server.Client()}).fetchCatalog(ctx, server.URL)
+	assert.Error(t, err)
+	_, err = (&EpssRunner{client: server.Client()}).fetch(ctx, server.URL)
+	assert.Error(t, err)
+	_, err = (&NvdRunner{client: server.Client()}).fetchPage(ctx, server.URL)
+	assert.ErrorContains(t, err, "failed to parse NVD response")
+}
+
+func TestParsePayloads(t *testing.T) {
+	catalog, err := parseKevCatalog([]byte(`{"catalogVersion":"2026.10.01","dateReleased":"2026-10-01T12:00:00.000Z","vulnerabilities":[{"cveID":"CVE-2026-0001"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-01T12:00:00Z", kevCursor(catalog))
+	assert.Equal(t, "2026.10.02", kevCursor(&KevCatalog{CatalogVersion: "2026.10.02"}))
+
+	page, err := parseEpssPage([]byte(`{"total":1,"data":[{"cve":"CVE-2026-0001","epss":"0.1","percentile":"0.5","date":"2026-10-01"}]}`))
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1)
+
+	resp, err := parseNvdPage([]byte(`{"totalResults":0,"vulnerabilities":[]}`))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Vulnerabilities)
+	_, err = parseNvdPage([]byte(`<html>`))
+	assert.Error(t, err)
+}
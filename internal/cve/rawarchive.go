@@ -0,0 +1,24 @@
+package cve
+
+import "tiger2go/internal/rawarchive"
+
+// SetArchive keeps a copy of every NVD page fetched over HTTP in a.
+func (r *NvdRunner) SetArchive(a *rawarchive.Archive) { r.archive = a }
+
+// SetArchive keeps a copy of every EPSS page fetched over HTTP in a.
+func (r *EpssRunner) SetArchive(a *rawarchive.Archive) { r.archive = a }
+
+// SetArchive keeps a copy of every KEV catalog fetched over HTTP in a.
+func (r *KevRunner) SetArchive(a *rawarchive.Archive) { r.archive = a }
+
+// SetRewrite makes Replay rewrite stored NVD rows even when their content
+// is unchanged, unless the stored version is newer, so a reprocess fills
+// columns derived by a newer release.
+func (r *NvdRunner) SetRewrite(rewrite bool) { r.rewrite = rewrite }
+
+// SetRewrite makes Replay replace stored EPSS scores rather than keep them.
+func (r *EpssRunner) SetRewrite(rewrite bool) { r.rewrite = rewrite }
+
+// SetRewrite makes Replay write a catalog that is not newer than the stored
+// one over the stored entries instead of refusing it.
+func (r *KevRunner) SetRewrite(rewrite bool) { r.rewrite = rewrite }
@@ -0,0 +1,52 @@
+package cve
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/httpreplay"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Cassette-backed tests run the real HTTP code paths against recorded
+// upstream responses. Re-record a cassette by running tigerfetch against the
+// live API with TIGERFETCH_HTTP_RECORD=<path>, then trimming the output.
+
+func TestKevFetchCatalog_Replay(t *testing.T) {
+	rep, err := httpreplay.NewReplayer("testdata/kev_catalog.cassette.json")
+	require.NoError(t, err)
+
+	runner := &KevRunner{client: rep.Client()}
+	catalog, err := runner.fetchCatalog(context.Background(),
+		"https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json")
+	require.NoError(t, err)
+	assert.Equal(t, "2024.01.15", catalog.CatalogVersion)
+	require.Len(t, catalog.Vulnerabilities, 2)
+	assert.Equal(t, "CVE-2024-21887", catalog.Vulnerabilities[1].CveID)
+}
+
+func TestNvdFetchWithRetry_Replay(t *testing.T) {
+	rep, err := httpreplay.NewReplayer("testdata/nvd_page.cassette.json")
+	require.NoError(t, err)
+
+	runner := &NvdRunner{
+		cfg:    config.NvdConfig{ApiKey: "test-key"},
+		client: rep.Client(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := runner.fetchWithRetry(ctx,
+		"https://services.nvd.nist.gov/rest/json/cves/2.0?lastModStartDate=2024-01-12T00:00:00.000Z")
+	require.NoError(t, err)
+
+	var resp NvdResponse
+	require.NoError(t, json.Unmarshal(data, &resp))
+	assert.Equal(t, 2, resp.TotalResults)
+	assert.Len(t, rep.Interactions(), 1)
+}
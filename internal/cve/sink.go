@@ -0,0 +1,40 @@
+package cve
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/metrics"
+)
+
+// ScoreSink receives a copy of score data as it is stored in Postgres, for
+// an append-only analytics store (see internal/clickhouse). Postgres stays
+// the source of truth: a sink error is logged and counted but never fails
+// the ingestion run.
+type ScoreSink interface {
+	Name() string
+	// InsertEpss receives one page of a day's EPSS scores.
+	InsertEpss(ctx context.Context, asOf time.Time, rows []EpssRow) error
+	// InsertCvssScores receives the CVSS base scores from one NVD page.
+	InsertCvssScores(ctx context.Context, scores []CvssScore) error
+}
+
+// CvssScore is one CVE's base score at a given NVD lastModified time.
+type CvssScore struct {
+	CveID    string
+	Source   string
+	CvssBase *float64
+	Modified time.Time
+}
+
+// SetSink sends each EPSS page to s after it is stored.
+func (r *EpssRunner) SetSink(s ScoreSink) { r.sink = s }
+
+// SetSink sends the CVSS scores of each NVD page to s after it is stored.
+func (r *NvdRunner) SetSink(s ScoreSink) { r.sink = s }
+
+func sinkFailed(s ScoreSink, table string, err error) {
+	slog.Warn("Score sink insert failed", "sink", s.Name(), "table", table, "error", err)
+	metrics.SinkErrors.WithLabelValues(s.Name(), table).Inc()
+}
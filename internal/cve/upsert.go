@@ -0,0 +1,39 @@
+package cve
+
+import (
+	"crypto/sha256"
+	"log/slog"
+
+	"tiger2go/internal/metrics"
+)
+
+// upsertStats counts the outcome of writing a set of rows to cve_enriched.
+// A row is skipped when its content_hash matches the stored one: the
+// ON CONFLICT update is guarded by a WHERE clause, so Postgres writes no new
+// tuple and reports zero rows affected. Provenance columns are therefore
+// those of the fetch that last changed the row, not the latest fetch.
+type upsertStats struct {
+	Written int
+	Skipped int
+}
+
+func (s *upsertStats) add(o upsertStats) {
+	s.Written += o.Written
+	s.Skipped += o.Skipped
+}
+
+// record reports the stats on the per-source upsert counters.
+func (s upsertStats) record(source string) {
+	metrics.CveUpserts.WithLabelValues(source, "written").Add(float64(s.Written))
+	metrics.CveUpserts.WithLabelValues(source, "skipped_unchanged").Add(float64(s.Skipped))
+}
+
+func (s upsertStats) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("written", s.Written), slog.Int("skipped_unchanged", s.Skipped))
+}
+
+// contentHash is the value stored in cve_enriched.content_hash.
+func ContentHash(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
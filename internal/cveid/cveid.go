@@ -0,0 +1,151 @@
+// Package cveid finds CVE IDs in advisory text and in the URLs advisories
+// link to. Results are upper-cased and de-duplicated in order of first
+// appearance.
+package cveid
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pattern matches an ID in normalized text. Besides the canonical form it
+// accepts spaces around the hyphens ("CVE - 2024 - 1234") and a space in
+// place of the first one ("CVE 2024-1234", often a non-breaking space).
+var pattern = regexp.MustCompile(`(?i)\bCVE(?:[ \t]*-[ \t]*|[ \t])(\d{4})[ \t]*-[ \t]*(\d{4,})\b`)
+
+// exact matches a whole, already upper-cased ID.
+var exact = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+
+// firstYear is the year of the first CVE IDs.
+const firstYear = 1999
+
+// Extract returns the CVE IDs mentioned in texts. Text is normalized first
+// (see Normalize), so IDs written with Unicode hyphens, non-breaking or
+// zero-width characters, full-width forms or HTML entities are found too.
+// IDs that cannot have been assigned (see Valid) are typos and are dropped.
+func Extract(texts ...string) []string {
+	var ids []string
+	now := time.Now()
+	for _, t := range texts {
+		for _, m := range pattern.FindAllStringSubmatch(Normalize(t), -1) {
+			if id := "CVE-" + m[1] + "-" + m[2]; Valid(id, now) {
+				ids = add(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// Valid reports whether id, in canonical CVE-YYYY-NNNN form, could have
+// been assigned by now: the year is from 1999 to next year (CNAs reserve
+// IDs ahead of the year), and a sequence number longer than four digits
+// has no leading zero.
+func Valid(id string, now time.Time) bool {
+	if !exact.MatchString(id) {
+		return false
+	}
+	year, _ := strconv.Atoi(id[4:8])
+	seq := id[9:]
+	return year >= firstYear && year <= now.Year()+1 && (len(seq) == 4 || seq[0] != '0')
+}
+
+// Normalize rewrites the characters HTML exports and word processors put
+// into IDs: entities are decoded, Unicode hyphens and minus signs become
+// "-", non-breaking and other Unicode spaces become " ", zero-width
+// characters and soft hyphens are removed, and full-width ASCII is folded
+// to ASCII.
+func Normalize(s string) string {
+	if strings.Contains(s, "&") {
+		s = html.UnescapeString(s)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x80:
+			return r
+		case r == '\u2010', r == '\u2011', r == '\u2012', r == '\u2013', r == '\u2014',
+			r == '\u2015', r == '\u2212', r == '\ufe58', r == '\ufe63':
+			return '-'
+		case r == '\u00ad', r == '\u200b', r == '\u200c', r == '\u200d', r == '\u2060', r == '\ufeff':
+			return -1
+		case r == '\u00a0', r == '\u202f', r == '\u3000', r >= '\u2000' && r <= '\u200a':
+			return ' '
+		case r >= '\uff01' && r <= '\uff5e':
+			return r - 0xff01 + '!'
+		}
+		return r
+	}, s)
+}
+
+// FromURLs returns the CVE IDs named by CVE record pages among urls: NVD
+// (nvd.nist.gov/vuln/detail/<id>), MITRE (cve.mitre.org/cgi-bin/cvename.cgi?name=<id>)
+// and CVE.org (www.cve.org/CVERecord?id=<id>). Unlike Extract it decodes
+// escaped paths and query strings, and it ignores other sites' URLs that
+// merely contain an ID.
+func FromURLs(urls ...string) []string {
+	var ids []string
+	for _, raw := range urls {
+		if id := fromURL(raw); id != "" {
+			ids = add(ids, id)
+		}
+	}
+	return ids
+}
+
+func fromURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	var id string
+	switch strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") {
+	case "nvd.nist.gov":
+		if rest, ok := strings.CutPrefix(u.Path, "/vuln/detail/"); ok {
+			id = strings.TrimSuffix(rest, "/")
+		}
+	case "cve.mitre.org":
+		if strings.HasSuffix(u.Path, "/cvename.cgi") {
+			id = u.Query().Get("name")
+		}
+	case "cve.org":
+		if strings.EqualFold(u.Path, "/CVERecord") {
+			id = u.Query().Get("id")
+		}
+	}
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if !Valid(id, time.Now()) {
+		return ""
+	}
+	return id
+}
+
+var hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// Links returns the href targets of the anchors in an HTML fragment, with
+// entities decoded, in document order.
+func Links(fragment string) []string {
+	var out []string
+	for _, m := range hrefPattern.FindAllStringSubmatch(fragment, -1) {
+		out = append(out, html.UnescapeString(m[1]+m[2]))
+	}
+	return out
+}
+
+// Merge appends the IDs in more that ids does not already hold.
+func Merge(ids []string, more ...string) []string {
+	for _, id := range more {
+		ids = add(ids, id)
+	}
+	return ids
+}
+
+func add(ids []string, id string) []string {
+	if slices.Contains(ids, id) {
+		return ids
+	}
+	return append(ids, id)
+}
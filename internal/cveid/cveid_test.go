@@ -0,0 +1,118 @@
+package cveid
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	ids := Extract(
+		"Ivanti fixes CVE-2024-21887 and cve-2023-46805",
+		"See CVE-2024-21887. Not an ID: CVE-24-1, XCVE-2024-12345",
+	)
+	assert.Equal(t, []string{"CVE-2024-21887", "CVE-2023-46805"}, ids)
+}
+
+func TestExtract_Variants(t *testing.T) {
+	tests := []struct {
+		name, in string
+		want     []string
+	}{
+		{"non-breaking hyphens", "CVE\u20112024\u20113400", []string{"CVE-2024-3400"}},
+		{"en dash and minus sign", "CVE\u20132024\u221221762", []string{"CVE-2024-21762"}},
+		{"non-breaking space", "CVE\u00a02023-4966", []string{"CVE-2023-4966"}},
+		{"zero-width space", "CVE-2023-\u200b20198", []string{"CVE-2023-20198"}},
+		{"soft hyphen", "CVE-20\u00ad24-1086", []string{"CVE-2024-1086"}},
+		{"spaced hyphens", "cve - 2024 - 0012", []string{"CVE-2024-0012"}},
+		{"full-width", "\uff23\uff36\uff25\uff0d\uff12\uff10\uff12\uff14\uff0d\uff13\uff14\uff10\uff10", []string{"CVE-2024-3400"}},
+		{"HTML entities", "CVE&#8209;2024&#8209;3400 and CVE&nbsp;2024-21762", []string{"CVE-2024-3400", "CVE-2024-21762"}},
+		{"mixed case", "Cve-2024-3400", []string{"CVE-2024-3400"}},
+		{"line break is not a separator", "CVE\n2024-3400", nil},
+		{"short year", "CVE-24-3400", nil},
+		{"inside a word", "XCVE-2024-3400", nil},
+		{"year before CVE", "CVE-1024-3400", nil},
+		{"leading zero in a long sequence", "CVE-2024-034000", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Extract(tt.in), tt.name)
+	}
+}
+
+func TestValid(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"CVE-1999-0001", true},
+		{"CVE-2024-0001", true},
+		{"CVE-2025-21333", true},
+		{"CVE-2021-1234567", true},
+		{"CVE-1998-0001", false},
+		{"CVE-2026-0001", false},
+		{"CVE-2204-3400", false},
+		{"CVE-2024-01234", false},
+		{"cve-2024-1234", false},
+		{"CVE-2024-123", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Valid(tt.id, now), tt.id)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "a-b c d", Normalize("a\u2010b\u00a0c\u2009d\u200b"))
+	assert.Equal(t, "plain ASCII & more", Normalize("plain ASCII &amp; more"))
+}
+
+func TestFromURLs(t *testing.T) {
+	ids := FromURLs(
+		"https://nvd.nist.gov/vuln/detail/CVE-2024-3400",
+		"https://nvd.nist.gov/vuln/detail/cve-2024-21762/",
+		"https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE%2D2023%2D4966",
+		"https://www.cve.org/CVERecord?id=CVE-2023-20198",
+		"https://nvd.nist.gov/vuln/detail/CVE-2024-3400#range-1", // duplicate
+		"https://example.com/blog/CVE-2024-0001",                 // not a CVE record page
+		"https://nvd.nist.gov/vuln/search?query=CVE-2024-0002",
+		"https://cve.mitre.org/cgi-bin/cvename.cgi?name=not-an-id",
+		"::not a url",
+	)
+	assert.Equal(t, []string{"CVE-2024-3400", "CVE-2024-21762", "CVE-2023-4966", "CVE-2023-20198"}, ids)
+}
+
+func TestLinks(t *testing.T) {
+	links := Links(`<p>Details: <a href="https://nvd.nist.gov/vuln/detail/CVE-2024-3400" rel="nofollow">NVD</a>
+		and <a HREF='https://www.cve.org/CVERecord?id=CVE-2023-20198&amp;x=1'>CVE.org</a></p>`)
+	assert.Equal(t, []string{
+		"https://nvd.nist.gov/vuln/detail/CVE-2024-3400",
+		"https://www.cve.org/CVERecord?id=CVE-2023-20198&x=1",
+	}, links)
+}
+
+func TestMerge(t *testing.T) {
+	assert.Equal(t, []string{"CVE-2024-0001", "CVE-2024-0002"}, Merge([]string{"CVE-2024-0001"}, "CVE-2024-0002", "CVE-2024-0001"))
+}
+
+// BenchmarkExtract measures CVE extraction from an advisory-sized text,
+// the work done for every stored advisory a query or the coverage check
+// reads.
+func BenchmarkExtract(b *testing.B) {
+	text := strings.Repeat("Ivanti Connect Secure contains an authentication bypass (CVE-2023-46805) and a "+
+		"command injection vulnerability (CVE‑2024‑21887) in web components. ", 20) +
+		`<a href="https://nvd.nist.gov/vuln/detail/CVE-2024-22024">NVD</a>`
+	b.Run("extract", func(b *testing.B) {
+		b.SetBytes(int64(len(text)))
+		for b.Loop() {
+			_ = Extract("Ivanti advisory", text)
+		}
+	})
+	b.Run("links", func(b *testing.B) {
+		b.SetBytes(int64(len(text)))
+		for b.Loop() {
+			_ = FromURLs(Links(text)...)
+		}
+	})
+}
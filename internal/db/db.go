@@ -11,8 +11,14 @@ import (
 	"github.com/pressly/goose/v3"
 )
 
-// NewPool creates a new PostgreSQL connection pool.
+// NewPool creates a new PostgreSQL connection pool with default tracing.
 func NewPool(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
+	return NewPoolWithOptions(ctx, databaseURL, PoolOptions{})
+}
+
+// NewPoolWithOptions creates a new PostgreSQL connection pool whose queries
+// are timed, exported as metrics and logged when slow (see PoolOptions).
+func NewPoolWithOptions(ctx context.Context, databaseURL string, opts PoolOptions) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
@@ -23,6 +29,10 @@ func NewPool(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
 	config.MinConns = 2
 	config.MaxConnLifetime = 1 * time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
+	config.ConnConfig.Tracer = newQueryTracer(opts)
+	if opts.ReadOnly {
+		config.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
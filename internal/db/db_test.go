@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPoolWithOptions_ReadOnly(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+
+	pool, err := NewPoolWithOptions(ctx, databaseURL, PoolOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var one int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT 1").Scan(&one))
+
+	_, err = pool.Exec(ctx, "CREATE TEMP TABLE readonly_probe (id INT)")
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "expected a Postgres error, got %v", err)
+	assert.Equal(t, "25006", pgErr.Code) // read_only_sql_transaction
+}
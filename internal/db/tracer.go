@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSlowQueryThreshold is used when PoolOptions.SlowQueryThreshold is zero.
+const DefaultSlowQueryThreshold = time.Second
+
+// PoolOptions tunes query tracing on a pool created by NewPoolWithOptions.
+type PoolOptions struct {
+	// SlowQueryThreshold logs queries, batches and COPYs that take at least
+	// this long at WARN. Zero means DefaultSlowQueryThreshold; negative
+	// disables slow-query logging (metrics are still recorded).
+	SlowQueryThreshold time.Duration
+	// LogStatements logs every statement's SQL and duration at DEBUG.
+	// Arguments are never logged.
+	LogStatements bool
+	// ReadOnly makes every transaction on the pool read-only
+	// (default_transaction_read_only), so a reporting pool cannot write even
+	// when it points at the primary.
+	ReadOnly bool
+}
+
+// queryTracer implements pgx.QueryTracer, pgx.BatchTracer and
+// pgx.CopyFromTracer. Durations are exported per query label
+// (see queryLabel) so the metric's cardinality is bounded by the code, not
+// by the data.
+type queryTracer struct {
+	slow          time.Duration
+	logStatements bool
+}
+
+func newQueryTracer(opts PoolOptions) *queryTracer {
+	slow := opts.SlowQueryThreshold
+	if slow == 0 {
+		slow = DefaultSlowQueryThreshold
+	}
+	return &queryTracer{slow: slow, logStatements: opts.LogStatements}
+}
+
+type traceKey struct{}
+
+type traceStart struct {
+	label string
+	sql   string
+	start time.Time
+}
+
+func (t *queryTracer) begin(ctx context.Context, label, sql string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceStart{label: label, sql: sql, start: time.Now()})
+}
+
+func (t *queryTracer) end(ctx context.Context, rows int64, err error) {
+	ts, ok := ctx.Value(traceKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(ts.start)
+	metrics.DBQueryDuration.WithLabelValues(ts.label).Observe(elapsed.Seconds())
+
+	attrs := []any{"query", ts.label, "duration", elapsed, "rows", rows}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	if t.slow > 0 && elapsed >= t.slow {
+		metrics.DBSlowQueries.WithLabelValues(ts.label).Inc()
+		slog.Warn("Slow database query", append(attrs, "sql", compactSQL(ts.sql))...)
+		return
+	}
+	if t.logStatements {
+		slog.Debug("Database query", append(attrs, "sql", compactSQL(ts.sql))...)
+	}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.begin(ctx, queryLabel(data.SQL), data.SQL)
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+// A batch is timed as a whole and labelled after its first statement;
+// with LogStatements each queued statement is also logged as it completes.
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	label, sql := "batch", ""
+	if data.Batch != nil && len(data.Batch.QueuedQueries) > 0 {
+		sql = data.Batch.QueuedQueries[0].SQL
+		label = "batch " + queryLabel(sql)
+	}
+	return t.begin(ctx, label, sql)
+}
+
+func (t *queryTracer) TraceBatchQuery(_ context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if t.logStatements {
+		slog.Debug("Database batch statement", "query", queryLabel(data.SQL), "rows", data.CommandTag.RowsAffected(), "sql", compactSQL(data.SQL))
+	}
+}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, -1, data.Err)
+}
+
+func (t *queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	table := strings.ToLower(data.TableName.Sanitize())
+	return t.begin(ctx, "COPY "+strings.ReplaceAll(table, `"`, ""), "COPY "+table+" ("+strings.Join(data.ColumnNames, ", ")+") FROM STDIN")
+}
+
+func (t *queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+// queryLabel reduces a statement to its verb and main table, e.g.
+// "INSERT cve_enriched" or "SELECT epss_daily", for use as a metric label.
+func queryLabel(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToUpper(fields[0])
+
+	var table string
+	switch verb {
+	case "INSERT":
+		table = wordAfter(fields, "INTO")
+	case "DELETE", "SELECT", "WITH":
+		table = wordAfter(fields, "FROM")
+	case "UPDATE":
+		if len(fields) > 1 {
+			table = fields[1]
+		}
+	}
+	if strings.HasPrefix(table, "(") {
+		return verb // subquery
+	}
+	if i := strings.IndexAny(table, "(;,"); i >= 0 {
+		table = table[:i]
+	}
+	table = strings.ToLower(strings.Trim(table, `"`))
+	if table == "" {
+		return verb
+	}
+	return verb + " " + table
+}
+
+func wordAfter(fields []string, word string) string {
+	for i := 0; i < len(fields)-1; i++ {
+		if strings.EqualFold(fields[i], word) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// compactSQL collapses the indentation of multi-line statements for logging.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
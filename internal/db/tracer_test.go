@@ -0,0 +1,98 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLabel(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"INSERT INTO cve_enriched (cve_id) VALUES ($1)", "INSERT cve_enriched"},
+		{"\n\t\tinsert into archive(guid) values ($1)", "INSERT archive"},
+		{"SELECT cursor FROM ingest_state WHERE source = 'NVD'", "SELECT ingest_state"},
+		{"SELECT count(*) FROM (SELECT 1) s", "SELECT"},
+		{"UPDATE epss_provenance SET pages = $1", "UPDATE epss_provenance"},
+		{"DELETE FROM kev_changes WHERE id = $1", "DELETE kev_changes"},
+		{"CREATE TEMP TABLE nvd_stage (cve_id TEXT)", "CREATE"},
+		{"SELECT 1", "SELECT"},
+		{"   ", "unknown"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, queryLabel(tt.sql), tt.sql)
+	}
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestQueryTracer_SlowQuery(t *testing.T) {
+	logs := captureLogs(t)
+	tr := newQueryTracer(PoolOptions{SlowQueryThreshold: time.Nanosecond})
+
+	ctx := tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  "SELECT cve_id\n\t\tFROM cve_enriched WHERE cve_id = $1",
+		Args: []any{"secret-arg"},
+	})
+	time.Sleep(time.Millisecond)
+	tr.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+
+	out := logs.String()
+	assert.Contains(t, out, `msg="Slow database query"`)
+	assert.Contains(t, out, `query="SELECT cve_enriched"`)
+	assert.Contains(t, out, `sql="SELECT cve_id FROM cve_enriched WHERE cve_id = $1"`)
+	assert.NotContains(t, out, "secret-arg", "arguments are never logged")
+}
+
+func TestQueryTracer_Quiet(t *testing.T) {
+	logs := captureLogs(t)
+
+	tr := newQueryTracer(PoolOptions{})
+	ctx := tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tr.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	assert.Empty(t, logs.String(), "fast queries are not logged by default")
+
+	tr = newQueryTracer(PoolOptions{SlowQueryThreshold: -1, LogStatements: true})
+	ctx = tr.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tr.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+	assert.Contains(t, logs.String(), `level=DEBUG msg="Database query"`)
+	assert.Contains(t, logs.String(), "error=boom")
+	assert.NotContains(t, logs.String(), "Slow database query")
+}
+
+func TestQueryTracer_BatchAndCopyLabels(t *testing.T) {
+	logs := captureLogs(t)
+	tr := newQueryTracer(PoolOptions{SlowQueryThreshold: time.Nanosecond})
+
+	batch := &pgx.Batch{}
+	batch.Queue("INSERT INTO current (guid) VALUES ($1)", "g")
+	ctx := tr.TraceBatchStart(context.Background(), nil, pgx.TraceBatchStartData{Batch: batch})
+	time.Sleep(time.Millisecond)
+	tr.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{})
+	assert.Contains(t, logs.String(), `query="batch INSERT current"`)
+
+	ctx = tr.TraceCopyFromStart(context.Background(), nil, pgx.TraceCopyFromStartData{
+		TableName:   pgx.Identifier{"nvd_stage"},
+		ColumnNames: []string{"cve_id", "json"},
+	})
+	time.Sleep(time.Millisecond)
+	tr.TraceCopyFromEnd(ctx, nil, pgx.TraceCopyFromEndData{CommandTag: pgconn.NewCommandTag("COPY 2")})
+	assert.Contains(t, logs.String(), `query="COPY nvd_stage"`)
+	assert.Contains(t, logs.String(), "rows=2")
+}
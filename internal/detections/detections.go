@@ -0,0 +1,220 @@
+// Package detections cross-references CVEs with public detection content:
+// Sigma rules, Nuclei templates and Emerging Threats Open Suricata
+// signatures. The Runner downloads each source's rules, keeps those that
+// reference a CVE and replaces that source's rows in detection_rules;
+// enrichment then lists the rules per CVE, so detection engineers see what
+// coverage already exists before writing their own.
+package detections
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sources, as used in [detections] sources.
+const (
+	SourceSigma  = "sigma"
+	SourceNuclei = "nuclei"
+	SourceETOpen = "etopen"
+)
+
+// Sources lists every source.
+var Sources = []string{SourceSigma, SourceNuclei, SourceETOpen}
+
+// Default download URLs.
+const (
+	DefaultSigmaURL  = "https://github.com/SigmaHQ/sigma/archive/refs/heads/master.zip"
+	DefaultNucleiURL = "https://raw.githubusercontent.com/projectdiscovery/nuclei-templates/main/cves.json"
+	DefaultETOpenURL = "https://rules.emergingthreats.net/open/suricata-7.0.3/emerging-all.rules"
+)
+
+// maxResponseBytes bounds a download; the Sigma archive and the ET Open
+// rules file are each a few tens of megabytes.
+const maxResponseBytes = 256 << 20
+
+var cveIDPattern = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+
+// Rule is one detection rule that references a CVE.
+type Rule struct {
+	CveID  string `json:"-"`
+	Source string `json:"source"`        // sigma, nuclei or etopen
+	ID     string `json:"id"`            // Sigma rule UUID, Nuclei template ID or Suricata SID
+	Title  string `json:"title"`         // rule title, template name or signature message
+	URL    string `json:"url,omitempty"` // rule page; ET Open signatures have none
+}
+
+// Runner downloads detection rules and stores those referencing a CVE.
+type Runner struct {
+	db       *pgxpool.Pool
+	client   *http.Client
+	sources  []string
+	urls     map[string]string
+	maxBytes int64
+}
+
+// NewRunner creates a Runner for the configured sources, all by default.
+// An unknown source is an error.
+func NewRunner(db *pgxpool.Pool, cfg config.DetectionsConfig) (*Runner, error) {
+	sources := Sources
+	if len(cfg.Sources) > 0 {
+		sources = nil
+		for _, s := range cfg.Sources {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !slices.Contains(Sources, s) {
+				return nil, fmt.Errorf("unknown detections source %q (available: %s)", s, strings.Join(Sources, ", "))
+			}
+			if !slices.Contains(sources, s) {
+				sources = append(sources, s)
+			}
+		}
+	}
+	return &Runner{
+		db:      db,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		sources: sources,
+		urls: map[string]string{
+			SourceSigma:  cmp.Or(cfg.SigmaURL, DefaultSigmaURL),
+			SourceNuclei: cmp.Or(cfg.NucleiURL, DefaultNucleiURL),
+			SourceETOpen: cmp.Or(cfg.ETOpenURL, DefaultETOpenURL),
+		},
+		maxBytes: httpclient.LimitFromMB(cfg.MaxResponseMB, maxResponseBytes),
+	}, nil
+}
+
+// Run refreshes every source. A failing source keeps its previous rows and
+// does not stop the others; the errors are returned joined.
+func (r *Runner) Run(ctx context.Context) error {
+	var errs []error
+	for _, source := range r.sources {
+		n, err := r.refresh(ctx, source)
+		if err != nil {
+			metrics.DetectionFetches.WithLabelValues(source, "error").Inc()
+			errs = append(errs, fmt.Errorf("detections %s: %w", source, err))
+			continue
+		}
+		metrics.DetectionFetches.WithLabelValues(source, "success").Inc()
+		metrics.DetectionRules.WithLabelValues(source).Set(float64(n))
+		slog.Info("Detection rules updated", "source", source, "rules", n)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) refresh(ctx context.Context, source string) (int, error) {
+	body, err := r.download(ctx, r.urls[source])
+	if err != nil {
+		return 0, err
+	}
+	var rules []Rule
+	switch source {
+	case SourceSigma:
+		rules, err = parseSigma(body)
+	case SourceNuclei:
+		rules, err = parseNuclei(bytes.NewReader(body))
+	case SourceETOpen:
+		rules, err = parseETOpen(bytes.NewReader(body))
+	}
+	if err != nil {
+		return 0, err
+	}
+	// An empty result is far more likely a changed format or a wrong URL
+	// than a repository without CVE rules; keep what is stored.
+	if len(rules) == 0 {
+		return 0, fmt.Errorf("no rules referencing a CVE in %s", r.urls[source])
+	}
+	return len(rules), r.store(ctx, source, rules)
+}
+
+func (r *Runner) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "tigerfetch/1.0 (+https://tigerblue.app)")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return httpclient.ReadBody(resp, r.maxBytes)
+}
+
+// store replaces source's rows with rules in one transaction.
+func (r *Runner) store(ctx context.Context, source string, rules []Rule) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM detection_rules WHERE source = $1", source); err != nil {
+		return fmt.Errorf("delete %s rules: %w", source, err)
+	}
+	rows := make([][]any, len(rules))
+	for i, rule := range rules {
+		rows[i] = []any{rule.CveID, rule.Source, rule.ID, rule.Title, rule.URL}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"detection_rules"},
+		[]string{"cve_id", "source", "rule_id", "title", "url"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return fmt.Errorf("copy %s rules: %w", source, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// ForCVEs returns the stored rules of ids, by CVE ID and ordered by source
+// and rule ID. CVEs without rules are absent.
+func ForCVEs(ctx context.Context, db *pgxpool.Pool, ids []string) (map[string][]Rule, error) {
+	out := map[string][]Rule{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, source, rule_id, title, url FROM detection_rules
+		WHERE cve_id = ANY($1)
+		ORDER BY cve_id, source, rule_id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query detection rules: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rule Rule
+		if err := rows.Scan(&rule.CveID, &rule.Source, &rule.ID, &rule.Title, &rule.URL); err != nil {
+			return nil, fmt.Errorf("scan detection rule: %w", err)
+		}
+		out[rule.CveID] = append(out[rule.CveID], rule)
+	}
+	return out, rows.Err()
+}
+
+// SourcesOf returns the distinct sources among rules, in Sources order.
+func SourcesOf(rules []Rule) []string {
+	var out []string
+	for _, s := range Sources {
+		if slices.ContainsFunc(rules, func(r Rule) bool { return r.Source == s }) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
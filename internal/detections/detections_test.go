@@ -0,0 +1,138 @@
+package detections
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSigma(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"sigma-master/rules-emerging-threats/2024/Exploits/CVE-2024-3400/proxy_panos_cve_2024_3400.yml": `title: Palo Alto PAN-OS CVE-2024-3400 Exploitation
+id: 5a1b2c3d-0000-4000-8000-000000000001
+description: Detects exploitation of CVE-2024-3400 in GlobalProtect.
+references:
+    - https://nvd.nist.gov/vuln/detail/CVE-2024-3400
+tags:
+    - attack.initial-access
+    - cve.2024-3400
+    - detection.emerging-threats
+logsource:
+    id: not-the-rule-id
+`,
+		"sigma-master/rules/windows/process_creation/proc_creation_win_susp.yml": "title: Suspicious Process\nid: 11111111-0000-4000-8000-000000000002\n",
+		"sigma-master/tests/CVE-2021-44228.yml":                                  "title: not a rule\nid: x\ntags:\n  - cve.2021-44228\n",
+		"sigma-master/rules/README.md":                                           "CVE-2021-44228",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	rules, err := parseSigma(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{{
+		CveID:  "CVE-2024-3400",
+		Source: SourceSigma,
+		ID:     "5a1b2c3d-0000-4000-8000-000000000001",
+		Title:  "Palo Alto PAN-OS CVE-2024-3400 Exploitation",
+		URL:    sigmaBlobURL + "rules-emerging-threats/2024/Exploits/CVE-2024-3400/proxy_panos_cve_2024_3400.yml",
+	}}, rules)
+
+	_, err = parseSigma([]byte("not a zip"))
+	assert.Error(t, err)
+}
+
+func TestParseNuclei(t *testing.T) {
+	in := `{"ID":"CVE-2024-21887","Info":{"Name":"Ivanti Connect Secure - Command Injection","Severity":"critical"},"file_path":"http/cves/2024/CVE-2024-21887.yaml"}
+{"ID":"cve-2021-44228","Info":{"Name":"Apache Log4j2 - Remote Code Injection"},"file_path":"http/cves/2021/CVE-2021-44228.yaml"}
+
+{"ID":"not-a-cve","Info":{"Name":"x"},"file_path":"http/misc/x.yaml"}
+`
+	rules, err := parseNuclei(strings.NewReader(in))
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{CveID: "CVE-2024-21887", Source: SourceNuclei, ID: "CVE-2024-21887", Title: "Ivanti Connect Secure - Command Injection", URL: nucleiBlobURL + "http/cves/2024/CVE-2024-21887.yaml"},
+		{CveID: "CVE-2021-44228", Source: SourceNuclei, ID: "CVE-2021-44228", Title: "Apache Log4j2 - Remote Code Injection", URL: nucleiBlobURL + "http/cves/2021/CVE-2021-44228.yaml"},
+	}, rules)
+
+	_, err = parseNuclei(strings.NewReader("{broken"))
+	assert.Error(t, err)
+}
+
+func TestParseETOpen(t *testing.T) {
+	in := `# Emerging Threats
+alert http any any -> $HOME_NET any (msg:"ET EXPLOIT Apache log4j RCE Attempt (\"jndi:ldap\") (CVE-2021-44228)"; flow:established,to_server; reference:cve,2021-44228; reference:cve,CVE-2021-45046; classtype:attempted-admin; sid:2034647; rev:3;)
+#alert http any any -> any any (msg:"ET EXPLOIT disabled"; reference:cve,2020-0001; sid:2000001; rev:1;)
+alert tcp any any -> any any (msg:"ET INFO no cve"; reference:url,example.com; sid:2000002; rev:1;)
+`
+	rules, err := parseETOpen(strings.NewReader(in))
+	require.NoError(t, err)
+	title := `ET EXPLOIT Apache log4j RCE Attempt ("jndi:ldap") (CVE-2021-44228)`
+	assert.Equal(t, []Rule{
+		{CveID: "CVE-2021-44228", Source: SourceETOpen, ID: "2034647", Title: title},
+		{CveID: "CVE-2021-45046", Source: SourceETOpen, ID: "2034647", Title: title},
+	}, rules)
+}
+
+func TestNewRunner_Sources(t *testing.T) {
+	r, err := NewRunner(nil, config.DetectionsConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, Sources, r.sources)
+	assert.Equal(t, DefaultNucleiURL, r.urls[SourceNuclei])
+
+	r, err = NewRunner(nil, config.DetectionsConfig{Sources: []string{"Nuclei", "nuclei"}, NucleiURL: "https://mirror.example/cves.json"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{SourceNuclei}, r.sources)
+	assert.Equal(t, "https://mirror.example/cves.json", r.urls[SourceNuclei])
+
+	_, err = NewRunner(nil, config.DetectionsConfig{Sources: []string{"yara"}})
+	assert.Error(t, err)
+}
+
+func TestSourcesOf(t *testing.T) {
+	assert.Equal(t, []string{SourceSigma, SourceETOpen},
+		SourcesOf([]Rule{{Source: SourceETOpen}, {Source: SourceSigma}, {Source: SourceETOpen}}))
+	assert.Empty(t, SourcesOf(nil))
+}
+
+func TestStore(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+	_, err = pool.Exec(ctx, "DELETE FROM detection_rules WHERE cve_id LIKE 'CVE-1999-%'")
+	require.NoError(t, err)
+
+	r := &Runner{db: pool}
+	require.NoError(t, r.store(ctx, SourceETOpen, []Rule{
+		{CveID: "CVE-1999-0001", Source: SourceETOpen, ID: "1", Title: "old"},
+	}))
+	require.NoError(t, r.store(ctx, SourceETOpen, []Rule{
+		{CveID: "CVE-1999-0002", Source: SourceETOpen, ID: "2", Title: "new"},
+	}))
+
+	got, err := ForCVEs(ctx, pool, []string{"CVE-1999-0001", "CVE-1999-0002"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Rule{
+		"CVE-1999-0002": {{CveID: "CVE-1999-0002", Source: SourceETOpen, ID: "2", Title: "new"}},
+	}, got, "a refresh replaces the source's rules")
+}
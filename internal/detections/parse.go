@@ -0,0 +1,177 @@
+package detections
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Rule pages. Links point at the upstream repositories even when the rules
+// were downloaded from a mirror.
+const (
+	sigmaBlobURL  = "https://github.com/SigmaHQ/sigma/blob/master/"
+	nucleiBlobURL = "https://github.com/projectdiscovery/nuclei-templates/blob/main/"
+)
+
+// maxRuleBytes caps one rule file inside the Sigma archive.
+const maxRuleBytes = 1 << 20
+
+var (
+	// Sigma tags CVEs as "cve.2024-3400"; descriptions and references
+	// spell them out.
+	sigmaCVE = regexp.MustCompile(`(?i)\bcve[.-](\d{4}-\d{4,})\b`)
+
+	suricataMsg = regexp.MustCompile(`\bmsg\s*:\s*"((?:[^"\\]|\\.)*)"`)
+	suricataSID = regexp.MustCompile(`\bsid\s*:\s*(\d+)\s*;`)
+	suricataCVE = regexp.MustCompile(`(?i)\breference\s*:\s*cve\s*,\s*(?:cve-)?(\d{4}-\d{4,})`)
+)
+
+// parseSigma reads a zip of the Sigma repository (GitHub's archive, with a
+// top-level "sigma-<branch>/" directory) and returns a rule per CVE each
+// YAML file under rules*/ references in its tags or text.
+func parseSigma(archive []byte) ([]Rule, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("open sigma archive: %w", err)
+	}
+	var out []Rule
+	for _, f := range zr.File {
+		_, rel, ok := strings.Cut(f.Name, "/")
+		if !ok || !strings.HasPrefix(rel, "rules") || f.FileInfo().IsDir() {
+			continue
+		}
+		if ext := path.Ext(rel); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		body, err := io.ReadAll(io.LimitReader(rc, maxRuleBytes))
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		out = append(out, sigmaRules(rel, body)...)
+	}
+	return dedupe(out), nil
+}
+
+// sigmaRules returns the rules for one Sigma file. title and id are read
+// from the top-level keys; a full YAML parse is not needed for those.
+func sigmaRules(rel string, body []byte) []Rule {
+	var title, id string
+	for _, line := range strings.Split(string(body), "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && (k == "title" || k == "id") {
+			v = strings.Trim(strings.TrimSpace(v), `"'`)
+			if k == "title" && title == "" {
+				title = v
+			} else if k == "id" && id == "" {
+				id = v
+			}
+		}
+	}
+	if id == "" {
+		id = strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	}
+	var out []Rule
+	for _, m := range sigmaCVE.FindAllStringSubmatch(string(body), -1) {
+		out = append(out, Rule{CveID: "CVE-" + m[1], Source: SourceSigma, ID: id, Title: title, URL: sigmaBlobURL + rel})
+	}
+	return out
+}
+
+// nucleiTemplate is one line of nuclei-templates' cves.json.
+type nucleiTemplate struct {
+	ID   string `json:"ID"`
+	Info struct {
+		Name string `json:"Name"`
+	} `json:"Info"`
+	FilePath string `json:"file_path"`
+}
+
+// parseNuclei reads cves.json, one JSON object per line, each a template
+// named after the CVE it checks for.
+func parseNuclei(r io.Reader) ([]Rule, error) {
+	var out []Rule
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var t nucleiTemplate
+		if err := json.Unmarshal(line, &t); err != nil {
+			return nil, fmt.Errorf("decode nuclei template: %w", err)
+		}
+		id := strings.ToUpper(strings.TrimSpace(t.ID))
+		if !cveIDPattern.MatchString(id) {
+			continue
+		}
+		ruleID := t.ID
+		if t.FilePath != "" {
+			ruleID = strings.TrimSuffix(path.Base(t.FilePath), path.Ext(t.FilePath))
+		}
+		rule := Rule{CveID: id, Source: SourceNuclei, ID: ruleID, Title: t.Info.Name}
+		if t.FilePath != "" {
+			rule.URL = nucleiBlobURL + t.FilePath
+		}
+		out = append(out, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read nuclei templates: %w", err)
+	}
+	return dedupe(out), nil
+}
+
+// parseETOpen reads a Suricata rules file and returns a rule per
+// "reference:cve,..." option of each enabled signature.
+func parseETOpen(r io.Reader) ([]Rule, error) {
+	var out []Rule
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sid := suricataSID.FindStringSubmatch(line)
+		if sid == nil {
+			continue
+		}
+		var title string
+		if m := suricataMsg.FindStringSubmatch(line); m != nil {
+			title = strings.ReplaceAll(m[1], `\"`, `"`)
+		}
+		for _, m := range suricataCVE.FindAllStringSubmatch(line, -1) {
+			out = append(out, Rule{CveID: "CVE-" + m[1], Source: SourceETOpen, ID: sid[1], Title: title})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read ET Open rules: %w", err)
+	}
+	return dedupe(out), nil
+}
+
+// dedupe drops repeated references of a rule to a CVE, keeping order. A
+// Sigma rule may name its CVE in the tags, title and references.
+func dedupe(rules []Rule) []Rule {
+	type key struct{ cve, source, id string }
+	seen := make(map[key]bool, len(rules))
+	return slices.DeleteFunc(rules, func(r Rule) bool {
+		k := key{r.CveID, r.Source, r.ID}
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		return false
+	})
+}
@@ -0,0 +1,505 @@
+// Package enrich merges what tigerfetch knows about a list of CVEs (NVD
+// score, KEV entry, latest EPSS) into one record per CVE. It backs the
+// POST /enrich API and the `tigerfetch enrich` command. Detection rules
+// stored by the detections runner are listed with each CVE, and so are the
+// end-of-life product releases it affects.
+//
+// Lookups read the local store first. CVEs the store has never seen can be
+// fetched from NVD and EPSS when an Upstream is set; those results are
+// returned but not stored, so the scheduled runners stay the only writers.
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/cveid"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/kev"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/reflabel"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Defaults for SetLimits.
+const (
+	DefaultMaxIDs      = 500
+	DefaultMaxUpstream = 20
+)
+
+// ErrTooManyIDs is returned when a request exceeds the configured maximum.
+var ErrTooManyIDs = errors.New("too many CVE IDs")
+
+// Result is the merged view of one CVE.
+type Result struct {
+	CveID  string     `json:"cve_id"`
+	Found  bool       `json:"found"`
+	Source string     `json:"source,omitempty"` // "local" or "upstream"; empty when not found
+	NVD    *NVD       `json:"nvd,omitempty"`
+	InKEV  bool       `json:"in_kev"`
+	KEV    *kev.Entry `json:"kev,omitempty"`
+	EPSS   *EPSS      `json:"epss,omitempty"`
+
+	Detections []detections.Rule `json:"detections,omitempty"` // Sigma, Nuclei and ET Open rules referencing it
+	EOL        []eol.Affected    `json:"eol,omitempty"`        // affected product releases past end of life
+
+	// Reserved is set when repeated upstream lookups found nothing: the ID
+	// is reserved but unpublished, or a typo. Lookups of it back off.
+	Reserved bool `json:"reserved,omitempty"`
+}
+
+// NVD is the NVD-derived part of a Result.
+type NVD struct {
+	CvssBase         *float64  `json:"cvss_base"`
+	Severity         string    `json:"severity,omitempty"`
+	CvssVersion      string    `json:"cvss_version,omitempty"` // "3.1" or "3.0"
+	CvssSource       string    `json:"cvss_source,omitempty"`  // whose score won under [merge] cvss: nvd, cna, adp or other
+	Modified         time.Time `json:"modified"`
+	SourceIdentifier string    `json:"source_identifier,omitempty"` // issuing CNA
+	VulnStatus       string    `json:"vuln_status,omitempty"`       // NVD analysis status
+
+	References []cve.NvdReference `json:"references,omitempty"`
+	ExploitRef bool               `json:"exploit_ref"`         // a reference is tagged Exploit
+	PatchURL   string             `json:"patch_url,omitempty"` // first reference tagged Patch
+
+	AdvisoryURL   string `json:"advisory_url,omitempty"`   // first reference tagged Vendor Advisory
+	AdvisoryLabel string `json:"advisory_label,omitempty"` // its page title, else host and advisory ID
+
+	FixedIn        []fixes.FixedIn `json:"fixed_in,omitempty"` // fixed versions, KB articles and errata
+	PatchAvailable bool            `json:"patch_available"`    // a Patch reference or any FixedIn
+
+	configurations []byte // CPE applicability, for end-of-life matching
+}
+
+// setReferences stores refs and the flags derived from their tags.
+func (n *NVD) setReferences(refs []cve.NvdReference) {
+	n.References = refs
+	n.ExploitRef = cve.TaggedURL(refs, cve.RefTagExploit) != ""
+	n.PatchURL = cve.TaggedURL(refs, cve.RefTagPatch)
+	n.AdvisoryURL = cve.TaggedURL(refs, cve.RefTagVendorAdvisory)
+	if n.AdvisoryURL != "" {
+		n.AdvisoryLabel = reflabel.Fallback(n.AdvisoryURL)
+	}
+}
+
+// setCvss picks the score under priority; n.SourceIdentifier must be set.
+// Records without CVSS v3 metrics keep the stored score, if any, with no
+// source.
+func (n *NVD) setCvss(metricsRaw []byte, priority []string) {
+	if c := cve.SelectCvss(metricsRaw, n.SourceIdentifier, priority); c != nil {
+		n.CvssBase, n.Severity, n.CvssVersion, n.CvssSource = &c.Score, c.Severity, c.Version, c.Source
+	}
+}
+
+// setFixes collects fixed versions from the CPE configurations and update
+// identifiers from the reference links; call it after setReferences.
+func (n *NVD) setFixes(configurations []byte) {
+	n.configurations = configurations
+	n.FixedIn = fixes.FromNVD(configurations)
+	for _, r := range n.References {
+		n.FixedIn = fixes.Add(n.FixedIn, fixes.FromText(r.URL)...)
+	}
+	n.PatchAvailable = n.PatchURL != "" || len(n.FixedIn) > 0
+}
+
+// EPSS is the most recent EPSS score for a CVE.
+type EPSS struct {
+	Score      float64 `json:"score"`
+	Percentile float64 `json:"percentile"`
+	AsOf       string  `json:"as_of"`
+}
+
+// Upstream fetches CVEs missing from the local store. *cve.NvdRunner and
+// *cve.EpssRunner provide the methods; see RunnerUpstream.
+type Upstream interface {
+	LookupNVD(ctx context.Context, cveID string) (*cve.NvdCveItem, error)
+	LookupEPSS(ctx context.Context, cveIDs []string) ([]cve.EpssRow, error)
+}
+
+// RunnerUpstream adapts the NVD and EPSS runners to Upstream. A nil runner
+// skips that source.
+type RunnerUpstream struct {
+	NVD  *cve.NvdRunner
+	EPSS *cve.EpssRunner
+}
+
+func (u RunnerUpstream) LookupNVD(ctx context.Context, cveID string) (*cve.NvdCveItem, error) {
+	if u.NVD == nil {
+		return nil, nil
+	}
+	return u.NVD.Lookup(ctx, cveID)
+}
+
+func (u RunnerUpstream) LookupEPSS(ctx context.Context, cveIDs []string) ([]cve.EpssRow, error) {
+	if u.EPSS == nil {
+		return nil, nil
+	}
+	return u.EPSS.Lookup(ctx, cveIDs)
+}
+
+// NewRunnerUpstream builds an Upstream from configuration. EPSS is skipped
+// when epss.url is unset; NVD falls back to the public API URL.
+func NewRunnerUpstream(cfg *config.Config) RunnerUpstream {
+	u := RunnerUpstream{NVD: cve.NewNvdRunner(nil, cfg.NVD)}
+	if cfg.EPSS.URL != "" {
+		u.EPSS = cve.NewEpssRunner(nil, cfg.EPSS)
+	}
+	return u
+}
+
+// Enricher answers enrichment requests.
+type Enricher struct {
+	db          *pgxpool.Pool
+	kev         *kev.Cache
+	upstream    Upstream
+	maxIDs      int
+	maxUpstream int
+	misses      misses
+
+	cvssPriority []string // nil takes NVD's order
+}
+
+// New creates an Enricher reading from db (typically the read pool).
+func New(db *pgxpool.Pool) *Enricher {
+	return &Enricher{db: db, maxIDs: DefaultMaxIDs, maxUpstream: DefaultMaxUpstream}
+}
+
+// SetKevCache answers KEV membership from the in-memory set instead of the
+// database.
+func (e *Enricher) SetKevCache(c *kev.Cache) { e.kev = c }
+
+// SetCvssPriority sets the CVSS source precedence; see cve.SelectCvss.
+func (e *Enricher) SetCvssPriority(priority []string) { e.cvssPriority = priority }
+
+// SetUpstream enables fetching CVEs the local store does not know.
+func (e *Enricher) SetUpstream(u Upstream) { e.upstream = u }
+
+// SetLimits caps IDs per request and upstream lookups per request. Zero
+// keeps the default; a negative maxUpstream disables upstream fallback.
+func (e *Enricher) SetLimits(maxIDs, maxUpstream int) {
+	if maxIDs > 0 {
+		e.maxIDs = maxIDs
+	}
+	if maxUpstream != 0 {
+		e.maxUpstream = maxUpstream
+	}
+}
+
+// MaxIDs is the per-request limit.
+func (e *Enricher) MaxIDs() int { return e.maxIDs }
+
+// Normalize upper-cases and de-duplicates IDs, keeping first-seen order,
+// and separates out strings that are not CVE IDs, or IDs that cannot have
+// been assigned (see cveid.Valid). Unicode hyphens and spaces pasted from
+// HTML are folded first (see cveid.Normalize).
+func Normalize(ids []string) (valid, invalid []string) {
+	seen := make(map[string]bool, len(ids))
+	now := time.Now()
+	for _, raw := range ids {
+		id := strings.ToUpper(strings.TrimSpace(cveid.Normalize(raw)))
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if cveid.Valid(id, now) {
+			valid = append(valid, id)
+		} else {
+			invalid = append(invalid, raw)
+		}
+	}
+	return valid, invalid
+}
+
+// ReadIDs reads CVE IDs separated by whitespace or commas. Text after '#'
+// on a line is a comment.
+func ReadIDs(r io.Reader) ([]string, error) {
+	var ids []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		ids = append(ids, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+	return ids, sc.Err()
+}
+
+// Enrich returns one Result per ID, in order. ids must already be
+// normalized (see Normalize).
+func (e *Enricher) Enrich(ctx context.Context, ids []string) ([]Result, error) {
+	if len(ids) > e.maxIDs {
+		return nil, fmt.Errorf("%w: %d requested, limit %d", ErrTooManyIDs, len(ids), e.maxIDs)
+	}
+
+	results := make([]Result, len(ids))
+	byID := make(map[string]*Result, len(ids))
+	for i, id := range ids {
+		results[i].CveID = id
+		byID[id] = &results[i]
+	}
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	if err := e.loadNVD(ctx, ids, byID); err != nil {
+		return nil, err
+	}
+	if err := e.loadKEV(ctx, ids, byID); err != nil {
+		return nil, err
+	}
+	if err := e.loadEPSS(ctx, ids, byID); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].Found {
+			results[i].Source = "local"
+		}
+	}
+
+	e.fillFromUpstream(ctx, results)
+	if err := e.loadAdvisoryLabels(ctx, results); err != nil {
+		return nil, err
+	}
+	rules, err := detections.ForCVEs(ctx, e.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, list := range rules {
+		if r := byID[id]; r != nil {
+			r.Detections = list
+		}
+	}
+	if err := e.loadEOL(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (e *Enricher) loadNVD(ctx context.Context, ids []string, byID map[string]*Result) error {
+	rows, err := e.db.Query(ctx, `
+		SELECT cve_id, cvss_base::float8, COALESCE(json->'metrics', '{}'),
+		       modified, COALESCE(source_identifier, ''), COALESCE(vuln_status, ''),
+		       COALESCE(json->'references', '[]'), COALESCE(json->'configurations', '[]')
+		FROM cve_enriched
+		WHERE source = 'NVD' AND cve_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("query NVD records: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var n NVD
+		var refs []cve.NvdReference
+		var metricsRaw, configurations []byte
+		if err := rows.Scan(&id, &n.CvssBase, &metricsRaw, &n.Modified, &n.SourceIdentifier, &n.VulnStatus, &refs, &configurations); err != nil {
+			return fmt.Errorf("scan NVD record: %w", err)
+		}
+		n.setCvss(metricsRaw, e.cvssPriority)
+		n.setReferences(refs)
+		n.setFixes(configurations)
+		if r := byID[id]; r != nil {
+			r.NVD, r.Found = &n, true
+		}
+	}
+	return rows.Err()
+}
+
+// loadAdvisoryLabels replaces fallback advisory labels with the page titles
+// the reference label resolver stored.
+func (e *Enricher) loadAdvisoryLabels(ctx context.Context, results []Result) error {
+	var urls []string
+	for _, r := range results {
+		if r.NVD != nil && r.NVD.AdvisoryURL != "" {
+			urls = append(urls, r.NVD.AdvisoryURL)
+		}
+	}
+	labels, err := reflabel.Labels(ctx, e.db, urls)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.NVD == nil {
+			continue
+		}
+		if label, ok := labels[r.NVD.AdvisoryURL]; ok {
+			r.NVD.AdvisoryLabel = label
+		}
+	}
+	return nil
+}
+
+// loadEOL marks results whose NVD configurations affect a product release
+// past end of life.
+func (e *Enricher) loadEOL(ctx context.Context, results []Result) error {
+	configurations := map[string][]byte{}
+	for _, r := range results {
+		if r.NVD != nil {
+			configurations[r.CveID] = r.NVD.configurations
+		}
+	}
+	affected, err := eol.Lookup(ctx, e.db, configurations)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].EOL = affected[results[i].CveID]
+	}
+	return nil
+}
+
+func (e *Enricher) loadKEV(ctx context.Context, ids []string, byID map[string]*Result) error {
+	if e.kev != nil && !e.kev.Catalog().LoadedAt().IsZero() {
+		cat := e.kev.Catalog()
+		for _, id := range ids {
+			if entry, ok := cat.Get(id); ok {
+				r := byID[id]
+				r.KEV, r.InKEV, r.Found = &entry, true, true
+			}
+		}
+		return nil
+	}
+
+	rows, err := e.db.Query(ctx, `
+		SELECT cve_id,
+		       COALESCE(json->>'vendorProject', ''), COALESCE(json->>'product', ''),
+		       COALESCE(json->>'vulnerabilityName', ''),
+		       COALESCE(json->>'dateAdded', ''), COALESCE(json->>'dueDate', ''),
+		       COALESCE(json->>'knownRansomwareCampaignUse', '') = 'Known'
+		FROM cve_enriched
+		WHERE source = 'CISA-KEV' AND cve_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("query KEV records: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k kev.Entry
+		if err := rows.Scan(&k.CveID, &k.VendorProject, &k.Product, &k.VulnerabilityName, &k.DateAdded, &k.DueDate, &k.Ransomware); err != nil {
+			return fmt.Errorf("scan KEV record: %w", err)
+		}
+		if r := byID[k.CveID]; r != nil {
+			r.KEV, r.InKEV, r.Found = &k, true, true
+		}
+	}
+	return rows.Err()
+}
+
+// loadEPSS reads the most recent score within the last month of history,
+// which keeps the scan to the newest partitions.
+func (e *Enricher) loadEPSS(ctx context.Context, ids []string, byID map[string]*Result) error {
+	rows, err := e.db.Query(ctx, `
+		SELECT DISTINCT ON (cve_id) cve_id, epss::float8, COALESCE(percentile, 0)::float8, as_of::text
+		FROM epss_daily
+		WHERE cve_id = ANY($1) AND as_of >= CURRENT_DATE - 31
+		ORDER BY cve_id, as_of DESC
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("query EPSS scores: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var s EPSS
+		if err := rows.Scan(&id, &s.Score, &s.Percentile, &s.AsOf); err != nil {
+			return fmt.Errorf("scan EPSS score: %w", err)
+		}
+		if r := byID[id]; r != nil {
+			r.EPSS, r.Found = &s, true
+		}
+	}
+	return rows.Err()
+}
+
+// fillFromUpstream looks up CVEs with no local data. Upstream errors leave
+// the result as not found rather than failing the whole request. CVEs
+// neither NVD nor EPSS returned are looked up again only after a backoff.
+func (e *Enricher) fillFromUpstream(ctx context.Context, results []Result) {
+	if e.upstream == nil || e.maxUpstream < 0 {
+		return
+	}
+
+	now := time.Now()
+	var missing []*Result
+	for i := range results {
+		if results[i].Found || len(missing) >= e.maxUpstream {
+			continue
+		}
+		due, reserved := e.misses.check(results[i].CveID, now)
+		results[i].Reserved = reserved
+		if !due {
+			metrics.EnrichUpstreamSkipped.Inc()
+			continue
+		}
+		missing = append(missing, &results[i])
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	ids := make([]string, len(missing))
+	failed := map[string]bool{}
+	for i, r := range missing {
+		ids[i] = r.CveID
+
+		item, err := e.upstream.LookupNVD(ctx, r.CveID)
+		if err != nil {
+			slog.Warn("Enrich: NVD lookup failed", "cve_id", r.CveID, "error", err)
+			failed[r.CveID] = true
+			continue
+		}
+		if item == nil {
+			continue
+		}
+		modified, _ := time.Parse(time.RFC3339, item.Cve.LastModified)
+		r.NVD = &NVD{Modified: modified, SourceIdentifier: item.Cve.SourceIdentifier, VulnStatus: item.Cve.VulnStatus}
+		r.NVD.setCvss(item.Cve.Metrics, e.cvssPriority)
+		r.NVD.setReferences(item.Cve.References)
+		r.NVD.setFixes(item.Cve.Configurations)
+		r.Found, r.Source = true, "upstream"
+	}
+
+	scores, err := e.upstream.LookupEPSS(ctx, ids)
+	if err != nil {
+		slog.Warn("Enrich: EPSS lookup failed", "count", len(ids), "error", err)
+		return
+	}
+	byID := make(map[string]*Result, len(missing))
+	for _, r := range missing {
+		byID[r.CveID] = r
+	}
+	for _, row := range scores {
+		r := byID[strings.ToUpper(row.CVE)]
+		if r == nil {
+			continue
+		}
+		var s EPSS
+		if _, err := fmt.Sscan(row.EPSS, &s.Score); err != nil {
+			continue
+		}
+		_, _ = fmt.Sscan(row.Percentile, &s.Percentile)
+		s.AsOf = row.Date
+		r.EPSS, r.Found, r.Source = &s, true, "upstream"
+	}
+
+	for _, r := range missing {
+		switch {
+		case r.Found:
+			e.misses.clear(r.CveID)
+			r.Reserved = false
+		case !failed[r.CveID]:
+			r.Reserved = e.misses.record(r.CveID, now)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+	"tiger2go/internal/kev"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	valid, invalid := Normalize([]string{" cve-2024-21887", "CVE-2024-21887", "", "CVE-24-1", "CVE-2023-46805", "CVE-2021-1234567", "CVE-2204-21887", "CVE-2024-021887"})
+	assert.Equal(t, []string{"CVE-2024-21887", "CVE-2023-46805", "CVE-2021-1234567"}, valid)
+	assert.Equal(t, []string{"CVE-24-1", "CVE-2204-21887", "CVE-2024-021887"}, invalid, "malformed, future-year and zero-padded IDs")
+
+	valid, _ = Normalize([]string{"CVE\u20112024\u201121887\u00a0", "\u200bCVE-2024-21887"})
+	assert.Equal(t, []string{"CVE-2024-21887"}, valid, "Unicode hyphens, spaces and zero-width characters")
+}
+
+func TestReadIDs(t *testing.T) {
+	ids, err := ReadIDs(strings.NewReader("# from scanner export\nCVE-2024-21887, CVE-2023-46805\n\n\tcve-2024-0001 # trailing comment\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CVE-2024-21887", "CVE-2023-46805", "cve-2024-0001"}, ids)
+}
+
+func TestEnrich_TooMany(t *testing.T) {
+	e := New(nil)
+	e.SetLimits(1, 0)
+	_, err := e.Enrich(context.Background(), []string{"CVE-2024-0001", "CVE-2024-0002"})
+	assert.ErrorIs(t, err, ErrTooManyIDs)
+}
+
+type stubUpstream struct {
+	nvd     map[string]*cve.NvdCveItem
+	epss    []cve.EpssRow
+	nvdErr  error
+	lookups []string
+}
+
+func (s *stubUpstream) LookupNVD(_ context.Context, id string) (*cve.NvdCveItem, error) {
+	s.lookups = append(s.lookups, id)
+	if s.nvdErr != nil {
+		return nil, s.nvdErr
+	}
+	return s.nvd[id], nil
+}
+
+func (s *stubUpstream) LookupEPSS(_ context.Context, _ []string) ([]cve.EpssRow, error) {
+	return s.epss, nil
+}
+
+func TestFillFromUpstream(t *testing.T) {
+	item := &cve.NvdCveItem{}
+	item.Cve.ID = "CVE-2024-21887"
+	item.Cve.LastModified = "2024-01-22T17:15:10Z"
+	item.Cve.Metrics = json.RawMessage(`{"cvssMetricV31":[{"source":"nvd@nist.gov","type":"Primary","cvssData":{"baseScore":9.1,"baseSeverity":"CRITICAL"}}]}`)
+	item.Cve.References = []cve.NvdReference{
+		{URL: "https://forums.ivanti.com/s/article/CVE-2023-46805", Tags: []string{"Vendor Advisory", "Patch"}},
+		{URL: "http://packetstormsecurity.com/files/176668", Tags: []string{"Exploit", "Third Party Advisory"}},
+	}
+
+	up := &stubUpstream{
+		nvd:  map[string]*cve.NvdCveItem{"CVE-2024-21887": item},
+		epss: []cve.EpssRow{{CVE: "CVE-2024-21887", EPSS: "0.97", Percentile: "0.999", Date: "2024-01-23"}},
+	}
+	e := New(nil)
+	e.SetUpstream(up)
+	e.SetLimits(0, 2)
+
+	results := []Result{
+		{CveID: "CVE-2024-0001", Found: true, Source: "local"},
+		{CveID: "CVE-2024-21887"},
+		{CveID: "CVE-2024-0002"},
+		{CveID: "CVE-2024-0003"},
+	}
+	e.fillFromUpstream(context.Background(), results)
+
+	assert.Equal(t, []string{"CVE-2024-21887", "CVE-2024-0002"}, up.lookups, "capped at maxUpstream and skips found CVEs")
+	r := results[1]
+	assert.True(t, r.Found)
+	assert.Equal(t, "upstream", r.Source)
+	require.NotNil(t, r.NVD)
+	assert.InDelta(t, 9.1, *r.NVD.CvssBase, 0.001)
+	assert.Equal(t, "CRITICAL", r.NVD.Severity)
+	assert.Equal(t, cve.CvssSourceNVD, r.NVD.CvssSource)
+	assert.True(t, r.NVD.ExploitRef)
+	assert.Equal(t, "https://forums.ivanti.com/s/article/CVE-2023-46805", r.NVD.PatchURL)
+	assert.Len(t, r.NVD.References, 2)
+	assert.Equal(t, "https://forums.ivanti.com/s/article/CVE-2023-46805", r.NVD.AdvisoryURL)
+	assert.Equal(t, "forums.ivanti.com: CVE-2023-46805", r.NVD.AdvisoryLabel, "fallback until a title is resolved")
+	require.NotNil(t, r.EPSS)
+	assert.InDelta(t, 0.97, r.EPSS.Score, 0.001)
+	assert.False(t, results[2].Found)
+	assert.Empty(t, results[2].Source)
+}
+
+func TestFillFromUpstream_ErrorsLeaveNotFound(t *testing.T) {
+	e := New(nil)
+	e.SetUpstream(&stubUpstream{nvdErr: errors.New("429 Too Many Requests")})
+	results := []Result{{CveID: "CVE-2024-0001"}}
+	e.fillFromUpstream(context.Background(), results)
+	assert.False(t, results[0].Found)
+}
+
+func TestEnrich_FromDatabase(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id LIKE 'CVE-TEST-ENRICH-%'")
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE cve_id LIKE 'CVE-TEST-ENRICH-%'")
+		_, _ = pool.Exec(ctx, "DELETE FROM reference_labels WHERE url = 'https://example.com/test-enrich-advisory'")
+	}
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified) VALUES
+			('CVE-TEST-ENRICH-001', 'NVD', '{"metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":8.1,"baseSeverity":"HIGH"}}]},
+			  "references":[{"url":"https://example.com/fix","tags":["Patch"]},{"url":"https://example.com/poc","tags":["Exploit"]},
+			               {"url":"https://example.com/test-enrich-advisory","tags":["Vendor Advisory"]}]}', 8.1, now()),
+			('CVE-TEST-ENRICH-001', 'CISA-KEV', '{"vendorProject":"Acme","dueDate":"2099-01-22"}', NULL, now())
+	`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO epss_daily (as_of, cve_id, epss, percentile) VALUES
+			(CURRENT_DATE - 1, 'CVE-TEST-ENRICH-001', 0.10, 0.50),
+			(CURRENT_DATE, 'CVE-TEST-ENRICH-001', 0.42, 0.91)
+	`)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO reference_labels (url, label) VALUES ('https://example.com/test-enrich-advisory', 'Acme Security Advisory 2024-01')`)
+	require.NoError(t, err)
+
+	e := New(pool)
+	results, err := e.Enrich(ctx, []string{"CVE-TEST-ENRICH-001", "CVE-TEST-ENRICH-404"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	r := results[0]
+	assert.True(t, r.Found)
+	assert.Equal(t, "local", r.Source)
+	require.NotNil(t, r.NVD)
+	assert.InDelta(t, 8.1, *r.NVD.CvssBase, 0.001)
+	assert.Equal(t, "HIGH", r.NVD.Severity)
+	assert.Equal(t, "https://example.com/fix", r.NVD.PatchURL)
+	assert.True(t, r.NVD.ExploitRef)
+	assert.Equal(t, "Acme Security Advisory 2024-01", r.NVD.AdvisoryLabel)
+	assert.True(t, r.InKEV)
+	assert.Equal(t, "Acme", r.KEV.VendorProject)
+	require.NotNil(t, r.EPSS)
+	assert.InDelta(t, 0.42, r.EPSS.Score, 0.001, "latest score wins")
+	assert.False(t, results[1].Found)
+
+	// The in-memory KEV set answers membership once loaded.
+	cache := kev.NewCache(pool, 0)
+	cache.Store(kev.NewCatalog([]kev.Entry{{CveID: "CVE-TEST-ENRICH-404"}}, nil))
+	e.SetKevCache(cache)
+	results, err = e.Enrich(ctx, []string{"CVE-TEST-ENRICH-404"})
+	require.NoError(t, err)
+	assert.True(t, results[0].InKEV)
+}
+
+func TestFillFromUpstream_BacksOffMisses(t *testing.T) {
+	up := &stubUpstream{}
+	e := New(nil)
+	e.SetUpstream(up)
+
+	results := []Result{{CveID: "CVE-2024-99999"}}
+	e.fillFromUpstream(context.Background(), results)
+	e.fillFromUpstream(context.Background(), []Result{{CveID: "CVE-2024-99999"}})
+	assert.Len(t, up.lookups, 1, "not looked up again before the retry time")
+	assert.False(t, results[0].Reserved)
+
+	now := time.Now()
+	for range reservedAfter - 1 {
+		e.misses.record("CVE-2024-99999", now)
+	}
+	results = []Result{{CveID: "CVE-2024-99999"}}
+	e.fillFromUpstream(context.Background(), results)
+	assert.True(t, results[0].Reserved, "reserved after repeated misses")
+
+	e.misses.byID["CVE-2024-99999"] = miss{count: reservedAfter, retryAt: now.Add(-time.Minute)}
+	item := &cve.NvdCveItem{}
+	item.Cve.ID = "CVE-2024-99999"
+	up.nvd = map[string]*cve.NvdCveItem{"CVE-2024-99999": item}
+	results = []Result{{CveID: "CVE-2024-99999"}}
+	e.fillFromUpstream(context.Background(), results)
+	assert.True(t, results[0].Found)
+	assert.False(t, results[0].Reserved)
+	assert.NotContains(t, e.misses.byID, "CVE-2024-99999", "a published CVE is forgotten")
+}
+
+func TestMisses_Backoff(t *testing.T) {
+	var m misses
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.record("CVE-2024-0001", now)
+	due, _ := m.check("CVE-2024-0001", now.Add(59*time.Minute))
+	assert.False(t, due)
+	due, _ = m.check("CVE-2024-0001", now.Add(time.Hour))
+	assert.True(t, due)
+	m.record("CVE-2024-0001", now)
+	assert.Equal(t, now.Add(2*time.Hour), m.byID["CVE-2024-0001"].retryAt)
+	for range 20 {
+		m.record("CVE-2024-0001", now)
+	}
+	assert.Equal(t, now.Add(missRetryMax), m.byID["CVE-2024-0001"].retryAt, "capped")
+}
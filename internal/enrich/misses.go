@@ -0,0 +1,80 @@
+package enrich
+
+import (
+	"sync"
+	"time"
+)
+
+// Upstream lookups of a CVE that NVD and EPSS both do not know are retried
+// after missRetryMin, doubling up to missRetryMax. After reservedAfter
+// misses the ID is reported as reserved: assigned but not published, or a
+// typo that passed the format checks.
+const (
+	missRetryMin  = time.Hour
+	missRetryMax  = 7 * 24 * time.Hour
+	reservedAfter = 3
+	maxMisses     = 100_000
+)
+
+// misses remembers upstream lookups that found nothing, so IDs that never
+// resolve stop costing an NVD request on every enrichment. The state lives
+// in memory: the enricher reads through the read pool and never writes.
+type misses struct {
+	mu   sync.Mutex
+	byID map[string]miss
+}
+
+type miss struct {
+	count   int
+	retryAt time.Time
+}
+
+// check reports whether id is due for another upstream lookup at now, and
+// whether it is considered reserved.
+func (m *misses) check(id string, now time.Time) (due, reserved bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms, ok := m.byID[id]
+	if !ok {
+		return true, false
+	}
+	return !now.Before(ms.retryAt), ms.count >= reservedAfter
+}
+
+// record counts a lookup of id that found nothing and returns whether id is
+// now considered reserved.
+func (m *misses) record(id string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.byID == nil {
+		m.byID = map[string]miss{}
+	}
+	ms, ok := m.byID[id]
+	if !ok && len(m.byID) >= maxMisses {
+		m.prune(now)
+		if len(m.byID) >= maxMisses {
+			return false
+		}
+	}
+	ms.count++
+	wait := missRetryMin << min(ms.count-1, 16)
+	ms.retryAt = now.Add(min(wait, missRetryMax))
+	m.byID[id] = ms
+	return ms.count >= reservedAfter
+}
+
+// clear forgets id once a lookup finds it.
+func (m *misses) clear(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.byID, id)
+}
+
+// prune drops entries due for a retry; the caller holds mu.
+func (m *misses) prune(now time.Time) {
+	for id, ms := range m.byID {
+		if !now.Before(ms.retryAt) {
+			delete(m.byID, id)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Package eol flags CVEs that affect products past end of life, where
+// patching is not an option. The Runner downloads the endoflife.date
+// dataset once a day and stores the release cycles of every product that
+// lists a CPE identifier in eol_releases; Lookup matches the vulnerable CPE
+// ranges of a CVE's NVD configurations against the cycles that have reached
+// end of life.
+package eol
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultURL is the endoflife.date API's list of every product with its
+// identifiers and releases.
+const DefaultURL = "https://endoflife.date/api/v1/products/full"
+
+// maxResponseBytes bounds the download; the full dataset is a few
+// megabytes.
+const maxResponseBytes = 64 << 20
+
+// Release is one stored release cycle of a product.
+type Release struct {
+	CPE     string // CPE "vendor:product" the product is known by
+	Label   string // product name, e.g. "Apache Tomcat"
+	Name    string // release cycle, e.g. "8.5"
+	EOLFrom string // end of support, YYYY-MM-DD; empty when unknown
+	EOL     bool   // past end of life
+}
+
+// Affected is an end-of-life release a CVE affects.
+type Affected struct {
+	Product   string `json:"product"`             // e.g. "Apache Tomcat"
+	Release   string `json:"release"`             // e.g. "8.5"
+	EOLFrom   string `json:"eol_from,omitempty"`  // end of support, YYYY-MM-DD
+	Supported string `json:"supported,omitempty"` // newest supported release to move to
+}
+
+// Label is the product and release, e.g. "Apache Tomcat 8.5".
+func (a Affected) Label() string { return a.Product + " " + a.Release }
+
+// Labels returns the label of each release, for filter expressions and
+// tables.
+func Labels(list []Affected) []string {
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = a.Label()
+	}
+	return out
+}
+
+// RequiredAction is the remediation for CVEs in end-of-life releases, in
+// place of a patch; empty when list is.
+func RequiredAction(list []Affected) string {
+	return LocalizedAction(i18n.English(), list)
+}
+
+// LocalizedAction is RequiredAction in the language of loc.
+func LocalizedAction(loc *i18n.Localizer, list []Affected) string {
+	if len(list) == 0 {
+		return ""
+	}
+	moves := make([]string, len(list))
+	for i, a := range list {
+		moves[i] = loc.T("eol.move", map[string]string{
+			"Release": a.Label(),
+			"Target":  cmp.Or(a.Supported, loc.T("eol.supported", nil)),
+		})
+	}
+	return loc.T("eol.action", map[string]string{"Moves": loc.List(moves)})
+}
+
+// Runner downloads the endoflife.date dataset into eol_releases.
+type Runner struct {
+	db       *pgxpool.Pool
+	client   *http.Client
+	url      string
+	maxBytes int64
+}
+
+// NewRunner creates a Runner for cfg.
+func NewRunner(db *pgxpool.Pool, cfg config.EOLConfig) *Runner {
+	return &Runner{
+		db:       db,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		url:      cmp.Or(cfg.URL, DefaultURL),
+		maxBytes: httpclient.LimitFromMB(cfg.MaxResponseMB, maxResponseBytes),
+	}
+}
+
+// Run downloads the dataset and replaces the stored releases. On error the
+// previous releases are kept.
+func (r *Runner) Run(ctx context.Context) error {
+	n, err := r.refresh(ctx)
+	if err != nil {
+		metrics.EOLFetches.WithLabelValues("error").Inc()
+		return fmt.Errorf("endoflife.date: %w", err)
+	}
+	metrics.EOLFetches.WithLabelValues("success").Inc()
+	metrics.EOLReleases.Set(float64(n))
+	slog.Info("End-of-life releases updated", "releases", n)
+	return nil
+}
+
+func (r *Runner) refresh(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "tigerfetch/1.0 (+https://tigerblue.app)")
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: unexpected status %d", r.url, resp.StatusCode)
+	}
+	body, err := httpclient.ReadBody(resp, r.maxBytes)
+	if err != nil {
+		return 0, err
+	}
+	releases, err := parse(body)
+	if err != nil {
+		return 0, err
+	}
+	// As with detection rules, nothing usable means a changed format or a
+	// wrong URL; keep what is stored.
+	if len(releases) == 0 {
+		return 0, fmt.Errorf("no products with a CPE identifier in %s", r.url)
+	}
+	return len(releases), r.store(ctx, releases)
+}
+
+// store replaces eol_releases with releases in one transaction.
+func (r *Runner) store(ctx context.Context, releases []Release) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM eol_releases"); err != nil {
+		return fmt.Errorf("delete releases: %w", err)
+	}
+	rows := make([][]any, len(releases))
+	for i, rel := range releases {
+		var eolFrom any
+		if rel.EOLFrom != "" {
+			eolFrom = rel.EOLFrom
+		}
+		rows[i] = []any{rel.CPE, rel.Label, rel.Name, eolFrom, rel.EOL}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"eol_releases"},
+		[]string{"cpe", "product", "release", "eol_from", "is_eol"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return fmt.Errorf("copy releases: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// ForCVEs returns the end-of-life releases the stored NVD records of ids
+// affect, by CVE ID. CVEs without any are absent.
+func ForCVEs(ctx context.Context, db *pgxpool.Pool, ids []string) (map[string][]Affected, error) {
+	if len(ids) == 0 {
+		return map[string][]Affected{}, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, COALESCE(json->'configurations', '[]')
+		FROM cve_enriched
+		WHERE source = 'NVD' AND cve_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query NVD configurations: %w", err)
+	}
+	configurations := make(map[string][]byte, len(ids))
+	for rows.Next() {
+		var id string
+		var cfg []byte
+		if err := rows.Scan(&id, &cfg); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan NVD configurations: %w", err)
+		}
+		configurations[id] = cfg
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query NVD configurations: %w", err)
+	}
+	return Lookup(ctx, db, configurations)
+}
+
+// Lookup returns the end-of-life releases each CVE's NVD "configurations"
+// affect, by CVE ID. CVEs without any are absent.
+func Lookup(ctx context.Context, db *pgxpool.Pool, configurations map[string][]byte) (map[string][]Affected, error) {
+	out := map[string][]Affected{}
+	byCVE := make(map[string][]cpeRange, len(configurations))
+	var cpes []string
+	seen := map[string]bool{}
+	for id, cfg := range configurations {
+		ranges := vulnerableRanges(cfg)
+		byCVE[id] = ranges
+		for _, rg := range ranges {
+			if !seen[rg.cpe] {
+				seen[rg.cpe] = true
+				cpes = append(cpes, rg.cpe)
+			}
+		}
+	}
+	if len(cpes) == 0 {
+		return out, nil
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT cpe, product, release, COALESCE(to_char(eol_from, 'YYYY-MM-DD'), ''),
+		       is_eol OR COALESCE(eol_from <= CURRENT_DATE, false)
+		FROM eol_releases
+		WHERE cpe = ANY($1)
+		ORDER BY cpe, product, release
+	`, cpes)
+	if err != nil {
+		return nil, fmt.Errorf("query end-of-life releases: %w", err)
+	}
+	defer rows.Close()
+	releases := map[string][]Release{}
+	for rows.Next() {
+		var rel Release
+		if err := rows.Scan(&rel.CPE, &rel.Label, &rel.Name, &rel.EOLFrom, &rel.EOL); err != nil {
+			return nil, fmt.Errorf("scan end-of-life release: %w", err)
+		}
+		releases[rel.CPE] = append(releases[rel.CPE], rel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query end-of-life releases: %w", err)
+	}
+
+	for id, ranges := range byCVE {
+		if list := affected(ranges, releases); len(list) > 0 {
+			out[id] = list
+		}
+	}
+	return out, nil
+}
+
+// product is the part of an endoflife.date product parse reads.
+type product struct {
+	Label       string `json:"label"`
+	Identifiers []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"identifiers"`
+	Releases []struct {
+		Name    string `json:"name"`
+		IsEOL   bool   `json:"isEol"`
+		EOLFrom string `json:"eolFrom"`
+	} `json:"releases"`
+}
+
+// parse reads the full dataset and returns a Release per release cycle and
+// CPE identifier of each product.
+func parse(body []byte) ([]Release, error) {
+	var doc struct {
+		Result []product `json:"result"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode endoflife.date products: %w", err)
+	}
+	var out []Release
+	type key struct{ cpe, release string }
+	seen := map[key]bool{}
+	for _, p := range doc.Result {
+		for _, id := range p.Identifiers {
+			if id.Type != "cpe" {
+				continue
+			}
+			cpe := cpeProduct(id.ID)
+			if cpe == "" {
+				continue
+			}
+			for _, rel := range p.Releases {
+				k := key{cpe, rel.Name}
+				if rel.Name == "" || seen[k] {
+					continue
+				}
+				seen[k] = true
+				out = append(out, Release{CPE: cpe, Label: p.Label, Name: rel.Name, EOLFrom: rel.EOLFrom, EOL: rel.IsEOL})
+			}
+		}
+	}
+	return out, nil
+}
+
+// cpeProduct returns "vendor:product" of a CPE 2.3 name ("cpe:2.3:a:...")
+// or CPE 2.2 URI ("cpe:/a:..."), or "".
+func cpeProduct(cpe string) string {
+	parts := strings.Split(strings.ToLower(cpe), ":")
+	switch {
+	case len(parts) >= 5 && parts[0] == "cpe" && parts[1] == "2.3":
+		parts = parts[3:]
+	case len(parts) >= 3 && parts[0] == "cpe" && strings.HasPrefix(parts[1], "/"):
+		parts = parts[2:]
+	default:
+		return ""
+	}
+	if parts[0] == "" || parts[0] == "*" || parts[1] == "" || parts[1] == "*" {
+		return ""
+	}
+	return parts[0] + ":" + parts[1]
+}
@@ -0,0 +1,119 @@
+package eol
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"tiger2go/internal/db"
+	"tiger2go/internal/i18n"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	body := `{"schema_version":"1.2.0","total":3,"result":[
+		{"name":"tomcat","label":"Apache Tomcat","identifiers":[
+			{"id":"pkg:maven/org.apache.tomcat/tomcat","type":"purl"},
+			{"id":"cpe:/a:apache:tomcat","type":"cpe"},
+			{"id":"cpe:2.3:a:apache:tomcat:*:*:*:*:*:*:*:*","type":"cpe"}],
+		 "releases":[
+			{"name":"11.0","isEol":false,"eolFrom":null},
+			{"name":"8.5","isEol":true,"eolFrom":"2024-03-31"}]},
+		{"name":"nocpe","label":"No CPE","identifiers":[],"releases":[{"name":"1","isEol":true}]},
+		{"name":"ubuntu","label":"Ubuntu","identifiers":[{"id":"cpe:/o:canonical:ubuntu_linux","type":"cpe"}],
+		 "releases":[{"name":"18.04","isEol":true,"eolFrom":"2023-05-31"}]}
+	]}`
+	got, err := parse([]byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, []Release{
+		{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "11.0"},
+		{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "8.5", EOLFrom: "2024-03-31", EOL: true},
+		{CPE: "canonical:ubuntu_linux", Label: "Ubuntu", Name: "18.04", EOLFrom: "2023-05-31", EOL: true},
+	}, got, "a product's CPE 2.2 and 2.3 identifiers are one CPE")
+
+	_, err = parse([]byte("<html>"))
+	assert.Error(t, err)
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		rg    cpeRange
+		cycle string
+		want  bool
+	}{
+		{cpeRange{version: "8.5.12"}, "8.5", true},
+		{cpeRange{version: "8.50"}, "8.5", false},
+		{cpeRange{start: "8.5.0", end: "8.5.100"}, "8.5", true},
+		{cpeRange{start: "8.5.0", end: "8.5.100"}, "9.0", false},
+		{cpeRange{end: "9.0.0"}, "8.5", true},
+		{cpeRange{end: "9.0.0"}, "9.0", false},
+		{cpeRange{end: "9.0.12"}, "9.0", true},
+		{cpeRange{end: "9.0", endIncluding: true}, "9.0", true},
+		{cpeRange{start: "10.1.0", end: "10.1.16"}, "8.5", false},
+		{cpeRange{start: "16.04", end: "20.04"}, "18.04", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.rg.covers(tt.cycle), "%+v covers %s", tt.rg, tt.cycle)
+	}
+}
+
+func TestAffected(t *testing.T) {
+	cfg := `[{"nodes":[{"operator":"OR","cpeMatch":[
+		{"vulnerable":true,"criteria":"cpe:2.3:a:apache:tomcat:*:*:*:*:*:*:*:*","versionStartIncluding":"8.5.0","versionEndExcluding":"8.5.94"},
+		{"vulnerable":true,"criteria":"cpe:2.3:a:apache:tomcat:*:*:*:*:*:*:*:*","versionStartIncluding":"9.0.0","versionEndExcluding":"9.0.81"},
+		{"vulnerable":true,"criteria":"cpe:2.3:a:apache:http_server:*:*:*:*:*:*:*:*"},
+		{"vulnerable":false,"criteria":"cpe:2.3:o:canonical:ubuntu_linux:18.04:*:*:*:lts:*:*:*"}
+	]}]}]`
+	ranges := vulnerableRanges([]byte(cfg))
+	assert.Len(t, ranges, 2, "unbounded and non-vulnerable matches are skipped")
+
+	releases := map[string][]Release{
+		"apache:tomcat": {
+			{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "11.0"},
+			{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "10.1"},
+			{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "9.0"},
+			{CPE: "apache:tomcat", Label: "Apache Tomcat", Name: "8.5", EOLFrom: "2024-03-31", EOL: true},
+		},
+		"canonical:ubuntu_linux": {{CPE: "canonical:ubuntu_linux", Label: "Ubuntu", Name: "18.04", EOL: true}},
+	}
+	got := affected(ranges, releases)
+	assert.Equal(t, []Affected{{Product: "Apache Tomcat", Release: "8.5", EOLFrom: "2024-03-31", Supported: "11.0"}}, got)
+	assert.Equal(t, []string{"Apache Tomcat 8.5"}, Labels(got))
+	assert.Equal(t, "End of life, no fix will be released: upgrade Apache Tomcat 8.5 to 11.0; isolate or decommission systems that cannot be upgraded.",
+		RequiredAction(got))
+	assert.Empty(t, RequiredAction(nil))
+
+	de, err := i18n.New("de")
+	require.NoError(t, err)
+	two := append(got, Affected{Product: "Ubuntu", Release: "18.04"})
+	assert.Equal(t, "Supportende, es wird kein Fix veröffentlicht: Apache Tomcat 8.5 auf 11.0 und Ubuntu 18.04 auf eine unterstützte Version aktualisieren; "+
+		"Systeme, die nicht aktualisiert werden können, isolieren oder außer Betrieb nehmen.", LocalizedAction(de, two))
+}
+
+func TestLookup(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	r := &Runner{db: pool}
+	require.NoError(t, r.store(ctx, []Release{
+		{CPE: "example:eol_test", Label: "EOL Test", Name: "1.0", EOLFrom: "2020-01-01"},
+		{CPE: "example:eol_test", Label: "EOL Test", Name: "2.0", EOLFrom: "2999-01-01"},
+	}))
+	defer func() { _, _ = pool.Exec(ctx, "DELETE FROM eol_releases WHERE cpe = 'example:eol_test'") }()
+
+	cfg := `[{"nodes":[{"cpeMatch":[{"vulnerable":true,"criteria":"cpe:2.3:a:example:eol_test:*:*:*:*:*:*:*:*","versionEndExcluding":"2.0.5"}]}]}]`
+	got, err := Lookup(ctx, pool, map[string][]byte{"CVE-1999-0001": []byte(cfg), "CVE-1999-0002": []byte("[]")})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Affected{
+		"CVE-1999-0001": {{Product: "EOL Test", Release: "1.0", EOLFrom: "2020-01-01", Supported: "2.0"}},
+	}, got, "a past eol_from date counts as end of life")
+}
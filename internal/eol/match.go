@@ -0,0 +1,163 @@
+package eol
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cpeRange is the vulnerable versions of one CPE match: a single version,
+// or a range with at least one bound.
+type cpeRange struct {
+	cpe          string // "vendor:product"
+	version      string // exact version; empty for a range
+	start, end   string // at the cycle's precision, an excluded start is no different
+	endIncluding bool
+}
+
+// nvdConfig is the part of an NVD "configurations" entry vulnerableRanges
+// reads.
+type nvdConfig struct {
+	Nodes []struct {
+		CPEMatch []struct {
+			Vulnerable            bool   `json:"vulnerable"`
+			Criteria              string `json:"criteria"`
+			VersionStartIncluding string `json:"versionStartIncluding"`
+			VersionStartExcluding string `json:"versionStartExcluding"`
+			VersionEndIncluding   string `json:"versionEndIncluding"`
+			VersionEndExcluding   string `json:"versionEndExcluding"`
+		} `json:"cpeMatch"`
+	} `json:"nodes"`
+}
+
+// vulnerableRanges returns the vulnerable CPE matches of configurations.
+// Matches of every version ("*" without bounds) are skipped: they say
+// nothing about which release cycles are affected.
+func vulnerableRanges(configurations []byte) []cpeRange {
+	var cfgs []nvdConfig
+	if err := json.Unmarshal(configurations, &cfgs); err != nil {
+		return nil
+	}
+	var out []cpeRange
+	for _, c := range cfgs {
+		for _, n := range c.Nodes {
+			for _, m := range n.CPEMatch {
+				cpe := cpeProduct(m.Criteria)
+				if !m.Vulnerable || cpe == "" {
+					continue
+				}
+				rg := cpeRange{
+					cpe:          cpe,
+					start:        cmp.Or(m.VersionStartIncluding, m.VersionStartExcluding),
+					end:          m.VersionEndExcluding,
+					endIncluding: m.VersionEndIncluding != "",
+				}
+				if rg.endIncluding {
+					rg.end = m.VersionEndIncluding
+				}
+				if parts := strings.Split(m.Criteria, ":"); len(parts) > 5 && parts[5] != "*" && parts[5] != "-" {
+					rg.version = parts[5]
+				} else if rg.start == "" && rg.end == "" {
+					continue
+				}
+				out = append(out, rg)
+			}
+		}
+	}
+	return out
+}
+
+// affected returns the end-of-life releases any of ranges falls in, in
+// order of first match.
+func affected(ranges []cpeRange, releases map[string][]Release) []Affected {
+	var out []Affected
+	for _, rg := range ranges {
+		for _, rel := range releases[rg.cpe] {
+			if !rel.EOL || !rg.covers(rel.Name) {
+				continue
+			}
+			a := Affected{Product: rel.Label, Release: rel.Name, EOLFrom: rel.EOLFrom, Supported: supported(releases[rg.cpe], rel.Label)}
+			if !slices.Contains(out, a) {
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+// supported returns the newest release of product that is not end of
+// life, or "".
+func supported(releases []Release, product string) string {
+	var best string
+	for _, rel := range releases {
+		if rel.Label == product && !rel.EOL && (best == "" || compareVersions(rel.Name, best) > 0) {
+			best = rel.Name
+		}
+	}
+	return best
+}
+
+// covers reports whether any version of release cycle cycle (e.g. "8.5",
+// covering 8.5.x) is in rg. Bounds are compared at the cycle's precision,
+// so 8.5.0 <= v < 8.5.100 covers "8.5" and v < 9.0 covers every 8.x.
+func (rg cpeRange) covers(cycle string) bool {
+	if rg.version != "" {
+		return rg.version == cycle || strings.HasPrefix(rg.version, cycle+".")
+	}
+	n := len(strings.Split(cycle, "."))
+	if rg.start != "" && compareVersions(truncate(rg.start, n), cycle) > 0 {
+		return false
+	}
+	if rg.end != "" {
+		c := compareVersions(cycle, truncate(rg.end, n))
+		if c > 0 {
+			return false
+		}
+		// Excluding the cycle's first version (e.g. < 8.5.0) leaves none of it.
+		if c == 0 && !rg.endIncluding && compareVersions(rg.end, cycle) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate keeps the first n dot-separated segments of v.
+func truncate(v string, n int) string {
+	parts := strings.SplitN(v, ".", n+1)
+	if len(parts) > n {
+		parts = parts[:n]
+	}
+	return strings.Join(parts, ".")
+}
+
+// compareVersions compares dot-separated versions segment by segment,
+// numerically where both segments are numbers; missing segments are 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, errX := strconv.Atoi(x)
+		yn, errY := strconv.Atoi(y)
+		var c int
+		if errX == nil && errY == nil {
+			c = xn - yn
+		} else {
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			if c < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
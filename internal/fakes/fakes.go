@@ -0,0 +1,120 @@
+// Package fakes provides in-memory implementations of the upstream fetcher
+// interfaces (cve.NVDFetcher, cve.KEVFetcher, cve.EPSSFetcher and
+// ingestor.FeedFetcher) plus canned fixtures, so runners can be exercised
+// without live HTTP.
+//
+// Each fake records the URLs it was asked for and returns Err, when set,
+// instead of data.
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/ingestor"
+
+	"github.com/mmcdole/gofeed"
+)
+
+var (
+	_ cve.NVDFetcher       = (*NVD)(nil)
+	_ cve.KEVFetcher       = (*KEV)(nil)
+	_ cve.EPSSFetcher      = (*EPSS)(nil)
+	_ ingestor.FeedFetcher = (*Feed)(nil)
+)
+
+// calls is a concurrency-safe record of requested URLs.
+type calls struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (c *calls) record(url string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.urls = append(c.urls, url)
+	return len(c.urls) - 1
+}
+
+// URLs returns the URLs requested so far, in order.
+func (c *calls) URLs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.urls...)
+}
+
+// NVD serves Pages in order, one per call. Once the pages run out it
+// returns an empty page so paging loops terminate.
+type NVD struct {
+	calls
+	Pages []*cve.NvdResponse
+	Err   error
+}
+
+func (f *NVD) FetchPage(ctx context.Context, url string) (*cve.NvdResponse, error) {
+	n := f.record(url)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if n < len(f.Pages) {
+		return f.Pages[n], nil
+	}
+	return &cve.NvdResponse{}, nil
+}
+
+// KEV serves the same Catalog on every call.
+type KEV struct {
+	calls
+	Catalog *cve.KevCatalog
+	Err     error
+}
+
+func (f *KEV) FetchCatalog(ctx context.Context, url string) (*cve.KevCatalog, error) {
+	f.record(url)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.Catalog == nil {
+		return nil, cve.ErrNotFound
+	}
+	return f.Catalog, nil
+}
+
+// EPSS serves Pages in order, one per call, then empty pages.
+type EPSS struct {
+	calls
+	Pages []*cve.EpssResponse
+	Err   error
+}
+
+func (f *EPSS) FetchPage(ctx context.Context, url string) (*cve.EpssResponse, error) {
+	n := f.record(url)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if n < len(f.Pages) {
+		return f.Pages[n], nil
+	}
+	return &cve.EpssResponse{Status: "OK"}, nil
+}
+
+// Feed serves parsed feeds keyed by URL.
+type Feed struct {
+	calls
+	Feeds map[string]*gofeed.Feed
+	Err   error
+}
+
+func (f *Feed) Fetch(ctx context.Context, url string) (*gofeed.Feed, error) {
+	f.record(url)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	feed, ok := f.Feeds[url]
+	if !ok {
+		return nil, fmt.Errorf("fakes: no feed registered for %s", url)
+	}
+	return feed, nil
+}
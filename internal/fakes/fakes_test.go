@@ -0,0 +1,80 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tiger2go/internal/cve"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixturesDecode(t *testing.T) {
+	kev := KevCatalog()
+	require.Len(t, kev.Vulnerabilities, 2)
+	assert.Equal(t, "CVE-2023-46805", kev.Vulnerabilities[0].CveID)
+
+	nvd := NvdPage()
+	require.Len(t, nvd.Vulnerabilities, 2)
+	assert.Equal(t, 2, nvd.TotalResults)
+	assert.NotEmpty(t, nvd.Vulnerabilities[1].Cve.Metrics)
+
+	epss := EpssPage()
+	require.Len(t, epss.Data, 2)
+	assert.Equal(t, "CVE-2024-21887", epss.Data[1].CVE)
+
+	feed := RSSFeed()
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "fixture-guid-001", feed.Items[0].GUID)
+}
+
+func TestFixturesAreIndependentCopies(t *testing.T) {
+	a := KevCatalog()
+	a.Vulnerabilities[0].CveID = "mutated"
+	assert.Equal(t, "CVE-2023-46805", KevCatalog().Vulnerabilities[0].CveID)
+}
+
+func TestNVD_ServesPagesThenEmpty(t *testing.T) {
+	f := &NVD{Pages: []*cve.NvdResponse{NvdPage()}}
+	ctx := context.Background()
+
+	p, err := f.FetchPage(ctx, "https://nvd.example/page1")
+	require.NoError(t, err)
+	assert.Len(t, p.Vulnerabilities, 2)
+
+	p, err = f.FetchPage(ctx, "https://nvd.example/page2")
+	require.NoError(t, err)
+	assert.Empty(t, p.Vulnerabilities)
+
+	assert.Equal(t, []string{"https://nvd.example/page1", "https://nvd.example/page2"}, f.URLs())
+}
+
+func TestKEV_Errors(t *testing.T) {
+	_, err := (&KEV{}).FetchCatalog(context.Background(), "u")
+	assert.ErrorIs(t, err, cve.ErrNotFound, "no catalog behaves like a missing upstream")
+
+	boom := errors.New("boom")
+	_, err = (&KEV{Catalog: KevCatalog(), Err: boom}).FetchCatalog(context.Background(), "u")
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestEPSS_ServesPages(t *testing.T) {
+	f := &EPSS{Pages: []*cve.EpssResponse{EpssPage()}}
+	p, err := f.FetchPage(context.Background(), "u")
+	require.NoError(t, err)
+	assert.Len(t, p.Data, 2)
+}
+
+func TestFeed_UnknownURL(t *testing.T) {
+	f := &Feed{Feeds: map[string]*gofeed.Feed{"https://a.example/rss": RSSFeed()}}
+
+	feed, err := f.Fetch(context.Background(), "https://a.example/rss")
+	require.NoError(t, err)
+	assert.Equal(t, "Fixture Security Feed", feed.Title)
+
+	_, err = f.Fetch(context.Background(), "https://b.example/rss")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package fakes
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+
+	"tiger2go/internal/cve"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Fixtures are small, real-shaped upstream responses covering two
+// Ivanti Connect Secure CVEs that appear in every source.
+//
+//go:embed testdata
+var testdata embed.FS
+
+// Raw returns the bytes of a fixture file under testdata/, e.g. "kev_catalog.json".
+func Raw(name string) []byte {
+	b, err := testdata.ReadFile("testdata/" + name)
+	if err != nil {
+		panic("fakes: missing fixture " + name)
+	}
+	return b
+}
+
+func decode(name string, v any) {
+	if err := json.Unmarshal(Raw(name), v); err != nil {
+		panic("fakes: invalid fixture " + name + ": " + err.Error())
+	}
+}
+
+// KevCatalog returns a fresh copy of the canned KEV catalog.
+func KevCatalog() *cve.KevCatalog {
+	var c cve.KevCatalog
+	decode("kev_catalog.json", &c)
+	return &c
+}
+
+// NvdPage returns a fresh copy of the canned NVD page.
+func NvdPage() *cve.NvdResponse {
+	var r cve.NvdResponse
+	decode("nvd_page.json", &r)
+	return &r
+}
+
+// EpssPage returns a fresh copy of the canned EPSS page.
+func EpssPage() *cve.EpssResponse {
+	var r cve.EpssResponse
+	decode("epss_page.json", &r)
+	return &r
+}
+
+// RSSFeed returns the canned RSS feed, parsed.
+func RSSFeed() *gofeed.Feed {
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(Raw("feed.xml")))
+	if err != nil {
+		panic("fakes: invalid fixture feed.xml: " + err.Error())
+	}
+	return feed
+}
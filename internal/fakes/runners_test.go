@@ -0,0 +1,143 @@
+package fakes
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+	"tiger2go/internal/ingestor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPool returns a pool on a migrated DATABASE_URL, skipping the test
+// without one. The runners below fetch from the fakes but store for real.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"), "failed to run migrations")
+	pool, err := db.NewPool(context.Background(), databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestNvdRunner_WithFake(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source IN ('NVD', 'NVD-MODIFIED')")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE source = 'NVD' AND cve_id IN ('CVE-2023-46805', 'CVE-2024-21887')")
+	}
+	cleanup()
+	defer cleanup()
+	// Resume the backfill 60 days back, so the run covers a few windows.
+	_, err := pool.Exec(ctx, "INSERT INTO ingest_state (source, cursor) VALUES ('NVD', $1)",
+		time.Now().AddDate(0, 0, -60).Format(time.RFC3339))
+	require.NoError(t, err)
+
+	fake := &NVD{Pages: []*cve.NvdResponse{NvdPage()}}
+	runner := cve.NewNvdRunner(pool, config.NvdConfig{Enabled: true, ApiKey: "test-key", PageSize: 10})
+	runner.SetFetcher(fake)
+	require.NoError(t, runner.Run(ctx))
+
+	assert.NotEmpty(t, fake.URLs())
+	var count int
+	require.NoError(t, pool.QueryRow(ctx,
+		"SELECT count(*) FROM cve_enriched WHERE source = 'NVD' AND cve_id IN ('CVE-2023-46805', 'CVE-2024-21887')",
+	).Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestKevRunner_WithFake(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = 'CISA-KEV'")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE source = 'CISA-KEV'")
+	}
+	cleanup()
+	defer cleanup()
+
+	fake := &KEV{Catalog: KevCatalog()}
+	runner := cve.NewKevRunner(pool, config.KevConfig{Enabled: true, URL: "https://kev.example/catalog.json"})
+	runner.SetFetcher(fake)
+	require.NoError(t, runner.Run(ctx))
+
+	assert.Equal(t, []string{"https://kev.example/catalog.json"}, fake.URLs())
+	var product string
+	require.NoError(t, pool.QueryRow(ctx,
+		"SELECT json->>'product' FROM cve_enriched WHERE source = 'CISA-KEV' AND cve_id = 'CVE-2024-21887'",
+	).Scan(&product))
+	assert.Equal(t, "Connect Secure and Policy Secure", product)
+	var cursor string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = 'CISA-KEV'").Scan(&cursor))
+	assert.NotEmpty(t, cursor)
+}
+
+func TestEpssRunner_WithFake(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	// A day far ahead, so the run does not touch real history.
+	page := EpssPage()
+	for i := range page.Data {
+		page.Data[i].Date = "2100-03-01"
+	}
+	day := time.Date(2100, 3, 1, 0, 0, 0, 0, time.UTC)
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE as_of = $1", day)
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_provenance WHERE as_of = $1", day)
+	}
+	cleanup()
+	defer cleanup()
+	require.NoError(t, cve.EnsureEpssPartition(ctx, pool, day))
+
+	fake := &EPSS{Pages: []*cve.EpssResponse{page}}
+	runner := cve.NewEpssRunner(pool, config.EpssConfig{Enabled: true, URL: "https://epss.example/data/v1/epss"})
+	runner.SetFetcher(fake)
+	require.NoError(t, runner.Run(ctx))
+
+	var epss float64
+	require.NoError(t, pool.QueryRow(ctx,
+		"SELECT epss::float8 FROM epss_daily WHERE as_of = $1 AND cve_id = 'CVE-2024-21887'", day,
+	).Scan(&epss))
+	assert.InDelta(t, 0.97372, epss, 1e-9)
+}
+
+func TestFeedClient_WithFake(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	const url = "https://fixture.example/feed.xml"
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM archive WHERE feed_url = $1", url)
+		_, _ = pool.Exec(ctx, "DELETE FROM current WHERE feed_url = $1", url)
+	}
+	cleanup()
+	defer cleanup()
+
+	fake := &Feed{Feeds: map[string]*gofeed.Feed{url: RSSFeed()}}
+	client := ingestor.New(pool)
+	client.SetFetcher(fake)
+	require.NoError(t, client.FetchAndSave(ctx, config.Feed{Name: "Fixture", URL: url, FeedType: "test"}))
+
+	assert.Equal(t, []string{url}, fake.URLs())
+	var title string
+	require.NoError(t, pool.QueryRow(ctx,
+		"SELECT title FROM current WHERE feed_url = $1 AND guid = 'fixture-guid-001'", url,
+	).Scan(&title))
+	assert.Equal(t, "Ivanti Connect Secure zero-days exploited in the wild", title)
+}
@@ -0,0 +1,214 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// node is a type-checked expression. eval returns a value of typ(): bool,
+// float64, string, time.Time, []string or []float64.
+type node interface {
+	typ() Type
+	eval(r Record) (any, error)
+}
+
+type literal struct {
+	t Type
+	v any
+}
+
+func (n *literal) typ() Type                { return n.t }
+func (n *literal) eval(Record) (any, error) { return n.v, nil }
+
+type field struct {
+	name string
+	t    Type
+}
+
+func (n *field) typ() Type { return n.t }
+
+func (n *field) eval(r Record) (any, error) {
+	v, ok := r[n.name]
+	if !ok || v == nil {
+		return zero(n.t), nil
+	}
+	switch n.t {
+	case Bool:
+		_, ok = v.(bool)
+	case Number:
+		_, ok = v.(float64)
+	case String:
+		_, ok = v.(string)
+	case Time:
+		_, ok = v.(time.Time)
+	case StringList:
+		_, ok = v.([]string)
+	case NumberList:
+		_, ok = v.([]float64)
+	}
+	if !ok {
+		return nil, fmt.Errorf("field %s: got %T, want %s", n.name, v, n.t)
+	}
+	return v, nil
+}
+
+func zero(t Type) any {
+	switch t {
+	case Bool:
+		return false
+	case Number:
+		return 0.0
+	case String:
+		return ""
+	case Time:
+		return time.Time{}
+	case StringList:
+		return []string(nil)
+	case NumberList:
+		return []float64(nil)
+	}
+	return nil
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (n *logical) typ() Type { return Bool }
+
+// eval short-circuits, so the right side's runtime errors only surface when
+// it decides the result.
+func (n *logical) eval(r Record) (any, error) {
+	l, err := n.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if l.(bool) != n.and {
+		return l, nil
+	}
+	return n.right.eval(r)
+}
+
+type not struct{ x node }
+
+func (n *not) typ() Type { return Bool }
+
+func (n *not) eval(r Record) (any, error) {
+	v, err := n.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type negate struct{ x node }
+
+func (n *negate) typ() Type { return Number }
+
+func (n *negate) eval(r Record) (any, error) {
+	v, err := n.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return -v.(float64), nil
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (n *compare) typ() Type { return Bool }
+
+func (n *compare) eval(r Record) (any, error) {
+	l, err := n.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := n.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	var c int
+	switch lv := l.(type) {
+	case bool:
+		if lv != rv.(bool) {
+			c = 1
+		}
+	case float64:
+		c = cmpFloat(lv, rv.(float64))
+	case string:
+		c = strings.Compare(lv, rv.(string))
+	case time.Time:
+		c = lv.Compare(rv.(time.Time))
+	}
+	switch n.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type member struct{ item, list node }
+
+func (n *member) typ() Type { return Bool }
+
+func (n *member) eval(r Record) (any, error) {
+	item, err := n.item.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	list, err := n.list.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	switch l := list.(type) {
+	case []string:
+		return slices.Contains(l, item.(string)), nil
+	case []float64:
+		return slices.Contains(l, item.(float64)), nil
+	}
+	return false, nil
+}
+
+type call struct {
+	t    Type
+	args []node
+	fn   func(args []any) (any, error)
+}
+
+func (n *call) typ() Type { return n.t }
+
+func (n *call) eval(r Record) (any, error) {
+	vals := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return n.fn(vals)
+}
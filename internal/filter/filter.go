@@ -0,0 +1,599 @@
+// Package filter is a small expression language for selecting records, a
+// subset of CEL (https://cel.dev) syntax:
+//
+//	kev && epss > 0.5 && source in ["CISA", "MSRC"]
+//	title.contains("Ivanti") || "CVE-2024-21887" in cves
+//	published >= timestamp("2024-01-01") && !(severity == "LOW")
+//
+// Operators, loosest first: ||, &&, then == != < <= > >= in, then unary !
+// and -. Literals are numbers, "strings" (or 'strings'), true, false and
+// [lists]. Functions: size(x), timestamp(s). String methods: contains,
+// startsWith, endsWith, matches (RE2).
+//
+// Expressions are type-checked against the record's fields when compiled,
+// so a misspelt field or a string compared with a number is reported before
+// any record is read.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Type is the type of a field or expression.
+type Type int
+
+const (
+	Bool Type = iota + 1
+	Number
+	String
+	Time
+	StringList
+	NumberList
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Time:
+		return "timestamp"
+	case StringList:
+		return "list(string)"
+	case NumberList:
+		return "list(number)"
+	}
+	return "unknown"
+}
+
+// elem is the element type of a list type, or 0.
+func (t Type) elem() Type {
+	switch t {
+	case StringList:
+		return String
+	case NumberList:
+		return Number
+	}
+	return 0
+}
+
+// Record maps field names to values: bool, float64, string, time.Time,
+// []string or []float64 as declared in the schema. A missing field reads as
+// its type's zero value.
+type Record map[string]any
+
+// Program is a compiled filter.
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses src and checks it against fields, which maps each field
+// name to its type. The expression must be a bool.
+func Compile(src string, fields map[string]Type) (*Program, error) {
+	p := &parser{src: src, fields: fields}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	if root.typ() != Bool {
+		return nil, fmt.Errorf("filter must be a bool expression, got %s", root.typ())
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// String returns the source expression.
+func (p *Program) String() string { return p.src }
+
+// Match evaluates the filter against r.
+func (p *Program) Match(r Record) (bool, error) {
+	v, err := p.root.eval(r)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// ---------------------------------------------------------------------------
+// Lexer
+// ---------------------------------------------------------------------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	kind tokKind
+	text string // identifier, operator or decoded string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."}
+
+type parser struct {
+	src    string
+	fields map[string]Type
+	toks   []token
+	i      int
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("filter: %s at column %d", fmt.Sprintf(format, args...), tok.pos+1)
+}
+
+func (p *parser) lex() error {
+	s := p.src
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					switch s[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(s[i])
+					}
+					continue
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return p.errorf(token{pos: start}, "unterminated string")
+			}
+			i++
+			p.toks = append(p.toks, token{kind: tokString, text: b.String(), pos: start})
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.' || s[i] == 'e' || s[i] == 'E') {
+				i++
+			}
+			var n float64
+			if _, err := fmt.Sscan(s[start:i], &n); err != nil {
+				return p.errorf(token{pos: start}, "invalid number %q", s[start:i])
+			}
+			p.toks = append(p.toks, token{kind: tokNumber, text: s[start:i], num: n, pos: start})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(s) && (s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] >= '0' && s[i] <= '9') {
+				i++
+			}
+			p.toks = append(p.toks, token{kind: tokIdent, text: s[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf(token{pos: i}, "unexpected character %q", c)
+			}
+			p.toks = append(p.toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.toks = append(p.toks, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword text.
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokOp || t.kind == tokIdent) && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %s", text, t)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Parser and type checker
+// ---------------------------------------------------------------------------
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBool(tok, "||", left, right); err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseRel()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("&&") {
+			return left, nil
+		}
+		right, err := p.parseRel()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.wantBool(tok, "&&", left, right); err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) wantBool(tok token, op string, operands ...node) error {
+	for _, n := range operands {
+		if n.typ() != Bool {
+			return p.errorf(tok, "%s needs bool operands, got %s", op, n.typ())
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseRel() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	op := tok.text
+	switch {
+	case tok.kind == tokOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">="):
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != right.typ() {
+			return nil, p.errorf(tok, "cannot compare %s %s %s", left.typ(), op, right.typ())
+		}
+		ordered := op != "==" && op != "!="
+		if ordered && left.typ() != Number && left.typ() != String && left.typ() != Time {
+			return nil, p.errorf(tok, "%s is not ordered", left.typ())
+		}
+		if !ordered && left.typ().elem() != 0 {
+			return nil, p.errorf(tok, "cannot compare lists with %s; use in", op)
+		}
+		return &compare{op: op, left: left, right: right}, nil
+	case tok.kind == tokIdent && op == "in":
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if right.typ().elem() == 0 {
+			return nil, p.errorf(tok, "in needs a list on the right, got %s", right.typ())
+		}
+		if right.typ().elem() != left.typ() {
+			return nil, p.errorf(tok, "cannot look for %s in %s", left.typ(), right.typ())
+		}
+		return &member{item: left, list: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	switch {
+	case p.accept("!"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != Bool {
+			return nil, p.errorf(tok, "! needs a bool, got %s", x.typ())
+		}
+		return &not{x: x}, nil
+	case p.accept("-"):
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != Number {
+			return nil, p.errorf(tok, "- needs a number, got %s", x.typ())
+		}
+		return &negate{x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(".") {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, p.errorf(name, "expected method name, found %s", name)
+		}
+		args, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		if x, err = p.method(name, x, args); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) parseArgs() ([]node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	if p.accept(")") {
+		return args, nil
+	}
+	for {
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if p.accept(")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literal{t: Number, v: tok.num}, nil
+	case tokString:
+		return &literal{t: String, v: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literal{t: Bool, v: tok.text == "true"}, nil
+		}
+		if p.peek().kind == tokOp && p.peek().text == "(" {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return p.function(tok, args)
+		}
+		t, ok := p.fields[tok.text]
+		if !ok {
+			return nil, p.errorf(tok, "unknown field %q (fields: %s)", tok.text, p.fieldNames())
+		}
+		return &field{name: tok.text, t: t}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.parseList(tok)
+		}
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// parseList parses a list literal. Elements must be constants of one type.
+func (p *parser) parseList(open token) (node, error) {
+	var strs []string
+	var nums []float64
+	var elem Type
+	if !p.accept("]") {
+		for {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			lit, ok := constant(x)
+			if !ok {
+				return nil, p.errorf(open, "list elements must be literals")
+			}
+			if elem == 0 {
+				elem = lit.t
+			}
+			if lit.t != elem || (elem != String && elem != Number) {
+				return nil, p.errorf(open, "list elements must all be strings or all numbers")
+			}
+			if elem == String {
+				strs = append(strs, lit.v.(string))
+			} else {
+				nums = append(nums, lit.v.(float64))
+			}
+			if p.accept("]") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if elem == Number {
+		return &literal{t: NumberList, v: nums}, nil
+	}
+	return &literal{t: StringList, v: strs}, nil
+}
+
+// constant folds literals and negated number literals.
+func constant(n node) (*literal, bool) {
+	switch x := n.(type) {
+	case *literal:
+		return x, true
+	case *negate:
+		if lit, ok := x.x.(*literal); ok {
+			return &literal{t: Number, v: -lit.v.(float64)}, true
+		}
+	}
+	return nil, false
+}
+
+func (p *parser) fieldNames() string {
+	names := make([]string, 0, len(p.fields))
+	for n := range p.fields {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+func (p *parser) function(name token, args []node) (node, error) {
+	switch name.text {
+	case "size":
+		if len(args) != 1 || (args[0].typ() != String && args[0].typ().elem() == 0) {
+			return nil, p.errorf(name, "size takes one string or list")
+		}
+		return &call{t: Number, args: args, fn: func(v []any) (any, error) {
+			switch x := v[0].(type) {
+			case string:
+				return float64(len([]rune(x))), nil
+			case []string:
+				return float64(len(x)), nil
+			case []float64:
+				return float64(len(x)), nil
+			}
+			return 0.0, nil
+		}}, nil
+	case "timestamp":
+		if len(args) != 1 || args[0].typ() != String {
+			return nil, p.errorf(name, "timestamp takes one string")
+		}
+		if lit, ok := args[0].(*literal); ok {
+			t, err := parseTime(lit.v.(string))
+			if err != nil {
+				return nil, p.errorf(name, "%v", err)
+			}
+			return &literal{t: Time, v: t}, nil
+		}
+		return &call{t: Time, args: args, fn: func(v []any) (any, error) { return parseTime(v[0].(string)) }}, nil
+	}
+	return nil, p.errorf(name, "unknown function %q", name.text)
+}
+
+var methods = []string{"contains", "startsWith", "endsWith", "matches"}
+
+func (p *parser) method(name token, recv node, args []node) (node, error) {
+	if !slices.Contains(methods, name.text) {
+		return nil, p.errorf(name, "unknown method %q", name.text)
+	}
+	if recv.typ() != String {
+		return nil, p.errorf(name, "%s is a string method, called on %s", name.text, recv.typ())
+	}
+	if len(args) != 1 || args[0].typ() != String {
+		return nil, p.errorf(name, "%s takes one string", name.text)
+	}
+	all := []node{recv, args[0]}
+	var fn func(s, arg string) bool
+	switch name.text {
+	case "contains":
+		fn = strings.Contains
+	case "startsWith":
+		fn = strings.HasPrefix
+	case "endsWith":
+		fn = strings.HasSuffix
+	case "matches":
+		if lit, ok := args[0].(*literal); ok {
+			re, err := regexp.Compile(lit.v.(string))
+			if err != nil {
+				return nil, p.errorf(name, "invalid regexp: %v", err)
+			}
+			return &call{t: Bool, args: all, fn: func(v []any) (any, error) {
+				return re.MatchString(v[0].(string)), nil
+			}}, nil
+		}
+		return &call{t: Bool, args: all, fn: func(v []any) (any, error) {
+			re, err := regexp.Compile(v[1].(string))
+			if err != nil {
+				return nil, fmt.Errorf("invalid regexp: %w", err)
+			}
+			return re.MatchString(v[0].(string)), nil
+		}}, nil
+	}
+	return &call{t: Bool, args: all, fn: func(v []any) (any, error) {
+		return fn(v[0].(string), v[1].(string)), nil
+	}}, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates (UTC midnight).
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: want RFC 3339 or YYYY-MM-DD", s)
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = map[string]Type{
+	"kev":       Bool,
+	"epss":      Number,
+	"cvss":      Number,
+	"source":    String,
+	"title":     String,
+	"published": Time,
+	"cves":      StringList,
+	"scores":    NumberList,
+}
+
+func TestMatch(t *testing.T) {
+	rec := Record{
+		"kev":       true,
+		"epss":      0.97,
+		"cvss":      9.1,
+		"source":    "CISA",
+		"title":     "Ivanti Connect Secure auth bypass",
+		"published": time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+		"cves":      []string{"CVE-2023-46805", "CVE-2024-21887"},
+		"scores":    []float64{9.1, 8.2},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`kev == true && epss > 0.5 && source in ["CISA","MSRC"]`, true},
+		{`kev && epss > 0.99`, false},
+		{`!kev || cvss >= 9`, true},
+		{`!(kev && source == "MSRC")`, true},
+		{`kev && epss > 0.5 || false`, true},
+		{`false && epss > 0.5 || source == 'CISA'`, true}, // && binds tighter
+		{`"CVE-2024-21887" in cves`, true},
+		{`"CVE-2024-0001" in cves`, false},
+		{`9.1 in scores && -1 < 0`, true},
+		{`title.contains("Ivanti") && title.startsWith("Ivanti") && !title.endsWith("x")`, true},
+		{`title.matches("(?i)connect\\s+secure")`, true},
+		{`size(cves) == 2 && size(source) == 4`, true},
+		{`published >= timestamp("2024-01-01") && published < timestamp("2024-01-10T12:00:01Z")`, true},
+		{`source != "CISA"`, false},
+		{`source < "D"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Compile(tt.expr, testFields)
+			require.NoError(t, err)
+			got, err := p.Match(rec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatch_MissingFieldsAreZero(t *testing.T) {
+	p, err := Compile(`!kev && epss == 0 && source == "" && size(cves) == 0`, testFields)
+	require.NoError(t, err)
+	ok, err := p.Match(Record{"epss": nil})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestMatch_WrongFieldType(t *testing.T) {
+	p, err := Compile(`epss > 0.5`, testFields)
+	require.NoError(t, err)
+	_, err = p.Match(Record{"epss": "0.9"})
+	assert.ErrorContains(t, err, "field epss")
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`kve == true`, `unknown field "kve"`},
+		{`epss > "0.5"`, "cannot compare number > string"},
+		{`epss`, "must be a bool"},
+		{`epss && kev`, "&& needs bool operands"},
+		{`source in "CISA"`, "needs a list"},
+		{`1 in cves`, "cannot look for number"},
+		{`source in ["CISA", 1]`, "all be strings or all numbers"},
+		{`source in [title]`, "must be literals"},
+		{`cves == ["a"]`, "use in"},
+		{`kev < true`, "not ordered"},
+		{`(kev`, `expected ")"`},
+		{`kev &&`, "unexpected end of filter"},
+		{`kev kev`, `unexpected "kev" at column 5`},
+		{`source == "CISA`, "unterminated string"},
+		{`source = "CISA"`, `unexpected character '='`},
+		{`epss.contains("x")`, "string method"},
+		{`title.lower()`, `unknown method "lower"`},
+		{`title.matches("(")`, "invalid regexp"},
+		{`now() > published`, `unknown function "now"`},
+		{`published > timestamp("yesterday")`, "invalid timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr, testFields)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package fixes normalizes what sources say about fixed versions into one
+// FixedIn shape: the first fixed version of a product from NVD's CPE
+// configurations ("versionEndExcluding"), and vendor update identifiers
+// found in advisory text and reference links: Microsoft KB articles,
+// Linux distribution errata (RHSA, USN, DSA, ...) and "fixed in version X"
+// statements. A CVE or advisory with any of them, or with an NVD reference
+// tagged Patch, has a patch available.
+package fixes
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+
+	"tiger2go/internal/cveid"
+)
+
+// FixedIn is one fix. Version is set for fixed versions, Ref for update
+// identifiers; Product is set when the source names it.
+type FixedIn struct {
+	Product string `json:"product,omitempty"` // CPE "vendor:product", e.g. "ivanti:connect_secure"
+	Version string `json:"version,omitempty"` // first fixed version
+	Ref     string `json:"ref,omitempty"`     // KB article or errata ID, e.g. "KB5034441", "RHSA-2024:1234"
+}
+
+// Label is the fix as one string: "ivanti:connect_secure 22.7", "22.7" or
+// "KB5034441".
+func (f FixedIn) Label() string {
+	if f.Ref != "" {
+		return f.Ref
+	}
+	return strings.TrimSpace(f.Product + " " + f.Version)
+}
+
+var (
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+	kbPattern     = regexp.MustCompile(`\bKB\d{6,7}\b`)
+	errataPattern = regexp.MustCompile(`\b(?:RHSA-\d{4}:\d{4,5}|RLSA-\d{4}:\d{4,5}|ALSA-\d{4}:\d{4,5}|ELSA-\d{4}-\d{4,5}|` +
+		`USN-\d{3,5}-\d{1,2}|DSA-\d{4,5}-\d{1,2}|DLA-\d{3,5}-\d{1,2}|SUSE-SU-\d{4}:\d{3,5}-\d{1,2}|ALAS(?:2|2023)?-\d{4}-\d{3,5})\b`)
+	versionPattern = regexp.MustCompile(`(?i)\b(?:fixed|patched|resolved|addressed|upgrade|update)\s+(?:in|to)\s+` +
+		`(?:version\s+|release\s+|v)?(\d+(?:\.\d+){1,3}(?:[a-z]+\d+(?:\.\d+)*)?(?:-[a-z0-9.]+)?)\b`)
+)
+
+// FromText returns the KB articles, errata and fixed versions in texts, in
+// order of appearance. Texts may be HTML fragments or URLs.
+func FromText(texts ...string) []FixedIn {
+	var out []FixedIn
+	for _, t := range texts {
+		t = cveid.Normalize(tagPattern.ReplaceAllString(t, " "))
+		type match struct {
+			at  int
+			fix FixedIn
+		}
+		var ms []match
+		for _, loc := range kbPattern.FindAllStringIndex(t, -1) {
+			ms = append(ms, match{loc[0], FixedIn{Ref: t[loc[0]:loc[1]]}})
+		}
+		for _, loc := range errataPattern.FindAllStringIndex(t, -1) {
+			ms = append(ms, match{loc[0], FixedIn{Ref: t[loc[0]:loc[1]]}})
+		}
+		for _, m := range versionPattern.FindAllStringSubmatchIndex(t, -1) {
+			ms = append(ms, match{m[0], FixedIn{Version: t[m[2]:m[3]]}})
+		}
+		slices.SortStableFunc(ms, func(a, b match) int { return a.at - b.at })
+		for _, m := range ms {
+			out = Add(out, m.fix)
+		}
+	}
+	return out
+}
+
+// nvdConfig is the part of an NVD "configurations" entry FromNVD reads.
+type nvdConfig struct {
+	Nodes []struct {
+		CPEMatch []struct {
+			Vulnerable          bool   `json:"vulnerable"`
+			Criteria            string `json:"criteria"`
+			VersionEndExcluding string `json:"versionEndExcluding"`
+		} `json:"cpeMatch"`
+	} `json:"nodes"`
+}
+
+// FromNVD returns the first fixed versions in an NVD record's
+// "configurations": the versionEndExcluding of each vulnerable CPE match.
+// Malformed input yields nothing.
+func FromNVD(configurations []byte) []FixedIn {
+	var cfgs []nvdConfig
+	if err := json.Unmarshal(configurations, &cfgs); err != nil {
+		return nil
+	}
+	var out []FixedIn
+	for _, c := range cfgs {
+		for _, n := range c.Nodes {
+			for _, m := range n.CPEMatch {
+				if !m.Vulnerable || m.VersionEndExcluding == "" {
+					continue
+				}
+				out = Add(out, FixedIn{Product: cpeProduct(m.Criteria), Version: m.VersionEndExcluding})
+			}
+		}
+	}
+	return out
+}
+
+// cpeProduct returns "vendor:product" of a CPE 2.3 name, or "".
+func cpeProduct(cpe string) string {
+	parts := strings.Split(cpe, ":")
+	if len(parts) < 5 || parts[0] != "cpe" {
+		return ""
+	}
+	return parts[3] + ":" + parts[4]
+}
+
+// Add appends the fixes not already in list.
+func Add(list []FixedIn, fixes ...FixedIn) []FixedIn {
+	for _, f := range fixes {
+		if !slices.Contains(list, f) {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// Labels returns the label of each fix, for filter expressions and tables.
+func Labels(fixes []FixedIn) []string {
+	out := make([]string, len(fixes))
+	for i, f := range fixes {
+		out[i] = f.Label()
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package fixes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromText(t *testing.T) {
+	got := FromText(
+		"<p>Install KB5034441 or RHSA-2024:0123; Ubuntu users see USN-6543-1.</p>",
+		"The issue is fixed in version 22.7R2.3 and customers should upgrade to 10.2.1 or later. KB5034441 again.",
+		"https://access.redhat.com/errata/RHSA-2024:0123",
+	)
+	assert.Equal(t, []FixedIn{
+		{Ref: "KB5034441"},
+		{Ref: "RHSA-2024:0123"},
+		{Ref: "USN-6543-1"},
+		{Version: "22.7R2.3"},
+		{Version: "10.2.1"},
+	}, got, "repeats are dropped")
+
+	assert.Equal(t, []FixedIn{{Version: "3.0.7"}, {Ref: "DSA-5600-1"}}, FromText("Patched in v3.0.7 (DSA-5600-1)"))
+	assert.Empty(t, FromText("No fix is available. Version 2.1 is affected; see KB12 and the updated guidance."))
+}
+
+func TestFromNVD(t *testing.T) {
+	cfg := `[{"nodes":[{"operator":"OR","negate":false,"cpeMatch":[
+		{"vulnerable":true,"criteria":"cpe:2.3:a:ivanti:connect_secure:*:*:*:*:*:*:*:*","versionStartIncluding":"9.0","versionEndExcluding":"22.7"},
+		{"vulnerable":true,"criteria":"cpe:2.3:a:ivanti:policy_secure:*:*:*:*:*:*:*:*","versionEndIncluding":"22.6"},
+		{"vulnerable":false,"criteria":"cpe:2.3:o:linux:linux_kernel:-:*:*:*:*:*:*:*","versionEndExcluding":"6.1"},
+		{"vulnerable":true,"criteria":"cpe:2.3:a:ivanti:connect_secure:*:*:*:*:*:*:*:*","versionEndExcluding":"22.7"}
+	]}]}]`
+	assert.Equal(t, []FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}}, FromNVD([]byte(cfg)))
+	assert.Empty(t, FromNVD([]byte(`{"not":"a list"}`)))
+	assert.Empty(t, FromNVD(nil))
+}
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, []string{"ivanti:connect_secure 22.7", "3.0.7", "KB5034441"},
+		Labels([]FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}, {Version: "3.0.7"}, {Ref: "KB5034441"}}))
+}
@@ -0,0 +1,98 @@
+// Package healthcheck pings a dead man's switch (healthchecks.io, Dead Man's
+// Snitch, Cronitor and the like) when a scheduled run ends, so a run that
+// failed or never happened raises an alert in the external monitor.
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBodyBytes bounds the run report sent with a ping; healthchecks.io
+// keeps the first 100 KB of a ping body.
+const maxBodyBytes = 100 << 10
+
+// Pinger pings URL after a successful run and FailURL after a failed one.
+type Pinger struct {
+	URL     string
+	FailURL string // default URL + "/fail", the healthchecks.io convention
+	Client  *http.Client
+	// Backoff is the wait before each retry; a ping that still fails is
+	// given up on, the monitor then reports the run as missed.
+	Backoff []time.Duration
+}
+
+// New returns a Pinger for url, or nil when url is empty. A nil Pinger
+// does nothing.
+func New(url, failURL string, client *http.Client) *Pinger {
+	if url == "" && failURL == "" {
+		return nil
+	}
+	if failURL == "" {
+		failURL = strings.TrimRight(url, "/") + "/fail"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Pinger{URL: url, FailURL: failURL, Client: client, Backoff: []time.Duration{time.Second, 5 * time.Second}}
+}
+
+// Success reports a completed run, with body (the run report) attached.
+func (p *Pinger) Success(ctx context.Context, body []byte) error {
+	if p == nil || p.URL == "" {
+		return nil
+	}
+	return p.ping(ctx, p.URL, body)
+}
+
+// Fail reports a failed run, with body (the report or the error) attached.
+func (p *Pinger) Fail(ctx context.Context, body []byte) error {
+	if p == nil || p.FailURL == "" {
+		return nil
+	}
+	return p.ping(ctx, p.FailURL, body)
+}
+
+func (p *Pinger) ping(ctx context.Context, url string, body []byte) error {
+	if len(body) > maxBodyBytes {
+		body = body[:maxBodyBytes]
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = p.post(ctx, url, body); err == nil {
+			return nil
+		}
+		if attempt >= len(p.Backoff) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Backoff[attempt]):
+		}
+	}
+}
+
+func (p *Pinger) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tigerfetch/1.0 (+https://tigerblue.app)")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("healthcheck ping: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("healthcheck ping: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package healthcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	pings  []string // path: body
+	failOn int      // the first failOn requests get a 503
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failOn > 0 {
+		r.failOn--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	r.pings = append(r.pings, req.URL.Path+": "+string(body))
+}
+
+func TestPinger(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p := New(srv.URL+"/ping/abc/", "", nil)
+	require.NoError(t, p.Success(context.Background(), []byte(`{"ran":["nvd"]}`)))
+	require.NoError(t, p.Fail(context.Background(), []byte(`{"failed":{"nvd":"503"}}`)))
+	assert.Equal(t, []string{
+		`/ping/abc/: {"ran":["nvd"]}`,
+		`/ping/abc/fail: {"failed":{"nvd":"503"}}`,
+	}, rec.pings)
+}
+
+func TestPinger_FailURL(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p := New(srv.URL+"/snitch", srv.URL+"/snitch-failed", nil)
+	require.NoError(t, p.Fail(context.Background(), nil))
+	assert.Equal(t, []string{"/snitch-failed: "}, rec.pings)
+}
+
+func TestPinger_Retries(t *testing.T) {
+	rec := &recorder{failOn: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p := New(srv.URL, "", nil)
+	p.Backoff = []time.Duration{time.Millisecond, time.Millisecond}
+	require.NoError(t, p.Success(context.Background(), nil))
+	assert.Len(t, rec.pings, 1)
+
+	rec.failOn = 3
+	err := p.Success(context.Background(), nil)
+	assert.ErrorContains(t, err, "503")
+}
+
+func TestPinger_Disabled(t *testing.T) {
+	var p *Pinger = New("", "", nil)
+	assert.Nil(t, p)
+	assert.NoError(t, p.Success(context.Background(), nil))
+	assert.NoError(t, p.Fail(context.Background(), nil))
+}
@@ -0,0 +1,75 @@
+// Package httpclient holds the response-handling rules shared by every
+// upstream fetcher: bounded body reads and safe decoding of compressed bodies.
+package httpclient
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrResponseTooLarge is returned when a response body, after decompression,
+// exceeds its size limit.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// LimitFromMB converts a configured limit in megabytes to bytes, returning
+// def when the setting is zero or negative.
+func LimitFromMB(mb int, def int64) int64 {
+	if mb <= 0 {
+		return def
+	}
+	return int64(mb) << 20
+}
+
+// ReadBody reads resp.Body, failing with ErrResponseTooLarge once more than
+// limit bytes have been read. The limit applies to the decoded body, so a
+// small gzip or deflate payload that inflates past it (a decompression bomb)
+// is rejected without being fully expanded.
+//
+// net/http transparently decodes gzip it asked for itself; ReadBody decodes
+// any Content-Encoding the transport left in place (a server sending gzip
+// unasked, or deflate). Unknown encodings are an error.
+func ReadBody(resp *http.Response, limit int64) ([]byte, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if (encoding == "" || encoding == "identity") && resp.ContentLength > limit {
+		return nil, tooLarge(resp, limit)
+	}
+
+	var body io.Reader = resp.Body
+	switch encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip body: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		body = zr
+	case "deflate":
+		fr := flate.NewReader(resp.Body)
+		defer func() { _ = fr.Close() }()
+		body = fr
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, tooLarge(resp, limit)
+	}
+	return data, nil
+}
+
+func tooLarge(resp *http.Response, limit int64) error {
+	if resp.Request != nil && resp.Request.URL != nil {
+		return fmt.Errorf("%w: %s exceeded %d bytes", ErrResponseTooLarge, resp.Request.URL.Redacted(), limit)
+	}
+	return fmt.Errorf("%w: exceeded %d bytes", ErrResponseTooLarge, limit)
+}
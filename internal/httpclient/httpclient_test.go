@@ -0,0 +1,129 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func response(body []byte, encoding string) *http.Response {
+	h := http.Header{}
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: -1,
+	}
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestReadBody_Plain(t *testing.T) {
+	got, err := ReadBody(response([]byte("hello"), ""), 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+
+	_, err = ReadBody(response([]byte("hello!"), ""), 5)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestReadBody_ContentLengthRejectedEarly(t *testing.T) {
+	resp := response(nil, "")
+	resp.ContentLength = 1 << 30
+	_, err := ReadBody(resp, 1<<20)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestReadBody_Gzip(t *testing.T) {
+	got, err := ReadBody(response(gzipped(t, []byte(`{"ok":true}`)), "gzip"), 1<<10)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ok":true}`, string(got))
+}
+
+func TestReadBody_GzipBomb(t *testing.T) {
+	// 64 MB of zeros compresses to ~64 KB.
+	bomb := gzipped(t, make([]byte, 64<<20))
+	require.Less(t, len(bomb), 1<<20)
+
+	_, err := ReadBody(response(bomb, "gzip"), 1<<20)
+	assert.ErrorIs(t, err, ErrResponseTooLarge, "the limit applies to the inflated size")
+}
+
+func TestReadBody_Deflate(t *testing.T) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("deflated"))
+	require.NoError(t, fw.Close())
+
+	got, err := ReadBody(response(buf.Bytes(), "deflate"), 1<<10)
+	require.NoError(t, err)
+	assert.Equal(t, "deflated", string(got))
+}
+
+func TestReadBody_UnknownEncoding(t *testing.T) {
+	_, err := ReadBody(response([]byte("x"), "br"), 1<<10)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestReadBody_ErrorNamesURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("A"), 2048))
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/catalog.json")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	_, err = ReadBody(resp, 1024)
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	assert.Contains(t, err.Error(), "/catalog.json")
+}
+
+func TestLimitFromMB(t *testing.T) {
+	assert.Equal(t, int64(42), LimitFromMB(0, 42))
+	assert.Equal(t, int64(42), LimitFromMB(-1, 42))
+	assert.Equal(t, int64(3<<20), LimitFromMB(3, 42))
+}
+
+func FuzzReadBody(f *testing.F) {
+	f.Add([]byte("hello"), int64(5))
+	f.Add([]byte("hello"), int64(4))
+	f.Add([]byte{}, int64(0))
+	f.Fuzz(func(t *testing.T, data []byte, limit int64) {
+		if limit < 0 || limit > 1<<20 {
+			return
+		}
+		got, err := ReadBody(response(data, ""), limit)
+		if int64(len(data)) > limit {
+			if !errors.Is(err, ErrResponseTooLarge) {
+				t.Fatalf("want ErrResponseTooLarge for %d bytes over limit %d, got %v", len(data), limit, err)
+			}
+			return
+		}
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("ReadBody(%d bytes, %d) = %d bytes, %v", len(data), limit, len(got), err)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/metrics"
+)
+
+// Defaults for ResolverOptions.
+const (
+	DefaultDNSCacheTTL = 5 * time.Minute
+	DefaultDNSRetries  = 2
+	DefaultDNSStaleFor = time.Hour
+)
+
+const (
+	dnsRetryBackoff = 200 * time.Millisecond // doubled per attempt
+	dnsTimeout      = 5 * time.Second        // per lookup attempt
+)
+
+// ResolverOptions configure a Resolver.
+type ResolverOptions struct {
+	// CacheTTL is how long an answer is reused. Zero means
+	// DefaultDNSCacheTTL; negative means answers are not cached.
+	CacheTTL time.Duration
+	// Retries is the number of further attempts after a lookup fails
+	// with a temporary error. Zero means DefaultDNSRetries; negative
+	// means none.
+	Retries int
+	// Servers are DNS servers ("10.0.0.2", "1.1.1.1:53") asked, in order,
+	// when the system resolver fails.
+	Servers []string
+	// StaleFor is how long past its TTL an answer still stands in when
+	// every lookup fails. Zero means DefaultDNSStaleFor; negative means
+	// never.
+	StaleFor time.Duration
+}
+
+// Resolver resolves the hosts dialled by HTTP transports with a cache,
+// retries and secondary DNS servers, so repeated requests to an upstream
+// do not each wait for a lookup and a transient DNS failure does not fail
+// them. A nil *Resolver leaves resolution to the dialer.
+type Resolver struct {
+	opts      ResolverOptions
+	resolvers []lookuper // the system's, then one per server
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+// lookuper is the part of *net.Resolver a Resolver asks.
+type lookuper interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// NewResolver returns a Resolver with opts. Servers without a port use
+// port 53.
+func NewResolver(opts ResolverOptions) (*Resolver, error) {
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = DefaultDNSCacheTTL
+	}
+	if opts.Retries == 0 {
+		opts.Retries = DefaultDNSRetries
+	}
+	opts.Retries = max(opts.Retries, 0)
+	if opts.StaleFor == 0 {
+		opts.StaleFor = DefaultDNSStaleFor
+	}
+	opts.StaleFor = max(opts.StaleFor, 0)
+	r := &Resolver{opts: opts, resolvers: []lookuper{net.DefaultResolver}, cache: map[string]dnsEntry{}}
+	for _, s := range opts.Servers {
+		server := strings.TrimSpace(s)
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		host, _, _ := net.SplitHostPort(server)
+		if _, err := netip.ParseAddr(host); err != nil {
+			return nil, fmt.Errorf("DNS server %q must be an IP address", s)
+		}
+		r.resolvers = append(r.resolvers, &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		})
+	}
+	return r, nil
+}
+
+// Transport returns next with its dials resolved by r, when next is an
+// *http.Transport (cloned, so next itself is unchanged); other transports
+// are returned as is. A nil Resolver returns next as is.
+func (r *Resolver) Transport(next http.RoundTripper) http.RoundTripper {
+	t, ok := next.(*http.Transport)
+	if r == nil || !ok {
+		return next
+	}
+	t = t.Clone()
+	t.DialContext = r.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return t
+}
+
+// DialContext returns a dial function that resolves the host through r and
+// dials its addresses with d in turn, IPv4 first, until one connects. A nil
+// Resolver returns d.DialContext.
+func (r *Resolver) DialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if r == nil {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return d.DialContext(ctx, network, addr)
+		}
+		addrs, err := r.Lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range addrs {
+			if network == "tcp4" && !ip.Is4() || network == "tcp6" && ip.Is4() {
+				continue
+			}
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("dial %s: no %s address for %s", network, network, host)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// Lookup returns the addresses of host, IPv4 first: from the cache while
+// fresh, else from the system resolver and then each secondary server,
+// retried while the failure is temporary. When all of that fails, an
+// answer up to StaleFor past its TTL is used.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	now := time.Now()
+	r.mu.Lock()
+	e, cached := r.cache[host]
+	r.mu.Unlock()
+	if cached && now.Before(e.expires) {
+		metrics.DNSLookups.WithLabelValues("cached").Inc()
+		return e.addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err == nil {
+		metrics.DNSLookups.WithLabelValues("resolved").Inc()
+		if r.opts.CacheTTL > 0 {
+			r.mu.Lock()
+			r.cache[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(r.opts.CacheTTL)}
+			r.mu.Unlock()
+		}
+		return addrs, nil
+	}
+	if cached && now.Before(e.expires.Add(r.opts.StaleFor)) && ctx.Err() == nil {
+		slog.Warn("DNS lookup failed, using the last answer", "host", host, "error", err)
+		metrics.DNSLookups.WithLabelValues("stale").Inc()
+		return e.addrs, nil
+	}
+	metrics.DNSLookups.WithLabelValues("error").Inc()
+	return nil, err
+}
+
+func (r *Resolver) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	var lastErr error
+	backoff := dnsRetryBackoff
+	for attempt := 0; attempt <= r.opts.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+		notFound := true
+		for _, res := range r.resolvers {
+			lctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+			addrs, err := res.LookupNetIP(lctx, "ip", host)
+			cancel()
+			if err == nil && len(addrs) > 0 {
+				return sortAddrs(addrs), nil
+			}
+			if err == nil {
+				err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				notFound = false
+			}
+		}
+		if notFound {
+			return nil, lastErr // the name does not exist; asking again will not change that
+		}
+	}
+	return nil, lastErr
+}
+
+// sortAddrs puts IPv4 addresses first, unmapped, keeping the resolver's
+// order otherwise.
+func sortAddrs(addrs []netip.Addr) []netip.Addr {
+	out := make([]netip.Addr, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.Unmap()
+	}
+	slices.SortStableFunc(out, func(a, b netip.Addr) int {
+		switch {
+		case a.Is4() == b.Is4():
+			return 0
+		case a.Is4():
+			return -1
+		}
+		return 1
+	})
+	return out
+}
@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDNS answers lookups from a script, one entry per call; past its
+// end it repeats the last.
+type fakeDNS struct {
+	answers []fakeAnswer
+	calls   int
+}
+
+type fakeAnswer struct {
+	addrs []netip.Addr
+	err   error
+}
+
+func (f *fakeDNS) LookupNetIP(context.Context, string, string) ([]netip.Addr, error) {
+	a := f.answers[min(f.calls, len(f.answers)-1)]
+	f.calls++
+	return a.addrs, a.err
+}
+
+var (
+	errTemporary  = &net.DNSError{Err: "server misbehaving", Name: "api.example", IsTemporary: true}
+	errNoSuchHost = &net.DNSError{Err: "no such host", Name: "api.example", IsNotFound: true}
+	loopback      = []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+)
+
+func testResolver(t *testing.T, opts ResolverOptions, lookups ...lookuper) *Resolver {
+	t.Helper()
+	r, err := NewResolver(opts)
+	require.NoError(t, err)
+	r.resolvers = lookups
+	return r
+}
+
+func TestResolver_Caches(t *testing.T) {
+	dns := &fakeDNS{answers: []fakeAnswer{{addrs: loopback}}}
+	r := testResolver(t, ResolverOptions{}, dns)
+	for range 3 {
+		addrs, err := r.Lookup(context.Background(), "API.example.")
+		require.NoError(t, err)
+		assert.Equal(t, loopback, addrs)
+	}
+	assert.Equal(t, 1, dns.calls)
+
+	r = testResolver(t, ResolverOptions{CacheTTL: -1}, dns)
+	for range 2 {
+		_, err := r.Lookup(context.Background(), "api.example")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, dns.calls, "no cache")
+}
+
+func TestResolver_RetriesAndFallsBack(t *testing.T) {
+	primary := &fakeDNS{answers: []fakeAnswer{{err: errTemporary}}}
+	secondary := &fakeDNS{answers: []fakeAnswer{{err: errTemporary}, {addrs: loopback}}}
+	r := testResolver(t, ResolverOptions{}, primary, secondary)
+	addrs, err := r.Lookup(context.Background(), "api.example")
+	require.NoError(t, err)
+	assert.Equal(t, loopback, addrs)
+	assert.Equal(t, 2, primary.calls, "the system resolver first on each attempt")
+	assert.Equal(t, 2, secondary.calls)
+
+	primary = &fakeDNS{answers: []fakeAnswer{{err: errTemporary}}}
+	r = testResolver(t, ResolverOptions{Retries: -1}, primary)
+	_, err = r.Lookup(context.Background(), "api.example")
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 1, primary.calls)
+}
+
+func TestResolver_NotFoundIsNotRetried(t *testing.T) {
+	dns := &fakeDNS{answers: []fakeAnswer{{err: errNoSuchHost}}}
+	r := testResolver(t, ResolverOptions{}, dns)
+	_, err := r.Lookup(context.Background(), "api.example")
+	var dnsErr *net.DNSError
+	require.True(t, errors.As(err, &dnsErr))
+	assert.True(t, dnsErr.IsNotFound)
+	assert.Equal(t, 1, dns.calls)
+}
+
+func TestResolver_Stale(t *testing.T) {
+	dns := &fakeDNS{answers: []fakeAnswer{{addrs: loopback}, {err: errTemporary}}}
+	r := testResolver(t, ResolverOptions{Retries: -1}, dns)
+	_, err := r.Lookup(context.Background(), "api.example")
+	require.NoError(t, err)
+
+	r.cache["api.example"] = dnsEntry{addrs: loopback, expires: time.Now().Add(-time.Minute)}
+	addrs, err := r.Lookup(context.Background(), "api.example")
+	require.NoError(t, err, "the expired answer stands in")
+	assert.Equal(t, loopback, addrs)
+
+	r.cache["api.example"] = dnsEntry{addrs: loopback, expires: time.Now().Add(-2 * time.Hour)}
+	_, err = r.Lookup(context.Background(), "api.example")
+	assert.Error(t, err, "too old")
+}
+
+func TestResolver_Transport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Host)
+		assert.Equal(t, "api.example", host, "the name is kept for the Host header and TLS")
+	}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	dns := &fakeDNS{answers: []fakeAnswer{{addrs: append([]netip.Addr{netip.MustParseAddr("::1")}, loopback...)}}}
+	r := testResolver(t, ResolverOptions{}, dns)
+	base := &http.Transport{}
+	client := &http.Client{Transport: r.Transport(base)}
+	assert.Nil(t, base.DialContext, "the transport given is not changed")
+
+	resp, err := client.Get("http://api.example:" + port + "/")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, dns.calls)
+
+	var nilResolver *Resolver
+	assert.Same(t, base, nilResolver.Transport(base))
+}
+
+func TestNewResolver_Servers(t *testing.T) {
+	r, err := NewResolver(ResolverOptions{Servers: []string{"1.1.1.1", "[2606:4700::1111]:53"}})
+	require.NoError(t, err)
+	assert.Len(t, r.resolvers, 3)
+
+	_, err = NewResolver(ResolverOptions{Servers: []string{"dns.example"}})
+	assert.Error(t, err)
+}
+
+func TestSortAddrs(t *testing.T) {
+	in := []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("::ffff:10.0.0.1"), netip.MustParseAddr("10.0.0.2")}
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("::1")}, sortAddrs(in))
+}
@@ -0,0 +1,258 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/metrics"
+)
+
+// ErrRateLimited is returned for requests a host's rate limit would hold
+// back longer than the Throttle allows.
+var ErrRateLimited = errors.New("upstream rate limited")
+
+// DefaultMaxRateLimitWait is the longest a Throttle holds a request back
+// unless told otherwise.
+const DefaultMaxRateLimitWait = time.Minute
+
+const (
+	maxThrottleRetries = 2           // retries of a rate-limited GET within the wait allowed
+	lowRemainingShare  = 10          // pace once a tenth of the window's requests remain
+	lowRemaining       = 5           // or this many, when the limit is not sent
+	firstBackoff       = time.Second // after a 429 that says nothing about when to retry
+	unixResetAfter     = 1e9         // larger X-RateLimit-Reset values are Unix times, as GitHub sends them
+)
+
+// Throttle paces requests per host by what its responses say of its rate
+// limit: Retry-After on 429 and 503, and the remaining requests and reset
+// of X-RateLimit-* (GitHub, FIRST) or RateLimit-* headers. A host that
+// stops answering with headers is not slowed down at all, so there is no
+// static sleep to tune. A nil *Throttle does nothing, so components can
+// take one unconditionally.
+type Throttle struct {
+	maxWait time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimit
+}
+
+type hostLimit struct {
+	next     time.Time     // earliest next request
+	interval time.Duration // between requests while few remain in the window
+	backoff  time.Duration // after a 429 without headers, doubled for each in a row
+}
+
+// NewThrottle returns a Throttle that holds requests back at most maxWait
+// (DefaultMaxRateLimitWait when zero or negative); requests that would
+// wait longer fail with ErrRateLimited.
+func NewThrottle(maxWait time.Duration) *Throttle {
+	if maxWait <= 0 {
+		maxWait = DefaultMaxRateLimitWait
+	}
+	return &Throttle{maxWait: maxWait, hosts: map[string]*hostLimit{}}
+}
+
+// Transport paces requests through next (http.DefaultTransport when nil).
+// A GET or HEAD answered 429, or 503 with Retry-After, is retried up to
+// twice once the host allows it, if that is within the wait allowed. A
+// transport that is already paced by t, directly or under wrappers with an
+// Unwrap method, is returned unchanged. A nil Throttle returns next as is.
+func (t *Throttle) Transport(next http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for rt := next; rt != nil; {
+		if tt, ok := rt.(*throttleTransport); ok && tt.throttle == t {
+			return next
+		}
+		u, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		rt = u.Unwrap()
+	}
+	return &throttleTransport{throttle: t, next: next}
+}
+
+type throttleTransport struct {
+	throttle *Throttle
+	next     http.RoundTripper
+}
+
+func (tt *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := tt.throttle
+	host := strings.ToLower(req.URL.Hostname())
+	if err := t.wait(req.Context(), host); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := tt.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !t.observe(host, resp) || attempt == maxThrottleRetries || !replayable(req) || t.delay(host) > t.maxWait {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+		metrics.UpstreamThrottleEvents.WithLabelValues(host, "retried").Inc()
+		if err := t.wait(req.Context(), host); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Unwrap returns the wrapped transport.
+func (tt *throttleTransport) Unwrap() http.RoundTripper { return tt.next }
+
+// replayable reports whether req can be sent again as is.
+func replayable(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+}
+
+func (t *Throttle) host(name string) *hostLimit {
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostLimit{}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// delay is how long a request to host would wait now.
+func (t *Throttle) delay(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return max(time.Until(t.host(host).next), 0)
+}
+
+// wait reserves the next slot of host and sleeps until it, or fails with
+// ErrRateLimited when that is more than the wait allowed.
+func (t *Throttle) wait(ctx context.Context, host string) error {
+	t.mu.Lock()
+	h := t.host(host)
+	now := time.Now()
+	at := now
+	if h.next.After(now) {
+		at = h.next
+	}
+	d := at.Sub(now)
+	if d > t.maxWait {
+		t.mu.Unlock()
+		metrics.UpstreamThrottleEvents.WithLabelValues(host, "refused").Inc()
+		return fmt.Errorf("%w: %s for another %s", ErrRateLimited, host, d.Round(time.Second))
+	}
+	h.next = at.Add(h.interval)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	metrics.UpstreamThrottleWait.WithLabelValues(host).Add(d.Seconds())
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe updates host's limit from resp and reports whether resp was a
+// refusal worth retrying.
+func (t *Throttle) observe(host string, resp *http.Response) bool {
+	now := time.Now()
+	retryAfter := RetryAfter(resp.Header.Get("Retry-After"), now)
+	remaining, limit, reset, ok := rateLimit(resp.Header, now)
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable && retryAfter > 0
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.host(host)
+	var until time.Time
+	switch {
+	case limited && retryAfter > 0:
+		until = now.Add(retryAfter)
+	case ok && remaining == 0:
+		until = now.Add(reset)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		h.backoff = min(max(2*h.backoff, firstBackoff), t.maxWait)
+		until = now.Add(h.backoff)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		h.backoff = 0
+	}
+	h.interval = 0
+	if ok && remaining > 0 && reset > 0 && (limit > 0 && remaining*lowRemainingShare <= limit || limit <= 0 && remaining <= lowRemaining) {
+		// Spread what is left over the window, never pausing longer than
+		// a request may wait
+		h.interval = min(reset/time.Duration(remaining), t.maxWait)
+	}
+	if until.After(h.next) {
+		h.next = until
+	}
+	return limited
+}
+
+// rateLimit reads the requests remaining in the current window, its limit
+// (0 when not sent) and the time until it resets from X-RateLimit-* or the
+// IETF draft's RateLimit-* headers. Reset is in seconds, or a Unix time.
+func rateLimit(h http.Header, now time.Time) (remaining, limit int, reset time.Duration, ok bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		rem, err := leadingInt(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		limit, _ = leadingInt(h.Get(prefix + "Limit"))
+		if r, err := leadingInt(h.Get(prefix + "Reset")); err == nil {
+			if r > unixResetAfter {
+				reset = time.Unix(int64(r), 0).Sub(now)
+			} else {
+				reset = time.Duration(r) * time.Second
+			}
+		}
+		return max(rem, 0), limit, max(reset, 0), true
+	}
+	return 0, 0, 0, false
+}
+
+// leadingInt parses the number a header value starts with, ignoring the
+// quota policies ("100, 100;w=60") some servers append.
+func leadingInt(v string) (int, error) {
+	v, _, _ = strings.Cut(v, ",")
+	v, _, _ = strings.Cut(v, ";")
+	return strconv.Atoi(strings.TrimSpace(v))
+}
+
+// RetryAfter parses a Retry-After header given either as delay-seconds or
+// as an HTTP date. It returns zero for missing or invalid values.
+func RetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
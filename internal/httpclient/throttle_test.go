@@ -0,0 +1,159 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, error) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestThrottle_RetriesAfterRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		assert.GreaterOrEqual(t, time.Since(first), time.Second)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewThrottle(time.Minute).Transport(nil)}
+	status, err := get(t, client, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestThrottle_RefusesLongWaits(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewThrottle(time.Second).Transport(nil)}
+	status, err := get(t, client, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, "the last request of the window is answered")
+
+	_, err = get(t, client, srv.URL)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.EqualValues(t, 1, calls.Load(), "no request until the window resets")
+}
+
+func TestThrottle_GivesUpOnRepeated429(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	// Backoffs after a bare 429 are capped at the wait allowed
+	client := &http.Client{Transport: NewThrottle(20 * time.Millisecond).Transport(nil)}
+	status, err := get(t, client, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.EqualValues(t, 1+maxThrottleRetries, calls.Load())
+
+	calls.Store(0)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, http.NoBody)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.EqualValues(t, 1, calls.Load(), "a POST is not sent twice")
+}
+
+func TestThrottle_Observe(t *testing.T) {
+	th := NewThrottle(10 * time.Second)
+	answer := func(status int, headers ...string) bool {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		for i := 0; i < len(headers); i += 2 {
+			resp.Header.Set(headers[i], headers[i+1])
+		}
+		return th.observe("api.example", resp)
+	}
+	h := func() *hostLimit { return th.hosts["api.example"] }
+
+	assert.False(t, answer(http.StatusOK, "X-RateLimit-Limit", "5000", "X-RateLimit-Remaining", "4000", "X-RateLimit-Reset", "3600"))
+	assert.Zero(t, h().interval, "plenty left")
+	assert.Zero(t, th.delay("api.example"))
+
+	answer(http.StatusOK, "X-RateLimit-Limit", "100", "X-RateLimit-Remaining", "10", "X-RateLimit-Reset", "20")
+	assert.Equal(t, 2*time.Second, h().interval, "the rest spread over the window")
+	answer(http.StatusOK, "RateLimit-Limit", "100, 100;w=60", "RateLimit-Remaining", "1", "RateLimit-Reset", "60")
+	assert.Equal(t, 10*time.Second, h().interval, "never longer than a request may wait")
+	answer(http.StatusOK)
+	assert.Zero(t, h().interval, "no headers, no pacing")
+
+	assert.True(t, answer(http.StatusTooManyRequests))
+	assert.Equal(t, time.Second, h().backoff)
+	assert.True(t, answer(http.StatusTooManyRequests))
+	assert.Equal(t, 2*time.Second, h().backoff)
+	answer(http.StatusOK)
+	assert.Zero(t, h().backoff)
+
+	assert.False(t, answer(http.StatusServiceUnavailable), "a 503 without Retry-After is an outage")
+	assert.True(t, answer(http.StatusServiceUnavailable, "Retry-After", "5"))
+	assert.InDelta(t, 5*time.Second, th.delay("api.example"), float64(100*time.Millisecond))
+}
+
+func TestThrottle_Transport(t *testing.T) {
+	th := NewThrottle(0)
+	assert.Equal(t, DefaultMaxRateLimitWait, th.maxWait)
+	inner := th.Transport(nil)
+	assert.Same(t, inner, th.Transport(inner))
+
+	var nilThrottle *Throttle
+	assert.Equal(t, http.DefaultTransport, nilThrottle.Transport(http.DefaultTransport))
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", "60")
+	h.Set("X-RateLimit-Remaining", "3")
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(90*time.Second).Unix(), 10))
+	remaining, limit, reset, ok := rateLimit(h, now)
+	assert.True(t, ok)
+	assert.Equal(t, 3, remaining)
+	assert.Equal(t, 60, limit)
+	assert.Equal(t, 90*time.Second, reset, "Unix time, as GitHub sends it")
+
+	_, _, _, ok = rateLimit(http.Header{}, now)
+	assert.False(t, ok)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, RetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, RetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, RetryAfter("", now))
+	assert.Zero(t, RetryAfter("-5", now))
+	assert.Zero(t, RetryAfter("soon", now))
+	assert.Zero(t, RetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now), "dates in the past mean retry now")
+}
@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for TransportOptions.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 16 // net/http keeps 2, closing connections a concurrent backfill would reuse
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultTLSSessionCache     = 256
+)
+
+// TransportOptions size the connection pool of a transport. Upstreams are
+// few and asked thousands of times in a backfill, so idle connections,
+// HTTP/2 and TLS session tickets are what keep requests from paying a
+// handshake each.
+type TransportOptions struct {
+	// MaxIdleConns bounds idle connections across hosts. Zero means
+	// DefaultMaxIdleConns.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost bounds idle connections to one host. Zero means
+	// DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections idle this long. Zero means
+	// DefaultIdleConnTimeout.
+	IdleConnTimeout time.Duration
+	// TLSSessionCache is the number of TLS sessions kept for resumption,
+	// so a new connection to a known host skips the full handshake. Zero
+	// means DefaultTLSSessionCache; negative means none.
+	TLSSessionCache int
+}
+
+func (o TransportOptions) withDefaults() TransportOptions {
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost <= 0 {
+		o.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout <= 0 {
+		o.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if o.TLSSessionCache == 0 {
+		o.TLSSessionCache = DefaultTLSSessionCache
+	}
+	return o
+}
+
+// NewTransport returns a transport like http.DefaultTransport, proxies
+// from the environment and HTTP/2 included, with its pool sized by opts
+// and a TLS session cache. Build one per process and share it: each
+// transport has its own pool, so a client with a transport of its own
+// reconnects to hosts the others are already connected to.
+func NewTransport(opts TransportOptions) *http.Transport {
+	t := newTransport(opts, &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	t.Proxy = http.ProxyFromEnvironment
+	return t
+}
+
+func newTransport(opts TransportOptions, dialer *net.Dialer) *http.Transport {
+	opts = opts.withDefaults()
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if opts.TLSSessionCache > 0 {
+		t.TLSClientConfig = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(opts.TLSSessionCache)}
+	}
+	return t
+}
+
+// guardedKey identifies the transports URLPolicy clients share.
+type guardedKey struct {
+	resolver *Resolver
+	pool     TransportOptions
+}
+
+var guarded = struct {
+	sync.Mutex
+	m map[guardedKey]*http.Transport
+}{m: map[guardedKey]*http.Transport{}}
+
+// guardedTransport returns the transport dialling only public addresses
+// for resolver and pool, built on first use and shared by every client of
+// a policy that blocks private networks.
+func guardedTransport(resolver *Resolver, pool TransportOptions) *http.Transport {
+	key := guardedKey{resolver, pool}
+	guarded.Lock()
+	defer guarded.Unlock()
+	if t, ok := guarded.m[key]; ok {
+		return t
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   blockPrivateControl,
+	}
+	// No proxy: the dial check would see the proxy's address, not the
+	// target's.
+	t := newTransport(pool, dialer)
+	t.DialContext = resolver.DialContext(dialer)
+	guarded.m[key] = t
+	return t
+}
@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tlsServer is an HTTP/2 test server counting the connections it accepts.
+func tlsServer(t testing.TB) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+// trusting makes t trust srv's certificate.
+func trusting(t *http.Transport, srv *httptest.Server) *http.Transport {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	t.TLSClientConfig.RootCAs = roots
+	return t
+}
+
+func fetch(t testing.TB, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestNewTransport_ReusesHTTP2Connection(t *testing.T) {
+	srv, conns := tlsServer(t)
+	client := &http.Client{Transport: trusting(NewTransport(TransportOptions{}), srv)}
+
+	var reused int
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			reused++
+		}
+	}}
+	for range 5 {
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(t.Context(), trace), http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, 2, resp.ProtoMajor)
+	}
+	assert.Equal(t, 4, reused)
+	assert.EqualValues(t, 1, conns.Load())
+}
+
+func TestNewTransport_ResumesTLSSessions(t *testing.T) {
+	srv, conns := tlsServer(t)
+	tr := trusting(NewTransport(TransportOptions{}), srv)
+	tr.DisableKeepAlives = true // a new connection per request
+	client := &http.Client{Transport: tr}
+
+	assert.False(t, fetch(t, client, srv.URL).TLS.DidResume)
+	assert.True(t, fetch(t, client, srv.URL).TLS.DidResume, "the second handshake resumes the first session")
+	assert.EqualValues(t, 2, conns.Load())
+}
+
+func TestTransportOptions_Defaults(t *testing.T) {
+	tr := NewTransport(TransportOptions{MaxIdleConnsPerHost: 4})
+	assert.Equal(t, DefaultMaxIdleConns, tr.MaxIdleConns)
+	assert.Equal(t, 4, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, DefaultIdleConnTimeout, tr.IdleConnTimeout)
+	assert.True(t, tr.ForceAttemptHTTP2)
+	assert.NotNil(t, tr.Proxy)
+	require.NotNil(t, tr.TLSClientConfig)
+	assert.NotNil(t, tr.TLSClientConfig.ClientSessionCache)
+
+	assert.Nil(t, NewTransport(TransportOptions{TLSSessionCache: -1}).TLSClientConfig)
+}
+
+func TestURLPolicy_SharesTransport(t *testing.T) {
+	p := URLPolicy{BlockPrivate: true}
+	a, b := p.Client(0), p.Client(time.Minute)
+	assert.Same(t, a.Transport, b.Transport, "one pool for every client of the policy")
+	assert.Nil(t, a.Transport.(*http.Transport).Proxy)
+
+	p.Pool = TransportOptions{MaxIdleConnsPerHost: 4}
+	assert.NotSame(t, a.Transport, p.Client(0).Transport)
+	assert.Equal(t, 4, p.Client(0).Transport.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+// BenchmarkTransport compares requests over one shared transport with
+// requests that open a connection each, with and without TLS session
+// resumption; conns/op is the TLS handshakes paid per request.
+func BenchmarkTransport(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		keepAlive bool
+		sessions  int
+	}{
+		{"reused", true, 0},
+		{"resumed", false, 0},
+		{"full_handshake", false, -1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			srv, conns := tlsServer(b)
+			tr := trusting(NewTransport(TransportOptions{TLSSessionCache: bc.sessions}), srv)
+			tr.DisableKeepAlives = !bc.keepAlive
+			client := &http.Client{Transport: tr}
+			fetch(b, client, srv.URL)
+			conns.Store(0)
+			for b.Loop() {
+				fetch(b, client, srv.URL)
+			}
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
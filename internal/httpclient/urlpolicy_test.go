@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckURL_Schemes(t *testing.T) {
+	var p URLPolicy
+	assert.NoError(t, p.CheckURL("https://example.com/feed"))
+	assert.NoError(t, p.CheckURL("http://example.com/feed"))
+	assert.ErrorIs(t, p.CheckURL("file:///etc/passwd"), ErrURLNotAllowed)
+	assert.ErrorIs(t, p.CheckURL("gopher://example.com/"), ErrURLNotAllowed)
+	assert.ErrorIs(t, p.CheckURL("https:///nohost"), ErrURLNotAllowed)
+
+	p.AllowedSchemes = []string{"https"}
+	assert.ErrorIs(t, p.CheckURL("http://example.com/feed"), ErrURLNotAllowed)
+}
+
+func TestCheckURL_AllowedHosts(t *testing.T) {
+	p := URLPolicy{AllowedHosts: []string{"feeds.example.com", ".cisa.gov"}}
+	assert.NoError(t, p.CheckURL("https://feeds.example.com/rss"))
+	assert.NoError(t, p.CheckURL("https://www.cisa.gov/feed.xml"))
+	assert.NoError(t, p.CheckURL("https://cisa.gov/feed.xml"))
+	assert.ErrorIs(t, p.CheckURL("https://evil.example.com/rss"), ErrURLNotAllowed)
+	assert.ErrorIs(t, p.CheckURL("https://notcisa.gov/rss"), ErrURLNotAllowed)
+}
+
+func TestCheckURL_PrivateLiterals(t *testing.T) {
+	p := URLPolicy{BlockPrivate: true}
+	for _, u := range []string{
+		"http://127.0.0.1/",
+		"http://10.1.2.3/",
+		"http://192.168.0.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://100.64.0.1/",
+		"http://[::1]/",
+		"http://[fd00::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://0.0.0.0/",
+	} {
+		assert.ErrorIs(t, p.CheckURL(u), ErrURLNotAllowed, u)
+	}
+	assert.NoError(t, p.CheckURL("http://93.184.216.34/"))
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	assert.True(t, isBlockedIP(net.ParseIP("172.16.5.4")))
+	assert.True(t, isBlockedIP(net.ParseIP("fe80::1")))
+	assert.False(t, isBlockedIP(net.ParseIP("8.8.8.8")))
+	assert.False(t, isBlockedIP(net.ParseIP("2606:4700::1111")))
+}
+
+func TestClient_BlockPrivateAtDial(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// "localhost" passes the literal check and is only caught after resolution.
+	url := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	_, err := URLPolicy{BlockPrivate: true}.Client(5 * time.Second).Get(url)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrURLNotAllowed)
+
+	resp, err := URLPolicy{}.Client(5 * time.Second).Get(url)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
+
+func TestClient_RedirectLimitAndTargets(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, ts.URL+"/loop", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/once":
+			http.Redirect(w, r, ts.URL+"/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := URLPolicy{MaxRedirects: 3}.Client(5 * time.Second).Get(ts.URL + "/loop")
+	assert.ErrorIs(t, err, ErrURLNotAllowed)
+
+	_, err = URLPolicy{}.Client(5 * time.Second).Get(ts.URL + "/file")
+	assert.ErrorIs(t, err, ErrURLNotAllowed)
+
+	_, err = URLPolicy{MaxRedirects: -1}.Client(5 * time.Second).Get(ts.URL + "/once")
+	assert.ErrorIs(t, err, ErrURLNotAllowed, "negative MaxRedirects disables redirects")
+
+	resp, err := URLPolicy{}.Client(5 * time.Second).Get(ts.URL + "/once")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
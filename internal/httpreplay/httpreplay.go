@@ -0,0 +1,171 @@
+// Package httpreplay records HTTP interactions to a JSON cassette and replays
+// them later, so tests against NVD, KEV, EPSS and feeds can run offline and
+// deterministically, and so a misbehaving upstream response can be captured
+// and attached to a bug report.
+//
+// Only the request method and URL are recorded; request headers (which carry
+// API keys) are never written. Set-Cookie is dropped from responses.
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNoInteraction is returned in replay mode when the cassette has no
+// remaining interaction matching a request.
+var ErrNoInteraction = errors.New("httpreplay: no recorded interaction")
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Cassette is the on-disk fixture format.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport is an http.RoundTripper that either records through to a real
+// transport or replays from a cassette.
+type Transport struct {
+	path   string
+	next   http.RoundTripper // nil in replay mode
+	mu     sync.Mutex
+	tape   Cassette
+	played map[int]bool
+}
+
+// NewRecorder returns a Transport that forwards requests to next (or
+// http.DefaultTransport when nil) and writes every interaction to path.
+// An existing cassette at path is overwritten.
+func NewRecorder(path string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{path: path, next: next}
+}
+
+// NewReplayer loads the cassette at path and serves responses from it
+// without touching the network. Interactions with the same method and URL
+// are replayed in recorded order.
+func NewReplayer(path string) (*Transport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	t := &Transport{path: path, played: make(map[int]bool)}
+	if err := json.Unmarshal(data, &t.tape); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Client returns an http.Client using this transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Interactions returns a copy of the interactions recorded or loaded so far.
+func (t *Transport) Interactions() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Interaction(nil), t.tape.Interactions...)
+}
+
+// Unwrap returns the transport a recorder forwards to; nil when replaying.
+func (t *Transport) Unwrap() http.RoundTripper { return t.next }
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.next == nil {
+		return t.replay(req)
+	}
+	return t.record(req)
+}
+
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	url := req.URL.String()
+	for i, in := range t.tape.Interactions {
+		if t.played[i] || in.Method != req.Method || in.URL != url {
+			continue
+		}
+		t.played[i] = true
+		header := in.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+			StatusCode:    in.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(in.Body))),
+			ContentLength: int64(len(in.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, url)
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tape.Interactions = append(t.tape.Interactions, Interaction{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       string(body),
+	})
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// save writes the cassette atomically. Callers hold t.mu.
+func (t *Transport) save() error {
+	data, err := json.MarshalIndent(t.tape, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("create cassette dir: %w", err)
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
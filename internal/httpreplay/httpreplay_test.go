@@ -0,0 +1,87 @@
+package httpreplay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = io.WriteString(w, `{"page":`+r.URL.Query().Get("p")+`}`)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec := NewRecorder(path, nil)
+	for _, p := range []string{"1", "2"} {
+		req, _ := http.NewRequest("GET", ts.URL+"?p="+p, nil)
+		req.Header.Set("apiKey", "do-not-record")
+		resp, err := rec.Client().Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, `{"page":`+p+`}`, string(body), "recorder passes the real body through")
+	}
+	require.Equal(t, 2, hits)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "do-not-record", "request headers are never recorded")
+	assert.NotContains(t, string(raw), "session=secret", "Set-Cookie is stripped")
+
+	ts.Close()
+
+	rep, err := NewReplayer(path)
+	require.NoError(t, err)
+	resp, err := rep.Client().Get(ts.URL + "?p=2")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"page":2}`, string(body))
+	assert.Equal(t, 2, hits, "replay does not touch the network")
+}
+
+func TestReplay_SameURLInOrderThenExhausted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"interactions":[
+		{"method":"GET","url":"https://nvd.example/x","status_code":503,"body":""},
+		{"method":"GET","url":"https://nvd.example/x","status_code":200,"body":"ok"}
+	]}`), 0o644))
+
+	rep, err := NewReplayer(path)
+	require.NoError(t, err)
+	c := rep.Client()
+
+	resp, err := c.Get("https://nvd.example/x")
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+
+	resp, err = c.Get("https://nvd.example/x")
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	_, err = c.Get("https://nvd.example/x")
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+	assert.True(t, strings.Contains(err.Error(), "https://nvd.example/x"))
+}
+
+func TestNewReplayer_MissingFile(t *testing.T) {
+	_, err := NewReplayer(filepath.Join(t.TempDir(), "nope.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+// Package i18n localizes the human-readable output: summary and report
+// tables and Slack digests. Messages live in go-i18n TOML catalogs under
+// locales/, one file per language, embedded in the binary. English is the
+// source language; a message missing from another catalog falls back to
+// it. Machine-readable output (JSON, CSV, generic webhooks) is never
+// translated.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.toml
+var locales embed.FS
+
+var (
+	loadOnce sync.Once
+	bundle   *goi18n.Bundle
+	matcher  language.Matcher
+	loadErr  error
+)
+
+// load parses the embedded catalogs once.
+func load() error {
+	loadOnce.Do(func() {
+		b := goi18n.NewBundle(language.English)
+		b.RegisterUnmarshalFunc("toml", toml.Unmarshal)
+		paths, err := fs.Glob(locales, "locales/*.toml")
+		if err != nil {
+			loadErr = err
+			return
+		}
+		for _, p := range paths {
+			if _, err := b.LoadMessageFileFS(locales, p); err != nil {
+				loadErr = fmt.Errorf("catalog %s: %w", p, err)
+				return
+			}
+		}
+		bundle, matcher = b, language.NewMatcher(b.LanguageTags())
+	})
+	return loadErr
+}
+
+// Languages lists the languages with a catalog, English first.
+func Languages() []string {
+	if load() != nil {
+		return []string{"en"}
+	}
+	out := make([]string, 0, len(bundle.LanguageTags()))
+	for _, t := range bundle.LanguageTags() {
+		out = append(out, t.String())
+	}
+	return out
+}
+
+// Localizer renders messages in one language.
+type Localizer struct {
+	lang string
+	loc  *goi18n.Localizer
+}
+
+// New returns a Localizer for lang, a language tag such as "de" or
+// "fr-CA"; "" is English. A language without a catalog is an error rather
+// than silently English, so a typo in config is noticed.
+func New(lang string) (*Localizer, error) {
+	if err := load(); err != nil {
+		return nil, err
+	}
+	if lang == "" {
+		lang = "en"
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return nil, fmt.Errorf("language %q: %w", lang, err)
+	}
+	if _, _, conf := matcher.Match(tag); conf == language.No {
+		return nil, fmt.Errorf("no translations for language %q (have %s)", lang, strings.Join(Languages(), ", "))
+	}
+	return &Localizer{lang: lang, loc: goi18n.NewLocalizer(bundle, lang)}, nil
+}
+
+// English returns the Localizer for the source language.
+func English() *Localizer {
+	l, err := New("en")
+	if err != nil {
+		panic(err) // the embedded catalogs are checked by the tests
+	}
+	return l
+}
+
+// Language is the tag l was created for.
+func (l *Localizer) Language() string { return l.lang }
+
+// T renders the message id with data, a map or struct for its template.
+// An unknown id renders as itself.
+func (l *Localizer) T(id string, data any) string {
+	s, err := l.loc.Localize(&goi18n.LocalizeConfig{MessageID: id, TemplateData: data})
+	if err != nil {
+		return id
+	}
+	return s
+}
+
+// N renders the plural message id for count. The template sees count as
+// .Count, alongside the fields of data when it is a map.
+func (l *Localizer) N(id string, count int, data map[string]any) string {
+	td := map[string]any{"Count": count}
+	for k, v := range data {
+		td[k] = v
+	}
+	s, err := l.loc.Localize(&goi18n.LocalizeConfig{MessageID: id, TemplateData: td, PluralCount: count})
+	if err != nil {
+		return id
+	}
+	return s
+}
+
+// Header is the table header for column name: its "column.<name>"
+// message, upper-cased, or the upper-cased name when there is none.
+func (l *Localizer) Header(name string) string {
+	s, err := l.loc.Localize(&goi18n.LocalizeConfig{MessageID: "column." + name})
+	if err != nil {
+		return strings.ToUpper(name)
+	}
+	return strings.ToUpper(s)
+}
+
+// List joins items with the language's word for "and".
+func (l *Localizer) List(items []string) string {
+	return strings.Join(items, " "+l.T("list.and", nil)+" ")
+}
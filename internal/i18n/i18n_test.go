@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogIDs reads the message IDs of the catalog for lang.
+func catalogIDs(t *testing.T, lang string) map[string]bool {
+	t.Helper()
+	raw, err := fs.ReadFile(locales, "locales/active."+lang+".toml")
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, toml.Unmarshal(raw, &m))
+	ids := map[string]bool{}
+	for id := range m {
+		ids[id] = true
+	}
+	return ids
+}
+
+func TestCatalogsTranslateEveryMessage(t *testing.T) {
+	en := catalogIDs(t, "en")
+	for _, lang := range Languages() {
+		if lang == "en" {
+			continue
+		}
+		ids := catalogIDs(t, lang)
+		for id := range en {
+			assert.True(t, ids[id], "%s: missing %q", lang, id)
+		}
+		for id := range ids {
+			assert.True(t, en[id] || strings.HasPrefix(id, "column."), "%s: %q is not an English message", lang, id)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, []string{"en", "de", "fr"}, Languages())
+
+	l, err := New("")
+	require.NoError(t, err)
+	assert.Equal(t, "en", l.Language())
+
+	_, err = New("de-AT")
+	require.NoError(t, err)
+
+	_, err = New("ja")
+	assert.ErrorContains(t, err, "no translations")
+	_, err = New("not a tag!")
+	assert.Error(t, err)
+}
+
+func TestLocalize(t *testing.T) {
+	de, err := New("de")
+	require.NoError(t, err)
+	en := English()
+
+	assert.Equal(t, "Zusammenfassung seit 2026-10-01", de.T("summary.since", map[string]any{"Since": "2026-10-01"}))
+	assert.Equal(t, "no.such.message", de.T("no.such.message", nil))
+
+	assert.Equal(t, "New in CISA KEV — 1 CVE added", en.N("slack.kev.header", 1, nil))
+	assert.Equal(t, "New in CISA KEV — 3 CVEs added", en.N("slack.kev.header", 3, nil))
+	assert.Equal(t, "Neu im CISA-KEV — 3 CVEs hinzugefügt", de.N("slack.kev.header", 3, nil))
+
+	assert.Equal(t, "TITEL", de.Header("title"))
+	assert.Equal(t, "CVSS", de.Header("cvss"), "untranslated columns keep their name")
+	assert.Equal(t, "TITLE", en.Header("title"))
+
+	assert.Equal(t, "a und b", de.List([]string{"a", "b"}))
+}
@@ -0,0 +1,228 @@
+// Package inbound receives advisories pushed by webhook, for sources that
+// push rather than publish a feed. Each configured source has its own URL,
+// POST /inbound/{name}, and a shared secret: a request must carry
+// X-Hub-Signature-256, the HMAC-SHA256 of its body (the GitHub scheme), or
+// it is refused. Accepted advisories become the items of the feed at
+// inbound:{name} and take the feed path from there: sanitising, IDs,
+// quality checks, archive and current, and everything that reads them.
+package inbound
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// defaultMaxBody bounds a request body unless max_body_mb is set. A push
+// carries a handful of advisories.
+const defaultMaxBody = 1 << 20
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body.
+const SignatureHeader = "X-Hub-Signature-256"
+
+// Formats of pushed payloads.
+const (
+	FormatTigerfetch = "tigerfetch" // Payload
+	FormatGitHub     = "github"     // security_advisory and repository_advisory events
+)
+
+// Store keeps the advisories of a push; *ingestor.Client implements it.
+type Store interface {
+	Store(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) (int, error)
+}
+
+// FeedURL is the feed URL advisories pushed by source name are stored
+// under.
+func FeedURL(name string) string { return feedScheme + name }
+
+// SourceName is the source whose advisories are stored under feedURL.
+func SourceName(feedURL string) string { return strings.TrimPrefix(feedURL, feedScheme) }
+
+const feedScheme = "inbound:"
+
+type source struct {
+	feed   config.Feed
+	title  string
+	secret []byte
+	decode func(body []byte) (*gofeed.Feed, []string, error)
+}
+
+// Receiver serves the push URLs of the configured sources.
+type Receiver struct {
+	store      Store
+	sources    map[string]source
+	maxBody    int64
+	quarantine *quarantine.Store
+	archive    *rawarchive.Archive
+}
+
+// New returns the receiver of cfg's sources. Every source needs a unique
+// name, a secret and a known format.
+func New(store Store, cfg config.InboundConfig) (*Receiver, error) {
+	r := &Receiver{
+		store:   store,
+		sources: make(map[string]source, len(cfg.Sources)),
+		maxBody: httpclient.LimitFromMB(cfg.MaxBodyMB, defaultMaxBody),
+	}
+	for _, sc := range cfg.Sources {
+		if sc.Name == "" || strings.ContainsAny(sc.Name, "/ ") {
+			return nil, fmt.Errorf("inbound source name %q must be non-empty, without slashes or spaces", sc.Name)
+		}
+		if _, dup := r.sources[sc.Name]; dup {
+			return nil, fmt.Errorf("inbound source %q is configured twice", sc.Name)
+		}
+		if sc.Secret == "" {
+			return nil, fmt.Errorf("inbound source %q needs a secret", sc.Name)
+		}
+		s := source{
+			feed:   config.Feed{Name: sc.Name, URL: FeedURL(sc.Name)},
+			title:  cmp.Or(sc.Title, sc.Name),
+			secret: []byte(sc.Secret),
+		}
+		switch cmp.Or(sc.Format, FormatTigerfetch) {
+		case FormatTigerfetch:
+			s.decode = decodePayload
+		case FormatGitHub:
+			s.decode = decodeGitHub
+		default:
+			return nil, fmt.Errorf("inbound source %q: unknown format %q (tigerfetch or github)", sc.Name, sc.Format)
+		}
+		r.sources[sc.Name] = s
+	}
+	return r, nil
+}
+
+// SetQuarantine keeps signed payloads that fail to decode in q.
+func (r *Receiver) SetQuarantine(q *quarantine.Store) { r.quarantine = q }
+
+// SetArchive keeps a copy of every signed payload in a.
+func (r *Receiver) SetArchive(a *rawarchive.Archive) { r.archive = a }
+
+// Handler returns the receiver's routes.
+func (r *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /inbound/{name}", r.handlePush)
+	return mux
+}
+
+// pushResponse answers an accepted push.
+type pushResponse struct {
+	Received  int `json:"received"`  // advisories in the payload
+	Stored    int `json:"stored"`    // of them stored; the rest failed checks and are logged
+	Withdrawn int `json:"withdrawn"` // of them withdrawn by the source
+}
+
+func (r *Receiver) handlePush(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	src, ok := r.sources[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown source")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, r.maxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			metrics.InboundRequests.WithLabelValues(name, "too_large").Inc()
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", r.maxBody))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "reading body failed")
+		return
+	}
+	if !validSignature(src.secret, body, req.Header.Get(SignatureHeader)) {
+		metrics.InboundRequests.WithLabelValues(name, "unauthorized").Inc()
+		slog.Warn("Inbound push with a bad signature refused", "source", name, "remote", req.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "missing or invalid "+SignatureHeader)
+		return
+	}
+	r.archive.Put(req.Context(), rawarchive.Inbound, src.feed.URL, body)
+
+	feed, withdrawn, err := src.decode(body)
+	if err != nil {
+		metrics.InboundRequests.WithLabelValues(name, "invalid").Inc()
+		r.quarantine.Put(req.Context(), quarantine.Inbound, name, src.feed.URL, body, err)
+		writeError(w, http.StatusBadRequest, "invalid payload: "+err.Error())
+		return
+	}
+	if feed == nil {
+		// An event of no interest, such as GitHub's ping
+		metrics.InboundRequests.WithLabelValues(name, "ignored").Inc()
+		writeJSON(w, http.StatusOK, pushResponse{})
+		return
+	}
+	feed.Title = src.title
+	stored, err := r.store.Store(req.Context(), src.feed, feed, withdrawn)
+	if err != nil {
+		metrics.InboundRequests.WithLabelValues(name, "error").Inc()
+		slog.Error("Storing pushed advisories failed", "source", name, "error", err)
+		writeError(w, http.StatusInternalServerError, "storing failed")
+		return
+	}
+	metrics.InboundRequests.WithLabelValues(name, "stored").Inc()
+	slog.Info("Pushed advisories stored", "source", name, "received", len(feed.Items), "stored", stored)
+	writeJSON(w, http.StatusOK, pushResponse{Received: len(feed.Items), Stored: stored, Withdrawn: len(withdrawn)})
+}
+
+// Replay stores a payload pushed by source name again, such as one
+// quarantined or archived, and returns how many advisories it stored.
+func (r *Receiver) Replay(ctx context.Context, name string, body []byte) (int, error) {
+	src, ok := r.sources[name]
+	if !ok {
+		return 0, fmt.Errorf("inbound source %q is no longer configured", name)
+	}
+	feed, withdrawn, err := src.decode(body)
+	if err != nil || feed == nil {
+		return 0, err
+	}
+	feed.Title = src.title
+	return r.store.Store(ctx, src.feed, feed, withdrawn)
+}
+
+// validSignature checks header, "sha256=<hex>", against body in constant
+// time.
+func validSignature(secret, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, Sign(secret, body))
+}
+
+// Sign returns the HMAC-SHA256 of body, for senders and tests.
+func Sign(secret, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,162 @@
+package inbound
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStore struct {
+	feedCfg   config.Feed
+	feed      *gofeed.Feed
+	withdrawn []string
+}
+
+func (s *stubStore) Store(_ context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) (int, error) {
+	s.feedCfg, s.feed, s.withdrawn = feedCfg, feed, withdrawn
+	return len(feed.Items), nil
+}
+
+var testConfig = config.InboundConfig{MaxBodyMB: 1, Sources: []config.InboundSourceConfig{
+	{Name: "psirt", Secret: "s3cret", Title: "Internal PSIRT"},
+	{Name: "github", Secret: "gh-secret", Format: "github"},
+}}
+
+func push(t *testing.T, h http.Handler, name, secret, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/inbound/"+name, strings.NewReader(body))
+	if secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign([]byte(secret), []byte(body))))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPush_Payload(t *testing.T) {
+	store := &stubStore{}
+	r, err := New(store, testConfig)
+	require.NoError(t, err)
+	body := `{"advisories":[
+		{"id":"PSIRT-7","title":"CVE-2026-1234 in the VPN gateway","link":"https://psirt.example.com/7",
+		 "published":"2026-10-01T08:00:00Z","summary":"Patch now","content":"<p>Details</p>",
+		 "author":"PSIRT","categories":["vpn"]},
+		{"id":"PSIRT-3","withdrawn":true}]}`
+
+	rr := push(t, r.Handler(), "psirt", "s3cret", body)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp pushResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, pushResponse{Received: 2, Stored: 2, Withdrawn: 1}, resp)
+
+	assert.Equal(t, config.Feed{Name: "psirt", URL: "inbound:psirt"}, store.feedCfg)
+	assert.Equal(t, "Internal PSIRT", store.feed.Title)
+	assert.Equal(t, []string{"PSIRT-3"}, store.withdrawn)
+	item := store.feed.Items[0]
+	assert.Equal(t, "PSIRT-7", item.GUID)
+	assert.Equal(t, "CVE-2026-1234 in the VPN gateway", item.Title)
+	assert.Equal(t, "Patch now", item.Description)
+	assert.Equal(t, "<p>Details</p>", item.Content)
+	assert.Equal(t, "PSIRT", item.Authors[0].Name)
+	assert.Equal(t, []string{"vpn"}, item.Categories)
+	require.NotNil(t, item.PublishedParsed)
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), *item.PublishedParsed)
+}
+
+func TestPush_Refused(t *testing.T) {
+	store := &stubStore{}
+	r, err := New(store, testConfig)
+	require.NoError(t, err)
+	h := r.Handler()
+	body := `{"advisories":[{"id":"PSIRT-7"}]}`
+
+	assert.Equal(t, http.StatusUnauthorized, push(t, h, "psirt", "", body).Code, "unsigned")
+	assert.Equal(t, http.StatusUnauthorized, push(t, h, "psirt", "wrong", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, push(t, h, "psirt", "gh-secret", body).Code, "another source's secret")
+	assert.Equal(t, http.StatusNotFound, push(t, h, "nope", "s3cret", body).Code)
+	assert.Equal(t, http.StatusBadRequest, push(t, h, "psirt", "s3cret", `{"advisories":[{"title":"no id"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, push(t, h, "psirt", "s3cret", `{"advisory":[]}`).Code, "unknown field")
+	big := `{"advisories":[{"id":"x","content":"` + strings.Repeat("a", 1<<20) + `"}]}`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, push(t, h, "psirt", "s3cret", big).Code)
+	assert.Nil(t, store.feed, "nothing stored")
+
+	req := httptest.NewRequest(http.MethodGet, "/inbound/psirt", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestPush_GitHub(t *testing.T) {
+	store := &stubStore{}
+	r, err := New(store, testConfig)
+	require.NoError(t, err)
+	h := r.Handler()
+
+	rr := push(t, h, "github", "gh-secret", `{"zen":"Keep it logically awesome.","hook_id":1}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "ping")
+	assert.Nil(t, store.feed)
+
+	body := `{"action":"published","security_advisory":{
+		"ghsa_id":"GHSA-abcd-efgh-ijkl","cve_id":"CVE-2026-4242","summary":"RCE in <widget>",
+		"description":"First paragraph.\n\nSecond & last.","severity":"critical",
+		"identifiers":[{"type":"GHSA","value":"GHSA-abcd-efgh-ijkl"},{"type":"CVE","value":"CVE-2026-4242"}],
+		"references":[{"url":"https://example.com/fix"}],
+		"published_at":"2026-10-02T10:00:00Z","updated_at":"2026-10-03T10:00:00Z","withdrawn_at":null,
+		"vulnerabilities":[{"package":{"ecosystem":"npm","name":"widget"}}]}}`
+	rr = push(t, h, "github", "gh-secret", body)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Len(t, store.feed.Items, 1)
+	item := store.feed.Items[0]
+	assert.Equal(t, "GHSA-abcd-efgh-ijkl", item.GUID)
+	assert.Equal(t, "RCE in <widget>", item.Title)
+	assert.Equal(t, "https://github.com/advisories/GHSA-abcd-efgh-ijkl", item.Link)
+	assert.Equal(t, `<p>First paragraph.</p><p>Second &amp; last.</p><p>Identifiers: GHSA-abcd-efgh-ijkl, CVE-2026-4242</p>`+
+		`<ul><li><a href="https://example.com/fix">https://example.com/fix</a></li></ul>`, item.Content)
+	assert.Equal(t, []string{"critical", "npm/widget"}, item.Categories)
+	assert.Empty(t, store.withdrawn)
+
+	withdrawn := strings.Replace(body, `"action":"published"`, `"action":"withdrawn"`, 1)
+	require.Equal(t, http.StatusOK, push(t, h, "github", "gh-secret", withdrawn).Code)
+	assert.Equal(t, []string{"GHSA-abcd-efgh-ijkl"}, store.withdrawn)
+
+	repo := `{"action":"published","repository_advisory":{"ghsa_id":"GHSA-1111-2222-3333","summary":"Repo bug",
+		"html_url":"https://github.com/acme/app/security/advisories/GHSA-1111-2222-3333"}}`
+	require.Equal(t, http.StatusOK, push(t, h, "github", "gh-secret", repo).Code)
+	assert.Equal(t, "https://github.com/acme/app/security/advisories/GHSA-1111-2222-3333", store.feed.Items[0].Link)
+}
+
+func TestReplay(t *testing.T) {
+	store := &stubStore{}
+	r, err := New(store, testConfig)
+	require.NoError(t, err)
+	n, err := r.Replay(t.Context(), SourceName(FeedURL("psirt")), []byte(`{"advisories":[{"id":"PSIRT-9"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "PSIRT-9", store.feed.Items[0].GUID)
+
+	_, err = r.Replay(t.Context(), "gone", []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestNew_Config(t *testing.T) {
+	for name, sources := range map[string][]config.InboundSourceConfig{
+		"no secret":      {{Name: "a"}},
+		"duplicate":      {{Name: "a", Secret: "x"}, {Name: "a", Secret: "y"}},
+		"slash in name":  {{Name: "a/b", Secret: "x"}},
+		"unknown format": {{Name: "a", Secret: "x", Format: "csaf"}},
+	} {
+		_, err := New(&stubStore{}, config.InboundConfig{Sources: sources})
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,176 @@
+package inbound
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Payload is the documented push schema, format "tigerfetch". Advisories
+// are keyed by ID within their source: pushing one again updates it.
+type Payload struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+// Advisory is one pushed advisory. CVE IDs are read from the title,
+// summary, content and link, as for feed items.
+type Advisory struct {
+	ID         string     `json:"id"` // required, stable per source
+	Title      string     `json:"title"`
+	Link       string     `json:"link"`
+	Published  *time.Time `json:"published"`
+	Updated    *time.Time `json:"updated"`
+	Summary    string     `json:"summary"`
+	Content    string     `json:"content"` // HTML, sanitised like feed content
+	Author     string     `json:"author"`
+	Categories []string   `json:"categories"`
+	Withdrawn  bool       `json:"withdrawn"` // taken down by its source
+}
+
+// decodePayload reads a Payload. Unknown fields are refused, so a typo
+// does not silently drop data.
+func decodePayload(body []byte) (*gofeed.Feed, []string, error) {
+	var p Payload
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, nil, err
+	}
+	feed := &gofeed.Feed{FeedType: "webhook", FeedVersion: FormatTigerfetch}
+	var withdrawn []string
+	for i, a := range p.Advisories {
+		if strings.TrimSpace(a.ID) == "" {
+			return nil, nil, fmt.Errorf("advisories[%d]: id is required", i)
+		}
+		item := &gofeed.Item{
+			GUID:            a.ID,
+			Title:           a.Title,
+			Link:            a.Link,
+			PublishedParsed: a.Published,
+			UpdatedParsed:   a.Updated,
+			Description:     a.Summary,
+			Content:         a.Content,
+			Categories:      a.Categories,
+		}
+		if a.Author != "" {
+			item.Authors = []*gofeed.Person{{Name: a.Author}}
+		}
+		feed.Items = append(feed.Items, item)
+		if a.Withdrawn {
+			withdrawn = append(withdrawn, a.ID)
+		}
+	}
+	return feed, withdrawn, nil
+}
+
+// githubAdvisory is the advisory of GitHub's security_advisory (global
+// database) and repository_advisory webhook events.
+type githubAdvisory struct {
+	GHSAID      string     `json:"ghsa_id"`
+	CVEID       string     `json:"cve_id"`
+	HTMLURL     string     `json:"html_url"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description"`
+	Severity    string     `json:"severity"`
+	PublishedAt *time.Time `json:"published_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	WithdrawnAt *time.Time `json:"withdrawn_at"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+	References []struct {
+		URL string `json:"url"`
+	} `json:"references"`
+	Vulnerabilities []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+	} `json:"vulnerabilities"`
+}
+
+// decodeGitHub reads a GitHub webhook event. The event is told by its
+// body, not the X-GitHub-Event header, so quarantined payloads replay the
+// same way; events other than advisories, such as ping, return no feed.
+func decodeGitHub(body []byte) (*gofeed.Feed, []string, error) {
+	var event struct {
+		Action             string          `json:"action"`
+		SecurityAdvisory   *githubAdvisory `json:"security_advisory"`
+		RepositoryAdvisory *githubAdvisory `json:"repository_advisory"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, nil, err
+	}
+	a := event.SecurityAdvisory
+	if a == nil {
+		a = event.RepositoryAdvisory
+	}
+	if a == nil {
+		return nil, nil, nil
+	}
+	if a.GHSAID == "" {
+		return nil, nil, errors.New("advisory has no ghsa_id")
+	}
+
+	ids := []string{a.GHSAID}
+	if a.CVEID != "" {
+		ids = append(ids, a.CVEID)
+	}
+	for _, id := range a.Identifiers {
+		if id.Value != "" && id.Value != a.GHSAID && id.Value != a.CVEID {
+			ids = append(ids, id.Value)
+		}
+	}
+	var b strings.Builder
+	for para := range strings.SplitSeq(strings.TrimSpace(a.Description), "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(para))
+		}
+	}
+	fmt.Fprintf(&b, "<p>Identifiers: %s</p>", html.EscapeString(strings.Join(ids, ", ")))
+	if len(a.References) > 0 {
+		b.WriteString("<ul>")
+		for _, ref := range a.References {
+			u := html.EscapeString(ref.URL)
+			fmt.Fprintf(&b, `<li><a href="%s">%s</a></li>`, u, u)
+		}
+		b.WriteString("</ul>")
+	}
+
+	var categories []string
+	if a.Severity != "" {
+		categories = append(categories, a.Severity)
+	}
+	for _, v := range a.Vulnerabilities {
+		if v.Package.Name != "" {
+			categories = append(categories, v.Package.Ecosystem+"/"+v.Package.Name)
+		}
+	}
+	link := a.HTMLURL
+	if link == "" {
+		link = "https://github.com/advisories/" + a.GHSAID
+	}
+	item := &gofeed.Item{
+		GUID:            a.GHSAID,
+		Title:           a.Summary,
+		Link:            link,
+		PublishedParsed: a.PublishedAt,
+		UpdatedParsed:   a.UpdatedAt,
+		Description:     a.Summary,
+		Content:         b.String(),
+		Authors:         []*gofeed.Person{{Name: "GitHub"}},
+		Categories:      categories,
+	}
+	var withdrawn []string
+	if a.WithdrawnAt != nil || event.Action == "withdrawn" {
+		withdrawn = []string{a.GHSAID}
+	}
+	return &gofeed.Feed{FeedType: "webhook", FeedVersion: FormatGitHub, Items: []*gofeed.Item{item}}, withdrawn, nil
+}
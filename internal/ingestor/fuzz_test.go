@@ -0,0 +1,149 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/quarantine"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzParseFeed feeds arbitrary bytes through the same parse-and-sanitize
+// steps processItem applies before touching the database.
+func FuzzParseFeed(f *testing.F) {
+	f.Add(testRSSFeed)
+	f.Add(`<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>A</title><entry><id>1</id><title>x</title><content type="html">&lt;script&gt;alert(1)&lt;/script&gt;</content><updated>2024-01-01T00:00:00Z</updated></entry></feed>`)
+	f.Add(`<?xml version="1.0"?><!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;&lol;">]><rss><channel><title>&lol2;</title></channel></rss>`)
+	f.Add(`{"version":"https://jsonfeed.org/version/1","items":[{"id":"1"}]}`)
+	f.Add(`<rss><channel><item><pubDate>not a date</pubDate></item></channel></rss>`)
+
+	policy := bluemonday.UGCPolicy()
+	f.Fuzz(func(t *testing.T, data string) {
+		feed, err := gofeed.NewParser().ParseString(data)
+		if err != nil {
+			return
+		}
+		for _, item := range feed.Items {
+			if item == nil {
+				continue
+			}
+			_ = policy.Sanitize(item.Content)
+			_ = policy.Sanitize(item.Description)
+		}
+	})
+}
+
+func TestParseFeed_DeeplyNestedXML(t *testing.T) {
+	nested := "<rss><channel>" + strings.Repeat("<x>", 100000) + strings.Repeat("</x>", 100000) + "</channel></rss>"
+	// Unknown elements are skipped iteratively; deep nesting must not
+	// exhaust the stack.
+	assert.NotPanics(t, func() {
+		_, _ = gofeed.NewParser().ParseString(nested)
+	})
+}
+
+func TestFetch_RejectsOversizedFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	c := New(nil)
+	c.maxBytes = 64
+
+	_, err := c.fetch(context.Background(), server.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, httpclient.ErrResponseTooLarge)
+
+	c.maxBytes = defaultMaxFeedBytes
+	feed, err := c.fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Feed", feed.Title)
+}
+
+func TestFetch_HTTPErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := New(nil).fetch(context.Background(), server.URL)
+	var httpErr gofeed.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
+
+func TestFetch_ParseErrorKeepsPayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>maintenance</html>"))
+	}))
+	defer server.Close()
+
+	_, err := New(nil).fetch(context.Background(), server.URL)
+	var pe *quarantine.ParseError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "<html>maintenance</html>", string(pe.Payload))
+}
+
+// benchFeed returns an RSS or Atom document of n advisory-sized items.
+func benchFeed(atom bool, n int) string {
+	var b strings.Builder
+	body := strings.Repeat("<p>A remote attacker can execute arbitrary code via a crafted request to the "+
+		"management interface. See <a href=\"https://nvd.nist.gov/vuln/detail/CVE-2024-21887\">CVE-2024-21887</a>.</p>", 8)
+	if atom {
+		b.WriteString(`<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Bench</title>`)
+	} else {
+		b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Bench</title><link>https://example.com</link>`)
+	}
+	for i := range n {
+		if atom {
+			fmt.Fprintf(&b, `<entry><id>urn:bench:%d</id><title>Advisory %d</title><link href="https://example.com/%d"/>`+
+				`<updated>2024-01-01T00:00:00Z</updated><content type="html">%s</content></entry>`, i, i, i, html.EscapeString(body))
+		} else {
+			fmt.Fprintf(&b, `<item><guid>urn:bench:%d</guid><title>Advisory %d</title><link>https://example.com/%d</link>`+
+				`<pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate><description>%s</description></item>`, i, i, i, html.EscapeString(body))
+		}
+	}
+	if atom {
+		b.WriteString(`</feed>`)
+	} else {
+		b.WriteString(`</channel></rss>`)
+	}
+	return b.String()
+}
+
+// BenchmarkParseFeed measures the parse-and-sanitize steps of a fetch on a
+// 200-item feed, the default item limit's order of magnitude.
+func BenchmarkParseFeed(b *testing.B) {
+	policy := bluemonday.UGCPolicy()
+	for _, bc := range []struct {
+		name string
+		atom bool
+	}{{"rss", false}, {"atom", true}} {
+		data := benchFeed(bc.atom, 200)
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				feed, err := gofeed.NewParser().ParseString(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, item := range feed.Items {
+					_ = policy.Sanitize(item.Content)
+					_ = policy.Sanitize(item.Description)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package ingestor
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DefaultIDNamespace seeds advisory IDs unless advisory_id_namespace is
+// set. The migration that made IDs deterministic re-keyed stored
+// advisories with it.
+const DefaultIDNamespace = "2f6ab692-a2b5-42df-9db8-38855ee8b261"
+
+// Namespace is the UUID that advisory IDs are derived in.
+type Namespace [16]byte
+
+// ParseNamespace parses a UUID in its canonical form; "" is
+// DefaultIDNamespace.
+func ParseNamespace(s string) (Namespace, error) {
+	var ns Namespace
+	if s == "" {
+		s = DefaultIDNamespace
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return ns, fmt.Errorf("invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(ns[:], []byte(strings.ReplaceAll(s, "-", ""))); err != nil {
+		return ns, fmt.Errorf("invalid UUID %q", s)
+	}
+	return ns, nil
+}
+
+// AdvisoryID is the RFC 9562 name-based (SHA-1) UUID of the item with
+// guid, its GUID or else its link, in the feed at feedURL. The same item
+// gets the same ID on every fetch and on every instance sharing ns, and
+// the same GUID in two feeds gets two IDs. The name matches the
+// uuid_generate_v5 backfill in the migrations.
+func AdvisoryID(ns Namespace, feedURL, guid string) string {
+	h := sha1.New()
+	h.Write(ns[:])
+	h.Write([]byte(feedURL + "\n" + guid))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
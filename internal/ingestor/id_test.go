@@ -0,0 +1,32 @@
+package ingestor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisoryID(t *testing.T) {
+	ns, err := ParseNamespace("")
+	require.NoError(t, err)
+	const feed = "https://www.cisa.gov/cybersecurity-advisories/all.xml"
+	const guid = "https://www.cisa.gov/news-events/alerts/2024/01/10/ivanti"
+
+	// The value uuid_generate_v5 gives for the migration's backfill
+	assert.Equal(t, "c6e59068-383b-5024-bb5b-ac2d037ed053", AdvisoryID(ns, feed, guid))
+	assert.Equal(t, AdvisoryID(ns, feed, guid), AdvisoryID(ns, feed, guid))
+	assert.NotEqual(t, AdvisoryID(ns, feed, guid), AdvisoryID(ns, "https://example.com/feed.xml", guid),
+		"the same GUID in another feed")
+
+	other, err := ParseNamespace("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+	require.NoError(t, err)
+	assert.NotEqual(t, AdvisoryID(ns, feed, guid), AdvisoryID(other, feed, guid))
+}
+
+func TestParseNamespace_Invalid(t *testing.T) {
+	for _, s := range []string{"nope", "6ba7b8119dad11d180b400c04fd430c8", "6ba7b811-9dad-11d1-80b4-00c04fd430cz", "6ba7b811-9dad-11d1-80b400-c04fd430c8"} {
+		_, err := ParseNamespace(s)
+		assert.Error(t, err, s)
+	}
+}
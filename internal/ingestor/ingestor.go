@@ -1,33 +1,260 @@
 package ingestor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
 	"time"
 
+	"tiger2go/internal/budget"
 	"tiger2go/internal/config"
+	"tiger2go/internal/crawl"
+	"tiger2go/internal/httpclient"
 	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+	"tiger2go/internal/quality"
+	"tiger2go/internal/quarantine"
+	"tiger2go/internal/rawarchive"
+	"tiger2go/internal/upstream"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/mmcdole/gofeed"
 )
 
+// FeedFetcher retrieves and parses a single RSS/Atom feed. Client uses a
+// gofeed parser by default; SetFetcher substitutes another implementation,
+// such as the in-memory fake in internal/fakes.
+type FeedFetcher interface {
+	Fetch(ctx context.Context, url string) (*gofeed.Feed, error)
+}
+
+// defaultMaxFeedBytes bounds a feed body unless feed_max_response_mb is set.
+// Real feeds are well under 5 MB; the cap stops a broken or hostile server
+// from exhausting memory.
+const defaultMaxFeedBytes = 16 << 20
+
+// defaultMaxFeedItems bounds the items stored per fetch unless
+// feed_max_items is set. Security feeds list tens to hundreds; a
+// misconfigured or aggregator feed listing tens of thousands would
+// otherwise dominate the run.
+const defaultMaxFeedItems = 1000
+
 type Client struct {
-	db     *pgxpool.Pool
-	policy *bluemonday.Policy
-	pf     *gofeed.Parser
+	db          *pgxpool.Pool
+	policy      *bluemonday.Policy
+	pf          *gofeed.Parser
+	fetcher     FeedFetcher // nil means fetch over HTTP and parse with pf
+	maxBytes    int64
+	maxItems    int           // default items stored per fetch; 0 no limit
+	maxAge      time.Duration // default item age cutoff; 0 keeps all
+	withdraw    int           // default fetches an item may be missing before it is withdrawn; 0 never
+	guard       httpclient.URLPolicy
+	http        *http.Client
+	budget      *budget.Budget // nil: unlimited
+	upstream    *upstream.Stats
+	throttle    *httpclient.Throttle // nil: rate limit headers are ignored
+	crawler     *crawl.Crawler       // politeness for backfill fetches; nil: none
+	quality     *quality.Recorder
+	quarantine  *quarantine.Store
+	archive     *rawarchive.Archive
+	idSpace     Namespace
+	redirectors []string // hosts whose links are resolved
+	tracking    []string // query parameters removed from links
 }
 
 func New(db *pgxpool.Pool) *Client {
 	pf := gofeed.NewParser()
 	pf.UserAgent = "TigerFetch-Go/1.0"
+	ns, _ := ParseNamespace(DefaultIDNamespace)
 	return &Client{
-		db:     db,
-		policy: bluemonday.UGCPolicy(),
-		pf:     pf,
+		db:          db,
+		policy:      bluemonday.UGCPolicy(),
+		pf:          pf,
+		maxBytes:    defaultMaxFeedBytes,
+		maxItems:    defaultMaxFeedItems,
+		http:        httpclient.URLPolicy{}.Client(0),
+		idSpace:     ns,
+		redirectors: defaultRedirectors,
+		tracking:    defaultTrackingParams,
+	}
+}
+
+// SetURLPolicy restricts which feed URLs, redirect targets and addresses
+// may be fetched.
+func (c *Client) SetURLPolicy(p httpclient.URLPolicy) {
+	c.guard = p
+	c.http = p.Client(0)
+	c.http.Transport = c.budget.Transport(c.throttle.Transport(c.upstream.Transport(c.http.Transport)))
+}
+
+// SetBudget counts feed requests and new advisories against the run's
+// [limits].
+func (c *Client) SetBudget(b *budget.Budget) {
+	c.budget = b
+	c.http.Transport = b.Transport(c.http.Transport)
+}
+
+// SetUpstream measures feed requests, per host, into s.
+func (c *Client) SetUpstream(s *upstream.Stats) {
+	c.upstream = s
+	c.SetURLPolicy(c.guard)
+}
+
+// SetThrottle paces feed requests by the rate limit headers of each host.
+func (c *Client) SetThrottle(t *httpclient.Throttle) {
+	c.throttle = t
+	c.SetURLPolicy(c.guard)
+}
+
+// SetCrawler fetches feeds marked backfill, which load a site's archive,
+// through c's politeness layer: robots.txt, one request at a time per host
+// and a pause between them.
+func (c *Client) SetCrawler(cr *crawl.Crawler) { c.crawler = cr }
+
+// SetQuality records the data quality violations of new items in q.
+func (c *Client) SetQuality(q *quality.Recorder) { c.quality = q }
+
+// SetQuarantine keeps feed bodies that fail to parse in q.
+func (c *Client) SetQuarantine(q *quarantine.Store) { c.quarantine = q }
+
+// SetArchive keeps a copy of every feed body fetched over HTTP in a.
+func (c *Client) SetArchive(a *rawarchive.Archive) { c.archive = a }
+
+// SetIDNamespace derives the IDs of new advisories in ns.
+func (c *Client) SetIDNamespace(ns Namespace) { c.idSpace = ns }
+
+// SetMaxResponseMB overrides the feed body size limit; zero keeps the default.
+func (c *Client) SetMaxResponseMB(mb int) {
+	c.maxBytes = httpclient.LimitFromMB(mb, defaultMaxFeedBytes)
+}
+
+// SetMaxItems overrides the default number of items stored per fetch,
+// the newest; zero keeps the default and a negative value lifts the limit.
+// A feed's max_items and backfill settings override it.
+func (c *Client) SetMaxItems(n int) {
+	if n != 0 {
+		c.maxItems = max(n, 0)
+	}
+}
+
+// SetMaxItemAge sets the default item age cutoff: items dated longer ago
+// are dropped after parsing, so feeds that serve their whole archive on
+// every fetch do not rewrite and re-enrich years-old advisories. A feed's
+// max_item_age and backfill settings override it; zero keeps all items.
+func (c *Client) SetMaxItemAge(d time.Duration) { c.maxAge = d }
+
+// SetWithdrawAfter sets the default number of fetches in a row an item
+// must be missing from its feed, while older items are still listed,
+// before it is marked withdrawn. A feed's withdraw_after overrides it;
+// zero never marks items.
+func (c *Client) SetWithdrawAfter(n int) { c.withdraw = n }
+
+// SetFetcher replaces the parser used to fetch feeds.
+func (c *Client) SetFetcher(f FeedFetcher) { c.fetcher = f }
+
+// fetch retrieves a feed via the configured fetcher, or over HTTP. The body
+// is read with a size limit before parsing, rather than letting gofeed
+// stream an unbounded response.
+func (c *Client) fetch(ctx context.Context, url string) (*gofeed.Feed, error) {
+	return c.fetchWith(ctx, c.http, url)
+}
+
+// fetchFeed fetches feedCfg, through the crawler when it is a backfill.
+func (c *Client) fetchFeed(ctx context.Context, feedCfg config.Feed) (*gofeed.Feed, error) {
+	hc := c.http
+	if feedCfg.Backfill && c.crawler != nil {
+		hc = &http.Client{
+			CheckRedirect: c.http.CheckRedirect,
+			Transport:     c.crawler.Transport(c.http.Transport),
+		}
+	}
+	return c.fetchWith(ctx, hc, feedCfg.URL)
+}
+
+func (c *Client) fetchWith(ctx context.Context, hc *http.Client, url string) (*gofeed.Feed, error) {
+	if c.fetcher != nil {
+		return c.fetcher.Fetch(ctx, url)
+	}
+
+	if err := c.guard.CheckURL(url); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.pf.UserAgent)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, gofeed.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := httpclient.ReadBody(resp, c.maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	c.archive.Put(ctx, rawarchive.Feeds, url, body)
+	feed, err := c.pf.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, &quarantine.ParseError{Payload: body, Err: err}
+	}
+	return feed, nil
+}
+
+// itemDate is the date the age cutoff compares: published, else updated.
+// Undated items return nil and are always kept.
+func itemDate(item *gofeed.Item) *time.Time {
+	if item.PublishedParsed != nil {
+		return item.PublishedParsed
 	}
+	return item.UpdatedParsed
+}
+
+// recent drops items dated before cutoff, keeping undated ones. A zero
+// cutoff keeps everything.
+func recent(items []*gofeed.Item, cutoff time.Time) []*gofeed.Item {
+	if cutoff.IsZero() {
+		return items
+	}
+	out := items[:0:0]
+	for _, item := range items {
+		if d := itemDate(item); d == nil || !d.Before(cutoff) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// newest returns the n most recently dated items, newest first. Undated
+// items sort after dated ones, in feed order.
+func newest(items []*gofeed.Item, n int) []*gofeed.Item {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b *gofeed.Item) int {
+		da, db := itemDate(a), itemDate(b)
+		switch {
+		case da == nil && db == nil:
+			return 0
+		case da == nil:
+			return 1
+		case db == nil:
+			return -1
+		}
+		return db.Compare(*da)
+	})
+	return sorted[:n]
 }
 
 func (c *Client) FetchAndSave(ctx context.Context, feedCfg config.Feed) (retErr error) {
@@ -42,24 +269,104 @@ func (c *Client) FetchAndSave(ctx context.Context, feedCfg config.Feed) (retErr
 		}
 	}()
 
+	maxAge, err := feedCfg.GetMaxItemAge(c.maxAge)
+	if err != nil {
+		return fmt.Errorf("invalid max_item_age for feed %s: %w", feedCfg.Name, err)
+	}
+
 	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	slog.Debug("Fetching feed", "url", feedCfg.URL)
 
 	httpStart := time.Now()
-	feed, err := c.pf.ParseURLWithContext(feedCfg.URL, opCtx)
+	feed, err := c.fetchFeed(opCtx, feedCfg)
 	metrics.UpstreamRequestDuration.WithLabelValues("feed").Observe(time.Since(httpStart).Seconds())
 	if err != nil {
+		var pe *quarantine.ParseError
+		if errors.As(err, &pe) {
+			c.quarantine.Put(ctx, quarantine.Feeds, feedCfg.Name, feedCfg.URL, pe.Payload, pe.Err)
+		}
 		return fmt.Errorf("failed to parse feed %s: %w", feedCfg.URL, err)
 	}
 
 	slog.Info("Fetched feed success", "title", feed.Title, "items", len(feed.Items), "url", feedCfg.URL)
 
+	status := http.StatusOK
+	if c.fetcher != nil {
+		status = 0
+	}
+	if _, err := c.save(ctx, opCtx, feedCfg, feed, status, start.Add(-maxAge), maxAge); err != nil {
+		return err
+	}
+	if err := c.trackWithdrawals(opCtx, feedCfg, feed, feedCfg.GetWithdrawAfter(c.withdraw)); err != nil {
+		slog.Warn("Tracking withdrawn advisories failed", "feed", feedCfg.Name, "error", err)
+	}
+	return nil
+}
+
+// Replay stores the items of a feed payload fetched at fetched under the
+// same rules as a fetch then, and returns how many it stored.
+func (c *Client) Replay(ctx context.Context, feedCfg config.Feed, payload []byte, fetched time.Time) (int, error) {
+	maxAge, err := feedCfg.GetMaxItemAge(c.maxAge)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_item_age for feed %s: %w", feedCfg.Name, err)
+	}
+	feed, err := c.pf.Parse(bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse feed %s: %w", feedCfg.URL, err)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return c.save(ctx, opCtx, feedCfg, feed, 0, fetched.Add(-maxAge), maxAge)
+}
+
+// Store stores the items of a feed pushed rather than fetched, such as the
+// advisories of a webhook, and returns how many it stored. A push is news,
+// so no age cutoff applies. The items named in withdrawn are marked
+// withdrawn and the others are reinstated if they were.
+func (c *Client) Store(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) (int, error) {
+	opCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	n, err := c.save(ctx, opCtx, feedCfg, feed, 0, time.Time{}, 0)
+	if err != nil {
+		return n, err
+	}
+	if err := c.markPushed(opCtx, feedCfg, feed, withdrawn); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// save stores the items of feed dated after cutoff, all when maxAge is 0,
+// with status recorded in their provenance, and returns how many it stored.
+func (c *Client) save(ctx, opCtx context.Context, feedCfg config.Feed, feed *gofeed.Feed, status int, cutoff time.Time, maxAge time.Duration) (int, error) {
+	items := feed.Items
+	if maxAge > 0 {
+		items = recent(items, cutoff)
+		if skipped := len(feed.Items) - len(items); skipped > 0 {
+			metrics.FeedItemsTooOld.WithLabelValues(feedCfg.Name).Add(float64(skipped))
+			slog.Debug("Skipped items older than max_item_age", "count", skipped, "max_item_age", maxAge, "feed", feedCfg.Name)
+		}
+	}
+
+	if limit := feedCfg.GetMaxItems(c.maxItems); limit > 0 && len(items) > limit {
+		metrics.FeedItemsTruncated.WithLabelValues(feedCfg.Name).Add(float64(len(items) - limit))
+		slog.Warn("Feed lists more items than max_items, storing the newest", "feed", feedCfg.Name,
+			"items", len(items), "max_items", limit)
+		items = newest(items, limit)
+	}
+
+	prov := provenance.New(feedCfg.URL, status, strings.TrimSpace(feed.FeedType+" "+feed.FeedVersion))
+
 	processed := 0
 	failed := 0
-	for _, item := range feed.Items {
-		if err := c.processItem(opCtx, feedCfg, feed, item); err != nil {
+	for _, item := range items {
+		if ctx.Err() != nil {
+			// Shutdown or the run budget: the next fetch picks up the rest
+			return processed, fmt.Errorf("feed %s: %w", feedCfg.Name, context.Cause(ctx))
+		}
+		if err := c.processItem(opCtx, feedCfg, feed, item, prov); err != nil {
 			slog.Error("Failed to process item", "guid", item.GUID, "error", err)
 			failed++
 			continue
@@ -72,10 +379,10 @@ func (c *Client) FetchAndSave(ctx context.Context, feedCfg config.Feed) (retErr
 
 	slog.Info("Processed items", "count", processed, "feed", feedCfg.Name)
 
-	return nil
+	return processed, nil
 }
 
-func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, item *gofeed.Item) error {
+func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, item *gofeed.Item, prov provenance.Record) error {
 	// 1. Sanitize
 	content := c.policy.Sanitize(item.Content)
 	if content == "" {
@@ -89,24 +396,38 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 	}
 
 	// 2. Resolve fields
-	guid := item.GUID
-	if guid == "" {
-		guid = item.Link
-	}
+	guid := itemGUID(item)
+	// Violations of an item already stored were recorded when it was new;
+	// feeds repeat their items on every fetch.
+	chk := c.quality.Checker(feedCfg.Name)
 	if guid == "" {
+		chk.Required(item.Title, "guid", "")
+		chk.Flush(ctx)
 		return fmt.Errorf("item has no guid and no link")
 	}
+	chk.Required(guid, "title", item.Title)
+	// Stored under an ID derived from feed and GUID, so it is the same in
+	// archive and current, on every fetch and on every instance with the
+	// same namespace
+	id := AdvisoryID(c.idSpace, feedCfg.URL, guid)
+	// The same advisory linked through a redirector or with tracking
+	// parameters gets the same link in every feed
+	link := item.Link
+	if link != "" {
+		link = c.canonicalLink(ctx, feedCfg.URL, guid, item.Link)
+	}
 
-	published := time.Now()
-	if item.PublishedParsed != nil {
-		published = *item.PublishedParsed
-	} else if item.UpdatedParsed != nil {
-		published = *item.UpdatedParsed
+	// Undated items keep a NULL published date; first_seen_at records when
+	// they were stored.
+	published := itemDate(item)
+	if published != nil {
+		chk.Date(guid, "published", *published)
 	}
 
 	updated := published
 	if item.UpdatedParsed != nil {
-		updated = *item.UpdatedParsed
+		updated = item.UpdatedParsed
+		chk.Date(guid, "updated", *updated)
 	}
 
 	author := ""
@@ -134,19 +455,25 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 		INSERT INTO archive (
 			guid, title, link, published, content, summary, author, categories,
 			entry_updated, feed_url, feed_title, feed_description, feed_language,
-			feed_updated, inserted_at
+			feed_updated, inserted_at,
+			fetch_url, fetched_at, http_status, upstream_version, tool_version, id,
+			original_link
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8,
 			$9, $10, $11, $12, $13,
-			$14, NOW()
+			$14, NOW(),
+			$15, $16, $17, $18, $19, $20,
+			NULLIF($21, $3)
 		)
 		ON CONFLICT (guid, feed_url) DO NOTHING
 	`
 
 	archiveResult, err := tx.Exec(ctx, archiveQuery,
-		guid, item.Title, item.Link, published, content, summary, author, categories,
+		guid, item.Title, link, published, content, summary, author, categories,
 		updated, feedCfg.URL, feedTitle, feedDesc, feedLang,
 		time.Now(),
+		prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, id,
+		item.Link,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert archive: %w", err)
@@ -154,6 +481,11 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 
 	if archiveResult.RowsAffected() > 0 {
 		metrics.FeedItemsNew.WithLabelValues(feedCfg.Name).Inc()
+		if published != nil {
+			if lag := time.Since(*published); lag >= 0 {
+				metrics.FeedDetectionLatency.WithLabelValues(feedCfg.Name).Observe(lag.Seconds())
+			}
+		}
 	}
 
 	// 4. Current Table (Upsert)
@@ -161,15 +493,20 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 		INSERT INTO current (
 			guid, title, link, published, content, summary, author, categories,
 			entry_updated, feed_url, feed_title, feed_description, feed_language,
-			feed_updated, inserted_at
+			feed_updated, inserted_at,
+			fetch_url, fetched_at, http_status, upstream_version, tool_version, id,
+			original_link
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8,
 			$9, $10, $11, $12, $13,
-			$14, NOW()
+			$14, NOW(),
+			$15, $16, $17, $18, $19, $20,
+			NULLIF($21, $3)
 		)
 		ON CONFLICT (guid, feed_url) DO UPDATE SET
 			title = EXCLUDED.title,
 			link = EXCLUDED.link,
+			original_link = EXCLUDED.original_link,
 			published = EXCLUDED.published,
 			content = EXCLUDED.content,
 			summary = EXCLUDED.summary,
@@ -179,13 +516,20 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 			feed_url = EXCLUDED.feed_url,
 			feed_title = EXCLUDED.feed_title,
 			feed_description = EXCLUDED.feed_description,
-			feed_updated = EXCLUDED.feed_updated
+			feed_updated = EXCLUDED.feed_updated,
+			fetch_url = EXCLUDED.fetch_url,
+			fetched_at = EXCLUDED.fetched_at,
+			http_status = EXCLUDED.http_status,
+			upstream_version = EXCLUDED.upstream_version,
+			tool_version = EXCLUDED.tool_version
 	`
 
 	currentResult, err := tx.Exec(ctx, currentQuery,
-		guid, item.Title, item.Link, published, content, summary, author, categories,
+		guid, item.Title, link, published, content, summary, author, categories,
 		updated, feedCfg.URL, feedTitle, feedDesc, feedLang,
 		time.Now(),
+		prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion, id,
+		item.Link,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to upsert current: %w", err)
@@ -196,5 +540,12 @@ func (c *Client) processItem(ctx context.Context, feedCfg config.Feed, feed *gof
 		metrics.FeedItemsUpdated.WithLabelValues(feedCfg.Name).Inc()
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	if archiveResult.RowsAffected() > 0 {
+		c.budget.AddNewAdvisory()
+		chk.Flush(ctx)
+	}
+	return nil
 }
@@ -6,11 +6,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"tiger2go/internal/config"
 	"tiger2go/internal/db"
+	"tiger2go/internal/httpclient"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mmcdole/gofeed"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -158,6 +161,17 @@ func TestFetchAndSave_Integration(t *testing.T) {
 	assert.Equal(t, "Test Article One", title)
 	assert.Equal(t, "Short summary of article one", summary)
 
+	// Both tables use the derived ID
+	ns, err := ParseNamespace("")
+	require.NoError(t, err)
+	var archiveID, currentID string
+	err = testPool.QueryRow(ctx, "SELECT id::text FROM archive WHERE guid = 'test-guid-001' AND feed_url = $1", mockServer.URL).Scan(&archiveID)
+	require.NoError(t, err)
+	err = testPool.QueryRow(ctx, "SELECT id::text FROM current WHERE guid = 'test-guid-001' AND feed_url = $1", mockServer.URL).Scan(&currentID)
+	require.NoError(t, err)
+	assert.Equal(t, AdvisoryID(ns, mockServer.URL, "test-guid-001"), archiveID)
+	assert.Equal(t, archiveID, currentID)
+
 	// Second run: should be idempotent (no new archive rows)
 	err = client.FetchAndSave(ctx, feedCfg)
 	require.NoError(t, err)
@@ -288,3 +302,83 @@ func TestFetchAndSave_HTTPError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to parse feed")
 }
+
+func TestFetch_URLPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	c := New(nil)
+	c.SetURLPolicy(httpclient.URLPolicy{BlockPrivate: true})
+	_, err := c.fetch(context.Background(), server.URL)
+	assert.ErrorIs(t, err, httpclient.ErrURLNotAllowed, "loopback test server is a private address")
+
+	_, err = c.fetch(context.Background(), "file:///etc/passwd")
+	assert.ErrorIs(t, err, httpclient.ErrURLNotAllowed)
+
+	c.SetURLPolicy(httpclient.URLPolicy{})
+	_, err = c.fetch(context.Background(), server.URL)
+	assert.NoError(t, err)
+}
+
+func TestRecent_MaxItemAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { t := now.Add(-d); return &t }
+	items := []*gofeed.Item{
+		{GUID: "new", PublishedParsed: at(time.Hour)},
+		{GUID: "old", PublishedParsed: at(400 * 24 * time.Hour)},
+		{GUID: "old-but-updated", UpdatedParsed: at(24 * time.Hour)},
+		{GUID: "undated"},
+	}
+	guids := func(items []*gofeed.Item) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.GUID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"new", "old-but-updated", "undated"}, guids(recent(items, now.Add(-90*24*time.Hour))))
+	assert.Len(t, recent(items, time.Time{}), 4, "zero cutoff keeps everything")
+	assert.Len(t, items, 4, "input is not modified")
+}
+
+func TestNewest_MaxItems(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { t := now.Add(-d); return &t }
+	items := []*gofeed.Item{
+		{GUID: "undated"},
+		{GUID: "week", PublishedParsed: at(7 * 24 * time.Hour)},
+		{GUID: "hour", PublishedParsed: at(time.Hour)},
+		{GUID: "day", UpdatedParsed: at(24 * time.Hour)},
+	}
+	guids := func(items []*gofeed.Item) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.GUID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"hour", "day"}, guids(newest(items, 2)))
+	assert.Equal(t, []string{"hour", "day", "week", "undated"}, guids(newest(items, 4)))
+	assert.Equal(t, "undated", items[0].GUID, "input is not modified")
+}
+
+func TestSetMaxItems(t *testing.T) {
+	c := New(nil)
+	assert.Equal(t, defaultMaxFeedItems, c.maxItems)
+	c.SetMaxItems(0)
+	assert.Equal(t, defaultMaxFeedItems, c.maxItems, "zero keeps the default")
+	c.SetMaxItems(50)
+	assert.Equal(t, 50, c.maxItems)
+	c.SetMaxItems(-1)
+	assert.Zero(t, c.maxItems, "negative lifts the limit")
+}
+
+func TestFetchAndSave_InvalidMaxItemAge(t *testing.T) {
+	c := New(nil)
+	err := c.FetchAndSave(context.Background(), config.Feed{Name: "Bad", MaxItemAge: "ancient"})
+	assert.ErrorContains(t, err, "invalid max_item_age for feed Bad")
+}
@@ -0,0 +1,173 @@
+package ingestor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxLinkRedirects bounds the redirects followed from a redirector link.
+const maxLinkRedirects = 5
+
+// defaultRedirectors are link shorteners and feed proxies whose links only
+// redirect to the advisory. Their links are resolved; others are not
+// requested at all.
+var defaultRedirectors = []string{
+	"feedproxy.google.com", "feeds.feedburner.com", "aka.ms", "go.microsoft.com",
+	"bit.ly", "buff.ly", "dlvr.it", "lnkd.in", "ow.ly", "t.co", "tinyurl.com", "trib.al",
+}
+
+// defaultTrackingParams identify a campaign or a click, not a page. A
+// trailing "*" matches a prefix.
+var defaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok", "igshid", "ref_src",
+}
+
+// SetLinkRedirectors resolves links through hosts, besides the built-in
+// shorteners and feed proxies. An entry starting with "." matches any
+// subdomain.
+func (c *Client) SetLinkRedirectors(hosts []string) {
+	c.redirectors = append(append([]string(nil), defaultRedirectors...), hosts...)
+}
+
+// SetTrackingParams removes params from links, besides the built-in
+// utm_* and click IDs.
+func (c *Client) SetTrackingParams(params []string) {
+	c.tracking = append(append([]string(nil), defaultTrackingParams...), params...)
+}
+
+// CanonicalLink is raw with its scheme and host lowercased, a default port
+// dropped and the query parameters in strip removed. Other parameters keep
+// their order and encoding. Links that are not http or https URLs are
+// returned as is.
+func CanonicalLink(raw string, strip []string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		var kept []string
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			key, _, _ := strings.Cut(pair, "=")
+			if k, err := url.QueryUnescape(key); err == nil {
+				key = k
+			}
+			if pair != "" && !matchParam(strip, key) {
+				kept = append(kept, pair)
+			}
+		}
+		u.RawQuery = strings.Join(kept, "&")
+		u.ForceQuery = false
+	}
+	return u.String()
+}
+
+func matchParam(patterns []string, key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(key, prefix) || key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalLink is the link item is stored under: raw resolved, when it
+// points at a redirector, and made canonical. A link resolved before is
+// reused from current rather than requested again.
+func (c *Client) canonicalLink(ctx context.Context, feedURL, guid, raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || !hostMatches(c.redirectors, u.Hostname()) {
+		return CanonicalLink(raw, c.tracking)
+	}
+	var stored string
+	err = c.db.QueryRow(ctx, `
+		SELECT link FROM current
+		WHERE guid = $1 AND feed_url = $2 AND original_link = $3
+	`, guid, feedURL, raw).Scan(&stored)
+	if err == nil {
+		return stored
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return CanonicalLink(raw, c.tracking)
+	}
+
+	final, err := c.resolve(ctx, u.String())
+	if err != nil {
+		metrics.FeedLinksResolved.WithLabelValues("error").Inc()
+		return CanonicalLink(raw, c.tracking)
+	}
+	metrics.FeedLinksResolved.WithLabelValues("resolved").Inc()
+	return CanonicalLink(final, c.tracking)
+}
+
+// resolve follows the redirects of link, up to maxLinkRedirects, with HEAD
+// requests (GET where HEAD is refused) under the URL policy, and returns
+// where they end.
+func (c *Client) resolve(ctx context.Context, link string) (string, error) {
+	hc := *c.http
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	for range maxLinkRedirects {
+		if err := c.guard.CheckURL(link); err != nil {
+			return "", err
+		}
+		resp, err := c.request(ctx, &hc, http.MethodHead, link)
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp, err = c.request(ctx, &hc, http.MethodGet, link)
+		}
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+			return link, nil
+		}
+		next, err := resp.Location()
+		if err != nil {
+			return "", err
+		}
+		link = next.String()
+	}
+	return link, nil
+}
+
+func (c *Client) request(ctx context.Context, hc *http.Client, method, link string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.pf.UserAgent)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+func hostMatches(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if host == h || strings.HasPrefix(h, ".") && (strings.HasSuffix(host, h) || host == h[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalLink(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://example.com/advisory?id=42&utm_source=rss&utm_medium=feed": "https://example.com/advisory?id=42",
+		"HTTPS://Example.COM:443/a?fbclid=x":                                "https://example.com/a",
+		"http://example.com:8080/a?b=1&gclid=x&c=%20":                       "http://example.com:8080/a?b=1&c=%20",
+		"https://example.com":                                               "https://example.com/",
+		"https://example.com/a#CVE-2024-1":                                  "https://example.com/a#CVE-2024-1",
+		" https://example.com/a?UTM_Campaign=x ":                            "https://example.com/a",
+		"urn:uuid:1234":                                                     "urn:uuid:1234",
+		"":                                                                  "",
+	} {
+		assert.Equal(t, want, CanonicalLink(raw, defaultTrackingParams), raw)
+	}
+	assert.Equal(t, "https://example.com/a", CanonicalLink("https://example.com/a?src=rss", []string{"src"}))
+}
+
+func TestResolve(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+		case "/hop":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, r, srv.URL+"/advisory?utm_source=twitter", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(nil)
+	final, err := c.resolve(context.Background(), srv.URL+"/short")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/advisory?utm_source=twitter", final)
+	assert.Equal(t, srv.URL+"/advisory", CanonicalLink(final, c.tracking))
+
+	final, err = c.resolve(context.Background(), srv.URL+"/loop")
+	require.NoError(t, err, "stops after maxLinkRedirects")
+	assert.Equal(t, srv.URL+"/loop", final)
+}
+
+func TestCanonicalLink_NotARedirector(t *testing.T) {
+	c := New(nil) // no database: a redirector link would be looked up
+	c.SetTrackingParams([]string{"src"})
+	assert.Equal(t, "https://example.com/a", c.canonicalLink(context.Background(), "https://example.com/feed", "g", "https://example.com/a?src=rss&utm_id=1"))
+
+	c.SetLinkRedirectors([]string{".example.net"})
+	assert.True(t, hostMatches(c.redirectors, "go.example.net"))
+	assert.True(t, hostMatches(c.redirectors, "t.co"))
+	assert.False(t, hostMatches(c.redirectors, "example.com"))
+}
+
+func TestFetchAndSave_CanonicalLink(t *testing.T) {
+	skipIfNoDB(t)
+	ctx := context.Background()
+
+	var resolved atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			resolved.Add(1)
+			http.Redirect(w, r, "/advisory?utm_campaign=x&id=7", http.StatusMovedPermanently)
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Links</title>
+<item><guid>link-001</guid><title>Redirected</title><link>%[1]s/short</link></item>
+<item><guid>link-002</guid><title>Tracked</title><link>%[1]s/other?utm_source=rss</link></item>
+</channel></rss>`, srv.URL)
+		}
+	}))
+	defer srv.Close()
+	feedURL := srv.URL + "/feed"
+	cleanup := func() {
+		_, _ = testPool.Exec(ctx, "DELETE FROM archive WHERE feed_url = $1", feedURL)
+		_, _ = testPool.Exec(ctx, "DELETE FROM current WHERE feed_url = $1", feedURL)
+	}
+	cleanup()
+	defer cleanup()
+
+	client := New(testPool)
+	client.SetLinkRedirectors([]string{"127.0.0.1"})
+	feedCfg := config.Feed{Name: "Links", URL: feedURL}
+	for range 2 {
+		require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+	}
+	assert.EqualValues(t, 1, resolved.Load(), "a resolved link is reused")
+
+	links := map[string][2]*string{}
+	rows, err := testPool.Query(ctx, "SELECT guid, link, original_link FROM current WHERE feed_url = $1", feedURL)
+	require.NoError(t, err)
+	for rows.Next() {
+		var guid string
+		var link, original *string
+		require.NoError(t, rows.Scan(&guid, &link, &original))
+		links[guid] = [2]*string{link, original}
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, links, 2)
+	assert.Equal(t, srv.URL+"/advisory?id=7", *links["link-001"][0])
+	assert.Equal(t, srv.URL+"/short", *links["link-001"][1])
+	assert.Equal(t, srv.URL+"/other", *links["link-002"][0])
+	assert.Equal(t, srv.URL+"/other?utm_source=rss", *links["link-002"][1])
+}
@@ -0,0 +1,89 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Advisory is one row of the current table as served by the list and
+// change feed APIs. It carries every content column so a mirror can
+// rebuild the row.
+type Advisory struct {
+	ID              string     `json:"id"`
+	GUID            string     `json:"guid"`
+	Title           string     `json:"title"`
+	Link            string     `json:"link"`
+	Published       *time.Time `json:"published"`
+	Content         string     `json:"content"`
+	Summary         string     `json:"summary"`
+	Author          string     `json:"author"`
+	Categories      []string   `json:"categories"`
+	EntryUpdated    *time.Time `json:"entry_updated"`
+	FeedURL         string     `json:"feed_url"`
+	FeedTitle       string     `json:"feed_title"`
+	FeedDescription string     `json:"feed_description"`
+	FeedLanguage    string     `json:"feed_language"`
+	FeedUpdated     *time.Time `json:"feed_updated"`
+	FirstSeenAt     time.Time  `json:"first_seen_at"` // when tigerfetch first stored it
+	UpdatedAt       time.Time  `json:"updated_at"`
+	WithdrawnAt     *time.Time `json:"withdrawn_at"` // when it dropped out of its feed; nil while listed
+}
+
+// Cursor returns the position just after a.
+func (a Advisory) Cursor() cursor.Cursor {
+	return cursor.Cursor{UpdatedAt: a.UpdatedAt, Key: []string{a.ID}}
+}
+
+// AdvisoryCursorKeys is the number of key parts in an advisory cursor.
+const AdvisoryCursorKeys = 1
+
+// zeroUUID sorts before every id, for the start cursor.
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// ListAdvisories returns one page of current rows in (updated_at, id)
+// order. feedURL optionally restricts the list to one feed and w to items
+// published or first seen in a time range.
+func ListAdvisories(ctx context.Context, pool *pgxpool.Pool, page cursor.Page, feedURL string, w window.Window) ([]Advisory, error) {
+	id := page.After.KeyPart(0)
+	if id == "" {
+		id = zeroUUID
+	}
+	since, until := w.Bounds()
+	rows, err := pool.Query(ctx, `
+		SELECT id::text, guid, title, link, published, COALESCE(content, ''), COALESCE(summary, ''),
+		       COALESCE(author, ''), COALESCE(categories, '{}'), entry_updated, feed_url,
+		       COALESCE(feed_title, ''), COALESCE(feed_description, ''), COALESCE(feed_language, ''),
+		       feed_updated, first_seen_at, updated_at, withdrawn_at
+		FROM current
+		WHERE (updated_at, id) > ($1, $2::uuid)
+		  AND updated_at < clock_timestamp() - $3::interval
+		  AND ($4 = '' OR feed_url = $4)
+		  AND ($5::timestamptz IS NULL OR `+w.Column()+` >= $5)
+		  AND ($6::timestamptz IS NULL OR `+w.Column()+` < $6)
+		ORDER BY updated_at, id
+		LIMIT $7
+	`, page.After.UpdatedAt, id, page.Settle, feedURL, since, until, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list advisories: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Advisory
+	for rows.Next() {
+		var a Advisory
+		if err := rows.Scan(&a.ID, &a.GUID, &a.Title, &a.Link, &a.Published, &a.Content, &a.Summary,
+			&a.Author, &a.Categories, &a.EntryUpdated, &a.FeedURL,
+			&a.FeedTitle, &a.FeedDescription, &a.FeedLanguage,
+			&a.FeedUpdated, &a.FirstSeenAt, &a.UpdatedAt, &a.WithdrawnAt); err != nil {
+			return nil, fmt.Errorf("scan advisory: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,126 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mmcdole/gofeed"
+)
+
+// itemGUID is how an item is keyed in storage: its GUID, else its link.
+func itemGUID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	return item.Link
+}
+
+// missedItem is a stored item a fetch did not list.
+type missedItem struct {
+	guid      string
+	withdrawn bool // missing long enough to be marked withdrawn now
+}
+
+// listingWindow returns the GUIDs feed lists and the oldest date among
+// its items, nil when none is dated.
+func listingWindow(feed *gofeed.Feed) ([]string, *time.Time) {
+	var guids []string
+	var oldest *time.Time
+	for _, item := range feed.Items {
+		if guid := itemGUID(item); guid != "" {
+			guids = append(guids, guid)
+		}
+		if d := itemDate(item); d != nil && (oldest == nil || d.Before(*oldest)) {
+			oldest = d
+		}
+	}
+	return guids, oldest
+}
+
+// trackWithdrawals compares what feed lists with what is stored for it.
+// Feeds only list their latest items, so an item that falls off the end
+// has merely aged out; one missing while older items are still listed has
+// been taken down. After after such fetches in a row it is marked
+// withdrawn, and it is reinstated if listed again. Undated items are never
+// marked, nor are items of a fetch that listed nothing dated.
+func (c *Client) trackWithdrawals(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, after int) error {
+	guids, oldest := listingWindow(feed)
+	if len(guids) == 0 {
+		return nil
+	}
+	if _, err := c.db.Exec(ctx, `
+		UPDATE current SET missed_fetches = 0, withdrawn_at = NULL
+		WHERE feed_url = $1 AND guid = ANY($2) AND (missed_fetches > 0 OR withdrawn_at IS NOT NULL)
+	`, feedCfg.URL, guids); err != nil {
+		return fmt.Errorf("reset missed fetches: %w", err)
+	}
+	if oldest == nil || after <= 0 {
+		return nil
+	}
+
+	rows, err := c.db.Query(ctx, `
+		UPDATE current SET
+			missed_fetches = missed_fetches + 1,
+			withdrawn_at = CASE WHEN missed_fetches + 1 >= $4 THEN now() END
+		WHERE feed_url = $1 AND guid <> ALL($2) AND published > $3 AND withdrawn_at IS NULL
+		RETURNING guid, withdrawn_at IS NOT NULL
+	`, feedCfg.URL, guids, *oldest, after)
+	if err != nil {
+		return fmt.Errorf("count missed fetches: %w", err)
+	}
+	missing, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (missedItem, error) {
+		var m missedItem
+		err := row.Scan(&m.guid, &m.withdrawn)
+		return m, err
+	})
+	if err != nil {
+		return fmt.Errorf("count missed fetches: %w", err)
+	}
+	for _, m := range missing {
+		if m.withdrawn {
+			metrics.FeedItemsWithdrawn.WithLabelValues(feedCfg.Name).Inc()
+			slog.Info("Advisory withdrawn from feed", "feed", feedCfg.Name, "guid", m.guid, "missed_fetches", after)
+		}
+	}
+	return nil
+}
+
+// markPushed applies the withdrawals of a push: pushing sources say when
+// an advisory is withdrawn instead of dropping it from a listing.
+func (c *Client) markPushed(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) error {
+	guids, _ := listingWindow(feed)
+	if len(guids) == 0 {
+		return nil
+	}
+	rows, err := c.db.Query(ctx, `
+		UPDATE current SET
+			missed_fetches = 0,
+			withdrawn_at = CASE WHEN guid = ANY($3) THEN COALESCE(withdrawn_at, now()) END
+		WHERE feed_url = $1 AND guid = ANY($2) AND (withdrawn_at IS NOT NULL OR guid = ANY($3))
+		RETURNING guid, COALESCE(withdrawn_at = now(), false)
+	`, feedCfg.URL, guids, withdrawn)
+	if err != nil {
+		return fmt.Errorf("mark withdrawn: %w", err)
+	}
+	marked, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (missedItem, error) {
+		var m missedItem
+		err := row.Scan(&m.guid, &m.withdrawn)
+		return m, err
+	})
+	if err != nil {
+		return fmt.Errorf("mark withdrawn: %w", err)
+	}
+	for _, m := range marked {
+		if m.withdrawn {
+			metrics.FeedItemsWithdrawn.WithLabelValues(feedCfg.Name).Inc()
+			slog.Info("Advisory withdrawn by its source", "feed", feedCfg.Name, "guid", m.guid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListingWindow(t *testing.T) {
+	jan, feb := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	guids, oldest := listingWindow(&gofeed.Feed{Items: []*gofeed.Item{
+		{GUID: "a", PublishedParsed: &feb},
+		{Link: "https://example.com/b", UpdatedParsed: &jan},
+		{Title: "no guid or link"},
+	}})
+	assert.Equal(t, []string{"a", "https://example.com/b"}, guids)
+	require.NotNil(t, oldest)
+	assert.Equal(t, jan, *oldest)
+
+	_, oldest = listingWindow(&gofeed.Feed{Items: []*gofeed.Item{{GUID: "undated"}}})
+	assert.Nil(t, oldest)
+}
+
+// withdrawalDays dates the items of withdrawalFeed.
+var withdrawalDays = map[string]int{"newer": 11, "new": 10, "middle": 9, "old": 8}
+
+// withdrawalFeed lists items by GUID.
+func withdrawalFeed(guids ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Withdrawals</title>`)
+	for _, g := range guids {
+		day := time.Date(2024, 3, withdrawalDays[g], 0, 0, 0, 0, time.UTC)
+		fmt.Fprintf(&b, `<item><title>%s</title><link>https://example.com/%s</link><guid>%s</guid><pubDate>%s</pubDate></item>`,
+			g, g, g, day.Format(time.RFC1123Z))
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
+
+func TestFetchAndSave_Withdrawal(t *testing.T) {
+	skipIfNoDB(t)
+	ctx := context.Background()
+
+	var body atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+	feedCfg := config.Feed{Name: "Withdrawals", URL: srv.URL}
+	cleanup := func() { _, _ = testPool.Exec(ctx, "DELETE FROM current WHERE feed_url = $1", srv.URL) }
+	cleanup()
+	defer cleanup()
+
+	withdrawn := func(guid string) bool {
+		var at *time.Time
+		require.NoError(t, testPool.QueryRow(ctx,
+			"SELECT withdrawn_at FROM current WHERE feed_url = $1 AND guid = $2", srv.URL, guid).Scan(&at))
+		return at != nil
+	}
+
+	client := New(testPool)
+	client.SetWithdrawAfter(2)
+	body.Store(withdrawalFeed("new", "middle", "old"))
+	require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+
+	// "middle" is taken down while "old" is still listed; "old" then ages out
+	body.Store(withdrawalFeed("new", "old"))
+	require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+	assert.False(t, withdrawn("middle"), "missing from one fetch only")
+	require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+	assert.True(t, withdrawn("middle"))
+
+	body.Store(withdrawalFeed("newer", "new"))
+	for range 3 {
+		require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+	}
+	assert.False(t, withdrawn("old"), "older than everything listed: aged out")
+
+	// Listed again: reinstated
+	body.Store(withdrawalFeed("newer", "new", "middle"))
+	require.NoError(t, client.FetchAndSave(ctx, feedCfg))
+	assert.False(t, withdrawn("middle"))
+}
+
+func TestStore_PushedWithdrawal(t *testing.T) {
+	skipIfNoDB(t)
+	ctx := context.Background()
+	feedCfg := config.Feed{Name: "Pushed", URL: "inbound:test-pushed"}
+	cleanup := func() { _, _ = testPool.Exec(ctx, "DELETE FROM current WHERE feed_url = $1", feedCfg.URL) }
+	cleanup()
+	defer cleanup()
+
+	withdrawn := func(guid string) bool {
+		var at *time.Time
+		require.NoError(t, testPool.QueryRow(ctx,
+			"SELECT withdrawn_at FROM current WHERE feed_url = $1 AND guid = $2", feedCfg.URL, guid).Scan(&at))
+		return at != nil
+	}
+	old := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	feed := func(guids ...string) *gofeed.Feed {
+		f := &gofeed.Feed{Title: "Pushed", FeedType: "webhook"}
+		for _, g := range guids {
+			f.Items = append(f.Items, &gofeed.Item{GUID: g, Title: g, PublishedParsed: &old})
+		}
+		return f
+	}
+
+	client := New(testPool)
+	client.SetMaxItemAge(24 * time.Hour)
+	n, err := client.Store(ctx, feedCfg, feed("a", "b"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "no age cutoff for pushes")
+
+	_, err = client.Store(ctx, feedCfg, feed("b"), []string{"b"})
+	require.NoError(t, err)
+	assert.True(t, withdrawn("b"))
+	assert.False(t, withdrawn("a"), "not in the push")
+
+	_, err = client.Store(ctx, feedCfg, feed("b"), nil)
+	require.NoError(t, err)
+	assert.False(t, withdrawn("b"), "pushed again: reinstated")
+}
@@ -0,0 +1,227 @@
+// Package ingeststate reads and adjusts the cursors runners keep in the
+// ingest_state table, for `tigerfetch cursor`. Each source has its own
+// cursor format; Validate checks a new value against it, so an operator
+// moving a cursor cannot leave one its runner fails to read or silently
+// resets.
+package ingeststate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/maillist"
+	"tiger2go/internal/mirror"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sources with a fixed name; products, remotes and mailing lists add
+// prefixed ones.
+const (
+	NVD         = "NVD"          // publication backfill, RFC 3339
+	NVDModified = "NVD-MODIFIED" // modified sync, RFC 3339
+	KEV         = "CISA-KEV"     // catalog release date (RFC 3339) or version
+	Alerting    = "ALERTING"     // date of the last sleeper alerts
+	AlertingKEV = "ALERTING-KEV" // last kev_changes ID notified
+	Consolidate = "CONSOLIDATE"  // list cursor into cve_enriched
+
+	NVDProductPrefix = "NVD-PRODUCT:"        // + [[nvd.products]] name, RFC 3339
+	MirrorPrefix     = "MIRROR:"             // + remote base URL, the remote's /changes cursor
+	MailPrefix       = maillist.CursorPrefix // + [[mail.lists]] name, UIDVALIDITY:UID
+)
+
+// ErrUnknownSource is returned for sources no runner keeps a cursor under.
+var ErrUnknownSource = errors.New("unknown cursor source")
+
+// kevVersion is a KEV catalogVersion, e.g. "2026.03.14".
+var kevVersion = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}$`)
+
+// Entry is one stored cursor.
+type Entry struct {
+	Source string `json:"source"`
+	Cursor string `json:"cursor"`
+}
+
+// List returns every stored cursor by source.
+func List(ctx context.Context, db *pgxpool.Pool) ([]Entry, error) {
+	rows, err := db.Query(ctx, "SELECT source, cursor FROM ingest_state ORDER BY source")
+	if err != nil {
+		return nil, fmt.Errorf("list cursors: %w", err)
+	}
+	list, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Entry])
+	if err != nil {
+		return nil, fmt.Errorf("list cursors: %w", err)
+	}
+	return list, nil
+}
+
+// Get returns the cursor of source; ok is false when none is stored.
+func Get(ctx context.Context, db *pgxpool.Pool, source string) (cur string, ok bool, err error) {
+	err = db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", source).Scan(&cur)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("read cursor %s: %w", source, err)
+	}
+	return cur, true, nil
+}
+
+// Set stores cur as the cursor of source. Run Validate first.
+func Set(ctx context.Context, db *pgxpool.Pool, source, cur string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO ingest_state (source, cursor) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+	`, source, cur)
+	if err != nil {
+		return fmt.Errorf("set cursor %s: %w", source, err)
+	}
+	return nil
+}
+
+// Reset deletes the cursor of source, so its runner starts as on its first
+// run (see ResetEffect). It reports whether there was one.
+func Reset(ctx context.Context, db *pgxpool.Pool, source string) (bool, error) {
+	tag, err := db.Exec(ctx, "DELETE FROM ingest_state WHERE source = $1", source)
+	if err != nil {
+		return false, fmt.Errorf("reset cursor %s: %w", source, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Known reports whether a runner keeps a cursor under source.
+func Known(source string) bool {
+	switch source {
+	case NVD, NVDModified, KEV, Alerting, AlertingKEV, Consolidate:
+		return true
+	}
+	return strings.HasPrefix(source, NVDProductPrefix) && len(source) > len(NVDProductPrefix) ||
+		strings.HasPrefix(source, MirrorPrefix) && len(source) > len(MirrorPrefix) ||
+		strings.HasPrefix(source, MailPrefix) && len(source) > len(MailPrefix)
+}
+
+// Validate checks value as a new cursor of source at now and returns it
+// in the form the runner writes. Timestamps may be given as RFC 3339 or a
+// date (midnight UTC) and must not be in the future. List cursors
+// (CONSOLIDATE, MIRROR:) are opaque positions only a runner can produce:
+// they can be reset, not set.
+func Validate(source, value string, now time.Time) (string, error) {
+	if !Known(source) {
+		return "", fmt.Errorf("%w %q", ErrUnknownSource, source)
+	}
+	value = strings.TrimSpace(value)
+	switch {
+	case source == NVD, source == NVDModified, strings.HasPrefix(source, NVDProductPrefix):
+		return timestamp(value, now)
+	case source == KEV:
+		if kevVersion.MatchString(value) {
+			return value, nil
+		}
+		t, err := timestamp(value, now)
+		if err != nil {
+			return "", fmt.Errorf("%w, or a catalog version such as 2026.03.14", err)
+		}
+		return t, nil
+	case source == Alerting:
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return "", fmt.Errorf("want a date such as 2026-03-14: %w", err)
+		}
+		if t.After(now) {
+			return "", errors.New("date is in the future")
+		}
+		return value, nil
+	case source == AlertingKEV:
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 0 {
+			return "", fmt.Errorf("want a kev_changes ID (0 or more), not %q", value)
+		}
+		return strconv.FormatInt(id, 10), nil
+	case strings.HasPrefix(source, MailPrefix):
+		validity, uid, err := maillist.ParseCursor(value)
+		if err != nil {
+			return "", err
+		}
+		return maillist.FormatCursor(validity, uid), nil
+	}
+	return "", errors.New("an opaque list position only its runner can produce; reset it instead")
+}
+
+func timestamp(value string, now time.Time) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		var derr error
+		if t, derr = time.Parse(time.DateOnly, value); derr != nil {
+			return "", fmt.Errorf("want an RFC 3339 time such as 2026-03-14T00:00:00Z or a date: %w", err)
+		}
+	}
+	if t.After(now) {
+		return "", errors.New("time is in the future")
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// Describe renders a stored cursor for people: timestamps with their age at
+// now, list cursors decoded. It returns "" when there is nothing to add to
+// the cursor itself.
+func Describe(source, value string, now time.Time) string {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return fmt.Sprintf("%s ago", now.Sub(t).Round(time.Second))
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil && source == Alerting {
+		return fmt.Sprintf("%d days ago", int(now.Sub(t).Hours()/24))
+	}
+	if strings.HasPrefix(source, MailPrefix) {
+		if validity, uid, err := maillist.ParseCursor(value); err == nil {
+			return fmt.Sprintf("after UID %d of mailbox UIDVALIDITY %d", uid, validity)
+		}
+		return ""
+	}
+	keyLen := 0
+	switch {
+	case source == Consolidate:
+		keyLen = cve.EnrichedCursorKeys
+	case strings.HasPrefix(source, MirrorPrefix):
+		keyLen = mirror.CursorKeys
+	default:
+		return ""
+	}
+	if c, err := cursor.Parse(value, keyLen); err == nil && !c.IsZero() {
+		return fmt.Sprintf("after %s %s", c.UpdatedAt.UTC().Format(time.RFC3339), strings.Join(c.Key, "/"))
+	}
+	return ""
+}
+
+// ResetEffect says what the runner of source does once its cursor is
+// reset, for the confirmation prompt.
+func ResetEffect(source string) string {
+	switch {
+	case source == NVD:
+		return "the NVD publication backfill starts again from 2000-01-01, re-fetching every CVE"
+	case source == NVDModified:
+		return "the NVD modified sync restarts from the NVD cursor"
+	case strings.HasPrefix(source, NVDProductPrefix):
+		return "the product monitor's next run looks back nvd.products_lookback again"
+	case source == KEV:
+		return "the next KEV run processes the current catalog again"
+	case source == Alerting:
+		return "sleeper alerts for the latest date are sent again, subject to webhook cooldowns"
+	case source == AlertingKEV:
+		return "KEV additions within alerting.lookback_days are notified again"
+	case source == Consolidate:
+		return "every CVE is consolidated again"
+	case strings.HasPrefix(source, MirrorPrefix):
+		return "the remote's whole change feed is pulled again"
+	case strings.HasPrefix(source, MailPrefix):
+		return "the list's posts within mail.lookback are read again"
+	}
+	return "its runner starts as on its first run"
+}
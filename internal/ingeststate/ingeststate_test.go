@@ -0,0 +1,107 @@
+package ingeststate
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		source, value, want string
+	}{
+		{NVD, "2026-03-01T08:30:00Z", "2026-03-01T08:30:00Z"},
+		{NVDModified, "2026-03-01T08:30:00+02:00", "2026-03-01T06:30:00Z"},
+		{"NVD-PRODUCT:openssl", " 2026-03-01 ", "2026-03-01T00:00:00Z"},
+		{KEV, "2026.03.10", "2026.03.10"},
+		{KEV, "2026-03-10T15:00:00Z", "2026-03-10T15:00:00Z"},
+		{Alerting, "2026-03-13", "2026-03-13"},
+		{AlertingKEV, "0042", "42"},
+		{"MAIL:oss-security", "7:0120", "7:120"},
+	} {
+		got, err := Validate(tc.source, tc.value, now)
+		if assert.NoError(t, err, "%s %q", tc.source, tc.value) {
+			assert.Equal(t, tc.want, got)
+		}
+	}
+
+	for _, tc := range []struct{ source, value string }{
+		{NVD, "2026-03-01 08:30"},
+		{NVD, "2026-03-15T00:00:00Z"}, // future
+		{KEV, "2026.3.10"},
+		{Alerting, "2026-03-13T00:00:00Z"},
+		{Alerting, "2026-03-15"},
+		{AlertingKEV, "-1"},
+		{Consolidate, ""},
+		{"MIRROR:https://peer.example", "x"},
+		{"NVD-PRODUCT:", "2026-03-01"},
+		{"MAIL:oss-security", "120"},
+		{"MAIL:", "7:120"},
+		{"nvd", "2026-03-01"},
+	} {
+		_, err := Validate(tc.source, tc.value, now)
+		assert.Error(t, err, "%s %q", tc.source, tc.value)
+	}
+	_, err := Validate("EPSS", "2026-03-01", now)
+	assert.ErrorIs(t, err, ErrUnknownSource)
+}
+
+func TestDescribe(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2h0m0s ago", Describe(NVD, "2026-03-14T10:00:00Z", now))
+	assert.Equal(t, "2 days ago", Describe(Alerting, "2026-03-12", now))
+	assert.Empty(t, Describe(AlertingKEV, "42", now))
+	assert.Equal(t, "after UID 120 of mailbox UIDVALIDITY 7", Describe("MAIL:oss-security", "7:120", now))
+
+	c := cursor.Cursor{UpdatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Key: []string{"CVE-2026-0001", "x"}}
+	assert.Equal(t, "after 2026-03-01T00:00:00Z CVE-2026-0001/x", Describe(Consolidate, c.String(), now))
+	assert.Empty(t, Describe(Consolidate, "garbage", now))
+}
+
+// TestCursors requires a running DB.
+func TestCursors(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+	const source = "NVD-PRODUCT:ingeststate-test"
+	cleanup := func() { _, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = $1", source) }
+	cleanup()
+	defer cleanup()
+
+	_, ok, err = Get(ctx, pool, source)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, Set(ctx, pool, source, "2026-03-01T00:00:00Z"))
+	require.NoError(t, Set(ctx, pool, source, "2026-03-02T00:00:00Z"))
+	cur, ok, err := Get(ctx, pool, source)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2026-03-02T00:00:00Z", cur)
+
+	list, err := List(ctx, pool)
+	require.NoError(t, err)
+	assert.Contains(t, list, Entry{Source: source, Cursor: cur})
+
+	removed, err := Reset(ctx, pool, source)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = Reset(ctx, pool, source)
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
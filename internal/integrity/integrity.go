@@ -0,0 +1,135 @@
+// Package integrity writes export artifacts together with a SHA-256 manifest
+// and optional Ed25519 signatures, so consumers can check that a file has not
+// been altered between the collector and the reader.
+//
+// The manifest is a SHA256SUMS file in the artifact's directory, in the
+// format `sha256sum -c` reads. Signatures are minisign-compatible (legacy
+// "Ed" algorithm) and verify with `minisign -Vm <file> -P <public key>`.
+package integrity
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestName is the checksum manifest written next to artifacts.
+const ManifestName = "SHA256SUMS"
+
+// WriteArtifact writes data to path atomically, records its checksum in the
+// directory's manifest and, when signer is non-nil, writes path.minisig.
+func WriteArtifact(path string, data []byte, signer *Signer) error {
+	if err := writeAtomic(path, data, 0o644); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	if err := UpdateManifest(filepath.Dir(path), filepath.Base(path), hex.EncodeToString(sum[:])); err != nil {
+		return err
+	}
+
+	if signer != nil {
+		sig := signer.Sign(data, "file:"+filepath.Base(path))
+		if err := writeAtomic(path+".minisig", sig, 0o644); err != nil {
+			return fmt.Errorf("write signature: %w", err)
+		}
+	}
+	return nil
+}
+
+// UpdateManifest sets the checksum for name in dir's manifest, keeping the
+// other entries, and rewrites it sorted by file name.
+func UpdateManifest(dir, name, sum string) error {
+	path := filepath.Join(dir, ManifestName)
+	entries, err := ReadManifest(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if entries == nil {
+		entries = make(map[string]string)
+	}
+	entries[name] = sum
+
+	names := make([]string, 0, len(entries))
+	for n := range entries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&b, "%s  %s\n", entries[n], n)
+	}
+	return writeAtomic(path, []byte(b.String()), 0o644)
+}
+
+// ReadManifest parses a SHA256SUMS file into file name -> hex checksum.
+func ReadManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	entries := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("malformed manifest line %q", line)
+		}
+		entries[strings.TrimPrefix(name, "*")] = sum
+	}
+	return entries, sc.Err()
+}
+
+// VerifyFile checks path against the checksum recorded in its directory's manifest.
+func VerifyFile(path string) error {
+	entries, err := ReadManifest(filepath.Join(filepath.Dir(path), ManifestName))
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	want, ok := entries[filepath.Base(path)]
+	if !ok {
+		return fmt.Errorf("%s is not listed in %s", filepath.Base(path), ManifestName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: manifest %s, file %s", filepath.Base(path), want, got)
+	}
+	return nil
+}
+
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
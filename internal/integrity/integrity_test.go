@@ -0,0 +1,82 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteArtifact_ManifestAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "b.json")
+	b := filepath.Join(dir, "a.json")
+
+	require.NoError(t, WriteArtifact(a, []byte(`{"b":1}`), nil))
+	require.NoError(t, WriteArtifact(b, []byte(`{"a":1}`), nil))
+
+	manifest, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(manifest)), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], "  a.json"), "manifest is sorted by name")
+	// sha256("{\"a\":1}")
+	assert.True(t, strings.HasPrefix(lines[0], "015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862"))
+
+	require.NoError(t, VerifyFile(a))
+	require.NoError(t, os.WriteFile(a, []byte(`{"b":2}`), 0o644))
+	assert.ErrorContains(t, VerifyFile(a), "checksum mismatch")
+
+	// Rewriting an artifact replaces its entry rather than duplicating it.
+	require.NoError(t, WriteArtifact(a, []byte(`{"b":3}`), nil))
+	entries, err := ReadManifest(filepath.Join(dir, ManifestName))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, VerifyFile(a))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "tigerfetch.key")
+
+	signer, err := GenerateKey(keyPath)
+	require.NoError(t, err)
+	info, err := os.Stat(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = GenerateKey(keyPath)
+	assert.Error(t, err, "existing keys are never overwritten")
+
+	loaded, err := LoadSigner(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, signer.PublicKey(), loaded.PublicKey())
+
+	path := filepath.Join(dir, "kev.json")
+	data := []byte(`[{"cve_id":"CVE-2024-0001"}]`)
+	require.NoError(t, WriteArtifact(path, data, loaded))
+
+	sig, err := os.ReadFile(path + ".minisig")
+	require.NoError(t, err)
+	assert.Contains(t, string(sig), "trusted comment: file:kev.json")
+
+	assert.NoError(t, Verify(signer.PublicKey(), data, sig))
+	assert.ErrorIs(t, Verify(signer.PublicKey(), []byte("tampered"), sig), ErrBadSignature)
+
+	tamperedComment := strings.Replace(string(sig), "file:kev.json", "file:other.json", 1)
+	assert.ErrorIs(t, Verify(signer.PublicKey(), data, []byte(tamperedComment)), ErrBadSignature)
+
+	other, err := GenerateKey(filepath.Join(dir, "other.key"))
+	require.NoError(t, err)
+	assert.Error(t, Verify(other.PublicKey(), data, sig))
+}
+
+func TestLoadSigner_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.key")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+	_, err := LoadSigner(path)
+	assert.Error(t, err)
+}
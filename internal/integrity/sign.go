@@ -0,0 +1,138 @@
+package integrity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// minisign's legacy (non-prehashed) Ed25519 algorithm identifier.
+var sigAlgEd = []byte("Ed")
+
+// ErrBadSignature is returned by Verify when a signature does not match.
+var ErrBadSignature = errors.New("signature verification failed")
+
+// Signer signs artifacts with a local Ed25519 key.
+type Signer struct {
+	priv  ed25519.PrivateKey
+	keyID [8]byte
+}
+
+// GenerateKey writes a new secret key file (mode 0600) and returns its signer.
+// The file holds base64(key id || Ed25519 seed) on one line.
+func GenerateKey(path string) (*Signer, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	raw := append(id[:], seed...)
+	line := base64.StdEncoding.EncodeToString(raw) + "\n"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &Signer{priv: ed25519.NewKeyFromSeed(seed), keyID: id}, nil
+}
+
+// LoadSigner reads a secret key file written by GenerateKey.
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != 8+ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key file %s", path)
+	}
+	s := &Signer{priv: ed25519.NewKeyFromSeed(raw[8:])}
+	copy(s.keyID[:], raw[:8])
+	return s, nil
+}
+
+// PublicKey returns the public key in minisign's base64 format, suitable for
+// `minisign -P`.
+func (s *Signer) PublicKey() string {
+	pub := s.priv.Public().(ed25519.PublicKey)
+	buf := append(append(append([]byte{}, sigAlgEd...), s.keyID[:]...), pub...)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// Sign returns a minisign signature file for data. The trusted comment is
+// covered by the signature; minisign prints it on successful verification.
+func (s *Signer) Sign(data []byte, trustedComment string) []byte {
+	sig := ed25519.Sign(s.priv, data)
+	sigBlob := append(append(append([]byte{}, sigAlgEd...), s.keyID[:]...), sig...)
+	global := ed25519.Sign(s.priv, append(append([]byte{}, sig...), trustedComment...))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "untrusted comment: signature from tigerfetch key %X\n", reverse(s.keyID))
+	b.WriteString(base64.StdEncoding.EncodeToString(sigBlob) + "\n")
+	b.WriteString("trusted comment: " + trustedComment + "\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(global) + "\n")
+	return b.Bytes()
+}
+
+// Verify checks a minisign signature file against data and a public key in
+// minisign format.
+func Verify(publicKey string, data, sigFile []byte) error {
+	pkRaw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(pkRaw) != 2+8+ed25519.PublicKeySize || !bytes.Equal(pkRaw[:2], sigAlgEd) {
+		return errors.New("invalid public key")
+	}
+
+	lines := strings.Split(strings.TrimRight(string(sigFile), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature file")
+	}
+	sigBlob, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sigBlob) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !bytes.Equal(sigBlob[:2], sigAlgEd) {
+		return fmt.Errorf("unsupported signature algorithm %q", sigBlob[:2])
+	}
+	if !bytes.Equal(sigBlob[2:10], pkRaw[2:10]) {
+		return errors.New("signature was made with a different key")
+	}
+
+	pub := ed25519.PublicKey(pkRaw[10:])
+	sig := sigBlob[10:]
+	if !ed25519.Verify(pub, data, sig) {
+		return ErrBadSignature
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return errors.New("malformed global signature")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pub, append(append([]byte{}, sig...), trusted...), global) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// reverse returns the key id in the byte order minisign displays it.
+func reverse(id [8]byte) []byte {
+	out := make([]byte, 8)
+	for i := range id {
+		out[7-i] = id[i]
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+// Package ioc finds indicators of compromise in advisory text: IP
+// addresses, domains, URLs, file hashes and file paths. CERT advisories
+// list the infrastructure and payloads of a campaign next to its CVEs;
+// this keeps them as structured data for blocklists.
+//
+// Domains and URLs count only when written defanged ("evil[.]com",
+// "hxxp://..."), the convention advisories use for malicious
+// infrastructure; plain links are references to vendor pages and write-ups.
+// IP addresses count either way, except private, reserved and
+// documentation ranges and dotted version numbers ("versions 9.1.1.1
+// through 9.1.1.5").
+package ioc
+
+import (
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"tiger2go/internal/cveid"
+)
+
+// Indicator types.
+const (
+	TypeIPv4   = "ipv4"
+	TypeIPv6   = "ipv6"
+	TypeDomain = "domain"
+	TypeURL    = "url"
+	TypeMD5    = "md5"
+	TypeSHA1   = "sha1"
+	TypeSHA256 = "sha256"
+	TypeSHA512 = "sha512"
+	TypePath   = "path"
+)
+
+// Types lists every indicator type.
+var Types = []string{TypeIPv4, TypeIPv6, TypeDomain, TypeURL, TypeMD5, TypeSHA1, TypeSHA256, TypeSHA512, TypePath}
+
+// Indicator is one extracted IOC. Values are refanged; domains, URL
+// schemes and hosts, and hashes are lower-cased.
+type Indicator struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+var (
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+	// defang markers, undone by refanger
+	defangMarkers = []string{"[.]", "(.)", "{.}", "[dot]", "(dot)", "hxxp", "fxp", "[:]", "[://]"}
+	refanger      = strings.NewReplacer(
+		"[.]", ".", "(.)", ".", "{.}", ".", "[dot]", ".", "(dot)", ".",
+		"[://]", "://", "[:]", ":",
+	)
+	schemeFix = regexp.MustCompile(`(?i)^(?:hxxp(s?)|fxp)://`)
+
+	domainPattern = regexp.MustCompile(`(?i)^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}$`)
+	hexPattern    = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+	// Windows paths may contain spaces in directory names, so they are
+	// matched over the text rather than per word.
+	windowsPath = regexp.MustCompile(`(?i)(?:\b[a-z]:\\|%[a-z_]+%\\)(?:[^\\/:*?"<>|\r\n]+\\)*[^\\/:*?"<>|\s]+`)
+	unixPath    = regexp.MustCompile(`^/(?:tmp|var|etc|usr|opt|home|root|dev/shm|bin|sbin|lib|lib64|boot|srv|mnt|Library|Users|Applications|System)/[^\s"'<>]+$`)
+
+	// documentation and shared (CGNAT) ranges, which IsPrivate misses
+	nonPublic = []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	// words after which a dotted quad is a version, not an address
+	versionWords = []string{"version", "versions", "ver", "v", "build", "release", "releases", "firmware", "through", "prior", "before", "patch"}
+)
+
+// Extract returns the distinct indicators in texts: per text, Windows paths
+// first, then the rest in order of appearance. Texts may be HTML fragments;
+// tags are dropped and entities decoded.
+func Extract(texts ...string) []Indicator {
+	var out []Indicator
+	add := func(typ, value string) {
+		ind := Indicator{Type: typ, Value: value}
+		if !slices.Contains(out, ind) {
+			out = append(out, ind)
+		}
+	}
+	for _, t := range texts {
+		t = cveid.Normalize(tagPattern.ReplaceAllString(t, " "))
+		for _, m := range windowsPath.FindAllString(t, -1) {
+			add(TypePath, strings.TrimRight(m, ".,;:"))
+		}
+		prev := ""
+		for _, word := range strings.Fields(t) {
+			if typ, value := classify(word, prev); typ != "" {
+				add(typ, value)
+				if typ == TypeURL {
+					if host, ok := hostIndicator(value); ok {
+						add(host.Type, host.Value)
+					}
+				}
+			}
+			prev = strings.ToLower(strings.Trim(word, `.,;:!?()[]{}<>"'`))
+		}
+	}
+	return out
+}
+
+// classify returns the type and refanged value of one whitespace-separated
+// word, or "" when it is not an indicator. prev is the word before it.
+func classify(word, prev string) (typ, value string) {
+	defanged := false
+	for _, m := range defangMarkers {
+		if strings.Contains(strings.ToLower(word), m) {
+			defanged = true
+			break
+		}
+	}
+	w := refanger.Replace(word)
+	w = strings.TrimLeft(w, `([{<"'`)
+	w = strings.TrimRight(w, `.,;:!?)]}>"'`)
+	if w == "" {
+		return "", ""
+	}
+
+	if strings.Contains(w, "://") {
+		w = schemeFix.ReplaceAllString(w, "http$1://")
+		u, err := url.Parse(w)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp") {
+			return "", ""
+		}
+		u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+		host := u.Hostname()
+		if addr, err := netip.ParseAddr(host); err == nil {
+			if reportable(addr) {
+				return TypeURL, u.String()
+			}
+			return "", ""
+		}
+		if defanged && domainPattern.MatchString(host) {
+			return TypeURL, u.String()
+		}
+		return "", ""
+	}
+
+	if addr, ok := parseAddr(w); ok {
+		if !reportable(addr) || (addr.Is4() && !defanged && isVersionContext(prev, w)) {
+			return "", ""
+		}
+		if addr.Is4() {
+			return TypeIPv4, addr.String()
+		}
+		return TypeIPv6, addr.String()
+	}
+
+	if defanged {
+		host, _, _ := strings.Cut(w, "/")
+		if domainPattern.MatchString(host) && !strings.Contains(host, "@") {
+			return TypeDomain, strings.ToLower(host)
+		}
+		return "", ""
+	}
+
+	if hexPattern.MatchString(w) {
+		if t := hashType(w); t != "" {
+			return t, strings.ToLower(w)
+		}
+		return "", ""
+	}
+
+	if unixPath.MatchString(w) {
+		return TypePath, w
+	}
+	return "", ""
+}
+
+// parseAddr accepts an address with an optional port.
+func parseAddr(s string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// reportable excludes addresses that cannot be attacker infrastructure.
+func reportable(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVersionContext(prev, word string) bool {
+	return slices.Contains(versionWords, prev) || strings.HasPrefix(strings.ToLower(word), "v")
+}
+
+func hashType(hex string) string {
+	if strings.Trim(hex, "0123456789") == "" || strings.Count(hex, hex[:1]) == len(hex) {
+		return "" // a long number or filler, not a digest
+	}
+	switch len(hex) {
+	case 32:
+		return TypeMD5
+	case 40:
+		return TypeSHA1
+	case 64:
+		return TypeSHA256
+	case 128:
+		return TypeSHA512
+	}
+	return ""
+}
+
+// hostIndicator is the domain or address of a URL indicator.
+func hostIndicator(raw string) (Indicator, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Indicator{}, false
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		if addr.Is4() {
+			return Indicator{Type: TypeIPv4, Value: addr.String()}, true
+		}
+		return Indicator{Type: TypeIPv6, Value: addr.String()}, true
+	}
+	return Indicator{Type: TypeDomain, Value: host}, true
+}
+
+// Values returns the values of inds, for filter expressions.
+func Values(inds []Indicator) []string {
+	out := make([]string, len(inds))
+	for i, ind := range inds {
+		out[i] = ind.Value
+	}
+	return out
+}
+
+// TypesOf returns the distinct types among inds.
+func TypesOf(inds []Indicator) []string {
+	var out []string
+	for _, ind := range inds {
+		if !slices.Contains(out, ind.Type) {
+			out = append(out, ind.Type)
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package ioc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	content := `<p>The actors staged payloads on <code>hxxps://update-check[.]example-cdn[.]net/a.sh</code>
+and connected to 45.77.12.9:443 and <b>2a05:d014::1f</b>.</p>
+<table><tr><td>SHA256</td><td>E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855</td></tr>
+<tr><td>MD5</td><td>d41d8cd98f00b204e9800998ecf8427e</td></tr></table>
+<p>Dropped to C:\Program Files\Pulse Secure\lastauthserverused.js and /tmp/.kworker; C2 at c2[.]badhost[.]io.</p>
+<p>See https://www.cisa.gov/guidance and the vendor KB at support.ivanti.com.</p>`
+
+	got := Extract("Ivanti Connect Secure exploitation", content)
+	assert.Equal(t, []Indicator{
+		{TypePath, `C:\Program Files\Pulse Secure\lastauthserverused.js`},
+		{TypeURL, "https://update-check.example-cdn.net/a.sh"},
+		{TypeDomain, "update-check.example-cdn.net"},
+		{TypeIPv4, "45.77.12.9"},
+		{TypeIPv6, "2a05:d014::1f"},
+		{TypeSHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{TypeMD5, "d41d8cd98f00b204e9800998ecf8427e"},
+		{TypePath, "/tmp/.kworker"},
+		{TypeDomain, "c2.badhost.io"},
+	}, got)
+}
+
+func TestExtract_Ignores(t *testing.T) {
+	for name, text := range map[string]string{
+		"version numbers":     "Affects versions 9.1.1.1 through 9.1.1.5 and v22.7.1.2",
+		"private addresses":   "beacons to 10.0.0.5, 192.168.1.1, 127.0.0.1 and fe80::1",
+		"documentation":       "example 192.0.2.10 or 2001:db8::10",
+		"plain links":         "https://github.com/acme/app/commit/2f5a1c7d9e0b4a6f8c3d2e1f0a9b8c7d6e5f4a3b",
+		"plain domains":       "Download from support.ivanti.com or email psirt@cisco.com",
+		"defanged email":      "psirt[@]cisco[.]com",
+		"numbers and filler":  "12345678901234567890123456789012 00000000000000000000000000000000",
+		"times and odd hexes": "at 12:30:45 build deadbeef",
+		"url paths":           "/api/v1/totp/user-backup-code",
+	} {
+		assert.Empty(t, Extract(text), name)
+	}
+}
+
+func TestExtract_Defanged(t *testing.T) {
+	assert.Equal(t, []Indicator{
+		{TypeURL, "http://198.51.99.7/x"},
+		{TypeIPv4, "198.51.99.7"},
+		{TypeDomain, "evil.com"},
+		{TypeIPv4, "103.4.5.6"},
+		{TypeURL, "http://bad.example.org/p"},
+		{TypeDomain, "bad.example.org"},
+	}, Extract("hxxp://198.51.99[.]7/x, (EVIL[.]COM) 103[.]4[.]5[.]6 hxxp[://]bad(.)example{.}org/p"))
+}
+
+func TestPattern(t *testing.T) {
+	assert.Equal(t, "[ipv4-addr:value = '45.77.12.9']", Pattern(Indicator{TypeIPv4, "45.77.12.9"}))
+	assert.Equal(t, "[file:hashes.'SHA-256' = 'ab']", Pattern(Indicator{TypeSHA256, "ab"}))
+	assert.Equal(t, `[file:name = 'evil.dll' AND file:parent_directory_ref.path = 'C:\\Users\\it\'s']`,
+		Pattern(Indicator{TypePath, `C:\Users\it's\evil.dll`}))
+	assert.Equal(t, "[file:name = '.kworker' AND file:parent_directory_ref.path = '/tmp']",
+		Pattern(Indicator{TypePath, "/tmp/.kworker"}))
+}
+
+func TestWriteSTIX(t *testing.T) {
+	day := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	ip := Indicator{TypeIPv4, "45.77.12.9"}
+	mentions := []Mention{
+		{Indicator: ip, Source: "CISA", Title: "AA24-060B", Link: "https://cisa.example/aa24-060b", Date: day.AddDate(0, 0, 2)},
+		{Indicator: Indicator{TypeDomain, "c2.badhost.io"}, Source: "CISA", Title: "AA24-060B", Link: "https://cisa.example/aa24-060b", Date: day.AddDate(0, 0, 2)},
+		{Indicator: ip, Source: "Volexity", Title: "Ivanti exploitation", Link: "https://volexity.example/ivanti", Date: day},
+	}
+
+	var a, b bytes.Buffer
+	require.NoError(t, WriteSTIX(&a, mentions))
+	require.NoError(t, WriteSTIX(&b, mentions))
+	assert.Equal(t, a.String(), b.String(), "deterministic")
+
+	var bundle struct {
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Objects []struct {
+			Type        string `json:"type"`
+			SpecVersion string `json:"spec_version"`
+			ID          string `json:"id"`
+			Created     string `json:"created"`
+			Modified    string `json:"modified"`
+			Pattern     string `json:"pattern"`
+			PatternType string `json:"pattern_type"`
+			ValidFrom   string `json:"valid_from"`
+			ExtRefs     []struct {
+				SourceName string `json:"source_name"`
+				URL        string `json:"url"`
+			} `json:"external_references"`
+		} `json:"objects"`
+	}
+	require.NoError(t, json.Unmarshal(a.Bytes(), &bundle))
+	assert.Equal(t, "bundle", bundle.Type)
+	assert.Regexp(t, `^bundle--[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bundle.ID)
+	require.Len(t, bundle.Objects, 2)
+
+	o := bundle.Objects[0]
+	assert.Equal(t, "indicator", o.Type)
+	assert.Equal(t, "2.1", o.SpecVersion)
+	assert.Equal(t, "[ipv4-addr:value = '45.77.12.9']", o.Pattern)
+	assert.Equal(t, "stix", o.PatternType)
+	assert.Equal(t, "2024-01-10T08:00:00.000Z", o.Created)
+	assert.Equal(t, "2024-01-12T08:00:00.000Z", o.Modified)
+	assert.Equal(t, o.Created, o.ValidFrom)
+	assert.Len(t, o.ExtRefs, 2)
+	assert.Equal(t, "indicator--"+uuid5("ipv4:45.77.12.9"), o.ID)
+}
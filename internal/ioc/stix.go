@@ -0,0 +1,160 @@
+package ioc
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Mention is an indicator found in one advisory.
+type Mention struct {
+	Indicator
+	Source string    `json:"source"`
+	Title  string    `json:"title"`
+	Link   string    `json:"link"`
+	Date   time.Time `json:"date"` // published, else first seen
+}
+
+// stixNamespace seeds the UUIDv5 of every exported object, so the same
+// indicator keeps its STIX id across exports and consumers can deduplicate.
+var stixNamespace = [16]byte{0x4f, 0x1d, 0x6c, 0x3a, 0x8e, 0x52, 0x4b, 0x0d, 0x9a, 0x27, 0x5e, 0x61, 0xc8, 0x03, 0xb4, 0x9f}
+
+type stixBundle struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Objects []stixIndicator `json:"objects"`
+}
+
+type stixIndicator struct {
+	Type        string    `json:"type"`
+	SpecVersion string    `json:"spec_version"`
+	ID          string    `json:"id"`
+	Created     string    `json:"created"`
+	Modified    string    `json:"modified"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Pattern     string    `json:"pattern"`
+	PatternType string    `json:"pattern_type"`
+	ValidFrom   string    `json:"valid_from"`
+	Labels      []string  `json:"labels"`
+	ExtRefs     []stixRef `json:"external_references,omitempty"`
+}
+
+type stixRef struct {
+	SourceName  string `json:"source_name"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// WriteSTIX writes mentions as a STIX 2.1 bundle with one indicator per
+// distinct IOC, referencing every advisory that mentions it. Ids and
+// timestamps derive from the indicators and their mention dates, so
+// exporting the same data twice gives the same bundle.
+func WriteSTIX(w io.Writer, mentions []Mention) error {
+	byInd := map[Indicator][]Mention{}
+	var order []Indicator
+	for _, m := range mentions {
+		if _, ok := byInd[m.Indicator]; !ok {
+			order = append(order, m.Indicator)
+		}
+		byInd[m.Indicator] = append(byInd[m.Indicator], m)
+	}
+
+	objects := make([]stixIndicator, 0, len(order))
+	ids := make([]string, 0, len(order))
+	for _, ind := range order {
+		ms := byInd[ind]
+		first, last := ms[0].Date, ms[0].Date
+		var refs []stixRef
+		for _, m := range ms {
+			if m.Date.Before(first) {
+				first = m.Date
+			}
+			if m.Date.After(last) {
+				last = m.Date
+			}
+			ref := stixRef{SourceName: cmp.Or(m.Source, "advisory"), URL: m.Link, Description: m.Title}
+			if !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		id := "indicator--" + uuid5(ind.Type+":"+ind.Value)
+		ids = append(ids, id)
+		objects = append(objects, stixIndicator{
+			Type:        "indicator",
+			SpecVersion: "2.1",
+			ID:          id,
+			Created:     stixTime(first),
+			Modified:    stixTime(last),
+			Name:        ind.Value,
+			Description: "Mentioned in security advisories collected by tigerfetch",
+			Pattern:     Pattern(ind),
+			PatternType: "stix",
+			ValidFrom:   stixTime(first),
+			Labels:      []string{ind.Type},
+			ExtRefs:     refs,
+		})
+	}
+	slices.Sort(ids)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stixBundle{
+		Type:    "bundle",
+		ID:      "bundle--" + uuid5(strings.Join(ids, ",")),
+		Objects: objects,
+	})
+}
+
+// Pattern is the STIX pattern matching ind.
+func Pattern(ind Indicator) string {
+	q := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	switch ind.Type {
+	case TypeIPv4:
+		return "[ipv4-addr:value = " + q(ind.Value) + "]"
+	case TypeIPv6:
+		return "[ipv6-addr:value = " + q(ind.Value) + "]"
+	case TypeDomain:
+		return "[domain-name:value = " + q(ind.Value) + "]"
+	case TypeURL:
+		return "[url:value = " + q(ind.Value) + "]"
+	case TypeMD5:
+		return "[file:hashes.MD5 = " + q(ind.Value) + "]"
+	case TypeSHA1:
+		return "[file:hashes.'SHA-1' = " + q(ind.Value) + "]"
+	case TypeSHA256:
+		return "[file:hashes.'SHA-256' = " + q(ind.Value) + "]"
+	case TypeSHA512:
+		return "[file:hashes.'SHA-512' = " + q(ind.Value) + "]"
+	case TypePath:
+		sep := strings.LastIndexAny(ind.Value, `\/`)
+		if sep <= 0 {
+			return "[file:name = " + q(ind.Value) + "]"
+		}
+		return "[file:name = " + q(ind.Value[sep+1:]) + " AND file:parent_directory_ref.path = " + q(ind.Value[:sep]) + "]"
+	}
+	return ""
+}
+
+func stixTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// uuid5 is an RFC 9562 name-based (SHA-1) UUID in stixNamespace.
+func uuid5(name string) string {
+	h := sha1.New()
+	h.Write(stixNamespace[:])
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/hibiken/asynq"
+)
+
+// asynqPrefix starts the Asynq task type of every tigerfetch job kind.
+const asynqPrefix = "tigerfetch:"
+
+// asynqShutdown is how long the Asynq server waits at shutdown before it
+// gives up on running tasks and puts them back in the queue.
+const asynqShutdown = 10 * time.Second
+
+// AsynqQueue runs jobs on Asynq (Redis), so ingestion workers scale out
+// with the rest of an Asynq deployment and asynqmon shows the queue. Dead
+// jobs are Asynq's archived tasks.
+type AsynqQueue struct {
+	redis  asynq.RedisConnOpt
+	client *asynq.Client
+	settings
+}
+
+// NewAsynqQueue connects to jobs.redis_url.
+func NewAsynqQueue(cfg config.JobsConfig) (*AsynqQueue, error) {
+	set, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RedisURL == "" {
+		return nil, errors.New("jobs.redis_url is required for the asynq backend")
+	}
+	redis, err := asynq.ParseRedisURI(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.redis_url: %w", err)
+	}
+	return &AsynqQueue{redis: redis, client: asynq.NewClient(redis), settings: set}, nil
+}
+
+// uniqueFor is how long Asynq holds a job's uniqueness lock: the longest
+// run plus every backoff, so a job that is still running or retrying
+// blocks duplicates. A successful run releases it early; a dead one holds
+// it until it expires.
+func (q *AsynqQueue) uniqueFor() time.Duration {
+	d := longestRun
+	for attempts := 1; attempts < q.policy.MaxAttempts; attempts++ {
+		d += q.policy.Delay(attempts)
+	}
+	return d
+}
+
+// Enqueue adds a task unless one of the same kind and key is active.
+func (q *AsynqQueue) Enqueue(ctx context.Context, kind, key string) error {
+	task := asynq.NewTask(asynqPrefix+kind, []byte(key),
+		asynq.Queue(q.queue),
+		asynq.MaxRetry(q.policy.MaxAttempts-1),
+		asynq.Timeout(longestRun),
+		asynq.Unique(q.uniqueFor()),
+	)
+	_, err := q.client.EnqueueContext(ctx, task)
+	if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+		return fmt.Errorf("enqueue %s job: %w", kind, err)
+	}
+	return nil
+}
+
+// Work runs an Asynq server on jobs.queue until ctx ends. Tasks running at
+// shutdown go back to the queue without using up a retry.
+func (q *AsynqQueue) Work(ctx context.Context, handlers Handlers) error {
+	defer q.client.Close()
+	mux := asynq.NewServeMux()
+	for kind, handler := range handlers {
+		mux.HandleFunc(asynqPrefix+kind, func(tctx context.Context, task *asynq.Task) error {
+			retried, _ := asynq.GetRetryCount(tctx)
+			maxRetry, _ := asynq.GetMaxRetry(tctx)
+			tctx, stop := context.WithCancel(tctx)
+			defer stop()
+			defer context.AfterFunc(ctx, stop)()
+
+			key := string(task.Payload())
+			err := handler(tctx, key)
+			if err != nil && ctx.Err() != nil {
+				// Returning would count as a failure; hold the task until
+				// the server gives up on it, which requeues it.
+				time.Sleep(asynqShutdown + time.Second)
+				return err
+			}
+			record(kind, key, retried+1, maxRetry+1, err)
+			if IsPermanent(err) {
+				return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+			}
+			return err
+		})
+	}
+
+	srv := asynq.NewServer(q.redis, asynq.Config{
+		Concurrency: q.concurrency,
+		Queues:      map[string]int{q.queue: 1},
+		RetryDelayFunc: func(retried int, _ error, _ *asynq.Task) time.Duration {
+			return q.policy.Delay(retried + 1)
+		},
+		ShutdownTimeout: asynqShutdown,
+	})
+	if err := srv.Start(mux); err != nil {
+		return fmt.Errorf("start asynq server: %w", err)
+	}
+	<-ctx.Done()
+	srv.Shutdown()
+	return nil
+}
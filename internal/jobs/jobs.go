@@ -0,0 +1,194 @@
+// Package jobs runs the daemon's ingestion as discrete, persisted jobs:
+// fetch feed X, poll NVD, load the EPSS scores of day Z. The daemon's poll
+// loops enqueue jobs and a worker runs them. A failed job is retried with
+// exponential backoff, so a transient upstream error heals itself; after
+// max_attempts failures the job is dead and stays visible, with its last
+// error, in `tigerfetch jobs --state dead` until retried.
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Job kinds. The key tells jobs of one kind apart.
+const (
+	KindFeed        = "feed"         // key: feed name
+	KindNVD         = "nvd"          // resumes from the NVD cursor, one 120-day window after another
+	KindNVDProducts = "nvd_products" // [[nvd.products]] searches
+	KindKEV         = "kev"
+	KindEPSS        = "epss" // key: UTC day the scores were scheduled for
+	KindRemote      = "remote"
+	KindMail        = "mail"   // key: [[mail.lists]] name
+	KindSocial      = "social" // key: [[social.sources]] name
+)
+
+// Job states. Queued, running and retrying jobs are active: at most one
+// job per kind and key is.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateRetrying  = "retrying" // failed, waiting for RunAt
+	StateSucceeded = "succeeded"
+	StateDead      = "dead" // failed max_attempts times
+)
+
+// States lists the job states in lifecycle order.
+func States() []string {
+	return []string{StateQueued, StateRunning, StateRetrying, StateSucceeded, StateDead}
+}
+
+// Job is one unit of ingestion work.
+type Job struct {
+	ID          int64      `json:"id"`
+	Kind        string     `json:"kind"`
+	Key         string     `json:"key,omitempty"`
+	State       string     `json:"state"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `json:"run_at"` // when a queued or retrying job is due
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"` // when it succeeded or went dead
+}
+
+// Handler runs a job of one kind, given its key.
+type Handler func(ctx context.Context, key string) error
+
+// Handlers maps job kinds to their handlers.
+type Handlers map[string]Handler
+
+// Queue is a job backend: the daemon enqueues work on it and runs a worker.
+type Queue interface {
+	// Enqueue adds a job unless one of the same kind and key is active.
+	Enqueue(ctx context.Context, kind, key string) error
+	// Work runs due jobs with handlers until ctx ends.
+	Work(ctx context.Context, handlers Handlers) error
+}
+
+// Backends selectable with [jobs] backend.
+const (
+	BackendPostgres = "postgres"
+	BackendRiver    = "river"
+	BackendAsynq    = "asynq"
+)
+
+// Open returns the Queue of the configured backend. River keeps its own
+// tables in pool's database and migrates them here; Asynq connects to
+// jobs.redis_url.
+func Open(ctx context.Context, pool *pgxpool.Pool, cfg config.JobsConfig) (Queue, error) {
+	switch cfg.Backend {
+	case "", BackendPostgres:
+		return NewStore(pool, cfg)
+	case BackendRiver:
+		return NewRiverQueue(ctx, pool, cfg)
+	case BackendAsynq:
+		return NewAsynqQueue(cfg)
+	default:
+		return nil, fmt.Errorf("unknown jobs.backend %q (have %s, %s, %s)", cfg.Backend, BackendPostgres, BackendRiver, BackendAsynq)
+	}
+}
+
+// settings are the [jobs] options every backend shares.
+type settings struct {
+	policy       Policy
+	pollInterval time.Duration
+	concurrency  int
+	retention    time.Duration
+	queue        string
+}
+
+func parseConfig(cfg config.JobsConfig) (settings, error) {
+	if cfg.MaxAttempts < 0 || cfg.Concurrency < 0 {
+		return settings{}, errors.New("jobs.max_attempts and jobs.concurrency must not be negative")
+	}
+	s := settings{
+		policy:      Policy{MaxAttempts: cmp.Or(cfg.MaxAttempts, 5)},
+		concurrency: cmp.Or(cfg.Concurrency, 8),
+		queue:       cmp.Or(cfg.Queue, "tigerfetch"),
+	}
+	var err error
+	if s.policy.Backoff, err = cfg.GetBackoff(); err != nil || s.policy.Backoff <= 0 {
+		return settings{}, fmt.Errorf("jobs.backoff %q must be a positive duration", cfg.Backoff)
+	}
+	if s.policy.MaxBackoff, err = cfg.GetMaxBackoff(); err != nil || s.policy.MaxBackoff < s.policy.Backoff {
+		return settings{}, fmt.Errorf("jobs.max_backoff %q must be a duration of at least jobs.backoff", cfg.MaxBackoff)
+	}
+	if s.pollInterval, err = cfg.GetPollDuration(); err != nil || s.pollInterval <= 0 {
+		return settings{}, fmt.Errorf("jobs.poll_interval %q must be a positive duration", cfg.PollInterval)
+	}
+	if s.retention, err = cfg.GetRetention(); err != nil || s.retention <= 0 {
+		return settings{}, fmt.Errorf("jobs.retention %q must be a positive duration", cfg.Retention)
+	}
+	return s, nil
+}
+
+// longestRun bounds a single job run on the external backends, which
+// otherwise time out (Asynq, 30 minutes) or rescue (River, an hour) jobs
+// that run long, and a first NVD backfill runs for hours.
+const longestRun = 24 * time.Hour
+
+// record counts a finished attempt and logs failures. It returns the state
+// the job moves to.
+func record(kind, key string, attempts, maxAttempts int, err error) string {
+	state := StateSucceeded
+	if err != nil {
+		state = Policy{MaxAttempts: maxAttempts}.next(attempts, err)
+		slog.Error("Job failed", "kind", kind, "key", key, "attempt", attempts,
+			"max_attempts", maxAttempts, "state", state, "error", err)
+	}
+	metrics.JobRuns.WithLabelValues(kind, state).Inc()
+	return state
+}
+
+// Policy decides when failed jobs are retried.
+type Policy struct {
+	MaxAttempts int
+	Backoff     time.Duration // wait after the first failure
+	MaxBackoff  time.Duration
+}
+
+// Delay is the wait before the next run of a job that has failed attempts
+// times: Backoff, doubling per failure, at most MaxBackoff.
+func (p Policy) Delay(attempts int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// next is the state of a job after its attempts-th run failed with err.
+func (p Policy) next(attempts int, err error) string {
+	if attempts >= p.MaxAttempts || IsPermanent(err) {
+		return StateDead
+	}
+	return StateRetrying
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a job for a feed that is
+// no longer configured: the job goes dead at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: 5 * time.Minute}
+	var got []time.Duration
+	for attempts := 1; attempts <= 5; attempts++ {
+		got = append(got, p.Delay(attempts))
+	}
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}, got)
+	assert.Equal(t, 5*time.Minute, p.Delay(1000), "no overflow")
+}
+
+func TestPolicyNext(t *testing.T) {
+	p := Policy{MaxAttempts: 3}
+	err := errors.New("503 Service Unavailable")
+	assert.Equal(t, StateRetrying, p.next(1, err))
+	assert.Equal(t, StateRetrying, p.next(2, err))
+	assert.Equal(t, StateDead, p.next(3, err))
+	assert.Equal(t, StateDead, p.next(1, fmt.Errorf("feed %q: %w", "gone", Permanent(errors.New("not configured")))))
+}
+
+func TestNewStoreValidates(t *testing.T) {
+	s, err := NewStore(nil, config.JobsConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, Policy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: time.Hour}, s.policy)
+	assert.Equal(t, 8, s.concurrency)
+
+	for _, cfg := range []config.JobsConfig{
+		{MaxAttempts: -1},
+		{Backoff: "soon"},
+		{Backoff: "2h", MaxBackoff: "1h"},
+		{PollInterval: "0s"},
+		{Retention: "-1h"},
+	} {
+		_, err := NewStore(nil, cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open(context.Background(), nil, config.JobsConfig{Backend: "kafka"})
+	assert.ErrorContains(t, err, `unknown jobs.backend "kafka"`)
+
+	_, err = Open(context.Background(), nil, config.JobsConfig{Backend: BackendAsynq})
+	assert.ErrorContains(t, err, "jobs.redis_url is required")
+
+	q, err := Open(context.Background(), nil, config.JobsConfig{Backend: BackendAsynq, RedisURL: "redis://localhost:6379/2", MaxAttempts: 3})
+	require.NoError(t, err)
+	aq := q.(*AsynqQueue)
+	assert.Equal(t, "tigerfetch", aq.queue)
+	assert.Equal(t, longestRun+time.Minute+2*time.Minute, aq.uniqueFor(), "covers the run and both retries")
+}
+
+func TestRiverQueue(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	q, err := NewRiverQueue(ctx, pool, config.JobsConfig{Queue: "tigerfetch_test", Backoff: "10ms", MaxBackoff: "10ms", PollInterval: "50ms"})
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `DELETE FROM river_job WHERE queue = 'tigerfetch_test'`)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Enqueue(ctx, KindKEV, ""))
+	require.NoError(t, q.Enqueue(ctx, KindKEV, ""), "duplicate is skipped")
+	var n int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM river_job WHERE queue = 'tigerfetch_test'`).Scan(&n))
+	assert.Equal(t, 1, n)
+
+	var runs atomic.Int32
+	wctx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- q.Work(wctx, Handlers{KindKEV: func(context.Context, string) error {
+			if runs.Add(1) == 1 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}})
+	}()
+	require.Eventually(t, func() bool {
+		var state string
+		err := pool.QueryRow(ctx, `SELECT state FROM river_job WHERE queue = 'tigerfetch_test'`).Scan(&state)
+		return err == nil && state == "completed"
+	}, 10*time.Second, 50*time.Millisecond)
+	stop()
+	require.NoError(t, <-done)
+	assert.EqualValues(t, 2, runs.Load(), "the failure was retried")
+}
+
+func TestStore(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() {
+		_, err := pool.Exec(ctx, `DELETE FROM jobs WHERE kind LIKE 'test_%'`)
+		require.NoError(t, err)
+	}
+	cleanup()
+	defer cleanup()
+
+	s, err := NewStore(pool, config.JobsConfig{MaxAttempts: 2, Backoff: "10ms", MaxBackoff: "10ms", PollInterval: "10ms"})
+	require.NoError(t, err)
+
+	// A second enqueue while the first is pending is a no-op.
+	require.NoError(t, s.Enqueue(ctx, "test_flaky", "a"))
+	require.NoError(t, s.Enqueue(ctx, "test_flaky", "a"))
+	require.NoError(t, s.Enqueue(ctx, "test_broken", ""))
+	require.NoError(t, s.Enqueue(ctx, "test_orphan", ""))
+	queued, err := List(ctx, pool, ListOptions{State: StateQueued, Kind: "test_flaky"})
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+
+	var flaky atomic.Int32
+	handlers := Handlers{
+		"test_flaky": func(_ context.Context, key string) error {
+			assert.Equal(t, "a", key)
+			if flaky.Add(1) == 1 {
+				return errors.New("connection reset")
+			}
+			return nil
+		},
+		"test_broken": func(context.Context, string) error { return errors.New("404 Not Found") },
+	}
+	wctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, s.Work(wctx, handlers))
+	}()
+
+	state := func(kind string) Job {
+		jobs, err := List(ctx, pool, ListOptions{Kind: kind, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		return jobs[0]
+	}
+	require.Eventually(t, func() bool {
+		return state("test_flaky").State == StateSucceeded &&
+			state("test_broken").State == StateDead &&
+			state("test_orphan").State == StateDead
+	}, 5*time.Second, 10*time.Millisecond)
+	stop()
+	<-done
+
+	ok1 := state("test_flaky")
+	assert.Equal(t, 2, ok1.Attempts, "the transient failure was retried")
+	assert.Empty(t, ok1.LastError)
+	assert.NotNil(t, ok1.FinishedAt)
+
+	dead := state("test_broken")
+	assert.Equal(t, 2, dead.Attempts)
+	assert.Equal(t, "404 Not Found", dead.LastError)
+	assert.Equal(t, 1, state("test_orphan").Attempts, "a job without handler is not retried")
+	assert.Contains(t, state("test_orphan").LastError, `no handler for job kind "test_orphan"`)
+
+	// A dead job can be retried once, and not while another one is pending.
+	require.NoError(t, Retry(ctx, pool, dead.ID))
+	assert.Equal(t, StateQueued, state("test_broken").State)
+	assert.Error(t, Retry(ctx, pool, dead.ID), "no longer dead")
+	assert.Error(t, Retry(ctx, pool, ok1.ID), "never dead")
+}
@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivermigrate"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// riverKind is the River kind of every tigerfetch job. River kinds are
+// fixed per args type, so the tigerfetch kind travels in the args.
+const riverKind = "tigerfetch"
+
+// riverArgs is a job as River stores it in river_job.args.
+type riverArgs struct {
+	Job string `json:"job"`
+	Key string `json:"key"`
+}
+
+func (riverArgs) Kind() string { return riverKind }
+
+// riverActive are the River states in which a job blocks another of the
+// same kind and key, like idx_jobs_active: everything but finished jobs.
+var riverActive = []rivertype.JobState{
+	rivertype.JobStateAvailable,
+	rivertype.JobStatePending,
+	rivertype.JobStateRunning,
+	rivertype.JobStateRetryable,
+	rivertype.JobStateScheduled,
+}
+
+// RiverQueue runs jobs on River (riverqueue.com) in the daemon's database,
+// so any number of daemons can work one queue and River UI or the river
+// CLI can watch it. Dead jobs are River's discarded (out of attempts) and
+// cancelled (Permanent errors) jobs.
+type RiverQueue struct {
+	client *river.Client[pgx.Tx]
+	worker *riverWorker
+	settings
+}
+
+// NewRiverQueue migrates River's tables and builds a client working the
+// jobs.queue queue.
+func NewRiverQueue(ctx context.Context, pool *pgxpool.Pool, cfg config.JobsConfig) (*RiverQueue, error) {
+	set, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	driver := riverpgxv5.New(pool)
+	migrator, err := rivermigrate.New(driver, nil)
+	if err != nil {
+		return nil, fmt.Errorf("river migrations: %w", err)
+	}
+	if _, err := migrator.Migrate(ctx, rivermigrate.DirectionUp, nil); err != nil {
+		return nil, fmt.Errorf("river migrations: %w", err)
+	}
+
+	worker := &riverWorker{}
+	workers := river.NewWorkers()
+	river.AddWorker(workers, worker)
+	client, err := river.NewClient(driver, &river.Config{
+		Queues:                      map[string]river.QueueConfig{set.queue: {MaxWorkers: set.concurrency}},
+		Workers:                     workers,
+		MaxAttempts:                 set.policy.MaxAttempts,
+		RetryPolicy:                 riverRetry{set.policy},
+		JobTimeout:                  -1, // runs end with their own HTTP timeouts, or at shutdown
+		RescueStuckJobsAfter:        longestRun,
+		CompletedJobRetentionPeriod: set.retention,
+		FetchPollInterval:           set.pollInterval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("river client: %w", err)
+	}
+	return &RiverQueue{client: client, worker: worker, settings: set}, nil
+}
+
+// Enqueue inserts a River job unless one of the same kind and key is active.
+func (q *RiverQueue) Enqueue(ctx context.Context, kind, key string) error {
+	_, err := q.client.Insert(ctx, riverArgs{Job: kind, Key: key}, &river.InsertOpts{
+		Queue:      q.queue,
+		UniqueOpts: river.UniqueOpts{ByArgs: true, ByState: riverActive},
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue %s job: %w", kind, err)
+	}
+	return nil
+}
+
+// Work starts the River client and stops it when ctx ends, cancelling
+// running jobs; they go back to the queue without using up an attempt.
+func (q *RiverQueue) Work(ctx context.Context, handlers Handlers) error {
+	q.worker.handlers = handlers
+	if err := q.client.Start(context.WithoutCancel(ctx)); err != nil {
+		return fmt.Errorf("start river client: %w", err)
+	}
+	<-ctx.Done()
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	return q.client.StopAndCancel(stopCtx)
+}
+
+type riverWorker struct {
+	river.WorkerDefaults[riverArgs]
+	handlers Handlers // set by Work before the client starts
+}
+
+func (w *riverWorker) Work(ctx context.Context, job *river.Job[riverArgs]) error {
+	handler := w.handlers[job.Args.Job]
+	if handler == nil {
+		err := fmt.Errorf("no handler for job kind %q", job.Args.Job)
+		record(job.Args.Job, job.Args.Key, job.Attempt, job.MaxAttempts, Permanent(err))
+		return river.JobCancel(err)
+	}
+	err := handler(ctx, job.Args.Key)
+	if err != nil && ctx.Err() != nil {
+		return river.JobSnooze(0) // shutdown: run again when a worker is back
+	}
+	record(job.Args.Job, job.Args.Key, job.Attempt, job.MaxAttempts, err)
+	if IsPermanent(err) {
+		return river.JobCancel(err)
+	}
+	return err
+}
+
+// riverRetry applies Policy to River's retries.
+type riverRetry struct{ policy Policy }
+
+func (r riverRetry) NextRetry(job *rivertype.JobRow) time.Time {
+	return time.Now().Add(r.policy.Delay(job.Attempt))
+}
@@ -0,0 +1,305 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lease is how long a claimed job stays running without a heartbeat. A
+// worker that dies mid-job leaves it to be retried once the lease expires.
+const lease = 5 * time.Minute
+
+// Store is the built-in Queue, backed by the jobs table. Workers claim jobs
+// with FOR UPDATE SKIP LOCKED, so several daemons may share one database.
+type Store struct {
+	db *pgxpool.Pool
+	settings
+}
+
+// NewStore builds a Store from cfg, filling in the defaults.
+func NewStore(db *pgxpool.Pool, cfg config.JobsConfig) (*Store, error) {
+	set, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, settings: set}, nil
+}
+
+// activeStates matches the partial unique index idx_jobs_active.
+const activeStates = `('queued', 'running', 'retrying')`
+
+const jobColumns = `id, kind, key, state, attempts, max_attempts, run_at, COALESCE(last_error, ''), created_at, updated_at, finished_at`
+
+func scanJob(row pgx.Row) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.Key, &j.State, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt)
+	return j, err
+}
+
+// Enqueue adds a job due now unless one of the same kind and key is active.
+func (s *Store) Enqueue(ctx context.Context, kind, key string) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO jobs (kind, key, max_attempts) VALUES ($1, $2, $3)
+		ON CONFLICT (kind, key) WHERE state IN `+activeStates+` DO NOTHING
+	`, kind, key, s.policy.MaxAttempts)
+	if err != nil {
+		return fmt.Errorf("enqueue %s job: %w", kind, err)
+	}
+	return nil
+}
+
+// Work claims due jobs every poll interval and runs up to concurrency of
+// them at once. Jobs interrupted by shutdown go back to the queue without
+// using up an attempt.
+func (s *Store) Work(ctx context.Context, handlers Handlers) error {
+	sem := make(chan struct{}, s.concurrency)
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	ticker := time.NewTimer(0)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.maintain(ctx); err != nil {
+				slog.Error("Job queue maintenance failed", "error", err)
+			}
+			s.claimDue(ctx, sem, &running, handlers)
+			ticker.Reset(s.pollInterval)
+		}
+	}
+}
+
+// claimDue starts due jobs while slots are free.
+func (s *Store) claimDue(ctx context.Context, sem chan struct{}, running *sync.WaitGroup, handlers Handlers) {
+	for {
+		select {
+		case sem <- struct{}{}:
+		default:
+			return // all slots busy; try again next poll
+		}
+		job, err := s.claim(ctx)
+		if err != nil || job == nil {
+			<-sem
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Claiming job failed", "error", err)
+			}
+			return
+		}
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer func() { <-sem }()
+			s.run(ctx, *job, handlers[job.Kind])
+		}()
+	}
+}
+
+// claim marks the next due job running, or returns nil if none is due.
+func (s *Store) claim(ctx context.Context) (*Job, error) {
+	job, err := scanJob(s.db.QueryRow(ctx, `
+		UPDATE jobs SET state = 'running', attempts = attempts + 1,
+			locked_until = now() + make_interval(secs => $1), updated_at = now()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE state IN ('queued', 'retrying') AND run_at <= now()
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, lease.Seconds()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *Store) run(ctx context.Context, job Job, handler Handler) {
+	var err error
+	if handler == nil {
+		err = Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	} else {
+		hctx, cancel := context.WithCancel(ctx)
+		go s.heartbeat(hctx, job.ID)
+		err = handler(hctx, job.Key)
+		cancel()
+	}
+
+	// Finish the bookkeeping even when shutdown cancelled ctx.
+	dbctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	switch {
+	case err == nil:
+		record(job.Kind, job.Key, job.Attempts, job.MaxAttempts, nil)
+		err = s.complete(dbctx, job.ID)
+	case ctx.Err() != nil:
+		slog.Info("Job interrupted by shutdown, requeueing", "job", job.ID, "kind", job.Kind, "key", job.Key)
+		err = s.requeue(dbctx, job.ID)
+	default:
+		state := record(job.Kind, job.Key, job.Attempts, job.MaxAttempts, err)
+		delay := s.policy.Delay(job.Attempts)
+		if state == StateRetrying {
+			slog.Info("Job will be retried", "job", job.ID, "in", delay)
+		}
+		err = s.fail(dbctx, job.ID, state, err.Error(), delay)
+	}
+	if err != nil {
+		slog.Error("Recording job outcome failed", "job", job.ID, "error", err)
+	}
+}
+
+// heartbeat extends the lease of a running job until ctx ends.
+func (s *Store) heartbeat(ctx context.Context, id int64) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := s.db.Exec(ctx, `
+				UPDATE jobs SET locked_until = now() + make_interval(secs => $2)
+				WHERE id = $1 AND state = 'running'
+			`, id, lease.Seconds())
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("Extending job lease failed", "job", id, "error", err)
+			}
+		}
+	}
+}
+
+func (s *Store) complete(ctx context.Context, id int64) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs SET state = 'succeeded', last_error = NULL, locked_until = NULL,
+			updated_at = now(), finished_at = now()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (s *Store) fail(ctx context.Context, id int64, state, msg string, delay time.Duration) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs SET state = $2, last_error = $3, locked_until = NULL, updated_at = now(),
+			run_at = now() + make_interval(secs => $4),
+			finished_at = CASE WHEN $2 = 'dead' THEN now() END
+		WHERE id = $1
+	`, id, state, msg, delay.Seconds())
+	return err
+}
+
+// requeue returns an interrupted job to the queue and gives back its attempt.
+func (s *Store) requeue(ctx context.Context, id int64) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs SET state = CASE WHEN attempts > 1 THEN 'retrying' ELSE 'queued' END,
+			attempts = attempts - 1, locked_until = NULL, run_at = now(), updated_at = now()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// maintain retries jobs whose worker died, deletes succeeded jobs past
+// retention and refreshes the per-state gauge.
+func (s *Store) maintain(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE jobs SET
+			state = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'retrying' END,
+			finished_at = CASE WHEN attempts >= max_attempts THEN now() END,
+			last_error = 'lease expired: worker stopped while running the job',
+			locked_until = NULL, run_at = now(), updated_at = now()
+		WHERE state = 'running' AND locked_until < now()
+	`)
+	if err != nil {
+		return fmt.Errorf("recover expired leases: %w", err)
+	}
+	_, err = s.db.Exec(ctx, `
+		DELETE FROM jobs WHERE state = 'succeeded' AND finished_at < now() - make_interval(secs => $1)
+	`, s.retention.Seconds())
+	if err != nil {
+		return fmt.Errorf("delete old jobs: %w", err)
+	}
+
+	counts, err := CountByState(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	for _, state := range States() {
+		metrics.Jobs.WithLabelValues(state).Set(float64(counts[state]))
+	}
+	return nil
+}
+
+// CountByState counts the jobs in each state.
+func CountByState(ctx context.Context, db *pgxpool.Pool) (map[string]int, error) {
+	rows, err := db.Query(ctx, `SELECT state, count(*) FROM jobs GROUP BY state`)
+	if err != nil {
+		return nil, fmt.Errorf("count jobs: %w", err)
+	}
+	counts := map[string]int{}
+	var state string
+	var n int
+	_, err = pgx.ForEachRow(rows, []any{&state, &n}, func() error {
+		counts[state] = n
+		return nil
+	})
+	return counts, err
+}
+
+// ListOptions filter List.
+type ListOptions struct {
+	State string // one of the State constants; empty for all
+	Kind  string // empty for all
+	Limit int    // 0 for no limit
+}
+
+// List returns jobs, most recently updated first.
+func List(ctx context.Context, db *pgxpool.Pool, opts ListOptions) ([]Job, error) {
+	q := `SELECT ` + jobColumns + ` FROM jobs
+		WHERE ($1 = '' OR state = $1) AND ($2 = '' OR kind = $2)
+		ORDER BY updated_at DESC, id DESC`
+	args := []any{opts.State, opts.Kind}
+	if opts.Limit > 0 {
+		q += ` LIMIT $3`
+		args = append(args, opts.Limit)
+	}
+	rows, err := db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Job, error) {
+		return scanJob(row)
+	})
+}
+
+// Retry moves a dead job back to the queue with its attempts reset. It
+// fails if the job is not dead or another job of its kind and key is
+// already active.
+func Retry(ctx context.Context, db *pgxpool.Pool, id int64) error {
+	tag, err := db.Exec(ctx, `
+		UPDATE jobs j SET state = 'queued', attempts = 0, run_at = now(), updated_at = now(), finished_at = NULL
+		WHERE id = $1 AND state = 'dead' AND NOT EXISTS (
+			SELECT 1 FROM jobs a WHERE a.kind = j.kind AND a.key = j.key AND a.state IN `+activeStates+`
+		)
+	`, id)
+	if err != nil {
+		return fmt.Errorf("retry job %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("job %d is not dead, or a job of its kind and key is already pending", id)
+	}
+	return nil
+}
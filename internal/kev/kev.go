@@ -0,0 +1,193 @@
+// Package kev keeps the CISA KEV catalog and the set of CVEs tigerfetch has
+// stored in memory, so "is this CVE in KEV?" and "do we know this CVE?" are
+// answered without a catalog download or a database round trip.
+//
+// The KEV runner remains the only writer; Cache reloads from cve_enriched on
+// an interval, so a new catalog is visible here within one refresh.
+package kev
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultRefreshInterval is used when NewCache is given a zero interval.
+const DefaultRefreshInterval = 10 * time.Minute
+
+// Entry is the KEV record for one CVE.
+type Entry struct {
+	CveID             string `json:"cve_id"`
+	VendorProject     string `json:"vendor_project"`
+	Product           string `json:"product"`
+	VulnerabilityName string `json:"vulnerability_name"`
+	DateAdded         string `json:"date_added"`
+	DueDate           string `json:"due_date"`
+	Ransomware        bool   `json:"known_ransomware_use"` // CISA knows of ransomware campaigns using it
+}
+
+// Catalog is an immutable snapshot of the KEV set and the known-CVE index.
+// The zero value is an empty catalog.
+type Catalog struct {
+	entries  map[string]Entry
+	known    map[string]struct{}
+	loadedAt time.Time
+}
+
+// NewCatalog builds a snapshot from KEV entries and the IDs of every stored
+// CVE. KEV entries are always counted as known.
+func NewCatalog(entries []Entry, known []string) *Catalog {
+	c := &Catalog{
+		entries:  make(map[string]Entry, len(entries)),
+		known:    make(map[string]struct{}, len(known)+len(entries)),
+		loadedAt: time.Now(),
+	}
+	for _, id := range known {
+		c.known[normalize(id)] = struct{}{}
+	}
+	for _, e := range entries {
+		id := normalize(e.CveID)
+		e.CveID = id
+		c.entries[id] = e
+		c.known[id] = struct{}{}
+	}
+	return c
+}
+
+// InKEV reports whether the CVE is in the KEV catalog. IDs are matched
+// case-insensitively.
+func (c *Catalog) InKEV(cveID string) bool {
+	_, ok := c.entries[normalize(cveID)]
+	return ok
+}
+
+// Get returns the KEV entry for a CVE.
+func (c *Catalog) Get(cveID string) (Entry, bool) {
+	e, ok := c.entries[normalize(cveID)]
+	return e, ok
+}
+
+// Known reports whether any source (NVD, KEV) has stored the CVE.
+func (c *Catalog) Known(cveID string) bool {
+	_, ok := c.known[normalize(cveID)]
+	return ok
+}
+
+// Len is the number of KEV entries.
+func (c *Catalog) Len() int { return len(c.entries) }
+
+// KnownLen is the number of distinct stored CVEs.
+func (c *Catalog) KnownLen() int { return len(c.known) }
+
+// LoadedAt is when the snapshot was built; zero for the empty catalog.
+func (c *Catalog) LoadedAt() time.Time { return c.loadedAt }
+
+func normalize(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
+
+// Load reads a snapshot from cve_enriched.
+func Load(ctx context.Context, pool *pgxpool.Pool) (*Catalog, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT cve_id,
+		       COALESCE(json->>'vendorProject', ''), COALESCE(json->>'product', ''),
+		       COALESCE(json->>'vulnerabilityName', ''),
+		       COALESCE(json->>'dateAdded', ''), COALESCE(json->>'dueDate', ''),
+		       COALESCE(json->>'knownRansomwareCampaignUse', '') = 'Known'
+		FROM cve_enriched
+		WHERE source = 'CISA-KEV'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("load KEV entries: %w", err)
+	}
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.CveID, &e.VendorProject, &e.Product, &e.VulnerabilityName, &e.DateAdded, &e.DueDate, &e.Ransomware); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan KEV entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load KEV entries: %w", err)
+	}
+
+	rows, err = pool.Query(ctx, "SELECT DISTINCT cve_id FROM cve_enriched")
+	if err != nil {
+		return nil, fmt.Errorf("load known CVEs: %w", err)
+	}
+	defer rows.Close()
+	var known []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan known CVE: %w", err)
+		}
+		known = append(known, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load known CVEs: %w", err)
+	}
+
+	return NewCatalog(entries, known), nil
+}
+
+// Cache holds the current Catalog and reloads it periodically. Readers call
+// Catalog and never block on a refresh.
+type Cache struct {
+	pool     *pgxpool.Pool
+	interval time.Duration
+	current  atomic.Pointer[Catalog]
+}
+
+// NewCache creates a cache that starts empty; call Refresh or Run to load it.
+func NewCache(pool *pgxpool.Pool, interval time.Duration) *Cache {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	c := &Cache{pool: pool, interval: interval}
+	c.current.Store(&Catalog{})
+	return c
+}
+
+// Catalog returns the latest snapshot. It is never nil.
+func (c *Cache) Catalog() *Catalog { return c.current.Load() }
+
+// Refresh reloads the snapshot. On error the previous snapshot is kept.
+func (c *Cache) Refresh(ctx context.Context) error {
+	cat, err := Load(ctx, c.pool)
+	if err != nil {
+		return err
+	}
+	c.current.Store(cat)
+	slog.Info("KEV cache refreshed", "kev", cat.Len(), "known_cves", cat.KnownLen())
+	return nil
+}
+
+// Run refreshes immediately and then on every interval until ctx is done.
+func (c *Cache) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if err := c.Refresh(ctx); err != nil {
+				slog.Error("KEV cache refresh failed", "error", err)
+			}
+			timer.Reset(c.interval)
+		}
+	}
+}
+
+// Store replaces the snapshot, for callers that build one themselves
+// (tests, or a runner that has just ingested a new catalog).
+func (c *Cache) Store(cat *Catalog) { c.current.Store(cat) }
@@ -0,0 +1,79 @@
+package kev
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_Lookups(t *testing.T) {
+	cat := NewCatalog(
+		[]Entry{{CveID: "CVE-2024-21887", VendorProject: "Ivanti", Product: "Connect Secure"}},
+		[]string{"CVE-2023-46805", "cve-2024-0001"},
+	)
+
+	assert.True(t, cat.InKEV("CVE-2024-21887"))
+	assert.True(t, cat.InKEV(" cve-2024-21887 "), "lookups are case- and space-insensitive")
+	assert.False(t, cat.InKEV("CVE-2023-46805"))
+
+	e, ok := cat.Get("cve-2024-21887")
+	require.True(t, ok)
+	assert.Equal(t, "Ivanti", e.VendorProject)
+	assert.Equal(t, "CVE-2024-21887", e.CveID)
+
+	assert.True(t, cat.Known("CVE-2024-0001"))
+	assert.True(t, cat.Known("CVE-2024-21887"), "KEV entries are known")
+	assert.False(t, cat.Known("CVE-1999-0001"))
+	assert.Equal(t, 1, cat.Len())
+	assert.Equal(t, 3, cat.KnownLen())
+}
+
+func TestCache_EmptyUntilLoaded(t *testing.T) {
+	c := NewCache(nil, 0)
+	require.NotNil(t, c.Catalog())
+	assert.False(t, c.Catalog().InKEV("CVE-2024-21887"))
+	assert.True(t, c.Catalog().LoadedAt().IsZero())
+
+	c.Store(NewCatalog([]Entry{{CveID: "CVE-2024-21887"}}, nil))
+	assert.True(t, c.Catalog().InKEV("CVE-2024-21887"))
+}
+
+func TestCache_RefreshFromDatabase(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id LIKE 'CVE-TEST-KEVSET-%'")
+	}
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, modified) VALUES
+			('CVE-TEST-KEVSET-001', 'CISA-KEV', '{"vendorProject":"Acme","dueDate":"2099-01-22"}', now()),
+			('CVE-TEST-KEVSET-002', 'NVD', '{}', now())
+	`)
+	require.NoError(t, err)
+
+	c := NewCache(pool, 0)
+	require.NoError(t, c.Refresh(ctx))
+
+	e, ok := c.Catalog().Get("CVE-TEST-KEVSET-001")
+	require.True(t, ok)
+	assert.Equal(t, "Acme", e.VendorProject)
+	assert.Equal(t, "2099-01-22", e.DueDate)
+	assert.False(t, c.Catalog().InKEV("CVE-TEST-KEVSET-002"))
+	assert.True(t, c.Catalog().Known("CVE-TEST-KEVSET-002"))
+}
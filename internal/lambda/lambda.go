@@ -0,0 +1,122 @@
+// Package lambda implements the AWS Lambda Runtime API loop for a custom
+// runtime (provided.al2023), so the tigerfetch binary is its own bootstrap
+// without an SDK: fetch the next invocation, run the handler within the
+// invocation's deadline, post the result or the error.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler runs one invocation. The context ends at the invocation's
+// deadline.
+type Handler func(ctx context.Context, event []byte) ([]byte, error)
+
+const apiVersion = "2018-06-01"
+
+// maxEventBytes bounds an invocation event; Lambda caps payloads at 6 MB.
+const maxEventBytes = 6 << 20
+
+// Serve runs invocations from the Runtime API at api ($AWS_LAMBDA_RUNTIME_API)
+// one at a time until ctx ends or the API fails.
+func Serve(ctx context.Context, api string, handler Handler) error {
+	c := &client{base: "http://" + api + "/" + apiVersion, http: &http.Client{}}
+	for {
+		inv, err := c.next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := c.invoke(ctx, inv, handler); err != nil {
+			return err
+		}
+	}
+}
+
+type client struct {
+	base string
+	http *http.Client
+}
+
+type invocation struct {
+	id       string
+	deadline time.Time
+	event    []byte
+}
+
+// next blocks until Lambda hands over an invocation.
+func (c *client) next(ctx context.Context) (invocation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/runtime/invocation/next", nil)
+	if err != nil {
+		return invocation{}, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return invocation{}, fmt.Errorf("lambda next invocation: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return invocation{}, fmt.Errorf("lambda next invocation: %s", resp.Status)
+	}
+	event, err := io.ReadAll(io.LimitReader(resp.Body, maxEventBytes))
+	if err != nil {
+		return invocation{}, fmt.Errorf("lambda next invocation: %w", err)
+	}
+	inv := invocation{id: resp.Header.Get("Lambda-Runtime-Aws-Request-Id"), event: event}
+	if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+		inv.deadline = time.UnixMilli(ms)
+	}
+	if inv.id == "" {
+		return invocation{}, fmt.Errorf("lambda next invocation: no request ID")
+	}
+	return inv, nil
+}
+
+// invoke runs handler and reports its outcome. Only a failure to report
+// is returned: a handler error is the invocation's, not the runtime's.
+func (c *client) invoke(ctx context.Context, inv invocation, handler Handler) error {
+	hctx := ctx
+	if !inv.deadline.IsZero() {
+		var cancel context.CancelFunc
+		hctx, cancel = context.WithDeadline(ctx, inv.deadline)
+		defer cancel()
+	}
+	out, err := handler(hctx, inv.event)
+	if err != nil {
+		body, _ := json.Marshal(map[string]string{"errorMessage": err.Error(), "errorType": "TigerfetchError"})
+		return c.post(inv.id, "error", body)
+	}
+	return c.post(inv.id, "response", out)
+}
+
+// post reports on an invocation. It does not take the handler's context:
+// the result is worth delivering even when the run was cut short.
+func (c *client) post(id, kind string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+"/runtime/invocation/"+id+"/"+kind, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if kind == "error" {
+		req.Header.Set("Lambda-Runtime-Function-Error-Type", "TigerfetchError")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("lambda %s: %w", kind, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("lambda %s: %s", kind, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime serves queued events on the Runtime API and records results.
+type fakeRuntime struct {
+	mu      sync.Mutex
+	events  []string
+	served  int
+	results map[string]string // request ID: "response: ..." or "error: ..."
+	done    chan struct{}     // closed when every event has a result
+}
+
+func (f *fakeRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/2018-06-01/runtime/invocation/next":
+		if len(f.events) == 0 {
+			f.mu.Unlock()
+			<-r.Context().Done() // like Lambda: block until the next invocation
+			f.mu.Lock()
+			return
+		}
+		f.served++
+		id := strconv.Itoa(f.served)
+		w.Header().Set("Lambda-Runtime-Aws-Request-Id", id)
+		w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(time.Now().Add(time.Second).UnixMilli(), 10))
+		_, _ = io.WriteString(w, f.events[0])
+		f.events = f.events[1:]
+	case r.Method == http.MethodPost:
+		parts := strings.Split(r.URL.Path, "/") // /2018-06-01/runtime/invocation/{id}/{kind}
+		body, _ := io.ReadAll(r.Body)
+		f.results[parts[4]] = parts[5] + ": " + string(body)
+		w.WriteHeader(http.StatusAccepted)
+		if len(f.events) == 0 {
+			close(f.done)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestServe(t *testing.T) {
+	rt := &fakeRuntime{events: []string{`{"n":1}`, `{"fail":true}`}, results: map[string]string{}, done: make(chan struct{})}
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		errc <- Serve(ctx, strings.TrimPrefix(srv.URL, "http://"), func(ctx context.Context, event []byte) ([]byte, error) {
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+			if strings.Contains(string(event), "fail") {
+				return nil, errors.New("nvd: 503 Service Unavailable")
+			}
+			return []byte(`{"ok":true}`), nil
+		})
+	}()
+	select {
+	case <-rt.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("invocations not completed")
+	}
+	cancel()
+	require.NoError(t, <-errc)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	assert.Equal(t, map[string]string{
+		"1": `response: {"ok":true}`,
+		"2": `error: {"errorMessage":"nvd: 503 Service Unavailable","errorType":"TigerfetchError"}`,
+	}, rt.results)
+}
@@ -0,0 +1,190 @@
+package maillist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLiteral bounds one message fetched; list posts with patches attached
+// run to a few hundred KB.
+const maxLiteral = 16 << 20
+
+// literalSuffix ends a response line announcing a literal: {size}.
+var literalSuffix = regexp.MustCompile(`\{(\d+)\}$`)
+
+// imapConn is the little of IMAP4rev1 (RFC 3501) a poller needs: LOGIN,
+// EXAMINE, UID SEARCH, UID FETCH and LOGOUT, one command at a time.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// response is one untagged response line with the literals it carried.
+type response struct {
+	text     string
+	literals [][]byte
+}
+
+// newIMAPConn reads the server greeting of conn.
+func newIMAPConn(conn net.Conn) (*imapConn, error) {
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, _, err := c.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, fmt.Errorf("imap greeting: %q", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapConn) login(user, password string) error {
+	_, err := c.command("LOGIN " + quote(user) + " " + quote(password))
+	return err
+}
+
+// examine opens mailbox read-only and returns its UIDVALIDITY: UIDs
+// only keep their meaning while it stays the same.
+func (c *imapConn) examine(mailbox string) (uint32, error) {
+	resps, err := c.command("EXAMINE " + quote(mailbox))
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range resps {
+		if _, rest, ok := strings.Cut(r.text, "[UIDVALIDITY "); ok {
+			v, _, _ := strings.Cut(rest, "]")
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("imap UIDVALIDITY %q: %w", v, err)
+			}
+			return uint32(n), nil
+		}
+	}
+	return 0, errors.New("imap: mailbox has no UIDVALIDITY")
+}
+
+// searchUIDs returns the UIDs matching criteria in ascending order.
+func (c *imapConn) searchUIDs(criteria string) ([]uint32, error) {
+	resps, err := c.command("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		rest, ok := strings.CutPrefix(r.text, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for f := range strings.FieldsSeq(rest) {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("imap SEARCH result %q: %w", f, err)
+			}
+			uids = append(uids, uint32(n))
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+// fetch returns the raw message of uid, without setting \Seen.
+func (c *imapConn) fetch(uid uint32) ([]byte, error) {
+	resps, err := c.command(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps {
+		if strings.Contains(r.text, " FETCH ") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: no message with UID %d", uid)
+}
+
+func (c *imapConn) logout() {
+	_, _ = c.command("LOGOUT")
+}
+
+// command sends one command and returns its untagged responses, or an
+// error when it does not complete with OK.
+func (c *imapConn) command(cmd string) ([]response, error) {
+	c.tag++
+	tag := "t" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+	verb, _, _ := strings.Cut(cmd, " ")
+	var resps []response
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("imap %s: %w", verb, err)
+		}
+		if rest, ok := strings.CutPrefix(r.text, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, fmt.Errorf("imap %s: %s", verb, rest)
+			}
+			return resps, nil
+		}
+		resps = append(resps, r)
+	}
+}
+
+// readResponse reads one response line, following literals to its end.
+func (c *imapConn) readResponse() (response, error) {
+	var r response
+	for {
+		line, size, err := c.readLine()
+		if err != nil {
+			return r, err
+		}
+		r.text += line
+		if size < 0 {
+			return r, nil
+		}
+		lit := make([]byte, size)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return r, err
+		}
+		r.literals = append(r.literals, lit)
+	}
+}
+
+// readLine reads a line without its CRLF, and the size of the literal it
+// announces, -1 for none.
+func (c *imapConn) readLine() (string, int, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", -1, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	m := literalSuffix.FindStringSubmatch(line)
+	if m == nil {
+		return line, -1, nil
+	}
+	size, err := strconv.Atoi(m[1])
+	if err != nil || size > maxLiteral {
+		return "", -1, fmt.Errorf("literal of %s bytes exceeds %d", m[1], maxLiteral)
+	}
+	return line, size, nil
+}
+
+// quote makes s an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// searchSince is the SEARCH criterion for messages received on or after
+// t's day.
+func searchSince(t time.Time) string {
+	return "SINCE " + t.UTC().Format("2-Jan-2006")
+}
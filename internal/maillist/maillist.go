@@ -0,0 +1,331 @@
+// Package maillist ingests advisories that only arrive by email, such as
+// oss-security and vendor PSIRT lists. A Runner polls the IMAP mailbox of
+// each list read-only for posts newer than its cursor, parses them (plain
+// text and HTML) and stores them with the feed client as the items of a
+// feed named after the list, at mail:{name}. From there they take the feed
+// path: CVE IDs in the subject and body are found like those of feed items,
+// and are listed as the post's categories too.
+package maillist
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cveid"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mmcdole/gofeed"
+)
+
+// CursorPrefix + list name is the ingest_state source of a list's cursor,
+// "UIDVALIDITY:UID" of the last post taken.
+const CursorPrefix = "MAIL:"
+
+// defaultMaxMessages bounds the posts taken per list and run unless
+// max_messages is set; a backlog is worked off over the following runs.
+const defaultMaxMessages = 200
+
+// sessionTimeout bounds one poll of a mailbox.
+const sessionTimeout = 5 * time.Minute
+
+// summaryRunes bounds the summary taken from a post's first paragraph.
+const summaryRunes = 300
+
+// Store keeps the posts of a poll; *ingestor.Client implements it.
+type Store interface {
+	Store(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) (int, error)
+}
+
+// FeedURL is the feed URL the posts of list name are stored under.
+func FeedURL(name string) string { return "mail:" + name }
+
+// FormatCursor renders the cursor after uid in a mailbox with validity.
+func FormatCursor(validity, uid uint32) string {
+	return fmt.Sprintf("%d:%d", validity, uid)
+}
+
+// ParseCursor reads a cursor written by FormatCursor.
+func ParseCursor(s string) (validity, uid uint32, err error) {
+	v, u, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("want UIDVALIDITY:UID, not %q", s)
+	}
+	vv, err1 := strconv.ParseUint(v, 10, 32)
+	uu, err2 := strconv.ParseUint(u, 10, 32)
+	if err := errors.Join(err1, err2); err != nil {
+		return 0, 0, fmt.Errorf("want UIDVALIDITY:UID, not %q: %w", s, err)
+	}
+	return uint32(vv), uint32(uu), nil
+}
+
+type list struct {
+	cfg      config.MailListConfig
+	addr     string
+	password string
+	mailbox  string
+	feed     config.Feed
+}
+
+// Runner polls the configured lists.
+type Runner struct {
+	db          *pgxpool.Pool
+	store       Store
+	lists       map[string]list
+	names       []string
+	lookback    time.Duration
+	maxMessages int
+	dial        func(ctx context.Context, addr string) (net.Conn, error)
+	now         func() time.Time
+}
+
+// NewRunner returns the runner of cfg's lists. Every list needs a unique
+// name, a server and a login; a password_env that is not set is an error.
+func NewRunner(db *pgxpool.Pool, store Store, cfg config.MailConfig) (*Runner, error) {
+	lookback, err := cfg.GetLookback()
+	if err != nil || lookback <= 0 {
+		return nil, fmt.Errorf("invalid mail.lookback %q", cfg.Lookback)
+	}
+	r := &Runner{
+		db:          db,
+		store:       store,
+		lists:       make(map[string]list, len(cfg.Lists)),
+		lookback:    lookback,
+		maxMessages: cmp.Or(max(cfg.MaxMessages, 0), defaultMaxMessages),
+		dial:        dialTLS,
+		now:         time.Now,
+	}
+	for _, lc := range cfg.Lists {
+		if lc.Name == "" {
+			return nil, errors.New("mailing list without a name")
+		}
+		if _, dup := r.lists[lc.Name]; dup {
+			return nil, fmt.Errorf("mailing list %q is configured twice", lc.Name)
+		}
+		if lc.Server == "" || lc.Username == "" {
+			return nil, fmt.Errorf("mailing list %q needs a server and a username", lc.Name)
+		}
+		l := list{
+			cfg:      lc,
+			addr:     lc.Server,
+			password: lc.Password,
+			mailbox:  cmp.Or(lc.Mailbox, "INBOX"),
+			feed:     config.Feed{Name: lc.Name, URL: FeedURL(lc.Name)},
+		}
+		if _, _, err := net.SplitHostPort(l.addr); err != nil {
+			l.addr = net.JoinHostPort(l.addr, "993")
+		}
+		if lc.PasswordEnv != "" {
+			if l.password = os.Getenv(lc.PasswordEnv); l.password == "" {
+				return nil, fmt.Errorf("mailing list %q: %s is not set", lc.Name, lc.PasswordEnv)
+			}
+		}
+		r.lists[lc.Name] = l
+		r.names = append(r.names, lc.Name)
+	}
+	return r, nil
+}
+
+// Names returns the configured lists, one job each.
+func (r *Runner) Names() []string { return r.names }
+
+// SetDialer replaces the TLS dialer, for tests.
+func (r *Runner) SetDialer(dial func(ctx context.Context, addr string) (net.Conn, error)) {
+	r.dial = dial
+}
+
+func dialTLS(ctx context.Context, addr string) (net.Conn, error) {
+	d := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 30 * time.Second}}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// Run stores the posts of list name that arrived since its cursor, at most
+// max_messages, and moves the cursor past them. Without a cursor, or when
+// the mailbox's UIDVALIDITY changed, it takes the posts of the lookback.
+func (r *Runner) Run(ctx context.Context, name string) error {
+	l, ok := r.lists[name]
+	if !ok {
+		return fmt.Errorf("mailing list %q is not configured", name)
+	}
+	var validity, last uint32
+	hasCursor := true
+	var raw string
+	err := r.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", CursorPrefix+name).Scan(&raw)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		hasCursor = false
+	case err != nil:
+		return fmt.Errorf("read cursor of %s: %w", name, err)
+	default:
+		if validity, last, err = ParseCursor(raw); err != nil {
+			slog.Warn("Invalid mailing list cursor, starting from the lookback", "list", name, "error", err)
+			hasCursor = false
+		}
+	}
+
+	feed, newValidity, newLast, err := r.poll(ctx, l, hasCursor, validity, last)
+	if err != nil {
+		return err
+	}
+	if len(feed.Items) > 0 {
+		n, err := r.store.Store(ctx, l.feed, feed, nil)
+		if err != nil {
+			return fmt.Errorf("store posts of %s: %w", name, err)
+		}
+		slog.Info("Mailing list posts stored", "list", name, "posts", len(feed.Items), "stored", n)
+	}
+	if newValidity == validity && newLast == last && hasCursor {
+		return nil
+	}
+	if newLast == 0 {
+		return nil // nothing in the lookback yet: search it again next run
+	}
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO ingest_state (source, cursor) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+	`, CursorPrefix+name, FormatCursor(newValidity, newLast)); err != nil {
+		return fmt.Errorf("save cursor of %s: %w", name, err)
+	}
+	return nil
+}
+
+// poll reads the posts of l after UID last, or of the lookback when there
+// is no cursor or validity no longer holds, and returns them with the
+// cursor after them.
+func (r *Runner) poll(ctx context.Context, l list, hasCursor bool, validity, last uint32) (*gofeed.Feed, uint32, uint32, error) {
+	conn, err := r.dial(ctx, l.addr)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("connect to %s: %w", l.addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	_ = conn.SetDeadline(time.Now().Add(sessionTimeout))
+
+	c, err := newIMAPConn(conn)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if err := c.login(l.cfg.Username, l.password); err != nil {
+		return nil, 0, 0, err
+	}
+	current, err := c.examine(l.mailbox)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	criteria := fmt.Sprintf("UID %d:*", last+1)
+	if !hasCursor || current != validity {
+		if hasCursor {
+			slog.Warn("Mailbox UIDVALIDITY changed, taking the lookback again", "list", l.cfg.Name,
+				"was", validity, "now", current)
+		}
+		criteria, last = searchSince(r.now().Add(-r.lookback)), 0
+	}
+	uids, err := c.searchUIDs(criteria)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// "UID n:*" always matches the newest message, even below n
+	uids = slices.DeleteFunc(uids, func(uid uint32) bool { return uid <= last })
+	if len(uids) > r.maxMessages {
+		slog.Info("More new posts than max_messages, taking the oldest", "list", l.cfg.Name,
+			"posts", len(uids), "max_messages", r.maxMessages)
+		uids = uids[:r.maxMessages]
+	}
+
+	feed := &gofeed.Feed{Title: l.cfg.Name, FeedType: "email", FeedVersion: "imap"}
+	for _, uid := range uids {
+		raw, err := c.fetch(uid)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		m, err := ParseMessage(raw)
+		if err != nil {
+			metrics.MailMessages.WithLabelValues(l.cfg.Name, "invalid").Inc()
+			slog.Warn("Unparsable mailing list post skipped", "list", l.cfg.Name, "uid", uid, "error", err)
+			continue
+		}
+		if !l.wants(m) {
+			metrics.MailMessages.WithLabelValues(l.cfg.Name, "skipped").Inc()
+			continue
+		}
+		metrics.MailMessages.WithLabelValues(l.cfg.Name, "taken").Inc()
+		feed.Items = append(feed.Items, l.item(m, raw))
+	}
+	c.logout()
+	if len(uids) > 0 {
+		last = uids[len(uids)-1]
+	}
+	return feed, current, last, nil
+}
+
+// wants reports whether m is a post of l to store.
+func (l list) wants(m Message) bool {
+	if l.cfg.ListID != "" && !strings.Contains(strings.ToLower(m.ListID), strings.ToLower(l.cfg.ListID)) {
+		return false
+	}
+	return !l.cfg.SkipReplies || !m.IsReply()
+}
+
+// item turns m into a feed item: keyed by Message-ID (a digest of raw
+// without one), HTML content as sent or plain text preformatted, the
+// first paragraph as summary and the CVE IDs it names as categories.
+func (l list) item(m Message, raw []byte) *gofeed.Item {
+	guid := m.ID
+	if guid == "" {
+		sum := sha256.Sum256(raw)
+		guid = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	content := m.HTML
+	if content == "" && m.Text != "" {
+		content = "<pre>" + html.EscapeString(m.Text) + "</pre>"
+	}
+	item := &gofeed.Item{
+		GUID:            guid,
+		Title:           m.Subject,
+		Description:     html.EscapeString(firstParagraph(m.Text)),
+		Content:         content,
+		PublishedParsed: m.Date,
+		Categories:      cveid.Extract(m.Subject, m.Text, m.HTML),
+	}
+	if m.From != "" {
+		item.Authors = []*gofeed.Person{{Name: m.From}}
+	}
+	if l.cfg.Link != "" && m.ID != "" {
+		item.Link = strings.ReplaceAll(l.cfg.Link, "{message_id}", url.PathEscape(m.ID))
+	}
+	return item
+}
+
+// firstParagraph returns the first paragraph of text that is not a quote
+// or a greeting line, on one line and at most summaryRunes long.
+func firstParagraph(text string) string {
+	for para := range strings.SplitSeq(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		para = strings.Join(strings.Fields(para), " ")
+		if para == "" || strings.HasPrefix(para, ">") || strings.HasSuffix(para, ",") && len(para) < 40 {
+			continue
+		}
+		if utf8.RuneCountInString(para) > summaryRunes {
+			para = string([]rune(para)[:summaryRunes]) + "…"
+		}
+		return para
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package maillist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStore struct{}
+
+func (stubStore) Store(_ context.Context, _ config.Feed, feed *gofeed.Feed, _ []string) (int, error) {
+	return len(feed.Items), nil
+}
+
+// fakeIMAP serves one session of a read-only mailbox with validity and
+// messages by UID, recording the commands it got.
+type fakeIMAP struct {
+	validity uint32
+	messages map[uint32]string
+	commands []string
+}
+
+func (f *fakeIMAP) dial(context.Context, string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go f.serve(server)
+	return client, nil
+}
+
+func (f *fakeIMAP) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		f.commands = append(f.commands, cmd)
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN "):
+			if cmd != `LOGIN "bot" "pw"` {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] Invalid credentials\r\n", tag)
+				continue
+			}
+		case strings.HasPrefix(cmd, "EXAMINE "):
+			fmt.Fprintf(conn, "* %d EXISTS\r\n* OK [UIDVALIDITY %d] UIDs valid\r\n", len(f.messages), f.validity)
+		case strings.HasPrefix(cmd, "UID SEARCH "):
+			var uids []string
+			for uid := range f.messages {
+				uids = append(uids, fmt.Sprint(uid))
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(cmd, "UID FETCH "):
+			var uid uint32
+			_, _ = fmt.Sscanf(cmd, "UID FETCH %d", &uid)
+			msg := f.messages[uid]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", uid, len(msg), msg)
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func post(id, subject, extra, body string) string {
+	return string(crlf(fmt.Sprintf("Message-ID: <%s>\nFrom: Alice <alice@example.org>\nSubject: %s\n"+
+		"Date: Thu, 01 Oct 2026 10:00:00 +0000\nList-Id: <oss-security.lists.openwall.com>\n%s\n%s", id, subject, extra, body)))
+}
+
+func testRunner(t *testing.T, f *fakeIMAP, lc config.MailListConfig) (*Runner, list) {
+	t.Helper()
+	lc.Name, lc.Server, lc.Username, lc.Password = "oss-security", "imap.example.org", "bot", "pw"
+	r, err := NewRunner(nil, stubStore{}, config.MailConfig{Lookback: "168h", MaxMessages: 2, Lists: []config.MailListConfig{lc}})
+	require.NoError(t, err)
+	r.SetDialer(f.dial)
+	r.now = func() time.Time { return time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC) }
+	return r, r.lists["oss-security"]
+}
+
+func TestPoll(t *testing.T) {
+	f := &fakeIMAP{validity: 7, messages: map[uint32]string{
+		3: post("a@x", "CVE-2026-1111 in libfoo", "", "libfoo 1.2 overflows.\n\nDetails & patch follow.\n"),
+		4: post("b@x", "Re: CVE-2026-1111 in libfoo", "In-Reply-To: <a@x>\n", "Thanks.\n"),
+		5: post("c@x", "CVE-2026-2222", "", "Later post.\n"),
+	}}
+	r, l := testRunner(t, f, config.MailListConfig{SkipReplies: true, Link: "https://lists.example/{message_id}"})
+	assert.Equal(t, "imap.example.org:993", l.addr)
+
+	feed, validity, last, err := r.poll(t.Context(), l, false, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), validity)
+	assert.Equal(t, uint32(4), last, "max_messages caps the oldest posts")
+	assert.Contains(t, f.commands, "UID SEARCH SINCE 1-Oct-2026")
+	assert.Contains(t, f.commands, "UID FETCH 3 BODY.PEEK[]")
+
+	require.Len(t, feed.Items, 1, "reply skipped")
+	item := feed.Items[0]
+	assert.Equal(t, "a@x", item.GUID)
+	assert.Equal(t, "CVE-2026-1111 in libfoo", item.Title)
+	assert.Equal(t, "libfoo 1.2 overflows.", item.Description)
+	assert.Equal(t, "<pre>libfoo 1.2 overflows.\r\n\r\nDetails &amp; patch follow.\r\n</pre>", item.Content)
+	assert.Equal(t, "https://lists.example/a@x", item.Link)
+	assert.Equal(t, "Alice", item.Authors[0].Name)
+	assert.Equal(t, []string{"CVE-2026-1111"}, item.Categories)
+
+	f.commands = nil
+	feed, validity, last, err = r.poll(t.Context(), l, true, 7, 4)
+	require.NoError(t, err)
+	assert.Contains(t, f.commands, "UID SEARCH UID 5:*")
+	assert.Equal(t, []string{"c@x"}, []string{feed.Items[0].GUID})
+	assert.Equal(t, uint32(7), validity)
+	assert.Equal(t, uint32(5), last)
+
+	f.commands = nil
+	_, _, last, err = r.poll(t.Context(), l, true, 6, 5)
+	require.NoError(t, err)
+	assert.Contains(t, f.commands, "UID SEARCH SINCE 1-Oct-2026", "new UIDVALIDITY: lookback again")
+	assert.Equal(t, uint32(4), last)
+}
+
+func TestPoll_ListID(t *testing.T) {
+	f := &fakeIMAP{validity: 1, messages: map[uint32]string{1: post("a@x", "Hello", "", "Hi.\n")}}
+	r, l := testRunner(t, f, config.MailListConfig{ListID: "other.example.org"})
+	feed, _, last, err := r.poll(t.Context(), l, false, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, feed.Items)
+	assert.Equal(t, uint32(1), last, "skipped posts move the cursor too")
+
+	l.password = "wrong"
+	_, _, _, err = r.poll(t.Context(), l, false, 0, 0)
+	assert.ErrorContains(t, err, "AUTHENTICATIONFAILED")
+}
+
+func TestNewRunner_Config(t *testing.T) {
+	t.Setenv("MAIL_TEST_EMPTY", "")
+	for name, lists := range map[string][]config.MailListConfig{
+		"no name":        {{Server: "imap", Username: "u"}},
+		"no server":      {{Name: "a", Username: "u"}},
+		"duplicate":      {{Name: "a", Server: "imap", Username: "u"}, {Name: "a", Server: "imap", Username: "u"}},
+		"password unset": {{Name: "a", Server: "imap", Username: "u", PasswordEnv: "MAIL_TEST_EMPTY"}},
+	} {
+		_, err := NewRunner(nil, stubStore{}, config.MailConfig{Lists: lists})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	v, uid, err := ParseCursor(FormatCursor(7, 120))
+	require.NoError(t, err)
+	assert.Equal(t, [2]uint32{7, 120}, [2]uint32{v, uid})
+	for _, s := range []string{"", "7", "7:", "x:1", "7:-1", "7:4294967296"} {
+		_, _, err := ParseCursor(s)
+		assert.Error(t, err, s)
+	}
+}
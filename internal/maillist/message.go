@@ -0,0 +1,165 @@
+package maillist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxParts bounds the MIME parts walked per message.
+const maxParts = 100
+
+// Message is the part of a list post stored as an advisory.
+type Message struct {
+	ID        string     // Message-ID without angle brackets
+	Subject   string     // decoded
+	From      string     // display name, else address
+	Date      *time.Time // nil when missing or unparsable
+	ListID    string     // List-Id header, e.g. "<oss-security.lists.openwall.com>"
+	InReplyTo string
+	Text      string // first text/plain part, decoded
+	HTML      string // first text/html part, decoded
+}
+
+// IsReply reports whether m answers another post.
+func (m Message) IsReply() bool {
+	s := strings.ToLower(strings.TrimSpace(m.Subject))
+	return m.InReplyTo != "" || strings.HasPrefix(s, "re:") || strings.HasPrefix(s, "aw:")
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// ParseMessage reads an RFC 5322 message with its MIME parts. Parts other
+// than text, and attachments, are skipped; text in other charsets is
+// converted to UTF-8.
+func ParseMessage(raw []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, err
+	}
+	h := msg.Header
+	m := Message{
+		ID:        strings.Trim(strings.TrimSpace(h.Get("Message-Id")), "<>"),
+		Subject:   decodeHeader(h.Get("Subject")),
+		ListID:    strings.TrimSpace(h.Get("List-Id")),
+		InReplyTo: strings.TrimSpace(h.Get("In-Reply-To")),
+	}
+	if addr, err := parseAddress(h.Get("From")); err == nil {
+		m.From = addr.Name
+		if m.From == "" {
+			m.From = addr.Address
+		}
+	} else {
+		m.From = decodeHeader(h.Get("From"))
+	}
+	if d, err := h.Date(); err == nil {
+		d = d.UTC()
+		m.Date = &d
+	}
+	parts := 0
+	if err := m.walk(mimePart{header: h, body: msg.Body}, &parts); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// mimePart is a message or one of its parts.
+type mimePart struct {
+	header interface{ Get(string) string }
+	body   io.Reader
+}
+
+// walk collects the first plain and HTML text of p and its parts.
+func (m *Message) walk(p mimePart, parts *int) error {
+	if *parts++; *parts > maxParts {
+		return fmt.Errorf("more than %d MIME parts", maxParts)
+	}
+	if disp, _, _ := mime.ParseMediaType(p.header.Get("Content-Disposition")); disp == "attachment" {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(p.header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{} // RFC 2045 default
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(p.body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("multipart: %w", err)
+			}
+			if err := m.walk(mimePart{header: part.Header, body: part}, parts); err != nil {
+				return err
+			}
+		}
+	case mediaType == "text/plain" && m.Text == "", mediaType == "text/html" && m.HTML == "":
+		text, err := decodeBody(p.body, p.header.Get("Content-Transfer-Encoding"), params["charset"])
+		if err != nil {
+			return err
+		}
+		if mediaType == "text/plain" {
+			m.Text = text
+		} else {
+			m.HTML = text
+		}
+	}
+	return nil
+}
+
+// decodeBody undoes the transfer encoding of body and converts it from
+// charset to UTF-8.
+func decodeBody(body io.Reader, encoding, charset string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body) // skips line breaks
+	}
+	if charset != "" && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "us-ascii") {
+		r, err := charsetReader(charset, body)
+		if err != nil {
+			return "", err
+		}
+		body = r
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("decode body: %w", err)
+	}
+	return strings.ToValidUTF8(string(b), "�"), nil
+}
+
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("charset %q: %w", charset, err)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping s when it cannot.
+func decodeHeader(s string) string {
+	if d, err := wordDecoder.DecodeHeader(s); err == nil {
+		s = d
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func parseAddress(s string) (*mail.Address, error) {
+	p := mail.AddressParser{WordDecoder: wordDecoder}
+	return p.Parse(s)
+}
@@ -0,0 +1,76 @@
+package maillist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func crlf(s string) []byte { return []byte(strings.ReplaceAll(s, "\n", "\r\n")) }
+
+func TestParseMessage_Multipart(t *testing.T) {
+	raw := crlf(`From: =?UTF-8?Q?Ren=C3=A9_Dupont?= <rene@example.org>
+To: oss-security@lists.openwall.com
+Subject: =?ISO-8859-1?Q?[oss-security]_CVE-2026-1234:_d=E9j=E0_vu?=
+Date: Thu, 01 Oct 2026 10:00:00 +0200
+Message-ID: <20261001.abc@example.org>
+List-Id: <oss-security.lists.openwall.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+A heap overflow in libfoo allows remote code execution. Fixed in 1.2=
+.3.
+--inner
+Content-Type: text/html; charset=iso-8859-1
+Content-Transfer-Encoding: base64
+
+PHA+ZOlq4CB2dTwvcD4=
+--inner--
+--outer
+Content-Type: text/plain
+Content-Disposition: attachment; filename="fix.patch"
+
+--- a/foo.c
+--outer--
+`)
+	m, err := ParseMessage(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "20261001.abc@example.org", m.ID)
+	assert.Equal(t, "[oss-security] CVE-2026-1234: déjà vu", m.Subject)
+	assert.Equal(t, "René Dupont", m.From)
+	assert.Equal(t, "<oss-security.lists.openwall.com>", m.ListID)
+	require.NotNil(t, m.Date)
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), *m.Date)
+	assert.Equal(t, "A heap overflow in libfoo allows remote code execution. Fixed in 1.2.3.", strings.TrimSpace(m.Text))
+	assert.Equal(t, "<p>déjà vu</p>", m.HTML)
+	assert.False(t, m.IsReply())
+}
+
+func TestParseMessage_Plain(t *testing.T) {
+	raw := crlf(`From: psirt@vendor.example
+Subject: Re: CVE-2026-1234
+In-Reply-To: <20261001.abc@example.org>
+
+Not a multipart message.
+`)
+	m, err := ParseMessage(raw)
+	require.NoError(t, err)
+	assert.Empty(t, m.ID)
+	assert.Nil(t, m.Date)
+	assert.Equal(t, "psirt@vendor.example", m.From)
+	assert.Equal(t, "Not a multipart message.\r\n", m.Text)
+	assert.True(t, m.IsReply())
+
+	_, err = ParseMessage([]byte("no header block"))
+	assert.Error(t, err)
+}
@@ -32,6 +32,32 @@ var FeedItemsNew = promauto.NewCounterVec(prometheus.CounterOpts{
 	Help: "Items that were genuinely new (archive INSERT succeeded).",
 }, []string{"feed_name"})
 
+var FeedDetectionLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tigerfetch_feed_detection_latency_seconds",
+	Help:    "Time from an item's published date to tigerfetch first storing it (new dated items only).",
+	Buckets: []float64{300, 900, 3600, 6 * 3600, 86400, 3 * 86400, 7 * 86400, 30 * 86400},
+}, []string{"feed_name"})
+
+var FeedItemsTooOld = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_feed_items_too_old_total",
+	Help: "Items skipped because they are dated before the feed's max_item_age cutoff.",
+}, []string{"feed_name"})
+
+var FeedItemsTruncated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_feed_items_truncated_total",
+	Help: "Items left out because a fetch listed more than the feed's max_items.",
+}, []string{"feed_name"})
+
+var FeedLinksResolved = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_feed_links_resolved_total",
+	Help: "Item links through a redirector resolved to where they lead, by outcome (resolved, error).",
+}, []string{"outcome"})
+
+var FeedItemsWithdrawn = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_feed_items_withdrawn_total",
+	Help: "Advisories marked withdrawn after dropping out of their feed.",
+}, []string{"feed_name"})
+
 var FeedItemsUpdated = promauto.NewCounterVec(prometheus.CounterOpts{
 	Name: "tigerfetch_feed_items_updated_total",
 	Help: "Items that hit the ON CONFLICT UPDATE path in current.",
@@ -99,6 +125,16 @@ var NvdCursorLag = promauto.NewGauge(prometheus.GaugeOpts{
 	Help: "Seconds between NVD cursor and now.",
 })
 
+var ProductMonitorRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_product_monitor_runs_total",
+	Help: "NVD product monitor polls by product and outcome.",
+}, []string{"product", "status"})
+
+var ProductMonitorMatches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_product_monitor_matches_total",
+	Help: "CVEs newly matched to a monitored product.",
+}, []string{"product"})
+
 // ---------------------------------------------------------------------------
 // EPSS
 // ---------------------------------------------------------------------------
@@ -143,6 +179,31 @@ var KevVulnsProcessed = promauto.NewCounter(prometheus.CounterOpts{
 	Help: "Total KEV vulnerabilities upserted.",
 })
 
+var KevChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_kev_changes_total",
+	Help: "KEV catalog changes detected by type (added, updated, removed).",
+}, []string{"change_type"})
+
+var KevDateParseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_kev_date_parse_failures_total",
+	Help: "KEV date fields present but unparsable, by field.",
+}, []string{"field"})
+
+var DataQualityViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_data_quality_violations_total",
+	Help: "Ingested values that broke a data quality rule, by source and rule.",
+}, []string{"source", "rule"})
+
+var QuarantinedPayloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_quarantined_payloads_total",
+	Help: "Upstream payloads that failed to parse and were quarantined, by source.",
+}, []string{"source"})
+
+var ArchivedPayloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_archived_payloads_total",
+	Help: "Upstream payloads fetched while [raw_archive] is on, by source and result (stored, unchanged, error).",
+}, []string{"source", "result"})
+
 var KevRunDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 	Name:    "tigerfetch_kev_run_duration_seconds",
 	Help:    "Duration of a full KEV Run() cycle.",
@@ -189,6 +250,230 @@ var UpstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
 }, []string{"source"})
 
+var UpstreamHostRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_upstream_host_requests_total",
+	Help: "HTTP requests to upstream hosts, by host and status class (2xx, 3xx, 4xx, 5xx, error for no response).",
+}, []string{"host", "code"})
+
+var UpstreamHostLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tigerfetch_upstream_host_latency_seconds",
+	Help:    "Time from sending a request to an upstream host to its response headers.",
+	Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"host"})
+
+var UpstreamThrottleWait = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_upstream_throttle_wait_seconds_total",
+	Help: "Time requests were held back for an upstream host's rate limit, by host.",
+}, []string{"host"})
+
+var UpstreamThrottleEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_upstream_throttle_events_total",
+	Help: "Rate-limited requests to upstream hosts, by host and event (retried, refused when the wait would exceed max_rate_limit_wait).",
+}, []string{"host", "event"})
+
+var DNSLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_dns_lookups_total",
+	Help: "Host lookups of the [http] DNS resolver, by result (cached, resolved, stale when a failed lookup fell back to an expired answer, error).",
+}, []string{"result"})
+
+// ---------------------------------------------------------------------------
+// cve_enriched writes (NVD and KEV)
+// ---------------------------------------------------------------------------
+
+var CveUpserts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_cve_upserts_total",
+	Help: "cve_enriched rows by source and outcome (written, skipped_unchanged).",
+}, []string{"source", "outcome"})
+
+// ---------------------------------------------------------------------------
+// Database queries
+// ---------------------------------------------------------------------------
+
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tigerfetch_db_query_duration_seconds",
+	Help:    "Database statement, batch and COPY duration by query (verb and table).",
+	Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30},
+}, []string{"query"})
+
+var DBSlowQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_db_slow_queries_total",
+	Help: "Database queries slower than database.slow_query_threshold.",
+}, []string{"query"})
+
+// ---------------------------------------------------------------------------
+// Analytics sinks
+// ---------------------------------------------------------------------------
+
+var SinkRows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_sink_rows_total",
+	Help: "Rows sent to an analytics sink, by sink and table.",
+}, []string{"sink", "table"})
+
+var SinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_sink_errors_total",
+	Help: "Failed analytics sink inserts, by sink and table.",
+}, []string{"sink", "table"})
+
+// ---------------------------------------------------------------------------
+// Lookup API
+// ---------------------------------------------------------------------------
+
+var APIRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_api_rejected_total",
+	Help: "API requests refused, by client (key name or \"anonymous\") and reason (unauthorized, forbidden, rate, quota).",
+}, []string{"client", "reason"})
+
+var InboundRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_inbound_requests_total",
+	Help: "Webhook pushes by inbound source and result (stored, ignored, unauthorized, invalid, too_large, error).",
+}, []string{"source", "result"})
+
+var MailMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_mail_messages_total",
+	Help: "Mailing list posts fetched by list and result (taken, skipped, invalid).",
+}, []string{"list", "result"})
+
+var SocialPosts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_social_posts_total",
+	Help: "Social and community posts read by source and result (taken, skipped).",
+}, []string{"source", "result"})
+
+var EnrichUpstreamSkipped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "tigerfetch_enrich_upstream_skipped_total",
+	Help: "Upstream enrichment lookups skipped for CVE IDs that NVD and EPSS recently did not return.",
+})
+
+// ---------------------------------------------------------------------------
+// Remote instance sync (federation)
+// ---------------------------------------------------------------------------
+
+var RemoteRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_remote_runs_total",
+	Help: "Remote sync outcomes (success, error).",
+}, []string{"status"})
+
+var RemoteChangesApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_remote_changes_applied_total",
+	Help: "Changes applied from the remote instance's feed, by kind (advisory, cve, kev, epss).",
+}, []string{"kind"})
+
+var RemoteLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "tigerfetch_remote_last_success_timestamp",
+	Help: "Unix timestamp of the last remote sync that reached the end of the feed.",
+})
+
+// ---------------------------------------------------------------------------
+// Reference labels
+// ---------------------------------------------------------------------------
+
+var ReferenceLabels = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_reference_labels_total",
+	Help: "Vendor advisory titles fetched, by outcome (resolved, untitled, error, throttled, disallowed).",
+}, []string{"outcome"})
+
+// ---------------------------------------------------------------------------
+// Crawl politeness
+// ---------------------------------------------------------------------------
+
+var CrawlRobots = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_crawl_robots_fetches_total",
+	Help: "robots.txt files fetched by the crawl politeness layer, by result (ok, missing, unreachable).",
+}, []string{"result"})
+
+var CrawlDisallowed = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "tigerfetch_crawl_disallowed_total",
+	Help: "Page requests not made because robots.txt disallows them.",
+})
+
+// ---------------------------------------------------------------------------
+// Detection rules
+// ---------------------------------------------------------------------------
+
+var DetectionRules = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tigerfetch_detection_rules",
+	Help: "Stored detection rules referencing a CVE, by source (sigma, nuclei, etopen).",
+}, []string{"source"})
+
+var DetectionFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_detection_fetches_total",
+	Help: "Detection rule downloads, by source and outcome (success, error).",
+}, []string{"source", "outcome"})
+
+// ---------------------------------------------------------------------------
+// End-of-life releases
+// ---------------------------------------------------------------------------
+
+var EOLReleases = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "tigerfetch_eol_releases",
+	Help: "Stored endoflife.date release cycles of products with a CPE identifier.",
+})
+
+var EOLFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_eol_fetches_total",
+	Help: "endoflife.date downloads, by outcome (success, error).",
+}, []string{"outcome"})
+
+// ---------------------------------------------------------------------------
+// Coverage gaps
+// ---------------------------------------------------------------------------
+
+var CoverageGaps = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tigerfetch_coverage_gaps",
+	Help: "CVEs referenced by advisories still without an EPSS score or NVD record, by what is missing (epss, nvd).",
+}, []string{"missing"})
+
+// ---------------------------------------------------------------------------
+// Consolidated CVE records
+// ---------------------------------------------------------------------------
+
+var ConsolidatedRecords = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "tigerfetch_consolidated_records_total",
+	Help: "Consolidated CVE records written because a source record changed.",
+})
+
+// ---------------------------------------------------------------------------
+// Scheduler
+// ---------------------------------------------------------------------------
+
+var SchedulerRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tigerfetch_scheduler_running",
+	Help: "Runs holding a [scheduler] slot, by source.",
+}, []string{"source"})
+
+var SchedulerQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tigerfetch_scheduler_queued",
+	Help: "Runs waiting for a [scheduler] slot, by source.",
+}, []string{"source"})
+
+var SchedulerWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tigerfetch_scheduler_wait_seconds",
+	Help:    "Time a run waited for a [scheduler] slot, by source.",
+	Buckets: []float64{0.01, 1, 10, 60, 300, 900, 3600},
+}, []string{"source"})
+
+// ---------------------------------------------------------------------------
+// Jobs
+// ---------------------------------------------------------------------------
+
+var JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_job_runs_total",
+	Help: "Ingestion job attempts, by kind and outcome (succeeded, retrying, dead).",
+}, []string{"kind", "outcome"})
+
+var Jobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tigerfetch_jobs",
+	Help: "Ingestion jobs in the jobs table, by state.",
+}, []string{"state"})
+
+// ---------------------------------------------------------------------------
+// Event summaries
+// ---------------------------------------------------------------------------
+
+var EventSummaries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tigerfetch_event_summaries_total",
+	Help: "LLM analyst notes for summary events, by outcome (generated, cached, error).",
+}, []string{"outcome"})
+
 // ---------------------------------------------------------------------------
 // App info
 // ---------------------------------------------------------------------------
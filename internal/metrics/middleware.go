@@ -34,7 +34,7 @@ func (w *responseWriter) WriteHeader(code int) {
 // cardinality explosion from arbitrary client-supplied paths.
 func normalizePath(path string) string {
 	switch path {
-	case "/metrics", "/healthz":
+	case "/metrics", "/healthz", "/enrich", "/openapi.json", "/cves", "/cves/records", "/advisories", "/changes":
 		return path
 	default:
 		return "other"
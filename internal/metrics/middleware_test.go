@@ -15,6 +15,9 @@ func TestNormalizePath(t *testing.T) {
 	}{
 		{"/metrics", "/metrics"},
 		{"/healthz", "/healthz"},
+		{"/enrich", "/enrich"},
+		{"/openapi.json", "/openapi.json"},
+		{"/changes", "/changes"},
 		{"/", "other"},
 		{"/admin", "other"},
 		{"/some/random/path", "other"},
@@ -0,0 +1,177 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/provenance"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ApplyStats counts the changes applied from one page, by kind.
+type ApplyStats map[string]int
+
+// Apply writes one page of changes to the local database and records
+// nextCursor under stateKey in ingest_state, all in one transaction, so an
+// interrupted sync resumes exactly where the last committed page ended.
+// prov describes the page fetch and is stored on advisory and CVE rows.
+//
+// Writes are idempotent upserts: replaying a page is harmless. Advisory
+// ids follow the source, also when it re-keys them, so both instances page
+// the same way.
+func Apply(ctx context.Context, db *pgxpool.Pool, changes []Change, stateKey, nextCursor string, prov provenance.Record) (ApplyStats, error) {
+	if err := ensurePartitions(ctx, db, changes); err != nil {
+		return nil, err
+	}
+
+	stats := ApplyStats{}
+	batch := &pgx.Batch{}
+	for _, c := range changes {
+		switch {
+		case c.Kind == KindAdvisory && c.Advisory != nil:
+			queueAdvisory(batch, c.Advisory, prov)
+		case c.Kind == KindCVE && c.CVE != nil:
+			queueCVE(batch, c.CVE, prov)
+		case c.Kind == KindEPSS && c.EPSS != nil:
+			queueEPSS(batch, c.EPSS)
+		case c.Kind == KindKEV && c.KEV != nil:
+			cve.QueueKevChanges(batch, []cve.KevChange{*c.KEV}, c.KEV.CatalogVersion, c.KEV.DateReleased)
+		default:
+			return nil, fmt.Errorf("malformed %q change at %s", c.Kind, c.ChangedAt.Format(time.RFC3339Nano))
+		}
+		stats[c.Kind]++
+	}
+	batch.Queue(`
+		INSERT INTO ingest_state (source, cursor) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET cursor = EXCLUDED.cursor
+	`, stateKey, nextCursor)
+
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apply changes: %w", err)
+	}
+	return stats, nil
+}
+
+// ensurePartitions creates the epss_daily partitions the page writes to.
+// DDL stays outside the apply transaction so concurrent EPSS loads are not
+// blocked on it.
+func ensurePartitions(ctx context.Context, db *pgxpool.Pool, changes []Change) error {
+	months := map[string]time.Time{}
+	for _, c := range changes {
+		if c.EPSS == nil {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", c.EPSS.Date)
+		if err != nil {
+			return fmt.Errorf("invalid EPSS date %q for %s: %w", c.EPSS.Date, c.EPSS.CVE, err)
+		}
+		months[d.Format("2006-01")] = d
+	}
+	for _, d := range months {
+		if err := cve.EnsureEpssPartition(ctx, db, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queueAdvisory(batch *pgx.Batch, a *ingestor.Advisory, prov provenance.Record) {
+	batch.Queue(`
+		INSERT INTO current (
+			id, guid, title, link, published, content, summary, author, categories,
+			entry_updated, feed_url, feed_title, feed_description, feed_language,
+			feed_updated, inserted_at, first_seen_at,
+			fetch_url, fetched_at, http_status, upstream_version, tool_version, withdrawn_at
+		) VALUES (
+			$1::uuid, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9,
+			$10, $11, NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''),
+			$15, NOW(), COALESCE($21, clock_timestamp()),
+			$16, $17, $18, $19, $20, $22
+		)
+		ON CONFLICT (guid, feed_url) DO UPDATE SET
+			id = EXCLUDED.id,
+			title = EXCLUDED.title,
+			link = EXCLUDED.link,
+			published = EXCLUDED.published,
+			content = EXCLUDED.content,
+			summary = EXCLUDED.summary,
+			author = EXCLUDED.author,
+			categories = EXCLUDED.categories,
+			entry_updated = EXCLUDED.entry_updated,
+			feed_title = EXCLUDED.feed_title,
+			feed_description = EXCLUDED.feed_description,
+			feed_language = EXCLUDED.feed_language,
+			feed_updated = EXCLUDED.feed_updated,
+			fetch_url = EXCLUDED.fetch_url,
+			fetched_at = EXCLUDED.fetched_at,
+			http_status = EXCLUDED.http_status,
+			upstream_version = EXCLUDED.upstream_version,
+			tool_version = EXCLUDED.tool_version,
+			withdrawn_at = EXCLUDED.withdrawn_at
+	`, a.ID, a.GUID, a.Title, a.Link, utc(a.Published), a.Content, a.Summary, a.Author, a.Categories,
+		utc(a.EntryUpdated), a.FeedURL, a.FeedTitle, a.FeedDescription, a.FeedLanguage,
+		utc(a.FeedUpdated),
+		prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion,
+		firstSeen(a.FirstSeenAt), utc(a.WithdrawnAt))
+}
+
+func queueCVE(batch *pgx.Batch, r *cve.EnrichedRecord, prov provenance.Record) {
+	batch.Queue(`
+		INSERT INTO cve_enriched (
+			cve_id, source, json, cvss_base, modified, content_hash,
+			fetch_url, fetched_at, http_status, upstream_version, tool_version, first_seen_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12, clock_timestamp()))
+		ON CONFLICT (cve_id, source)
+		DO UPDATE SET
+			json = EXCLUDED.json,
+			cvss_base = EXCLUDED.cvss_base,
+			modified = EXCLUDED.modified,
+			content_hash = EXCLUDED.content_hash,
+			fetch_url = EXCLUDED.fetch_url,
+			fetched_at = EXCLUDED.fetched_at,
+			http_status = EXCLUDED.http_status,
+			upstream_version = EXCLUDED.upstream_version,
+			tool_version = EXCLUDED.tool_version
+		WHERE cve_enriched.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+	`, r.CveID, r.Source, []byte(r.Data), r.CvssBase, r.Modified, cve.ContentHash(r.Data),
+		prov.FetchURL, prov.FetchedAt, prov.Status(), prov.Version(), prov.ToolVersion,
+		firstSeen(r.FirstSeenAt))
+}
+
+// queueEPSS inserts a score. epss_daily rows are never rewritten upstream,
+// so an existing row wins.
+func queueEPSS(batch *pgx.Batch, e *cve.EpssRow) {
+	batch.Queue(`
+		INSERT INTO epss_daily (as_of, cve_id, epss, percentile)
+		VALUES ($1::date, $2, $3::numeric, NULLIF($4, '')::numeric)
+		ON CONFLICT (as_of, cve_id) DO NOTHING
+	`, e.Date, e.CVE, e.EPSS, e.Percentile)
+}
+
+// utc converts t for a TIMESTAMP (without time zone) column: pgx writes the
+// wall-clock time and drops the zone, and these columns hold UTC.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// firstSeen keeps the remote's first-seen time for rows new to this
+// database. Zero (a remote predating first_seen_at) means now.
+func firstSeen(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,174 @@
+// Package mirror is tigerfetch's delta sync: a change feed that merges every
+// stored record type into one cursor-ordered stream (served as GET /changes),
+// and the client side that replays the feed into another instance's
+// database so downstream mirrors never re-pull everything.
+//
+// The feed covers advisories (current), NVD and KEV records (cve_enriched),
+// KEV catalog diffs (kev_changes) and EPSS scores (epss_daily). Rows are
+// ordered by their server-assigned change time, then kind and key, so the
+// cursor semantics are those of package cursor: a row that changes again
+// moves past the cursor and is delivered again.
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/ingestor"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Change kinds, in feed order for rows changed at the same instant.
+const (
+	KindAdvisory = "advisory"
+	KindCVE      = "cve"
+	KindEPSS     = "epss"
+	KindKEV      = "kev"
+)
+
+// CursorKeys is the number of key parts in a change feed cursor: the kind
+// and two kind-specific key columns.
+const CursorKeys = 3
+
+// Change is one record in the feed. Exactly one of the payload fields is
+// set, matching Kind.
+type Change struct {
+	Kind      string              `json:"kind"`
+	ChangedAt time.Time           `json:"changed_at"`
+	Advisory  *ingestor.Advisory  `json:"advisory,omitempty"`
+	CVE       *cve.EnrichedRecord `json:"cve,omitempty"`
+	KEV       *cve.KevChange      `json:"kev,omitempty"`
+	EPSS      *cve.EpssRow        `json:"epss,omitempty"`
+
+	key [2]string
+}
+
+// Cursor returns the position just after c.
+func (c Change) Cursor() cursor.Cursor {
+	return cursor.Cursor{UpdatedAt: c.ChangedAt, Key: []string{c.Kind, c.key[0], c.key[1]}}
+}
+
+// ChangePage is the /changes response.
+type ChangePage struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"next_cursor"`
+	HasMore    bool     `json:"has_more"`
+}
+
+// ListChanges returns one page of the change feed in
+// (changed_at, kind, key) order.
+//
+// Each branch of the union is limited on its own, using its table's change
+// time index, before the merge; kev_changes ids are zero-padded so they
+// compare as text in numeric order.
+func ListChanges(ctx context.Context, pool *pgxpool.Pool, page cursor.Page) ([]Change, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT changed_at, kind, k1, k2, payload FROM (
+			(SELECT updated_at AS changed_at, 'advisory' AS kind, id::text AS k1, '' AS k2,
+			        jsonb_build_object(
+			            'id', id, 'guid', guid, 'title', title, 'link', link,
+			            'published', published AT TIME ZONE 'UTC',
+			            'content', COALESCE(content, ''), 'summary', COALESCE(summary, ''),
+			            'author', COALESCE(author, ''), 'categories', COALESCE(categories, '{}'),
+			            'entry_updated', entry_updated AT TIME ZONE 'UTC',
+			            'feed_url', feed_url, 'feed_title', COALESCE(feed_title, ''),
+			            'feed_description', COALESCE(feed_description, ''),
+			            'feed_language', COALESCE(feed_language, ''),
+			            'feed_updated', feed_updated AT TIME ZONE 'UTC',
+			            'first_seen_at', first_seen_at, 'updated_at', updated_at,
+			            'withdrawn_at', withdrawn_at) AS payload
+			 FROM current
+			 WHERE updated_at >= $1
+			   AND (updated_at, 'advisory', id::text, '') > ($1, $2, $3, $4)
+			   AND updated_at < clock_timestamp() - $5::interval
+			 ORDER BY updated_at, id::text
+			 LIMIT $6)
+			UNION ALL
+			(SELECT updated_at, 'cve', cve_id, source,
+			        jsonb_build_object(
+			            'cve_id', cve_id, 'source', source, 'cvss_base', cvss_base::float8,
+			            'modified', modified, 'first_seen_at', first_seen_at,
+			            'updated_at', updated_at, 'data', json,
+			            'source_identifier', COALESCE(source_identifier, ''),
+			            'vuln_status', COALESCE(vuln_status, ''))
+			 FROM cve_enriched
+			 WHERE updated_at >= $1
+			   AND (updated_at, 'cve', cve_id, source) > ($1, $2, $3, $4)
+			   AND updated_at < clock_timestamp() - $5::interval
+			 ORDER BY updated_at, cve_id, source
+			 LIMIT $6)
+			UNION ALL
+			(SELECT inserted_at, 'epss', cve_id, as_of::text,
+			        jsonb_build_object(
+			            'cve', cve_id, 'epss', epss::text,
+			            'percentile', COALESCE(percentile::text, ''), 'date', as_of::text)
+			 FROM epss_daily
+			 WHERE inserted_at >= $1
+			   AND (inserted_at, 'epss', cve_id, as_of::text) > ($1, $2, $3, $4)
+			   AND inserted_at < clock_timestamp() - $5::interval
+			 ORDER BY inserted_at, cve_id, as_of
+			 LIMIT $6)
+			UNION ALL
+			(SELECT detected_at, 'kev', lpad(id::text, 19, '0'), '',
+			        jsonb_build_object(
+			            'id', id, 'cve_id', cve_id, 'change_type', change_type,
+			            'catalog_version', catalog_version, 'date_released', date_released,
+			            'changed_fields', changed_fields, 'detected_at', detected_at,
+			            'archived', archived, 'vuln', json)
+			 FROM kev_changes
+			 WHERE detected_at >= $1
+			   AND (detected_at, 'kev', lpad(id::text, 19, '0'), '') > ($1, $2, $3, $4)
+			   AND detected_at < clock_timestamp() - $5::interval
+			 ORDER BY detected_at, id
+			 LIMIT $6)
+		) feed
+		ORDER BY changed_at, kind, k1, k2
+		LIMIT $6
+	`, page.After.UpdatedAt, page.After.KeyPart(0), page.After.KeyPart(1), page.After.KeyPart(2),
+		page.Settle, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list changes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Change
+	for rows.Next() {
+		var c Change
+		var payload []byte
+		if err := rows.Scan(&c.ChangedAt, &c.Kind, &c.key[0], &c.key[1], &payload); err != nil {
+			return nil, fmt.Errorf("scan change: %w", err)
+		}
+		if err := c.decode(payload); err != nil {
+			return nil, fmt.Errorf("decode %s change %s: %w", c.Kind, c.key[0], err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// decode unmarshals payload into the field for c.Kind.
+func (c *Change) decode(payload []byte) error {
+	var v any
+	switch c.Kind {
+	case KindAdvisory:
+		c.Advisory = &ingestor.Advisory{}
+		v = c.Advisory
+	case KindCVE:
+		c.CVE = &cve.EnrichedRecord{}
+		v = c.CVE
+	case KindEPSS:
+		c.EPSS = &cve.EpssRow{}
+		v = c.EPSS
+	case KindKEV:
+		c.KEV = &cve.KevChange{}
+		v = c.KEV
+	default:
+		return fmt.Errorf("unknown change kind %q", c.Kind)
+	}
+	return json.Unmarshal(payload, v)
+}
@@ -0,0 +1,114 @@
+package mirror
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cursor"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+	"tiger2go/internal/provenance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListChanges_ApplyRoundTrip(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	const stateKey = "MIRROR:test"
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM current WHERE feed_url = 'https://mirror.test/feed'")
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = 'CVE-TEST-MIRROR-1'")
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id = 'CVE-TEST-MIRROR-1'")
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE cve_id = 'CVE-TEST-MIRROR-1'")
+		_, _ = pool.Exec(ctx, "DELETE FROM ingest_state WHERE source = $1", stateKey)
+	}
+	cleanup()
+	defer cleanup()
+
+	var start time.Time
+	require.NoError(t, pool.QueryRow(ctx, "SELECT clock_timestamp()").Scan(&start))
+	_, err = pool.Exec(ctx, `
+		INSERT INTO current (guid, title, link, published, feed_url)
+		VALUES ('mirror-1', 'Advisory', 'https://mirror.test/1', '2024-01-22 10:00:00', 'https://mirror.test/feed')`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified)
+		VALUES ('CVE-TEST-MIRROR-1', 'NVD', '{"id":"CVE-TEST-MIRROR-1"}', 9.1, '2024-01-20')`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO kev_changes (cve_id, change_type, catalog_version, json)
+		VALUES ('CVE-TEST-MIRROR-1', 'added', '2100.01.01', '{"cveID":"CVE-TEST-MIRROR-1"}')`)
+	require.NoError(t, err)
+	require.NoError(t, cve.EnsureEpssPartition(ctx, pool, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)))
+	_, err = pool.Exec(ctx, `
+		INSERT INTO epss_daily (as_of, cve_id, epss, percentile)
+		VALUES ('2100-01-01', 'CVE-TEST-MIRROR-1', 0.5, 0.9)`)
+	require.NoError(t, err)
+
+	// Page one change at a time to exercise the cursor across kinds.
+	after := cursor.Cursor{UpdatedAt: start, Key: []string{"", "", ""}}
+	var got []Change
+	for range 10 {
+		page, err := ListChanges(ctx, pool, cursor.Page{After: after, Limit: 1})
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, page[0])
+		after = page[0].Cursor()
+	}
+	kinds := map[string]Change{}
+	for _, c := range got {
+		kinds[c.Kind] = c
+	}
+	require.Contains(t, kinds, KindAdvisory)
+	require.Contains(t, kinds, KindCVE)
+	require.Contains(t, kinds, KindKEV)
+	require.Contains(t, kinds, KindEPSS)
+	assert.Equal(t, "mirror-1", kinds[KindAdvisory].Advisory.GUID)
+	assert.Equal(t, time.Date(2024, 1, 22, 10, 0, 0, 0, time.UTC), kinds[KindAdvisory].Advisory.Published.UTC())
+	assert.InDelta(t, 9.1, *kinds[KindCVE].CVE.CvssBase, 0.001)
+	assert.Equal(t, "CVE-TEST-MIRROR-1", kinds[KindKEV].KEV.Vuln.CveID)
+	assert.Equal(t, "2100-01-01", kinds[KindEPSS].EPSS.Date)
+
+	// Wipe the rows and rebuild them from the feed; a replay is a no-op.
+	cleanup()
+	prov := provenance.New("https://central.test/changes", 200, "")
+	for range 2 {
+		stats, err := Apply(ctx, pool, got, stateKey, after.String(), prov)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats[KindEPSS])
+	}
+
+	var n int
+	require.NoError(t, pool.QueryRow(ctx, `
+		SELECT (SELECT count(*) FROM current WHERE feed_url = 'https://mirror.test/feed')
+		     + (SELECT count(*) FROM cve_enriched WHERE cve_id = 'CVE-TEST-MIRROR-1')
+		     + (SELECT count(*) FROM kev_changes WHERE cve_id = 'CVE-TEST-MIRROR-1')
+		     + (SELECT count(*) FROM epss_daily WHERE cve_id = 'CVE-TEST-MIRROR-1')`).Scan(&n))
+	assert.Equal(t, 4, n)
+
+	var id, saved string
+	require.NoError(t, pool.QueryRow(ctx, "SELECT id::text FROM current WHERE guid = 'mirror-1'").Scan(&id))
+	assert.Equal(t, kinds[KindAdvisory].Advisory.ID, id, "advisory ids are kept")
+
+	var advSeen, cveSeen time.Time
+	require.NoError(t, pool.QueryRow(ctx, "SELECT first_seen_at FROM current WHERE guid = 'mirror-1'").Scan(&advSeen))
+	require.NoError(t, pool.QueryRow(ctx, "SELECT first_seen_at FROM cve_enriched WHERE cve_id = 'CVE-TEST-MIRROR-1'").Scan(&cveSeen))
+	assert.True(t, kinds[KindAdvisory].Advisory.FirstSeenAt.Equal(advSeen), "first-seen times are kept")
+	assert.True(t, kinds[KindCVE].CVE.FirstSeenAt.Equal(cveSeen))
+	require.NoError(t, pool.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", stateKey).Scan(&saved))
+	assert.Equal(t, after.String(), saved)
+}
@@ -0,0 +1,102 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/metrics"
+)
+
+// maxPageBytes bounds a /changes response. A full page of 1000 NVD records
+// is a few MB; the limit leaves room for large advisories.
+const maxPageBytes = 64 << 20
+
+// Client reads another instance's change feed.
+type Client struct {
+	base   string
+	apiKey string
+	http   *http.Client
+}
+
+// NewClient returns a client for the API at baseURL (e.g.
+// "https://central:9102"). apiKey is sent as a bearer token when set.
+func NewClient(baseURL, apiKey string, hc *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid sync URL %q: want http(s)://host[:port]", baseURL)
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{base: strings.TrimRight(baseURL, "/"), apiKey: apiKey, http: hc}, nil
+}
+
+// BaseURL returns the API base URL without a trailing slash.
+func (c *Client) BaseURL() string { return c.base }
+
+// PageURL is the request URL for the page after cursor.
+func (c *Client) PageURL(cursor string, limit int) string {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("since", cursor)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if len(q) == 0 {
+		return c.base + "/changes"
+	}
+	return c.base + "/changes?" + q.Encode()
+}
+
+// Changes fetches the page after cursor ("" for the start).
+func (c *Client) Changes(ctx context.Context, cursor string, limit int) (*ChangePage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.PageURL(cursor, limit), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "tigerfetch/1.0 (+https://tigerblue.app)")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	httpStart := time.Now()
+	resp, err := c.http.Do(req)
+	metrics.UpstreamRequestDuration.WithLabelValues("remote").Observe(time.Since(httpStart).Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("fetch changes: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := httpclient.ReadBody(resp, maxPageBytes)
+	if err != nil {
+		return nil, fmt.Errorf("read changes: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(body, &e)
+		if e.Error == "" {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			return nil, fmt.Errorf("changes: %s: %s (retry after %ss)", resp.Status, e.Error, ra)
+		}
+		return nil, fmt.Errorf("changes: %s: %s", resp.Status, e.Error)
+	}
+
+	var page ChangePage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("decode changes: %w", err)
+	}
+	return &page, nil
+}
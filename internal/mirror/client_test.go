@@ -0,0 +1,66 @@
+package mirror
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient_RejectsBadURLs(t *testing.T) {
+	for _, u := range []string{"", "central:9102", "ftp://central", "https://"} {
+		_, err := NewClient(u, "", nil)
+		assert.Error(t, err, u)
+	}
+	c, err := NewClient("https://central:9102/", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://central:9102", c.BaseURL())
+	assert.Equal(t, "https://central:9102/changes", c.PageURL("", 0))
+	assert.Equal(t, "https://central:9102/changes?limit=500&since=abc", c.PageURL("abc", 500))
+}
+
+func TestClient_Changes(t *testing.T) {
+	var gotAuth, gotSince string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotSince = r.Header.Get("Authorization"), r.URL.Query().Get("since")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"changes":[
+			{"kind":"cve","changed_at":"2024-01-22T10:00:00.123456Z","cve":{"cve_id":"CVE-2024-21887","source":"CISA-KEV","cvss_base":null,"modified":"2024-01-10T00:00:00Z","updated_at":"2024-01-22T10:00:00.123456Z","data":{"cveID":"CVE-2024-21887"}}},
+			{"kind":"epss","changed_at":"2024-01-22T10:00:01Z","epss":{"cve":"CVE-2024-21887","epss":"0.97","percentile":"0.99","date":"2024-01-22"}}
+		],"next_cursor":"next","has_more":true}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "s3cret", srv.Client())
+	require.NoError(t, err)
+	page, err := c.Changes(context.Background(), "prev", 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer s3cret", gotAuth)
+	assert.Equal(t, "prev", gotSince)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "next", page.NextCursor)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "CISA-KEV", page.Changes[0].CVE.Source)
+	assert.JSONEq(t, `{"cveID":"CVE-2024-21887"}`, string(page.Changes[0].CVE.Data))
+	assert.Equal(t, "0.97", page.Changes[1].EPSS.EPSS)
+}
+
+func TestClient_ChangesError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"daily quota exceeded"}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "", srv.Client())
+	require.NoError(t, err)
+	_, err = c.Changes(context.Background(), "", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "daily quota exceeded")
+	assert.Contains(t, err.Error(), "retry after 30s")
+}
@@ -0,0 +1,90 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/provenance"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Syncer pulls a remote instance's change feed into the local database.
+type Syncer struct {
+	db       *pgxpool.Pool
+	client   *Client
+	pageSize int
+}
+
+// NewSyncer returns a Syncer writing to db. pageSize 0 uses the server's
+// default page size.
+func NewSyncer(db *pgxpool.Pool, client *Client, pageSize int) *Syncer {
+	return &Syncer{db: db, client: client, pageSize: pageSize}
+}
+
+// StateKey is the ingest_state source holding the cursor for this remote,
+// so one database can mirror several instances.
+func (s *Syncer) StateKey() string { return "MIRROR:" + s.client.BaseURL() }
+
+// Run applies pages until the feed is exhausted and returns the number of
+// changes applied by kind. Each page is committed with its cursor, so Run
+// can be interrupted and resumed at any point.
+func (s *Syncer) Run(ctx context.Context) (ApplyStats, error) {
+	var cur string
+	err := s.db.QueryRow(ctx, "SELECT cursor FROM ingest_state WHERE source = $1", s.StateKey()).Scan(&cur)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("load sync cursor: %w", err)
+	}
+
+	total := ApplyStats{}
+	for {
+		page, err := s.client.Changes(ctx, cur, s.pageSize)
+		if err != nil {
+			return total, err
+		}
+		prov := provenance.New(s.client.PageURL(cur, s.pageSize), http.StatusOK, "")
+		stats, err := Apply(ctx, s.db, page.Changes, s.StateKey(), page.NextCursor, prov)
+		if err != nil {
+			return total, err
+		}
+		for k, n := range stats {
+			total[k] += n
+		}
+		slog.Debug("Applied change page", "remote", s.client.BaseURL(), "changes", len(page.Changes), "has_more", page.HasMore)
+
+		if !page.HasMore || page.NextCursor == cur {
+			return total, nil
+		}
+		cur = page.NextCursor
+	}
+}
+
+// Poll is one daemon sync of the [remote] instance: Run with its outcome
+// recorded in metrics and the log.
+func (s *Syncer) Poll(ctx context.Context) error {
+	start := time.Now()
+	stats, err := s.Run(ctx)
+	for kind, n := range stats {
+		metrics.RemoteChangesApplied.WithLabelValues(kind).Add(float64(n))
+	}
+	if err != nil {
+		metrics.RemoteRuns.WithLabelValues("error").Inc()
+		return fmt.Errorf("sync from %s: %w", s.client.BaseURL(), err)
+	}
+	metrics.RemoteRuns.WithLabelValues("success").Inc()
+	metrics.RemoteLastSuccess.SetToCurrentTime()
+	slog.Info("Remote sync complete",
+		"remote", s.client.BaseURL(),
+		"advisories", stats[KindAdvisory],
+		"cves", stats[KindCVE],
+		"kev_changes", stats[KindKEV],
+		"epss", stats[KindEPSS],
+		"duration", time.Since(start).Round(time.Millisecond))
+	return nil
+}
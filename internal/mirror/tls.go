@@ -0,0 +1,140 @@
+package mirror
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"tiger2go/internal/config"
+)
+
+// ErrPinMismatch is returned when no certificate in the server's verified
+// chain matches a configured pin.
+var ErrPinMismatch = errors.New("no certificate matches pin_sha256")
+
+// NewRemoteClient returns a Client for the [remote] instance, with the
+// federation link's TLS settings applied.
+func NewRemoteClient(cfg config.RemoteConfig) (*Client, error) {
+	hc, err := HTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cfg.URL, cfg.APIKey, hc)
+}
+
+// HTTPClient returns the http.Client for the federation link: the system
+// roots or ca_file, an optional client certificate, and certificate pinning
+// when pin_sha256 is set. Pins are checked after normal chain verification,
+// never instead of it; to pin a self-signed certificate, also name it in
+// ca_file.
+func HTTPClient(cfg config.RemoteConfig) (*http.Client, error) {
+	timeout, err := cfg.GetTimeout()
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid remote.timeout %q", cfg.Timeout)
+	}
+	tc, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport := baseTransport(http.DefaultTransport).Clone()
+	transport.TLSClientConfig = tc
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// baseTransport finds the *http.Transport under rt, which the daemon may
+// wrap to record or count requests, falling back to Go's defaults.
+func baseTransport(rt http.RoundTripper) *http.Transport {
+	for {
+		switch t := rt.(type) {
+		case *http.Transport:
+			return t
+		case interface{ Unwrap() http.RoundTripper }:
+			rt = t.Unwrap()
+		default:
+			return &http.Transport{Proxy: http.ProxyFromEnvironment, ForceAttemptHTTP2: true}
+		}
+	}
+}
+
+func tlsConfig(cfg config.RemoteConfig) (*tls.Config, error) {
+	// A session cache of its own: the default transport's is replaced
+	// with the rest of its TLS config
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: cfg.ServerName, ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+	secured := cfg.CAFile != "" || cfg.CertFile != "" || cfg.KeyFile != "" || len(cfg.PinSHA256) > 0
+	if u, err := url.Parse(cfg.URL); secured && (err != nil || u.Scheme != "https") {
+		return nil, fmt.Errorf("remote TLS settings need an https url, got %q", cfg.URL)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read remote.ca_file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("remote.ca_file %s holds no PEM certificates", cfg.CAFile)
+		}
+		tc.RootCAs = roots
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("remote.cert_file and remote.key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load remote client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(cfg.PinSHA256) > 0 {
+		pins, err := parsePins(cfg.PinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		tc.VerifyConnection = verifyPins(pins)
+	}
+	return tc, nil
+}
+
+// parsePins decodes base64 SHA-256 pins, accepting the "sha256/" prefix
+// used by HPKP and curl's --pinnedpubkey.
+func parsePins(raw []string) ([][]byte, error) {
+	pins := make([][]byte, 0, len(raw))
+	for _, p := range raw {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(p), "sha256/"))
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid remote.pin_sha256 %q: want base64 of a SHA-256 digest", p)
+		}
+		pins = append(pins, b)
+	}
+	return pins, nil
+}
+
+// verifyPins accepts a connection when any certificate in a verified chain
+// has a pinned public key. Pinning an intermediate or root survives leaf
+// renewals; pinning the leaf key survives renewals that reuse the key.
+func verifyPins(pins [][]byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, pin := range pins {
+					if subtle.ConstantTimeCompare(sum[:], pin) == 1 {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("%w for %s", ErrPinMismatch, cs.ServerName)
+	}
+}
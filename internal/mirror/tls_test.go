@@ -0,0 +1,95 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pinServer(t *testing.T) (*httptest.Server, string, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"changes":[],"next_cursor":"","has_more":false}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "central.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, block, 0o600))
+
+	sum := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	return srv, caFile, base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestRemoteClient_Pinning(t *testing.T) {
+	srv, caFile, pin := pinServer(t)
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr error
+	}{
+		{"no pins", nil, nil},
+		{"matching pin", []string{pin}, nil},
+		{"matching pin with prefix", []string{other, "sha256/" + pin}, nil},
+		{"wrong pin", []string{other}, ErrPinMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRemoteClient(config.RemoteConfig{URL: srv.URL, CAFile: caFile, PinSHA256: tt.pins})
+			require.NoError(t, err)
+			_, err = c.Changes(context.Background(), "", 0)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRemoteClient_UntrustedServer(t *testing.T) {
+	srv, _, pin := pinServer(t)
+	c, err := NewRemoteClient(config.RemoteConfig{URL: srv.URL, PinSHA256: []string{pin}})
+	require.NoError(t, err)
+	_, err = c.Changes(context.Background(), "", 0)
+	assert.Error(t, err, "a pin does not replace chain verification")
+}
+
+func TestRemoteClient_InvalidConfig(t *testing.T) {
+	_, caFile, pin := pinServer(t)
+	for name, cfg := range map[string]config.RemoteConfig{
+		"pins over http":   {URL: "http://central:9102", PinSHA256: []string{pin}},
+		"short pin":        {URL: "https://central:9102", PinSHA256: []string{"AAAA"}},
+		"missing ca file":  {URL: "https://central:9102", CAFile: caFile + ".missing"},
+		"cert without key": {URL: "https://central:9102", CertFile: caFile},
+		"bad timeout":      {URL: "https://central:9102", Timeout: "soon"},
+	} {
+		_, err := NewRemoteClient(cfg)
+		assert.Error(t, err, name)
+	}
+}
+
+type wrapped struct{ next http.RoundTripper }
+
+func (w wrapped) RoundTrip(req *http.Request) (*http.Response, error) { return w.next.RoundTrip(req) }
+func (w wrapped) Unwrap() http.RoundTripper                           { return w.next }
+
+func TestBaseTransport(t *testing.T) {
+	base := http.DefaultTransport.(*http.Transport)
+	assert.Same(t, base, baseTransport(base))
+	assert.Same(t, base, baseTransport(wrapped{wrapped{base}}), "unwraps recording and budget transports")
+	assert.NotNil(t, baseTransport(http.NewFileTransport(http.Dir("."))))
+}
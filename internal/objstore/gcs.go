@@ -0,0 +1,104 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// gcsAPI is the Cloud Storage JSON API; tests point it elsewhere.
+var gcsAPI = "https://storage.googleapis.com"
+
+// gcsObject is an object in Google Cloud Storage.
+type gcsObject struct {
+	bucket, key string
+	http        *http.Client
+	token       func(ctx context.Context, hc *http.Client) (string, error)
+}
+
+func (o *gcsObject) String() string { return "gs://" + o.bucket + "/" + o.key }
+
+func (o *gcsObject) Get(ctx context.Context) ([]byte, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", gcsAPI, url.PathEscape(o.bucket), url.PathEscape(o.key))
+	req, err := o.request(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(o.http, req)
+}
+
+func (o *gcsObject) Put(ctx context.Context, data []byte) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", gcsAPI, url.PathEscape(o.bucket), url.QueryEscape(o.key))
+	req, err := o.request(ctx, http.MethodPost, u, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = do(o.http, req)
+	return err
+}
+
+func (o *gcsObject) Delete(ctx context.Context) error {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gcsAPI, url.PathEscape(o.bucket), url.PathEscape(o.key))
+	req, err := o.request(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := do(o.http, req); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (o *gcsObject) request(ctx context.Context, method, u string, body []byte) (*http.Request, error) {
+	token, err := o.token(ctx, o.http)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// metadataToken is the access token of the service account the function
+// runs as. Outside Google Cloud, GOOGLE_OAUTH_ACCESS_TOKEN (e.g. from
+// `gcloud auth print-access-token`) is used instead.
+func metadataToken(ctx context.Context, hc *http.Client) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := do(hc, req)
+	if err != nil {
+		return "", fmt.Errorf("gs state needs the metadata server or GOOGLE_OAUTH_ACCESS_TOKEN: %w", err)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil || tok.AccessToken == "" {
+		return "", errors.New("metadata server returned no access token")
+	}
+	return tok.AccessToken, nil
+}
@@ -0,0 +1,140 @@
+// Package objstore reads and writes single objects in object storage, for
+// state that must outlive a serverless invocation and for archived upstream
+// payloads. It speaks just enough of each API to get, put and delete one
+// object: local files, S3 and S3-compatible
+// stores (SigV4 with credentials from the environment, as Lambda provides
+// them), and Google Cloud Storage (a token from the metadata server, as on
+// Cloud Functions and Cloud Run).
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tiger2go/internal/httpclient"
+)
+
+// ErrNotExist is returned by Get for a missing object.
+var ErrNotExist = errors.New("object does not exist")
+
+// maxObjectBytes bounds an object read; state documents are small.
+const maxObjectBytes = 16 << 20
+
+// Object is one object in a store.
+type Object interface {
+	// Get returns the object's content, or ErrNotExist.
+	Get(ctx context.Context) ([]byte, error)
+	// Put replaces the object's content.
+	Put(ctx context.Context, data []byte) error
+	// Delete removes the object; a missing one is not an error.
+	Delete(ctx context.Context) error
+	// String is the object's URL.
+	String() string
+}
+
+// Options configure Open.
+type Options struct {
+	Endpoint string       // S3-compatible endpoint, e.g. "https://storage.googleapis.com" or a MinIO URL; default AWS
+	Region   string       // S3 region; default $AWS_REGION, then us-east-1
+	Client   *http.Client // default a client with a one-minute timeout
+}
+
+// Open returns the object at rawURL: file:///path, s3://bucket/key or
+// gs://bucket/key.
+func Open(rawURL string, opts Options) (Object, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object URL %q: %w", rawURL, err)
+	}
+	hc := opts.Client
+	if hc == nil {
+		hc = &http.Client{Timeout: time.Minute}
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid object URL %q: want file:///path", rawURL)
+		}
+		return fileObject(u.Path), nil
+	case "s3":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid object URL %q: want s3://bucket/key", rawURL)
+		}
+		return newS3Object(u.Host, key, opts, hc)
+	case "gs":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid object URL %q: want gs://bucket/key", rawURL)
+		}
+		return &gcsObject{bucket: u.Host, key: key, http: hc, token: metadataToken}, nil
+	default:
+		return nil, fmt.Errorf("invalid object URL %q: scheme must be file, s3 or gs", rawURL)
+	}
+}
+
+type fileObject string
+
+func (f fileObject) String() string { return "file://" + string(f) }
+
+func (f fileObject) Get(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return data, err
+}
+
+// Put writes through a temporary file, so a crash never leaves half a
+// document behind.
+func (f fileObject) Put(_ context.Context, data []byte) error {
+	dir := filepath.Dir(string(f))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".objstore-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
+
+func (f fileObject) Delete(context.Context) error {
+	if err := os.Remove(string(f)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// do sends req and returns the body of a 2xx response. 404 is ErrNotExist.
+func do(hc *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound && (req.Method == http.MethodGet || req.Method == http.MethodDelete) {
+		return nil, ErrNotExist
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(msg))
+	}
+	return httpclient.ReadBody(resp, maxObjectBytes)
+}
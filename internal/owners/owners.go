@@ -0,0 +1,181 @@
+// Package owners maps advisories to the teams that own the affected
+// products ([[owners]]), so alerting can route each one to its team's
+// channel and tracker. An advisory is matched by its KEV vendor and product,
+// the CPEs NVD lists for it and the [[nvd.products]] monitors that matched
+// it.
+package owners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"tiger2go/internal/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Owner is a team an advisory belongs to.
+type Owner struct {
+	Team        string   `json:"team"`
+	JiraProject string   `json:"jira_project,omitempty"`
+	Webhooks    []string `json:"-"`
+}
+
+// Affected is what an advisory is matched on. Any field may be empty.
+type Affected struct {
+	Vendor   string   // KEV vendorProject
+	Product  string   // KEV product
+	CPEs     []string // NVD CPE match criteria
+	Monitors []string // names of the [[nvd.products]] entries that matched
+}
+
+type rule struct {
+	owner    Owner
+	products []string
+	cpes     []string
+	monitors []string
+}
+
+// Map assigns owners. A nil Map owns nothing.
+type Map struct {
+	rules  []rule
+	routed map[string]bool // webhooks some owner routes to
+}
+
+// New validates cfg and returns its Map, nil when cfg is empty.
+func New(cfg []config.OwnerConfig) (*Map, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	m := &Map{routed: map[string]bool{}}
+	for _, c := range cfg {
+		if c.Team == "" {
+			return nil, errors.New("owner without a team")
+		}
+		if len(c.Products)+len(c.CPEs)+len(c.Monitors) == 0 {
+			return nil, fmt.Errorf("owner %q matches nothing: set products, cpes or monitors", c.Team)
+		}
+		r := rule{
+			owner:    Owner{Team: c.Team, JiraProject: c.JiraProject, Webhooks: c.Webhooks},
+			products: lower(c.Products),
+			cpes:     lower(c.CPEs),
+			monitors: lower(c.Monitors),
+		}
+		for _, p := range append(slices.Clone(r.products), r.cpes...) {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("owner %q: invalid pattern %q", c.Team, p)
+			}
+		}
+		for _, wh := range c.Webhooks {
+			m.routed[wh] = true
+		}
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Routed reports whether some owner routes to webhook. A routed webhook
+// only receives the advisories of the teams routing to it; the others
+// receive everything.
+func (m *Map) Routed(webhook string) bool {
+	return m != nil && m.routed[webhook]
+}
+
+// Match returns the owners of an advisory, in configuration order.
+func (m *Map) Match(a Affected) []Owner {
+	if m == nil {
+		return nil
+	}
+	var names []string
+	if a.Vendor != "" || a.Product != "" {
+		names = append(names, normalize(a.Vendor)+":"+normalize(a.Product))
+	}
+	cpes := lower(a.CPEs)
+	for _, cpe := range cpes {
+		// cpe:2.3:part:vendor:product:...
+		if f := strings.Split(cpe, ":"); len(f) > 4 {
+			names = append(names, f[3]+":"+f[4])
+		}
+	}
+	monitors := lower(a.Monitors)
+
+	var out []Owner
+	for _, r := range m.rules {
+		if matchAny(r.products, names) || matchAny(r.cpes, cpes) ||
+			slices.ContainsFunc(r.monitors, func(p string) bool { return slices.Contains(monitors, p) }) {
+			out = append(out, r.owner)
+		}
+	}
+	return out
+}
+
+// For reports whether owners route to webhook.
+func For(owners []Owner, webhook string) bool {
+	return slices.ContainsFunc(owners, func(o Owner) bool { return slices.Contains(o.Webhooks, webhook) })
+}
+
+// Teams lists the owners' team names.
+func Teams(owners []Owner) []string {
+	out := make([]string, len(owners))
+	for i, o := range owners {
+		out[i] = o.Team
+	}
+	return out
+}
+
+// CPEs reads the CPE match criteria NVD lists for each of ids.
+func CPEs(ctx context.Context, db *pgxpool.Pool, ids []string) (map[string][]string, error) {
+	out := map[string][]string{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, array_agg(DISTINCT m #>> '{}')
+		FROM cve_enriched,
+		     jsonb_path_query(json, '$.configurations[*].nodes[*].cpeMatch[*].criteria') AS m
+		WHERE source = 'NVD' AND cve_id = ANY($1)
+		GROUP BY cve_id
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("read CPEs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var cpes []string
+		if err := rows.Scan(&id, &cpes); err != nil {
+			return nil, err
+		}
+		out[id] = cpes
+	}
+	return out, rows.Err()
+}
+
+func matchAny(patterns, values []string) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if ok, _ := path.Match(p, v); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize spells a KEV vendor or product like a CPE field: "IOS XE
+// Software" is "ios_xe_software".
+func normalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), " ", "_")
+}
+
+func lower(items []string) []string {
+	out := make([]string, len(items))
+	for i, s := range items {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
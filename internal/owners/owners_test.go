@@ -0,0 +1,64 @@
+package owners
+
+import (
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMap(t *testing.T) *Map {
+	t.Helper()
+	m, err := New([]config.OwnerConfig{
+		{Team: "network", Products: []string{"cisco:ios*", "fortinet:*"}, Webhooks: []string{"slack-network"}, JiraProject: "NET"},
+		{Team: "devtools", CPEs: []string{"cpe:2.3:a:gitlab:*"}, Webhooks: []string{"slack-devtools"}},
+		{Team: "edge", Monitors: []string{"NetScaler"}},
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestMatch(t *testing.T) {
+	m := testMap(t)
+
+	kev := m.Match(Affected{Vendor: "Cisco", Product: "IOS XE Software"})
+	assert.Equal(t, []string{"network"}, Teams(kev), "KEV names are normalized like CPE fields")
+	assert.Equal(t, "NET", kev[0].JiraProject)
+
+	cpe := m.Match(Affected{CPEs: []string{"cpe:2.3:a:gitlab:gitlab:*:*:*:*:community:*:*:*", "cpe:2.3:o:fortinet:fortios:7.4.0:*:*:*:*:*:*:*"}})
+	assert.Equal(t, []string{"network", "devtools"}, Teams(cpe), "products also match CPE vendor:product")
+
+	assert.Equal(t, []string{"edge"}, Teams(m.Match(Affected{Monitors: []string{"netscaler"}})))
+	assert.Empty(t, m.Match(Affected{Vendor: "Microsoft", Product: "Exchange Server"}))
+}
+
+func TestRouting(t *testing.T) {
+	m := testMap(t)
+	assert.True(t, m.Routed("slack-network"))
+	assert.False(t, m.Routed("soc"))
+
+	owned := m.Match(Affected{Vendor: "Fortinet", Product: "FortiOS"})
+	assert.True(t, For(owned, "slack-network"))
+	assert.False(t, For(owned, "slack-devtools"))
+}
+
+func TestNil(t *testing.T) {
+	m, err := New(nil)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Nil(t, m.Match(Affected{Vendor: "Cisco", Product: "IOS"}))
+	assert.False(t, m.Routed("soc"))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []config.OwnerConfig{
+		{Products: []string{"cisco:*"}},
+		{Team: "empty"},
+		{Team: "bad", Products: []string{"cisco:[ios"}},
+	} {
+		_, err := New([]config.OwnerConfig{cfg})
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
@@ -0,0 +1,222 @@
+// Package pdf writes simple PDF 1.4 documents: A4 pages of text in the
+// standard Helvetica fonts, lines and filled rectangles, which is all the
+// executive report's tables and bar charts need. The standard fonts are
+// built into every PDF reader, so nothing is embedded and text is limited
+// to the Windows-1252 character set; other characters print as "?".
+//
+// Coordinates are in points from the top-left corner of the page; text is
+// placed by its baseline.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is an RGB colour.
+type Color struct{ R, G, B uint8 }
+
+// Common colours.
+var (
+	Black = Color{0, 0, 0}
+	Gray  = Color{110, 110, 110}
+	White = Color{255, 255, 255}
+)
+
+// Doc is a document being written. Drawing goes to the last added page.
+type Doc struct {
+	title string
+	pages []*bytes.Buffer
+}
+
+// New starts a document with title in its metadata.
+func New(title string) *Doc {
+	return &Doc{title: title}
+}
+
+// AddPage starts a new page.
+func (d *Doc) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// Pages is the number of pages so far.
+func (d *Doc) Pages() int { return len(d.pages) }
+
+func (d *Doc) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text writes s with its baseline at y, in Helvetica (or Helvetica-Bold)
+// of size points.
+func (d *Doc) Text(x, y, size float64, bold bool, c Color, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s rg %s %s Td (%s) Tj ET\n",
+		font, num(size), c.op(), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// TextRight writes s ending at x.
+func (d *Doc) TextRight(x, y, size float64, bold bool, c Color, s string) {
+	d.Text(x-TextWidth(s, size, bold), y, size, bold, c, s)
+}
+
+// TextCenter writes s centred on x.
+func (d *Doc) TextCenter(x, y, size float64, bold bool, c Color, s string) {
+	d.Text(x-TextWidth(s, size, bold)/2, y, size, bold, c, s)
+}
+
+// Rect fills the rectangle with top-left corner x, y.
+func (d *Doc) Rect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(d.page(), "%s rg %s %s %s %s re f\n", c.op(), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Line draws a line width points wide.
+func (d *Doc) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(d.page(), "%s RG %s w %s %s m %s %s l S\n",
+		c.op(), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Bytes renders the document.
+func (d *Doc) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-5 are fixed; each page is a page object and its content.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = strconv.Itoa(6+2*i) + " 0 R"
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (tigerfetch) >>", escape(encode(d.title))))
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func (c Color) op() string {
+	return num(float64(c.R)/255) + " " + num(float64(c.G)/255) + " " + num(float64(c.B)/255)
+}
+
+// num formats a coordinate with at most two decimals.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// encode converts s to Windows-1252, the encoding of the fonts.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			b = '?'
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// escape writes b as the contents of a PDF string literal, keeping the
+// content stream ASCII.
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 32 || c > 126:
+			fmt.Fprintf(&sb, "\\%03o", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// TextWidth is the width of s in points.
+func TextWidth(s string, size float64, bold bool) float64 {
+	widths := &helvetica
+	if bold {
+		widths = &helveticaBold
+	}
+	var units int
+	for _, c := range encode(s) {
+		if c >= 32 && c <= 126 {
+			units += widths[c-32]
+		} else {
+			units += 556 // accented letters and symbols are about as wide as a digit
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// Fit shortens s with "..." to fit width points.
+func Fit(s string, size, width float64, bold bool) string {
+	if TextWidth(s, size, bold) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && TextWidth(string(r)+"...", size, bold) > width {
+		r = r[:len(r)-1]
+	}
+	return strings.TrimRight(string(r), " ") + "..."
+}
+
+// Glyph widths of ASCII 32-126 in thousandths of the font size, from the
+// Adobe font metrics of the standard fonts.
+var (
+	helvetica = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBold = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
@@ -0,0 +1,57 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytes_CrossReference(t *testing.T) {
+	d := New("Weekly (draft)")
+	d.Text(10, 20, 12, false, Black, "Page one")
+	d.AddPage()
+	d.Rect(10, 10, 50, 20, Gray)
+	d.Line(0, 0, 100, 100, 1, Black)
+	out := d.Bytes()
+
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Count 2")
+	assert.Contains(t, string(out), "/Title (Weekly \\(draft\\))")
+
+	// startxref points at the xref table, whose entries point at the objects.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n0 10\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.Len(t, entries, 9)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(out[off:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+	}
+}
+
+func TestText_Encoding(t *testing.T) {
+	d := New("")
+	d.Text(0, 0, 10, true, White, `Größe (a\b) – 日本`)
+	page := d.pages[0].String()
+	assert.Contains(t, page, "/F2 10 Tf 1 1 1 rg")
+	assert.Contains(t, page, `(Gr\366\337e \(a\\b\) \226 ??) Tj`)
+}
+
+func TestTextWidthAndFit(t *testing.T) {
+	assert.InDelta(t, 5.56, TextWidth("0", 10, false), 0.001)
+	assert.InDelta(t, 6.11, TextWidth("b", 10, true), 0.001)
+	assert.Greater(t, TextWidth("Hello", 10, true), TextWidth("Hello", 10, false))
+
+	assert.Equal(t, "short", Fit("short", 10, 100, false))
+	s := Fit("Microsoft Windows Server Message Block", 10, 80, false)
+	assert.Equal(t, "Microsoft Wind...", s)
+	assert.LessOrEqual(t, TextWidth(s, 10, false), 80.0)
+	assert.Equal(t, "...", Fit("abc", 10, 1, false))
+}
@@ -0,0 +1,57 @@
+// Package provenance describes where and when a stored record came from, so
+// any datum can be traced back to the upstream response that produced it.
+package provenance
+
+import "time"
+
+var toolVersion = "dev"
+
+// SetToolVersion records the running build, e.g. "1.3.0+a1b2c3d". main calls
+// it once at startup with the ldflags-injected version and commit.
+func SetToolVersion(version, commit string) {
+	toolVersion = version
+	if commit != "" && commit != "none" {
+		toolVersion += "+" + commit
+	}
+}
+
+// ToolVersion returns the version recorded by SetToolVersion.
+func ToolVersion() string { return toolVersion }
+
+// Record is the provenance stored alongside a record.
+type Record struct {
+	FetchURL        string    `json:"fetch_url"`
+	FetchedAt       time.Time `json:"fetched_at"`
+	HTTPStatus      int       `json:"http_status,omitempty"`      // 0 when not fetched over HTTP (e.g. a fake fetcher)
+	UpstreamVersion string    `json:"upstream_version,omitempty"` // API version, catalogVersion or feed format
+	ToolVersion     string    `json:"tool_version"`
+}
+
+// New returns a Record for a response fetched now.
+func New(fetchURL string, httpStatus int, upstreamVersion string) Record {
+	return Record{
+		FetchURL:        fetchURL,
+		FetchedAt:       time.Now().UTC(),
+		HTTPStatus:      httpStatus,
+		UpstreamVersion: upstreamVersion,
+		ToolVersion:     toolVersion,
+	}
+}
+
+// Status returns the HTTP status for storage, nil when unknown.
+func (r Record) Status() *int {
+	if r.HTTPStatus == 0 {
+		return nil
+	}
+	s := r.HTTPStatus
+	return &s
+}
+
+// Version returns the upstream version for storage, nil when unknown.
+func (r Record) Version() *string {
+	if r.UpstreamVersion == "" {
+		return nil
+	}
+	v := r.UpstreamVersion
+	return &v
+}
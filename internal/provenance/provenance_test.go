@@ -0,0 +1,36 @@
+package provenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetToolVersion(t *testing.T) {
+	defer SetToolVersion("dev", "")
+
+	SetToolVersion("1.3.0", "a1b2c3d")
+	assert.Equal(t, "1.3.0+a1b2c3d", ToolVersion())
+
+	SetToolVersion("dev", "none")
+	assert.Equal(t, "dev", ToolVersion())
+}
+
+func TestNew(t *testing.T) {
+	SetToolVersion("1.3.0", "")
+	defer SetToolVersion("dev", "")
+
+	before := time.Now().UTC()
+	r := New("https://example.com/kev.json", 200, "2024.01.15")
+	assert.Equal(t, "https://example.com/kev.json", r.FetchURL)
+	assert.Equal(t, "1.3.0", r.ToolVersion)
+	assert.False(t, r.FetchedAt.Before(before))
+	assert.Equal(t, time.UTC, r.FetchedAt.Location())
+	assert.Equal(t, 200, *r.Status())
+	assert.Equal(t, "2024.01.15", *r.Version())
+
+	empty := New("u", 0, "")
+	assert.Nil(t, empty.Status())
+	assert.Nil(t, empty.Version())
+}
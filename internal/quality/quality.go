@@ -0,0 +1,218 @@
+// Package quality checks ingested records against plausibility rules before
+// they are stored: CVSS scores within 0–10, EPSS scores and percentiles
+// within 0–1, dates not in the future beyond a tolerance, and mandatory
+// fields present. A value breaking a rule never reaches scoring: an
+// out-of-range score is stored as NULL (or, for EPSS, the row is left out)
+// and a record without its ID is skipped. Every violation is logged,
+// counted, and written to data_quality_violations, and the counts of a run
+// go into its summary.
+package quality
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Rules a value can break.
+const (
+	RuleRequired = "required"    // a mandatory field is empty
+	RuleRange    = "range"       // a score outside its scale
+	RuleNumber   = "number"      // a score that is not a number
+	RuleFuture   = "future_date" // a date later than now plus the tolerance
+)
+
+// DefaultFutureTolerance is the [quality] future_tolerance default.
+const DefaultFutureTolerance = 48 * time.Hour
+
+// pruneEvery spaces the deletes of expired violations.
+const pruneEvery = time.Hour
+
+// Violation is one value that broke a rule.
+type Violation struct {
+	Source string // NVD, EPSS, CISA-KEV or the feed name
+	Record string // CVE ID or feed item GUID; "" for the payload as a whole
+	Field  string // upstream field name, e.g. "baseScore" or "dateAdded"
+	Rule   string
+	Value  string
+}
+
+// Recorder stores violations and counts them per source and rule. A nil
+// *Recorder still checks, with the default tolerance, but stores nothing,
+// so components can take one unconditionally.
+type Recorder struct {
+	db        *pgxpool.Pool
+	tolerance time.Duration
+	retention time.Duration
+
+	mu        sync.Mutex
+	counts    map[string]map[string]int // source -> rule -> violations
+	lastPrune time.Time
+}
+
+// NewRecorder writes violations to db; tolerance is how far in the future
+// a date may lie (clock skew, time zones) and retention how long stored
+// violations are kept.
+func NewRecorder(db *pgxpool.Pool, tolerance, retention time.Duration) *Recorder {
+	return &Recorder{db: db, tolerance: tolerance, retention: retention, counts: map[string]map[string]int{}}
+}
+
+// FromConfig returns a Recorder writing to db with the [quality] settings.
+func FromConfig(db *pgxpool.Pool, cfg config.QualityConfig) (*Recorder, error) {
+	tolerance, err := cfg.GetFutureTolerance()
+	if err != nil || tolerance < 0 {
+		return nil, fmt.Errorf("quality.future_tolerance %q must be a positive duration", cfg.FutureTolerance)
+	}
+	retention, err := cfg.GetRetention()
+	if err != nil || retention <= 0 {
+		return nil, fmt.Errorf("quality.retention %q must be a positive duration", cfg.Retention)
+	}
+	return NewRecorder(db, tolerance, retention), nil
+}
+
+// Checker starts checking records of source.
+func (r *Recorder) Checker(source string) *Checker {
+	tolerance := DefaultFutureTolerance
+	if r != nil {
+		tolerance = r.tolerance
+	}
+	return &Checker{rec: r, source: source, now: time.Now(), tolerance: tolerance}
+}
+
+// Counts returns the violations recorded so far per source and rule.
+func (r *Recorder) Counts() map[string]map[string]int {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]map[string]int, len(r.counts))
+	for source, rules := range r.counts {
+		out[source] = maps.Clone(rules)
+	}
+	return out
+}
+
+// record counts vs, which share a source, and stores them. One warning
+// summarizes them (a broken upstream page can hold thousands); each is
+// logged at debug level. Storing is best effort: a failure is logged and
+// never fails the ingestion that found them.
+func (r *Recorder) record(ctx context.Context, vs []Violation) {
+	byRule := map[string]int{}
+	for _, v := range vs {
+		slog.Debug("Data quality violation", "source", v.Source, "record", v.Record, "field", v.Field, "rule", v.Rule, "value", v.Value)
+		metrics.DataQualityViolations.WithLabelValues(v.Source, v.Rule).Inc()
+		byRule[v.Field+" "+v.Rule]++
+	}
+	slog.Warn("Data quality violations", "source", vs[0].Source, "count", len(vs), "by_field", byRule,
+		"first_record", vs[0].Record)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	for _, v := range vs {
+		if r.counts[v.Source] == nil {
+			r.counts[v.Source] = map[string]int{}
+		}
+		r.counts[v.Source][v.Rule]++
+	}
+	prune := r.retention > 0 && time.Since(r.lastPrune) >= pruneEvery
+	if prune {
+		r.lastPrune = time.Now()
+	}
+	r.mu.Unlock()
+
+	if r.db == nil {
+		return
+	}
+	rows := make([][]any, len(vs))
+	for i, v := range vs {
+		rows[i] = []any{v.Source, v.Record, v.Field, v.Rule, v.Value}
+	}
+	if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"data_quality_violations"},
+		[]string{"source", "record_id", "field", "rule", "value"}, pgx.CopyFromRows(rows)); err != nil {
+		slog.Warn("Storing data quality violations failed", "count", len(vs), "error", err)
+	}
+	if prune {
+		if _, err := r.db.Exec(ctx, "DELETE FROM data_quality_violations WHERE detected_at < $1", time.Now().Add(-r.retention)); err != nil {
+			slog.Warn("Pruning data quality violations failed", "error", err)
+		}
+	}
+}
+
+// Checker collects the violations of one batch of a source's records,
+// e.g. an NVD page or a KEV catalog. Each check reports whether the value
+// passed, for the caller to drop the ones that did not.
+type Checker struct {
+	rec       *Recorder
+	source    string
+	now       time.Time
+	tolerance time.Duration
+	found     []Violation
+}
+
+func (c *Checker) add(record, field, rule, value string) {
+	c.found = append(c.found, Violation{Source: c.source, Record: record, Field: field, Rule: rule, Value: value})
+}
+
+// Required checks that a mandatory field is set.
+func (c *Checker) Required(record, field, value string) bool {
+	if value == "" {
+		c.add(record, field, RuleRequired, "")
+		return false
+	}
+	return true
+}
+
+// Range checks that score v lies within [lo, hi].
+func (c *Checker) Range(record, field string, v, lo, hi float64) bool {
+	if v < lo || v > hi || math.IsNaN(v) {
+		c.add(record, field, RuleRange, strconv.FormatFloat(v, 'g', -1, 64))
+		return false
+	}
+	return true
+}
+
+// Number checks that raw, a score sent as text, is a number within
+// [lo, hi].
+func (c *Checker) Number(record, field, raw string, lo, hi float64) bool {
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		c.add(record, field, RuleNumber, raw)
+		return false
+	}
+	return c.Range(record, field, v, lo, hi)
+}
+
+// Date checks that t is not later than now plus the tolerance. A zero t
+// (no date) passes.
+func (c *Checker) Date(record, field string, t time.Time) bool {
+	if !t.IsZero() && t.After(c.now.Add(c.tolerance)) {
+		c.add(record, field, RuleFuture, t.UTC().Format(time.RFC3339))
+		return false
+	}
+	return true
+}
+
+// Violations returns what the checks found so far.
+func (c *Checker) Violations() []Violation { return c.found }
+
+// Flush records the violations found since the last flush.
+func (c *Checker) Flush(ctx context.Context) {
+	if len(c.found) == 0 {
+		return
+	}
+	c.rec.record(ctx, c.found)
+	c.found = nil
+}
@@ -0,0 +1,56 @@
+package quality
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	chk := NewRecorder(nil, time.Hour, 0).Checker("NVD")
+
+	assert.True(t, chk.Required("CVE-1", "id", "CVE-1"))
+	assert.False(t, chk.Required("", "id", ""))
+	assert.True(t, chk.Range("CVE-1", "baseScore", 10, 0, 10))
+	assert.False(t, chk.Range("CVE-1", "baseScore", 10.1, 0, 10))
+	assert.False(t, chk.Range("CVE-1", "baseScore", math.NaN(), 0, 10))
+	assert.True(t, chk.Number("CVE-1", "epss", "0.5", 0, 1))
+	assert.False(t, chk.Number("CVE-1", "epss", "", 0, 1))
+	assert.True(t, chk.Date("CVE-1", "published", time.Now().Add(59*time.Minute)), "within the tolerance")
+	assert.True(t, chk.Date("CVE-1", "published", time.Time{}), "no date")
+	assert.False(t, chk.Date("CVE-1", "published", time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	assert.Equal(t, []Violation{
+		{Source: "NVD", Field: "id", Rule: RuleRequired},
+		{Source: "NVD", Record: "CVE-1", Field: "baseScore", Rule: RuleRange, Value: "10.1"},
+		{Source: "NVD", Record: "CVE-1", Field: "baseScore", Rule: RuleRange, Value: "NaN"},
+		{Source: "NVD", Record: "CVE-1", Field: "epss", Rule: RuleNumber},
+		{Source: "NVD", Record: "CVE-1", Field: "published", Rule: RuleFuture, Value: "2099-01-01T00:00:00Z"},
+	}, chk.Violations())
+}
+
+func TestRecorder_Counts(t *testing.T) {
+	rec := NewRecorder(nil, time.Hour, 0)
+	nvd, kev := rec.Checker("NVD"), rec.Checker("CISA-KEV")
+	nvd.Range("CVE-1", "baseScore", 11, 0, 10)
+	nvd.Range("CVE-2", "baseScore", -1, 0, 10)
+	kev.Required("", "cveID", "")
+	nvd.Flush(context.Background())
+	kev.Flush(context.Background())
+	nvd.Flush(context.Background())
+
+	counts := rec.Counts()
+	assert.Equal(t, map[string]map[string]int{"NVD": {RuleRange: 2}, "CISA-KEV": {RuleRequired: 1}}, counts)
+	assert.Empty(t, nvd.Violations(), "flushed")
+	counts["NVD"][RuleRange] = 0
+	assert.Equal(t, 2, rec.Counts()["NVD"][RuleRange], "a copy")
+
+	var none *Recorder
+	chk := none.Checker("EPSS")
+	assert.False(t, chk.Number("CVE-1", "epss", "2", 0, 1))
+	chk.Flush(context.Background())
+	assert.Nil(t, none.Counts())
+}
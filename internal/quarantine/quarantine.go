@@ -0,0 +1,187 @@
+// Package quarantine keeps upstream payloads that failed to parse — an NVD
+// page, the KEV catalog, an EPSS page or a feed — together with the parse
+// error, so format drift can be debugged from the exact bytes upstream sent
+// and the payload re-processed with `tigerfetch replay` once the parser is
+// fixed. A payload that keeps failing is stored once, with a count of the
+// times it was seen.
+package quarantine
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sources of quarantined payloads, named like their pipeline steps.
+const (
+	NVD     = "nvd"
+	KEV     = "kev"
+	EPSS    = "epss"
+	Feeds   = "feeds"
+	Inbound = "inbound" // pushed by webhook; the name is the inbound source
+)
+
+// pruneEvery spaces the deletes of expired payloads.
+const pruneEvery = time.Hour
+
+// ErrNotFound is returned for an unknown entry ID.
+var ErrNotFound = errors.New("no such quarantined payload")
+
+// ParseError is a payload that was fetched but did not parse. Fetch paths
+// without their own Store return it, for the caller to quarantine.
+type ParseError struct {
+	Payload []byte
+	Err     error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Entry is one quarantined payload.
+type Entry struct {
+	ID         int64      `json:"id"`
+	Source     string     `json:"source"`         // nvd, kev, epss or feeds
+	Name       string     `json:"name,omitempty"` // the feed name
+	URL        string     `json:"url"`
+	Error      string     `json:"error"` // the parse error, or that of the last replay
+	Size       int        `json:"size"`
+	Attempts   int        `json:"attempts"` // fetches that returned this payload
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `json:"last_seen"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+	Payload    []byte     `json:"-"`
+}
+
+// Store keeps payloads in the quarantine table. A nil *Store keeps
+// nothing, so components can take one unconditionally.
+type Store struct {
+	db        *pgxpool.Pool
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// New stores payloads in db and deletes them retention after they were
+// last seen; zero keeps them.
+func New(db *pgxpool.Pool, retention time.Duration) *Store {
+	return &Store{db: db, retention: retention}
+}
+
+// FromConfig returns the Store of the [quarantine] settings, nil when
+// disabled.
+func FromConfig(db *pgxpool.Pool, cfg config.QuarantineConfig) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	retention, err := cfg.GetRetention()
+	if err != nil || retention <= 0 {
+		return nil, fmt.Errorf("quarantine.retention %q must be a positive duration", cfg.Retention)
+	}
+	return New(db, retention), nil
+}
+
+// Put quarantines payload, fetched from url, which failed to parse with
+// parseErr. name is the feed name for Feeds. Quarantining is best effort:
+// a failure is logged, and the fetch fails with parseErr either way.
+func (s *Store) Put(ctx context.Context, source, name, url string, payload []byte, parseErr error) {
+	metrics.QuarantinedPayloads.WithLabelValues(source).Inc()
+	if s == nil {
+		return
+	}
+	sum := sha256.Sum256(payload)
+	var id int64
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO quarantine (source, name, url, payload, sha256, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (source, sha256) DO UPDATE SET
+			attempts = quarantine.attempts + 1,
+			last_seen_at = now(),
+			url = EXCLUDED.url,
+			error = EXCLUDED.error,
+			replayed_at = NULL
+		RETURNING id
+	`, source, name, url, payload, sum[:], parseErr.Error()).Scan(&id)
+	if err != nil {
+		slog.Warn("Quarantining unparsable payload failed", "source", source, "url", url, "error", err)
+		return
+	}
+	slog.Warn("Quarantined unparsable payload", "id", id, "source", source, "name", name, "url", url,
+		"bytes", len(payload), "error", parseErr)
+
+	s.mu.Lock()
+	prune := s.retention > 0 && time.Since(s.lastPrune) >= pruneEvery
+	if prune {
+		s.lastPrune = time.Now()
+	}
+	s.mu.Unlock()
+	if prune {
+		if _, err := s.db.Exec(ctx, "DELETE FROM quarantine WHERE last_seen_at < $1", time.Now().Add(-s.retention)); err != nil {
+			slog.Warn("Pruning quarantined payloads failed", "error", err)
+		}
+	}
+}
+
+// ListOptions filter List.
+type ListOptions struct {
+	Source  string // "" for all
+	Pending bool   // only payloads not replayed successfully
+	Limit   int    // 0 for all
+}
+
+// List returns quarantined payloads, most recently seen first, without
+// their bytes.
+func List(ctx context.Context, db *pgxpool.Pool, opts ListOptions) ([]Entry, error) {
+	q := `SELECT id, source, name, url, error, length(payload), attempts, first_seen_at, last_seen_at, replayed_at
+		FROM quarantine
+		WHERE ($1 = '' OR source = $1) AND (NOT $2 OR replayed_at IS NULL)
+		ORDER BY last_seen_at DESC, id DESC`
+	args := []any{opts.Source, opts.Pending}
+	if opts.Limit > 0 {
+		q += ` LIMIT $3`
+		args = append(args, opts.Limit)
+	}
+	rows, err := db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list quarantine: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Entry, error) {
+		var e Entry
+		err := row.Scan(&e.ID, &e.Source, &e.Name, &e.URL, &e.Error, &e.Size, &e.Attempts, &e.FirstSeen, &e.LastSeen, &e.ReplayedAt)
+		return e, err
+	})
+}
+
+// Get returns entry id with its payload.
+func Get(ctx context.Context, db *pgxpool.Pool, id int64) (Entry, error) {
+	e := Entry{ID: id}
+	err := db.QueryRow(ctx, `
+		SELECT source, name, url, error, length(payload), attempts, first_seen_at, last_seen_at, replayed_at, payload
+		FROM quarantine WHERE id = $1
+	`, id).Scan(&e.Source, &e.Name, &e.URL, &e.Error, &e.Size, &e.Attempts, &e.FirstSeen, &e.LastSeen, &e.ReplayedAt, &e.Payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return e, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return e, err
+}
+
+// Replayed records the outcome of replaying entry id: a nil err marks it
+// replayed, otherwise err replaces its error and it stays pending.
+func Replayed(ctx context.Context, db *pgxpool.Pool, id int64, err error) error {
+	if err != nil {
+		_, execErr := db.Exec(ctx, "UPDATE quarantine SET error = $2 WHERE id = $1", id, err.Error())
+		return execErr
+	}
+	_, execErr := db.Exec(ctx, "UPDATE quarantine SET replayed_at = now() WHERE id = $1", id)
+	return execErr
+}
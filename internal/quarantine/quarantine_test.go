@@ -0,0 +1,40 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseError(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := error(&ParseError{Payload: []byte("{"), Err: cause})
+	assert.Equal(t, "unexpected EOF", err.Error())
+	assert.ErrorIs(t, err, cause)
+}
+
+func TestFromConfig(t *testing.T) {
+	s, err := FromConfig(nil, config.QuarantineConfig{Enabled: false})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = FromConfig(nil, config.QuarantineConfig{Enabled: true})
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "720h0m0s", s.retention.String())
+
+	_, err = FromConfig(nil, config.QuarantineConfig{Enabled: true, Retention: "soon"})
+	assert.Error(t, err)
+}
+
+func TestStore_NilKeepsNothing(t *testing.T) {
+	var s *Store
+	assert.NotPanics(t, func() {
+		s.Put(context.Background(), NVD, "", "https://example.test/page", []byte("{"), errors.New("unexpected EOF"))
+	})
+}
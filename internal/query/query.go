@@ -0,0 +1,347 @@
+// Package query selects stored advisories with filter expressions. Each
+// advisory is enriched with the NVD, KEV and EPSS data of the CVEs it
+// mentions, so an expression can combine feed and vulnerability fields:
+//
+//	kev == true && epss > 0.5 && source in ["CISA", "MSRC"]
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/config"
+	"tiger2go/internal/cveid"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/filter"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/ioc"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Fields is the schema expressions are compiled against. Keys match the
+// JSON names of Advisory, except that iocs holds indicator values and
+// ioc_types their distinct types.
+var Fields = map[string]filter.Type{
+	"source":          filter.String,
+	"feed_type":       filter.String,
+	"feed_url":        filter.String,
+	"tags":            filter.StringList,
+	"title":           filter.String,
+	"link":            filter.String,
+	"author":          filter.String,
+	"summary":         filter.String,
+	"categories":      filter.StringList,
+	"published":       filter.Time,
+	"first_seen_at":   filter.Time,
+	"cves":            filter.StringList,
+	"kev":             filter.Bool,
+	"epss":            filter.Number,
+	"percentile":      filter.Number,
+	"cvss":            filter.Number,
+	"severity":        filter.String,
+	"exploit_ref":     filter.Bool,
+	"patches":         filter.StringList,
+	"products":        filter.StringList,
+	"iocs":            filter.StringList,
+	"ioc_types":       filter.StringList,
+	"actors":          filter.StringList,
+	"ransomware":      filter.Bool,
+	"detections":      filter.StringList,
+	"fixed_in":        filter.StringList,
+	"patch_available": filter.Bool,
+	"eol":             filter.StringList,
+	"unscored":        filter.StringList,
+}
+
+// Advisory is one stored advisory with the highest-risk data of its CVEs.
+type Advisory struct {
+	ID             string            `json:"id"`     // stable advisory ID, as in GET /advisories
+	GUID           string            `json:"guid"`   // the feed's GUID for the item, else its link
+	Source         string            `json:"source"` // feed name from config, else the feed's own title
+	FeedType       string            `json:"feed_type"`
+	FeedURL        string            `json:"feed_url"`
+	Tags           []string          `json:"tags"`
+	Title          string            `json:"title"`
+	Link           string            `json:"link"`
+	Author         string            `json:"author"`
+	Summary        string            `json:"summary"`
+	Categories     []string          `json:"categories"`
+	Published      *time.Time        `json:"published"`
+	FirstSeenAt    time.Time         `json:"first_seen_at"` // when tigerfetch first stored it
+	CVEs           []string          `json:"cves"`
+	KEV            bool              `json:"kev"`             // any CVE is in KEV
+	EPSS           float64           `json:"epss"`            // highest EPSS score
+	Percentile     float64           `json:"percentile"`      // highest EPSS percentile
+	CVSS           float64           `json:"cvss"`            // highest CVSS base score
+	Severity       string            `json:"severity"`        // NVD severity of the highest-scoring CVE
+	ExploitRef     bool              `json:"exploit_ref"`     // any CVE has an NVD reference tagged Exploit
+	Patches        []string          `json:"patches"`         // NVD patch links of its CVEs
+	Products       []string          `json:"products"`        // KEV vendor and product of its CVEs, e.g. "Ivanti Connect Secure"
+	IOCs           []ioc.Indicator   `json:"iocs"`            // indicators in its title, summary and content
+	Actors         []string          `json:"actors"`          // threat actors and ransomware families it names
+	Ransomware     bool              `json:"ransomware"`      // names a ransomware family, or KEV knows ransomware use of a CVE
+	Detections     []detections.Rule `json:"detections"`      // Sigma, Nuclei and ET Open rules for its CVEs
+	FixedIn        []fixes.FixedIn   `json:"fixed_in"`        // fixed versions and updates it or NVD names
+	PatchAvailable bool              `json:"patch_available"` // any FixedIn, or an NVD patch link for a CVE
+	EOL            []eol.Affected    `json:"eol"`             // end-of-life product releases its CVEs affect
+	Unscored       []string          `json:"unscored"`        // its CVEs with no EPSS score or NVD record yet, to judge manually
+}
+
+// Record is the view of a that expressions evaluate.
+func (a Advisory) Record() filter.Record {
+	r := filter.Record{
+		"source":          a.Source,
+		"feed_type":       a.FeedType,
+		"feed_url":        a.FeedURL,
+		"tags":            a.Tags,
+		"title":           a.Title,
+		"link":            a.Link,
+		"author":          a.Author,
+		"summary":         a.Summary,
+		"categories":      a.Categories,
+		"first_seen_at":   a.FirstSeenAt,
+		"cves":            a.CVEs,
+		"kev":             a.KEV,
+		"epss":            a.EPSS,
+		"percentile":      a.Percentile,
+		"cvss":            a.CVSS,
+		"severity":        a.Severity,
+		"exploit_ref":     a.ExploitRef,
+		"patches":         a.Patches,
+		"products":        a.Products,
+		"iocs":            ioc.Values(a.IOCs),
+		"ioc_types":       ioc.TypesOf(a.IOCs),
+		"actors":          a.Actors,
+		"ransomware":      a.Ransomware,
+		"detections":      detections.SourcesOf(a.Detections),
+		"fixed_in":        fixes.Labels(a.FixedIn),
+		"patch_available": a.PatchAvailable,
+		"eol":             eol.Labels(a.EOL),
+		"unscored":        a.Unscored,
+	}
+	if a.Published != nil {
+		r["published"] = *a.Published
+	}
+	return r
+}
+
+// DefaultBatchSize is the number of advisories Each loads, enriches and
+// hands on at a time when no batch size is given.
+const DefaultBatchSize = 500
+
+// Options bounds a query.
+type Options struct {
+	Window    window.Window // only advisories published or first seen in this range
+	Limit     int           // stop after this many matches; 0 for no limit
+	Actors    *actors.Dictionary
+	BatchSize int // advisories enriched and matched at a time; 0 for DefaultBatchSize
+}
+
+// errLimit ends Each once a query has its matches.
+var errLimit = errors.New("query limit reached")
+
+// Run returns the stored advisories matching prog, newest first. feeds maps
+// feed URLs to their configured name, type and tags. Advisories are
+// matched a batch at a time, so only the matches are held for the whole
+// query, and loading stops at the limit.
+func Run(ctx context.Context, db *pgxpool.Pool, e *enrich.Enricher, feeds []config.Feed, prog *filter.Program, opts Options) ([]Advisory, error) {
+	var out []Advisory
+	err := Each(ctx, db, e, feeds, opts.Actors, opts.Window, opts.BatchSize, func(advs []Advisory) error {
+		for _, a := range advs {
+			ok, err := prog.Match(a.Record())
+			if err != nil {
+				return fmt.Errorf("evaluate %q for %s: %w", prog, a.Link, err)
+			}
+			if !ok {
+				continue
+			}
+			out = append(out, a)
+			if opts.Limit > 0 && len(out) == opts.Limit {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Load returns every stored advisory in w, newest first, enriched with the
+// data of the CVEs it mentions and tagged with the actors of dict it names.
+// Advisories withdrawn from their feed are left out, so queries, IOC
+// exports and the summary stop reporting them.
+// A nil e skips enrichment, for callers that only need the CVE IDs.
+func Load(ctx context.Context, db *pgxpool.Pool, e *enrich.Enricher, feeds []config.Feed, dict *actors.Dictionary, w window.Window) ([]Advisory, error) {
+	var out []Advisory
+	err := Each(ctx, db, e, feeds, dict, w, 0, func(advs []Advisory) error {
+		out = append(out, advs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Each streams the advisories Load returns to fn, batchSize at a time (0
+// for DefaultBatchSize), each batch enriched on its own. A batch is not
+// used after fn returns, so a caller keeping only part of each holds only
+// that part: the advisory contents and the enrichment of the CVEs of the
+// batches before are released. The scan holds a connection of db while fn
+// runs; an error from fn stops it and is returned.
+func Each(ctx context.Context, db *pgxpool.Pool, e *enrich.Enricher, feeds []config.Feed, dict *actors.Dictionary, w window.Window, batchSize int, fn func([]Advisory) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	flush := func(advs []Advisory) error {
+		if len(advs) == 0 {
+			return nil
+		}
+		if e != nil {
+			if err := enrichAll(ctx, e, advs); err != nil {
+				return err
+			}
+		}
+		return fn(advs)
+	}
+
+	byURL := make(map[string]config.Feed, len(feeds))
+	for _, f := range feeds {
+		byURL[f.URL] = f
+	}
+
+	since, until := w.Bounds()
+	rows, err := db.Query(ctx, `
+		SELECT id::text, guid, title, link, published, first_seen_at, COALESCE(content, ''), COALESCE(summary, ''),
+		       COALESCE(author, ''), COALESCE(categories, '{}'), feed_url, COALESCE(feed_title, '')
+		FROM current
+		WHERE withdrawn_at IS NULL
+		  AND ($1::timestamptz IS NULL OR `+w.Column()+` >= $1)
+		  AND ($2::timestamptz IS NULL OR `+w.Column()+` < $2)
+		ORDER BY `+w.Column()+` DESC, id
+	`, since, until)
+	if err != nil {
+		return fmt.Errorf("query advisories: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]Advisory, 0, batchSize)
+	for rows.Next() {
+		var a Advisory
+		var content, feedTitle string
+		if err := rows.Scan(&a.ID, &a.GUID, &a.Title, &a.Link, &a.Published, &a.FirstSeenAt, &content, &a.Summary,
+			&a.Author, &a.Categories, &a.FeedURL, &feedTitle); err != nil {
+			return fmt.Errorf("scan advisory: %w", err)
+		}
+		a.Source = feedTitle
+		if f, ok := byURL[a.FeedURL]; ok {
+			a.Source, a.FeedType, a.Tags = f.Name, f.FeedType, f.Tags
+		}
+		refs := append([]string{a.Link}, cveid.Links(content)...)
+		refs = append(refs, cveid.Links(a.Summary)...)
+		a.CVEs = cveid.Merge(cveid.Extract(a.Title, a.Summary, content), cveid.FromURLs(refs...)...)
+		a.IOCs = ioc.Extract(a.Title, a.Summary, content)
+		a.FixedIn = fixes.FromText(a.Title, a.Summary, content)
+		a.PatchAvailable = len(a.FixedIn) > 0
+		named := dict.Match(a.Title, a.Summary, content)
+		a.Actors, a.Ransomware = actors.Names(named), actors.AnyRansomware(named)
+		batch = append(batch, a)
+		if len(batch) == batchSize {
+			if err := flush(batch); err != nil {
+				return err
+			}
+			batch = make([]Advisory, 0, batchSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query advisories: %w", err)
+	}
+	return flush(batch)
+}
+
+// enrichAll looks up every CVE advs mention once, in batches of the
+// enricher's limit, and folds the results into each advisory.
+func enrichAll(ctx context.Context, e *enrich.Enricher, advs []Advisory) error {
+	var ids []string
+	seen := map[string]bool{}
+	for _, a := range advs {
+		for _, id := range a.CVEs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	byID := make(map[string]enrich.Result, len(ids))
+	for batch := range slices.Chunk(ids, e.MaxIDs()) {
+		results, err := e.Enrich(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("enrich advisories: %w", err)
+		}
+		for _, r := range results {
+			byID[r.CveID] = r
+		}
+	}
+
+	for i := range advs {
+		for _, id := range advs[i].CVEs {
+			if r, ok := byID[id]; ok {
+				advs[i].merge(r)
+			}
+		}
+	}
+	return nil
+}
+
+// merge keeps the riskiest value of each field across an advisory's CVEs.
+func (a *Advisory) merge(r enrich.Result) {
+	a.KEV = a.KEV || r.InKEV
+	if r.EPSS == nil || r.NVD == nil {
+		a.Unscored = append(a.Unscored, r.CveID)
+	}
+	if r.KEV != nil {
+		a.Ransomware = a.Ransomware || r.KEV.Ransomware
+		p := strings.TrimSpace(r.KEV.VendorProject + " " + r.KEV.Product)
+		if p != "" && !slices.Contains(a.Products, p) {
+			a.Products = append(a.Products, p)
+		}
+	}
+	if r.EPSS != nil {
+		a.EPSS = max(a.EPSS, r.EPSS.Score)
+		a.Percentile = max(a.Percentile, r.EPSS.Percentile)
+	}
+	if r.NVD != nil {
+		a.ExploitRef = a.ExploitRef || r.NVD.ExploitRef
+		if r.NVD.PatchURL != "" && !slices.Contains(a.Patches, r.NVD.PatchURL) {
+			a.Patches = append(a.Patches, r.NVD.PatchURL)
+		}
+		a.FixedIn = fixes.Add(a.FixedIn, r.NVD.FixedIn...)
+		a.PatchAvailable = a.PatchAvailable || r.NVD.PatchAvailable
+	}
+	for _, x := range r.EOL {
+		if !slices.Contains(a.EOL, x) {
+			a.EOL = append(a.EOL, x)
+		}
+	}
+	for _, d := range r.Detections {
+		if !slices.ContainsFunc(a.Detections, func(x detections.Rule) bool { return x.Source == d.Source && x.ID == d.ID }) {
+			a.Detections = append(a.Detections, d)
+		}
+	}
+	if r.NVD != nil && r.NVD.CvssBase != nil && *r.NVD.CvssBase >= a.CVSS {
+		a.CVSS = *r.NVD.CvssBase
+		a.Severity = r.NVD.Severity
+	}
+}
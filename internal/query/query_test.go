@@ -0,0 +1,142 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/db"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/filter"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/ioc"
+	"tiger2go/internal/kev"
+	"tiger2go/internal/window"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge_KeepsRiskiestValues(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	var a Advisory
+	a.merge(enrich.Result{CveID: "CVE-2024-0001", NVD: &enrich.NVD{CvssBase: f(9.8), Severity: "CRITICAL",
+		ExploitRef: true, PatchURL: "https://example.com/fix", PatchAvailable: true,
+		FixedIn: []fixes.FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}}},
+		EPSS: &enrich.EPSS{Score: 0.2, Percentile: 0.9}})
+	a.merge(enrich.Result{CveID: "CVE-2024-0002", InKEV: true, KEV: &kev.Entry{VendorProject: "Ivanti", Product: "Connect Secure", Ransomware: true}, NVD: &enrich.NVD{CvssBase: f(5.0), Severity: "MEDIUM"},
+		EPSS:       &enrich.EPSS{Score: 0.7, Percentile: 0.8},
+		Detections: []detections.Rule{{Source: detections.SourceNuclei, ID: "CVE-2024-0002"}, {Source: detections.SourceETOpen, ID: "2049876"}}})
+	a.merge(enrich.Result{CveID: "CVE-2024-0003", EOL: []eol.Affected{{Product: "Ivanti Connect Secure", Release: "9.1"}}, NVD: &enrich.NVD{FixedIn: []fixes.FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}}},
+		Detections: []detections.Rule{{Source: detections.SourceETOpen, ID: "2049876"}}})
+
+	assert.True(t, a.KEV)
+	assert.True(t, a.Ransomware)
+	assert.Len(t, a.Detections, 2, "a signature covering two CVEs is listed once")
+	assert.Equal(t, 0.7, a.EPSS)
+	assert.Equal(t, 0.9, a.Percentile)
+	assert.Equal(t, 9.8, a.CVSS)
+	assert.Equal(t, "CRITICAL", a.Severity)
+	assert.True(t, a.ExploitRef)
+	assert.Equal(t, []string{"https://example.com/fix"}, a.Patches)
+	assert.Equal(t, []string{"Ivanti Connect Secure"}, a.Products)
+	assert.Equal(t, []fixes.FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}}, a.FixedIn)
+	assert.True(t, a.PatchAvailable)
+	assert.Equal(t, []string{"Ivanti Connect Secure 9.1"}, eol.Labels(a.EOL))
+}
+
+func TestRecord_MatchesFields(t *testing.T) {
+	published := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	a := Advisory{
+		Source: "CISA", FeedType: "cert", Tags: []string{"gov"}, Title: "Ivanti Connect Secure",
+		Published: &published, CVEs: []string{"CVE-2024-21887"}, KEV: true, EPSS: 0.97, CVSS: 9.1, Severity: "CRITICAL",
+		IOCs:   []ioc.Indicator{{Type: ioc.TypeIPv4, Value: "45.77.12.9"}, {Type: ioc.TypeSHA256, Value: "e3b0"}},
+		Actors: []string{"LockBit", "UNC5221"}, Ransomware: true,
+		Detections: []detections.Rule{{Source: detections.SourceSigma, ID: "5a1b2c3d"}},
+		FixedIn:    []fixes.FixedIn{{Version: "22.7R2.3"}, {Ref: "KB5034441"}}, PatchAvailable: true,
+		EOL:      []eol.Affected{{Product: "Ivanti Connect Secure", Release: "9.1"}},
+		Unscored: []string{"CVE-2024-22024"},
+	}
+	for key := range a.Record() {
+		assert.Contains(t, Fields, key)
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`kev == true && epss > 0.5 && source in ["CISA", "MSRC"]`, true},
+		{`"CVE-2024-21887" in cves && severity == "CRITICAL"`, true},
+		{`published >= timestamp("2024-01-01") && "gov" in tags`, true},
+		{`title.contains("Fortinet") || cvss < 7`, false},
+		{`size(categories) == 0 && author == ""`, true},
+		{`"45.77.12.9" in iocs && "sha256" in ioc_types`, true},
+		{`ransomware == true && "UNC5221" in actors`, true},
+		{`"sigma" in detections && !("nuclei" in detections)`, true},
+		{`patch_available == true && "KB5034441" in fixed_in`, true},
+		{`size(eol) > 0 && "Ivanti Connect Secure 9.1" in eol`, true},
+		{`size(unscored) > 0 && !("CVE-2024-21887" in unscored)`, true},
+	}
+	for _, tt := range tests {
+		prog, err := filter.Compile(tt.expr, Fields)
+		require.NoError(t, err, tt.expr)
+		got, err := prog.Match(a.Record())
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestRecord_UndatedAdvisory(t *testing.T) {
+	prog, err := filter.Compile(`published < timestamp("1971-01-01")`, Fields)
+	require.NoError(t, err)
+	got, err := prog.Match(Advisory{}.Record())
+	require.NoError(t, err)
+	assert.True(t, got, "a missing published date is the zero time")
+}
+
+func TestEach_Batches(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	cleanup := func() { _, _ = pool.Exec(ctx, "DELETE FROM current WHERE feed_url = 'https://query.test/feed'") }
+	cleanup()
+	defer cleanup()
+	t0 := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		_, err := pool.Exec(ctx, `
+			INSERT INTO current (guid, title, link, summary, feed_url, first_seen_at)
+			VALUES ($1, $2, $3, '', 'https://query.test/feed', $4)`,
+			fmt.Sprintf("query-%d", i), fmt.Sprintf("CVE-1999-99000%d", i), fmt.Sprintf("https://query.test/%d", i), t0.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+	}
+	w := window.Window{Field: window.FirstSeen, Since: t0, Until: t0.Add(24 * time.Hour)}
+
+	var sizes []int
+	require.NoError(t, Each(ctx, pool, nil, nil, nil, w, 2, func(advs []Advisory) error {
+		sizes = append(sizes, len(advs))
+		return nil
+	}))
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+
+	prog, err := filter.Compile(`"CVE-1999-990003" in cves || "CVE-1999-990001" in cves`, Fields)
+	require.NoError(t, err)
+	advs, err := Run(ctx, pool, nil, nil, prog, Options{Window: w, BatchSize: 2})
+	require.NoError(t, err)
+	require.Len(t, advs, 2)
+	assert.Equal(t, []string{"CVE-1999-990003"}, advs[0].CVEs, "newest first")
+
+	advs, err = Run(ctx, pool, nil, nil, prog, Options{Window: w, BatchSize: 2, Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, advs, 1)
+}
@@ -0,0 +1,275 @@
+// Package rawarchive keeps a gzip copy of every upstream payload — NVD and
+// EPSS pages, KEV catalogs, feed bodies — in the database or in object
+// storage, so the derived tables can be rebuilt after a schema or parser
+// change with `tigerfetch archive rebuild` instead of refetching from
+// rate-limited upstreams. A payload fetched again unchanged is stored once;
+// its last fetch time moves and retention counts from there.
+package rawarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+	"tiger2go/internal/objstore"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sources of archived payloads, named like those of internal/quarantine so
+// both are re-processed by the same code.
+const (
+	NVD     = "nvd"
+	KEV     = "kev"
+	EPSS    = "epss"
+	Feeds   = "feeds"
+	Inbound = "inbound" // pushed by webhook; the name is the inbound source
+)
+
+// pruneEvery spaces the deletes of expired payloads.
+const pruneEvery = time.Hour
+
+// ErrNotFound is returned for an unknown entry ID.
+var ErrNotFound = errors.New("no such archived payload")
+
+// Entry is one archived payload.
+type Entry struct {
+	ID           int64     `json:"id"`
+	Source       string    `json:"source"` // nvd, kev, epss or feeds
+	URL          string    `json:"url"`
+	Size         int       `json:"size"`                 // uncompressed bytes
+	ObjectURL    string    `json:"object_url,omitempty"` // "" when stored in the database
+	FirstFetched time.Time `json:"first_fetched"`
+	LastFetched  time.Time `json:"last_fetched"`
+	Payload      []byte    `json:"-"` // uncompressed, set by Get
+}
+
+// Archive stores payloads. A nil *Archive stores nothing, so components
+// can take one unconditionally.
+type Archive struct {
+	db        *pgxpool.Pool
+	base      string // object storage prefix; "" stores payloads in raw_payloads
+	opts      objstore.Options
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// New stores payloads in db, or under the object storage prefix base when
+// set, and deletes those not fetched again for retention; zero keeps them.
+func New(db *pgxpool.Pool, base string, opts objstore.Options, retention time.Duration) *Archive {
+	return &Archive{db: db, base: strings.TrimSuffix(base, "/"), opts: opts, retention: retention}
+}
+
+// FromConfig returns the Archive of the [raw_archive] settings, nil when
+// disabled.
+func FromConfig(db *pgxpool.Pool, cfg config.RawArchiveConfig) (*Archive, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	return Open(db, cfg)
+}
+
+// Open returns the Archive of the [raw_archive] settings whether or not
+// archiving is enabled, for reading what was archived.
+func Open(db *pgxpool.Pool, cfg config.RawArchiveConfig) (*Archive, error) {
+	retention, err := cfg.GetRetention()
+	if err != nil || retention < 0 {
+		return nil, fmt.Errorf("raw_archive.retention %q must be a positive duration", cfg.Retention)
+	}
+	opts := objstore.Options{Endpoint: cfg.Endpoint, Region: cfg.Region}
+	if cfg.URL != "" {
+		// Fail on a bad URL or missing credentials now rather than on
+		// every fetch
+		if _, err := objstore.Open(strings.TrimSuffix(cfg.URL, "/")+"/probe", opts); err != nil {
+			return nil, fmt.Errorf("raw_archive.url: %w", err)
+		}
+	}
+	return New(db, cfg.URL, opts, retention), nil
+}
+
+// Put archives payload, fetched from url. Archiving is best effort: a
+// failure is logged and counted, and never fails the fetch.
+func (a *Archive) Put(ctx context.Context, source, url string, payload []byte) {
+	if a == nil {
+		return
+	}
+	result, err := a.put(ctx, source, url, payload)
+	if err != nil {
+		slog.Warn("Archiving upstream payload failed", "source", source, "url", url, "error", err)
+		result = "error"
+	}
+	metrics.ArchivedPayloads.WithLabelValues(source, result).Inc()
+	a.prune(ctx)
+}
+
+func (a *Archive) put(ctx context.Context, source, url string, payload []byte) (string, error) {
+	sum := sha256.Sum256(payload)
+	tag, err := a.db.Exec(ctx, `
+		UPDATE raw_payloads SET last_fetched_at = now() WHERE source = $1 AND sha256 = $2
+	`, source, sum[:])
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() > 0 {
+		return "unchanged", nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	inline, objectURL := buf.Bytes(), ""
+	if a.base != "" {
+		obj, err := objstore.Open(objectKey(a.base, source, time.Now(), sum), a.opts)
+		if err != nil {
+			return "", err
+		}
+		if err := obj.Put(ctx, inline); err != nil {
+			return "", err
+		}
+		inline, objectURL = nil, obj.String()
+	}
+	_, err = a.db.Exec(ctx, `
+		INSERT INTO raw_payloads (source, url, sha256, size, payload, object_url)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		ON CONFLICT (source, sha256) DO UPDATE SET last_fetched_at = now()
+	`, source, url, sum[:], len(payload), inline, objectURL)
+	if err != nil {
+		return "", err
+	}
+	return "stored", nil
+}
+
+// objectKey spreads objects by source and day, so bucket lifecycle rules
+// and listings can work on prefixes.
+func objectKey(base, source string, at time.Time, sum [sha256.Size]byte) string {
+	return fmt.Sprintf("%s/%s/%s/%x.gz", base, source, at.UTC().Format("2006/01/02"), sum)
+}
+
+// prune deletes payloads not fetched for the retention, at most hourly.
+func (a *Archive) prune(ctx context.Context) {
+	a.mu.Lock()
+	due := a.retention > 0 && time.Since(a.lastPrune) >= pruneEvery
+	if due {
+		a.lastPrune = time.Now()
+	}
+	a.mu.Unlock()
+	if !due {
+		return
+	}
+	rows, err := a.db.Query(ctx, `
+		DELETE FROM raw_payloads WHERE last_fetched_at < $1 RETURNING COALESCE(object_url, '')
+	`, time.Now().Add(-a.retention))
+	if err != nil {
+		slog.Warn("Pruning archived payloads failed", "error", err)
+		return
+	}
+	objects, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		slog.Warn("Pruning archived payloads failed", "error", err)
+		return
+	}
+	for _, u := range objects {
+		if u == "" {
+			continue
+		}
+		obj, err := objstore.Open(u, a.opts)
+		if err == nil {
+			err = obj.Delete(ctx)
+		}
+		if err != nil {
+			slog.Warn("Deleting archived payload object failed", "object", u, "error", err)
+		}
+	}
+}
+
+// ListOptions filter List.
+type ListOptions struct {
+	Source string    // "" for all
+	Since  time.Time // only payloads first fetched at or after this; zero for all
+	Limit  int       // 0 for all
+	Newest bool      // most recently first fetched first
+}
+
+// List returns archived payloads in the order they were first fetched,
+// without their bytes.
+func (a *Archive) List(ctx context.Context, opts ListOptions) ([]Entry, error) {
+	q := `SELECT id, source, url, size, COALESCE(object_url, ''), first_fetched_at, last_fetched_at
+		FROM raw_payloads
+		WHERE ($1 = '' OR source = $1) AND first_fetched_at >= $2`
+	if opts.Newest {
+		q += ` ORDER BY first_fetched_at DESC, id DESC`
+	} else {
+		q += ` ORDER BY first_fetched_at, id`
+	}
+	args := []any{opts.Source, opts.Since}
+	if opts.Limit > 0 {
+		q += ` LIMIT $3`
+		args = append(args, opts.Limit)
+	}
+	rows, err := a.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list raw archive: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Entry, error) {
+		var e Entry
+		err := row.Scan(&e.ID, &e.Source, &e.URL, &e.Size, &e.ObjectURL, &e.FirstFetched, &e.LastFetched)
+		return e, err
+	})
+}
+
+// Get returns entry id with its payload, uncompressed.
+func (a *Archive) Get(ctx context.Context, id int64) (Entry, error) {
+	e := Entry{ID: id}
+	var stored []byte
+	err := a.db.QueryRow(ctx, `
+		SELECT source, url, size, COALESCE(object_url, ''), first_fetched_at, last_fetched_at, payload
+		FROM raw_payloads WHERE id = $1
+	`, id).Scan(&e.Source, &e.URL, &e.Size, &e.ObjectURL, &e.FirstFetched, &e.LastFetched, &stored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return e, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return e, err
+	}
+	if e.ObjectURL != "" {
+		obj, err := objstore.Open(e.ObjectURL, a.opts)
+		if err != nil {
+			return e, err
+		}
+		if stored, err = obj.Get(ctx); err != nil {
+			return e, fmt.Errorf("archived payload %d: %w", id, err)
+		}
+	}
+	e.Payload, err = gunzip(stored)
+	if err != nil {
+		return e, fmt.Errorf("archived payload %d: %w", id, err)
+	}
+	return e, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zr.Close() }()
+	return io.ReadAll(zr)
+}
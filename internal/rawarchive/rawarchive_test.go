@@ -0,0 +1,62 @@
+package rawarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromConfig(t *testing.T) {
+	a, err := FromConfig(nil, config.RawArchiveConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	a, err = FromConfig(nil, config.RawArchiveConfig{Enabled: true, URL: "file:///var/lib/tigerfetch/archive/", Retention: "8760h"})
+	require.NoError(t, err)
+	assert.Equal(t, "file:///var/lib/tigerfetch/archive", a.base)
+	assert.Equal(t, 8760*time.Hour, a.retention)
+
+	_, err = FromConfig(nil, config.RawArchiveConfig{Enabled: true, URL: "ftp://host/archive"})
+	assert.ErrorContains(t, err, "raw_archive.url")
+	_, err = FromConfig(nil, config.RawArchiveConfig{Enabled: true, Retention: "forever"})
+	assert.ErrorContains(t, err, "raw_archive.retention")
+
+	// Reading what was archived does not need archiving on
+	a, err = Open(nil, config.RawArchiveConfig{})
+	require.NoError(t, err)
+	assert.NotNil(t, a)
+}
+
+func TestObjectKey(t *testing.T) {
+	sum := sha256.Sum256([]byte("{}"))
+	at := time.Date(2026, 10, 16, 23, 30, 0, 0, time.FixedZone("", -2*3600))
+	assert.Equal(t, "s3://bucket/raw/kev/2026/10/17/44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a.gz",
+		objectKey("s3://bucket/raw", KEV, at, sum))
+}
+
+func TestGunzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`<rss></rss>`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	data, err := gunzip(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, `<rss></rss>`, string(data))
+	_, err = gunzip([]byte("not gzip"))
+	assert.Error(t, err)
+}
+
+func TestArchive_NilStoresNothing(t *testing.T) {
+	var a *Archive
+	assert.NotPanics(t, func() { a.Put(context.Background(), NVD, "https://example.test/page", []byte("{}")) })
+}
@@ -0,0 +1,276 @@
+// Package reflabel gives NVD reference links readable labels. The Resolver
+// fetches the pages of references tagged "Vendor Advisory" and stores their
+// <title> in reference_labels, so reports can show "Cisco Security
+// Advisory: ..." instead of a raw URL. Fetching is opt-in
+// ([reference_labels]) and polite: one request at a time through the
+// [crawl] layer, which honours robots.txt and pauses between requests to
+// the same host, and hosts that answer 429 or 503 are left alone until the
+// next run.
+package reflabel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tiger2go/internal/crawl"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/metrics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Defaults for NewResolver.
+const (
+	DefaultBatchSize  = 200
+	DefaultRetryAfter = 168 * time.Hour
+)
+
+// UserAgent is sent with page and robots.txt requests.
+const UserAgent = "tigerfetch/1.0 (+https://tigerblue.app)"
+
+const (
+	maxPageBytes = 4 << 20 // titles sit in <head>; larger pages are not worth reading
+	maxLabelLen  = 200     // runes
+)
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Resolver fetches and stores vendor advisory titles.
+type Resolver struct {
+	db         *pgxpool.Pool
+	policy     httpclient.URLPolicy
+	client     *http.Client
+	batch      int
+	retryAfter time.Duration
+}
+
+// NewResolver creates a Resolver fetching under policy through crawler;
+// a nil crawler fetches without pauses or robots.txt checks. Zero values
+// use the defaults.
+func NewResolver(db *pgxpool.Pool, policy httpclient.URLPolicy, crawler *crawl.Crawler, batch int, retryAfter time.Duration) *Resolver {
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+	client := policy.Client(30 * time.Second)
+	if crawler != nil {
+		// The crawler times requests itself, not counting the pause
+		client.Timeout = 0
+		client.Transport = crawler.Transport(client.Transport)
+	}
+	return &Resolver{
+		db:         db,
+		policy:     policy,
+		client:     client,
+		batch:      batch,
+		retryAfter: retryAfter,
+	}
+}
+
+// Run resolves up to one batch of unlabelled vendor advisory URLs, those of
+// the most recently modified CVEs first.
+func (r *Resolver) Run(ctx context.Context) error {
+	urls, err := r.pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	throttled := map[string]bool{}
+	var resolved, failed int
+	for _, u := range urls {
+		host := hostOf(u)
+		if throttled[host] {
+			continue
+		}
+		label, status, err := r.fetch(ctx, u)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+			slog.Info("Reference labels: host is throttling, skipping it this run", "host", host, "status", status)
+			metrics.ReferenceLabels.WithLabelValues("throttled").Inc()
+			throttled[host] = true
+			continue
+		}
+
+		var msg string
+		switch {
+		case errors.Is(err, crawl.ErrDisallowed):
+			msg = crawl.ErrDisallowed.Error()
+			metrics.ReferenceLabels.WithLabelValues("disallowed").Inc()
+		case err != nil:
+			msg = err.Error()
+			failed++
+			metrics.ReferenceLabels.WithLabelValues("error").Inc()
+		case label == "":
+			metrics.ReferenceLabels.WithLabelValues("untitled").Inc()
+		default:
+			resolved++
+			metrics.ReferenceLabels.WithLabelValues("resolved").Inc()
+		}
+		if err := r.save(ctx, u, label, status, msg); err != nil {
+			return err
+		}
+	}
+	slog.Info("Reference labels updated", "checked", len(urls), "resolved", resolved, "failed", failed)
+	return nil
+}
+
+// pending returns vendor advisory URLs with no label, skipping those whose
+// last attempt is more recent than retryAfter.
+func (r *Resolver) pending(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT v.url
+		FROM (
+			SELECT ref->>'url' AS url, max(e.modified) AS modified
+			FROM cve_enriched e,
+			     jsonb_array_elements(CASE WHEN jsonb_typeof(e.json->'references') = 'array'
+			                               THEN e.json->'references' ELSE '[]' END) ref
+			WHERE e.source = 'NVD' AND ref->'tags' ? $1
+			GROUP BY 1
+		) v
+		WHERE v.url IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM reference_labels l
+			WHERE l.url = v.url AND (l.label IS NOT NULL OR l.fetched_at > $2)
+		  )
+		ORDER BY v.modified DESC NULLS LAST, v.url
+		LIMIT $3
+	`, cve.RefTagVendorAdvisory, time.Now().Add(-r.retryAfter), r.batch)
+	if err != nil {
+		return nil, fmt.Errorf("query unlabelled references: %w", err)
+	}
+	defer rows.Close()
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, fmt.Errorf("scan unlabelled reference: %w", err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// fetch GETs raw and returns its title. Non-HTML responses (PDFs, text
+// advisories) have no title and are not an error.
+func (r *Resolver) fetch(ctx context.Context, raw string) (label string, status int, err error) {
+	if err := r.policy.CheckURL(raw); err != nil {
+		return "", 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(strings.ToLower(ct), "html") {
+		return "", resp.StatusCode, nil
+	}
+	body, err := httpclient.ReadBody(resp, maxPageBytes)
+	if err != nil && !errors.Is(err, httpclient.ErrResponseTooLarge) {
+		return "", resp.StatusCode, err
+	}
+	return Title(body), resp.StatusCode, nil
+}
+
+func (r *Resolver) save(ctx context.Context, u, label string, status int, msg string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO reference_labels (url, label, http_status, error, fetched_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, 0), NULLIF($4, ''), clock_timestamp())
+		ON CONFLICT (url) DO UPDATE SET
+			label = EXCLUDED.label, http_status = EXCLUDED.http_status,
+			error = EXCLUDED.error, fetched_at = EXCLUDED.fetched_at
+	`, u, label, status, msg)
+	if err != nil {
+		return fmt.Errorf("save reference label: %w", err)
+	}
+	return nil
+}
+
+// Labels returns the stored labels of urls. URLs without one are absent.
+func Labels(ctx context.Context, db *pgxpool.Pool, urls []string) (map[string]string, error) {
+	out := map[string]string{}
+	if len(urls) == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT url, label FROM reference_labels
+		WHERE url = ANY($1) AND label IS NOT NULL
+	`, urls)
+	if err != nil {
+		return nil, fmt.Errorf("query reference labels: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u, label string
+		if err := rows.Scan(&u, &label); err != nil {
+			return nil, fmt.Errorf("scan reference label: %w", err)
+		}
+		out[u] = label
+	}
+	return out, rows.Err()
+}
+
+// Title extracts a page's <title>, unescaped, with whitespace collapsed
+// and cut to a readable length. It returns "" when there is none.
+func Title(page []byte) string {
+	m := titlePattern.FindSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	t := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	if utf8.RuneCountInString(t) > maxLabelLen {
+		t = string([]rune(t)[:maxLabelLen-1]) + "…"
+	}
+	return t
+}
+
+// Fallback labels a URL that has no stored title by its host and last path
+// segment ("sec.cloudapps.cisco.com: cisco-sa-asaftd-webvpn-dos"), which is
+// usually the advisory ID. It returns raw when it cannot be parsed.
+func Fallback(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	seg := path.Base(strings.TrimRight(u.Path, "/"))
+	seg = strings.TrimSuffix(seg, path.Ext(seg))
+	if seg == "" || seg == "." || seg == "/" {
+		return u.Hostname()
+	}
+	return u.Hostname() + ": " + seg
+}
+
+func hostOf(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return strings.ToLower(u.Hostname())
+	}
+	return raw
+}
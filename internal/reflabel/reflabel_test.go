@@ -0,0 +1,131 @@
+package reflabel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"tiger2go/internal/db"
+	"tiger2go/internal/httpclient"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTitle(t *testing.T) {
+	assert.Equal(t, "Cisco Security Advisory: Cisco ASA WebVPN DoS",
+		Title([]byte("<html><head><TITLE lang=\"en\">\n  Cisco Security Advisory:\n\tCisco ASA WebVPN DoS </TITLE></head>")))
+	assert.Equal(t, "Fortinet PSIRT – FG-IR-24-015", Title([]byte("<title>Fortinet PSIRT &ndash; FG-IR-24-015</title>")))
+	assert.Empty(t, Title([]byte("<html><body>no title</body></html>")))
+
+	long := Title([]byte("<title>" + strings.Repeat("a", 500) + "</title>"))
+	assert.Equal(t, maxLabelLen, len([]rune(long)))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
+
+func TestFallback(t *testing.T) {
+	assert.Equal(t, "sec.cloudapps.cisco.com: cisco-sa-asaftd-webvpn-dos",
+		Fallback("https://sec.cloudapps.cisco.com/security/center/content/CiscoSecurityAdvisory/cisco-sa-asaftd-webvpn-dos"))
+	assert.Equal(t, "www.fortiguard.com: FG-IR-24-015", Fallback("https://www.fortiguard.com/psirt/FG-IR-24-015/"))
+	assert.Equal(t, "example.com: advisory", Fallback("https://example.com/advisory.html"))
+	assert.Equal(t, "example.com", Fallback("https://example.com/"))
+	assert.Equal(t, "not a url", Fallback("not a url"))
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Contains(t, req.Header.Get("User-Agent"), "tigerfetch")
+		switch req.URL.Path {
+		case "/advisory":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<title>Acme Security Advisory ACME-2024-01</title>"))
+		case "/advisory.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.7"))
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	r := NewResolver(nil, httpclient.URLPolicy{}, nil, 0, 0)
+	ctx := context.Background()
+
+	label, status, err := r.fetch(ctx, srv.URL+"/advisory")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Acme Security Advisory ACME-2024-01", label)
+
+	label, _, err = r.fetch(ctx, srv.URL+"/advisory.pdf")
+	require.NoError(t, err)
+	assert.Empty(t, label, "non-HTML has no title")
+
+	_, status, err = r.fetch(ctx, srv.URL+"/busy")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	_, status, err = r.fetch(ctx, srv.URL+"/gone")
+	assert.ErrorContains(t, err, "unexpected status 404")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	r = NewResolver(nil, httpclient.URLPolicy{BlockPrivate: true}, nil, 0, 0)
+	_, _, err = r.fetch(ctx, srv.URL+"/advisory")
+	assert.ErrorIs(t, err, httpclient.ErrURLNotAllowed, "the URL policy applies")
+}
+
+func TestRun_Integration(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write([]byte("<title>Advisory " + strings.TrimPrefix(req.URL.Path, "/") + "</title>"))
+	}))
+	defer srv.Close()
+
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = 'CVE-TEST-REFLABEL-1'")
+		_, _ = pool.Exec(ctx, "DELETE FROM reference_labels WHERE url LIKE $1", srv.URL+"%")
+	}
+	cleanup()
+	defer cleanup()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, modified) VALUES
+		  ('CVE-TEST-REFLABEL-1', 'NVD', jsonb_build_object('references', jsonb_build_array(
+		    jsonb_build_object('url', $1::text, 'tags', jsonb_build_array('Vendor Advisory')),
+		    jsonb_build_object('url', $2::text, 'tags', jsonb_build_array('Vendor Advisory')),
+		    jsonb_build_object('url', $3::text, 'tags', jsonb_build_array('Exploit')))), now() + interval '1 day')`,
+		srv.URL+"/SA-1", srv.URL+"/missing", srv.URL+"/poc")
+	require.NoError(t, err)
+
+	r := NewResolver(pool, httpclient.URLPolicy{}, nil, 0, 0)
+	require.NoError(t, r.Run(ctx))
+
+	labels, err := Labels(ctx, pool, []string{srv.URL + "/SA-1", srv.URL + "/missing", srv.URL + "/poc"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{srv.URL + "/SA-1": "Advisory SA-1"}, labels, "only vendor advisories are fetched")
+
+	var status int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT http_status FROM reference_labels WHERE url = $1", srv.URL+"/missing").Scan(&status))
+	assert.Equal(t, http.StatusNotFound, status)
+
+	pending, err := r.pending(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, pending, srv.URL+"/missing", "failures wait for retry_after")
+}
@@ -0,0 +1,232 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"tiger2go/internal/i18n"
+	"tiger2go/internal/pdf"
+)
+
+// Layout, in points.
+const (
+	margin  = 48.0
+	content = pdf.PageWidth - 2*margin
+)
+
+var (
+	accent     = pdf.Color{R: 22, G: 60, B: 110}
+	light      = pdf.Color{R: 236, G: 240, B: 245}
+	rule       = pdf.Color{R: 200, G: 205, B: 212}
+	good       = pdf.Color{R: 46, G: 139, B: 87}
+	severityOf = map[string]pdf.Color{
+		"CRITICAL": {R: 153, G: 27, B: 30},
+		"HIGH":     {R: 221, G: 107, B: 32},
+		"MEDIUM":   {R: 236, G: 178, B: 46},
+		"LOW":      {R: 90, G: 143, B: 41},
+		"":         {R: 160, G: 160, B: 160},
+	}
+)
+
+// PDF renders w in the language of loc, with dates in zone.
+func PDF(w *Weekly, loc *i18n.Localizer, zone *time.Location, generated time.Time) []byte {
+	doc := pdf.New(loc.T("report.title", nil))
+	doc.AddPage()
+	y := margin + 22
+
+	doc.Text(margin, y, 22, true, accent, loc.T("report.title", nil))
+	y += 18
+	doc.Text(margin, y, 11, false, pdf.Gray, loc.T("report.period", map[string]string{
+		"Since": w.Since.In(zone).Format(time.DateOnly), "Until": w.Until.In(zone).Format(time.DateOnly)}))
+	y += 24
+
+	// Headline figures
+	kpis := []struct {
+		label string
+		value int
+	}{
+		{loc.T("report.kpi.new_cves", nil), w.NewCVEs},
+		{loc.T("report.kpi.critical", nil), w.Critical},
+		{loc.T("report.kpi.kev", nil), w.KEVAdded},
+		{loc.T("report.kpi.ransomware", nil), w.Ransomware},
+		{loc.T("report.kpi.advisories", nil), w.Advisories},
+	}
+	gap := 8.0
+	boxW := (content - gap*float64(len(kpis)-1)) / float64(len(kpis))
+	for i, k := range kpis {
+		x := margin + float64(i)*(boxW+gap)
+		doc.Rect(x, y, boxW, 58, light)
+		doc.TextCenter(x+boxW/2, y+30, 22, true, accent, strconv.Itoa(k.value))
+		doc.TextCenter(x+boxW/2, y+48, 8.5, false, pdf.Gray, pdf.Fit(k.label, 8.5, boxW-6, false))
+	}
+	y += 58 + 34
+
+	// Severity distribution and KEV trend, side by side
+	half := (content - 24) / 2
+	bars := make([]bar, len(Severities))
+	for i, sev := range Severities {
+		label := sev
+		if sev == "" {
+			label = loc.T("report.unscored", nil)
+		}
+		bars[i] = bar{label: label, value: float64(w.Severity[sev]), text: strconv.Itoa(w.Severity[sev]), color: severityOf[sev]}
+	}
+	heading(doc, margin, y, loc.T("report.severity", nil))
+	barChart(doc, margin, y+14, half, 150, bars, 0)
+
+	bars = make([]bar, len(w.KEVTrend))
+	for i, b := range w.KEVTrend {
+		bars[i] = bar{label: b.Start.In(zone).Format("01-02"), value: float64(b.Count), text: strconv.Itoa(b.Count), color: accent}
+	}
+	heading(doc, margin+half+24, y, loc.T("report.kev_trend", nil))
+	barChart(doc, margin+half+24, y+14, half, 150, bars, 0)
+	y += 14 + 150 + 40
+
+	// Acknowledgement SLA
+	target := Duration(w.SLA)
+	heading(doc, margin, y, loc.T("report.sla", map[string]string{"Target": target}))
+	y += 18
+	a := w.Alerts
+	if a.Due+a.Open == 0 {
+		doc.Text(margin, y, 10, false, pdf.Black, loc.T("report.sla.none", nil))
+	} else {
+		pct := "–"
+		if p := a.Percent(); p >= 0 {
+			pct = strconv.Itoa(p) + " %"
+		}
+		doc.Text(margin, y, 10, false, pdf.Black, loc.T("report.sla.summary", map[string]string{
+			"Within": strconv.Itoa(a.Within), "Due": strconv.Itoa(a.Due), "Percent": pct,
+			"Median": Duration(a.MedianAck), "Late": strconv.Itoa(a.Late),
+			"Escalated": strconv.Itoa(a.Escalated), "Open": strconv.Itoa(a.Open)}))
+	}
+	y += 12
+	bars = make([]bar, len(w.SLATrend))
+	for i, b := range w.SLATrend {
+		bars[i] = bar{label: b.Start.In(zone).Format("01-02"), color: good}
+		if b.Count > 0 {
+			p := 100 * b.Within / b.Count
+			bars[i].value, bars[i].text = float64(p), strconv.Itoa(p)+"%"
+		}
+	}
+	barChart(doc, margin, y, content, 90, bars, 100)
+	y += 90 + 40
+
+	// The week's KEV additions
+	heading(doc, margin, y, loc.T("report.top_kev", map[string]int{"Shown": len(w.KEV), "Total": w.KEVAdded}))
+	y += 8
+	cols := []struct {
+		header string
+		width  float64
+		value  func(KEVEntry) string
+	}{
+		{loc.Header("cve"), 92, func(e KEVEntry) string { return e.CVEID }},
+		{loc.Header("vendor"), 80, func(e KEVEntry) string { return e.Vendor }},
+		{loc.Header("product"), 90, func(e KEVEntry) string { return e.Product }},
+		{loc.Header("title"), content - 92 - 80 - 90 - 60 - 40, func(e KEVEntry) string { return e.Name }},
+		{loc.Header("due"), 60, func(e KEVEntry) string { return e.DueDate }},
+		{loc.Header("ransomware"), 40, func(e KEVEntry) string {
+			if e.Ransomware {
+				return "•"
+			}
+			return ""
+		}},
+	}
+	row := func(bold bool, color pdf.Color, values func(i int) string) {
+		x := margin
+		for i, c := range cols {
+			doc.Text(x+2, y+11, 8.5, bold, color, pdf.Fit(values(i), 8.5, c.width-6, bold))
+			x += c.width
+		}
+		y += 16
+	}
+	doc.Rect(margin, y, content, 16, accent)
+	row(true, pdf.White, func(i int) string { return cols[i].header })
+	if len(w.KEV) == 0 {
+		doc.Text(margin+2, y+11, 8.5, false, pdf.Gray, loc.T("summary.none", nil))
+		y += 16
+	}
+	for n, e := range w.KEV {
+		if y > pdf.PageHeight-margin-30 {
+			footer(doc, loc, zone, generated)
+			doc.AddPage()
+			y = margin
+		}
+		if n%2 == 1 {
+			doc.Rect(margin, y, content, 16, light)
+		}
+		color := pdf.Black
+		if e.Ransomware {
+			color = severityOf["CRITICAL"]
+		}
+		row(false, color, func(i int) string { return cols[i].value(e) })
+	}
+	footer(doc, loc, zone, generated)
+	return doc.Bytes()
+}
+
+func heading(doc *pdf.Doc, x, y float64, s string) {
+	doc.Text(x, y, 12, true, accent, s)
+}
+
+func footer(doc *pdf.Doc, loc *i18n.Localizer, zone *time.Location, generated time.Time) {
+	y := pdf.PageHeight - margin + 18
+	doc.Line(margin, y-12, pdf.PageWidth-margin, y-12, 0.5, rule)
+	doc.Text(margin, y, 8, false, pdf.Gray, loc.T("report.generated", map[string]string{
+		"Time": generated.In(zone).Format("2006-01-02 15:04 MST")}))
+	doc.TextRight(pdf.PageWidth-margin, y, 8, false, pdf.Gray, loc.T("report.page", map[string]int{"Page": doc.Pages()}))
+}
+
+type bar struct {
+	label string
+	value float64
+	text  string // printed above the bar
+	color pdf.Color
+}
+
+// barChart draws bars in the box at x, y, scaled to top (0: the largest
+// value), with labels under the axis.
+func barChart(doc *pdf.Doc, x, y, w, h float64, bars []bar, top float64) {
+	if top == 0 {
+		for _, b := range bars {
+			top = max(top, b.value)
+		}
+	}
+	plotH := h - 16 - 12 // room for the labels below and the values above
+	base := y + 12 + plotH
+	doc.Line(x, base, x+w, base, 0.75, rule)
+	if len(bars) == 0 {
+		return
+	}
+	slot := w / float64(len(bars))
+	barW := slot * 0.62
+	label := min(8, slot/3.2)
+	for i, b := range bars {
+		cx := x + slot*float64(i) + slot/2
+		if top > 0 && b.value > 0 {
+			bh := plotH * b.value / top
+			doc.Rect(cx-barW/2, base-bh, barW, bh, b.color)
+			doc.TextCenter(cx, base-bh-3, label, false, pdf.Black, b.text)
+		} else if b.text != "" {
+			doc.TextCenter(cx, base-3, label, false, pdf.Black, b.text)
+		}
+		doc.TextCenter(cx, base+11, label, false, pdf.Gray, pdf.Fit(b.label, label, slot-2, false))
+	}
+}
+
+// Duration formats d in days, hours and minutes: "1d4h", "3h20m", "45m".
+func Duration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, h, m := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0 && h > 0:
+		return fmt.Sprintf("%dd%dh", days, h)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh%dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dm", m)
+}
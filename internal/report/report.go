@@ -0,0 +1,254 @@
+// Package report builds the weekly executive report: headline counts, the
+// CVSS severity of new CVEs, the KEV addition trend and how quickly
+// critical alerts were acknowledged, rendered as a PDF for readers who
+// will not run the CLI.
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// week is the report period and the width of each trend bar.
+const week = 7 * 24 * time.Hour
+
+// Severities lists the CVSS severities in chart order; "" counts CVEs
+// without a score.
+var Severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", ""}
+
+// Options are the settings of one report.
+type Options struct {
+	Weeks     int           // bars of the trend charts, the last one the report's week
+	SLA       time.Duration // acknowledgement target of critical alerts
+	TopKEV    int           // KEV additions listed
+	Timestamp time.Time     // end of the report's week
+}
+
+// Weekly is the report for the week ending Until.
+type Weekly struct {
+	Since, Until time.Time
+
+	NewCVEs    int // CVE records first stored in the week
+	Critical   int // of which CVSS severity CRITICAL
+	KEVAdded   int
+	Ransomware int // KEV additions with known ransomware use
+	Advisories int // feed advisories first stored in the week
+
+	Severity map[string]int // new CVEs by CVSS severity, see Severities
+	KEVTrend []Bucket       // KEV additions per week, oldest first
+
+	SLA      time.Duration
+	Alerts   Acks     // critical alerts of the week
+	SLATrend []Bucket // per week: Count alerts due, Within acknowledged in time
+
+	KEV []KEVEntry // the week's KEV additions, ransomware and earliest due first
+}
+
+// Bucket is one week of a trend.
+type Bucket struct {
+	Start  time.Time
+	Count  int
+	Within int // SLA trend only
+}
+
+// Acks summarises the acknowledgement of critical alerts. Alerts still
+// within their deadline are not counted as due yet.
+type Acks struct {
+	Due       int // acknowledged, or unacknowledged past the target
+	Within    int // acknowledged within the target
+	Late      int // acknowledged after it
+	Open      int // unacknowledged, including ones not yet due
+	Escalated int
+	MedianAck time.Duration
+}
+
+// Percent is the share of due alerts acknowledged in time, or -1 when none
+// were due.
+func (a Acks) Percent() int {
+	if a.Due == 0 {
+		return -1
+	}
+	return 100 * a.Within / a.Due
+}
+
+// KEVEntry is a KEV addition listed in the report.
+type KEVEntry struct {
+	CVEID      string
+	Vendor     string
+	Product    string
+	Name       string
+	DueDate    string
+	Ransomware bool
+}
+
+// Build queries the report for the week ending opts.Timestamp.
+func Build(ctx context.Context, db *pgxpool.Pool, opts Options) (*Weekly, error) {
+	until := opts.Timestamp.UTC()
+	w := &Weekly{Since: until.Add(-week), Until: until, SLA: opts.SLA, Severity: map[string]int{}}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"counts", func() error { return w.counts(ctx, db) }},
+		{"severity", func() error { return w.severity(ctx, db) }},
+		{"KEV trend", func() error { return w.kevTrend(ctx, db, opts.Weeks) }},
+		{"acknowledgements", func() error { return w.acks(ctx, db, opts.Weeks) }},
+		{"KEV additions", func() error { return w.kevAdditions(ctx, db, opts.TopKEV) }},
+	}
+	for _, s := range steps {
+		if err := s.run(); err != nil {
+			return nil, fmt.Errorf("report %s: %w", s.name, err)
+		}
+	}
+	return w, nil
+}
+
+func (w *Weekly) counts(ctx context.Context, db *pgxpool.Pool) error {
+	return db.QueryRow(ctx, `
+		SELECT
+			(SELECT count(*) FROM cve_consolidated WHERE first_seen_at > $1 AND first_seen_at <= $2),
+			(SELECT count(*) FROM cve_consolidated WHERE first_seen_at > $1 AND first_seen_at <= $2
+			    AND upper(cvss_severity) = 'CRITICAL'),
+			(SELECT count(*) FROM kev_changes WHERE change_type = 'added' AND NOT archived
+			    AND detected_at > $1 AND detected_at <= $2),
+			(SELECT count(*) FROM kev_changes WHERE change_type = 'added' AND NOT archived
+			    AND detected_at > $1 AND detected_at <= $2
+			    AND COALESCE(json->>'knownRansomwareCampaignUse', '') = 'Known'),
+			(SELECT count(*) FROM current WHERE first_seen_at > $1 AND first_seen_at <= $2)
+	`, w.Since, w.Until).Scan(&w.NewCVEs, &w.Critical, &w.KEVAdded, &w.Ransomware, &w.Advisories)
+}
+
+func (w *Weekly) severity(ctx context.Context, db *pgxpool.Pool) error {
+	rows, err := db.Query(ctx, `
+		SELECT upper(cvss_severity), count(*) FROM cve_consolidated
+		WHERE first_seen_at > $1 AND first_seen_at <= $2
+		GROUP BY 1
+	`, w.Since, w.Until)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sev string
+		var n int
+		if err := rows.Scan(&sev, &n); err != nil {
+			return err
+		}
+		switch sev {
+		case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+		default:
+			sev = "" // NONE and unscored
+		}
+		w.Severity[sev] += n
+	}
+	return rows.Err()
+}
+
+func (w *Weekly) kevTrend(ctx context.Context, db *pgxpool.Pool, weeks int) error {
+	w.KEVTrend = buckets(w.Until, weeks)
+	rows, err := db.Query(ctx, `
+		SELECT floor(extract(epoch FROM $1::timestamptz - detected_at) / 604800)::int, count(*)
+		FROM kev_changes
+		WHERE change_type = 'added' AND NOT archived
+		  AND detected_at > $1 - make_interval(weeks => $2) AND detected_at <= $1
+		GROUP BY 1
+	`, w.Until, weeks)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ago, n int
+		if err := rows.Scan(&ago, &n); err != nil {
+			return err
+		}
+		if b := bucketAgo(w.KEVTrend, ago); b != nil {
+			b.Count = n
+		}
+	}
+	return rows.Err()
+}
+
+func (w *Weekly) acks(ctx context.Context, db *pgxpool.Pool, weeks int) error {
+	w.SLATrend = buckets(w.Until, weeks)
+	// An alert is due once acknowledged or once its target passed, and
+	// counts in the week it was detected.
+	rows, err := db.Query(ctx, `
+		SELECT floor(extract(epoch FROM $1::timestamptz - detected_at) / 604800)::int,
+		       count(*) FILTER (WHERE acked_at IS NOT NULL OR detected_at <= $1 - make_interval(secs => $3)),
+		       count(*) FILTER (WHERE acked_at - detected_at <= make_interval(secs => $3)),
+		       count(*) FILTER (WHERE acked_at - detected_at > make_interval(secs => $3)),
+		       count(*) FILTER (WHERE acked_at IS NULL),
+		       count(*) FILTER (WHERE escalated_at IS NOT NULL),
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY extract(epoch FROM acked_at - detected_at))
+		           FILTER (WHERE acked_at IS NOT NULL), 0)
+		FROM critical_alerts
+		WHERE detected_at > $1 - make_interval(weeks => $2) AND detected_at <= $1
+		GROUP BY 1
+	`, w.Until, weeks, w.SLA.Seconds())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ago int
+		var a Acks
+		var median float64
+		if err := rows.Scan(&ago, &a.Due, &a.Within, &a.Late, &a.Open, &a.Escalated, &median); err != nil {
+			return err
+		}
+		if b := bucketAgo(w.SLATrend, ago); b != nil {
+			b.Count, b.Within = a.Due, a.Within
+		}
+		if ago == 0 {
+			a.MedianAck = time.Duration(median * float64(time.Second))
+			w.Alerts = a
+		}
+	}
+	return rows.Err()
+}
+
+func (w *Weekly) kevAdditions(ctx context.Context, db *pgxpool.Pool, limit int) error {
+	rows, err := db.Query(ctx, `
+		SELECT cve_id, COALESCE(vendor_project, ''), COALESCE(product, ''), COALESCE(vulnerability_name, ''),
+		       COALESCE(json->>'dueDate', ''),
+		       COALESCE(json->>'knownRansomwareCampaignUse', '') = 'Known' AS ransomware
+		FROM kev_changes
+		WHERE change_type = 'added' AND NOT archived
+		  AND detected_at > $1 AND detected_at <= $2
+		ORDER BY ransomware DESC, json->>'dueDate', cve_id
+		LIMIT $3
+	`, w.Since, w.Until, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e KEVEntry
+		if err := rows.Scan(&e.CVEID, &e.Vendor, &e.Product, &e.Name, &e.DueDate, &e.Ransomware); err != nil {
+			return err
+		}
+		w.KEV = append(w.KEV, e)
+	}
+	return rows.Err()
+}
+
+// buckets returns weeks empty weekly buckets ending at until, oldest first.
+func buckets(until time.Time, weeks int) []Bucket {
+	out := make([]Bucket, max(weeks, 1))
+	for i := range out {
+		out[i].Start = until.Add(-time.Duration(len(out)-i) * week)
+	}
+	return out
+}
+
+// bucketAgo is the bucket of the week ago weeks before the last one.
+func bucketAgo(b []Bucket, ago int) *Bucket {
+	if ago < 0 || ago >= len(b) {
+		return nil
+	}
+	return &b[len(b)-1-ago]
+}
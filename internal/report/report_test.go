@@ -0,0 +1,106 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/i18n"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sample() *Weekly {
+	until := time.Date(2026, 10, 12, 6, 0, 0, 0, time.UTC)
+	w := &Weekly{
+		Since: until.Add(-week), Until: until,
+		NewCVEs: 812, Critical: 41, KEVAdded: 3, Ransomware: 1, Advisories: 230,
+		Severity: map[string]int{"CRITICAL": 41, "HIGH": 260, "MEDIUM": 390, "LOW": 21, "": 100},
+		KEVTrend: buckets(until, 4),
+		SLA:      4 * time.Hour,
+		Alerts:   Acks{Due: 4, Within: 3, Late: 1, Open: 1, Escalated: 1, MedianAck: 95 * time.Minute},
+		SLATrend: buckets(until, 4),
+		KEV: []KEVEntry{
+			{CVEID: "CVE-2026-1001", Vendor: "Cisco", Product: "IOS XE Software", Name: "Cisco IOS XE Web UI Privilege Escalation", DueDate: "2026-11-02", Ransomware: true},
+			{CVEID: "CVE-2026-1002", Vendor: "Ivanti", Product: "Connect Secure", Name: "Ivanti Connect Secure Command Injection", DueDate: "2026-11-02"},
+		},
+	}
+	for i := range w.KEVTrend {
+		w.KEVTrend[i].Count = i + 1
+		w.SLATrend[i].Count, w.SLATrend[i].Within = 4, i
+	}
+	return w
+}
+
+func TestPDF(t *testing.T) {
+	loc, err := i18n.New("en")
+	require.NoError(t, err)
+	out := PDF(sample(), loc, time.UTC, time.Date(2026, 10, 12, 7, 30, 0, 0, time.UTC))
+
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	for _, s := range []string{
+		"(Weekly Threat Report)",
+		"(2026-10-05 to 2026-10-12)",
+		"(812)", "(230)",
+		"(New CVEs by severity)", "(CRITICAL)", "(Unscored)",
+		"(Critical alerts acknowledged within 4h)",
+		"(3 of 4 due alerts in time \\(75 %\\), median 1h35m; 1 late, 1 escalated, 1 open.)",
+		"(75%)",
+		"(KEV additions this week \\(2 of 3\\))",
+		"(CVE-2026-1001)",
+		"(Generated 2026-10-12 07:30 UTC by tigerfetch)",
+		"(Page 1)",
+	} {
+		assert.Contains(t, string(out), s)
+	}
+	assert.Equal(t, 1, strings.Count(string(out), "/Type /Page "))
+}
+
+func TestPDF_LongKEVListAddsPages(t *testing.T) {
+	loc, err := i18n.New("de")
+	require.NoError(t, err)
+	w := sample()
+	w.Alerts = Acks{}
+	for len(w.KEV) < 40 {
+		w.KEV = append(w.KEV, w.KEV[1])
+	}
+	out := string(PDF(w, loc, time.UTC, w.Until))
+
+	assert.True(t, strings.Contains(out, "(Keine kritischen Alarme in dieser Woche.)"))
+	assert.True(t, strings.Contains(out, "(Seite 2)"))
+	assert.True(t, strings.Contains(out, "/Count 2 "))
+}
+
+func TestBuckets(t *testing.T) {
+	until := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	b := buckets(until, 3)
+	require.Len(t, b, 3)
+	assert.Equal(t, until.Add(-3*week), b[0].Start)
+	assert.Equal(t, until.Add(-week), b[2].Start)
+
+	assert.Same(t, &b[2], bucketAgo(b, 0))
+	assert.Same(t, &b[0], bucketAgo(b, 2))
+	assert.Nil(t, bucketAgo(b, 3))
+	assert.Nil(t, bucketAgo(b, -1))
+	assert.Len(t, buckets(until, 0), 1)
+}
+
+func TestAcksPercent(t *testing.T) {
+	assert.Equal(t, -1, Acks{Open: 2}.Percent())
+	assert.Equal(t, 66, Acks{Due: 3, Within: 2}.Percent())
+}
+
+func TestDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * time.Second:              "1m",
+		45 * time.Minute:              "45m",
+		4 * time.Hour:                 "4h",
+		3*time.Hour + 20*time.Minute:  "3h20m",
+		24 * time.Hour:                "1d",
+		28*time.Hour + 10*time.Minute: "1d4h",
+	} {
+		assert.Equal(t, want, Duration(d), d.String())
+	}
+}
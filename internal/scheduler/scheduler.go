@@ -0,0 +1,276 @@
+// Package scheduler shares a concurrency budget among the daemon's runners.
+// Every scheduled run (an NVD poll, a KEV download, one feed fetch) takes a
+// slot for its duration. When the budget is spent, waiting runs start in
+// priority order as slots free up; among sources of equal priority, each
+// gets starts in proportion to its weight (stride scheduling). Reserved
+// slots are only handed to critical sources, so a KEV poll never queues
+// behind a multi-hour NVD backfill and a daily EPSS load.
+package scheduler
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+)
+
+// Source names, as used in [scheduler.sources] and metric labels.
+const (
+	KEV             = "kev"
+	Alerting        = "alerting"
+	Feeds           = "feeds"
+	Mail            = "mail"
+	Social          = "social"
+	EPSS            = "epss"
+	Remote          = "remote"
+	NVD             = "nvd"
+	NVDProducts     = "nvd_products"
+	Merge           = "merge"
+	Coverage        = "coverage"
+	ReferenceLabels = "reference_labels"
+	Detections      = "detections"
+	EOL             = "eol"
+)
+
+// defaultPriority ranks sources by how much a delay costs: KEV listings and
+// the alerts built on them first, bulk backfills and housekeeping last.
+var defaultPriority = map[string]int{
+	KEV:             100,
+	Alerting:        90,
+	Feeds:           70,
+	Mail:            70,
+	Social:          70,
+	EPSS:            60,
+	Remote:          50,
+	NVD:             40,
+	NVDProducts:     40,
+	Merge:           30,
+	Coverage:        20,
+	ReferenceLabels: 10,
+	Detections:      10,
+	EOL:             10,
+}
+
+// DefaultCritical are the sources that may use reserved slots.
+var DefaultCritical = []string{KEV, Alerting}
+
+// Sources lists the source names in priority order.
+func Sources() []string {
+	names := make([]string, 0, len(defaultPriority))
+	for name := range defaultPriority {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(defaultPriority[b], defaultPriority[a]), cmp.Compare(a, b))
+	})
+	return names
+}
+
+type source struct {
+	name          string
+	priority      int
+	weight        float64
+	maxConcurrent int
+	critical      bool
+
+	running int
+	waiting int
+	pass    float64 // stride position: grows by 1/weight per start
+}
+
+type waiter struct {
+	src     *source
+	seq     uint64
+	granted bool
+	ready   chan struct{}
+}
+
+// Scheduler hands out run slots. The zero budget admits every run at once,
+// which is the daemon's behaviour without [scheduler].
+type Scheduler struct {
+	budget   int
+	reserved int
+	sources  map[string]*source
+
+	mu      sync.Mutex
+	running int
+	queue   []*waiter
+	seq     uint64
+	vtime   float64 // pass of the last start, where newly busy sources join
+}
+
+// New builds a Scheduler from cfg. Unknown source names are an error, so a
+// typo in [scheduler.sources] does not silently keep the defaults.
+func New(cfg config.SchedulerConfig) (*Scheduler, error) {
+	if cfg.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("scheduler.max_concurrent must not be negative")
+	}
+	reserved := cfg.Reserved
+	if reserved == 0 && cfg.MaxConcurrent > 1 {
+		reserved = 1
+	}
+	if cfg.MaxConcurrent > 0 && (reserved < 0 || reserved >= cfg.MaxConcurrent) {
+		return nil, fmt.Errorf("scheduler.reserved (%d) must be below max_concurrent (%d)", reserved, cfg.MaxConcurrent)
+	}
+	critical := cfg.Critical
+	if critical == nil {
+		critical = DefaultCritical
+	}
+	for _, name := range critical {
+		if err := checkSource(name); err != nil {
+			return nil, fmt.Errorf("scheduler.critical: %w", err)
+		}
+	}
+	for name, sc := range cfg.Sources {
+		if err := checkSource(name); err != nil {
+			return nil, fmt.Errorf("scheduler.sources: %w", err)
+		}
+		if sc.Weight < 0 || sc.MaxConcurrent < 0 {
+			return nil, fmt.Errorf("scheduler.sources.%s: weight and max_concurrent must not be negative", name)
+		}
+	}
+
+	s := &Scheduler{budget: cfg.MaxConcurrent, reserved: reserved, sources: map[string]*source{}}
+	for name, prio := range defaultPriority {
+		sc := cfg.Sources[name]
+		s.sources[name] = &source{
+			name:          name,
+			priority:      cmp.Or(sc.Priority, prio),
+			weight:        cmp.Or(sc.Weight, 1),
+			maxConcurrent: sc.MaxConcurrent,
+			critical:      slices.Contains(critical, name),
+		}
+	}
+	return s, nil
+}
+
+func checkSource(name string) error {
+	if _, ok := defaultPriority[name]; !ok {
+		return fmt.Errorf("unknown source %q (have %s)", name, strings.Join(Sources(), ", "))
+	}
+	return nil
+}
+
+// Do runs fn in a slot of source, waiting for one if the budget is spent.
+// It returns ctx.Err() if ctx ends first.
+func (s *Scheduler) Do(ctx context.Context, source string, fn func(context.Context) error) error {
+	release, err := s.Acquire(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+// Acquire waits for a slot of source and returns the function that frees
+// it. Unknown sources panic: they are constants in the daemon.
+func (s *Scheduler) Acquire(ctx context.Context, name string) (func(), error) {
+	src, ok := s.sources[name]
+	if !ok {
+		panic("scheduler: unknown source " + name)
+	}
+	start := time.Now()
+
+	s.mu.Lock()
+	s.seq++
+	w := &waiter{src: src, seq: s.seq, ready: make(chan struct{})}
+	if src.running == 0 && src.waiting == 0 {
+		src.pass = max(src.pass, s.vtime)
+	}
+	src.waiting++
+	s.queue = append(s.queue, w)
+	metrics.SchedulerQueued.WithLabelValues(name).Inc()
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		s.mu.Lock()
+		if !w.granted {
+			s.remove(w)
+			src.waiting--
+			metrics.SchedulerQueued.WithLabelValues(name).Dec()
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		s.mu.Unlock()
+	}
+	metrics.SchedulerWait.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running--
+			src.running--
+			metrics.SchedulerRunning.WithLabelValues(name).Dec()
+			s.dispatch()
+		})
+	}, nil
+}
+
+// dispatch starts waiting runs while slots allow. Callers hold mu.
+func (s *Scheduler) dispatch() {
+	for {
+		var next *waiter
+		for _, w := range s.queue {
+			if s.admits(w.src) && (next == nil || before(w, next)) {
+				next = w
+			}
+		}
+		if next == nil {
+			return
+		}
+		s.remove(next)
+		src := next.src
+		src.waiting--
+		src.running++
+		s.running++
+		s.vtime = src.pass
+		src.pass += 1 / src.weight
+		next.granted = true
+		close(next.ready)
+		metrics.SchedulerQueued.WithLabelValues(src.name).Dec()
+		metrics.SchedulerRunning.WithLabelValues(src.name).Inc()
+	}
+}
+
+// admits reports whether a run of src may start now.
+func (s *Scheduler) admits(src *source) bool {
+	if src.maxConcurrent > 0 && src.running >= src.maxConcurrent {
+		return false
+	}
+	if s.budget == 0 {
+		return true
+	}
+	if src.critical {
+		return s.running < s.budget
+	}
+	return s.running < s.budget-s.reserved
+}
+
+// before orders waiters: higher priority, then lower stride pass, then
+// first come.
+func before(a, b *waiter) bool {
+	if a.src.priority != b.src.priority {
+		return a.src.priority > b.src.priority
+	}
+	if a.src.pass != b.src.pass {
+		return a.src.pass < b.src.pass
+	}
+	return a.seq < b.seq
+}
+
+func (s *Scheduler) remove(w *waiter) {
+	if i := slices.Index(s.queue, w); i >= 0 {
+		s.queue = slices.Delete(s.queue, i, i+1)
+	}
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newScheduler(t *testing.T, cfg config.SchedulerConfig) *Scheduler {
+	t.Helper()
+	s, err := New(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+// queued waits until n runs wait for a slot.
+func queued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queue) == n
+	}, time.Second, time.Millisecond)
+}
+
+// started records the order in which queued runs of the given sources get
+// a slot, releasing each as soon as it starts.
+func started(t *testing.T, s *Scheduler, sources ...string) <-chan string {
+	t.Helper()
+	order := make(chan string, len(sources))
+	for i, name := range sources {
+		go func() {
+			release, err := s.Acquire(context.Background(), name)
+			if err == nil {
+				order <- name
+				release()
+			}
+		}()
+		queued(t, s, i+1)
+	}
+	return order
+}
+
+func collect(t *testing.T, order <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for range n {
+		select {
+		case name := <-order:
+			got = append(got, name)
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d runs started: %v", len(got), n, got)
+		}
+	}
+	return got
+}
+
+func TestUnlimited(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{})
+	var releases []func()
+	for range 20 {
+		release, err := s.Acquire(context.Background(), NVD)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+	for _, r := range releases {
+		r()
+	}
+}
+
+func TestReservedSlotsKeepCriticalSourcesMoving(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{MaxConcurrent: 2}) // one slot reserved
+	ctx := context.Background()
+
+	backfill, err := s.Acquire(ctx, NVD)
+	require.NoError(t, err)
+
+	// EPSS may not take the reserved slot, KEV may.
+	epss := make(chan struct{})
+	go func() {
+		release, err := s.Acquire(ctx, EPSS)
+		if err == nil {
+			close(epss)
+			release()
+		}
+	}()
+	queued(t, s, 1)
+
+	kev, err := s.Acquire(ctx, KEV)
+	require.NoError(t, err, "KEV starts while NVD holds the shared slot")
+	kev()
+	select {
+	case <-epss:
+		t.Fatal("EPSS took the reserved slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	backfill()
+	select {
+	case <-epss:
+	case <-time.After(time.Second):
+		t.Fatal("EPSS did not start after NVD finished")
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{MaxConcurrent: 1})
+	hold, err := s.Acquire(context.Background(), Detections)
+	require.NoError(t, err)
+
+	order := started(t, s, EOL, NVD, KEV, Feeds)
+	hold()
+	assert.Equal(t, []string{KEV, Feeds, NVD, EOL}, collect(t, order, 4))
+}
+
+func TestWeightsShareEqualPriority(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{
+		MaxConcurrent: 1,
+		Sources: map[string]config.SchedulerSource{
+			NVD:         {Weight: 1},
+			NVDProducts: {Weight: 3},
+		},
+	})
+	hold, err := s.Acquire(context.Background(), KEV)
+	require.NoError(t, err)
+
+	var sources []string
+	for range 4 {
+		sources = append(sources, NVD, NVDProducts)
+	}
+	order := started(t, s, sources...)
+	hold()
+	got := collect(t, order, 8)
+
+	// Of the first four starts, the weight-3 source gets three.
+	products := 0
+	for _, name := range got[:4] {
+		if name == NVDProducts {
+			products++
+		}
+	}
+	assert.Equal(t, 3, products, "%v", got)
+}
+
+func TestSourceCap(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{
+		MaxConcurrent: 4,
+		Sources:       map[string]config.SchedulerSource{Feeds: {MaxConcurrent: 1}},
+	})
+	ctx := context.Background()
+	first, err := s.Acquire(ctx, Feeds)
+	require.NoError(t, err)
+
+	order := started(t, s, Feeds)
+	nvd, err := s.Acquire(ctx, NVD)
+	require.NoError(t, err, "other sources still get slots")
+	nvd()
+
+	first()
+	assert.Equal(t, []string{Feeds}, collect(t, order, 1))
+}
+
+func TestCancelWhileWaiting(t *testing.T) {
+	s := newScheduler(t, config.SchedulerConfig{MaxConcurrent: 1})
+	hold, err := s.Acquire(context.Background(), NVD)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := s.Do(ctx, EPSS, func(context.Context) error {
+			t.Error("cancelled run started")
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	queued(t, s, 1)
+	cancel()
+	wg.Wait()
+	queued(t, s, 0)
+
+	hold()
+	release, err := s.Acquire(context.Background(), EPSS)
+	require.NoError(t, err, "the cancelled waiter did not leak its slot")
+	release()
+}
+
+func TestNewValidates(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  config.SchedulerConfig
+		want string
+	}{
+		{config.SchedulerConfig{MaxConcurrent: -1}, "must not be negative"},
+		{config.SchedulerConfig{MaxConcurrent: 2, Reserved: 2}, "must be below max_concurrent"},
+		{config.SchedulerConfig{Critical: []string{"kve"}}, `unknown source "kve"`},
+		{config.SchedulerConfig{Sources: map[string]config.SchedulerSource{"nvd_backfill": {}}}, `unknown source "nvd_backfill"`},
+		{config.SchedulerConfig{Sources: map[string]config.SchedulerSource{NVD: {Weight: -1}}}, "must not be negative"},
+	} {
+		_, err := New(tt.cfg)
+		assert.ErrorContains(t, err, tt.want, "%+v", tt.cfg)
+	}
+	assert.Equal(t, KEV, Sources()[0])
+}
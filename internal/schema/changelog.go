@@ -0,0 +1,264 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Changelog renders the differences between consecutive major versions of
+// every schema as Markdown, newest first. docs/SCHEMA_CHANGELOG.md is this
+// output, kept current by `make schemas`.
+func Changelog() (string, error) {
+	var b strings.Builder
+	b.WriteString("# Output schema changelog\n\n")
+	b.WriteString("Generated by `make schemas` from the schemas embedded in tigerfetch; do not edit.\n")
+	b.WriteString("`tigerfetch schema print NAME` prints the current schemas and `--schema-version N`\n")
+	b.WriteString("on the export commands writes the shape of an earlier major version.\n")
+
+	versions := Versions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		label, err := versionLabel(v)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n## %s\n", label)
+		if i == 0 {
+			b.WriteString("\nFirst published version.\n")
+			continue
+		}
+		for _, name := range Names() {
+			entries, err := diffVersions(name, versions[i-1], v)
+			if err != nil {
+				return "", err
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n", name)
+			for _, e := range entries {
+				fmt.Fprintf(&b, "- %s\n", e)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// versionLabel is the full version a major version was frozen at.
+func versionLabel(major int) (string, error) {
+	raw, err := GetVersion(Names()[0], major)
+	if err != nil {
+		return "", err
+	}
+	var s struct {
+		Version string `json:"x-tigerfetch-version"`
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", err
+	}
+	return s.Version, nil
+}
+
+func diffVersions(name string, from, to int) ([]string, error) {
+	var docs [2]map[string]any
+	for i, v := range []int{from, to} {
+		raw, err := GetVersion(name, v)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &docs[i]); err != nil {
+			return nil, fmt.Errorf("schema %s v%d: %w", name, v, err)
+		}
+	}
+	return Diff(docs[0], docs[1]), nil
+}
+
+// Diff lists how schema b differs from schema a: properties added and
+// removed, changed types, nullability and required properties. Paths name
+// properties from the document root, with [] for array items.
+func Diff(a, b map[string]any) []string {
+	d := differ{
+		old:  pruner{defs: defsOf(a)},
+		new:  pruner{defs: defsOf(b)},
+		seen: map[[2]string]bool{},
+	}
+	d.walk("", a, b)
+	return d.entries
+}
+
+func defsOf(s map[string]any) map[string]any {
+	defs, _ := s["$defs"].(map[string]any)
+	return defs
+}
+
+type differ struct {
+	old, new pruner
+	seen     map[[2]string]bool // $ref pairs already compared, for recursive types
+	entries  []string
+}
+
+func (d *differ) add(path, format string, args ...any) {
+	if path == "" {
+		path = "(document)"
+	}
+	d.entries = append(d.entries, "`"+path+"`: "+fmt.Sprintf(format, args...))
+}
+
+func (d *differ) walk(path string, a, b map[string]any) {
+	typA, nullA := describe(a)
+	typB, nullB := describe(b)
+	refA, refB := refOf(a), refOf(b)
+	a, b = d.old.resolve(a), d.new.resolve(b)
+	if typA == "array" && typB == "object" && d.wrapped(path, a, b) {
+		return
+	}
+	if typA != typB {
+		d.add(path, "type changed from %s to %s", typA, typB)
+		return
+	}
+	switch {
+	case nullB && !nullA:
+		d.add(path, "may now be null")
+	case nullA && !nullB:
+		d.add(path, "is no longer null")
+	}
+
+	if refA != "" && refB != "" {
+		if d.seen[[2]string{refA, refB}] {
+			return
+		}
+		d.seen[[2]string{refA, refB}] = true
+	}
+	switch typB {
+	case "object":
+		propsA, _ := a["properties"].(map[string]any)
+		propsB, _ := b["properties"].(map[string]any)
+		reqA, reqB := requiredOf(a), requiredOf(b)
+		for _, k := range sortedKeys(union(propsA, propsB)) {
+			p := join(path, k)
+			subA, inA := propsA[k].(map[string]any)
+			subB, inB := propsB[k].(map[string]any)
+			switch {
+			case !inA:
+				t, _ := describe(d.new.resolve(subB))
+				d.add(p, "added (%s)", t)
+				continue
+			case !inB:
+				d.add(p, "removed")
+				continue
+			case reqB[k] && !reqA[k]:
+				d.add(p, "now required")
+			case reqA[k] && !reqB[k]:
+				d.add(p, "no longer required")
+			}
+			d.walk(p, subA, subB)
+		}
+	case "array":
+		itemsA, _ := a["items"].(map[string]any)
+		itemsB, _ := b["items"].(map[string]any)
+		if itemsA != nil && itemsB != nil {
+			d.walk(path+"[]", itemsA, itemsB)
+		}
+	}
+}
+
+// wrapped reports an array that moved into the items property of an
+// envelope object, and compares it with its new place.
+func (d *differ) wrapped(path string, a, b map[string]any) bool {
+	props, _ := b["properties"].(map[string]any)
+	items, _ := props["items"].(map[string]any)
+	if typ, _ := describe(d.new.resolve(items)); typ != "array" {
+		return false
+	}
+	d.add(path, "array moved into `%s` of an object", join(path, "items"))
+	for _, k := range sortedKeys(props) {
+		if k != "items" {
+			t, _ := describe(d.new.resolve(props[k].(map[string]any)))
+			d.add(join(path, k), "added (%s)", t)
+		}
+	}
+	d.walk(join(path, "items"), a, items)
+	return true
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describe returns the non-null type s accepts and whether it accepts
+// null. References are described as objects.
+func describe(s map[string]any) (string, bool) {
+	if s == nil {
+		return "any", false
+	}
+	if _, ok := s["$ref"]; ok {
+		return "object", false
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		typ, null := "any", false
+		for _, alt := range anyOf {
+			a, _ := alt.(map[string]any)
+			if a["type"] == "null" {
+				null = true
+				continue
+			}
+			typ, _ = describe(a)
+		}
+		return typ, null
+	}
+	switch t := s["type"].(type) {
+	case string:
+		return t, false
+	case []any:
+		var types []string
+		null := false
+		for _, x := range t {
+			if x == "null" {
+				null = true
+				continue
+			}
+			types = append(types, fmt.Sprint(x))
+		}
+		return strings.Join(types, " or "), null
+	}
+	return "any", false
+}
+
+// refOf is the $ref of s, or of its non-null alternative.
+func refOf(s map[string]any) string {
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		for _, alt := range anyOf {
+			if a, _ := alt.(map[string]any); a["$ref"] != nil {
+				return refOf(a)
+			}
+		}
+	}
+	ref, _ := s["$ref"].(string)
+	return ref
+}
+
+func requiredOf(s map[string]any) map[string]bool {
+	out := map[string]bool{}
+	req, _ := s["required"].([]any)
+	for _, r := range req {
+		if name, ok := r.(string); ok {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+func union(a, b map[string]any) map[string]any {
+	out := make(map[string]any, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// pruner drops the properties of a document that an earlier schema does
+// not know, so fields added since do not reach parsers written for it.
+type pruner struct {
+	defs map[string]any
+}
+
+// resolve follows $ref and, for nullable alternatives, picks the non-null
+// one.
+func (p pruner) resolve(s map[string]any) map[string]any {
+	for s != nil {
+		if ref, ok := s["$ref"].(string); ok {
+			s, _ = p.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+			continue
+		}
+		anyOf, _ := s["anyOf"].([]any)
+		var next map[string]any
+		for _, alt := range anyOf {
+			if a, ok := alt.(map[string]any); ok && a["type"] != "null" {
+				next = a
+				break
+			}
+		}
+		if next == nil {
+			return s
+		}
+		s = next
+	}
+	return nil
+}
+
+func (p pruner) prune(doc []byte, s map[string]any) ([]byte, error) {
+	s = p.resolve(s)
+	doc = bytes.TrimSpace(doc)
+	if s == nil || len(doc) == 0 {
+		return doc, nil
+	}
+
+	switch doc[0] {
+	case '{':
+		props, _ := s["properties"].(map[string]any)
+		extra, _ := s["additionalProperties"].(map[string]any)
+		if props == nil && extra == nil {
+			return doc, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(doc))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		out.WriteByte('{')
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := tok.(string)
+			var val json.RawMessage
+			if err := dec.Decode(&val); err != nil {
+				return nil, err
+			}
+			sub, ok := props[key].(map[string]any)
+			if !ok {
+				if extra == nil {
+					continue
+				}
+				sub = extra
+			}
+			if val, err = p.prune(val, sub); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			if out.Len() > 1 {
+				out.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			out.Write(k)
+			out.WriteByte(':')
+			out.Write(val)
+		}
+		out.WriteByte('}')
+		return out.Bytes(), nil
+
+	case '[':
+		items, _ := s["items"].(map[string]any)
+		if items == nil {
+			return doc, nil
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(doc, &elems); err != nil {
+			return nil, err
+		}
+		for i, e := range elems {
+			pruned, err := p.prune(e, items)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			elems[i] = pruned
+		}
+		return json.Marshal(elems)
+	}
+	return doc, nil
+}
@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/query"
+)
+
+// upgrades move a document of major version v to v+1, keyed by v. Add
+// one when bumping Major: renamed, moved or retyped properties are carried
+// over there, while added ones are simply left to their zero value.
+var upgrades = map[int]func(name string, doc any) (any, error){
+	// 2.0.0 wrapped list documents in {"schema_version", "items"}.
+	1: func(name string, doc any) (any, error) {
+		if !isList(name) {
+			return doc, nil
+		}
+		if _, ok := doc.([]any); !ok && doc != nil {
+			return nil, fmt.Errorf("version 1 %s is an array, got %T", name, doc)
+		}
+		return map[string]any{"items": doc}, nil
+	},
+}
+
+// Upgrade reads the document called name as written by any version of
+// tigerfetch and returns it in the shape of the current version, with
+// schema_version set where that shape has it. A document without
+// schema_version is taken for version 1; one written by a newer major
+// version fails.
+func Upgrade(name string, data []byte) ([]byte, error) {
+	if !slices.Contains(Names(), name) {
+		return nil, fmt.Errorf("unknown schema %q (have %s)", name, strings.Join(Names(), ", "))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+	major, err := documentMajor(name, doc)
+	if err != nil {
+		return nil, err
+	}
+	for v := major; v < Major; v++ {
+		upgrade, ok := upgrades[v]
+		if !ok {
+			return nil, fmt.Errorf("no upgrade of %s from version %d", name, v)
+		}
+		if doc, err = upgrade(name, doc); err != nil {
+			return nil, fmt.Errorf("upgrade %s from version %d: %w", name, v, err)
+		}
+	}
+	if m, ok := doc.(map[string]any); ok && hasVersion(name) {
+		m["schema_version"] = Version
+	}
+	return json.Marshal(doc)
+}
+
+// documentMajor is the major version doc was written in.
+func documentMajor(name string, doc any) (int, error) {
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return 1, nil
+	}
+	sv, ok := m["schema_version"].(string)
+	if !ok {
+		if isList(name) {
+			return 0, fmt.Errorf("%s document has no schema_version", name)
+		}
+		return 1, nil
+	}
+	major, err := strconv.Atoi(strings.SplitN(sv, ".", 2)[0])
+	if err != nil || major < 1 {
+		return 0, fmt.Errorf("%s document has invalid schema_version %q", name, sv)
+	}
+	if major > Major {
+		return 0, fmt.Errorf("%s document has schema_version %s, newer than this tigerfetch (%s)", name, sv, Version)
+	}
+	return major, nil
+}
+
+// Decode reads the document called name, written by any version of
+// tigerfetch, into v: the Go type of its current shape, e.g.
+// *[]query.Advisory for "query". List documents decode to their items.
+func Decode(name string, data []byte, v any) error {
+	doc, err := Upgrade(name, data)
+	if err != nil {
+		return err
+	}
+	if !isList(name) {
+		return json.Unmarshal(doc, v)
+	}
+	var env struct {
+		Items json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(doc, &env); err != nil {
+		return err
+	}
+	if len(env.Items) == 0 {
+		env.Items = []byte("null")
+	}
+	return json.Unmarshal(env.Items, v)
+}
+
+// New returns a pointer to the Go value of the document called name, a
+// slice of its items for list documents, for Decode.
+func New(name string) (any, error) {
+	i := slices.IndexFunc(documents, func(d document) bool { return d.name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	typ := documents[i].typ
+	if documents[i].list {
+		typ = reflect.SliceOf(typ)
+	}
+	return reflect.New(typ).Interface(), nil
+}
+
+// LoadAdvisories reads a `tigerfetch query --format json` file of any
+// version.
+func LoadAdvisories(r io.Reader) ([]query.Advisory, error) {
+	return load[query.Advisory](r, "query")
+}
+
+// LoadEnriched reads a `tigerfetch enrich --format json` file of any
+// version.
+func LoadEnriched(r io.Reader) ([]enrich.Result, error) {
+	return load[enrich.Result](r, "enrich")
+}
+
+func load[T any](r io.Reader, name string) ([]T, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	if err := Decode(name, data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// hasVersion reports whether the current shape of the document called
+// name carries schema_version.
+func hasVersion(name string) bool {
+	i := slices.IndexFunc(documents, func(d document) bool { return d.name == name })
+	if i < 0 {
+		return false
+	}
+	if documents[i].list {
+		return true
+	}
+	typ := documents[i].typ
+	for f := range typ.Fields() {
+		if strings.Split(f.Tag.Get("json"), ",")[0] == "schema_version" {
+			return true
+		}
+	}
+	return false
+}
+
+func isList(name string) bool {
+	i := slices.IndexFunc(documents, func(d document) bool { return d.name == name })
+	return i >= 0 && documents[i].list
+}
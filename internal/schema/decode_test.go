@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cve"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/query"
+	"tiger2go/internal/summary"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_EveryVersion(t *testing.T) {
+	ts := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	advs := []query.Advisory{{Source: "CISA", Title: "Ivanti Connect Secure", Published: &ts, FirstSeenAt: ts,
+		CVEs: []string{"CVE-2023-46805"}, KEV: true, CVSS: 9.1, Severity: "CRITICAL"}}
+	results := []enrich.Result{{CveID: "CVE-2023-46805", Found: true, Source: "local", InKEV: true,
+		EPSS: &enrich.EPSS{Score: 0.97, Percentile: 0.99, AsOf: "2024-01-11"}}}
+
+	for _, v := range Versions() {
+		out, err := Encode("query", v, advs)
+		require.NoError(t, err)
+		got, err := LoadAdvisories(strings.NewReader(string(out)))
+		require.NoError(t, err, "query v%d", v)
+		assert.Equal(t, advs, got, "query v%d", v)
+
+		out, err = Encode("enrich", v, results)
+		require.NoError(t, err)
+		gotResults, err := LoadEnriched(strings.NewReader(string(out)))
+		require.NoError(t, err, "enrich v%d", v)
+		assert.Equal(t, results, gotResults, "enrich v%d", v)
+	}
+
+	got, err := LoadAdvisories(strings.NewReader("[]"))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestUpgrade_Version1(t *testing.T) {
+	want := []cve.KevChange{{CveID: "CVE-2023-46805", ChangeType: "added"}}
+	old, err := Encode("kev-changes", 1, want)
+	require.NoError(t, err)
+	out, err := Upgrade("kev-changes", old)
+	require.NoError(t, err)
+	require.NoError(t, Validate("kev-changes", Major, out), string(out))
+	var changes []cve.KevChange
+	require.NoError(t, Decode("kev-changes", out, &changes))
+	assert.Equal(t, want, changes)
+
+	old, err = Encode("summary", 1, summary.Summary{SchemaVersion: Version})
+	require.NoError(t, err)
+	var s summary.Summary
+	require.NoError(t, Decode("summary", old, &s))
+	assert.Equal(t, Version, s.SchemaVersion)
+}
+
+func TestUpgrade_Rejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"newer major":          `{"schema_version": "99.0.0", "items": []}`,
+		"bad version":          `{"schema_version": "two", "items": []}`,
+		"list without version": `{"items": []}`,
+		"not json":             `[`,
+	} {
+		_, err := Upgrade("query", []byte(doc))
+		assert.Error(t, err, name)
+	}
+	_, err := Upgrade("nope", []byte(`{}`))
+	assert.ErrorContains(t, err, "unknown schema")
+}
+
+func TestNew(t *testing.T) {
+	v, err := New("enrich")
+	require.NoError(t, err)
+	assert.IsType(t, &[]enrich.Result{}, v)
+	v, err = New("summary")
+	require.NoError(t, err)
+	assert.IsType(t, &summary.Summary{}, v)
+}
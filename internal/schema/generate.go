@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Generate derives the current schema called name from its Go type,
+// indented and with stable key order. The embedded copy is what Get serves;
+// a test fails when the two differ.
+func Generate(name string) ([]byte, error) {
+	for _, d := range documents {
+		if d.name != name {
+			continue
+		}
+		g := &generator{defs: map[string]any{}}
+		doc := g.schema(d.typ)
+		if d.list {
+			doc = map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"schema_version": map[string]any{"type": "string"},
+					"items":          map[string]any{"type": "array", "items": doc},
+				},
+				"required": []string{"schema_version", "items"},
+			}
+		}
+		doc["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		doc["$id"] = "urn:tigerfetch:schema:" + d.name
+		doc["title"] = d.title
+		doc["x-tigerfetch-version"] = Version
+		if len(g.defs) > 0 {
+			doc["$defs"] = g.defs
+		}
+		return json.MarshalIndent(doc, "", "  ")
+	}
+	return nil, fmt.Errorf("unknown schema %q", name)
+}
+
+// generator derives JSON schemas from Go types using their json tags, like
+// the OpenAPI generator in internal/api. Named structs become $defs.
+type generator struct {
+	defs map[string]any
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	rawType       = reflect.TypeFor[json.RawMessage]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+func (g *generator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]any{"description": "Record as stored, source-specific JSON"}
+	case t.Kind() != reflect.Pointer && t.Implements(marshalerType):
+		return map[string]any{"description": "Upstream object as received"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// A nil slice is encoded as null.
+		return nullable(map[string]any{"type": "array", "items": g.schema(t.Elem())})
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())})
+	case reflect.Struct:
+		name := defName(t)
+		if _, done := g.defs[name]; !done {
+			g.defs[name] = nil // reserve, for recursive types
+			g.defs[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	}
+	return map[string]any{}
+}
+
+// nullable widens s to also accept null.
+func nullable(s map[string]any) map[string]any {
+	switch typ := s["type"].(type) {
+	case string:
+		s["type"] = []string{typ, "null"}
+		return s
+	case nil:
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+		}
+	}
+	return s
+}
+
+func (g *generator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.addFields(t, props, &required)
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// addFields adds the encoded fields of t, including those of embedded
+// structs, which encoding/json flattens into the parent.
+func (g *generator) addFields(t reflect.Type, props map[string]any, required *[]string) {
+	for f := range t.Fields() {
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// defName is the package-qualified type name, e.g. ingestor.Advisory
+// becomes "IngestorAdvisory".
+func defName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	return upperFirst(pkg) + upperFirst(t.Name())
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
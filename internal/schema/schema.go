@@ -0,0 +1,183 @@
+// Package schema publishes JSON Schemas (draft 2020-12) for the documents
+// tigerfetch writes: stored advisories and consolidated CVE records as the
+// API serves them, and the JSON output of the export commands. Schemas are
+// derived from the Go types by reflection over their json tags, checked in
+// under schemas/ and embedded in the binary, so integrators can code
+// against a file that a test keeps in step with the wire format.
+//
+// Export documents carry schema_version. The last schema of every earlier
+// major version is frozen under schemas/v<major>/, and Encode can still
+// write that shape for parsers that have not moved on; Decode reads a
+// document of any version into the current types. Before bumping the major
+// version, copy the current schemas to schemas/v<old major>/ and add the
+// upgrade step to decode.go.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/query"
+	"tiger2go/internal/summary"
+)
+
+// Version is the contract version written as schema_version and stamped
+// into every schema as x-tigerfetch-version. Bump the minor version for
+// additions and the major for breaking changes, as for api.Version.
+const Version = "2.1.0"
+
+// Major is the major part of Version.
+var Major, _ = strconv.Atoi(Version[:strings.Index(Version, ".")])
+
+// document is one published schema: the Go type it describes and what
+// produces it. List documents are a schema_version envelope around items
+// of typ.
+type document struct {
+	name  string
+	title string
+	typ   reflect.Type
+	list  bool
+}
+
+var documents = []document{
+	{"advisory", "Stored feed advisory (GET /advisories items)", reflect.TypeFor[ingestor.Advisory](), false},
+	{"cve", "Consolidated CVE record (GET /cves items)", reflect.TypeFor[consolidate.Record](), false},
+	{"enriched-advisory", "Advisory with its CVEs' KEV, EPSS, CVSS and derived fields", reflect.TypeFor[query.Advisory](), false},
+	{"query", "tigerfetch query --format json", reflect.TypeFor[query.Advisory](), true},
+	{"enrich", "tigerfetch enrich --format json", reflect.TypeFor[enrich.Result](), true},
+	{"kev-changes", "tigerfetch kev-changes --format json", reflect.TypeFor[cve.KevChange](), true},
+	{"summary", "tigerfetch summary --format json", reflect.TypeFor[summary.Summary](), false},
+}
+
+//go:embed schemas
+var files embed.FS
+
+// Names lists the published schemas in a stable order.
+func Names() []string {
+	var out []string
+	for _, d := range documents {
+		out = append(out, d.name)
+	}
+	return out
+}
+
+// Versions lists the major versions Encode can write, oldest first.
+func Versions() []int {
+	out := []int{}
+	for v := 1; v < Major; v++ {
+		if _, err := files.ReadDir(fmt.Sprintf("schemas/v%d", v)); err == nil {
+			out = append(out, v)
+		}
+	}
+	return append(out, Major)
+}
+
+// Get returns the current embedded schema called name, e.g. "advisory".
+func Get(name string) ([]byte, error) {
+	return GetVersion(name, Major)
+}
+
+// GetVersion returns the schema called name as of major version major.
+func GetVersion(name string, major int) ([]byte, error) {
+	if !slices.Contains(Names(), name) {
+		return nil, fmt.Errorf("unknown schema %q (have %s)", name, strings.Join(Names(), ", "))
+	}
+	if err := CheckVersion(major); err != nil {
+		return nil, err
+	}
+	b, err := files.ReadFile(fileName(name, major))
+	if err != nil {
+		return nil, fmt.Errorf("schema %s has no version %d", name, major)
+	}
+	return b, nil
+}
+
+// fileName is where the schema called name is checked in, relative to
+// this package.
+func fileName(name string, major int) string {
+	if major == Major {
+		return "schemas/" + name + ".schema.json"
+	}
+	return fmt.Sprintf("schemas/v%d/%s.schema.json", major, name)
+}
+
+// CheckVersion fails unless major is one of Versions.
+func CheckVersion(major int) error {
+	if slices.Contains(Versions(), major) {
+		return nil
+	}
+	var have []string
+	for _, v := range Versions() {
+		have = append(have, strconv.Itoa(v))
+	}
+	return fmt.Errorf("unknown schema version %d (have %s)", major, strings.Join(have, ", "))
+}
+
+// envelope wraps list documents so they can carry schema_version.
+type envelope struct {
+	SchemaVersion string `json:"schema_version"`
+	Items         any    `json:"items"`
+}
+
+// Encode writes v as the document called name, indented, in the shape of
+// major version major. For the current version list documents are wrapped
+// in {"schema_version", "items"} and v is written as is otherwise. For an
+// earlier version v is written bare, and properties that version's schema
+// does not know are dropped, keeping the order of the rest.
+func Encode(name string, major int, v any) ([]byte, error) {
+	i := slices.IndexFunc(documents, func(d document) bool { return d.name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	if major == Major {
+		if documents[i].list {
+			v = envelope{SchemaVersion: Version, Items: v}
+		}
+		return encodeIndent(v)
+	}
+
+	raw, err := GetVersion(name, major)
+	if err != nil {
+		return nil, err
+	}
+	var s map[string]any
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("schema %s v%d: %w", name, major, err)
+	}
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	defs, _ := s["$defs"].(map[string]any)
+	doc, err = pruner{defs: defs}.prune(doc, s)
+	if err != nil {
+		return nil, fmt.Errorf("write %s as version %d: %w", name, major, err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, doc, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// encodeIndent matches the commands' own indented JSON output.
+func encodeIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
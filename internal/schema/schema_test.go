@@ -0,0 +1,246 @@
+package schema
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cluster"
+	"tiger2go/internal/consolidate"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/detections"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/ingestor"
+	"tiger2go/internal/ioc"
+	"tiger2go/internal/kev"
+	"tiger2go/internal/query"
+	"tiger2go/internal/summary"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the checked-in schemas and changelog from the Go types")
+
+// TestSchemas_CheckedInCopiesAreCurrent fails when an output type changes
+// without `make schemas`.
+func TestSchemas_CheckedInCopiesAreCurrent(t *testing.T) {
+	for _, name := range Names() {
+		want, err := Generate(name)
+		require.NoError(t, err)
+		want = append(want, '\n')
+		if *update {
+			require.NoError(t, os.WriteFile(fileName(name, Major), want, 0o644))
+			continue
+		}
+		got, err := Get(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, string(want), string(got), "%s is stale; run `make schemas`", fileName(name, Major))
+	}
+}
+
+// TestChangelog_CheckedInCopyIsCurrent compares the embedded schemas, so
+// `make schemas` updates the changelog in a second run, after they are
+// rewritten.
+func TestChangelog_CheckedInCopyIsCurrent(t *testing.T) {
+	want, err := Changelog()
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, os.WriteFile(changelogFile, []byte(want), 0o644))
+		return
+	}
+	got, err := os.ReadFile(changelogFile)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got), "%s is stale; run `make schemas`", changelogFile)
+}
+
+const changelogFile = "../../docs/SCHEMA_CHANGELOG.md"
+
+func TestGetVersion_EveryVersionOfEverySchema(t *testing.T) {
+	assert.Equal(t, []int{1, Major}, Versions())
+	for _, v := range Versions() {
+		for _, name := range Names() {
+			_, err := GetVersion(name, v)
+			assert.NoError(t, err, "%s v%d", name, v)
+		}
+	}
+	_, err := GetVersion("query", Major+1)
+	assert.ErrorContains(t, err, "unknown schema version")
+}
+
+func TestGet_Unknown(t *testing.T) {
+	_, err := Get("nope")
+	assert.ErrorContains(t, err, "advisory")
+}
+
+func encode(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+// TestValidate_Outputs encodes fully populated documents and zero values,
+// whose nil slices and pointers are encoded as null, in every version and
+// validates them against that version's schema.
+func TestValidate_Outputs(t *testing.T) {
+	ts := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	score, epss := 9.1, 0.97
+	adv := query.Advisory{
+		Source: "CISA", FeedType: "alerts", Title: "Ivanti Connect Secure", Published: &ts, FirstSeenAt: ts,
+		CVEs: []string{"CVE-2023-46805"}, KEV: true, EPSS: epss, CVSS: score, Severity: "CRITICAL",
+		IOCs:       []ioc.Indicator{{Type: "ipv4", Value: "192.0.2.1"}},
+		Detections: []detections.Rule{{CveID: "CVE-2023-46805", Source: "sigma", ID: "1", Title: "Ivanti"}},
+		FixedIn:    []fixes.FixedIn{{Product: "ivanti:connect_secure", Version: "22.7"}},
+		EOL:        []eol.Affected{{Product: "Ivanti Connect Secure", Release: "9.1"}},
+	}
+	result := enrich.Result{
+		CveID: "CVE-2023-46805", Found: true, Source: "local", InKEV: true,
+		NVD:  &enrich.NVD{CvssBase: &score, Severity: "HIGH", Modified: ts, References: []cve.NvdReference{{URL: "https://example.com"}}},
+		KEV:  &kev.Entry{CveID: "CVE-2023-46805", DateAdded: "2024-01-10"},
+		EPSS: &enrich.EPSS{Score: epss, Percentile: 0.99, AsOf: "2024-01-11"},
+	}
+
+	for name, docs := range map[string][]any{
+		"advisory": {
+			ingestor.Advisory{ID: "00000000-0000-0000-0000-000000000001", Published: &ts, Categories: []string{"ics"}, FirstSeenAt: ts, UpdatedAt: ts},
+			ingestor.Advisory{},
+		},
+		"cve": {
+			consolidate.Record{CveID: "CVE-2023-46805", Sources: []string{"CISA-KEV", "NVD"}, CvssBase: &score, InKEV: true, KEVDateAdded: &ts, CWEs: []string{"CWE-287"}},
+			consolidate.Record{},
+		},
+		"enriched-advisory": {adv, query.Advisory{}},
+		"query":             {[]query.Advisory{adv, {}}, []query.Advisory{}},
+		"enrich":            {[]enrich.Result{result, {CveID: "CVE-2024-0001"}}},
+		"kev-changes": {
+			[]cve.KevChange{{CveID: "CVE-2023-46805", ChangeType: "added", DateReleased: &ts, ChangedFields: []string{"dueDate"}, DetectedAt: ts}},
+		},
+		"summary": {
+			summary.Summary{SchemaVersion: Version, Since: ts, Sections: []summary.Section{
+				{Name: "kev", Items: []summary.Item{{CveID: "CVE-2023-46805", CVSS: &score, EPSS: &epss, Date: ts}}},
+				{Name: "events", Events: []cluster.Event{{Title: "Ivanti", First: ts, Last: ts}}},
+			}},
+			summary.Summary{SchemaVersion: Version},
+		},
+	} {
+		for _, doc := range docs {
+			for _, v := range Versions() {
+				out, err := Encode(name, v, doc)
+				require.NoError(t, err, "%s v%d", name, v)
+				assert.NoError(t, Validate(name, v, out), "%s v%d", name, v)
+			}
+		}
+	}
+}
+
+func TestEncode_PreviousVersion(t *testing.T) {
+	changes := []cve.KevChange{{CveID: "CVE-2023-46805", ChangeType: "added"}}
+	out, err := Encode("kev-changes", Major, changes)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "{\n  \"schema_version\": \""+Version+"\",\n  \"items\": ["), string(out))
+
+	out, err = Encode("kev-changes", 1, changes)
+	require.NoError(t, err)
+	var v1 []cve.KevChange
+	require.NoError(t, json.Unmarshal(out, &v1), "version 1 is a bare array")
+	assert.Equal(t, changes, v1)
+
+	out, err = Encode("summary", 1, summary.Summary{SchemaVersion: Version})
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "schema_version")
+	assert.Contains(t, string(out), `"since"`)
+
+	_, err = Encode("summary", Major+1, summary.Summary{})
+	assert.ErrorContains(t, err, "unknown schema version")
+}
+
+func TestPrune_DropsUnknownPropertiesInOrder(t *testing.T) {
+	var s map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "array",
+		"items": {"anyOf": [{"$ref": "#/$defs/Item"}, {"type": "null"}]},
+		"$defs": {"Item": {"type": "object", "properties": {
+			"z": {"type": "string"},
+			"a": {"type": "object", "additionalProperties": {"$ref": "#/$defs/Item"}}
+		}}}
+	}`), &s))
+	doc := `[{"z":"1","new":true,"a":{"k":{"z":"2","new":[1]}}},null]`
+	out, err := pruner{defs: defsOf(s)}.prune([]byte(doc), s)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"z":"1","a":{"k":{"z":"2"}}},null]`, string(out))
+}
+
+func TestDiff(t *testing.T) {
+	var a, b map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "array",
+		"items": {"$ref": "#/$defs/Item"},
+		"$defs": {"Item": {"type": "object", "required": ["id"], "properties": {
+			"id": {"type": "string"},
+			"score": {"type": "number"},
+			"gone": {"type": "string"},
+			"next": {"$ref": "#/$defs/Item"}
+		}}}
+	}`), &a))
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "array",
+		"items": {"$ref": "#/$defs/Item"},
+		"$defs": {"Item": {"type": "object", "required": ["id", "score"], "properties": {
+			"id": {"type": "integer"},
+			"score": {"type": ["number", "null"]},
+			"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+			"next": {"anyOf": [{"$ref": "#/$defs/Item"}, {"type": "null"}]}
+		}}}
+	}`), &b))
+	assert.Equal(t, []string{
+		"`[].gone`: removed",
+		"`[].id`: type changed from string to integer",
+		"`[].next`: may now be null",
+		"`[].score`: now required",
+		"`[].score`: may now be null",
+		"`[].tags`: added (array)",
+	}, Diff(a, b))
+
+	a["type"] = "object"
+	assert.Equal(t, []string{"`(document)`: type changed from object to array"}, Diff(a, b))
+
+	a["type"] = "array"
+	envelope := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"schema_version": map[string]any{"type": "string"},
+			"items":          map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/Item"}},
+		},
+		"$defs": defsOf(a),
+	}
+	assert.Equal(t, []string{
+		"`(document)`: array moved into `items` of an object",
+		"`schema_version`: added (string)",
+	}, Diff(a, envelope))
+}
+
+func TestValidate_Rejects(t *testing.T) {
+	ts := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	adv := string(encode(t, ingestor.Advisory{FirstSeenAt: ts}))
+	sum := string(encode(t, summary.Summary{SchemaVersion: Version, Since: ts, Sections: []summary.Section{{Items: []summary.Item{{Date: ts}}}}}))
+
+	for name, tc := range map[string]struct {
+		schema, doc, err string
+	}{
+		"missing required": {"kev-changes", `{"schema_version": "2.0.0", "items": [{"cve_id": "CVE-2024-0001"}]}`, `/items/0: missing required property "change_type"`},
+		"wrong type":       {"advisory", strings.Replace(adv, `"id":""`, `"id":1`, 1), "/id: got integer, want string"},
+		"bad date-time":    {"advisory", strings.Replace(adv, "2024-01-10T12:00:00Z", "yesterday", 1), `/first_seen_at: "yesterday" is not a date-time`},
+		"null top level":   {"enrich", `null`, "/: got null, want object"},
+		"bare list":        {"enrich", `[]`, "/: got array, want object"},
+		"nested ref":       {"summary", strings.Replace(sum, `"in_kev":false`, `"in_kev":"no"`, 1), "/sections/0/items/0/in_kev: got string, want boolean"},
+	} {
+		err := Validate(tc.schema, Major, []byte(tc.doc))
+		assert.ErrorContains(t, err, tc.err, name)
+	}
+}
@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate checks doc against the embedded schema called name as of major
+// version major. It supports the keywords Generate emits: $ref into $defs,
+// type, properties, required, items, additionalProperties, anyOf and the
+// date-time format.
+func Validate(name string, major int, doc []byte) error {
+	raw, err := GetVersion(name, major)
+	if err != nil {
+		return err
+	}
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return fmt.Errorf("schema %s: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("decode document: %w", err)
+	}
+	defs, _ := root["$defs"].(map[string]any)
+	return validator{defs: defs}.check(root, v, "")
+}
+
+type validator struct {
+	defs map[string]any
+}
+
+// check validates v against s; path locates v in the document for errors,
+// as a JSON pointer.
+func (vd validator) check(s map[string]any, v any, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		def, ok := vd.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolvable $ref %q", pointer(path), ref)
+		}
+		if err := vd.check(def, v, path); err != nil {
+			return err
+		}
+	}
+
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var errs []string
+		for _, alt := range anyOf {
+			err := vd.check(alt.(map[string]any), v, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s: matches no alternative: %s", pointer(path), strings.Join(errs, "; "))
+		}
+	}
+
+	if typ, ok := s["type"]; ok {
+		var types []string
+		switch t := typ.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, x := range t {
+				types = append(types, x.(string))
+			}
+		}
+		if got := typeOf(v); !slices.Contains(types, got) && !(got == "integer" && slices.Contains(types, "number")) {
+			return fmt.Errorf("%s: got %s, want %s", pointer(path), got, strings.Join(types, " or "))
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", pointer(path), v)
+			}
+		}
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, x := range v {
+				if err := vd.check(items, x, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if req, ok := s["required"].([]any); ok {
+			for _, name := range req {
+				if _, ok := v[name.(string)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", pointer(path), name)
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		extra, _ := s["additionalProperties"].(map[string]any)
+		for _, k := range sortedKeys(v) {
+			sub, ok := props[k].(map[string]any)
+			if !ok {
+				sub = extra
+			}
+			if sub == nil {
+				continue
+			}
+			if err := vd.check(sub, v[k], path+"/"+escape(k)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// typeOf is the JSON Schema type of a value decoded with UseNumber.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func escape(k string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,94 @@
+package social
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// blueskyPost is a post view of the Bluesky AppView API.
+type blueskyPost struct {
+	URI    string `json:"uri"`
+	Author struct {
+		DID         string `json:"did"`
+		Handle      string `json:"handle"`
+		DisplayName string `json:"displayName"`
+	} `json:"author"`
+	Record struct {
+		Text      string     `json:"text"`
+		CreatedAt *time.Time `json:"createdAt"`
+		Facets    []struct {
+			Features []struct {
+				URI string `json:"uri"` // link facets
+			} `json:"features"`
+		} `json:"facets"`
+	} `json:"record"`
+	Embed *struct {
+		External *struct {
+			URI string `json:"uri"`
+		} `json:"external"`
+	} `json:"embed"`
+}
+
+// bluesky reads the newest posts of the source's account, without replies,
+// or a search for its hashtag. Reposts count as the reposted post.
+func (r *Runner) bluesky(ctx context.Context, s source) ([]post, error) {
+	var views []blueskyPost
+	if s.cfg.Hashtag != "" {
+		q := url.Values{"q": {"#" + strings.TrimPrefix(s.cfg.Hashtag, "#")}, "sort": {"latest"}, "limit": {fmt.Sprint(s.limit)}}
+		var resp struct {
+			Posts []blueskyPost `json:"posts"`
+		}
+		if err := r.getJSON(ctx, s, s.base+"/xrpc/app.bsky.feed.searchPosts?"+q.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		views = resp.Posts
+	} else {
+		q := url.Values{"actor": {strings.TrimPrefix(s.cfg.Account, "@")}, "filter": {"posts_no_replies"}, "limit": {fmt.Sprint(s.limit)}}
+		var resp struct {
+			Feed []struct {
+				Post blueskyPost `json:"post"`
+			} `json:"feed"`
+		}
+		if err := r.getJSON(ctx, s, s.base+"/xrpc/app.bsky.feed.getAuthorFeed?"+q.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		for _, f := range resp.Feed {
+			views = append(views, f.Post)
+		}
+	}
+
+	posts := make([]post, 0, len(views))
+	for _, v := range views {
+		p := post{
+			uri:     v.URI,
+			handle:  v.Author.Handle,
+			author:  v.Author.DisplayName,
+			text:    v.Record.Text,
+			created: v.Record.CreatedAt,
+		}
+		if p.author == "" {
+			p.author = p.handle
+		}
+		// at://did:plc:xyz/app.bsky.feed.post/3k... is shown at /profile/{handle}/post/3k...
+		if strings.HasPrefix(v.URI, "at://") {
+			p.link = "https://bsky.app/profile/" + cmp.Or(v.Author.Handle, v.Author.DID) + "/post/" + path.Base(v.URI)
+		}
+		for _, f := range v.Record.Facets {
+			for _, feat := range f.Features {
+				if feat.URI != "" {
+					p.links = append(p.links, feat.URI)
+				}
+			}
+		}
+		if v.Embed != nil && v.Embed.External != nil && v.Embed.External.URI != "" {
+			p.links = append(p.links, v.Embed.External.URI)
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
@@ -0,0 +1,61 @@
+package social
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+)
+
+// hnItem is an item of the Hacker News API.
+type hnItem struct {
+	ID      int64  `json:"id"`
+	Type    string `json:"type"`
+	By      string `json:"by"`
+	Time    int64  `json:"time"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Text    string `json:"text"` // HTML, Ask and Show HN
+	Dead    bool   `json:"dead"`
+	Deleted bool   `json:"deleted"`
+}
+
+// hackerNews reads the first stories of the source's list, one request
+// per story.
+func (r *Runner) hackerNews(ctx context.Context, s source) ([]post, error) {
+	var ids []int64
+	if err := r.getJSON(ctx, s, fmt.Sprintf("%s/v0/%sstories.json", s.base, cmp.Or(s.cfg.List, "new")), &ids); err != nil {
+		return nil, err
+	}
+	ids = ids[:min(len(ids), s.limit)]
+	posts := make([]post, 0, len(ids))
+	for _, id := range ids {
+		var it hnItem
+		if err := r.getJSON(ctx, s, fmt.Sprintf("%s/v0/item/%d.json", s.base, id), &it); err != nil {
+			return nil, err
+		}
+		if it.ID == 0 || it.Type != "story" || it.Dead || it.Deleted {
+			continue
+		}
+		discussion := fmt.Sprintf("https://news.ycombinator.com/item?id=%d", it.ID)
+		created := time.Unix(it.Time, 0).UTC()
+		p := post{
+			uri:     discussion,
+			link:    discussion,
+			title:   it.Title,
+			handle:  "Hacker News",
+			author:  it.By,
+			html:    it.Text,
+			text:    htmlText(it.Text),
+			created: &created,
+		}
+		if it.URL != "" {
+			p.links = append(p.links, it.URL)
+			if p.text == "" {
+				p.text = it.URL
+			}
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
@@ -0,0 +1,97 @@
+package social
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mastodonStatus is a status of the Mastodon REST API.
+type mastodonStatus struct {
+	ID        string          `json:"id"`
+	URI       string          `json:"uri"`
+	URL       string          `json:"url"`
+	CreatedAt *time.Time      `json:"created_at"`
+	Content   string          `json:"content"` // HTML
+	Spoiler   string          `json:"spoiler_text"`
+	Reblog    *mastodonStatus `json:"reblog"`
+	Account   struct {
+		Acct        string `json:"acct"`
+		DisplayName string `json:"display_name"`
+	} `json:"account"`
+	Card *struct {
+		URL string `json:"url"`
+	} `json:"card"`
+}
+
+// mastodon reads the newest statuses of the source's account, without
+// replies, or of its hashtag. Boosts count as the boosted status.
+func (r *Runner) mastodon(ctx context.Context, s source) ([]post, error) {
+	var u string
+	if s.cfg.Hashtag != "" {
+		u = fmt.Sprintf("%s/api/v1/timelines/tag/%s?limit=%d", s.base, url.PathEscape(strings.TrimPrefix(s.cfg.Hashtag, "#")), s.limit)
+	} else {
+		id, err := r.mastodonAccount(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		u = fmt.Sprintf("%s/api/v1/accounts/%s/statuses?limit=%d&exclude_replies=true", s.base, url.PathEscape(id), s.limit)
+	}
+	var statuses []mastodonStatus
+	if err := r.getJSON(ctx, s, u, &statuses); err != nil {
+		return nil, err
+	}
+	posts := make([]post, 0, len(statuses))
+	for _, st := range statuses {
+		if st.Reblog != nil {
+			st = *st.Reblog
+		}
+		p := post{
+			uri:     st.URI,
+			link:    st.URL,
+			handle:  st.Account.Acct,
+			author:  st.Account.DisplayName,
+			html:    st.Content,
+			text:    htmlText(st.Content),
+			created: st.CreatedAt,
+		}
+		if st.Spoiler != "" {
+			p.text = st.Spoiler + "\n\n" + p.text
+		}
+		if p.author == "" {
+			p.author = p.handle
+		}
+		if st.Card != nil && st.Card.URL != "" {
+			p.links = append(p.links, st.Card.URL)
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// mastodonAccount resolves the source's account to its ID on the instance,
+// once per runner.
+func (r *Runner) mastodonAccount(ctx context.Context, s source) (string, error) {
+	r.mu.Lock()
+	id, ok := r.accounts[s.cfg.Name]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	var account struct {
+		ID string `json:"id"`
+	}
+	u := s.base + "/api/v1/accounts/lookup?acct=" + url.QueryEscape(strings.TrimPrefix(s.cfg.Account, "@"))
+	if err := r.getJSON(ctx, s, u, &account); err != nil {
+		return "", fmt.Errorf("look up account %s: %w", s.cfg.Account, err)
+	}
+	if account.ID == "" {
+		return "", fmt.Errorf("look up account %s: no ID", s.cfg.Account)
+	}
+	r.mu.Lock()
+	r.accounts[s.cfg.Name] = account.ID
+	r.mu.Unlock()
+	return account.ID, nil
+}
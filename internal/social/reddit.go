@@ -0,0 +1,63 @@
+package social
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redditPost is a link or self post of a subreddit listing.
+type redditPost struct {
+	Title        string  `json:"title"`
+	Selftext     string  `json:"selftext"`
+	SelftextHTML string  `json:"selftext_html"` // unescaped with raw_json=1
+	URL          string  `json:"url"`           // the linked page, or the post itself
+	Permalink    string  `json:"permalink"`     // "/r/netsec/comments/abc/..."
+	Author       string  `json:"author"`
+	CreatedUTC   float64 `json:"created_utc"`
+	IsSelf       bool    `json:"is_self"`
+}
+
+// reddit reads the newest posts of the source's subreddit.
+func (r *Runner) reddit(ctx context.Context, s source) ([]post, error) {
+	sub := strings.TrimPrefix(strings.TrimPrefix(s.cfg.Subreddit, "/"), "r/")
+	u := fmt.Sprintf("%s/r/%s/new.json?limit=%d&raw_json=1", s.base, url.PathEscape(sub), s.limit)
+	var listing struct {
+		Data struct {
+			Children []struct {
+				Data redditPost `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	if err := r.getJSON(ctx, s, u, &listing); err != nil {
+		return nil, err
+	}
+	posts := make([]post, 0, len(listing.Data.Children))
+	for _, c := range listing.Data.Children {
+		rp := c.Data
+		if rp.Permalink == "" {
+			continue
+		}
+		created := time.Unix(int64(rp.CreatedUTC), 0).UTC()
+		p := post{
+			uri:     "https://www.reddit.com" + rp.Permalink,
+			link:    "https://www.reddit.com" + rp.Permalink,
+			title:   rp.Title,
+			handle:  "r/" + sub,
+			author:  "u/" + rp.Author,
+			text:    rp.Selftext,
+			html:    rp.SelftextHTML,
+			created: &created,
+		}
+		if !rp.IsSelf && rp.URL != "" {
+			p.links = append(p.links, rp.URL)
+			if p.text == "" {
+				p.text = rp.URL
+			}
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
@@ -0,0 +1,334 @@
+// Package social follows security accounts and hashtags on Mastodon and
+// Bluesky, subreddits and Hacker News, where exploitation chatter and
+// community attention often show up before an advisory. A Runner reads
+// the newest posts of each source from the network's public API and stores
+// those naming a CVE ID or one of the source's keywords as lightweight
+// advisories: the items of a feed named after the source, at
+// social:{name}, stored with the feed client so they take the feed path
+// from there. Posts are keyed by their URI, so reading one again on the
+// next poll updates it in place.
+package social
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/cveid"
+	"tiger2go/internal/httpclient"
+	"tiger2go/internal/metrics"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/mmcdole/gofeed"
+)
+
+// Networks.
+const (
+	Mastodon   = "mastodon"
+	Bluesky    = "bluesky"
+	Reddit     = "reddit"
+	HackerNews = "hackernews"
+)
+
+// Default API base URLs.
+const (
+	DefaultMastodon   = "https://mastodon.social"
+	DefaultBluesky    = "https://public.api.bsky.app"
+	DefaultReddit     = "https://www.reddit.com"
+	DefaultHackerNews = "https://hacker-news.firebaseio.com"
+)
+
+// defaultLimit is the number of newest posts read per poll; Mastodon
+// serves at most 40 a page.
+const defaultLimit = 40
+
+// maxLimit bounds limit on the other networks: one page of Bluesky and
+// Reddit, one request per story on Hacker News.
+const maxLimit = 100
+
+// userAgent identifies tigerfetch; Reddit throttles generic agents hard.
+const userAgent = "tigerfetch/1.0 (+https://tigerblue.app)"
+
+// maxResponseBytes bounds one API response unless max_response_mb is set.
+const maxResponseBytes = 4 << 20
+
+// titleRunes bounds the part of a post used as its title.
+const titleRunes = 100
+
+// lineBreaks are the HTML elements posts break lines and paragraphs with.
+var lineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+
+var stripTags = bluemonday.StrictPolicy()
+
+// Store keeps the posts of a poll; *ingestor.Client implements it.
+type Store interface {
+	Store(ctx context.Context, feedCfg config.Feed, feed *gofeed.Feed, withdrawn []string) (int, error)
+}
+
+// FeedURL is the feed URL the posts of source name are stored under.
+func FeedURL(name string) string { return "social:" + name }
+
+// post is a post of any network, reduced to what an advisory needs.
+type post struct {
+	uri     string // stable ID: the ActivityPub or AT URI
+	link    string // web page of the post
+	title   string // Reddit and Hacker News; others are titled by handle and opening
+	handle  string // e.g. "alice@infosec.exchange", "alice.bsky.social"
+	author  string // display name, else handle
+	text    string // plain text
+	html    string // as published; empty on Bluesky
+	links   []string
+	created *time.Time
+}
+
+type source struct {
+	cfg      config.SocialSourceConfig
+	base     string
+	limit    int
+	keywords []string // lower-cased
+	feed     config.Feed
+}
+
+// Runner polls the configured sources.
+type Runner struct {
+	store    Store
+	client   *http.Client
+	maxBytes int64
+	sources  map[string]source
+	names    []string
+
+	mu       sync.Mutex
+	accounts map[string]string // Mastodon account IDs by source name
+}
+
+// NewRunner returns the runner of cfg's sources, fetching with client
+// (nil: a plain client). Every source needs a unique name, a known network
+// and what to follow on it: an account or a hashtag on Mastodon and
+// Bluesky, a subreddit on Reddit.
+func NewRunner(store Store, cfg config.SocialConfig, client *http.Client) (*Runner, error) {
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	r := &Runner{
+		store:    store,
+		client:   client,
+		maxBytes: httpclient.LimitFromMB(cfg.MaxResponseMB, maxResponseBytes),
+		sources:  make(map[string]source, len(cfg.Sources)),
+		accounts: make(map[string]string),
+	}
+	for _, sc := range cfg.Sources {
+		if sc.Name == "" {
+			return nil, errors.New("social source without a name")
+		}
+		if _, dup := r.sources[sc.Name]; dup {
+			return nil, fmt.Errorf("social source %q is configured twice", sc.Name)
+		}
+		s := source{
+			cfg:   sc,
+			limit: min(cmp.Or(max(sc.Limit, 0), defaultLimit), maxLimit),
+			feed:  config.Feed{Name: sc.Name, URL: FeedURL(sc.Name)},
+		}
+		for _, k := range sc.Keywords {
+			if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+				s.keywords = append(s.keywords, k)
+			}
+		}
+		switch sc.Network {
+		case Mastodon, Bluesky:
+			if (sc.Account == "") == (sc.Hashtag == "") {
+				return nil, fmt.Errorf("social source %q needs either an account or a hashtag", sc.Name)
+			}
+			if sc.Network == Mastodon {
+				s.base = cmp.Or(sc.Instance, DefaultMastodon)
+				s.limit = min(s.limit, defaultLimit)
+			} else {
+				s.base = cmp.Or(sc.Instance, DefaultBluesky)
+			}
+		case Reddit:
+			if sc.Subreddit == "" {
+				return nil, fmt.Errorf("social source %q needs a subreddit", sc.Name)
+			}
+			s.base = cmp.Or(sc.Instance, DefaultReddit)
+		case HackerNews:
+			if !slices.Contains([]string{"", "new", "top", "best"}, sc.List) {
+				return nil, fmt.Errorf("social source %q: unknown list %q (want new, top or best)", sc.Name, sc.List)
+			}
+			s.base = cmp.Or(sc.Instance, DefaultHackerNews)
+		default:
+			return nil, fmt.Errorf("social source %q: unknown network %q (want %s, %s, %s or %s)",
+				sc.Name, sc.Network, Mastodon, Bluesky, Reddit, HackerNews)
+		}
+		if u, err := url.Parse(s.base); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("social source %q: invalid instance %q", sc.Name, s.base)
+		}
+		s.base = strings.TrimSuffix(s.base, "/")
+		r.sources[sc.Name] = s
+		r.names = append(r.names, sc.Name)
+	}
+	return r, nil
+}
+
+// Names returns the configured sources, one job each.
+func (r *Runner) Names() []string { return r.names }
+
+// Run reads the newest posts of source name and stores those that name a
+// CVE ID or a keyword.
+func (r *Runner) Run(ctx context.Context, name string) error {
+	s, ok := r.sources[name]
+	if !ok {
+		return fmt.Errorf("social source %q is not configured", name)
+	}
+	var posts []post
+	var err error
+	switch s.cfg.Network {
+	case Mastodon:
+		posts, err = r.mastodon(ctx, s)
+	case Bluesky:
+		posts, err = r.bluesky(ctx, s)
+	case Reddit:
+		posts, err = r.reddit(ctx, s)
+	case HackerNews:
+		posts, err = r.hackerNews(ctx, s)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", s.cfg.Network, name, err)
+	}
+
+	feed := &gofeed.Feed{Title: name, FeedType: "social", FeedVersion: s.cfg.Network}
+	seen := make(map[string]bool, len(posts))
+	for _, p := range posts {
+		if p.uri == "" || seen[p.uri] {
+			continue
+		}
+		seen[p.uri] = true
+		categories := append(cveid.Extract(append([]string{p.title, p.text}, p.links...)...), s.matches(p)...)
+		if len(categories) == 0 {
+			metrics.SocialPosts.WithLabelValues(name, "skipped").Inc()
+			continue
+		}
+		metrics.SocialPosts.WithLabelValues(name, "taken").Inc()
+		feed.Items = append(feed.Items, p.item(categories))
+	}
+	if len(feed.Items) == 0 {
+		return nil
+	}
+	n, err := r.store.Store(ctx, s.feed, feed, nil)
+	if err != nil {
+		return fmt.Errorf("store posts of %s: %w", name, err)
+	}
+	slog.Info("Social posts stored", "source", name, "posts", len(feed.Items), "stored", n)
+	return nil
+}
+
+// matches returns the keywords of s that p names, so a source can watch
+// for products and terms before they have a CVE ID.
+func (s source) matches(p post) []string {
+	if len(s.keywords) == 0 {
+		return nil
+	}
+	text := strings.ToLower(p.title + "\n" + p.text)
+	var found []string
+	for _, k := range s.keywords {
+		if strings.Contains(text, k) {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+// item turns p into a feed item, titled by its author and opening words
+// unless it has a title, with the CVE IDs and keywords it names as
+// categories.
+func (p post) item(categories []string) *gofeed.Item {
+	content := p.html
+	if content == "" {
+		var b strings.Builder
+		for para := range strings.SplitSeq(strings.TrimSpace(p.text), "\n\n") {
+			if para = strings.TrimSpace(para); para != "" {
+				fmt.Fprintf(&b, "<p>%s</p>", strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+			}
+		}
+		content = b.String()
+	}
+	title := p.title
+	if title == "" {
+		title = p.handle + ": " + opening(p.text)
+	}
+	item := &gofeed.Item{
+		GUID:            p.uri,
+		Title:           title,
+		Link:            p.link,
+		Description:     html.EscapeString(p.text),
+		Content:         content,
+		PublishedParsed: p.created,
+		Categories:      categories,
+	}
+	if p.author != "" {
+		item.Authors = []*gofeed.Person{{Name: p.author}}
+	}
+	return item
+}
+
+// opening returns the first line of text, at most titleRunes long.
+func opening(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	line = strings.Join(strings.Fields(line), " ")
+	if utf8.RuneCountInString(line) > titleRunes {
+		line = string([]rune(line)[:titleRunes]) + "…"
+	}
+	return line
+}
+
+// htmlText is the plain text of post HTML, its paragraphs and line breaks
+// kept.
+func htmlText(content string) string {
+	text := html.UnescapeString(stripTags.Sanitize(lineBreaks.ReplaceAllStringFunc(content, func(tag string) string {
+		if strings.EqualFold(tag, "</p>") {
+			return "\n\n"
+		}
+		return "\n"
+	})))
+	return strings.TrimSpace(text)
+}
+
+// getJSON decodes the JSON response to a GET of u into v.
+func (r *Runner) getJSON(ctx context.Context, s source, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", req.URL.Redacted(), resp.StatusCode)
+	}
+	body, err := httpclient.ReadBody(resp, r.maxBytes)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: %w", req.URL.Redacted(), err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiger2go/internal/config"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubStore struct {
+	feedCfg config.Feed
+	feed    *gofeed.Feed
+}
+
+func (s *stubStore) Store(_ context.Context, feedCfg config.Feed, feed *gofeed.Feed, _ []string) (int, error) {
+	s.feedCfg, s.feed = feedCfg, feed
+	return len(feed.Items), nil
+}
+
+func serve(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		body, ok := routes[key]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "login required", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun_Mastodon(t *testing.T) {
+	srv := serve(t, map[string]string{
+		"/api/v1/accounts/lookup?acct=alice%40infosec.exchange": `{"id":"42"}`,
+		"/api/v1/accounts/42/statuses?limit=40&exclude_replies=true": `[
+			{"id":"1","uri":"https://infosec.exchange/users/alice/statuses/1","url":"https://infosec.exchange/@alice/1",
+			 "created_at":"2026-10-01T08:00:00Z","content":"<p>Exploited in the wild: CVE-2026-1234 &amp; more</p><p>Patch now</p>",
+			 "account":{"acct":"alice","display_name":"Alice"}},
+			{"id":"2","uri":"https://infosec.exchange/users/alice/statuses/2","content":"<p>Lunch</p>","account":{"acct":"alice"}},
+			{"id":"3","reblog":{"uri":"https://other.example/statuses/9","url":"https://other.example/@bob/9",
+			 "content":"<p>Writeup</p>","account":{"acct":"bob@other.example"},"card":{"url":"https://nvd.nist.gov/vuln/detail/CVE-2026-5678"}},
+			 "account":{"acct":"alice"}}]`,
+	})
+	store := &stubStore{}
+	r, err := NewRunner(store, config.SocialConfig{Sources: []config.SocialSourceConfig{
+		{Name: "alice", Network: Mastodon, Instance: srv.URL, Account: "@alice@infosec.exchange", Token: "tok"},
+	}}, srv.Client())
+	require.NoError(t, err)
+	require.NoError(t, r.Run(t.Context(), "alice"))
+	require.NoError(t, r.Run(t.Context(), "alice"), "account ID cached")
+
+	assert.Equal(t, config.Feed{Name: "alice", URL: "social:alice"}, store.feedCfg)
+	require.Len(t, store.feed.Items, 2, "the post without a CVE ID is skipped")
+	item := store.feed.Items[0]
+	assert.Equal(t, "https://infosec.exchange/users/alice/statuses/1", item.GUID)
+	assert.Equal(t, "alice: Exploited in the wild: CVE-2026-1234 & more", item.Title)
+	assert.Equal(t, "https://infosec.exchange/@alice/1", item.Link)
+	assert.Equal(t, "Exploited in the wild: CVE-2026-1234 &amp; more\n\nPatch now", item.Description)
+	assert.Equal(t, "Alice", item.Authors[0].Name)
+	assert.Equal(t, []string{"CVE-2026-1234"}, item.Categories)
+	require.NotNil(t, item.PublishedParsed)
+
+	boost := store.feed.Items[1]
+	assert.Equal(t, "https://other.example/statuses/9", boost.GUID)
+	assert.Equal(t, "bob@other.example", boost.Authors[0].Name)
+	assert.Equal(t, []string{"CVE-2026-5678"}, boost.Categories)
+}
+
+func TestRun_Bluesky(t *testing.T) {
+	post := `{"uri":"at://did:plc:abc/app.bsky.feed.post/3kxyz","author":{"did":"did:plc:abc","handle":"carol.bsky.social"},
+		"record":{"text":"New advisory\n\nnvd.nist.gov/vuln/detail/CVE-2026-...","createdAt":"2026-10-02T09:00:00Z",
+		"facets":[{"features":[{"uri":"https://nvd.nist.gov/vuln/detail/CVE-2026-9999"}]},{"features":[{"did":"did:plc:x"}]}]}}`
+	srv := serve(t, map[string]string{
+		"/xrpc/app.bsky.feed.getAuthorFeed?actor=carol.bsky.social&filter=posts_no_replies&limit=40": `{"feed":[{"post":` + post + `}]}`,
+		"/xrpc/app.bsky.feed.searchPosts?limit=10&q=%23cve&sort=latest":                              `{"posts":[` + post + `]}`,
+	})
+	store := &stubStore{}
+	r, err := NewRunner(store, config.SocialConfig{Sources: []config.SocialSourceConfig{
+		{Name: "carol", Network: Bluesky, Instance: srv.URL + "/", Account: "carol.bsky.social", Token: "tok"},
+		{Name: "tag", Network: Bluesky, Instance: srv.URL, Hashtag: "#cve", Limit: 10, Token: "tok"},
+	}}, srv.Client())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol", "tag"}, r.Names())
+
+	for _, name := range r.Names() {
+		store.feed = nil
+		require.NoError(t, r.Run(t.Context(), name), name)
+		require.Len(t, store.feed.Items, 1, name)
+		item := store.feed.Items[0]
+		assert.Equal(t, "at://did:plc:abc/app.bsky.feed.post/3kxyz", item.GUID)
+		assert.Equal(t, "https://bsky.app/profile/carol.bsky.social/post/3kxyz", item.Link)
+		assert.Equal(t, "carol.bsky.social: New advisory", item.Title)
+		assert.Equal(t, "<p>New advisory</p><p>nvd.nist.gov/vuln/detail/CVE-2026-...</p>", item.Content)
+		assert.Equal(t, []string{"CVE-2026-9999"}, item.Categories, "from the link facet")
+	}
+}
+
+func TestRun_Reddit(t *testing.T) {
+	srv := serve(t, map[string]string{
+		"/r/netsec/new.json?limit=25&raw_json=1": `{"data":{"children":[
+			{"data":{"title":"Exploiting CVE-2026-1111 in FooVPN","url":"https://blog.example/foovpn","is_self":false,
+			 "permalink":"/r/netsec/comments/abc/exploiting/","author":"dave","created_utc":1790000000.0}},
+			{"data":{"title":"Zero-Day in widget","selftext":"Details inside","selftext_html":"<p>Details inside</p>","is_self":true,
+			 "permalink":"/r/netsec/comments/def/zero/","author":"erin","created_utc":1790000100.0}},
+			{"data":{"title":"Weekly hiring thread","is_self":true,"permalink":"/r/netsec/comments/ghi/hiring/","author":"mod"}}]}}`,
+	})
+	store := &stubStore{}
+	r, err := NewRunner(store, config.SocialConfig{Sources: []config.SocialSourceConfig{
+		{Name: "netsec", Network: Reddit, Instance: srv.URL, Subreddit: "r/netsec", Limit: 25, Keywords: []string{"zero-day"}, Token: "tok"},
+	}}, srv.Client())
+	require.NoError(t, err)
+	require.NoError(t, r.Run(t.Context(), "netsec"))
+
+	require.Len(t, store.feed.Items, 2)
+	link := store.feed.Items[0]
+	assert.Equal(t, "https://www.reddit.com/r/netsec/comments/abc/exploiting/", link.GUID)
+	assert.Equal(t, "Exploiting CVE-2026-1111 in FooVPN", link.Title)
+	assert.Equal(t, "u/dave", link.Authors[0].Name)
+	assert.Equal(t, []string{"CVE-2026-1111"}, link.Categories)
+	self := store.feed.Items[1]
+	assert.Equal(t, "<p>Details inside</p>", self.Content)
+	assert.Equal(t, []string{"zero-day"}, self.Categories, "keyword match")
+}
+
+func TestRun_HackerNews(t *testing.T) {
+	srv := serve(t, map[string]string{
+		"/v0/topstories.json": `[3, 2, 1]`,
+		"/v0/item/3.json":     `{"id":3,"type":"story","by":"frank","time":1790000000,"title":"Patch Tuesday fixes CVE-2026-2222","url":"https://news.example/pt"}`,
+		"/v0/item/2.json":     `{"id":2,"type":"story","title":"Show HN: my toaster","text":"<p>It toasts</p>","time":1790000000}`,
+	})
+	store := &stubStore{}
+	r, err := NewRunner(store, config.SocialConfig{Sources: []config.SocialSourceConfig{
+		{Name: "hn", Network: HackerNews, Instance: srv.URL, List: "top", Limit: 2, Token: "tok"},
+	}}, srv.Client())
+	require.NoError(t, err)
+	require.NoError(t, r.Run(t.Context(), "hn"))
+
+	require.Len(t, store.feed.Items, 1)
+	item := store.feed.Items[0]
+	assert.Equal(t, "https://news.ycombinator.com/item?id=3", item.GUID)
+	assert.Equal(t, "Patch Tuesday fixes CVE-2026-2222", item.Title)
+	assert.Equal(t, "frank", item.Authors[0].Name)
+	assert.Equal(t, []string{"CVE-2026-2222"}, item.Categories)
+}
+
+func TestRun_Unauthorized(t *testing.T) {
+	srv := serve(t, map[string]string{"/api/v1/timelines/tag/infosec?limit=40": `[]`})
+	r, err := NewRunner(&stubStore{}, config.SocialConfig{Sources: []config.SocialSourceConfig{
+		{Name: "tag", Network: Mastodon, Instance: srv.URL, Hashtag: "infosec"},
+	}}, srv.Client())
+	require.NoError(t, err)
+	assert.ErrorContains(t, r.Run(t.Context(), "tag"), "HTTP 401")
+	assert.Error(t, r.Run(t.Context(), "gone"))
+}
+
+func TestNewRunner_Config(t *testing.T) {
+	for name, sources := range map[string][]config.SocialSourceConfig{
+		"no name":          {{Network: Mastodon, Account: "a@b"}},
+		"duplicate":        {{Name: "a", Network: Mastodon, Account: "a@b"}, {Name: "a", Network: Bluesky, Account: "a"}},
+		"unknown network":  {{Name: "a", Network: "x", Account: "a"}},
+		"account and tag":  {{Name: "a", Network: Mastodon, Account: "a@b", Hashtag: "cve"}},
+		"neither":          {{Name: "a", Network: Bluesky}},
+		"invalid instance": {{Name: "a", Network: Mastodon, Account: "a@b", Instance: "mastodon.social"}},
+		"no subreddit":     {{Name: "a", Network: Reddit}},
+		"unknown list":     {{Name: "a", Network: HackerNews, List: "ask"}},
+	} {
+		_, err := NewRunner(&stubStore{}, config.SocialConfig{Sources: sources}, nil)
+		assert.Error(t, err, name)
+	}
+}
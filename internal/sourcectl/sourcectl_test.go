@@ -0,0 +1,56 @@
+package sourcectl
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate_NilDB(t *testing.T) {
+	ran := false
+	run := Gate(nil, "nvd", func(context.Context, string) error { ran = true; return nil })
+	require.NoError(t, run(context.Background(), ""))
+	assert.True(t, ran)
+}
+
+// TestSwitches requires a running DB.
+func TestSwitches(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+	cleanup := func() { _, _ = pool.Exec(ctx, "DELETE FROM source_controls WHERE source = 'test-source'") }
+	cleanup()
+	defer cleanup()
+
+	enabled, err := Enabled(ctx, pool, "test-source")
+	require.NoError(t, err)
+	assert.True(t, enabled, "never switched")
+
+	runs := 0
+	run := Gate(pool, "test-source", func(context.Context, string) error { runs++; return nil })
+
+	require.NoError(t, Set(ctx, pool, "test-source", false))
+	require.NoError(t, run(ctx, ""))
+	assert.Zero(t, runs, "skipped while off")
+
+	require.NoError(t, Set(ctx, pool, "test-source", true))
+	require.NoError(t, run(ctx, ""))
+	assert.Equal(t, 1, runs)
+
+	list, err := List(ctx, pool)
+	require.NoError(t, err)
+	assert.True(t, list["test-source"].Enabled)
+	assert.False(t, list["test-source"].UpdatedAt.IsZero())
+}
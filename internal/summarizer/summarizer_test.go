@@ -0,0 +1,194 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cluster"
+	"tiger2go/internal/config"
+	"tiger2go/internal/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent() cluster.Event {
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	return cluster.Event{
+		Title:    "Ivanti Connect Secure exploitation",
+		CVEs:     []string{"CVE-2023-46805", "CVE-2024-21887"},
+		Products: []string{"Ivanti Connect Secure"},
+		Sources:  []string{"CISA", "Volexity"},
+		First:    day,
+		Last:     day.AddDate(0, 0, 2),
+		KEV:      true,
+		CVSS:     9.1,
+		Severity: "CRITICAL",
+		Advisories: []cluster.Advisory{
+			{Source: "CISA", Title: "Ivanti emergency directive", Link: "https://cisa.example/ed-24-01", Date: day.AddDate(0, 0, 2)},
+			{Source: "Volexity", Title: "Active exploitation of Ivanti Connect Secure", Link: "https://volexity.example/ivanti", Date: day},
+		},
+	}
+}
+
+const reply = `Here you go:
+` + "```json" + `
+{"summary": "Ivanti Connect Secure is affected.\n  Two CVEs are chained in active exploitation. Patch now.",
+ "actions": ["Apply the vendor mitigation", " ", "Run the integrity checker"]}
+` + "```"
+
+func TestNew(t *testing.T) {
+	for name, cfg := range map[string]config.SummarizerConfig{
+		"no model":         {Provider: ProviderOpenAI},
+		"unknown provider": {Provider: "claude-cli", Model: "m"},
+		"bad url":          {Provider: ProviderOllama, Model: "m", URL: "file:///tmp/x"},
+		"bad timeout":      {Model: "m", Timeout: "soon"},
+	} {
+		_, err := New(cfg)
+		assert.Error(t, err, name)
+	}
+
+	s, err := New(config.SummarizerConfig{Model: "gpt-4o-mini"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultOpenAIURL, s.(*chatClient).baseURL)
+	s, err = New(config.SummarizerConfig{Provider: "Ollama", Model: "llama3.1"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultOllamaURL, s.(*chatClient).baseURL)
+}
+
+func TestSummarize_OpenAI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		var req struct {
+			Model    string    `json:"model"`
+			Messages []message `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "gpt-4o-mini", req.Model)
+		require.Len(t, req.Messages, 2)
+		assert.Contains(t, req.Messages[1].Content, "CVE-2024-21887")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": reply}}},
+		})
+	}))
+	defer srv.Close()
+
+	s, err := New(config.SummarizerConfig{URL: srv.URL + "/v1/", Model: "gpt-4o-mini", APIKey: "sk-test"})
+	require.NoError(t, err)
+	a, err := s.Summarize(context.Background(), testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "Ivanti Connect Secure is affected. Two CVEs are chained in active exploitation. Patch now.", a.Summary)
+	assert.Equal(t, []string{"Apply the vendor mitigation", "Run the integrity checker"}, a.Actions)
+	assert.Equal(t, "openai:gpt-4o-mini", a.Model)
+}
+
+func TestSummarize_Ollama(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		assert.Empty(t, r.Header.Get("Authorization"))
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, false, req["stream"])
+		assert.Equal(t, "json", req["format"])
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"message": map[string]any{"role": "assistant", "content": `{"summary": "One. Two. Three.", "actions": []}`},
+		})
+	}))
+	defer srv.Close()
+
+	s, err := New(config.SummarizerConfig{Provider: ProviderOllama, URL: srv.URL, Model: "llama3.1"})
+	require.NoError(t, err)
+	a, err := s.Summarize(context.Background(), testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "One. Two. Three.", a.Summary)
+	assert.Empty(t, a.Actions)
+	assert.Equal(t, "ollama:llama3.1", a.Model)
+}
+
+func TestSummarize_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+	}))
+	defer srv.Close()
+
+	s, err := New(config.SummarizerConfig{URL: srv.URL, Model: "m"})
+	require.NoError(t, err)
+	_, err = s.Summarize(context.Background(), testEvent())
+	assert.ErrorContains(t, err, "unexpected status 401")
+
+	for name, content := range map[string]string{
+		"prose":      "I cannot help with that.",
+		"no summary": `{"actions": ["x"]}`,
+		"broken":     `{"summary": "unterminated}`,
+	} {
+		_, err := Parse(content)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	e := testEvent()
+	for i := range 30 {
+		e.Advisories = append(e.Advisories, cluster.Advisory{Source: "Blog", Title: "Post " + strings.Repeat("x", i)})
+	}
+	p := Prompt(e)
+	assert.Contains(t, p, "Event: Ivanti Connect Secure exploitation – 32 advisories from 2 sources")
+	assert.Contains(t, p, "Highest CVSS: 9.1 CRITICAL")
+	assert.Contains(t, p, "In CISA KEV (known exploited): true")
+	assert.Contains(t, p, "- 2024-01-12 | CISA | Ivanti emergency directive")
+	assert.Contains(t, p, "... and 12 more")
+}
+
+type fakeSummarizer struct {
+	calls int
+	err   error
+}
+
+func (f *fakeSummarizer) Summarize(_ context.Context, e cluster.Event) (cluster.Analysis, error) {
+	f.calls++
+	if f.err != nil {
+		return cluster.Analysis{}, f.err
+	}
+	return cluster.Analysis{Summary: "Note on " + e.Title + ".", Actions: []string{"Patch"}, Model: "fake:1"}, nil
+}
+
+func TestAnnotate_Integration(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	events := []cluster.Event{testEvent()}
+	key := events[0].Key()
+	cleanup := func() { _, _ = pool.Exec(ctx, "DELETE FROM event_summaries WHERE event_key = $1", key) }
+	cleanup()
+	defer cleanup()
+
+	failing := &fakeSummarizer{err: errors.New("model down")}
+	require.NoError(t, NewAnnotator(pool, failing).Annotate(ctx, events), "model errors are not fatal")
+	assert.Nil(t, events[0].Analysis)
+
+	fake := &fakeSummarizer{}
+	require.NoError(t, NewAnnotator(pool, fake).Annotate(ctx, events))
+	require.NotNil(t, events[0].Analysis)
+	assert.Equal(t, "Note on Ivanti Connect Secure exploitation.", events[0].Analysis.Summary)
+
+	again := []cluster.Event{testEvent()}
+	require.NoError(t, NewAnnotator(pool, fake).Annotate(ctx, again))
+	assert.Equal(t, 1, fake.calls, "stored note reused")
+	assert.Equal(t, *events[0].Analysis, *again[0].Analysis)
+}
@@ -0,0 +1,407 @@
+// Package summary builds the digest printed by `tigerfetch summary`: CVEs
+// newly added to KEV, the biggest EPSS risers, CVEs that turned critical and
+// events (related advisories from several sources) within a look-back
+// window. The [summary] config picks the sections, the critical thresholds,
+// the number of items and their order.
+package summary
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"tiger2go/internal/actors"
+	"tiger2go/internal/cluster"
+	"tiger2go/internal/config"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+	"tiger2go/internal/fixes"
+	"tiger2go/internal/query"
+	"tiger2go/internal/window"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Section names, as used in [summary] sections.
+const (
+	SectionKEV        = "kev"
+	SectionEPSSMovers = "epss_movers"
+	SectionCritical   = "critical"
+	SectionEvents     = "events"
+)
+
+// Sort orders, as used in [summary] sort.
+const (
+	SortRisk = "risk" // KEV first, then known ransomware use, then end of life, then public exploit, then EPSS, then CVSS, then patch available
+	SortEPSS = "epss"
+	SortCVSS = "cvss"
+	SortDate = "date" // newest first
+)
+
+// Sections lists every section in default order.
+var Sections = []string{SectionKEV, SectionEPSSMovers, SectionCritical, SectionEvents}
+
+var sectionTitles = map[string]string{
+	SectionKEV:        "New in CISA KEV",
+	SectionEPSSMovers: "EPSS movers",
+	SectionCritical:   "Newly critical",
+	SectionEvents:     "Events",
+}
+
+// candidateLimit caps the CVEs a section considers before ranking.
+const candidateLimit = 1000
+
+// Options are the resolved [summary] settings.
+type Options struct {
+	Window       time.Duration
+	Sections     []string
+	MaxItems     int
+	Sort         string
+	CriticalCVSS float64
+	CriticalEPSS float64
+	MinEPSSDelta float64
+	Events       cluster.Options
+	Feeds        []config.Feed      // feed tags for the events section's advisories
+	Actors       *actors.Dictionary // actor tags for the events section's advisories
+	Annotator    Annotator          // optional analyst notes for listed events
+}
+
+// Annotator attaches an Analysis to events; summarizer.Annotator is the
+// LLM-backed one.
+type Annotator interface {
+	Annotate(ctx context.Context, events []cluster.Event) error
+}
+
+// NewOptions applies defaults to cfg and validates it.
+func NewOptions(cfg config.SummaryConfig) (Options, error) {
+	lookback, err := cfg.GetWindow()
+	if err != nil || lookback <= 0 {
+		return Options{}, fmt.Errorf("invalid summary window %q", cfg.Window)
+	}
+	eventWindow, err := cfg.GetEventWindow()
+	if err != nil || eventWindow <= 0 {
+		return Options{}, fmt.Errorf("invalid summary event_window %q", cfg.EventWindow)
+	}
+	o := Options{
+		Window:       lookback,
+		Sections:     Sections,
+		MaxItems:     cmp.Or(cfg.MaxItems, 10),
+		Sort:         cmp.Or(cfg.Sort, SortRisk),
+		CriticalCVSS: cmp.Or(cfg.CriticalCVSS, 9.0),
+		CriticalEPSS: cmp.Or(cfg.CriticalEPSS, 0.5),
+		MinEPSSDelta: cmp.Or(cfg.MinEPSSDelta, 0.1),
+		Events: cluster.Options{
+			Window:     eventWindow,
+			MinSources: cmp.Or(cfg.EventMinSources, cluster.DefaultMinSources),
+		},
+	}
+	if len(cfg.Sections) > 0 {
+		o.Sections = nil
+		for _, s := range cfg.Sections {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !slices.Contains(Sections, s) {
+				return Options{}, fmt.Errorf("unknown summary section %q (available: %s)", s, strings.Join(Sections, ", "))
+			}
+			if !slices.Contains(o.Sections, s) {
+				o.Sections = append(o.Sections, s)
+			}
+		}
+	}
+	switch o.Sort {
+	case SortRisk, SortEPSS, SortCVSS, SortDate:
+	default:
+		return Options{}, fmt.Errorf("unknown summary sort %q: want risk, epss, cvss or date", o.Sort)
+	}
+	if o.MaxItems < 0 {
+		return Options{}, fmt.Errorf("invalid summary max_items %d", o.MaxItems)
+	}
+	if o.Events.MinSources < 0 {
+		return Options{}, fmt.Errorf("invalid summary event_min_sources %d", o.Events.MinSources)
+	}
+	return o, nil
+}
+
+// Item is one CVE in a section.
+type Item struct {
+	CveID          string          `json:"cve_id"`
+	Title          string          `json:"title,omitempty"` // KEV vulnerability name
+	CVSS           *float64        `json:"cvss,omitempty"`
+	Severity       string          `json:"severity,omitempty"`
+	EPSS           *float64        `json:"epss,omitempty"`
+	EPSSDelta      *float64        `json:"epss_delta,omitempty"` // rise over the window; epss_movers only
+	InKEV          bool            `json:"in_kev"`
+	Ransomware     bool            `json:"ransomware"`                // KEV knows of ransomware campaigns using it
+	ExploitRef     bool            `json:"exploit_ref"`               // NVD lists a reference tagged Exploit
+	PatchURL       string          `json:"patch_url,omitempty"`       // first NVD reference tagged Patch
+	FixedIn        []fixes.FixedIn `json:"fixed_in,omitempty"`        // fixed versions and updates from NVD
+	PatchAvailable bool            `json:"patch_available"`           // a patch link or a fixed version is known
+	EOL            []eol.Affected  `json:"eol,omitempty"`             // affected product releases past end of life
+	RequiredAction string          `json:"required_action,omitempty"` // what to do instead of patching, for EOL releases
+	AdvisoryURL    string          `json:"advisory_url,omitempty"`    // first NVD reference tagged Vendor Advisory
+	AdvisoryLabel  string          `json:"advisory_label,omitempty"`  // its title, else host and advisory ID
+	Date           time.Time       `json:"date"`                      // KEV detection, NVD modification or EPSS score date
+}
+
+// Section is one ranked list. Total counts every qualifying CVE (or event,
+// in the events section), of which at most MaxItems are listed.
+type Section struct {
+	Name   string          `json:"name"`
+	Title  string          `json:"title"`
+	Total  int             `json:"total"`
+	Items  []Item          `json:"items"`
+	Events []cluster.Event `json:"events,omitempty"` // events section only; Items is empty
+}
+
+// Summary is the digest for one window.
+type Summary struct {
+	SchemaVersion string    `json:"schema_version"` // output contract version, set by the caller; see internal/schema
+	Since         time.Time `json:"since"`
+	Sections      []Section `json:"sections"`
+}
+
+// Build queries each configured section and enriches its CVEs through e.
+func Build(ctx context.Context, db *pgxpool.Pool, e *enrich.Enricher, opts Options, now time.Time) (*Summary, error) {
+	since := now.Add(-opts.Window)
+	out := &Summary{Since: since}
+	for _, name := range opts.Sections {
+		if name == SectionEvents {
+			sec, err := events(ctx, db, e, opts, since)
+			if err != nil {
+				return nil, fmt.Errorf("summary section %s: %w", name, err)
+			}
+			out.Sections = append(out.Sections, sec)
+			continue
+		}
+		var items []Item
+		var err error
+		switch name {
+		case SectionKEV:
+			items, err = kevAdditions(ctx, db, since)
+		case SectionEPSSMovers:
+			items, err = epssMovers(ctx, db, opts)
+		case SectionCritical:
+			items, err = newlyCritical(ctx, db, since, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("summary section %s: %w", name, err)
+		}
+		if err := enrichItems(ctx, e, items); err != nil {
+			return nil, fmt.Errorf("summary section %s: %w", name, err)
+		}
+		rank(items, opts.Sort)
+		sec := Section{Name: name, Title: sectionTitles[name], Total: len(items), Items: items}
+		if opts.MaxItems > 0 && len(sec.Items) > opts.MaxItems {
+			sec.Items = sec.Items[:opts.MaxItems]
+		}
+		if sec.Items == nil {
+			sec.Items = []Item{}
+		}
+		out.Sections = append(out.Sections, sec)
+	}
+	return out, nil
+}
+
+// events clusters the advisories first seen in the window.
+func events(ctx context.Context, db *pgxpool.Pool, e *enrich.Enricher, opts Options, since time.Time) (Section, error) {
+	advs, err := query.Load(ctx, db, e, opts.Feeds, opts.Actors, window.Window{Field: window.FirstSeen, Since: since})
+	if err != nil {
+		return Section{}, err
+	}
+	evs := cluster.Group(advs, opts.Events)
+	sec := Section{Name: SectionEvents, Title: sectionTitles[SectionEvents], Total: len(evs), Items: []Item{}, Events: evs}
+	if opts.MaxItems > 0 && len(sec.Events) > opts.MaxItems {
+		sec.Events = sec.Events[:opts.MaxItems]
+	}
+	if opts.Annotator != nil {
+		if err := opts.Annotator.Annotate(ctx, sec.Events); err != nil {
+			if ctx.Err() != nil {
+				return Section{}, err
+			}
+			slog.Warn("Event summaries unavailable", "error", err)
+		}
+	}
+	return sec, nil
+}
+
+func kevAdditions(ctx context.Context, db *pgxpool.Pool, since time.Time) ([]Item, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT ON (cve_id) cve_id, detected_at
+		FROM kev_changes
+		WHERE change_type = 'added' AND detected_at >= $1
+		ORDER BY cve_id, detected_at DESC
+		LIMIT $2
+	`, since, candidateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("query kev_changes: %w", err)
+	}
+	defer rows.Close()
+	var items []Item
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.CveID, &it.Date); err != nil {
+			return nil, fmt.Errorf("scan kev_changes row: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// epssLagDays is the window in whole days (at least one), the distance
+// between the EPSS scores compared for movers and threshold crossings.
+func epssLagDays(window time.Duration) int {
+	return max(1, int((window+23*time.Hour)/(24*time.Hour)))
+}
+
+// epssMovers compares the latest EPSS scores with those from the window's
+// length earlier.
+func epssMovers(ctx context.Context, db *pgxpool.Pool, opts Options) ([]Item, error) {
+	rows, err := db.Query(ctx, `
+		WITH latest AS (
+			SELECT max(as_of) AS as_of FROM epss_daily WHERE as_of >= CURRENT_DATE - 31
+		)
+		SELECT cur.cve_id, (cur.epss - prev.epss)::float8, cur.as_of::timestamptz
+		FROM latest
+		JOIN epss_daily cur ON cur.as_of = latest.as_of
+		JOIN epss_daily prev ON prev.cve_id = cur.cve_id AND prev.as_of = latest.as_of - $1::int
+		WHERE cur.epss - prev.epss >= $2
+		ORDER BY cur.epss - prev.epss DESC
+		LIMIT $3
+	`, epssLagDays(opts.Window), opts.MinEPSSDelta, candidateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("query EPSS movers: %w", err)
+	}
+	defer rows.Close()
+	var items []Item
+	for rows.Next() {
+		var it Item
+		var delta float64
+		if err := rows.Scan(&it.CveID, &delta, &it.Date); err != nil {
+			return nil, fmt.Errorf("scan EPSS mover: %w", err)
+		}
+		it.EPSSDelta = &delta
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// newlyCritical finds CVEs whose NVD record changed within the window with
+// a CVSS score at the threshold, and CVEs whose EPSS score crossed the
+// threshold over the window.
+func newlyCritical(ctx context.Context, db *pgxpool.Pool, since time.Time, opts Options) ([]Item, error) {
+	rows, err := db.Query(ctx, `
+		WITH latest AS (
+			SELECT max(as_of) AS as_of FROM epss_daily WHERE as_of >= CURRENT_DATE - 31
+		)
+		SELECT cve_id, max(changed) FROM (
+			SELECT cve_id, modified AS changed
+			FROM cve_enriched
+			WHERE source = 'NVD' AND modified >= $1 AND cvss_base >= $2
+			UNION ALL
+			SELECT cur.cve_id, cur.as_of::timestamptz
+			FROM latest
+			JOIN epss_daily cur ON cur.as_of = latest.as_of
+			JOIN epss_daily prev ON prev.cve_id = cur.cve_id AND prev.as_of = latest.as_of - $5::int
+			WHERE cur.epss >= $3 AND prev.epss < $3
+		) c
+		GROUP BY cve_id
+		LIMIT $4
+	`, since, opts.CriticalCVSS, opts.CriticalEPSS, candidateLimit, epssLagDays(opts.Window))
+	if err != nil {
+		return nil, fmt.Errorf("query critical CVEs: %w", err)
+	}
+	defer rows.Close()
+	var items []Item
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.CveID, &it.Date); err != nil {
+			return nil, fmt.Errorf("scan critical CVE: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// enrichItems fills scores, severity and KEV status from the enricher, in
+// batches of its limit.
+func enrichItems(ctx context.Context, e *enrich.Enricher, items []Item) error {
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.CveID
+	}
+	byID := make(map[string]enrich.Result, len(ids))
+	for batch := range slices.Chunk(ids, e.MaxIDs()) {
+		results, err := e.Enrich(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			byID[r.CveID] = r
+		}
+	}
+	for i := range items {
+		items[i].apply(byID[items[i].CveID])
+	}
+	return nil
+}
+
+func (it *Item) apply(r enrich.Result) {
+	if r.NVD != nil {
+		it.CVSS, it.Severity = r.NVD.CvssBase, r.NVD.Severity
+		it.ExploitRef, it.PatchURL = r.NVD.ExploitRef, r.NVD.PatchURL
+		it.FixedIn, it.PatchAvailable = r.NVD.FixedIn, r.NVD.PatchAvailable
+		it.AdvisoryURL, it.AdvisoryLabel = r.NVD.AdvisoryURL, r.NVD.AdvisoryLabel
+	}
+	if r.EPSS != nil {
+		score := r.EPSS.Score
+		it.EPSS = &score
+	}
+	it.InKEV = r.InKEV
+	it.EOL, it.RequiredAction = r.EOL, eol.RequiredAction(r.EOL)
+	if r.KEV != nil {
+		it.Title, it.Ransomware = r.KEV.VulnerabilityName, r.KEV.Ransomware
+	}
+}
+
+// rank orders items by sortBy, breaking ties by CVE ID so output is stable.
+func rank(items []Item, sortBy string) {
+	score := func(v *float64) float64 {
+		if v == nil {
+			return -1
+		}
+		return *v
+	}
+	first := func(a, b bool) int {
+		switch {
+		case a == b:
+			return 0
+		case a:
+			return -1
+		}
+		return 1
+	}
+	slices.SortStableFunc(items, func(a, b Item) int {
+		var c int
+		switch sortBy {
+		case SortEPSS:
+			c = cmp.Compare(score(b.EPSS), score(a.EPSS))
+		case SortCVSS:
+			c = cmp.Compare(score(b.CVSS), score(a.CVSS))
+		case SortDate:
+			c = b.Date.Compare(a.Date)
+		default:
+			c = cmp.Or(first(a.InKEV, b.InKEV),
+				first(a.Ransomware, b.Ransomware),
+				first(len(a.EOL) > 0, len(b.EOL) > 0),
+				first(a.ExploitRef, b.ExploitRef),
+				cmp.Compare(score(b.EPSS), score(a.EPSS)),
+				cmp.Compare(score(b.CVSS), score(a.CVSS)),
+				// Equal risk: what can be fixed today goes first.
+				first(a.PatchAvailable, b.PatchAvailable))
+		}
+		return cmp.Or(c, strings.Compare(a.CveID, b.CveID))
+	})
+}
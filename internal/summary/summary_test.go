@@ -0,0 +1,159 @@
+package summary
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"tiger2go/internal/cluster"
+	"tiger2go/internal/config"
+	"tiger2go/internal/cve"
+	"tiger2go/internal/db"
+	"tiger2go/internal/enrich"
+	"tiger2go/internal/eol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOptions(t *testing.T) {
+	o, err := NewOptions(config.SummaryConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, Options{
+		Window: 24 * time.Hour, Sections: Sections, MaxItems: 10, Sort: SortRisk,
+		CriticalCVSS: 9.0, CriticalEPSS: 0.5, MinEPSSDelta: 0.1,
+		Events: cluster.Options{Window: 72 * time.Hour, MinSources: 2},
+	}, o)
+
+	o, err = NewOptions(config.SummaryConfig{
+		Window: "168h", Sections: []string{"Critical", "kev", "kev"}, MaxItems: 5, Sort: "cvss", CriticalCVSS: 7,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{SectionCritical, SectionKEV}, o.Sections)
+	assert.Equal(t, 7.0, o.CriticalCVSS)
+	assert.Equal(t, SortCVSS, o.Sort)
+
+	for name, cfg := range map[string]config.SummaryConfig{
+		"bad window":       {Window: "daily"},
+		"negative window":  {Window: "-1h"},
+		"unknown section":  {Sections: []string{"watchlist"}},
+		"unknown sort":     {Sort: "alphabetical"},
+		"negative items":   {MaxItems: -1},
+		"bad event window": {EventWindow: "soon"},
+		"negative sources": {EventMinSources: -1},
+	} {
+		_, err := NewOptions(cfg)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEpssLagDays(t *testing.T) {
+	assert.Equal(t, 1, epssLagDays(time.Hour))
+	assert.Equal(t, 1, epssLagDays(24*time.Hour))
+	assert.Equal(t, 2, epssLagDays(25*time.Hour))
+	assert.Equal(t, 7, epssLagDays(168*time.Hour))
+}
+
+func TestRank(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	items := func() []Item {
+		return []Item{
+			{CveID: "CVE-2024-0001", EPSS: f(0.9), CVSS: f(5.0), Date: day(1)},
+			{CveID: "CVE-2024-0002", EPSS: f(0.2), CVSS: f(9.8), InKEV: true, Date: day(3)},
+			{CveID: "CVE-2024-0003", ExploitRef: true, Date: day(2)},
+			{CveID: "CVE-2024-0004", EPSS: f(0.9), CVSS: f(7.5), Date: day(1)},
+			{CveID: "CVE-2024-0005", EPSS: f(0.1), InKEV: true, Ransomware: true, Date: day(4)},
+			{CveID: "CVE-2024-0006", EPSS: f(0.9), CVSS: f(5.0), PatchAvailable: true, Date: day(1)},
+			{CveID: "CVE-2024-0007", EOL: []eol.Affected{{Product: "Apache Tomcat", Release: "8.5"}}, Date: day(5)},
+		}
+	}
+	ids := func(items []Item) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.CveID)
+		}
+		return out
+	}
+
+	for _, tt := range []struct {
+		sort string
+		want []string
+	}{
+		{SortRisk, []string{"CVE-2024-0005", "CVE-2024-0002", "CVE-2024-0007", "CVE-2024-0003", "CVE-2024-0004", "CVE-2024-0006", "CVE-2024-0001"}},
+		{SortEPSS, []string{"CVE-2024-0001", "CVE-2024-0004", "CVE-2024-0006", "CVE-2024-0002", "CVE-2024-0005", "CVE-2024-0003", "CVE-2024-0007"}},
+		{SortCVSS, []string{"CVE-2024-0002", "CVE-2024-0004", "CVE-2024-0001", "CVE-2024-0006", "CVE-2024-0003", "CVE-2024-0005", "CVE-2024-0007"}},
+		{SortDate, []string{"CVE-2024-0007", "CVE-2024-0005", "CVE-2024-0002", "CVE-2024-0003", "CVE-2024-0001", "CVE-2024-0004", "CVE-2024-0006"}},
+	} {
+		got := items()
+		rank(got, tt.sort)
+		assert.Equal(t, tt.want, ids(got), tt.sort)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	databaseURL, ok := os.LookupEnv("DATABASE_URL")
+	if !ok || databaseURL == "" {
+		t.Skip("DATABASE_URL not set; skipping integration test")
+	}
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(databaseURL, "../../migrations"))
+	pool, err := db.NewPool(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ids := []string{"CVE-TEST-SUMMARY-1", "CVE-TEST-SUMMARY-2"}
+	cleanup := func() {
+		_, _ = pool.Exec(ctx, "DELETE FROM cve_enriched WHERE cve_id = ANY($1)", ids)
+		_, _ = pool.Exec(ctx, "DELETE FROM kev_changes WHERE cve_id = ANY($1)", ids)
+		_, _ = pool.Exec(ctx, "DELETE FROM epss_daily WHERE cve_id = ANY($1)", ids)
+	}
+	cleanup()
+	defer cleanup()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, d := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		require.NoError(t, cve.EnsureEpssPartition(ctx, pool, d))
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO cve_enriched (cve_id, source, json, cvss_base, modified) VALUES
+		  ('CVE-TEST-SUMMARY-1', 'NVD', '{}', 9.8, now()),
+		  ('CVE-TEST-SUMMARY-2', 'NVD', '{}', 5.0, now())`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO kev_changes (cve_id, change_type, catalog_version, json)
+		VALUES ('CVE-TEST-SUMMARY-2', 'added', '2100.01.01', '{"cveID":"CVE-TEST-SUMMARY-2"}')`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `
+		INSERT INTO epss_daily (as_of, cve_id, epss, percentile) VALUES
+		  ($1, 'CVE-TEST-SUMMARY-2', 0.05, 0.5), ($2, 'CVE-TEST-SUMMARY-2', 0.95, 0.99)`,
+		today.AddDate(0, 0, -1), today)
+	require.NoError(t, err)
+
+	opts, err := NewOptions(config.SummaryConfig{MaxItems: 1000})
+	require.NoError(t, err)
+	s, err := Build(ctx, pool, enrich.New(pool), opts, time.Now())
+	require.NoError(t, err)
+	require.Len(t, s.Sections, 4)
+	assert.Equal(t, SectionEvents, s.Sections[3].Name)
+	assert.Empty(t, s.Sections[3].Items)
+
+	find := func(sec Section, id string) *Item {
+		for i := range sec.Items {
+			if sec.Items[i].CveID == id {
+				return &sec.Items[i]
+			}
+		}
+		return nil
+	}
+	kevItem := find(s.Sections[0], "CVE-TEST-SUMMARY-2")
+	require.NotNil(t, kevItem, "KEV addition listed")
+
+	mover := find(s.Sections[1], "CVE-TEST-SUMMARY-2")
+	require.NotNil(t, mover, "EPSS mover listed")
+	assert.InDelta(t, 0.9, *mover.EPSSDelta, 1e-9)
+
+	assert.NotNil(t, find(s.Sections[2], "CVE-TEST-SUMMARY-1"), "CVSS 9.8 is critical")
+	assert.NotNil(t, find(s.Sections[2], "CVE-TEST-SUMMARY-2"), "EPSS crossed 0.5")
+}
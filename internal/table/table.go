@@ -0,0 +1,241 @@
+// Package table renders CLI reports as aligned text tables with selectable
+// columns, truncation to the terminal width and colour by severity.
+package table
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Column is one selectable column of a table.
+type Column[T any] struct {
+	Name  string // --columns key; upper-cased for the header unless Options.Header is set
+	Value func(T) string
+	Flex  bool // free text (titles, names) that is truncated first to fit the width
+	Color bool // coloured by the row's severity
+
+	// Time, in place of Value, is an instant shown in Options.Location as
+	// Layout (default YYYY-MM-DD); the zero time is empty. Calendar dates
+	// such as KEV due dates use Value, so they never shift by a day.
+	Time   func(T) time.Time
+	Layout string
+}
+
+// Table describes the columns a report can show.
+type Table[T any] struct {
+	Columns  []Column[T]
+	Defaults []string       // column names shown when Options.Columns is empty
+	Severity func(T) string // NVD severity of a row, for colour; nil for none
+	Empty    string         // printed instead of a header-only table; "" prints the header
+}
+
+// Options controls one rendering.
+type Options struct {
+	Columns []string // column names in display order; empty for the defaults
+	Width   int      // fit rows in this many characters; 0 for no limit
+	Color   bool     // ANSI colour by severity
+
+	// Header renders a column name as its header, e.g. translated; nil
+	// upper-cases the name.
+	Header func(name string) string
+
+	// Location is the time zone of Time columns; nil for UTC.
+	Location *time.Location
+}
+
+// minFlex is the narrowest a Flex column is truncated to.
+const minFlex = 12
+
+const sep = "  "
+
+// Names lists the column names in definition order.
+func (t Table[T]) Names() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Check reports an unknown column in opts, so a command can reject its
+// flags before doing any work.
+func (t Table[T]) Check(opts Options) error {
+	_, err := t.selected(opts.Columns)
+	return err
+}
+
+// selected resolves opts.Columns (or the defaults) to columns.
+func (t Table[T]) selected(names []string) ([]Column[T], error) {
+	if len(names) == 0 {
+		names = t.Defaults
+	}
+	cols := make([]Column[T], 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(t.Columns, func(c Column[T]) bool { return c.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(t.Names(), ", "))
+		}
+		cols = append(cols, t.Columns[i])
+	}
+	return cols, nil
+}
+
+// Write renders rows to w.
+func (t Table[T]) Write(w io.Writer, rows []T, opts Options) error {
+	cols, err := t.selected(opts.Columns)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 && t.Empty != "" {
+		_, err := fmt.Fprintln(w, t.Empty)
+		return err
+	}
+
+	header := make([]string, len(cols))
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		header[i] = strings.ToUpper(c.Name)
+		if opts.Header != nil {
+			header[i] = opts.Header(c.Name)
+		}
+		widths[i] = utf8.RuneCountInString(header[i])
+	}
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		cells[r] = make([]string, len(cols))
+		for i, c := range cols {
+			v := clean(c.cell(row, opts.Location))
+			if v == "" {
+				v = "-"
+			}
+			cells[r][i] = v
+			widths[i] = max(widths[i], utf8.RuneCountInString(v))
+		}
+	}
+	if opts.Width > 0 {
+		fit(cols, header, widths, opts.Width)
+	}
+
+	var b strings.Builder
+	writeRow(&b, header, widths, nil)
+	for r, row := range rows {
+		var color []string
+		if opts.Color && t.Severity != nil {
+			if code := severityColor(t.Severity(row)); code != "" {
+				color = make([]string, len(cols))
+				for i, c := range cols {
+					if c.Color {
+						color[i] = code
+					}
+				}
+			}
+		}
+		writeRow(&b, cells[r], widths, color)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// cell is the text of c for row.
+func (c Column[T]) cell(row T, loc *time.Location) string {
+	if c.Time == nil {
+		return c.Value(row)
+	}
+	t := c.Time(row)
+	if t.IsZero() {
+		return ""
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout := c.Layout
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	return t.In(loc).Format(layout)
+}
+
+// fit narrows columns until a row fits in width: Flex columns first (widest
+// first, down to minFlex), then any column down to its header. A table that
+// still does not fit overflows.
+func fit[T any](cols []Column[T], header []string, widths []int, width int) {
+	total := func() int {
+		n := len(sep) * (len(widths) - 1)
+		for _, w := range widths {
+			n += w
+		}
+		return n
+	}
+	shrink := func(floor func(i int) int, eligible func(i int) bool) {
+		for total() > width {
+			widest := -1
+			for i := range widths {
+				if eligible(i) && widths[i] > floor(i) && (widest < 0 || widths[i] > widths[widest]) {
+					widest = i
+				}
+			}
+			if widest < 0 {
+				return
+			}
+			widths[widest]--
+		}
+	}
+	floor := func(i int) int {
+		if cols[i].Flex {
+			return max(minFlex, utf8.RuneCountInString(header[i]))
+		}
+		return utf8.RuneCountInString(header[i])
+	}
+	shrink(floor, func(i int) bool { return cols[i].Flex })
+	shrink(floor, func(int) bool { return true })
+}
+
+func writeRow(b *strings.Builder, cells []string, widths []int, color []string) {
+	for i, v := range cells {
+		v = truncate(v, widths[i])
+		pad := widths[i] - utf8.RuneCountInString(v)
+		if color != nil && color[i] != "" {
+			v = color[i] + v + reset
+		}
+		b.WriteString(v)
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", pad))
+			b.WriteString(sep)
+		}
+	}
+	b.WriteByte('\n')
+}
+
+// truncate cuts s to n characters, ending in "…" when shortened.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string([]rune(s)[:n])
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// clean flattens whitespace so a cell stays on one line.
+func clean(s string) string {
+	if !strings.ContainsAny(s, "\t\r\n") {
+		return s
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Split parses a --columns value: comma-separated, case-insensitive names.
+func Split(s string) []string {
+	var names []string
+	for part := range strings.SplitSeq(s, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			names = append(names, part)
+		}
+	}
+	return names
+}
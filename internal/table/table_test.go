@@ -0,0 +1,146 @@
+package table
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type vuln struct {
+	id, title, severity string
+}
+
+var vulnTable = Table[vuln]{
+	Columns: []Column[vuln]{
+		{Name: "cve", Value: func(v vuln) string { return v.id }},
+		{Name: "severity", Value: func(v vuln) string { return v.severity }, Color: true},
+		{Name: "title", Value: func(v vuln) string { return v.title }, Flex: true},
+	},
+	Defaults: []string{"cve", "title"},
+	Severity: func(v vuln) string { return v.severity },
+	Empty:    "No CVEs.",
+}
+
+var vulns = []vuln{
+	{"CVE-2024-21887", "Ivanti Connect Secure command injection in web components", "CRITICAL"},
+	{"CVE-2023-46805", "Ivanti authentication bypass", ""},
+}
+
+func render(t *testing.T, rows []vuln, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, vulnTable.Write(&buf, rows, opts))
+	return buf.String()
+}
+
+func TestWrite_Defaults(t *testing.T) {
+	assert.Equal(t, ""+
+		"CVE             TITLE\n"+
+		"CVE-2024-21887  Ivanti Connect Secure command injection in web components\n"+
+		"CVE-2023-46805  Ivanti authentication bypass\n",
+		render(t, vulns, Options{}))
+}
+
+func TestWrite_SelectedColumns(t *testing.T) {
+	assert.Equal(t, ""+
+		"SEVERITY  CVE\n"+
+		"CRITICAL  CVE-2024-21887\n"+
+		"-         CVE-2023-46805\n",
+		render(t, vulns, Options{Columns: Split(" Severity, cve,")}))
+
+	err := vulnTable.Write(&bytes.Buffer{}, vulns, Options{Columns: []string{"epss"}})
+	assert.ErrorContains(t, err, `unknown column "epss" (available: cve, severity, title)`)
+}
+
+func TestWrite_Header(t *testing.T) {
+	header := func(name string) string {
+		if name == "title" {
+			return "TITEL"
+		}
+		return strings.ToUpper(name)
+	}
+	assert.Equal(t, ""+
+		"CVE             TITEL\n"+
+		"CVE-2023-46805  Ivanti authentication bypass\n",
+		render(t, vulns[1:], Options{Header: header}))
+}
+
+func TestWrite_TimeColumns(t *testing.T) {
+	type event struct {
+		id string
+		at time.Time
+	}
+	events := Table[event]{Columns: []Column[event]{
+		{Name: "id", Value: func(e event) string { return e.id }},
+		{Name: "at", Time: func(e event) time.Time { return e.at }, Layout: "2006-01-02 15:04"},
+		{Name: "day", Time: func(e event) time.Time { return e.at }},
+	}, Defaults: []string{"id", "at", "day"}}
+	rows := []event{{"a", time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)}, {"b", time.Time{}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, events.Write(&buf, rows, Options{}))
+	assert.Equal(t, ""+
+		"ID  AT                DAY\n"+
+		"a   2026-03-01 23:30  2026-03-01\n"+
+		"b   -                 -\n", buf.String())
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, events.Write(&buf, rows[:1], Options{Location: tokyo}))
+	assert.Contains(t, buf.String(), "a   2026-03-02 08:30  2026-03-02\n")
+}
+
+func TestWrite_FitsWidth(t *testing.T) {
+	out := render(t, vulns, Options{Width: 40})
+	for line := range strings.Lines(out) {
+		assert.LessOrEqual(t, len([]rune(strings.TrimSuffix(line, "\n"))), 40, line)
+	}
+	assert.Contains(t, out, "CVE-2024-21887  Ivanti Connect Secure c…\n")
+	assert.Contains(t, out, "CVE-2023-46805  Ivanti authentication b…\n")
+
+	// Narrower than the columns allow: flex stops at minFlex, the others at
+	// their header, and the row overflows.
+	out = render(t, vulns, Options{Width: 10})
+	assert.Contains(t, out, "CV…  Ivanti Conn…\n")
+}
+
+func TestWrite_Color(t *testing.T) {
+	out := render(t, vulns, Options{Columns: []string{"cve", "severity"}, Color: true})
+	assert.Contains(t, out, "CVE-2024-21887  \x1b[1;31mCRITICAL\x1b[0m\n")
+	assert.Contains(t, out, "CVE-2023-46805  -\n", "rows without a severity stay plain")
+}
+
+func TestWrite_Empty(t *testing.T) {
+	assert.Equal(t, "No CVEs.\n", render(t, nil, Options{}))
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	for _, tt := range []struct {
+		mode     string
+		terminal bool
+		want     bool
+	}{
+		{"auto", true, true},
+		{"auto", false, false},
+		{"always", false, true},
+		{"never", true, false},
+	} {
+		got, err := UseColor(tt.mode, tt.terminal)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s terminal=%v", tt.mode, tt.terminal)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	got, _ := UseColor("auto", true)
+	assert.False(t, got)
+
+	_, err := UseColor("sometimes", true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package table
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const reset = "\x1b[0m"
+
+// severityColor is the ANSI colour for an NVD severity.
+func severityColor(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return "\x1b[1;31m" // bold red
+	case "HIGH":
+		return "\x1b[31m"
+	case "MEDIUM":
+		return "\x1b[33m"
+	case "LOW":
+		return "\x1b[32m"
+	}
+	return ""
+}
+
+// Terminal reports whether f is a terminal and, if so, its width in columns
+// (0 when unknown). $COLUMNS overrides the detected width.
+func Terminal(f *os.File) (bool, int) {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, 0
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return true, n
+	}
+	return true, terminalWidth(f)
+}
+
+// UseColor resolves a --color mode ("auto", "always" or "never"). auto
+// colours terminal output unless NO_COLOR is set or TERM is "dumb".
+func UseColor(mode string, terminal bool) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto", "":
+		return terminal && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb", nil
+	}
+	return false, fmt.Errorf("invalid color mode %q: want auto, always or never", mode)
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package table
+
+import "os"
+
+func terminalWidth(*os.File) int { return 0 }
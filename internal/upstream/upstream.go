@@ -0,0 +1,270 @@
+// Package upstream measures the requests tigerfetch makes, per upstream
+// host: how many, their status codes and how long each took to answer.
+// Every request is exported as metrics; a run also keeps its own Stats,
+// listed in the run summary and checked against the [run.slo] error rate
+// and latency thresholds there.
+package upstream
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tiger2go/internal/config"
+	"tiger2go/internal/metrics"
+)
+
+// maxSamples bounds the latencies kept per host for percentiles; past it
+// they are a uniform sample, so a long-running daemon stays bounded.
+const maxSamples = 1024
+
+// DefaultMinRequests is the traffic a host needs in a run before its SLO
+// is judged.
+const DefaultMinRequests = 10
+
+// Host is what a run asked of one upstream host.
+type Host struct {
+	Host     string           `json:"host"`
+	Requests int64            `json:"requests"`
+	Failed   int64            `json:"failed"` // no response, 429 or 5xx
+	Status   map[string]int64 `json:"status"` // by class: 2xx, 3xx, 4xx, 5xx, error
+	P50      float64          `json:"p50_seconds"`
+	P95      float64          `json:"p95_seconds"`
+	P99      float64          `json:"p99_seconds"`
+}
+
+// ErrorPercent is the share of requests that failed.
+func (h Host) ErrorPercent() float64 {
+	if h.Requests == 0 {
+		return 0
+	}
+	return 100 * float64(h.Failed) / float64(h.Requests)
+}
+
+// Stats counts requests per host. A nil *Stats counts nothing, so
+// components can take one unconditionally.
+type Stats struct {
+	mu    sync.Mutex
+	hosts map[string]*hostStats
+}
+
+type hostStats struct {
+	requests, failed int64
+	status           map[string]int64
+	seen             int64     // latencies observed, for sampling
+	samples          []float64 // seconds
+}
+
+// New returns empty Stats.
+func New() *Stats {
+	return &Stats{hosts: map[string]*hostStats{}}
+}
+
+// Transport measures requests through next (http.DefaultTransport when
+// nil), from sending to the response headers. A transport that already
+// measures into s, directly or under wrappers with an Unwrap method, is
+// returned unchanged so requests are not counted twice. A nil Stats
+// returns next as is.
+func (s *Stats) Transport(next http.RoundTripper) http.RoundTripper {
+	if s == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for rt := next; rt != nil; {
+		if t, ok := rt.(*transport); ok && t.stats == s {
+			return next
+		}
+		u, ok := rt.(interface{ Unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		rt = u.Unwrap()
+	}
+	return &transport{stats: s, next: next}
+}
+
+type transport struct {
+	stats *Stats
+	next  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	t.stats.observe(strings.ToLower(req.URL.Hostname()), status, time.Since(start))
+	return resp, err
+}
+
+// Unwrap returns the wrapped transport.
+func (t *transport) Unwrap() http.RoundTripper { return t.next }
+
+// observe records one request; status 0 is one that got no response.
+func (s *Stats) observe(host string, status int, d time.Duration) {
+	class := "error"
+	if status > 0 {
+		class = strconv.Itoa(status/100) + "xx"
+	}
+	metrics.UpstreamHostRequests.WithLabelValues(host, class).Inc()
+	metrics.UpstreamHostLatency.WithLabelValues(host).Observe(d.Seconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostStats{status: map[string]int64{}}
+		s.hosts[host] = h
+	}
+	h.requests++
+	h.status[class]++
+	if status == 0 || status == http.StatusTooManyRequests || status >= 500 {
+		h.failed++
+	}
+	h.seen++
+	if len(h.samples) < maxSamples {
+		h.samples = append(h.samples, d.Seconds())
+	} else if i := rand.Int64N(h.seen); i < maxSamples {
+		h.samples[i] = d.Seconds()
+	}
+}
+
+// Hosts returns the requests so far per host, most requested first.
+func (s *Stats) Hosts() []Host {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Host, 0, len(s.hosts))
+	for name, h := range s.hosts {
+		sorted := slices.Sorted(slices.Values(h.samples))
+		out = append(out, Host{
+			Host: name, Requests: h.requests, Failed: h.failed, Status: maps.Clone(h.status),
+			P50: percentile(sorted, 50), P95: percentile(sorted, 95), P99: percentile(sorted, 99),
+		})
+	}
+	slices.SortFunc(out, func(a, b Host) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), strings.Compare(a.Host, b.Host))
+	})
+	return out
+}
+
+// percentile is the nearest-rank p-th percentile of sorted, rounded to
+// the millisecond.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := max(int(math.Ceil(p/100*float64(len(sorted))))-1, 0)
+	return math.Round(sorted[i]*1000) / 1000
+}
+
+// Thresholds are the SLO of a host; zero values are not checked.
+type Thresholds struct {
+	MaxErrorPercent float64
+	MaxP95          time.Duration
+}
+
+// SLO holds the default Thresholds and those of named hosts.
+type SLO struct {
+	Default     Thresholds
+	Hosts       map[string]Thresholds // by lowercase host; ".example.com" matches subdomains
+	MinRequests int
+}
+
+// FromConfig reads the [run.slo] section. A host entry's unset
+// thresholds are the defaults; negative ones turn a check off.
+func FromConfig(cfg config.RunSLOConfig) (SLO, error) {
+	slo := SLO{Hosts: map[string]Thresholds{}, MinRequests: cfg.MinRequests}
+	if slo.MinRequests <= 0 {
+		slo.MinRequests = DefaultMinRequests
+	}
+	var err error
+	if slo.Default, err = thresholds(Thresholds{}, "run.slo", cfg.MaxErrorPercent, cfg.MaxP95Latency); err != nil {
+		return slo, err
+	}
+	for _, h := range cfg.Hosts {
+		name := strings.ToLower(strings.TrimSpace(h.Host))
+		if name == "" {
+			return slo, fmt.Errorf("run.slo.hosts: an entry has no host")
+		}
+		if slo.Hosts[name], err = thresholds(slo.Default, "run.slo.hosts "+name, h.MaxErrorPercent, h.MaxP95Latency); err != nil {
+			return slo, err
+		}
+	}
+	return slo, nil
+}
+
+func thresholds(def Thresholds, section string, errPct float64, p95 string) (Thresholds, error) {
+	t := def
+	if errPct > 100 {
+		return t, fmt.Errorf("%s max_error_percent must be at most 100, got %g", section, errPct)
+	}
+	if errPct != 0 {
+		t.MaxErrorPercent = max(errPct, 0)
+	}
+	if p95 != "" {
+		d, err := time.ParseDuration(p95)
+		if err != nil {
+			return t, fmt.Errorf("%s max_p95_latency %q: %w", section, p95, err)
+		}
+		t.MaxP95 = max(d, 0)
+	}
+	return t, nil
+}
+
+// For returns the Thresholds of host: its own, else those of the longest
+// matching ".domain" entry, else the defaults.
+func (s SLO) For(host string) Thresholds {
+	host = strings.ToLower(host)
+	if t, ok := s.Hosts[host]; ok {
+		return t
+	}
+	best, t := 0, s.Default
+	for name, ht := range s.Hosts {
+		if strings.HasPrefix(name, ".") && (strings.HasSuffix(host, name) || host == name[1:]) && len(name) > best {
+			best, t = len(name), ht
+		}
+	}
+	return t
+}
+
+// Breach is a host that missed its SLO in a run.
+type Breach struct {
+	Host      string  `json:"host"`
+	SLO       string  `json:"slo"`       // error_rate or p95_latency
+	Value     float64 `json:"value"`     // percent or seconds
+	Threshold float64 `json:"threshold"` // likewise
+}
+
+// Check returns the hosts in hosts that breached slo, skipping those
+// with fewer than its MinRequests requests.
+func (s SLO) Check(hosts []Host) []Breach {
+	var out []Breach
+	for _, h := range hosts {
+		if h.Requests < int64(s.MinRequests) {
+			continue
+		}
+		t := s.For(h.Host)
+		if pct := h.ErrorPercent(); t.MaxErrorPercent > 0 && pct > t.MaxErrorPercent {
+			out = append(out, Breach{Host: h.Host, SLO: "error_rate", Value: math.Round(pct*10) / 10, Threshold: t.MaxErrorPercent})
+		}
+		if t.MaxP95 > 0 && h.P95 > t.MaxP95.Seconds() {
+			out = append(out, Breach{Host: h.Host, SLO: "p95_latency", Value: h.P95, Threshold: t.MaxP95.Seconds()})
+		}
+	}
+	return out
+}
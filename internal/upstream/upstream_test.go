@@ -0,0 +1,143 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiger2go/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wrapper stands for another transport layer, like the run budget.
+type wrapper struct{ next http.RoundTripper }
+
+func (w wrapper) RoundTrip(req *http.Request) (*http.Response, error) { return w.next.RoundTrip(req) }
+func (w wrapper) Unwrap() http.RoundTripper                           { return w.next }
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s := New()
+	client := &http.Client{Transport: s.Transport(nil)}
+	for _, path := range []string{"/", "/", "/busy", "/broken", "/missing"} {
+		resp, err := client.Get(srv.URL + path)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	_, err := client.Get("http://127.0.0.1:1/")
+	require.Error(t, err)
+
+	hosts := s.Hosts()
+	require.Len(t, hosts, 1, "both servers are 127.0.0.1")
+	h := hosts[0]
+	assert.Equal(t, "127.0.0.1", h.Host)
+	assert.EqualValues(t, 6, h.Requests)
+	assert.EqualValues(t, 3, h.Failed, "429, 5xx and no response; a 404 is an answer")
+	assert.Equal(t, map[string]int64{"2xx": 2, "4xx": 2, "5xx": 1, "error": 1}, h.Status)
+	assert.InDelta(t, 50, h.ErrorPercent(), 0.01)
+	assert.LessOrEqual(t, h.P50, h.P95)
+	assert.LessOrEqual(t, h.P95, h.P99)
+}
+
+func TestTransport_CountsOnce(t *testing.T) {
+	s := New()
+	inner := s.Transport(http.DefaultTransport)
+	assert.Same(t, inner, s.Transport(inner))
+	outer := wrapper{inner}
+	assert.Equal(t, outer, s.Transport(outer), "measured under another layer")
+	assert.NotEqual(t, outer, New().Transport(outer), "other Stats measure again")
+}
+
+func TestNilStats(t *testing.T) {
+	var s *Stats
+	assert.Equal(t, http.DefaultTransport, s.Transport(http.DefaultTransport))
+	assert.Nil(t, s.Hosts())
+}
+
+func TestHosts_MostRequestedFirst(t *testing.T) {
+	s := New()
+	s.observe("a.example", 200, time.Second)
+	for range 2 {
+		s.observe("b.example", 200, time.Second)
+	}
+	s.observe("c.example", 200, time.Second)
+	var names []string
+	for _, h := range s.Hosts() {
+		names = append(names, h.Host)
+	}
+	assert.Equal(t, []string{"b.example", "a.example", "c.example"}, names)
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []float64
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, float64(i)/1000)
+	}
+	assert.Equal(t, 0.05, percentile(sorted, 50))
+	assert.Equal(t, 0.095, percentile(sorted, 95))
+	assert.Equal(t, 0.1, percentile(sorted, 100))
+	assert.Equal(t, 0.001, percentile(sorted[:1], 99))
+	assert.Zero(t, percentile(nil, 95))
+}
+
+func TestFromConfig(t *testing.T) {
+	slo, err := FromConfig(config.RunSLOConfig{
+		MaxErrorPercent: 5,
+		MaxP95Latency:   "3s",
+		Hosts: []config.HostSLOConfig{
+			{Host: "Services.NVD.nist.gov", MaxP95Latency: "20s"},
+			{Host: ".example.com", MaxErrorPercent: -1},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMinRequests, slo.MinRequests)
+	assert.Equal(t, Thresholds{MaxErrorPercent: 5, MaxP95: 3 * time.Second}, slo.Default)
+	assert.Equal(t, Thresholds{MaxErrorPercent: 5, MaxP95: 20 * time.Second}, slo.For("services.nvd.nist.gov"))
+	assert.Equal(t, Thresholds{MaxP95: 3 * time.Second}, slo.For("feeds.example.com"), "negative turns a check off")
+	assert.Equal(t, Thresholds{MaxP95: 3 * time.Second}, slo.For("example.com"))
+	assert.Equal(t, slo.Default, slo.For("notexample.com"))
+
+	for _, c := range []config.RunSLOConfig{
+		{MaxErrorPercent: 101},
+		{MaxP95Latency: "slow"},
+		{Hosts: []config.HostSLOConfig{{MaxErrorPercent: 1}}},
+		{Hosts: []config.HostSLOConfig{{Host: "a.example", MaxP95Latency: "1 minute"}}},
+	} {
+		_, err := FromConfig(c)
+		assert.Error(t, err, "%+v", c)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	slo := SLO{
+		Default:     Thresholds{MaxErrorPercent: 10, MaxP95: 2 * time.Second},
+		Hosts:       map[string]Thresholds{"slow.example": {MaxErrorPercent: 10}},
+		MinRequests: 10,
+	}
+	breaches := slo.Check([]Host{
+		{Host: "ok.example", Requests: 100, Failed: 10, P95: 2},
+		{Host: "bad.example", Requests: 30, Failed: 4, P95: 2.5},
+		{Host: "slow.example", Requests: 10, P95: 60},
+		{Host: "quiet.example", Requests: 9, Failed: 9, P95: 60},
+	})
+	assert.Equal(t, []Breach{
+		{Host: "bad.example", SLO: "error_rate", Value: 13.3, Threshold: 10},
+		{Host: "bad.example", SLO: "p95_latency", Value: 2.5, Threshold: 2},
+	}, breaches)
+
+	assert.Empty(t, SLO{MinRequests: 1}.Check([]Host{{Host: "a.example", Requests: 5, Failed: 5, P95: 60}}), "no thresholds set")
+}
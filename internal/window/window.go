@@ -0,0 +1,89 @@
+// Package window is the time range behind the --since/--until flags and the
+// since/until API parameters.
+package window
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fields an advisory window can filter on.
+const (
+	Published = "published"  // upstream date; undated items use first_seen
+	FirstSeen = "first_seen" // when tigerfetch first stored the item
+)
+
+// Window selects Since <= t < Until on Field. A zero bound is open.
+type Window struct {
+	Field string
+	Since time.Time
+	Until time.Time
+}
+
+// Parse builds a Window from flag or parameter values; see ParseTime. An
+// empty field means Published.
+func Parse(since, until, field string, now time.Time) (Window, error) {
+	w := Window{Field: field}
+	if w.Field == "" {
+		w.Field = Published
+	}
+	if w.Field != Published && w.Field != FirstSeen {
+		return Window{}, fmt.Errorf("invalid time field %q: want %s or %s", field, Published, FirstSeen)
+	}
+	var err error
+	if w.Since, err = ParseTime(since, now); err != nil {
+		return Window{}, fmt.Errorf("since: %w", err)
+	}
+	if w.Until, err = ParseTime(until, now); err != nil {
+		return Window{}, fmt.Errorf("until: %w", err)
+	}
+	if !w.Since.IsZero() && !w.Until.IsZero() && !w.Since.Before(w.Until) {
+		return Window{}, fmt.Errorf("since (%s) must be before until (%s)",
+			w.Since.Format(time.RFC3339), w.Until.Format(time.RFC3339))
+	}
+	return w, nil
+}
+
+// ParseTime reads one bound: an RFC 3339 timestamp, a YYYY-MM-DD date (UTC
+// midnight), or a Go duration counted back from now ("72h" is three days
+// ago). Empty and zero durations return the zero time, an open bound.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339, YYYY-MM-DD or a duration such as 72h", s)
+	}
+	if d <= 0 {
+		return time.Time{}, nil
+	}
+	return now.Add(-d), nil
+}
+
+// Bounds returns the bounds as query arguments, nil when open.
+func (w Window) Bounds() (since, until *time.Time) {
+	if !w.Since.IsZero() {
+		since = &w.Since
+	}
+	if !w.Until.IsZero() {
+		until = &w.Until
+	}
+	return since, until
+}
+
+// Column is the SQL expression for Field over an advisory row of current or
+// archive. published is a UTC timestamp without zone; undated items fall
+// back to first_seen_at.
+func (w Window) Column() string {
+	if w.Field == FirstSeen {
+		return "first_seen_at"
+	}
+	return "COALESCE(published AT TIME ZONE 'UTC', first_seen_at)"
+}
@@ -0,0 +1,63 @@
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"0", time.Time{}},
+		{"72h", now.Add(-72 * time.Hour)},
+		{"2024-01-01", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-01T08:30:00+01:00", time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		require.NoError(t, err, tt.in)
+		assert.True(t, tt.want.Equal(got), "%s: got %s", tt.in, got)
+	}
+
+	_, err := ParseTime("last week", now)
+	assert.ErrorContains(t, err, `invalid time "last week"`)
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	w, err := Parse("2024-03-01", "24h", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, Published, w.Field)
+	since, until := w.Bounds()
+	require.NotNil(t, since)
+	require.NotNil(t, until)
+	assert.Equal(t, time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), *until)
+
+	w, err = Parse("", "", FirstSeen, now)
+	require.NoError(t, err)
+	since, until = w.Bounds()
+	assert.Nil(t, since)
+	assert.Nil(t, until)
+
+	for name, args := range map[string][3]string{
+		"bad field":       {"", "", "updated"},
+		"bad since":       {"yesterday", "", ""},
+		"bad until":       {"", "soon", ""},
+		"since not first": {"2024-03-02", "2024-03-01", ""},
+	} {
+		_, err := Parse(args[0], args[1], args[2], now)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	assert.Equal(t, "first_seen_at", Window{Field: FirstSeen}.Column())
+	assert.Contains(t, Window{Field: Published}.Column(), "published AT TIME ZONE 'UTC'")
+}
@@ -0,0 +1,31 @@
+-- +goose Up
+-- KEV catalog diffs: one row per CVE that was added to, updated in, or
+-- removed from the CISA KEV catalog between two consecutive releases.
+--
+-- cve_enriched only holds the latest KEV entry per CVE, so "what got added
+-- to KEV today" was not answerable without this table.
+--
+-- changed_fields lists the KevVuln JSON keys that differ for 'updated' rows.
+-- The unique key makes re-processing the same catalog release a no-op.
+
+CREATE TABLE IF NOT EXISTS kev_changes (
+    id                 BIGSERIAL   PRIMARY KEY,
+    cve_id             TEXT        NOT NULL,
+    change_type        TEXT        NOT NULL CHECK (change_type IN ('added', 'updated', 'removed')),
+    catalog_version    TEXT        NOT NULL,
+    date_released      TIMESTAMPTZ,
+    vendor_project     TEXT,
+    product            TEXT,
+    vulnerability_name TEXT,
+    date_added         DATE,
+    changed_fields     TEXT[]      NOT NULL DEFAULT '{}',
+    json               JSONB       NOT NULL,
+    detected_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (cve_id, change_type, catalog_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kev_changes_detected ON kev_changes (detected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_kev_changes_type     ON kev_changes (change_type, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS kev_changes;
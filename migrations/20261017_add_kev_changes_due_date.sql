@@ -0,0 +1,9 @@
+-- +goose Up
+-- Parsed KEV due date alongside date_added. NULL means the catalog entry
+-- had no due date or the value could not be parsed (the raw string is kept
+-- in json->>'dueDate' either way).
+
+ALTER TABLE kev_changes ADD COLUMN IF NOT EXISTS due_date DATE;
+
+-- +goose Down
+ALTER TABLE kev_changes DROP COLUMN IF EXISTS due_date;
@@ -0,0 +1,67 @@
+-- +goose Up
+-- Provenance: where and when each stored record was fetched.
+--   fetch_url         request URL (NVD/EPSS pages include their query)
+--   fetched_at        when the response was received
+--   http_status       upstream status (NULL when not fetched over HTTP)
+--   upstream_version  NVD API version, KEV catalogVersion or feed format
+--   tool_version      tigerfetch build that wrote the row
+--
+-- EPSS rows number ~300k per day and all share one run's provenance, so it
+-- is kept per as_of date in epss_provenance rather than on every row.
+
+ALTER TABLE cve_enriched
+    ADD COLUMN IF NOT EXISTS fetch_url        TEXT,
+    ADD COLUMN IF NOT EXISTS fetched_at       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS http_status      SMALLINT,
+    ADD COLUMN IF NOT EXISTS upstream_version TEXT,
+    ADD COLUMN IF NOT EXISTS tool_version     TEXT;
+
+ALTER TABLE archive
+    ADD COLUMN IF NOT EXISTS fetch_url        TEXT,
+    ADD COLUMN IF NOT EXISTS fetched_at       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS http_status      SMALLINT,
+    ADD COLUMN IF NOT EXISTS upstream_version TEXT,
+    ADD COLUMN IF NOT EXISTS tool_version     TEXT;
+
+ALTER TABLE current
+    ADD COLUMN IF NOT EXISTS fetch_url        TEXT,
+    ADD COLUMN IF NOT EXISTS fetched_at       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS http_status      SMALLINT,
+    ADD COLUMN IF NOT EXISTS upstream_version TEXT,
+    ADD COLUMN IF NOT EXISTS tool_version     TEXT;
+
+CREATE TABLE IF NOT EXISTS epss_provenance (
+    as_of            DATE        PRIMARY KEY,
+    fetch_url        TEXT        NOT NULL,   -- first page URL
+    fetched_at       TIMESTAMPTZ NOT NULL,   -- first page fetch time
+    http_status      SMALLINT,
+    upstream_version TEXT,
+    tool_version     TEXT        NOT NULL,
+    pages            INT         NOT NULL DEFAULT 0,
+    row_count        INT         NOT NULL DEFAULT 0,
+    completed_at     TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS epss_provenance;
+
+ALTER TABLE current
+    DROP COLUMN IF EXISTS fetch_url,
+    DROP COLUMN IF EXISTS fetched_at,
+    DROP COLUMN IF EXISTS http_status,
+    DROP COLUMN IF EXISTS upstream_version,
+    DROP COLUMN IF EXISTS tool_version;
+
+ALTER TABLE archive
+    DROP COLUMN IF EXISTS fetch_url,
+    DROP COLUMN IF EXISTS fetched_at,
+    DROP COLUMN IF EXISTS http_status,
+    DROP COLUMN IF EXISTS upstream_version,
+    DROP COLUMN IF EXISTS tool_version;
+
+ALTER TABLE cve_enriched
+    DROP COLUMN IF EXISTS fetch_url,
+    DROP COLUMN IF EXISTS fetched_at,
+    DROP COLUMN IF EXISTS http_status,
+    DROP COLUMN IF EXISTS upstream_version,
+    DROP COLUMN IF EXISTS tool_version;
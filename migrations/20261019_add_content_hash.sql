@@ -0,0 +1,12 @@
+-- +goose Up
+-- SHA-256 of the stored json as tigerfetch serialised it. Upserts compare it
+-- and skip rows whose content has not changed, so re-fetching an unchanged
+-- NVD window or KEV catalog no longer rewrites every row (dead tuples, WAL).
+-- Existing rows start NULL and are filled in on their next write.
+
+ALTER TABLE cve_enriched
+    ADD COLUMN IF NOT EXISTS content_hash BYTEA;
+
+-- +goose Down
+ALTER TABLE cve_enriched
+    DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,67 @@
+-- +goose Up
+-- Server-side change time for cursor pagination. Upstream timestamps
+-- (cve_enriched.modified is NVD's lastModified, current.entry_updated is the
+-- feed's) can be older than rows already paged past, so a client syncing
+-- by them would miss records that arrive mid-sync. updated_at is set by the
+-- database when a row's content changes and never goes backwards.
+--
+-- clock_timestamp() rather than now() so long transactions stamp rows when
+-- they are written, not when the transaction began. Provenance-only updates
+-- (a re-fetch that changes fetched_at) keep the previous updated_at.
+
+ALTER TABLE cve_enriched
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE current
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_cve_enriched_updated ON cve_enriched (updated_at, cve_id, source);
+CREATE INDEX IF NOT EXISTS idx_current_updated      ON current (updated_at, id);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION cve_enriched_touch() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.json IS DISTINCT FROM OLD.json THEN
+        NEW.updated_at := clock_timestamp();
+    ELSE
+        NEW.updated_at := OLD.updated_at;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION current_touch() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT'
+       OR (NEW.title, NEW.link, NEW.published, NEW.content, NEW.summary, NEW.author,
+           NEW.categories, NEW.entry_updated)
+          IS DISTINCT FROM
+          (OLD.title, OLD.link, OLD.published, OLD.content, OLD.summary, OLD.author,
+           OLD.categories, OLD.entry_updated) THEN
+        NEW.updated_at := clock_timestamp();
+    ELSE
+        NEW.updated_at := OLD.updated_at;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS cve_enriched_touch ON cve_enriched;
+CREATE TRIGGER cve_enriched_touch BEFORE INSERT OR UPDATE ON cve_enriched
+    FOR EACH ROW EXECUTE FUNCTION cve_enriched_touch();
+
+DROP TRIGGER IF EXISTS current_touch ON current;
+CREATE TRIGGER current_touch BEFORE INSERT OR UPDATE ON current
+    FOR EACH ROW EXECUTE FUNCTION current_touch();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS current_touch ON current;
+DROP TRIGGER IF EXISTS cve_enriched_touch ON cve_enriched;
+DROP FUNCTION IF EXISTS current_touch();
+DROP FUNCTION IF EXISTS cve_enriched_touch();
+DROP INDEX IF EXISTS idx_current_updated;
+DROP INDEX IF EXISTS idx_cve_enriched_updated;
+ALTER TABLE current DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE cve_enriched DROP COLUMN IF EXISTS updated_at;
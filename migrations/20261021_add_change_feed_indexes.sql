@@ -0,0 +1,20 @@
+-- +goose Up
+-- Change feed (/changes) support for epss_daily and kev_changes, which are
+-- append-only and so need no trigger: their insert time is their change
+-- time. Defaults move to clock_timestamp() for the same reason as
+-- updated_at: a row must be stamped when it is written, not when its
+-- transaction began, or it could land behind a cursor already handed out.
+-- EPSS loads also stop sending inserted_at, so the tigerfetch host's clock
+-- no longer matters.
+
+ALTER TABLE epss_daily  ALTER COLUMN inserted_at SET DEFAULT clock_timestamp();
+ALTER TABLE kev_changes ALTER COLUMN detected_at SET DEFAULT clock_timestamp();
+
+CREATE INDEX IF NOT EXISTS idx_epss_daily_inserted ON epss_daily (inserted_at, cve_id, as_of);
+CREATE INDEX IF NOT EXISTS idx_kev_changes_feed    ON kev_changes (detected_at, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_kev_changes_feed;
+DROP INDEX IF EXISTS idx_epss_daily_inserted;
+ALTER TABLE kev_changes ALTER COLUMN detected_at SET DEFAULT now();
+ALTER TABLE epss_daily  ALTER COLUMN inserted_at SET DEFAULT now();
@@ -0,0 +1,39 @@
+-- +goose Up
+-- When tigerfetch first stored each advisory and CVE record, kept apart from
+-- the upstream publication date. Feeds backfill old items and NVD publishes
+-- CVEs days after their published date, so "new since the last run" and
+-- publication-to-detection latency need the local observation time. The
+-- column is written on insert only; upserts never touch it.
+--
+-- Existing rows get the best evidence available: inserted_at (a TIMESTAMP
+-- written with NOW(), so in the session time zone) for advisories, and the
+-- earlier of updated_at and fetched_at for CVE records.
+
+ALTER TABLE current      ADD COLUMN IF NOT EXISTS first_seen_at TIMESTAMPTZ;
+ALTER TABLE archive      ADD COLUMN IF NOT EXISTS first_seen_at TIMESTAMPTZ;
+ALTER TABLE cve_enriched ADD COLUMN IF NOT EXISTS first_seen_at TIMESTAMPTZ;
+
+UPDATE current      SET first_seen_at = inserted_at::timestamptz WHERE first_seen_at IS NULL;
+UPDATE archive      SET first_seen_at = inserted_at::timestamptz WHERE first_seen_at IS NULL;
+UPDATE cve_enriched SET first_seen_at = LEAST(updated_at, COALESCE(fetched_at, updated_at))
+    WHERE first_seen_at IS NULL;
+
+ALTER TABLE current
+    ALTER COLUMN first_seen_at SET DEFAULT clock_timestamp(),
+    ALTER COLUMN first_seen_at SET NOT NULL;
+ALTER TABLE archive
+    ALTER COLUMN first_seen_at SET DEFAULT clock_timestamp(),
+    ALTER COLUMN first_seen_at SET NOT NULL;
+ALTER TABLE cve_enriched
+    ALTER COLUMN first_seen_at SET DEFAULT clock_timestamp(),
+    ALTER COLUMN first_seen_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_current_first_seen      ON current (first_seen_at);
+CREATE INDEX IF NOT EXISTS idx_cve_enriched_first_seen ON cve_enriched (first_seen_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_cve_enriched_first_seen;
+DROP INDEX IF EXISTS idx_current_first_seen;
+ALTER TABLE cve_enriched DROP COLUMN IF EXISTS first_seen_at;
+ALTER TABLE archive      DROP COLUMN IF EXISTS first_seen_at;
+ALTER TABLE current      DROP COLUMN IF EXISTS first_seen_at;
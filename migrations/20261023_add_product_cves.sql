@@ -0,0 +1,18 @@
+-- +goose Up
+-- CVEs matched by the NVD product monitor ([[nvd.products]]), one row per
+-- product and CVE. first_matched_at is when the monitor first saw the CVE
+-- match, which may be long after publication: NVD usually adds product
+-- configurations during analysis.
+
+CREATE TABLE IF NOT EXISTS product_cves (
+    product          TEXT        NOT NULL,
+    cve_id           TEXT        NOT NULL,
+    first_matched_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    PRIMARY KEY (product, cve_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_cves_cve ON product_cves (cve_id);
+CREATE INDEX IF NOT EXISTS idx_product_cves_first_matched ON product_cves (first_matched_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS product_cves;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Which CNA issued each NVD CVE (sourceIdentifier) and where NVD's own
+-- analysis stands (vulnStatus), as columns for reporting. They are
+-- generated from the stored record, so the NVD runner, the product monitor
+-- and mirrors fill them without extra writes. KEV rows stay NULL.
+--
+-- Records stored before full NVD objects were kept lack both fields; they
+-- fill in as NVD re-sends them, or at once after resetting the NVD cursor.
+
+ALTER TABLE cve_enriched
+    ADD COLUMN IF NOT EXISTS source_identifier TEXT
+        GENERATED ALWAYS AS (json->>'sourceIdentifier') STORED,
+    ADD COLUMN IF NOT EXISTS vuln_status TEXT
+        GENERATED ALWAYS AS (json->>'vulnStatus') STORED;
+
+CREATE INDEX IF NOT EXISTS idx_cve_enriched_source_identifier
+    ON cve_enriched (source_identifier) WHERE source = 'NVD';
+CREATE INDEX IF NOT EXISTS idx_cve_enriched_vuln_status
+    ON cve_enriched (vuln_status) WHERE source = 'NVD';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_cve_enriched_vuln_status;
+DROP INDEX IF EXISTS idx_cve_enriched_source_identifier;
+ALTER TABLE cve_enriched
+    DROP COLUMN IF EXISTS vuln_status,
+    DROP COLUMN IF EXISTS source_identifier;
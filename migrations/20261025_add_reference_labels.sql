@@ -0,0 +1,17 @@
+-- +goose Up
+-- Human-readable labels for NVD reference URLs, so reports can show
+-- "Cisco Security Advisory: ..." instead of a raw link. The reference label
+-- resolver fills it for references tagged "Vendor Advisory" from each
+-- page's <title>. Failed fetches keep a row with a NULL label and the error,
+-- and are retried after reference_labels.retry_after.
+
+CREATE TABLE IF NOT EXISTS reference_labels (
+    url         TEXT        PRIMARY KEY,
+    label       TEXT,
+    http_status INT,
+    error       TEXT,
+    fetched_at  TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS reference_labels;
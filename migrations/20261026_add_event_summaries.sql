@@ -0,0 +1,18 @@
+-- +goose Up
+-- LLM-written analyst notes for the events of `tigerfetch summary`, keyed by
+-- the event's member advisories. An event that gains an advisory gets a new
+-- key and a new note; unchanged events reuse the stored one instead of
+-- calling the model again.
+
+CREATE TABLE IF NOT EXISTS event_summaries (
+    event_key  TEXT        PRIMARY KEY,
+    title      TEXT        NOT NULL,
+    links      TEXT[]      NOT NULL,
+    summary    TEXT        NOT NULL,
+    actions    JSONB       NOT NULL DEFAULT '[]',
+    model      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_summaries;
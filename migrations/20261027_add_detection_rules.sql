@@ -0,0 +1,20 @@
+-- +goose Up
+-- Public detection content that references a CVE: Sigma rules, Nuclei
+-- templates and Emerging Threats Open signatures. The detection runner
+-- replaces each source's rows after every successful download, so rules
+-- removed upstream disappear here too.
+
+CREATE TABLE IF NOT EXISTS detection_rules (
+    cve_id     TEXT        NOT NULL,
+    source     TEXT        NOT NULL,
+    rule_id    TEXT        NOT NULL,
+    title      TEXT        NOT NULL DEFAULT '',
+    url        TEXT        NOT NULL DEFAULT '',
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    PRIMARY KEY (cve_id, source, rule_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_detection_rules_source ON detection_rules (source);
+
+-- +goose Down
+DROP TABLE IF EXISTS detection_rules;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Release cycles of products on endoflife.date that list a CPE identifier,
+-- keyed by CPE "vendor:product". The end-of-life runner replaces the table
+-- after every successful download.
+
+CREATE TABLE IF NOT EXISTS eol_releases (
+    cpe        TEXT        NOT NULL,
+    product    TEXT        NOT NULL,
+    release    TEXT        NOT NULL,
+    eol_from   DATE,
+    is_eol     BOOLEAN     NOT NULL DEFAULT FALSE,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+    PRIMARY KEY (cpe, product, release)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS eol_releases;
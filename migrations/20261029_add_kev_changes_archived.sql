@@ -0,0 +1,12 @@
+-- +goose Up
+-- kev_changes rows imported from archived catalog releases (tigerfetch
+-- kev-import) rather than detected by the KEV runner. Their detected_at is
+-- the release date, and alerting skips them: they are history, not news.
+
+ALTER TABLE kev_changes ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_kev_changes_archived ON kev_changes (date_released) WHERE archived;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_kev_changes_archived;
+ALTER TABLE kev_changes DROP COLUMN IF EXISTS archived;
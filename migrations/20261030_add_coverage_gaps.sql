@@ -0,0 +1,21 @@
+-- +goose Up
+-- CVEs referenced by advisories that had no EPSS score or no NVD record
+-- when the coverage runner saw them. epss_at and nvd_at record when each
+-- arrived (the detection time if it was already there); a row with either
+-- NULL is an open gap.
+
+CREATE TABLE IF NOT EXISTS coverage_gaps (
+    cve_id              TEXT        PRIMARY KEY,
+    first_referenced_at TIMESTAMPTZ NOT NULL,
+    advisories          INTEGER     NOT NULL DEFAULT 0,
+    detected_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    checked_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    epss_at             TIMESTAMPTZ,
+    nvd_at              TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_coverage_gaps_open ON coverage_gaps (first_referenced_at DESC)
+    WHERE epss_at IS NULL OR nvd_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS coverage_gaps;
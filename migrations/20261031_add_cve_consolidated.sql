@@ -0,0 +1,34 @@
+-- +goose Up
+-- One normalized record per CVE, merged by the consolidation stage from
+-- every cve_enriched source (NVD, CISA-KEV, ...) so API clients do not have
+-- to merge the raw JSON themselves. updated_at changes only when a field
+-- does, and pages GET /cves like cve_enriched.updated_at pages the raw rows.
+
+CREATE TABLE IF NOT EXISTS cve_consolidated (
+    cve_id             TEXT        PRIMARY KEY,
+    sources            TEXT[]      NOT NULL,
+    description        TEXT        NOT NULL DEFAULT '',
+    published          TIMESTAMPTZ,
+    modified           TIMESTAMPTZ NOT NULL,
+    vuln_status        TEXT        NOT NULL DEFAULT '',
+    cna                TEXT        NOT NULL DEFAULT '',
+    cvss_base          NUMERIC,
+    cvss_severity      TEXT        NOT NULL DEFAULT '',
+    cvss_version       TEXT        NOT NULL DEFAULT '',
+    cvss_source        TEXT        NOT NULL DEFAULT '',
+    cwes               TEXT[]      NOT NULL DEFAULT '{}',
+    in_kev             BOOLEAN     NOT NULL DEFAULT false,
+    kev_vendor         TEXT        NOT NULL DEFAULT '',
+    kev_product        TEXT        NOT NULL DEFAULT '',
+    kev_name           TEXT        NOT NULL DEFAULT '',
+    kev_date_added     DATE,
+    kev_due_date       DATE,
+    kev_ransomware     BOOLEAN     NOT NULL DEFAULT false,
+    first_seen_at      TIMESTAMPTZ NOT NULL,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cve_consolidated_updated ON cve_consolidated (updated_at, cve_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS cve_consolidated;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Ingestion work (fetch feed X, poll NVD, load the EPSS scores of day Z)
+-- as discrete jobs. A failed job waits in 'retrying' with exponential
+-- backoff until it succeeds or runs out of attempts and goes 'dead', where
+-- `tigerfetch jobs` shows it with its last error until retried.
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id           BIGSERIAL   PRIMARY KEY,
+    kind         TEXT        NOT NULL,
+    key          TEXT        NOT NULL DEFAULT '',
+    state        TEXT        NOT NULL DEFAULT 'queued'
+                 CHECK (state IN ('queued', 'running', 'retrying', 'succeeded', 'dead')),
+    attempts     INT         NOT NULL DEFAULT 0,
+    max_attempts INT         NOT NULL,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until TIMESTAMPTZ,
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at  TIMESTAMPTZ
+);
+
+-- At most one pending or running job per kind and key, so a poll that
+-- comes round while the previous job is still retrying does not pile up.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_active ON jobs (kind, key)
+    WHERE state IN ('queued', 'running', 'retrying');
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs (run_at, id)
+    WHERE state IN ('queued', 'retrying');
+CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs (state, updated_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS jobs;
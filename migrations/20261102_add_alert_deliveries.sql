@@ -0,0 +1,27 @@
+-- +goose Up
+-- Notification state per webhook, kept across runs: which CVEs each webhook
+-- was last sent (so a CVE is not resent within the webhook's cooldown), and
+-- the notifications waiting to go out together as a digest.
+
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    webhook  TEXT        NOT NULL,
+    kind     TEXT        NOT NULL, -- 'sleeper' or 'kev_added'
+    item_key TEXT        NOT NULL, -- the CVE ID
+    sent_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (webhook, kind, item_key)
+);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_sent_at ON alert_deliveries (sent_at);
+
+CREATE TABLE IF NOT EXISTS alert_digest_queue (
+    id        BIGSERIAL   PRIMARY KEY,
+    webhook   TEXT        NOT NULL,
+    kind      TEXT        NOT NULL,
+    item_key  TEXT        NOT NULL,
+    item      JSONB       NOT NULL, -- the notification as sent
+    queued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (webhook, kind, item_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS alert_digest_queue;
+DROP TABLE IF EXISTS alert_deliveries;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Critical alerts: CVEs newly added to CISA KEV that affect a monitored
+-- product ([[nvd.products]], product_cves). Each waits for an analyst to
+-- acknowledge it (`tigerfetch ack`); one still unacknowledged after
+-- [alerting.escalation] after is escalated once to the escalation webhooks.
+
+CREATE TABLE IF NOT EXISTS critical_alerts (
+    cve_id       TEXT        PRIMARY KEY,
+    products     TEXT[]      NOT NULL,  -- the monitored products it affects
+    detected_at  TIMESTAMPTZ NOT NULL,  -- when the KEV addition was detected
+    acked_at     TIMESTAMPTZ,
+    acked_by     TEXT,
+    escalated_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_critical_alerts_open ON critical_alerts (detected_at)
+    WHERE acked_at IS NULL AND escalated_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS critical_alerts;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Data quality violations: ingested values that broke a plausibility rule
+-- (a CVSS score outside 0-10, an EPSS score outside 0-1, a date in the
+-- future, a missing mandatory field). The value was dropped or the record
+-- skipped; this is the trail of what upstream sent. Pruned after
+-- [quality] retention.
+
+CREATE TABLE IF NOT EXISTS data_quality_violations (
+    id          BIGSERIAL   PRIMARY KEY,
+    source      TEXT        NOT NULL,  -- NVD, EPSS, CISA-KEV or the feed name
+    record_id   TEXT        NOT NULL,  -- CVE ID or feed item GUID; '' for the whole payload
+    field       TEXT        NOT NULL,
+    rule        TEXT        NOT NULL,  -- required, range, number or future_date
+    value       TEXT        NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_data_quality_violations_detected ON data_quality_violations (detected_at);
+CREATE INDEX IF NOT EXISTS idx_data_quality_violations_source ON data_quality_violations (source, rule, detected_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS data_quality_violations;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Quarantine: upstream payloads (NVD pages, the KEV catalog, EPSS pages,
+-- feeds) that failed to parse, kept with the error so `tigerfetch replay`
+-- can re-process them after a parser fix. A payload fetched again is
+-- counted in attempts rather than stored twice.
+
+CREATE TABLE IF NOT EXISTS quarantine (
+    id            BIGSERIAL   PRIMARY KEY,
+    source        TEXT        NOT NULL,  -- nvd, kev, epss or feeds
+    name          TEXT        NOT NULL,  -- the feed name; '' for other sources
+    url           TEXT        NOT NULL,
+    payload       BYTEA       NOT NULL,
+    sha256        BYTEA       NOT NULL,
+    error         TEXT        NOT NULL,  -- the parse error, or that of the last replay
+    attempts      INT         NOT NULL DEFAULT 1,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    replayed_at   TIMESTAMPTZ,
+    UNIQUE (source, sha256)
+);
+CREATE INDEX IF NOT EXISTS idx_quarantine_last_seen ON quarantine (last_seen_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS quarantine;
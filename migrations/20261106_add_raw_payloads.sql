@@ -0,0 +1,24 @@
+-- +goose Up
+-- Raw payload archive ([raw_archive]): a gzip copy of every upstream
+-- payload (NVD pages, KEV catalogs, EPSS pages, feeds) so the derived tables
+-- can be rebuilt with `tigerfetch archive rebuild` instead of refetching from
+-- rate-limited upstreams. The payload is stored inline, or in object storage
+-- at object_url. A payload fetched again only moves last_fetched_at.
+
+CREATE TABLE IF NOT EXISTS raw_payloads (
+    id               BIGSERIAL   PRIMARY KEY,
+    source           TEXT        NOT NULL,  -- nvd, kev, epss or feeds
+    url              TEXT        NOT NULL,
+    sha256           BYTEA       NOT NULL,  -- of the uncompressed payload
+    size             INT         NOT NULL,  -- uncompressed bytes
+    payload          BYTEA,                 -- gzip; NULL when in object storage
+    object_url       TEXT,
+    first_fetched_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_fetched_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (source, sha256),
+    CHECK ((payload IS NULL) <> (object_url IS NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_raw_payloads_last_fetched ON raw_payloads (last_fetched_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS raw_payloads;
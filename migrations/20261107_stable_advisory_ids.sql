@@ -0,0 +1,22 @@
+-- +goose Up
+-- Advisory IDs were random (uuid_generate_v4), so the same item had one ID
+-- in archive and another in current, and a different one on every
+-- instance. They are now the UUIDv5 of feed URL and GUID in a namespace
+-- (ingestor.AdvisoryID); re-key stored rows with the built-in namespace,
+-- ingestor.DefaultIDNamespace.
+--
+-- Re-keyed current rows get a new updated_at, with the touch trigger off
+-- for the update, so API clients and mirrors paging by (updated_at, id)
+-- see every advisory once more under its new ID.
+
+UPDATE archive
+SET id = uuid_generate_v5('2f6ab692-a2b5-42df-9db8-38855ee8b261'::uuid, feed_url || E'\n' || guid);
+
+ALTER TABLE current DISABLE TRIGGER current_touch;
+UPDATE current
+SET id = uuid_generate_v5('2f6ab692-a2b5-42df-9db8-38855ee8b261'::uuid, feed_url || E'\n' || guid),
+    updated_at = clock_timestamp();
+ALTER TABLE current ENABLE TRIGGER current_touch;
+
+-- +goose Down
+-- The random IDs are gone; rows keep their derived ones.
@@ -0,0 +1,52 @@
+-- +goose Up
+-- Withdrawn advisories: vendors retract advisories by dropping them from
+-- their feed. An item missing from feed_withdraw_after fetches in a row,
+-- while older items are still listed, gets withdrawn_at set instead of
+-- being deleted; it is cleared if the item is listed again.
+-- missed_fetches counts the fetches it has been missing from.
+
+ALTER TABLE current
+    ADD COLUMN IF NOT EXISTS missed_fetches INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS withdrawn_at   TIMESTAMPTZ;
+
+-- A withdrawal is a change API clients and mirrors page for
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION current_touch() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT'
+       OR (NEW.title, NEW.link, NEW.published, NEW.content, NEW.summary, NEW.author,
+           NEW.categories, NEW.entry_updated, NEW.withdrawn_at)
+          IS DISTINCT FROM
+          (OLD.title, OLD.link, OLD.published, OLD.content, OLD.summary, OLD.author,
+           OLD.categories, OLD.entry_updated, OLD.withdrawn_at) THEN
+        NEW.updated_at := clock_timestamp();
+    ELSE
+        NEW.updated_at := OLD.updated_at;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION current_touch() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT'
+       OR (NEW.title, NEW.link, NEW.published, NEW.content, NEW.summary, NEW.author,
+           NEW.categories, NEW.entry_updated)
+          IS DISTINCT FROM
+          (OLD.title, OLD.link, OLD.published, OLD.content, OLD.summary, OLD.author,
+           OLD.categories, OLD.entry_updated) THEN
+        NEW.updated_at := clock_timestamp();
+    ELSE
+        NEW.updated_at := OLD.updated_at;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+ALTER TABLE current
+    DROP COLUMN IF EXISTS withdrawn_at,
+    DROP COLUMN IF EXISTS missed_fetches;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Canonical advisory links: link now holds the item's link with redirects
+-- through known tracking redirectors resolved and tracking parameters
+-- removed, so the same advisory has the same link in every feed.
+-- original_link keeps the feed's link when it differs.
+
+ALTER TABLE archive ADD COLUMN IF NOT EXISTS original_link TEXT;
+ALTER TABLE current ADD COLUMN IF NOT EXISTS original_link TEXT;
+
+-- +goose Down
+ALTER TABLE current DROP COLUMN IF EXISTS original_link;
+ALTER TABLE archive DROP COLUMN IF EXISTS original_link;
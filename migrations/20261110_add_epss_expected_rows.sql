@@ -0,0 +1,7 @@
+-- +goose Up
+-- The rows FIRST announced for an EPSS day, next to row_count. A day whose
+-- run failed keeps completed_at NULL and is re-ingested by the next run.
+ALTER TABLE epss_provenance ADD COLUMN IF NOT EXISTS expected_rows INT;
+
+-- +goose Down
+ALTER TABLE epss_provenance DROP COLUMN IF EXISTS expected_rows;
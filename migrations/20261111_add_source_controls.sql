@@ -0,0 +1,14 @@
+-- +goose Up
+-- Runtime switches for pipeline steps, set with `tigerfetch source
+-- enable|disable`. A source without a row runs as configured; one with
+-- enabled = false is skipped by the daemon and `tigerfetch run` until it
+-- is enabled again, without a config change or restart.
+
+CREATE TABLE IF NOT EXISTS source_controls (
+    source     TEXT        PRIMARY KEY, -- step name: nvd, kev, epss, feeds, merge, ...
+    enabled    BOOLEAN     NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS source_controls;
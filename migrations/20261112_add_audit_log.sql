@@ -0,0 +1,35 @@
+-- +goose Up
+-- Append-only audit trail of significant actions ([audit], internal/audit):
+-- source switches and cursor moves from the CLI, acknowledgements, webhook
+-- notifications. run ties together the events one process recorded.
+-- Rows are never changed: a trigger rejects UPDATE and DELETE, so the
+-- trail holds as compliance evidence.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id     BIGSERIAL   PRIMARY KEY,
+    at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    run    TEXT        NOT NULL,
+    actor  TEXT        NOT NULL,
+    action TEXT        NOT NULL, -- e.g. source.disable, cursor.reset, alert.ack, notification.send
+    target TEXT        NOT NULL,
+    detail JSONB
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_at_idx ON audit_log (at);
+CREATE INDEX IF NOT EXISTS audit_log_action_at_idx ON audit_log (action, at);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();